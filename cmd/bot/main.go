@@ -6,19 +6,27 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/Spok95/beauty-bot/internal/access"
 	"github.com/Spok95/beauty-bot/internal/bot"
 	"github.com/Spok95/beauty-bot/internal/config"
 	"github.com/Spok95/beauty-bot/internal/dialog"
 	"github.com/Spok95/beauty-bot/internal/domain/adminchat"
+	"github.com/Spok95/beauty-bot/internal/domain/audit"
 	"github.com/Spok95/beauty-bot/internal/domain/brands"
+	"github.com/Spok95/beauty-bot/internal/domain/broadcasts"
 	"github.com/Spok95/beauty-bot/internal/domain/catalog"
 	"github.com/Spok95/beauty-bot/internal/domain/consumption"
+	"github.com/Spok95/beauty-bot/internal/domain/discounts"
 	"github.com/Spok95/beauty-bot/internal/domain/inventory"
+	"github.com/Spok95/beauty-bot/internal/domain/invites"
 	"github.com/Spok95/beauty-bot/internal/domain/materials"
+	"github.com/Spok95/beauty-bot/internal/domain/reports"
 	subs "github.com/Spok95/beauty-bot/internal/domain/subscriptions"
+	"github.com/Spok95/beauty-bot/internal/domain/templates"
 	"github.com/Spok95/beauty-bot/internal/domain/users"
 	"github.com/Spok95/beauty-bot/internal/infra/db"
 	httpx "github.com/Spok95/beauty-bot/internal/infra/http"
@@ -103,7 +111,22 @@ func main() {
 	inventoryRepo := inventory.NewRepo(pool)
 	consRepo := consumption.NewRepo(pool)
 	subsRepo := subs.NewRepo(pool)
-	adminIDs := cfg.Telegram.AdminIDs
+	templatesRepo := templates.NewRepo(pool)
+	invitesRepo := invites.NewRepo(pool)
+	auditRepo := audit.NewRepo(pool)
+	discountsRepo := discounts.NewRepo(pool)
+	reportsRepo := reports.NewRepo(pool)
+	broadcastsRepo := broadcasts.NewRepo(pool)
+
+	// admin_ids из конфигурации нужны только для первого запуска: дальше админы живут в user_roles
+	bootstrapped, err := usersRepo.BootstrapAdmins(ctx, cfg.Telegram.AdminIDs)
+	if err != nil {
+		log.Error("admin bootstrap failed", "err", err)
+		return
+	}
+	if bootstrapped > 0 {
+		log.Info("admins bootstrapped from config", "count", bootstrapped)
+	}
 
 	srv := httpx.New(cfg.HTTP.Addr, cfg.Metrics.Enabled)
 
@@ -130,14 +153,51 @@ func main() {
 		api.Debug = true
 	}
 
-	tg := bot.New(api, log, usersRepo, stateRepo, adminChatRepo, cfg.Telegram.AdminChatID, adminIDs, catalogRepo, materialsRepo, brandRepo, inventoryRepo, consRepo, subsRepo, paymentsSvc)
+	policy, err := access.NewPolicy(cfg.Permissions)
+	if err != nil {
+		log.Error("permissions config invalid", "err", err)
+		return
+	}
 
-	go func() {
-		if err := tg.Run(ctx, cfg.Telegram.RequestTimeoutSec); err != nil {
-			log.Error("telegram runtime error", "err", err)
+	// отчёты по расписанию и рассылки считаются в часовом поясе салона (app.timezone)
+	loc, err := time.LoadLocation(cfg.App.Timezone)
+	if err != nil {
+		log.Warn("unknown app timezone, using local", "tz", cfg.App.Timezone, "err", err)
+		loc = time.Local
+	}
+
+	tg := bot.New(api, log, usersRepo, stateRepo, adminChatRepo, cfg.Telegram.AdminChatID, catalogRepo, materialsRepo, brandRepo, inventoryRepo, consRepo, subsRepo, paymentsSvc, templatesRepo, invitesRepo, auditRepo, discountsRepo, reportsRepo, broadcastsRepo, policy, loc)
+
+	wh := cfg.Telegram.Webhook
+	if wh.Enabled {
+		secret := wh.Secret
+		if secret == "" {
+			secret = bot.NewWebhookSecret()
 		}
-	}()
-	log.Info("telegram bot started")
+		srv.Handle(wh.Path, tg.WebhookHandler(secret))
+		opts := bot.WebhookOptions{
+			URL:            strings.TrimRight(wh.URL, "/") + wh.Path,
+			Secret:         secret,
+			MaxConnections: wh.MaxConnections,
+		}
+		go func() {
+			if err := tg.RunWebhook(ctx, opts, cfg.Telegram.RequestTimeoutSec); err != nil {
+				log.Error("telegram runtime error", "err", err)
+			}
+		}()
+		log.Info("telegram bot started", "mode", "webhook", "path", wh.Path)
+	} else {
+		go func() {
+			if err := tg.Run(ctx, cfg.Telegram.RequestTimeoutSec); err != nil {
+				log.Error("telegram runtime error", "err", err)
+			}
+		}()
+		log.Info("telegram bot started", "mode", "polling")
+	}
+
+	go tg.RunReportScheduler(ctx)
+	go tg.RunBroadcastQueue(ctx)
+	go tg.RunAdminChatSLA(ctx, time.Duration(cfg.AdminChat.SLAMinutes)*time.Minute)
 
 	<-ctx.Done()
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -0,0 +1,147 @@
+package access
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Spok95/beauty-bot/internal/domain/users"
+)
+
+// Capability — именованное право на действие в боте.
+type Capability string
+
+const (
+	CapWarehousesManage Capability = "warehouses.manage" // склады
+	CapCatalogManage    Capability = "catalog.manage"    // категории, материалы, бренды, шаблоны
+	CapStockManage      Capability = "stock.manage"      // инвентаризация: просмотр, приход/списание, выгрузка
+	CapStockImport      Capability = "stock.import"      // загрузка остатков из Excel
+	CapSuppliesManage   Capability = "supplies.manage"   // поставки и журнал поставок
+	CapPricesEdit       Capability = "prices.edit"       // цены материалов и тарифы аренды (Excel)
+	CapRatesEdit        Capability = "rates.edit"        // ступени тарифов аренды
+	CapSubsManage       Capability = "subs.manage"       // ручное оформление абонементов
+	CapSubsApprove      Capability = "subs.approve"      // подтверждение покупки абонемента
+	CapReportsView      Capability = "reports.view"      // отчёты по мастерам
+	CapBroadcast        Capability = "broadcast.send"    // рассылки
+	CapUsersManage      Capability = "users.manage"      // раздел «Пользователи»
+	CapUsersApprove     Capability = "users.approve"     // одобрение заявок на доступ
+	CapAuditView        Capability = "audit.view"        // журнал изменений и его выгрузка
+	CapChatUse          Capability = "chat.use"          // писать в чат с админом
+	CapChatHistory      Capability = "chat.history"      // история админ-чата и ответы
+	CapConsUse          Capability = "cons.use"          // расход/аренда, текущий чек
+	CapStockBrowse      Capability = "stock.browse"      // просмотр остатков мастером
+	CapSubsBuy          Capability = "subs.buy"          // свои абонементы и покупка
+)
+
+// All — все известные права (для проверки конфигурации).
+var All = []Capability{
+	CapWarehousesManage, CapCatalogManage, CapStockManage, CapStockImport,
+	CapSuppliesManage, CapPricesEdit, CapRatesEdit, CapSubsManage, CapSubsApprove,
+	CapReportsView, CapBroadcast, CapUsersManage, CapUsersApprove, CapAuditView,
+	CapChatUse, CapChatHistory, CapConsUse, CapStockBrowse, CapSubsBuy,
+}
+
+// defaults — права ролей «из коробки» (повторяют прежние проверки в боте).
+var defaults = map[users.Role][]Capability{
+	users.RoleAdmin: {
+		CapWarehousesManage, CapCatalogManage, CapStockManage, CapStockImport,
+		CapSuppliesManage, CapPricesEdit, CapRatesEdit, CapSubsManage, CapSubsApprove,
+		CapReportsView, CapBroadcast, CapUsersManage, CapUsersApprove, CapAuditView,
+		CapChatUse, CapChatHistory,
+	},
+	users.RoleAdministrator: {
+		CapCatalogManage, CapStockManage, CapStockImport, CapSuppliesManage,
+		CapChatUse, CapChatHistory,
+	},
+	users.RoleMaster: {
+		CapChatUse, CapConsUse, CapStockBrowse, CapSubsBuy,
+	},
+}
+
+// Policy — соответствие ролей и прав.
+type Policy struct {
+	roles map[users.Role]map[Capability]struct{}
+}
+
+// NewPolicy строит политику из умолчаний; overrides (роль → список прав)
+// полностью заменяет набор прав указанной роли.
+func NewPolicy(overrides map[string][]string) (*Policy, error) {
+	known := make(map[Capability]struct{}, len(All))
+	for _, c := range All {
+		known[c] = struct{}{}
+	}
+
+	p := &Policy{roles: make(map[users.Role]map[Capability]struct{}, len(defaults))}
+	for role, caps := range defaults {
+		p.set(role, caps)
+	}
+
+	for roleStr, list := range overrides {
+		role := users.Role(roleStr)
+		if !isKnownRole(role) {
+			return nil, fmt.Errorf("permissions: неизвестная роль %q", roleStr)
+		}
+		caps := make([]Capability, 0, len(list))
+		for _, s := range list {
+			c := Capability(s)
+			if _, ok := known[c]; !ok {
+				return nil, fmt.Errorf("permissions: неизвестное право %q у роли %q", s, roleStr)
+			}
+			caps = append(caps, c)
+		}
+		p.set(role, caps)
+	}
+	return p, nil
+}
+
+// DefaultPolicy — политика без переопределений.
+func DefaultPolicy() *Policy {
+	p, _ := NewPolicy(nil)
+	return p
+}
+
+func (p *Policy) set(role users.Role, caps []Capability) {
+	m := make(map[Capability]struct{}, len(caps))
+	for _, c := range caps {
+		m[c] = struct{}{}
+	}
+	p.roles[role] = m
+}
+
+// Allows — есть ли у роли право.
+func (p *Policy) Allows(role users.Role, c Capability) bool {
+	if p == nil {
+		return false
+	}
+	_, ok := p.roles[role][c]
+	return ok
+}
+
+// RolesWith — роли, которым выдано право (в порядке users.AllRoles).
+func (p *Policy) RolesWith(c Capability) []users.Role {
+	var out []users.Role
+	for _, r := range users.AllRoles {
+		if p.Allows(r, c) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Capabilities — отсортированный список прав роли.
+func (p *Policy) Capabilities(role users.Role) []Capability {
+	out := make([]Capability, 0, len(p.roles[role]))
+	for c := range p.roles[role] {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+func isKnownRole(r users.Role) bool {
+	for _, x := range users.AllRoles {
+		if x == r {
+			return true
+		}
+	}
+	return false
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/Spok95/beauty-bot/internal/access"
 	"github.com/Spok95/beauty-bot/internal/dialog"
 	"github.com/Spok95/beauty-bot/internal/domain/adminchat"
 	"github.com/Spok95/beauty-bot/internal/domain/users"
@@ -13,16 +14,9 @@ import (
 
 func (b *Bot) handleAdminChatMessage(ctx context.Context, msg *tgbotapi.Message) {
 	chatID := msg.Chat.ID
-	tgID := msg.From.ID
 
-	if len(b.adminIDs) == 0 {
-		b.send(tgbotapi.NewMessage(chatID,
-			"Админ-чат не настроен. Сообщение не отправлено."))
-		return
-	}
-
-	u, _ := b.users.GetByTelegramID(ctx, tgID)
-	if u == nil || u.Status != users.StatusApproved {
+	u := b.currentUser(ctx)
+	if u == nil {
 		b.send(tgbotapi.NewMessage(chatID, "Нет доступа к чату."))
 		return
 	}
@@ -43,6 +37,22 @@ func (b *Bot) handleAdminChatMessage(ctx context.Context, msg *tgbotapi.Message)
 		}
 	}
 
+	// остальные файлы альбома адресованы тому же сообщению, что и первый
+	if in.TelegramMediaGroupID != "" && in.ReplyToMessageID == 0 {
+		in.ReplyToMessageID = b.pendingAlbumReplyTo(chatID, in.TelegramMediaGroupID)
+	}
+
+	staff := b.can(ctx, access.CapChatHistory)
+
+	threadID, err := b.adminChatThreadFor(ctx, u, in.ReplyToMessageID, staff)
+	if err != nil {
+		b.log.Error("failed to resolve admin chat thread", "err", err)
+		b.send(tgbotapi.NewMessage(chatID,
+			"Не удалось сохранить сообщение. Попробуйте позже."))
+		return
+	}
+	in.ThreadID = threadID
+
 	stored, err := b.adminChatRepo.Create(ctx, in)
 	if err != nil {
 		b.log.Error("failed to store admin chat message", "err", err)
@@ -56,28 +66,64 @@ func (b *Bot) handleAdminChatMessage(ctx context.Context, msg *tgbotapi.Message)
 		_ = b.states.Set(ctx, chatID, dialog.StateChatAdmin, st.Payload)
 	}
 
-	recipients := b.adminChatRecipients(ctx, msg.Chat.ID)
-	if len(recipients) == 0 {
+	thread := b.touchAdminChatThread(ctx, threadID, u)
+
+	// файлы альбома приходят отдельными сообщениями: копим и пересылаем одним альбомом
+	if stored.TelegramMediaGroupID != "" {
+		b.bufferAdminChatAlbum(ctx, chatID, u, staff, thread, stored)
+		return
+	}
+
+	b.relayAdminChatMessage(ctx, chatID, u, staff, thread, []adminchat.Message{*stored})
+}
+
+// relayAdminChatMessage пересылает сохранённое сообщение (или альбом — items по порядку)
+// администраторам и автору исходного сообщения, если это ответ, и подтверждает отправителю.
+func (b *Bot) relayAdminChatMessage(ctx context.Context, chatID int64, u *users.User, staff bool, thread *adminchat.Thread, items []adminchat.Message) {
+	first := &items[0]
+
+	recipients := b.adminChatRecipients(ctx, chatID, thread)
+	if len(recipients) == 0 && !staff {
 		b.send(tgbotapi.NewMessage(chatID,
 			"Сообщение сохранено, но нет других администраторов для пересылки."))
 		return
 	}
 
-	header := b.adminChatHeader(stored, u)
+	header := b.adminChatHeader(ctx, first, u)
+	if len(items) > 1 {
+		header += fmt.Sprintf("\nАльбом: %d файлов (#%d–#%d)", len(items), first.ID, items[len(items)-1].ID)
+	}
+	if thread != nil {
+		header += "\n" + adminChatThreadLine(thread)
+	}
 
 	for _, adminID := range recipients {
-		b.send(tgbotapi.NewMessage(adminID, header))
-		b.sendAdminChatPayload(adminID, stored)
+		hm := tgbotapi.NewMessage(adminID, header)
+		if thread != nil {
+			hm.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("📂 Открыть обращение", fmt.Sprintf("adminchat:open:%d", thread.ID)),
+			))
+		}
+		b.send(hm)
+		b.sendAdminChatAlbum(adminID, items)
 	}
 
-	b.forwardAdminReplyToOriginalSender(ctx, stored, u)
+	if staff {
+		b.forwardAdminReplyToOriginalSender(ctx, items, u)
+	}
 
 	doneText := fmt.Sprintf(
 		"Сообщение сохранено и отправлено администраторам. ID: #%d",
-		stored.ID,
+		first.ID,
 	)
+	if len(items) > 1 {
+		doneText = fmt.Sprintf(
+			"Альбом из %d файлов сохранён и отправлен администраторам. ID: #%d",
+			len(items), first.ID,
+		)
+	}
 
-	if stored.ReplyToMessageID > 0 && (u.Role == users.RoleAdmin || u.Role == users.RoleAdministrator) {
+	if first.ReplyToMessageID > 0 && staff {
 		doneText += "\nОтвет также отправлен автору исходного сообщения."
 	}
 
@@ -149,7 +195,52 @@ func buildAdminChatInput(msg *tgbotapi.Message, u *users.User) adminchat.CreateM
 	return in
 }
 
-func (b *Bot) adminChatRecipients(ctx context.Context, senderChatID int64) []int64 {
+// adminChatThreadFor — обращение, к которому относится новое сообщение: ответ администрации
+// попадает в обращение исходного сообщения, остальное — в обращение самого отправителя.
+func (b *Bot) adminChatThreadFor(ctx context.Context, u *users.User, replyToID int64, staff bool) (int64, error) {
+	if staff && replyToID > 0 {
+		parent, err := b.adminChatRepo.GetByID(ctx, replyToID)
+		if err == nil && parent != nil {
+			if parent.ThreadID > 0 {
+				return parent.ThreadID, nil
+			}
+			if parent.SenderUserID > 0 {
+				return b.adminChatRepo.EnsureThread(ctx, parent.SenderUserID)
+			}
+		}
+	}
+	return b.adminChatRepo.EnsureThread(ctx, u.ID)
+}
+
+// touchAdminChatThread обновляет статус обращения после сообщения u и возвращает обращение
+// (nil — не удалось загрузить). Сообщение автора ждёт ответа, ответ администрации его снимает.
+func (b *Bot) touchAdminChatThread(ctx context.Context, threadID int64, u *users.User) *adminchat.Thread {
+	t, err := b.adminChatRepo.GetThread(ctx, threadID, u.ID)
+	if err != nil || t == nil {
+		b.log.Error("failed to load admin chat thread", "thread", threadID, "err", err)
+		return nil
+	}
+	if t.UserID == u.ID {
+		err = b.adminChatRepo.TouchByAuthor(ctx, threadID)
+	} else {
+		err = b.adminChatRepo.TouchByStaff(ctx, threadID, u.ID)
+	}
+	if err != nil {
+		b.log.Error("failed to update admin chat thread", "thread", threadID, "err", err)
+	}
+	// своё сообщение отправитель уже видел
+	if err := b.adminChatRepo.MarkRead(ctx, threadID, u.ID); err != nil {
+		b.log.Warn("failed to mark admin chat thread read", "thread", threadID, "err", err)
+	}
+	if fresh, err := b.adminChatRepo.GetThread(ctx, threadID, u.ID); err == nil && fresh != nil {
+		return fresh
+	}
+	return t
+}
+
+// adminChatRecipients — кого уведомить о сообщении: ответственного по обращению,
+// а если его нет (или пишет он сам) — всех, кто читает админ-чат.
+func (b *Bot) adminChatRecipients(ctx context.Context, senderChatID int64, t *adminchat.Thread) []int64 {
 	seen := map[int64]struct{}{}
 	out := []int64{}
 
@@ -166,26 +257,24 @@ func (b *Bot) adminChatRecipients(ctx context.Context, senderChatID int64) []int
 		out = append(out, tgID)
 	}
 
-	for adminID := range b.adminIDs {
-		add(adminID)
-	}
-
-	if admins, err := b.users.ListByRole(ctx, users.RoleAdmin, users.StatusApproved); err == nil {
-		for _, u := range admins {
-			add(u.TelegramID)
+	if t != nil && t.AssigneeID != 0 {
+		if a, err := b.users.GetByID(ctx, t.AssigneeID); err == nil && a != nil && a.TelegramID != senderChatID {
+			add(a.TelegramID)
+			if len(out) > 0 {
+				return out
+			}
 		}
 	}
 
-	if administrators, err := b.users.ListByRole(ctx, users.RoleAdministrator, users.StatusApproved); err == nil {
-		for _, u := range administrators {
-			add(u.TelegramID)
-		}
+	// получатели — все, кто читает админ-чат (по умолчанию admin и administrator)
+	for _, tgID := range b.recipientsWith(ctx, access.CapChatHistory) {
+		add(tgID)
 	}
 
 	return out
 }
 
-func (b *Bot) adminChatHeader(m *adminchat.Message, u *users.User) string {
+func (b *Bot) adminChatHeader(ctx context.Context, m *adminchat.Message, u *users.User) string {
 	role := roleLabel(users.Role(m.SenderRole))
 
 	if role == "" {
@@ -197,6 +286,21 @@ func (b *Bot) adminChatHeader(m *adminchat.Message, u *users.User) string {
 		name = strings.TrimSpace(u.Username)
 	}
 
+	// контакты и специализация — из профиля отправителя
+	profilePart := ""
+	if u != nil {
+		p := b.profileOf(ctx, u)
+		if full := p.FullName(); full != "" {
+			name = full
+		}
+		if p.Phone != "" {
+			profilePart += "\nТелефон: " + p.Phone
+		}
+		if len(p.Specializations) > 0 {
+			profilePart += "\nСпециализация: " + specializationsLabel(p.Specializations)
+		}
+	}
+
 	if name == "" {
 		name = fmt.Sprintf("id %d", m.SenderTelegramID)
 	}
@@ -208,11 +312,12 @@ func (b *Bot) adminChatHeader(m *adminchat.Message, u *users.User) string {
 	}
 
 	return fmt.Sprintf(
-		"💬 Админ-чат #%d%s\nОт: %s\nРоль: %s\nTelegram ID: %d\nТип: %s",
+		"💬 Админ-чат #%d%s\nОт: %s\nРоль: %s%s\nTelegram ID: %d\nТип: %s",
 		m.ID,
 		replyPart,
 		name,
 		role,
+		profilePart,
 		m.SenderTelegramID,
 		adminChatMessageTypeLabel(m.MessageType),
 	)
@@ -286,12 +391,20 @@ func adminChatCancelKeyboard() tgbotapi.InlineKeyboardMarkup {
 	)
 }
 
-func (b *Bot) sendAdminChatHistoryMedia(chatID int64, m *adminchat.Message) {
+func (b *Bot) sendAdminChatHistoryMedia(ctx context.Context, chatID int64, m *adminchat.Message) {
 	if m.TelegramFileID == "" {
 		b.send(tgbotapi.NewMessage(chatID, "У этого сообщения нет вложения."))
 		return
 	}
 
+	album := b.adminChatAlbumOf(ctx, m)
+	if len(album) > 1 {
+		b.send(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+			"📎 Альбом из истории #%d\nФайлов: %d", album[0].ID, len(album))))
+		b.sendAdminChatAlbum(chatID, album)
+		return
+	}
+
 	header := fmt.Sprintf(
 		"📎 Вложение из истории #%d\nТип: %s",
 		m.ID,
@@ -310,14 +423,11 @@ func (b *Bot) sendAdminChatHistoryMedia(chatID int64, m *adminchat.Message) {
 	b.sendAdminChatPayload(chatID, m)
 }
 
-func (b *Bot) forwardAdminReplyToOriginalSender(ctx context.Context, stored *adminchat.Message, sender *users.User) {
-	if stored == nil || stored.ReplyToMessageID <= 0 {
-		return
-	}
-
-	if sender == nil || sender.Role != users.RoleAdmin && sender.Role != users.RoleAdministrator {
+func (b *Bot) forwardAdminReplyToOriginalSender(ctx context.Context, items []adminchat.Message, sender *users.User) {
+	if len(items) == 0 || items[0].ReplyToMessageID <= 0 || sender == nil {
 		return
 	}
+	stored := &items[0]
 
 	parent, err := b.adminChatRepo.GetByID(ctx, stored.ReplyToMessageID)
 	if err != nil || parent == nil {
@@ -340,13 +450,23 @@ func (b *Bot) forwardAdminReplyToOriginalSender(ctx context.Context, stored *adm
 		adminName = fmt.Sprintf("id %d", stored.SenderTelegramID)
 	}
 
+	target := "ваше сообщение"
+	if parent.TelegramMediaGroupID != "" {
+		target = "ваш альбом"
+	}
+	kind := adminChatMessageTypeLabel(stored.MessageType)
+	if len(items) > 1 {
+		kind = fmt.Sprintf("альбом, %d файлов", len(items))
+	}
+
 	header := fmt.Sprintf(
-		"💬 Ответ администратора на ваше сообщение #%d\nОт: %s\nТип: %s",
+		"💬 Ответ администратора на %s #%d\nОт: %s\nТип: %s",
+		target,
 		parent.ID,
 		adminName,
-		adminChatMessageTypeLabel(stored.MessageType),
+		kind,
 	)
 
 	b.send(tgbotapi.NewMessage(parent.SenderTelegramID, header))
-	b.sendAdminChatPayload(parent.SenderTelegramID, stored)
+	b.sendAdminChatAlbum(parent.SenderTelegramID, items)
 }
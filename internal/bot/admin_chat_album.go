@@ -0,0 +1,147 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Spok95/beauty-bot/internal/domain/adminchat"
+	"github.com/Spok95/beauty-bot/internal/domain/users"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// adminChatAlbumWait — сколько ждать следующий файл альбома: Telegram присылает их
+// отдельными сообщениями почти одновременно.
+const adminChatAlbumWait = 1500 * time.Millisecond
+
+// adminChatAlbum — альбом, файлы которого ещё приходят.
+type adminChatAlbum struct {
+	items   []adminchat.Message
+	replyTo int64
+	thread  *adminchat.Thread
+	timer   *time.Timer
+}
+
+func adminChatAlbumKey(chatID int64, groupID string) string {
+	return fmt.Sprintf("%d:%s", chatID, groupID)
+}
+
+// pendingAlbumReplyTo — на какое сообщение отвечает собираемый альбом (0 — не ответ или альбома нет).
+func (b *Bot) pendingAlbumReplyTo(chatID int64, groupID string) int64 {
+	b.albumsMu.Lock()
+	defer b.albumsMu.Unlock()
+	if a := b.albums[adminChatAlbumKey(chatID, groupID)]; a != nil {
+		return a.replyTo
+	}
+	return 0
+}
+
+// bufferAdminChatAlbum добавляет сохранённый файл в альбом; альбом пересылается,
+// когда adminChatAlbumWait не приходит новых файлов.
+func (b *Bot) bufferAdminChatAlbum(ctx context.Context, chatID int64, u *users.User, staff bool, thread *adminchat.Thread, m *adminchat.Message) {
+	key := adminChatAlbumKey(chatID, m.TelegramMediaGroupID)
+
+	b.albumsMu.Lock()
+	defer b.albumsMu.Unlock()
+
+	a := b.albums[key]
+	if a == nil {
+		a = &adminChatAlbum{replyTo: m.ReplyToMessageID}
+		a.timer = time.AfterFunc(adminChatAlbumWait, func() {
+			b.flushAdminChatAlbum(ctx, key, chatID, u, staff)
+		})
+		b.albums[key] = a
+	} else {
+		a.timer.Reset(adminChatAlbumWait)
+	}
+	a.items = append(a.items, *m)
+	if thread != nil {
+		a.thread = thread
+	}
+}
+
+func (b *Bot) flushAdminChatAlbum(ctx context.Context, key string, chatID int64, u *users.User, staff bool) {
+	b.albumsMu.Lock()
+	a := b.albums[key]
+	delete(b.albums, key)
+	b.albumsMu.Unlock()
+
+	if a == nil || len(a.items) == 0 {
+		return
+	}
+	sort.Slice(a.items, func(i, j int) bool { return a.items[i].ID < a.items[j].ID })
+	b.relayAdminChatMessage(ctx, chatID, u, staff, a.thread, a.items)
+}
+
+// sendAdminChatAlbum отправляет файлы одним альбомом; одиночное сообщение или текст — как обычно.
+// Если Telegram не принял альбом, файлы уходят по одному.
+func (b *Bot) sendAdminChatAlbum(chatID int64, items []adminchat.Message) {
+	if len(items) == 1 {
+		b.sendAdminChatPayload(chatID, &items[0])
+		return
+	}
+
+	media := make([]interface{}, 0, len(items))
+	for _, m := range items {
+		file := tgbotapi.FileID(m.TelegramFileID)
+		switch m.MessageType {
+		case "photo":
+			im := tgbotapi.NewInputMediaPhoto(file)
+			im.Caption = m.Caption
+			media = append(media, im)
+		case "video":
+			im := tgbotapi.NewInputMediaVideo(file)
+			im.Caption = m.Caption
+			media = append(media, im)
+		case "document":
+			im := tgbotapi.NewInputMediaDocument(file)
+			im.Caption = m.Caption
+			media = append(media, im)
+		case "audio":
+			im := tgbotapi.NewInputMediaAudio(file)
+			im.Caption = m.Caption
+			media = append(media, im)
+		}
+	}
+
+	// в альбоме Telegram от 2 до 10 файлов
+	if len(media) >= 2 && len(media) <= 10 && len(media) == len(items) {
+		_, err := b.api.SendMediaGroup(tgbotapi.NewMediaGroup(chatID, media))
+		if err == nil {
+			return
+		}
+		b.log.Warn("send media group failed, sending one by one", "chat", chatID, "err", err)
+	}
+	for i := range items {
+		b.sendAdminChatPayload(chatID, &items[i])
+	}
+}
+
+// adminChatAlbumOf — весь альбом, в который входит m (или только m, если это не альбом).
+func (b *Bot) adminChatAlbumOf(ctx context.Context, m *adminchat.Message) []adminchat.Message {
+	if m.TelegramMediaGroupID == "" {
+		return []adminchat.Message{*m}
+	}
+	items, err := b.adminChatRepo.MediaGroup(ctx, m.SenderTelegramID, m.TelegramMediaGroupID)
+	if err != nil || len(items) == 0 {
+		return []adminchat.Message{*m}
+	}
+	return items
+}
+
+// groupAdminChatAlbums объединяет идущие подряд файлы одного альбома в один элемент истории.
+func groupAdminChatAlbums(list []adminchat.Message) [][]adminchat.Message {
+	var out [][]adminchat.Message
+	for _, m := range list {
+		if n := len(out); n > 0 && m.TelegramMediaGroupID != "" {
+			last := out[n-1]
+			if last[0].TelegramMediaGroupID == m.TelegramMediaGroupID && last[0].SenderTelegramID == m.SenderTelegramID {
+				out[n-1] = append(last, m)
+				continue
+			}
+		}
+		out = append(out, []adminchat.Message{m})
+	}
+	return out
+}
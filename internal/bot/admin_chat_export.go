@@ -0,0 +1,220 @@
+package bot
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Spok95/beauty-bot/internal/domain/adminchat"
+	"github.com/Spok95/beauty-bot/internal/domain/users"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	// Bot API отдаёт файлы до 20 МБ; отправить документ можно до 50 МБ — архив держим с запасом.
+	adminChatExportMaxFile    = 20 << 20
+	adminChatExportMaxArchive = 45 << 20
+
+	// adminChatExportTimeout — предел на всю выгрузку; недокачанные вложения отмечаются в таблице.
+	adminChatExportTimeout = 10 * time.Minute
+)
+
+// adminChatAttachmentName — имя вложения в архиве: ID сообщения и исходное имя (или расширение по типу).
+func adminChatAttachmentName(m adminchat.Message) string {
+	name := strings.NewReplacer("/", "_", "\\", "_").Replace(strings.TrimSpace(m.FileName))
+	if name == "" {
+		ext := ""
+		switch m.MessageType {
+		case "photo":
+			ext = ".jpg"
+		case "video":
+			ext = ".mp4"
+		case "voice":
+			ext = ".ogg"
+		case "audio":
+			ext = ".mp3"
+		default:
+			if list, _ := mime.ExtensionsByType(m.MimeType); len(list) > 0 {
+				ext = list[0]
+			}
+		}
+		name = m.MessageType + ext
+	}
+	return path.Join("files", fmt.Sprintf("%d_%s", m.ID, name))
+}
+
+// startAdminChatExport запускает выгрузку обращения в фоне: скачивание вложений может занять
+// минуты, а цикл обработки обновлений один. Файл придёт отдельным сообщением, когда будет готов.
+// false — выгрузка этого обращения уже идёт.
+func (b *Bot) startAdminChatExport(ctx context.Context, chatID, threadID int64) bool {
+	b.exportsMu.Lock()
+	if b.exports[threadID] {
+		b.exportsMu.Unlock()
+		return false
+	}
+	b.exports[threadID] = true
+	b.exportsMu.Unlock()
+
+	go func() {
+		defer func() {
+			b.exportsMu.Lock()
+			delete(b.exports, threadID)
+			b.exportsMu.Unlock()
+		}()
+		ctx, cancel := context.WithTimeout(ctx, adminChatExportTimeout)
+		defer cancel()
+		b.exportAdminChatThread(ctx, chatID, threadID)
+	}()
+	return true
+}
+
+// exportAdminChatThread выгружает переписку обращения: xlsx, а если есть вложения — zip
+// с таблицей и скачанными из Telegram файлами. Что не удалось скачать, отмечено в таблице.
+func (b *Bot) exportAdminChatThread(ctx context.Context, chatID int64, threadID int64) {
+	u := b.currentUser(ctx)
+	if u == nil {
+		return
+	}
+	t, err := b.adminChatRepo.GetThread(ctx, threadID, u.ID)
+	if err != nil || t == nil {
+		b.send(tgbotapi.NewMessage(chatID, "Обращение не найдено."))
+		return
+	}
+	list, err := b.adminChatRepo.ThreadHistory(ctx, threadID)
+	if err != nil {
+		b.log.Error("admin chat export failed", "thread", threadID, "err", err)
+		b.send(tgbotapi.NewMessage(chatID, "Ошибка загрузки переписки"))
+		return
+	}
+	if len(list) == 0 {
+		b.send(tgbotapi.NewMessage(chatID, "В обращении нет сообщений."))
+		return
+	}
+
+	// вложения: имя в архиве или причина, по которой файла нет
+	files := map[string][]byte{}
+	var names []string
+	notes := map[int64]string{}
+	size := 0
+	for _, m := range list {
+		if m.TelegramFileID == "" {
+			continue
+		}
+		if m.FileSize > adminChatExportMaxFile {
+			notes[m.ID] = "не скачан: больше 20 МБ"
+			continue
+		}
+		if ctx.Err() != nil {
+			notes[m.ID] = "не скачан: выгрузка заняла слишком много времени"
+			continue
+		}
+		data, err := b.downloadTelegramFile(ctx, m.TelegramFileID)
+		if err != nil {
+			b.log.Warn("admin chat attachment download failed", "message", m.ID, "err", err)
+			// текст ошибки не пишем в файл: в нём может быть ссылка с токеном бота
+			notes[m.ID] = "не скачан"
+			continue
+		}
+		if size+len(data) > adminChatExportMaxArchive {
+			notes[m.ID] = "не поместился в архив"
+			continue
+		}
+		name := adminChatAttachmentName(m)
+		files[name] = data
+		names = append(names, name)
+		notes[m.ID] = name
+		size += len(data)
+	}
+
+	f := excelize.NewFile()
+	defer func() { _ = f.Close() }()
+
+	sheet := f.GetSheetName(f.GetActiveSheetIndex())
+	header := []interface{}{
+		"ID", "Дата", "Отправитель", "Роль", "Тип", "Текст", "Подпись", "Ответ на", "Файл", "Вложение",
+	}
+	if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
+		b.send(tgbotapi.NewMessage(chatID, "Ошибка формирования файла (заголовок)"))
+		return
+	}
+	for i, m := range list {
+		reply := ""
+		if m.ReplyToMessageID > 0 {
+			reply = fmt.Sprintf("#%d", m.ReplyToMessageID)
+		}
+		sender := strings.TrimSpace(m.SenderUsername)
+		if m.SenderUserID == t.UserID {
+			sender = t.UserName
+		}
+		row := []interface{}{
+			m.ID,
+			m.CreatedAt.In(b.loc).Format("02.01.2006 15:04:05"),
+			orDash(sender),
+			roleLabel(users.Role(m.SenderRole)),
+			adminChatMessageTypeLabel(m.MessageType),
+			m.Text,
+			m.Caption,
+			reply,
+			m.FileName,
+			notes[m.ID],
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			b.send(tgbotapi.NewMessage(chatID, "Ошибка формирования файла"))
+			return
+		}
+	}
+	_ = f.SetColWidth(sheet, "B", "D", 18)
+	_ = f.SetColWidth(sheet, "F", "G", 50)
+	_ = f.SetColWidth(sheet, "J", "J", 40)
+
+	xlsx := &bytes.Buffer{}
+	if err := f.Write(xlsx); err != nil {
+		b.send(tgbotapi.NewMessage(chatID, "Ошибка записи файла"))
+		return
+	}
+
+	base := fmt.Sprintf("admin_chat_%d", t.ID)
+	caption := fmt.Sprintf("Переписка обращения #%d — %s: %d сообщ.", t.ID, orDash(t.UserName), len(list))
+	if len(files) == 0 {
+		doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: base + ".xlsx", Bytes: xlsx.Bytes()})
+		doc.Caption = caption
+		b.send(doc)
+		return
+	}
+
+	archive := &bytes.Buffer{}
+	zw := zip.NewWriter(archive)
+	add := func(name string, data []byte) error {
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	if err := add(base+".xlsx", xlsx.Bytes()); err != nil {
+		b.send(tgbotapi.NewMessage(chatID, "Ошибка формирования архива"))
+		return
+	}
+	for _, name := range names {
+		if err := add(name, files[name]); err != nil {
+			b.send(tgbotapi.NewMessage(chatID, "Ошибка формирования архива"))
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		b.send(tgbotapi.NewMessage(chatID, "Ошибка формирования архива"))
+		return
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: base + ".zip", Bytes: archive.Bytes()})
+	doc.Caption = fmt.Sprintf("%s, вложений: %d.", caption, len(files))
+	b.send(doc)
+}
@@ -4,105 +4,498 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/Spok95/beauty-bot/internal/access"
+	"github.com/Spok95/beauty-bot/internal/dialog"
 	"github.com/Spok95/beauty-bot/internal/domain/adminchat"
 	"github.com/Spok95/beauty-bot/internal/domain/users"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-const adminChatHistoryPageSize = 10
+const (
+	adminChatInboxPageSize  = 8
+	adminChatThreadPageSize = 5
+	adminChatSLATick        = time.Minute
+)
+
+// Фильтры списка обращений (в callback — одной буквой).
+var adminChatInboxFilters = []struct {
+	code, label string
+}{
+	{"a", "Все"},
+	{"o", "Открытые"},
+	{"p", "В работе"},
+	{"r", "Решённые"},
+	{"m", "Мои"},
+	{"u", "Непрочитанные"},
+}
+
+func adminChatInboxFilterLabel(code string) string {
+	for _, f := range adminChatInboxFilters {
+		if f.code == code {
+			return f.label
+		}
+	}
+	return "Все"
+}
+
+func adminChatStatusLabel(s adminchat.ThreadStatus) string {
+	switch s {
+	case adminchat.ThreadOpen:
+		return "🆕 открыто"
+	case adminchat.ThreadInProgress:
+		return "🛠 в работе"
+	case adminchat.ThreadResolved:
+		return "✅ решено"
+	default:
+		return string(s)
+	}
+}
+
+// adminChatWaitLabel — сколько обращение ждёт ответа: «40 мин», «2 ч 15 мин», «3 дн 4 ч».
+func adminChatWaitLabel(d time.Duration) string {
+	m := int(d / time.Minute)
+	switch {
+	case m < 60:
+		return fmt.Sprintf("%d мин", m)
+	case m < 24*60:
+		return fmt.Sprintf("%d ч %d мин", m/60, m%60)
+	default:
+		return fmt.Sprintf("%d дн %d ч", m/(24*60), m%(24*60)/60)
+	}
+}
+
+// adminChatThreadLine — строка статуса обращения для уведомлений.
+func adminChatThreadLine(t *adminchat.Thread) string {
+	line := fmt.Sprintf("Обращение #%d: %s", t.ID, adminChatStatusLabel(t.Status))
+	if t.AssigneeID != 0 {
+		line += " · ответственный: " + orDash(t.AssigneeName)
+	}
+	return line
+}
+
+// adminChatThreadButton — кнопка обращения в списке: непрочитанное, автор, статус, последняя активность.
+func (b *Bot) adminChatThreadButton(t adminchat.Thread) tgbotapi.InlineKeyboardButton {
+	mark := ""
+	switch {
+	case t.Unread > 0:
+		mark = fmt.Sprintf("🔴%d ", t.Unread)
+	case t.AwaitingReply && t.Status != adminchat.ThreadResolved:
+		mark = "⏳ "
+	}
+	label := fmt.Sprintf("%s%s · %s · %s", mark, orDash(t.UserName), adminChatStatusLabel(t.Status),
+		t.LastMessageAt.In(b.loc).Format("02.01 15:04"))
+	return tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("adminchat:t:%d:0", t.ID))
+}
 
-func (b *Bot) showAdminChatHistory(ctx context.Context, chatID int64, page int) {
+// showAdminChatInbox — список обращений по фильтру (см. adminChatInboxFilters).
+func (b *Bot) showAdminChatInbox(ctx context.Context, chatID int64, editMsgID *int, filter string, page int) {
+	u := b.currentUser(ctx)
+	if u == nil {
+		return
+	}
 	if page < 0 {
 		page = 0
 	}
 
-	offset := page * adminChatHistoryPageSize
+	var f adminchat.ThreadFilter
+	switch filter {
+	case "o":
+		f.Status = adminchat.ThreadOpen
+	case "p":
+		f.Status = adminchat.ThreadInProgress
+	case "r":
+		f.Status = adminchat.ThreadResolved
+	case "m":
+		f.AssigneeID = u.ID
+	case "u":
+		f.UnreadOnly = true
+	default:
+		filter = "a"
+	}
 
-	items, err := b.adminChatRepo.Last(ctx, adminChatHistoryPageSize, offset)
+	items, err := b.adminChatRepo.ListThreads(ctx, f, u.ID, adminChatInboxPageSize+1, page*adminChatInboxPageSize)
 	if err != nil {
-		b.log.Error("failed to load admin chat history", "err", err)
+		b.log.Error("failed to load admin chat threads", "err", err)
+		b.send(tgbotapi.NewMessage(chatID, "Не удалось загрузить обращения."))
+		return
+	}
+	hasNext := len(items) > adminChatInboxPageSize
+	if hasNext {
+		items = items[:adminChatInboxPageSize]
+	}
 
-		b.send(tgbotapi.NewMessage(chatID,
-			"Не удалось загрузить историю чата."))
+	var sb strings.Builder
+	sb.WriteString("💬 Обращения в админ-чат\n")
+	if threads, msgs, err := b.adminChatRepo.UnreadTotal(ctx, u.ID); err == nil && msgs > 0 {
+		_, _ = fmt.Fprintf(&sb, "Непрочитанных: %d сообщ. в %d обращ.\n", msgs, threads)
+	}
+	_, _ = fmt.Fprintf(&sb, "Фильтр: %s · страница %d\n", adminChatInboxFilterLabel(filter), page+1)
+	if len(items) == 0 {
+		sb.WriteString("\nОбращений нет.")
+	} else {
+		sb.WriteString("\n🔴 — непрочитанные сообщения, ⏳ — ждёт ответа.")
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var filterRow []tgbotapi.InlineKeyboardButton
+	for _, fl := range adminChatInboxFilters {
+		label := fl.label
+		if fl.code == filter {
+			label = "• " + label
+		}
+		filterRow = append(filterRow, tgbotapi.NewInlineKeyboardButtonData(label, "adminchat:inbox:"+fl.code+":0"))
+		if len(filterRow) == 3 {
+			rows = append(rows, filterRow)
+			filterRow = nil
+		}
+	}
+	for _, t := range items {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(b.adminChatThreadButton(t)))
+	}
+
+	var navRow []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад",
+			fmt.Sprintf("adminchat:inbox:%s:%d", filter, page-1)))
+	}
+	if hasNext {
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("➡️ Далее",
+			fmt.Sprintf("adminchat:inbox:%s:%d", filter, page+1)))
+	}
+	if len(navRow) > 0 {
+		rows = append(rows, navRow)
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔍 Найти мастера", "adminchat:find"),
+			tgbotapi.NewInlineKeyboardButtonData("🔎 Поиск по сообщениям", "adminchat:s:menu"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✖️ Закрыть", "nav:cancel"),
+		),
+	)
+	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	if editMsgID != nil {
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, *editMsgID, sb.String(), kb))
 		return
 	}
+	m := tgbotapi.NewMessage(chatID, sb.String())
+	m.ReplyMarkup = kb
+	b.send(m)
+}
+
+// handleAdminChatFind — поиск обращений по части ФИО или username мастера.
+func (b *Bot) handleAdminChatFind(ctx context.Context, chatID int64, text string) {
+	u := b.currentUser(ctx)
+	if u == nil {
+		return
+	}
+	q := strings.TrimSpace(text)
+	if q == "" {
+		b.send(tgbotapi.NewMessage(chatID, "Введите часть фамилии, имени или username мастера."))
+		return
+	}
+
+	items, err := b.adminChatRepo.ListThreads(ctx, adminchat.ThreadFilter{Query: q}, u.ID, 20, 0)
+	if err != nil {
+		b.log.Error("failed to search admin chat threads", "err", err)
+		b.send(tgbotapi.NewMessage(chatID, "Не удалось выполнить поиск."))
+		return
+	}
+	_ = b.states.Reset(ctx, chatID)
 
 	if len(items) == 0 {
-		b.send(tgbotapi.NewMessage(chatID,
-			"История чата пока пуста."))
+		m := tgbotapi.NewMessage(chatID, fmt.Sprintf("По запросу «%s» обращений не найдено.", q))
+		m.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔍 Искать ещё", "adminchat:find"),
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ К обращениям", "adminchat:inbox:a:0"),
+		))
+		b.send(m)
 		return
 	}
 
-	var lines []string
 	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, t := range items {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(b.adminChatThreadButton(t)))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ К обращениям", "adminchat:inbox:a:0"),
+	))
+	m := tgbotapi.NewMessage(chatID, fmt.Sprintf("🔍 Обращения по запросу «%s»: %d", q, len(items)))
+	m.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	b.send(m)
+}
 
-	lines = append(lines,
-		fmt.Sprintf("💬 История админ-чата\nСтраница: %d\n", page+1))
+// showAdminChatThread — карточка обращения с перепиской (новые сообщения внизу страницы).
+// Открытие отмечает обращение прочитанным.
+func (b *Bot) showAdminChatThread(ctx context.Context, chatID int64, editMsgID *int, threadID int64, page int) {
+	u := b.currentUser(ctx)
+	if u == nil {
+		return
+	}
+	if page < 0 {
+		page = 0
+	}
 
-	for _, m := range items {
-		lines = append(lines, formatAdminChatHistoryItem(&m))
-		lines = append(lines, "--------------------")
+	t, err := b.adminChatRepo.GetThread(ctx, threadID, u.ID)
+	if err != nil || t == nil {
+		b.send(tgbotapi.NewMessage(chatID, "Обращение не найдено."))
+		return
+	}
+	items, err := b.adminChatRepo.ThreadMessages(ctx, threadID, adminChatThreadPageSize+1, page*adminChatThreadPageSize)
+	if err != nil {
+		b.log.Error("failed to load admin chat thread messages", "thread", threadID, "err", err)
+		b.send(tgbotapi.NewMessage(chatID, "Не удалось загрузить переписку."))
+		return
+	}
+	hasOlder := len(items) > adminChatThreadPageSize
+	if hasOlder {
+		items = items[:adminChatThreadPageSize]
+	}
+	if err := b.adminChatRepo.MarkRead(ctx, threadID, u.ID); err != nil {
+		b.log.Warn("failed to mark admin chat thread read", "thread", threadID, "err", err)
+	}
 
-		row := []tgbotapi.InlineKeyboardButton{
-			tgbotapi.NewInlineKeyboardButtonData(
-				fmt.Sprintf("↩️ Ответить #%d", m.ID),
-				fmt.Sprintf("adminchat:reply:%d", m.ID),
-			),
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, "💬 Обращение #%d — %s\n", t.ID, orDash(t.UserName))
+	_, _ = fmt.Fprintf(&sb, "Статус: %s", adminChatStatusLabel(t.Status))
+	if t.AwaitingReply && t.Status != adminchat.ThreadResolved && t.LastUserMessageAt != nil {
+		_, _ = fmt.Fprintf(&sb, " · ждёт ответа %s", adminChatWaitLabel(time.Since(*t.LastUserMessageAt)))
+	}
+	_, _ = fmt.Fprintf(&sb, "\nОтветственный: %s\n", orDash(t.AssigneeName))
+	if author, err := b.users.GetByID(ctx, t.UserID); err == nil && author != nil {
+		if p := b.profileOf(ctx, author); p.Phone != "" {
+			_, _ = fmt.Fprintf(&sb, "Телефон: %s\n", p.Phone)
 		}
+	}
+	_, _ = fmt.Fprintf(&sb, "Страница %d\n", page+1)
 
-		if m.TelegramFileID != "" {
-			row = append(row,
-				tgbotapi.NewInlineKeyboardButtonData(
-					fmt.Sprintf("📎 Открыть #%d", m.ID),
-					fmt.Sprintf("adminchat:media:%d", m.ID),
-				),
-			)
+	chrono := make([]adminchat.Message, 0, len(items))
+	for i := len(items) - 1; i >= 0; i-- {
+		chrono = append(chrono, items[i])
+	}
+	var mediaRow []tgbotapi.InlineKeyboardButton
+	for _, g := range groupAdminChatAlbums(chrono) {
+		sb.WriteString("--------------------\n")
+		sb.WriteString(formatAdminChatHistoryGroup(g))
+		sb.WriteString("\n")
+		if g[0].TelegramFileID != "" {
+			mediaRow = append(mediaRow, tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("📎 #%d", g[0].ID), fmt.Sprintf("adminchat:media:%d", g[0].ID)))
 		}
+	}
 
-		rows = append(rows, row)
+	var rows [][]tgbotapi.InlineKeyboardButton
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("↩️ Ответить", fmt.Sprintf("adminchat:tr:%d", t.ID)),
+		tgbotapi.NewInlineKeyboardButtonData("📦 Выгрузить", fmt.Sprintf("adminchat:exp:%d", t.ID)),
+	))
+	var manageRow []tgbotapi.InlineKeyboardButton
+	if t.AssigneeID != u.ID {
+		manageRow = append(manageRow, tgbotapi.NewInlineKeyboardButtonData("🙋 Взять себе", fmt.Sprintf("adminchat:take:%d", t.ID)))
+	}
+	manageRow = append(manageRow, tgbotapi.NewInlineKeyboardButtonData("👤 Назначить", fmt.Sprintf("adminchat:asg:%d", t.ID)))
+	rows = append(rows, manageRow)
+	if t.Status == adminchat.ThreadResolved {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔄 Открыть снова", fmt.Sprintf("adminchat:st:%d:%s", t.ID, adminchat.ThreadOpen)),
+		))
+	} else {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Решено", fmt.Sprintf("adminchat:st:%d:%s", t.ID, adminchat.ThreadResolved)),
+		))
+	}
+	if len(mediaRow) > 0 {
+		rows = append(rows, mediaRow)
+	}
+	var navRow []tgbotapi.InlineKeyboardButton
+	if hasOlder {
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("⬆️ Раньше", fmt.Sprintf("adminchat:t:%d:%d", t.ID, page+1)))
 	}
+	if page > 0 {
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("⬇️ Позже", fmt.Sprintf("adminchat:t:%d:%d", t.ID, page-1)))
+	}
+	if len(navRow) > 0 {
+		rows = append(rows, navRow)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ К обращениям", "adminchat:inbox:a:0"),
+	))
+	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	text := sb.String()
+	if r := []rune(text); len(r) > 4000 {
+		text = string(r[:4000]) + "…"
+	}
+	if editMsgID != nil {
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, *editMsgID, text, kb))
+		return
+	}
+	m := tgbotapi.NewMessage(chatID, text)
+	m.ReplyMarkup = kb
+	b.send(m)
+}
 
-	msg := tgbotapi.NewMessage(chatID, strings.Join(lines, "\n"))
+// showAdminChatAssign — выбор ответственного из тех, кто читает админ-чат.
+func (b *Bot) showAdminChatAssign(ctx context.Context, chatID int64, msgID int, threadID int64) {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, s := range b.usersWith(ctx, access.CapChatHistory) {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			b.userFullName(ctx, s), fmt.Sprintf("adminchat:asgto:%d:%d", threadID, s.ID))))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", fmt.Sprintf("adminchat:t:%d:0", threadID)),
+	))
+	b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, msgID,
+		fmt.Sprintf("Кому назначить обращение #%d?", threadID), tgbotapi.NewInlineKeyboardMarkup(rows...)))
+}
 
-	navRow := []tgbotapi.InlineKeyboardButton{}
+// assignAdminChatThread назначает ответственного и уведомляет его, если назначил кто-то другой.
+func (b *Bot) assignAdminChatThread(ctx context.Context, threadID int64, assignee, by *users.User) error {
+	if err := b.adminChatRepo.Assign(ctx, threadID, assignee.ID); err != nil {
+		return err
+	}
+	if assignee.ID == by.ID || assignee.TelegramID == 0 {
+		return nil
+	}
+	t, err := b.adminChatRepo.GetThread(ctx, threadID, assignee.ID)
+	if err != nil || t == nil {
+		return err
+	}
+	m := tgbotapi.NewMessage(assignee.TelegramID, fmt.Sprintf(
+		"📌 Вам назначено обращение #%d — %s\nНазначил: %s", t.ID, orDash(t.UserName), b.userFullName(ctx, by)))
+	m.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("📂 Открыть обращение", fmt.Sprintf("adminchat:open:%d", t.ID)),
+	))
+	b.send(m)
+	return nil
+}
 
-	if page > 0 {
-		navRow = append(navRow,
-			tgbotapi.NewInlineKeyboardButtonData(
-				"⬅️ Назад",
-				fmt.Sprintf("adminchat:history:%d", page-1),
-			),
-		)
+// setAdminChatThreadStatus меняет статус; о решении обращения сообщаем автору.
+func (b *Bot) setAdminChatThreadStatus(ctx context.Context, threadID int64, status adminchat.ThreadStatus) error {
+	if err := b.adminChatRepo.SetStatus(ctx, threadID, status); err != nil {
+		return err
+	}
+	if status != adminchat.ThreadResolved {
+		return nil
 	}
+	t, err := b.adminChatRepo.GetThread(ctx, threadID, 0)
+	if err != nil || t == nil || t.UserTelegramID == 0 {
+		return err
+	}
+	b.send(tgbotapi.NewMessage(t.UserTelegramID,
+		"✅ Ваше обращение в админ-чат отмечено решённым.\nЕсли вопрос остался — просто напишите снова через «Чат с админом»."))
+	return nil
+}
 
-	if len(items) == adminChatHistoryPageSize {
-		navRow = append(navRow,
-			tgbotapi.NewInlineKeyboardButtonData(
-				"➡️ Далее",
-				fmt.Sprintf("adminchat:history:%d", page+1),
-			),
-		)
+// startAdminChatReply переводит администратора в режим ответа на сообщение m.
+func (b *Bot) startAdminChatReply(ctx context.Context, chatID int64, m *adminchat.Message) {
+	st, _ := b.states.Get(ctx, chatID)
+
+	if st.Payload == nil {
+		st.Payload = dialog.Payload{}
 	}
 
-	if len(navRow) > 0 {
-		rows = append(rows, navRow)
+	// ответ на альбом адресуется альбому целиком — его первому файлу
+	album := b.adminChatAlbumOf(ctx, m)
+	m = &album[0]
+
+	st.Payload["reply_to_admin_chat_message_id"] = m.ID
+
+	_ = b.states.Set(ctx, chatID, dialog.StateChatAdmin, st.Payload)
+
+	target, kind := "сообщение", adminChatMessageTypeLabel(m.MessageType)
+	if len(album) > 1 {
+		target, kind = "альбом", fmt.Sprintf("альбом, %d файлов", len(album))
 	}
 
-	rows = append(rows,
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(
-				"✖️ Закрыть",
-				"nav:cancel",
-			),
-		),
+	replyText := fmt.Sprintf(
+		"↩️ Ответ на %s #%d\n\nОт: %s\nТип: %s\n\nТеперь отправьте сообщение, фото или файл.",
+		target,
+		m.ID,
+		strings.TrimSpace(m.SenderUsername),
+		kind,
 	)
 
-	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	msg := tgbotapi.NewMessage(chatID, replyText)
+	msg.ReplyMarkup = adminChatCancelKeyboard()
 
 	b.send(msg)
 }
 
+// RunAdminChatSLA раз в минуту напоминает об обращениях, оставшихся без ответа дольше sla:
+// ответственному, а если его нет — всем, кто читает админ-чат. Повтор — не чаще раза в sla.
+func (b *Bot) RunAdminChatSLA(ctx context.Context, sla time.Duration) {
+	if sla <= 0 {
+		return
+	}
+	t := time.NewTicker(adminChatSLATick)
+	defer t.Stop()
+	for {
+		b.remindOverdueAdminChats(ctx, sla)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (b *Bot) remindOverdueAdminChats(ctx context.Context, sla time.Duration) {
+	list, err := b.adminChatRepo.ListOverdue(ctx, sla)
+	if err != nil {
+		b.log.Error("list overdue admin chat threads failed", "err", err)
+		return
+	}
+	for _, t := range list {
+		var targets []int64
+		if t.AssigneeID != 0 {
+			if a, err := b.users.GetByID(ctx, t.AssigneeID); err == nil && a != nil && a.TelegramID != 0 {
+				targets = []int64{a.TelegramID}
+			}
+		}
+		if len(targets) == 0 {
+			targets = b.recipientsWith(ctx, access.CapChatHistory)
+		}
+
+		wait := ""
+		if t.LastUserMessageAt != nil {
+			wait = " уже " + adminChatWaitLabel(time.Since(*t.LastUserMessageAt))
+		}
+		text := fmt.Sprintf("⏰ Обращение #%d без ответа%s\nОт: %s\nСтатус: %s\nОтветственный: %s",
+			t.ID, wait, orDash(t.UserName), adminChatStatusLabel(t.Status), orDash(t.AssigneeName))
+		for _, id := range targets {
+			m := tgbotapi.NewMessage(id, text)
+			m.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("📂 Открыть обращение", fmt.Sprintf("adminchat:open:%d", t.ID)),
+			))
+			b.send(m)
+		}
+		if err := b.adminChatRepo.MarkSLANotified(ctx, t.ID); err != nil {
+			b.log.Error("mark admin chat sla notified failed", "thread", t.ID, "err", err)
+		}
+	}
+}
+
+// formatAdminChatHistoryGroup — элемент истории: сообщение или альбом целиком.
+func formatAdminChatHistoryGroup(g []adminchat.Message) string {
+	if len(g) == 1 {
+		return formatAdminChatHistoryItem(&g[0])
+	}
+	m := g[0]
+	// подпись альбома обычно у одного из файлов
+	for _, it := range g {
+		if it.Caption != "" {
+			m.Caption = it.Caption
+			break
+		}
+	}
+	return formatAdminChatHistoryItem(&m) + fmt.Sprintf("\n🖼 Альбом: %d файлов (#%d–#%d)", len(g), g[0].ID, g[len(g)-1].ID)
+}
+
 func formatAdminChatHistoryItem(m *adminchat.Message) string {
 	role := roleLabel(users.Role(m.SenderRole))
 	if role == "" {
@@ -128,8 +521,8 @@ func formatAdminChatHistoryItem(m *adminchat.Message) string {
 		text = "(без текста)"
 	}
 
-	if len(text) > 300 {
-		text = text[:300] + "..."
+	if r := []rune(text); len(r) > 300 {
+		text = string(r[:300]) + "..."
 	}
 
 	replyPart := ""
@@ -0,0 +1,263 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Spok95/beauty-bot/internal/dialog"
+	"github.com/Spok95/beauty-bot/internal/domain/adminchat"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const adminChatSearchPageSize = 5
+
+// adminChatSearchTypes — порядок переключения фильтра по типу сообщения.
+var adminChatSearchTypes = []string{"", "text", "photo", "document", "video", "audio", "voice"}
+
+func adminChatSearchTypeLabel(t string) string {
+	if t == "" {
+		return "все"
+	}
+	return adminChatMessageTypeLabel(t)
+}
+
+// adminChatSearchFilter собирает фильтр из payload экрана поиска.
+func (b *Bot) adminChatSearchFilter(p dialog.Payload) adminchat.SearchFilter {
+	f := adminchat.SearchFilter{
+		Query:       payloadString(p, "q"),
+		Sender:      payloadString(p, "sender"),
+		MessageType: payloadString(p, "type"),
+	}
+	if period := payloadString(p, "period"); period != "" {
+		if from, to, errText := parsePeriod(period, b.loc); errText == "" {
+			f.From, f.To = from, to
+		}
+	}
+	return f
+}
+
+// showAdminChatSearchMenu — экран поиска: текущие условия и кнопки фильтров.
+func (b *Bot) showAdminChatSearchMenu(chatID int64, editMsgID *int, p dialog.Payload) {
+	text := fmt.Sprintf(
+		"🔎 Поиск по админ-чату\n\nЗапрос: %s\nОтправитель: %s\nТип: %s\nПериод: %s\n\n"+
+			"Отправьте текст запроса сообщением — поиск учитывает словоформы (например, «счёт» найдёт «счета»). "+
+			"Можно искать и без запроса, только по фильтрам.",
+		orDash(payloadString(p, "q")),
+		orDash(payloadString(p, "sender")),
+		adminChatSearchTypeLabel(payloadString(p, "type")),
+		orDash(payloadString(p, "period")),
+	)
+	kb := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("👤 Отправитель", "adminchat:s:in:sender"),
+			tgbotapi.NewInlineKeyboardButtonData("📅 Период", "adminchat:s:in:period"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📎 Тип: "+adminChatSearchTypeLabel(payloadString(p, "type")), "adminchat:s:type"),
+			tgbotapi.NewInlineKeyboardButtonData("🧹 Сбросить", "adminchat:s:clear"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔎 Искать", "adminchat:s:run:0"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ К обращениям", "adminchat:inbox:a:0"),
+			tgbotapi.NewInlineKeyboardButtonData("✖️ Закрыть", "nav:cancel"),
+		),
+	)
+	if editMsgID != nil {
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, *editMsgID, text, kb))
+		return
+	}
+	m := tgbotapi.NewMessage(chatID, text)
+	m.ReplyMarkup = kb
+	b.send(m)
+}
+
+// showAdminChatSearchResults — страница найденных сообщений.
+func (b *Bot) showAdminChatSearchResults(ctx context.Context, chatID int64, editMsgID *int, p dialog.Payload, page int) {
+	if page < 0 {
+		page = 0
+	}
+	hits, total, err := b.adminChatRepo.Search(ctx, b.adminChatSearchFilter(p), adminChatSearchPageSize, page*adminChatSearchPageSize)
+	if err != nil {
+		b.log.Error("admin chat search failed", "err", err)
+		b.send(tgbotapi.NewMessage(chatID, "Не удалось выполнить поиск. Проверьте запрос."))
+		return
+	}
+
+	var sb strings.Builder
+	if total == 0 {
+		sb.WriteString("🔎 Ничего не найдено. Измените запрос или фильтры.")
+	} else {
+		pages := (total + adminChatSearchPageSize - 1) / adminChatSearchPageSize
+		_, _ = fmt.Fprintf(&sb, "🔎 Найдено: %d · страница %d из %d\n", total, page+1, pages)
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, h := range hits {
+		snippet := h.Snippet
+		if snippet == "" {
+			snippet = strings.TrimSpace(h.Text)
+			if snippet == "" {
+				snippet = strings.TrimSpace(h.Caption)
+			}
+			if snippet == "" {
+				snippet = h.FileName
+			}
+			if r := []rune(snippet); len(r) > 200 {
+				snippet = string(r[:200]) + "…"
+			}
+		}
+		_, _ = fmt.Fprintf(&sb, "\n#%d · %s · %s · %s\n%s\n",
+			h.ID, h.CreatedAt.In(b.loc).Format("02.01.2006 15:04"), orDash(h.SenderName),
+			adminChatMessageTypeLabel(h.MessageType), orDash(snippet))
+
+		var row []tgbotapi.InlineKeyboardButton
+		if h.ThreadID > 0 {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("📂 #%d в обращении", h.ID), fmt.Sprintf("adminchat:open:%d", h.ThreadID)))
+		}
+		if h.TelegramFileID != "" {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("📎 #%d", h.ID), fmt.Sprintf("adminchat:media:%d", h.ID)))
+		}
+		if len(row) > 0 {
+			rows = append(rows, row)
+		}
+	}
+
+	var navRow []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", fmt.Sprintf("adminchat:s:run:%d", page-1)))
+	}
+	if (page+1)*adminChatSearchPageSize < total {
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("➡️ Далее", fmt.Sprintf("adminchat:s:run:%d", page+1)))
+	}
+	if len(navRow) > 0 {
+		rows = append(rows, navRow)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⚙️ Условия поиска", "adminchat:s:menu"),
+		tgbotapi.NewInlineKeyboardButtonData("✖️ Закрыть", "nav:cancel"),
+	))
+	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	text := sb.String()
+	if r := []rune(text); len(r) > 4000 {
+		text = string(r[:4000]) + "…"
+	}
+	if editMsgID != nil {
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, *editMsgID, text, kb))
+		return
+	}
+	m := tgbotapi.NewMessage(chatID, text)
+	m.ReplyMarkup = kb
+	b.send(m)
+}
+
+// handleAdminChatSearchInput — ввод в экране поиска: запрос (по умолчанию), отправитель или период.
+func (b *Bot) handleAdminChatSearchInput(ctx context.Context, chatID int64, st *dialog.Item, text string) {
+	p := st.Payload
+	if p == nil {
+		p = dialog.Payload{}
+	}
+	text = strings.TrimSpace(text)
+	input := payloadString(p, "input")
+	delete(p, "input")
+
+	switch input {
+	case "sender":
+		if text == "-" {
+			text = ""
+		}
+		p["sender"] = text
+	case "period":
+		if text == "-" {
+			text = ""
+		}
+		if text != "" {
+			if _, _, errText := parsePeriod(text, b.loc); errText != "" {
+				p["input"] = "period"
+				_ = b.states.Set(ctx, chatID, dialog.StateAdmChatSearch, p)
+				b.send(tgbotapi.NewMessage(chatID, errText))
+				return
+			}
+		}
+		p["period"] = text
+	default:
+		if text == "" {
+			b.send(tgbotapi.NewMessage(chatID, "Отправьте текст запроса."))
+			return
+		}
+		p["q"] = text
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmChatSearch, p)
+		b.showAdminChatSearchResults(ctx, chatID, nil, p, 0)
+		return
+	}
+	_ = b.states.Set(ctx, chatID, dialog.StateAdmChatSearch, p)
+	b.showAdminChatSearchMenu(chatID, nil, p)
+}
+
+// handleAdminChatSearchCallback — кнопки экрана поиска (adminchat:s:…).
+func (b *Bot) handleAdminChatSearchCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, data string) {
+	chatID := cb.Message.Chat.ID
+	msgID := cb.Message.MessageID
+
+	st, _ := b.states.Get(ctx, chatID)
+	p := dialog.Payload{}
+	if st != nil && st.State == dialog.StateAdmChatSearch && st.Payload != nil {
+		p = st.Payload
+	}
+
+	switch {
+	case data == "menu":
+		delete(p, "input")
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmChatSearch, p)
+		b.showAdminChatSearchMenu(chatID, &msgID, p)
+		_ = b.answerCallback(cb, "", false)
+
+	case data == "in:sender":
+		p["input"] = "sender"
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmChatSearch, p)
+		b.send(tgbotapi.NewMessage(chatID, "Введите часть фамилии, имени или username отправителя («-» — любой):"))
+		_ = b.answerCallback(cb, "", false)
+
+	case data == "in:period":
+		p["input"] = "period"
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmChatSearch, p)
+		b.send(tgbotapi.NewMessage(chatID, "Введите период в формате ДД.ММ.ГГГГ-ДД.ММ.ГГГГ («-» — за всё время):"))
+		_ = b.answerCallback(cb, "", false)
+
+	case data == "type":
+		cur := payloadString(p, "type")
+		next := adminChatSearchTypes[0]
+		for i, t := range adminChatSearchTypes {
+			if t == cur {
+				next = adminChatSearchTypes[(i+1)%len(adminChatSearchTypes)]
+				break
+			}
+		}
+		p["type"] = next
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmChatSearch, p)
+		b.showAdminChatSearchMenu(chatID, &msgID, p)
+		_ = b.answerCallback(cb, "Тип: "+adminChatSearchTypeLabel(next), false)
+
+	case data == "clear":
+		p = dialog.Payload{}
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmChatSearch, p)
+		b.showAdminChatSearchMenu(chatID, &msgID, p)
+		_ = b.answerCallback(cb, "Условия сброшены", false)
+
+	case strings.HasPrefix(data, "run:"):
+		page, _ := strconv.Atoi(strings.TrimPrefix(data, "run:"))
+		delete(p, "input")
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmChatSearch, p)
+		b.showAdminChatSearchResults(ctx, chatID, &msgID, p, page)
+		_ = b.answerCallback(cb, "", false)
+
+	default:
+		_ = b.answerCallback(cb, "Действие неактуально", false)
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"strings"
 
+	"github.com/Spok95/beauty-bot/internal/access"
+	"github.com/Spok95/beauty-bot/internal/domain/materials"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -14,6 +17,12 @@ func (b *Bot) showMaterialMenu(chatID int64, editMsgID *int) {
 			tgbotapi.NewInlineKeyboardButtonData("➕ Создать материал", "adm:mat:add"),
 			tgbotapi.NewInlineKeyboardButtonData("📄 Список материалов", "adm:mat:list"),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔎 Найти материал", "adm:mat:search"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📋 Шаблоны расхода", "adm:tpl:menu"),
+		),
 		navKeyboard(false, true).InlineKeyboard[0],
 	)
 	if editMsgID != nil {
@@ -329,11 +338,36 @@ func (b *Bot) showMaterialItemMenu(ctx context.Context, chatID int64, editMsgID
 		))
 	}
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("Единица: pcs/g", fmt.Sprintf("adm:mat:unit:%d", id)),
+		tgbotapi.NewInlineKeyboardButtonData("Единица: pcs/g/ml", fmt.Sprintf("adm:mat:unit:%d", id)),
+	))
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("📦 Упаковка", fmt.Sprintf("adm:mat:pu:%d", id)),
+		tgbotapi.NewInlineKeyboardButtonData("🔢 Штрихкоды", fmt.Sprintf("adm:mat:bc:%d", id)),
+	))
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🏷 Синонимы бренда", fmt.Sprintf("adm:brand:alias:%d", id)),
 	))
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
 		tgbotapi.NewInlineKeyboardButtonData(toggle, fmt.Sprintf("adm:mat:tg:%d", id)),
 	))
+
+	// История цен: последние изменения и запланированные (их можно отменить)
+	prices, _ := b.materials.ListPrices(ctx, id, 6)
+	now := b.now()
+	var priceLines []string
+	for _, p := range prices {
+		mark := ""
+		if p.Scheduled(now) {
+			mark = " ⏳"
+			if b.can(ctx, access.CapPricesEdit) {
+				rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+					fmt.Sprintf("❌ Отменить %.2f ₽ с %s", p.Price, p.ValidFrom.In(b.loc).Format("02.01.2006")),
+					fmt.Sprintf("price:mat:cancel:%d:%d", id, p.ID),
+				)))
+			}
+		}
+		priceLines = append(priceLines, fmt.Sprintf("• %s — %.2f ₽%s", p.ValidFrom.In(b.loc).Format("02.01.2006 15:04"), p.Price, mark))
+	}
 	rows = append(rows, navKeyboard(true, true).InlineKeyboard[0])
 	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
 
@@ -346,9 +380,12 @@ func (b *Bot) showMaterialItemMenu(ctx context.Context, chatID int64, editMsgID
 	matName := materialDisplayName(m.Brand, m.Name)
 
 	text := fmt.Sprintf(
-		"Материал: %s %s\nКатегория: %s\nЕд.: %s\nСтатус: %v",
-		badge(m.Active), matName, catName, m.Unit, m.Active,
+		"Материал: %s %s\nКатегория: %s\nЕд.: %s\nУпаковка: %s\nЦена: %s\nСтатус: %v",
+		badge(m.Active), matName, catName, m.Unit, materialPackLabel(*m), formatMaterialPrice(*m, m.PricePerUnit), m.Active,
 	)
+	if len(priceLines) > 0 {
+		text += "\n\nИстория цен (⏳ — запланирована):\n" + strings.Join(priceLines, "\n")
+	}
 
 	b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, editMsgID, text, kb))
 }
@@ -390,3 +427,96 @@ func (b *Bot) showMaterialBrandPick(ctx context.Context, chatID int64, editMsgID
 	text := "Выберите бренд для материала (или создайте новый):"
 	b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, editMsgID, text, kb))
 }
+
+// showAdminMaterialSearch — результаты поиска материала в админке (нечёткий поиск по всем материалам),
+// постранично; запрос хранится в состоянии для кнопок листания.
+func (b *Bot) showAdminMaterialSearch(ctx context.Context, chatID int64, editMsgID *int, query string, page int) {
+	if page < 0 {
+		page = 0
+	}
+	opt := materials.SearchOptions{Limit: materialSearchPageSize, Offset: page * materialSearchPageSize}
+	mats, total, err := b.materials.Search(ctx, query, opt)
+	if err != nil {
+		b.send(tgbotapi.NewMessage(chatID, "Ошибка поиска материалов"))
+		return
+	}
+	page = materials.SearchPage(page, materialSearchPageSize, total)
+
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for _, m := range mats {
+		label := fmt.Sprintf("%s %s", badge(m.Active), materialDisplayName(m.Brand, m.Name))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("adm:mat:menu:%d", m.ID)),
+		))
+	}
+	totalPages := (total + materialSearchPageSize - 1) / materialSearchPageSize
+	if totalPages > 1 {
+		var pager []tgbotapi.InlineKeyboardButton
+		if page > 0 {
+			pager = append(pager, tgbotapi.NewInlineKeyboardButtonData("⬅️", fmt.Sprintf("adm:mat:search:page:%d", page-1)))
+		} else {
+			pager = append(pager, tgbotapi.NewInlineKeyboardButtonData(" ", "noop"))
+		}
+		pager = append(pager, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d/%d", page+1, totalPages), "noop"))
+		if page < totalPages-1 {
+			pager = append(pager, tgbotapi.NewInlineKeyboardButtonData("➡️", fmt.Sprintf("adm:mat:search:page:%d", page+1)))
+		} else {
+			pager = append(pager, tgbotapi.NewInlineKeyboardButtonData(" ", "noop"))
+		}
+		rows = append(rows, pager)
+	}
+	rows = append(rows, navKeyboard(true, true).InlineKeyboard[0])
+	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	text := fmt.Sprintf("По запросу «%s» ничего не найдено. Введите другой запрос.", query)
+	if total > 0 {
+		text = fmt.Sprintf("Найдено по запросу «%s»: %d\nВыберите материал или введите другой запрос.", query, total)
+	}
+	if editMsgID != nil {
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, *editMsgID, text, kb))
+		return
+	}
+	m := tgbotapi.NewMessage(chatID, text)
+	m.ReplyMarkup = kb
+	b.send(m)
+}
+
+// showBrandAliases — синонимы бренда материала: список с удалением, добавление — сообщением.
+func (b *Bot) showBrandAliases(ctx context.Context, chatID int64, editMsgID *int, matID int64) {
+	m, _ := b.materials.GetByID(ctx, matID)
+	if m == nil {
+		if editMsgID != nil {
+			b.editTextAndClear(chatID, *editMsgID, "Материал не найден")
+		}
+		return
+	}
+	aliases, err := b.brands.ListAliases(ctx, m.BrandID)
+	if err != nil {
+		b.send(tgbotapi.NewMessage(chatID, "Ошибка загрузки синонимов"))
+		return
+	}
+
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, "Синонимы бренда «%s» для поиска:\n\n", m.Brand)
+	if len(aliases) == 0 {
+		sb.WriteString("Пока нет.\n")
+	}
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for _, a := range aliases {
+		_, _ = fmt.Fprintf(&sb, "• %s\n", a.Alias)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑 "+a.Alias, fmt.Sprintf("adm:brand:alias:del:%d:%d", matID, a.ID)),
+		))
+	}
+	sb.WriteString("\nЧтобы добавить синоним, отправьте его сообщением (например, «лореаль»).")
+	rows = append(rows, navKeyboard(true, true).InlineKeyboard[0])
+	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	if editMsgID != nil {
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, *editMsgID, sb.String(), kb))
+		return
+	}
+	out := tgbotapi.NewMessage(chatID, sb.String())
+	out.ReplyMarkup = kb
+	b.send(out)
+}
@@ -0,0 +1,419 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Spok95/beauty-bot/internal/dialog"
+	"github.com/Spok95/beauty-bot/internal/domain/users"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const adminUsersPageSize = 10
+
+func userStatusLabel(s users.Status) string {
+	switch s {
+	case users.StatusPending:
+		return "⏳ ждёт подтверждения"
+	case users.StatusApproved:
+		return "✅ активен"
+	case users.StatusRejected:
+		return "❌ отклонён"
+	case users.StatusBlocked:
+		return "⛔ заблокирован"
+	default:
+		return string(s)
+	}
+}
+
+func userStatusIcon(s users.Status) string {
+	switch s {
+	case users.StatusApproved:
+		return "✅"
+	case users.StatusPending:
+		return "⏳"
+	case users.StatusBlocked:
+		return "⛔"
+	default:
+		return "❌"
+	}
+}
+
+func userDisplayName(u *users.User) string {
+	if strings.TrimSpace(u.Username) != "" {
+		return u.Username
+	}
+	return fmt.Sprintf("tg:%d", u.TelegramID)
+}
+
+// showAdminUsersList — список пользователей с поиском и пагинацией.
+func (b *Bot) showAdminUsersList(ctx context.Context, chatID int64, editMsgID *int, query string, page int) {
+	if page < 0 {
+		page = 0
+	}
+	list, total, err := b.users.Search(ctx, query, adminUsersPageSize, page*adminUsersPageSize)
+	if err != nil {
+		b.send(tgbotapi.NewMessage(chatID, "Ошибка загрузки пользователей"))
+		return
+	}
+	totalPages := (total + adminUsersPageSize - 1) / adminUsersPageSize
+	if totalPages == 0 {
+		totalPages = 1
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for _, u := range list {
+		label := fmt.Sprintf("%s %s — %s", userStatusIcon(u.Status), userDisplayName(u), roleLabel(u.Role))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("adm:usr:card:%d", u.ID)),
+		))
+	}
+
+	if totalPages > 1 {
+		pager := []tgbotapi.InlineKeyboardButton{}
+		if page > 0 {
+			pager = append(pager, tgbotapi.NewInlineKeyboardButtonData("⬅️", fmt.Sprintf("adm:usr:page:%d", page-1)))
+		} else {
+			pager = append(pager, tgbotapi.NewInlineKeyboardButtonData(" ", "noop"))
+		}
+		pager = append(pager, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d/%d", page+1, totalPages), "noop"))
+		if page < totalPages-1 {
+			pager = append(pager, tgbotapi.NewInlineKeyboardButtonData("➡️", fmt.Sprintf("adm:usr:page:%d", page+1)))
+		} else {
+			pager = append(pager, tgbotapi.NewInlineKeyboardButtonData(" ", "noop"))
+		}
+		rows = append(rows, pager)
+	}
+
+	searchRow := []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("🔎 Поиск", "adm:usr:search"),
+	}
+	if query != "" {
+		searchRow = append(searchRow, tgbotapi.NewInlineKeyboardButtonData("♻️ Сбросить поиск", "adm:usr:reset"))
+	}
+	rows = append(rows, searchRow)
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🎟 Приглашения", "adm:usr:inv:list"),
+	))
+	rows = append(rows, navKeyboard(false, true).InlineKeyboard[0])
+
+	text := fmt.Sprintf("Пользователи: %d\nСтраница: %d/%d", total, page+1, totalPages)
+	if query != "" {
+		text = fmt.Sprintf("Поиск: «%s»\n", query) + text
+	}
+	if total == 0 {
+		text += "\n\nНикого не найдено."
+	}
+
+	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	_ = b.states.Set(ctx, chatID, dialog.StateAdmUsersList, dialog.Payload{"query": query, "page": page})
+	if editMsgID != nil {
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, *editMsgID, text, kb))
+		return
+	}
+	m := tgbotapi.NewMessage(chatID, text)
+	m.ReplyMarkup = kb
+	b.send(m)
+}
+
+// showAdminUserCard — карточка пользователя: роли, статус, активность и действия.
+func (b *Bot) showAdminUserCard(ctx context.Context, chatID int64, editMsgID *int, userID int64, listPayload dialog.Payload) {
+	u, err := b.users.GetByID(ctx, userID)
+	if err != nil || u == nil {
+		if editMsgID != nil {
+			b.editTextAndClear(chatID, *editMsgID, "Пользователь не найден")
+		}
+		return
+	}
+
+	roles := make([]string, 0, len(u.Roles))
+	for _, r := range u.Roles {
+		roles = append(roles, roleLabel(r))
+	}
+
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, "👤 %s\n", userDisplayName(u))
+	_, _ = fmt.Fprintf(&sb, "Telegram ID: %d\n", u.TelegramID)
+	_, _ = fmt.Fprintf(&sb, "Статус: %s\n", userStatusLabel(u.Status))
+	_, _ = fmt.Fprintf(&sb, "Активная роль: %s\n", roleLabel(u.Role))
+	_, _ = fmt.Fprintf(&sb, "Роли: %s\n", strings.Join(roles, ", "))
+	_, _ = fmt.Fprintf(&sb, "Зарегистрирован: %s\n", u.CreatedAt.In(b.loc).Format("02.01.2006"))
+	sb.WriteString("\n")
+	sb.WriteString(b.profileSummary(ctx, b.profileOf(ctx, u), true))
+
+	if act, err := b.cons.UserActivity(ctx, u.ID, b.now().AddDate(0, 0, -30)); err == nil {
+		_, _ = fmt.Fprintf(&sb, "\nЗа 30 дней: сессий %d на %.2f ₽\n", act.Sessions, act.Total)
+		if act.LastAt != nil {
+			_, _ = fmt.Fprintf(&sb, "Последняя сессия: %s\n", act.LastAt.In(b.loc).Format("02.01.2006 15:04"))
+		} else {
+			sb.WriteString("Сессий расхода/аренды ещё не было\n")
+		}
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	if u.Status == users.StatusBlocked {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔓 Разблокировать", fmt.Sprintf("adm:usr:unblock:%d", u.ID)),
+		))
+	} else {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⛔ Заблокировать", fmt.Sprintf("adm:usr:block:%d", u.ID)),
+		))
+	}
+
+	roleRow := []tgbotapi.InlineKeyboardButton{}
+	for _, r := range users.AllRoles {
+		if u.HasRole(r) {
+			roleRow = append(roleRow, tgbotapi.NewInlineKeyboardButtonData("➖ "+roleLabel(r), fmt.Sprintf("adm:usr:role:rm:%d:%s", u.ID, r)))
+		} else {
+			roleRow = append(roleRow, tgbotapi.NewInlineKeyboardButtonData("➕ "+roleLabel(r), fmt.Sprintf("adm:usr:role:add:%d:%s", u.ID, r)))
+		}
+	}
+	rows = append(rows, roleRow)
+
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("📇 Профиль", fmt.Sprintf("adm:usr:pf:%d", u.ID)),
+		tgbotapi.NewInlineKeyboardButtonData("✏️ Изменить ФИО", fmt.Sprintf("adm:usr:rn:%d", u.ID)),
+	))
+	if len(u.Roles) > 1 {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔄 Переключить роль", fmt.Sprintf("adm:usr:sw:%d", u.ID)),
+		))
+	}
+	rows = append(rows, navKeyboard(true, true).InlineKeyboard[0])
+	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	// сохраняем параметры списка, чтобы «Назад» вернул на ту же страницу
+	payload := dialog.Payload{"user_id": u.ID}
+	if listPayload != nil {
+		payload["query"] = listPayload["query"]
+		payload["page"] = listPayload["page"]
+	}
+	_ = b.states.Set(ctx, chatID, dialog.StateAdmUserCard, payload)
+
+	if editMsgID != nil {
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, *editMsgID, sb.String(), kb))
+		return
+	}
+	m := tgbotapi.NewMessage(chatID, sb.String())
+	m.ReplyMarkup = kb
+	b.send(m)
+}
+
+// showAdminUserSwitchRole — выбор роли, на которую принудительно переключить пользователя.
+func (b *Bot) showAdminUserSwitchRole(ctx context.Context, chatID int64, editMsgID int, u *users.User) {
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for _, r := range u.Roles {
+		label := roleLabel(r)
+		if r == u.Role {
+			label = "• " + label
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("adm:usr:sw:set:%d:%s", u.ID, r)),
+		))
+	}
+	rows = append(rows, navKeyboard(true, true).InlineKeyboard[0])
+	b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, editMsgID,
+		fmt.Sprintf("На какую роль переключить %s?", userDisplayName(u)),
+		tgbotapi.NewInlineKeyboardMarkup(rows...)))
+}
+
+// notifyUserBlocked сообщает пользователю о блокировке и убирает его клавиатуру.
+func (b *Bot) notifyUserBlocked(ctx context.Context, u *users.User) {
+	_ = b.states.Reset(ctx, u.TelegramID)
+	m := tgbotapi.NewMessage(u.TelegramID, "Ваш доступ к боту отключён администратором.")
+	m.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+	b.send(m)
+}
+
+// notifyUserRoleChanged показывает пользователю меню его текущей активной роли.
+func (b *Bot) notifyUserRoleChanged(ctx context.Context, userID int64, text string) {
+	u, _ := b.users.GetByID(ctx, userID)
+	if u == nil || u.Status != users.StatusApproved {
+		return
+	}
+	_ = b.states.Reset(ctx, u.TelegramID)
+	b.send(tgbotapi.NewMessage(u.TelegramID, text))
+	b.sendMenuForRole(u.TelegramID, u.Role)
+}
+
+// rejectIfBlocked — общий фильтр для всех обработчиков: заблокированный пользователь
+// получает короткий ответ и дальше не проходит.
+func (b *Bot) rejectIfBlocked(ctx context.Context, from *tgbotapi.User, chatID int64, cb *tgbotapi.CallbackQuery) bool {
+	if from == nil {
+		return false
+	}
+	u := b.currentUser(ctx)
+	if u == nil || u.Status != users.StatusBlocked {
+		return false
+	}
+	if cb != nil {
+		_ = b.answerCallback(cb, "Доступ отключён администратором", true)
+		return true
+	}
+	m := tgbotapi.NewMessage(chatID, "Ваш доступ к боту отключён администратором.")
+	m.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+	b.send(m)
+	return true
+}
+
+// handleAdminUsersCallback — колбэки раздела «Пользователи» (data без префикса adm:usr:).
+func (b *Bot) handleAdminUsersCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, data string) {
+	chatID := cb.Message.Chat.ID
+	msgID := cb.Message.MessageID
+
+	st, _ := b.states.Get(ctx, chatID)
+	var payload dialog.Payload
+	if st != nil {
+		payload = st.Payload
+	}
+
+	switch {
+	case strings.HasPrefix(data, "page:"):
+		page, _ := strconv.Atoi(strings.TrimPrefix(data, "page:"))
+		b.showAdminUsersList(ctx, chatID, &msgID, payloadString(payload, "query"), page)
+		_ = b.answerCallback(cb, "Ок", false)
+
+	case data == "search":
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmUsersSearch, payload)
+		b.editTextWithNav(chatID, msgID, "Введите часть ФИО или Telegram ID.")
+		_ = b.answerCallback(cb, "Ок", false)
+
+	case data == "reset":
+		b.showAdminUsersList(ctx, chatID, &msgID, "", 0)
+		_ = b.answerCallback(cb, "Ок", false)
+
+	case strings.HasPrefix(data, "inv:"):
+		b.handleAdminInvitesCallback(ctx, cb, strings.TrimPrefix(data, "inv:"))
+
+	case strings.HasPrefix(data, "pf:"):
+		b.handleAdminProfileCallback(ctx, cb, strings.TrimPrefix(data, "pf:"), payload)
+
+	case strings.HasPrefix(data, "card:"):
+		id, _ := strconv.ParseInt(strings.TrimPrefix(data, "card:"), 10, 64)
+		b.showAdminUserCard(ctx, chatID, &msgID, id, payload)
+		_ = b.answerCallback(cb, "Ок", false)
+
+	case strings.HasPrefix(data, "block:"), strings.HasPrefix(data, "unblock:"):
+		block := strings.HasPrefix(data, "block:")
+		idStr := strings.TrimPrefix(strings.TrimPrefix(data, "un"), "block:")
+		id, _ := strconv.ParseInt(idStr, 10, 64)
+		target, _ := b.users.GetByID(ctx, id)
+		if target == nil {
+			_ = b.answerCallback(cb, "Пользователь не найден", true)
+			return
+		}
+		if block && target.TelegramID == cb.From.ID {
+			_ = b.answerCallback(cb, "Нельзя заблокировать самого себя", true)
+			return
+		}
+		if block && target.HasRole(users.RoleAdmin) {
+			_ = b.answerCallback(cb, "Сначала снимите с пользователя роль администратора", true)
+			return
+		}
+		if block {
+			if err := b.users.Block(ctx, id); err != nil {
+				_ = b.answerCallback(cb, "Ошибка", true)
+				return
+			}
+			b.notifyUserBlocked(ctx, target)
+		} else {
+			status, err := b.users.Unblock(ctx, id)
+			if err != nil {
+				_ = b.answerCallback(cb, "Ошибка", true)
+				return
+			}
+			// доступ открываем только тем, кого одобрили до блокировки
+			if status == users.StatusApproved {
+				b.notifyUserRoleChanged(ctx, id, "Доступ к боту снова открыт.")
+			}
+		}
+		b.showAdminUserCard(ctx, chatID, &msgID, id, payload)
+		_ = b.answerCallback(cb, "Готово", false)
+
+	case strings.HasPrefix(data, "role:add:"), strings.HasPrefix(data, "role:rm:"):
+		add := strings.HasPrefix(data, "role:add:")
+		rest := strings.TrimPrefix(strings.TrimPrefix(data, "role:add:"), "role:rm:")
+		parts := strings.SplitN(rest, ":", 2)
+		if len(parts) != 2 {
+			_ = b.answerCallback(cb, "Некорректные данные", true)
+			return
+		}
+		id, _ := strconv.ParseInt(parts[0], 10, 64)
+		role := users.Role(parts[1])
+		target, _ := b.users.GetByID(ctx, id)
+		if target == nil {
+			_ = b.answerCallback(cb, "Пользователь не найден", true)
+			return
+		}
+		if add {
+			if err := b.users.AddRole(ctx, id, role); err != nil {
+				_ = b.answerCallback(cb, "Ошибка", true)
+				return
+			}
+			if target.Status == users.StatusApproved {
+				b.send(tgbotapi.NewMessage(target.TelegramID,
+					fmt.Sprintf("Вам добавлена роль «%s». Переключиться можно кнопкой «Сменить роль».", roleLabel(role))))
+			}
+		} else {
+			if err := b.users.RemoveRole(ctx, id, role); err != nil {
+				if errors.Is(err, users.ErrLastRole) {
+					_ = b.answerCallback(cb, "Нельзя снять последнюю роль. Заблокируйте пользователя.", true)
+					return
+				}
+				if errors.Is(err, users.ErrLastAdmin) {
+					_ = b.answerCallback(cb, "Это последний администратор — сначала назначьте другого.", true)
+					return
+				}
+				_ = b.answerCallback(cb, "Ошибка", true)
+				return
+			}
+			if target.Role == role {
+				b.notifyUserRoleChanged(ctx, id, fmt.Sprintf("Роль «%s» снята администратором.", roleLabel(role)))
+			}
+		}
+		b.showAdminUserCard(ctx, chatID, &msgID, id, payload)
+		_ = b.answerCallback(cb, "Готово", false)
+
+	case strings.HasPrefix(data, "rn:"):
+		id, _ := strconv.ParseInt(strings.TrimPrefix(data, "rn:"), 10, 64)
+		p := dialog.Payload{"user_id": id, "query": payload["query"], "page": payload["page"]}
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmUserRename, p)
+		b.editTextWithNav(chatID, msgID, "Введите новое ФИО пользователя сообщением.")
+		_ = b.answerCallback(cb, "Ок", false)
+
+	case strings.HasPrefix(data, "sw:set:"):
+		parts := strings.SplitN(strings.TrimPrefix(data, "sw:set:"), ":", 2)
+		if len(parts) != 2 {
+			_ = b.answerCallback(cb, "Некорректные данные", true)
+			return
+		}
+		id, _ := strconv.ParseInt(parts[0], 10, 64)
+		role := users.Role(parts[1])
+		if err := b.users.SetActiveRole(ctx, id, role); err != nil {
+			_ = b.answerCallback(cb, "Ошибка", true)
+			return
+		}
+		b.notifyUserRoleChanged(ctx, id, fmt.Sprintf("Администратор переключил вашу роль на «%s».", roleLabel(role)))
+		b.showAdminUserCard(ctx, chatID, &msgID, id, payload)
+		_ = b.answerCallback(cb, "Готово", false)
+
+	case strings.HasPrefix(data, "sw:"):
+		id, _ := strconv.ParseInt(strings.TrimPrefix(data, "sw:"), 10, 64)
+		target, _ := b.users.GetByID(ctx, id)
+		if target == nil {
+			_ = b.answerCallback(cb, "Пользователь не найден", true)
+			return
+		}
+		p := dialog.Payload{"user_id": id, "query": payload["query"], "page": payload["page"]}
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmUserSwitchRole, p)
+		b.showAdminUserSwitchRole(ctx, chatID, msgID, target)
+		_ = b.answerCallback(cb, "Ок", false)
+
+	default:
+		_ = b.answerCallback(cb, "Неизвестная команда", true)
+	}
+}
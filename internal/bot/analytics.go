@@ -0,0 +1,313 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Spok95/beauty-bot/internal/domain/analytics"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/xuri/excelize/v2"
+)
+
+// analyticsTop — размер топа материалов в файле (в тексте — первые 5).
+const analyticsTop = 10
+
+// handleAdmAnalytics считает аналитику за период [from; toExclusive) и такой же период месяцем раньше,
+// отправляет текстовую сводку и Excel с диаграммами.
+func (b *Bot) handleAdmAnalytics(ctx context.Context, chatID int64, from, toExclusive time.Time) error {
+	cur, err := b.analyticsFor(ctx, from, toExclusive)
+	if err != nil {
+		return err
+	}
+	prev, err := b.analyticsFor(ctx, monthEarlier(from), monthEarlier(toExclusive.AddDate(0, 0, -1)).AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+	period := fmt.Sprintf("%s — %s", from.Format("02.01.2006"), toExclusive.AddDate(0, 0, -1).Format("02.01.2006"))
+
+	b.send(tgbotapi.NewMessage(chatID, analyticsSummaryText(period, cur, prev)))
+	if cur.Sessions == 0 && cur.SubSold == 0 {
+		return nil
+	}
+
+	data, err := buildAnalyticsWorkbook(period, cur, prev)
+	if err != nil {
+		return err
+	}
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name: fmt.Sprintf("analytics_%s_%s.xlsx",
+			from.Format("20060102"), toExclusive.AddDate(0, 0, -1).Format("20060102")),
+		Bytes: data,
+	})
+	doc.Caption = "Аналитика за " + period
+	b.send(doc)
+	return nil
+}
+
+// analyticsFor — показатели сессий за [from; to) вместе с продажами абонементов.
+func (b *Bot) analyticsFor(ctx context.Context, from, to time.Time) (analytics.Summary, error) {
+	sessions, err := b.cons.ListMasterSessionsReport(ctx, from, to)
+	if err != nil {
+		return analytics.Summary{}, err
+	}
+	a := analytics.Analyze(sessions, analyticsTop)
+	sales, err := b.subs.SalesBetween(ctx, from, to)
+	if err != nil {
+		return analytics.Summary{}, err
+	}
+	a.SubSold, a.SubSales = sales.Count, sales.Amount
+	return a, nil
+}
+
+// monthEarlier — тот же день месяцем раньше; если такого дня нет (31 марта → февраль),
+// берётся последний день месяца, а не переход в следующий, как у AddDate.
+func monthEarlier(t time.Time) time.Time {
+	first := time.Date(t.Year(), t.Month()-1, 1, 0, 0, 0, 0, t.Location())
+	day := t.Day()
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// analyticsDelta — изменение к прошлому периоду: «+12.5%», «новое», пусто — без изменений.
+func analyticsDelta(cur, prev float64) string {
+	switch {
+	case prev == 0 && cur == 0:
+		return ""
+	case prev == 0:
+		return "новое"
+	}
+	d := (cur - prev) * 100 / math.Abs(prev)
+	return fmt.Sprintf("%+.1f%%", d)
+}
+
+// analyticsDeltaValue — изменение в процентах для Excel (nil — сравнивать не с чем).
+func analyticsDeltaValue(cur, prev float64) interface{} {
+	if prev == 0 {
+		return nil
+	}
+	return math.Round((cur-prev)*1000/math.Abs(prev)) / 10
+}
+
+func withDelta(s string, cur, prev float64) string {
+	if d := analyticsDelta(cur, prev); d != "" {
+		return fmt.Sprintf("%s (%s)", s, d)
+	}
+	return s
+}
+
+func analyticsSummaryText(period string, cur, prev analytics.Summary) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📊 Аналитика за %s\n", period))
+	if cur.Sessions == 0 && cur.SubSold == 0 {
+		sb.WriteString("\nЗа период нет сессий аренды, расхода материалов и продаж абонементов.")
+		return sb.String()
+	}
+	sb.WriteString("Изменения — к тому же периоду месяцем раньше.\n\n")
+
+	sb.WriteString(withDelta(fmt.Sprintf("Выручка к оплате: %.2f ₽", cur.Total), cur.Total, prev.Total) + "\n")
+	sb.WriteString(withDelta(fmt.Sprintf("— аренда: %.2f ₽", cur.Rent), cur.Rent, prev.Rent) + "\n")
+	sb.WriteString(withDelta(fmt.Sprintf("— материалы: %.2f ₽", cur.Materials), cur.Materials, prev.Materials) + "\n")
+	if cur.Discounts > 0 {
+		sb.WriteString(fmt.Sprintf("— скидки: −%.2f ₽\n", cur.Discounts))
+	}
+	sb.WriteString(withDelta(fmt.Sprintf("Продажи абонементов: %.2f ₽ (%d шт.)", cur.SubSales, cur.SubSold), cur.SubSales, prev.SubSales) + "\n")
+	sb.WriteString(withDelta(fmt.Sprintf("Использовано по абонементам: %.2f ₽", cur.SubRent), cur.SubRent, prev.SubRent) + "\n")
+	sb.WriteString(withDelta(fmt.Sprintf("Сессий: %d", cur.Sessions), float64(cur.Sessions), float64(prev.Sessions)))
+	sb.WriteString(fmt.Sprintf(", мастеров: %d\n", cur.Masters))
+
+	if len(cur.Usage) > 0 {
+		sb.WriteString("\nЗагрузка помещений:\n")
+		for _, u := range cur.Usage {
+			line := fmt.Sprintf("— %s: %d %s", placeLabel(u.Place), u.Qty, reportUnitLabel(u.Unit))
+			if u.SubQty > 0 {
+				line += fmt.Sprintf(", из них по абонементу %d", u.SubQty)
+			}
+			sb.WriteString(withDelta(line, float64(u.Qty), float64(prev.UsageQty(u.Place, u.Unit))) + "\n")
+		}
+	}
+
+	if cur.ThresholdSessions > 0 {
+		sb.WriteString(fmt.Sprintf("\nПорог материалов выполнен в %d из %d сессий аренды (%.0f%%",
+			cur.ThresholdMet, cur.ThresholdSessions, cur.ThresholdShare()))
+		if prev.ThresholdSessions > 0 {
+			sb.WriteString(fmt.Sprintf(", было %.0f%%", prev.ThresholdShare()))
+		}
+		sb.WriteString(")\n")
+	}
+
+	if len(cur.TopByCost) > 0 {
+		sb.WriteString("\nТоп материалов по сумме:\n")
+		for i, m := range cur.TopByCost[:min(5, len(cur.TopByCost))] {
+			sb.WriteString(fmt.Sprintf("%d. %s — %.2f ₽ (%s %s)\n",
+				i+1, materialDisplayName(m.BrandName, m.MaterialName), m.Cost, formatQty(m.Qty), m.Unit))
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// analyticsRange — ссылка на диапазон столбца для диаграммы: 'Лист'!$B$2:$B$5.
+func analyticsRange(sheet, col string, first, last int) string {
+	return fmt.Sprintf("'%s'!$%s$%d:$%s$%d", sheet, col, first, col, last)
+}
+
+func analyticsChart(f *excelize.File, sheet, cell string, typ excelize.ChartType, title string, series ...excelize.ChartSeries) error {
+	return f.AddChart(sheet, cell, &excelize.Chart{
+		Type:   typ,
+		Series: series,
+		Title:  []excelize.RichTextRun{{Text: title}},
+		Legend: excelize.ChartLegend{Position: "bottom"},
+		PlotArea: excelize.ChartPlotArea{
+			ShowVal:     typ != excelize.Pie,
+			ShowPercent: typ == excelize.Pie,
+		},
+		Dimension: excelize.ChartDimension{Width: 640, Height: 320},
+	})
+}
+
+// buildAnalyticsWorkbook — Excel: выручка, загрузка, материалы и мастера, с диаграммами.
+func buildAnalyticsWorkbook(period string, cur, prev analytics.Summary) ([]byte, error) {
+	f := excelize.NewFile()
+	defer func() { _ = f.Close() }()
+
+	// Выручка
+	const revenue = "Выручка"
+	if err := f.SetSheetName(f.GetSheetName(f.GetActiveSheetIndex()), revenue); err != nil {
+		return nil, err
+	}
+	rows := [][]interface{}{
+		{"Статья", period, "Месяцем раньше", "Изменение, %"},
+		{"Аренда", cur.Rent, prev.Rent, analyticsDeltaValue(cur.Rent, prev.Rent)},
+		{"Материалы", cur.Materials, prev.Materials, analyticsDeltaValue(cur.Materials, prev.Materials)},
+		{"Продажи абонементов", cur.SubSales, prev.SubSales, analyticsDeltaValue(cur.SubSales, prev.SubSales)},
+		{"Продано абонементов, шт.", cur.SubSold, prev.SubSold, analyticsDeltaValue(float64(cur.SubSold), float64(prev.SubSold))},
+		{"Использовано по абонементам", cur.SubRent, prev.SubRent, analyticsDeltaValue(cur.SubRent, prev.SubRent)},
+		{"Скидки", -cur.Discounts, -prev.Discounts, analyticsDeltaValue(cur.Discounts, prev.Discounts)},
+		{"К оплате", cur.Total, prev.Total, analyticsDeltaValue(cur.Total, prev.Total)},
+		{"Сессий", cur.Sessions, prev.Sessions, analyticsDeltaValue(float64(cur.Sessions), float64(prev.Sessions))},
+		{"Мастеров", cur.Masters, prev.Masters, analyticsDeltaValue(float64(cur.Masters), float64(prev.Masters))},
+		{"Порог выполнен, %", math.Round(cur.ThresholdShare()*10) / 10, math.Round(prev.ThresholdShare()*10) / 10, nil},
+	}
+	for i, r := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow(revenue, cell, &r); err != nil {
+			return nil, err
+		}
+	}
+	if err := analyticsChart(f, revenue, "F2", excelize.Pie, "Структура выручки", excelize.ChartSeries{
+		Name:       period,
+		Categories: analyticsRange(revenue, "A", 2, 4),
+		Values:     analyticsRange(revenue, "B", 2, 4),
+	}); err != nil {
+		return nil, err
+	}
+
+	// Загрузка помещений
+	if len(cur.Usage) > 0 {
+		const usage = "Загрузка"
+		if _, err := f.NewSheet(usage); err != nil {
+			return nil, err
+		}
+		header := []interface{}{"Помещение", "Ед.", "Сессий", "Кол-во", "По абонементу", "Месяцем раньше", "Изменение, %"}
+		if err := f.SetSheetRow(usage, "A1", &header); err != nil {
+			return nil, err
+		}
+		for i, u := range cur.Usage {
+			p := prev.UsageQty(u.Place, u.Unit)
+			row := []interface{}{
+				placeLabel(u.Place), reportUnitLabel(u.Unit), u.Sessions, u.Qty, u.SubQty, p,
+				analyticsDeltaValue(float64(u.Qty), float64(p)),
+			}
+			cell, _ := excelize.CoordinatesToCellName(1, i+2)
+			if err := f.SetSheetRow(usage, cell, &row); err != nil {
+				return nil, err
+			}
+		}
+		last := len(cur.Usage) + 1
+		if err := analyticsChart(f, usage, "I2", excelize.Col, "Загрузка помещений",
+			excelize.ChartSeries{Name: "Период", Categories: analyticsRange(usage, "A", 2, last), Values: analyticsRange(usage, "D", 2, last)},
+			excelize.ChartSeries{Name: "Месяцем раньше", Categories: analyticsRange(usage, "A", 2, last), Values: analyticsRange(usage, "F", 2, last)},
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	// Топ материалов
+	if len(cur.TopByCost) > 0 {
+		const mats = "Материалы"
+		if _, err := f.NewSheet(mats); err != nil {
+			return nil, err
+		}
+		writeTop := func(startRow int, title string, list []analytics.MaterialUsage) (int, error) {
+			_ = f.SetCellValue(mats, fmt.Sprintf("A%d", startRow), title)
+			header := []interface{}{"Бренд", "Материал", "Ед.", "Кол-во", "Сумма"}
+			if err := f.SetSheetRow(mats, fmt.Sprintf("A%d", startRow+1), &header); err != nil {
+				return 0, err
+			}
+			for i, m := range list {
+				row := []interface{}{m.BrandName, m.MaterialName, m.Unit, m.Qty, m.Cost}
+				if err := f.SetSheetRow(mats, fmt.Sprintf("A%d", startRow+2+i), &row); err != nil {
+					return 0, err
+				}
+			}
+			return startRow + 2 + len(list), nil
+		}
+		next, err := writeTop(1, "Топ по сумме", cur.TopByCost)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := writeTop(next+1, "Топ по количеству", cur.TopByQty); err != nil {
+			return nil, err
+		}
+		last := len(cur.TopByCost) + 2
+		if err := analyticsChart(f, mats, "G2", excelize.Bar, "Топ материалов по сумме, ₽", excelize.ChartSeries{
+			Name:       "Сумма",
+			Categories: analyticsRange(mats, "B", 3, last),
+			Values:     analyticsRange(mats, "E", 3, last),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	// Мастера: расход материалов на час/день аренды
+	if len(cur.Spend) > 0 {
+		const masters = "Мастера"
+		if _, err := f.NewSheet(masters); err != nil {
+			return nil, err
+		}
+		header := []interface{}{"Мастер", "Часов", "Материалы (почасовая)", "На час", "Дней", "Материалы (посуточная)", "На день"}
+		if err := f.SetSheetRow(masters, "A1", &header); err != nil {
+			return nil, err
+		}
+		for i, m := range cur.Spend {
+			row := []interface{}{
+				strings.TrimSpace(m.Username),
+				m.Hours, m.HourMaterials, math.Round(m.PerHour()*100) / 100,
+				m.Days, m.DayMaterials, math.Round(m.PerDay()*100) / 100,
+			}
+			cell, _ := excelize.CoordinatesToCellName(1, i+2)
+			if err := f.SetSheetRow(masters, cell, &row); err != nil {
+				return nil, err
+			}
+		}
+		last := len(cur.Spend) + 1
+		if err := analyticsChart(f, masters, "I2", excelize.Bar, "Материалы на час аренды, ₽", excelize.ChartSeries{
+			Name:       "На час",
+			Categories: analyticsRange(masters, "A", 2, last),
+			Values:     analyticsRange(masters, "D", 2, last),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,449 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Spok95/beauty-bot/internal/dialog"
+	"github.com/Spok95/beauty-bot/internal/domain/audit"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/xuri/excelize/v2"
+)
+
+const auditPageSize = 10
+
+// auditSections — разделы журнала в порядке показа.
+var auditSections = []audit.Entity{
+	audit.EntityWarehouse, audit.EntityCategory,
+	audit.EntityMaterial, audit.EntityBrand,
+	audit.EntityRentRate, audit.EntityTariffVersion,
+	audit.EntityTemplate, audit.EntitySubscription,
+	audit.EntityInvoice, audit.EntityUser,
+	audit.EntityInvite, audit.EntityDiscount,
+	audit.EntityReportSchedule, audit.EntityBroadcast,
+	audit.EntitySession,
+}
+
+func auditEntityLabel(e audit.Entity) string {
+	switch e {
+	case audit.EntityWarehouse:
+		return "Склад"
+	case audit.EntityCategory:
+		return "Категория"
+	case audit.EntityMaterial:
+		return "Материал"
+	case audit.EntityBrand:
+		return "Бренд"
+	case audit.EntityRentRate:
+		return "Тариф аренды"
+	case audit.EntityTariffVersion:
+		return "Версия тарифов"
+	case audit.EntityTemplate:
+		return "Шаблон"
+	case audit.EntitySubscription:
+		return "Абонемент"
+	case audit.EntityInvoice:
+		return "Счёт"
+	case audit.EntityUser:
+		return "Пользователь"
+	case audit.EntityInvite:
+		return "Приглашение"
+	case audit.EntityDiscount:
+		return "Скидка"
+	case audit.EntityReportSchedule:
+		return "Отчёт по расписанию"
+	case audit.EntityBroadcast:
+		return "Рассылка"
+	case audit.EntitySession:
+		return "Сессия"
+	default:
+		return string(e)
+	}
+}
+
+func auditActionLabel(a audit.Action) string {
+	switch a {
+	case audit.ActionCreate:
+		return "создание"
+	case audit.ActionDelete:
+		return "удаление"
+	default:
+		return "изменение"
+	}
+}
+
+func auditActionIcon(a audit.Action) string {
+	switch a {
+	case audit.ActionCreate:
+		return "➕"
+	case audit.ActionDelete:
+		return "🗑"
+	default:
+		return "✏️"
+	}
+}
+
+func auditSourceLabel(s audit.Source) string {
+	switch s {
+	case audit.SourceBot:
+		return "бот"
+	case audit.SourceExcel:
+		return "Excel"
+	case audit.SourceAPI:
+		return "API"
+	default:
+		return "система"
+	}
+}
+
+func auditActorLabel(e audit.Event) string {
+	if e.ActorID == 0 {
+		return auditSourceLabel(e.Source)
+	}
+	if strings.TrimSpace(e.ActorName) != "" {
+		return e.ActorName
+	}
+	return fmt.Sprintf("id:%d", e.ActorID)
+}
+
+// auditChange — изменение одного поля снимка.
+type auditChange struct {
+	Field  string
+	Before string
+	After  string
+}
+
+// auditDiff — поля, различающиеся в снимках до и после; служебный updated_at не показываем.
+// Для создания и удаления — все поля имеющегося снимка.
+func auditDiff(before, after json.RawMessage) []auditChange {
+	var b, a map[string]json.RawMessage
+	_ = json.Unmarshal(before, &b)
+	_ = json.Unmarshal(after, &a)
+
+	keys := map[string]struct{}{}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	delete(keys, "updated_at")
+
+	fields := make([]string, 0, len(keys))
+	for k := range keys {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+
+	var out []auditChange
+	for _, k := range fields {
+		bv, av := auditValue(b[k]), auditValue(a[k])
+		if bv == av {
+			continue
+		}
+		out = append(out, auditChange{Field: k, Before: bv, After: av})
+	}
+	return out
+}
+
+// auditValue — значение поля для показа: строки без кавычек, null — пусто.
+func auditValue(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+func auditFieldsLine(changes []auditChange) string {
+	names := make([]string, 0, len(changes))
+	for _, c := range changes {
+		names = append(names, c.Field)
+	}
+	return strings.Join(names, ", ")
+}
+
+// showAuditSections — выбор раздела журнала изменений.
+func (b *Bot) showAuditSections(ctx context.Context, chatID int64, editMsgID *int) {
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("Все изменения", "adm:audit:ent:all")),
+	}
+	for i := 0; i < len(auditSections); i += 2 {
+		row := []tgbotapi.InlineKeyboardButton{}
+		for _, e := range auditSections[i:min(i+2, len(auditSections))] {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(auditEntityLabel(e), "adm:audit:ent:"+string(e)))
+		}
+		rows = append(rows, row)
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📤 Выгрузить в Excel", "adm:audit:export")),
+		navKeyboard(false, true).InlineKeyboard[0],
+	)
+
+	text := "Журнал изменений\n\nВыберите раздел — покажу последние изменения с автором и источником."
+	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	_ = b.states.Set(ctx, chatID, dialog.StateAdmAudit, dialog.Payload{})
+	if editMsgID != nil {
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, *editMsgID, text, kb))
+		return
+	}
+	m := tgbotapi.NewMessage(chatID, text)
+	m.ReplyMarkup = kb
+	b.send(m)
+}
+
+// showAuditList — события раздела (entity пустой — все) или одного объекта, новые сверху.
+func (b *Bot) showAuditList(ctx context.Context, chatID int64, editMsgID *int, entity string, entityID int64, page int) {
+	if page < 0 {
+		page = 0
+	}
+	var f audit.Filter
+	if entity != "" {
+		f.Entities = []audit.Entity{audit.Entity(entity)}
+	}
+	f.EntityID = entityID
+
+	list, total, err := b.audit.List(ctx, f, auditPageSize, page*auditPageSize)
+	if err != nil {
+		b.log.Error("audit list failed", "err", err)
+		b.send(tgbotapi.NewMessage(chatID, "Ошибка загрузки журнала"))
+		return
+	}
+	totalPages := (total + auditPageSize - 1) / auditPageSize
+	if totalPages == 0 {
+		totalPages = 1
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for _, e := range list {
+		label := fmt.Sprintf("%s %s · %s #%d · %s",
+			auditActionIcon(e.Action), e.CreatedAt.In(b.loc).Format("02.01 15:04"),
+			auditEntityLabel(e.Entity), e.EntityID, auditActorLabel(e))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("adm:audit:ev:%d", e.ID)),
+		))
+	}
+
+	if totalPages > 1 {
+		pager := []tgbotapi.InlineKeyboardButton{}
+		if page > 0 {
+			pager = append(pager, tgbotapi.NewInlineKeyboardButtonData("⬅️", fmt.Sprintf("adm:audit:page:%d", page-1)))
+		} else {
+			pager = append(pager, tgbotapi.NewInlineKeyboardButtonData(" ", "noop"))
+		}
+		pager = append(pager, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d/%d", page+1, totalPages), "noop"))
+		if page < totalPages-1 {
+			pager = append(pager, tgbotapi.NewInlineKeyboardButtonData("➡️", fmt.Sprintf("adm:audit:page:%d", page+1)))
+		} else {
+			pager = append(pager, tgbotapi.NewInlineKeyboardButtonData(" ", "noop"))
+		}
+		rows = append(rows, pager)
+	}
+	rows = append(rows, navKeyboard(true, true).InlineKeyboard[0])
+
+	title := "Все изменения"
+	if entity != "" {
+		title = auditEntityLabel(audit.Entity(entity))
+	}
+	if entityID > 0 {
+		title = fmt.Sprintf("%s #%d", title, entityID)
+	}
+	text := fmt.Sprintf("Журнал: %s\nСобытий: %d, страница %d/%d", title, total, page+1, totalPages)
+	if total == 0 {
+		text += "\n\nИзменений пока нет."
+	}
+
+	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	_ = b.states.Set(ctx, chatID, dialog.StateAdmAuditList, dialog.Payload{
+		"entity": entity, "entity_id": entityID, "page": page,
+	})
+	if editMsgID != nil {
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, *editMsgID, text, kb))
+		return
+	}
+	m := tgbotapi.NewMessage(chatID, text)
+	m.ReplyMarkup = kb
+	b.send(m)
+}
+
+// showAuditEvent — событие с разницей «было → стало» по полям.
+func (b *Bot) showAuditEvent(ctx context.Context, chatID int64, msgID int, eventID int64, listPayload dialog.Payload) {
+	e, err := b.audit.GetByID(ctx, eventID)
+	if err != nil || e == nil {
+		b.editTextWithNav(chatID, msgID, "Событие не найдено")
+		return
+	}
+
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, "%s %s #%d — %s\n", auditActionIcon(e.Action), auditEntityLabel(e.Entity), e.EntityID, auditActionLabel(e.Action))
+	_, _ = fmt.Fprintf(&sb, "Когда: %s\n", e.CreatedAt.In(b.loc).Format("02.01.2006 15:04:05"))
+	_, _ = fmt.Fprintf(&sb, "Кто: %s\n", auditActorLabel(*e))
+	_, _ = fmt.Fprintf(&sb, "Источник: %s\n\n", auditSourceLabel(e.Source))
+
+	for _, c := range auditDiff(e.Before, e.After) {
+		switch e.Action {
+		case audit.ActionCreate:
+			_, _ = fmt.Fprintf(&sb, "• %s: %s\n", c.Field, truncateRunes(c.After, 200))
+		case audit.ActionDelete:
+			_, _ = fmt.Fprintf(&sb, "• %s: %s\n", c.Field, truncateRunes(c.Before, 200))
+		default:
+			_, _ = fmt.Fprintf(&sb, "• %s: %s → %s\n", c.Field,
+				orDash(truncateRunes(c.Before, 200)), orDash(truncateRunes(c.After, 200)))
+		}
+	}
+
+	kb := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔎 Все изменения объекта",
+			fmt.Sprintf("adm:audit:obj:%s:%d", e.Entity, e.EntityID))),
+		navKeyboard(true, true).InlineKeyboard[0],
+	)
+
+	p := dialog.Payload{}
+	for k, v := range listPayload {
+		p[k] = v
+	}
+	p["event_id"] = e.ID
+	_ = b.states.Set(ctx, chatID, dialog.StateAdmAuditEvent, p)
+	b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, msgID, truncateRunes(sb.String(), 4000), kb))
+}
+
+// truncateRunes обрезает строку до n символов.
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "…"
+}
+
+// handleAuditCallback — кнопки журнала (data без префикса "adm:audit:").
+func (b *Bot) handleAuditCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, data string) {
+	chatID := cb.Message.Chat.ID
+	msgID := cb.Message.MessageID
+	_ = b.answerCallback(cb, "", false)
+
+	switch {
+	case strings.HasPrefix(data, "ent:"):
+		entity := strings.TrimPrefix(data, "ent:")
+		if entity == "all" {
+			entity = ""
+		}
+		b.showAuditList(ctx, chatID, &msgID, entity, 0, 0)
+
+	case strings.HasPrefix(data, "page:"):
+		page, _ := strconv.Atoi(strings.TrimPrefix(data, "page:"))
+		st, _ := b.states.Get(ctx, chatID)
+		var entity string
+		var entityID int64
+		if st != nil {
+			entity = payloadString(st.Payload, "entity")
+			entityID = payloadInt64(st.Payload["entity_id"])
+		}
+		b.showAuditList(ctx, chatID, &msgID, entity, entityID, page)
+
+	case strings.HasPrefix(data, "ev:"):
+		id, _ := strconv.ParseInt(strings.TrimPrefix(data, "ev:"), 10, 64)
+		st, _ := b.states.Get(ctx, chatID)
+		var p dialog.Payload
+		if st != nil {
+			p = st.Payload
+		}
+		b.showAuditEvent(ctx, chatID, msgID, id, p)
+
+	case strings.HasPrefix(data, "obj:"):
+		parts := strings.Split(strings.TrimPrefix(data, "obj:"), ":")
+		if len(parts) != 2 {
+			return
+		}
+		id, _ := strconv.ParseInt(parts[1], 10, 64)
+		b.showAuditList(ctx, chatID, &msgID, parts[0], id, 0)
+
+	case data == "export":
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmAuditExportPeriod, dialog.Payload{})
+		b.editTextWithNav(chatID, msgID,
+			"Введите период выгрузки в формате ДД.ММ.ГГГГ-ДД.ММ.ГГГГ.\n"+
+				"Например: 01.11.2025-30.11.2025.\n"+
+				"Дата окончания включительно.")
+	}
+}
+
+// exportAuditExcel отправляет журнал изменений за период одним xlsx-файлом.
+func (b *Bot) exportAuditExcel(ctx context.Context, chatID int64, from, toExclusive time.Time) {
+	list, _, err := b.audit.List(ctx, audit.Filter{From: from, To: toExclusive}, 0, 0)
+	if err != nil {
+		b.log.Error("audit export failed", "err", err)
+		b.send(tgbotapi.NewMessage(chatID, "Ошибка загрузки журнала"))
+		return
+	}
+	if len(list) == 0 {
+		b.send(tgbotapi.NewMessage(chatID, "За этот период изменений нет. Введите другой период или нажмите «Отменить»."))
+		return
+	}
+
+	f := excelize.NewFile()
+	defer func() { _ = f.Close() }()
+
+	sheet := f.GetSheetName(f.GetActiveSheetIndex())
+	header := []interface{}{
+		"Дата", "Автор", "Источник", "Объект", "ID", "Действие", "Изменения", "До (JSON)", "После (JSON)",
+	}
+	if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
+		b.send(tgbotapi.NewMessage(chatID, "Ошибка формирования файла (заголовок)"))
+		return
+	}
+
+	for i, e := range list {
+		var changes []string
+		for _, c := range auditDiff(e.Before, e.After) {
+			switch e.Action {
+			case audit.ActionCreate:
+				changes = append(changes, fmt.Sprintf("%s: %s", c.Field, c.After))
+			case audit.ActionDelete:
+				changes = append(changes, fmt.Sprintf("%s: %s", c.Field, c.Before))
+			default:
+				changes = append(changes, fmt.Sprintf("%s: %s → %s", c.Field, c.Before, c.After))
+			}
+		}
+		row := []interface{}{
+			e.CreatedAt.In(b.loc).Format("02.01.2006 15:04:05"),
+			auditActorLabel(e),
+			auditSourceLabel(e.Source),
+			auditEntityLabel(e.Entity),
+			e.EntityID,
+			auditActionLabel(e.Action),
+			strings.Join(changes, "\n"),
+			string(e.Before),
+			string(e.After),
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			b.send(tgbotapi.NewMessage(chatID, "Ошибка формирования файла"))
+			return
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := f.Write(buf); err != nil {
+		b.send(tgbotapi.NewMessage(chatID, "Ошибка записи файла"))
+		return
+	}
+
+	to := toExclusive.Add(-24 * time.Hour)
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("audit_%s_%s.xlsx", from.Format("20060102"), to.Format("20060102")),
+		Bytes: buf.Bytes(),
+	})
+	doc.Caption = fmt.Sprintf("Журнал изменений за %s–%s: %d событий.",
+		from.Format("02.01.2006"), to.Format("02.01.2006"), len(list))
+	b.send(doc)
+	_ = b.states.Set(ctx, chatID, dialog.StateIdle, dialog.Payload{})
+}
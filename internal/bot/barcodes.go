@@ -0,0 +1,172 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Spok95/beauty-bot/internal/dialog"
+	"github.com/Spok95/beauty-bot/internal/domain/materials"
+	"github.com/Spok95/beauty-bot/internal/infra/barcode"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const barcodePrompt = "Отправьте фото штрихкода или введите код цифрами."
+
+// hasBarcodeImage — пришло ли в сообщении изображение (фото или картинка файлом).
+func hasBarcodeImage(msg *tgbotapi.Message) bool {
+	if len(msg.Photo) > 0 {
+		return true
+	}
+	return msg.Document != nil && strings.HasPrefix(msg.Document.MimeType, "image/")
+}
+
+// readBarcode достаёт штрихкод из сообщения: распознаёт фото или берёт введённый текст.
+// errText — готовый текст ошибки для пользователя.
+func (b *Bot) readBarcode(ctx context.Context, msg *tgbotapi.Message) (code string, errText string) {
+	if hasBarcodeImage(msg) {
+		fileID := ""
+		if len(msg.Photo) > 0 {
+			fileID = msg.Photo[len(msg.Photo)-1].FileID // самое большое разрешение
+		} else {
+			fileID = msg.Document.FileID
+		}
+		data, err := b.downloadTelegramFile(ctx, fileID)
+		if err != nil {
+			b.log.Error("barcode: download photo failed", "err", err)
+			return "", "Не удалось скачать фото. Попробуйте ещё раз."
+		}
+		text, err := barcode.Decode(data)
+		if err != nil {
+			if !errors.Is(err, barcode.ErrNotFound) {
+				b.log.Warn("barcode: decode failed", "err", err)
+			}
+			return "", "Не удалось распознать штрихкод на фото. Сфотографируйте крупнее и ровнее или введите код цифрами."
+		}
+		return materials.NormalizeBarcode(text), ""
+	}
+
+	code = materials.NormalizeBarcode(msg.Text)
+	if code == "" {
+		return "", barcodePrompt
+	}
+	return code, ""
+}
+
+// findMaterialByBarcode ищет активный материал по коду, доступный на складе (whID = 0 — без проверки склада).
+func (b *Bot) findMaterialByBarcode(ctx context.Context, code string, whID int64) (*materials.Material, string) {
+	m, err := b.materials.GetByBarcode(ctx, code)
+	if err != nil {
+		return nil, "Ошибка поиска по штрихкоду."
+	}
+	if m == nil {
+		return nil, fmt.Sprintf("Материал со штрихкодом %s не найден. Попросите администратора привязать код к материалу.", code)
+	}
+	if !m.Active {
+		return nil, fmt.Sprintf("Материал «%s» скрыт и недоступен.", materialDisplayName(m.Brand, m.Name))
+	}
+	if whID > 0 {
+		ok, err := b.materials.IsMaterialAllowedInWarehouse(ctx, whID, m.ID)
+		if err != nil {
+			return nil, "Ошибка проверки материала на складе."
+		}
+		if !ok {
+			return nil, fmt.Sprintf("Материал «%s» не относится к выбранному складу.", materialDisplayName(m.Brand, m.Name))
+		}
+	}
+	return m, ""
+}
+
+// consPickByBarcode — выбор материала в корзину расхода по штрихкоду, дальше — ввод количества.
+func (b *Bot) consPickByBarcode(ctx context.Context, chatID int64, st *dialog.Item, msg *tgbotapi.Message) {
+	code, errText := b.readBarcode(ctx, msg)
+	if errText != "" {
+		b.send(tgbotapi.NewMessage(chatID, errText))
+		return
+	}
+	m, errText := b.findMaterialByBarcode(ctx, code, payloadInt64(st.Payload["warehouse_id"]))
+	if errText != "" {
+		b.send(tgbotapi.NewMessage(chatID, errText))
+		return
+	}
+
+	// после ввода количества вернёмся к вводу следующего кода/названия
+	st.Payload["mat_id"] = float64(m.ID)
+	st.Payload["cons_search_loop"] = true
+	delete(st.Payload, "cons_brand_id")
+	delete(st.Payload, "cons_pick_level")
+	_ = b.states.Set(ctx, chatID, dialog.StateConsMatQty, st.Payload)
+
+	out := tgbotapi.NewMessage(chatID, fmt.Sprintf("Введите количество для:\n%s\n\n%s",
+		materialDisplayName(m.Brand, m.Name), materialQtyHint(m.Unit)))
+	out.ReplyMarkup = navKeyboard(true, true)
+	b.send(out)
+}
+
+// supPickByBarcode — выбор материала в поставку по штрихкоду, дальше — ввод количества.
+func (b *Bot) supPickByBarcode(ctx context.Context, chatID int64, st *dialog.Item, msg *tgbotapi.Message) {
+	code, errText := b.readBarcode(ctx, msg)
+	if errText != "" {
+		b.send(tgbotapi.NewMessage(chatID, errText))
+		return
+	}
+	whID := payloadInt64(st.Payload["wh_id"])
+	m, errText := b.findMaterialByBarcode(ctx, code, whID)
+	if errText != "" {
+		b.send(tgbotapi.NewMessage(chatID, errText))
+		return
+	}
+
+	payload := dialog.Payload{
+		"wh_id":  whID,
+		"mat_id": m.ID,
+	}
+	if items, ok := st.Payload["items"]; ok {
+		payload["items"] = items
+	}
+	out := tgbotapi.NewMessage(chatID, fmt.Sprintf("%s\n\n%s", materialDisplayName(m.Brand, m.Name), supQtyPrompt(*m)))
+	out.ReplyMarkup = navKeyboard(true, true)
+	sent, _ := b.api.Send(out)
+	b.saveLastStep(ctx, chatID, dialog.StateSupQty, payload, sent.MessageID)
+}
+
+// showMaterialBarcodes — штрихкоды материала в админке: список с удалением, добавление — сообщением.
+func (b *Bot) showMaterialBarcodes(ctx context.Context, chatID int64, editMsgID *int, matID int64) {
+	m, _ := b.materials.GetByID(ctx, matID)
+	if m == nil {
+		if editMsgID != nil {
+			b.editTextAndClear(chatID, *editMsgID, "Материал не найден")
+		}
+		return
+	}
+	codes, err := b.materials.ListBarcodes(ctx, matID)
+	if err != nil {
+		b.send(tgbotapi.NewMessage(chatID, "Ошибка загрузки штрихкодов"))
+		return
+	}
+
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, "Штрихкоды: %s\n\n", materialDisplayName(m.Brand, m.Name))
+	if len(codes) == 0 {
+		sb.WriteString("Пока нет ни одного кода.\n")
+	}
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for _, c := range codes {
+		_, _ = fmt.Fprintf(&sb, "• %s\n", c.Code)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑 "+c.Code, fmt.Sprintf("adm:mat:bc:del:%d:%d", matID, c.ID)),
+		))
+	}
+	sb.WriteString("\nЧтобы добавить код, отправьте фото штрихкода или введите код цифрами.")
+	rows = append(rows, navKeyboard(true, true).InlineKeyboard[0])
+	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	if editMsgID != nil {
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, *editMsgID, sb.String(), kb))
+		return
+	}
+	out := tgbotapi.NewMessage(chatID, sb.String())
+	out.ReplyMarkup = kb
+	b.send(out)
+}
@@ -3,13 +3,22 @@ package bot
 import (
 	"context"
 	"log/slog"
+	"sync"
+	"time"
 
+	"github.com/Spok95/beauty-bot/internal/access"
 	"github.com/Spok95/beauty-bot/internal/domain/adminchat"
+	"github.com/Spok95/beauty-bot/internal/domain/audit"
 	"github.com/Spok95/beauty-bot/internal/domain/brands"
+	"github.com/Spok95/beauty-bot/internal/domain/broadcasts"
 	"github.com/Spok95/beauty-bot/internal/domain/consumption"
+	"github.com/Spok95/beauty-bot/internal/domain/discounts"
 	"github.com/Spok95/beauty-bot/internal/domain/inventory"
+	"github.com/Spok95/beauty-bot/internal/domain/invites"
 	"github.com/Spok95/beauty-bot/internal/domain/materials"
+	"github.com/Spok95/beauty-bot/internal/domain/reports"
 	subsdomain "github.com/Spok95/beauty-bot/internal/domain/subscriptions"
+	"github.com/Spok95/beauty-bot/internal/domain/templates"
 	payments "github.com/Spok95/beauty-bot/internal/infra/payments"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
@@ -27,8 +36,7 @@ type Bot struct {
 	users         *users.Repo
 	states        *dialog.Repo
 	adminChatRepo *adminchat.Repo
-	adminChat     int64
-	adminIDs      map[int64]struct{}
+	adminChat     int64 // необязательный общий чат (группа) для дублирования уведомлений
 	catalog       *catalog.Repo
 	materials     *materials.Repo
 	brands        *brands.Repo
@@ -36,57 +44,90 @@ type Bot struct {
 	cons          *consumption.Repo
 	subs          *subsdomain.Repo
 	payments      *payments.Service
+	templates     *templates.Repo
+	invites       *invites.Repo
+	audit         *audit.Repo
+	discounts     *discounts.Repo
+	reports       *reports.Repo
+	broadcasts    *broadcasts.Repo
+	policy        *access.Policy
+
+	loc           *time.Location // часовой пояс салона (app.timezone)
+	broadcastWake chan struct{}  // сигнал очереди рассылок: есть рассылка к отправке
+
+	webhookUpdates chan tgbotapi.Update // обновления, принятые WebhookHandler
+
+	albumsMu sync.Mutex
+	albums   map[string]*adminChatAlbum // альбомы админ-чата, ждущие остальных файлов (ключ — чат и media group)
+
+	exportsMu sync.Mutex
+	exports   map[int64]bool // обращения админ-чата, выгрузка которых идёт сейчас
 }
 
 func New(api *tgbotapi.BotAPI, log *slog.Logger,
 	usersRepo *users.Repo, statesRepo *dialog.Repo,
 	adminChatRepo *adminchat.Repo,
-	adminChatID int64, adminIDs []int64,
+	adminChatID int64,
 	catalogRepo *catalog.Repo,
 	materialsRepo *materials.Repo, brandsRepo *brands.Repo,
 	inventoryRepo *inventory.Repo,
 	consRepo *consumption.Repo, subsRepo *subsdomain.Repo,
-	paymentsSvc *payments.Service) *Bot {
-
-	adminMap := make(map[int64]struct{}, len(adminIDs))
-	for _, id := range adminIDs {
-		if id == 0 {
-			continue
-		}
-		adminMap[id] = struct{}{}
-	}
-	// совместимость: если список пуст, но adminChatID задан — считаем его единственным админом
-	if len(adminMap) == 0 && adminChatID != 0 {
-		adminMap[adminChatID] = struct{}{}
-	}
+	paymentsSvc *payments.Service,
+	templatesRepo *templates.Repo,
+	invitesRepo *invites.Repo,
+	auditRepo *audit.Repo,
+	discountsRepo *discounts.Repo,
+	reportsRepo *reports.Repo,
+	broadcastsRepo *broadcasts.Repo,
+	policy *access.Policy,
+	loc *time.Location) *Bot {
 
 	return &Bot{
 		api: api, log: log, users: usersRepo, states: statesRepo,
 		adminChatRepo: adminChatRepo,
-		adminChat:     adminChatID, adminIDs: adminMap,
-		catalog:   catalogRepo,
-		materials: materialsRepo, brands: brandsRepo,
+		adminChat:     adminChatID,
+		catalog:       catalogRepo,
+		materials:     materialsRepo, brands: brandsRepo,
 		inventory: inventoryRepo,
 		cons:      consRepo, subs: subsRepo,
-		payments: paymentsSvc,
+		payments:   paymentsSvc,
+		templates:  templatesRepo,
+		invites:    invitesRepo,
+		audit:      auditRepo,
+		discounts:  discountsRepo,
+		reports:    reportsRepo,
+		broadcasts: broadcastsRepo,
+		policy:     policy,
+		loc:        loc,
+
+		broadcastWake: make(chan struct{}, 1),
+		albums:        map[string]*adminChatAlbum{},
+		exports:       map[int64]bool{},
+
+		webhookUpdates: make(chan tgbotapi.Update, 100),
 	}
 }
 
-func (b *Bot) isAdminID(tgID int64) bool {
-	if b == nil {
-		return false
-	}
-	if len(b.adminIDs) == 0 {
-		return tgID == b.adminChat && b.adminChat != 0
-	}
-	_, ok := b.adminIDs[tgID]
-	return ok
+// now — текущее время в часовом поясе салона: от него считаются даты, месяцы и часы.
+func (b *Bot) now() time.Time {
+	return time.Now().In(b.loc)
 }
 
+// Run получает обновления long polling. Webhook, оставшийся от запуска в режиме webhook,
+// снимается: с ним getUpdates отвечает 409 Conflict.
 func (b *Bot) Run(ctx context.Context, timeoutSec int) error {
+	if _, err := b.api.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		b.log.Warn("deleteWebhook failed", "err", err)
+	}
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = timeoutSec
 	updates := b.api.GetUpdatesChan(u)
+	defer b.api.StopReceivingUpdates()
+	return b.serve(ctx, updates)
+}
+
+// serve — общий цикл обработки обновлений для long polling и webhook.
+func (b *Bot) serve(ctx context.Context, updates <-chan tgbotapi.Update) error {
 	for {
 		select {
 		case <-ctx.Done():
@@ -103,6 +144,13 @@ func (b *Bot) Run(ctx context.Context, timeoutSec int) error {
 
 func (b *Bot) onMessage(ctx context.Context, upd tgbotapi.Update) {
 	msg := upd.Message
+	ctx = b.withActor(ctx, msg.From)
+	if b.rejectIfBlocked(ctx, msg.From, msg.Chat.ID, nil) {
+		return
+	}
+	if !b.authorizeMessage(ctx, msg) {
+		return
+	}
 
 	if msg.IsCommand() {
 		b.handleCommand(ctx, msg)
@@ -112,5 +160,13 @@ func (b *Bot) onMessage(ctx context.Context, upd tgbotapi.Update) {
 }
 
 func (b *Bot) onCallback(ctx context.Context, upd tgbotapi.Update) {
-	b.handleCallback(ctx, upd.CallbackQuery)
+	cb := upd.CallbackQuery
+	ctx = b.withActor(ctx, cb.From)
+	if b.rejectIfBlocked(ctx, cb.From, 0, cb) {
+		return
+	}
+	if !b.authorizeCallback(ctx, cb) {
+		return
+	}
+	b.handleCallback(ctx, cb)
 }
@@ -0,0 +1,743 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Spok95/beauty-bot/internal/dialog"
+	"github.com/Spok95/beauty-bot/internal/domain/broadcasts"
+	"github.com/Spok95/beauty-bot/internal/domain/users"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// broadcastQueueTick — как часто очередь проверяет запланированные рассылки.
+	broadcastQueueTick = 15 * time.Second
+	// broadcastSendInterval — пауза между сообщениями (лимит Telegram — около 30 сообщений в секунду).
+	broadcastSendInterval = 50 * time.Millisecond
+	broadcastBatch        = 100
+	broadcastListLimit    = 10
+
+	broadcastTextLimit    = 4096
+	broadcastCaptionLimit = 1024
+)
+
+const broadcastScheduleLayout = "02.01.2006 15:04"
+
+// broadcastSegment — сегмент получателей из черновика рассылки.
+func broadcastSegment(p dialog.Payload) broadcasts.Segment {
+	return broadcasts.Segment{
+		Roles:       payloadStrings(p, "roles"),
+		Place:       payloadString(p, "place"),
+		WarehouseID: payloadInt64(p["wh_id"]),
+		Subs:        payloadString(p, "subs"),
+	}
+}
+
+func broadcastSubsLabel(subs string) string {
+	switch subs {
+	case broadcasts.SubsActive:
+		return "есть абонемент"
+	case broadcasts.SubsNone:
+		return "нет абонемента"
+	default:
+		return "все"
+	}
+}
+
+// broadcastSegmentText — описание получателей: «Роли: Мастер · Помещение: Кабинет».
+func (b *Bot) broadcastSegmentText(ctx context.Context, seg broadcasts.Segment) string {
+	var parts []string
+	if len(seg.Roles) > 0 {
+		labels := make([]string, 0, len(seg.Roles))
+		for _, r := range seg.Roles {
+			labels = append(labels, roleLabel(users.Role(r)))
+		}
+		parts = append(parts, "роли: "+strings.Join(labels, ", "))
+	}
+	if seg.Place != "" {
+		parts = append(parts, "помещение: "+placeLabel(seg.Place))
+	}
+	if seg.WarehouseID != 0 {
+		parts = append(parts, "склад: "+b.warehouseName(ctx, seg.WarehouseID))
+	}
+	if seg.Subs != broadcasts.SubsAny {
+		parts = append(parts, "абонемент: "+broadcastSubsLabel(seg.Subs))
+	}
+	if len(parts) == 0 {
+		return "все подтверждённые пользователи"
+	}
+	return strings.Join(parts, " · ")
+}
+
+// warehouseName — название склада по id.
+func (b *Bot) warehouseName(ctx context.Context, id int64) string {
+	if w, _ := b.catalog.GetWarehouseByID(ctx, id); w != nil {
+		return w.Name
+	}
+	return fmt.Sprintf("#%d", id)
+}
+
+func broadcastStatusLabel(s broadcasts.Status) string {
+	switch s {
+	case broadcasts.StatusScheduled:
+		return "🕒 запланирована"
+	case broadcasts.StatusSending:
+		return "📤 отправляется"
+	case broadcasts.StatusDone:
+		return "✅ отправлена"
+	case broadcasts.StatusCanceled:
+		return "⛔ отменена"
+	default:
+		return string(s)
+	}
+}
+
+func broadcastMediaLabel(m broadcasts.MediaType) string {
+	switch m {
+	case broadcasts.MediaPhoto:
+		return "фото"
+	case broadcasts.MediaDocument:
+		return "документ"
+	default:
+		return ""
+	}
+}
+
+// broadcastExcerpt — начало текста рассылки для списков.
+func broadcastExcerpt(bc broadcasts.Broadcast, n int) string {
+	text := strings.Join(strings.Fields(bc.Text), " ")
+	if text == "" && bc.MediaType != broadcasts.MediaNone {
+		return "[" + broadcastMediaLabel(bc.MediaType) + "]"
+	}
+	if utf8.RuneCountInString(text) > n {
+		text = string([]rune(text)[:n]) + "…"
+	}
+	return text
+}
+
+// broadcastMessage — сообщение рассылки для получателя.
+func broadcastMessage(chatID int64, text string, media broadcasts.MediaType, fileID string) tgbotapi.Chattable {
+	switch media {
+	case broadcasts.MediaPhoto:
+		m := tgbotapi.NewPhoto(chatID, tgbotapi.FileID(fileID))
+		m.Caption = text
+		return m
+	case broadcasts.MediaDocument:
+		m := tgbotapi.NewDocument(chatID, tgbotapi.FileID(fileID))
+		m.Caption = text
+		return m
+	default:
+		return tgbotapi.NewMessage(chatID, text)
+	}
+}
+
+// showBroadcastMenu — меню рассылок.
+func (b *Bot) showBroadcastMenu(ctx context.Context, chatID int64, editMsgID *int) {
+	_ = b.states.Set(ctx, chatID, dialog.StateAdmBroadcastMenu, dialog.Payload{})
+	text := "Рассылки\n\nСоздайте сообщение (текст, фото или документ), выберите получателей и отправьте сразу или в нужное время. По каждой рассылке видно, кому она доставлена."
+	kb := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("➕ Новая рассылка", "adm:bc:new")),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📋 Отправленные и запланированные", "adm:bc:list")),
+		navKeyboard(false, true).InlineKeyboard[0],
+	)
+	if editMsgID != nil {
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, *editMsgID, text, kb))
+		return
+	}
+	m := tgbotapi.NewMessage(chatID, text)
+	m.ReplyMarkup = kb
+	b.send(m)
+}
+
+// handleBroadcastContent принимает текст, фото или документ рассылки.
+func (b *Bot) handleBroadcastContent(ctx context.Context, msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	p := dialog.Payload{}
+	text := strings.TrimSpace(msg.Caption)
+	limit := broadcastCaptionLimit
+	switch {
+	case len(msg.Photo) > 0:
+		p["media_type"] = string(broadcasts.MediaPhoto)
+		p["media_file_id"] = msg.Photo[len(msg.Photo)-1].FileID
+	case msg.Document != nil:
+		p["media_type"] = string(broadcasts.MediaDocument)
+		p["media_file_id"] = msg.Document.FileID
+	default:
+		text = strings.TrimSpace(msg.Text)
+		limit = broadcastTextLimit
+		if text == "" {
+			b.send(tgbotapi.NewMessage(chatID, "Отправьте текст, фото или документ. Текст рассылки не может быть пустым."))
+			return
+		}
+	}
+	if n := utf8.RuneCountInString(text); n > limit {
+		b.send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Слишком длинный текст: %d символов, допустимо %d.", n, limit)))
+		return
+	}
+	p["text"] = text
+
+	_ = b.states.Set(ctx, chatID, dialog.StateAdmBroadcastSegment, p)
+	b.showBroadcastSegment(ctx, chatID, nil, p)
+}
+
+// showBroadcastSegment — выбор получателей черновика рассылки.
+func (b *Bot) showBroadcastSegment(ctx context.Context, chatID int64, editMsgID *int, p dialog.Payload) {
+	seg := broadcastSegment(p)
+	count := 0
+	if list, err := b.broadcasts.Recipients(ctx, seg, b.now().Format("2006-01")); err != nil {
+		b.log.Error("broadcast recipients failed", "err", err)
+	} else {
+		count = len(list)
+	}
+
+	text := fmt.Sprintf("Получатели рассылки\n\nОтбор: %s\nПолучателей сейчас: %d\n\nРоли, помещение и склад берутся из анкет пользователей, абонемент — на текущий месяц.",
+		b.broadcastSegmentText(ctx, seg), count)
+
+	selected := map[string]bool{}
+	for _, r := range seg.Roles {
+		selected[r] = true
+	}
+	var roleRow []tgbotapi.InlineKeyboardButton
+	for _, r := range users.AllRoles {
+		roleRow = append(roleRow, tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("%s %s", badge(selected[string(r)]), roleLabel(r)), "adm:bc:role:"+string(r)))
+	}
+
+	placeLabelText := "Все помещения"
+	if seg.Place != "" {
+		placeLabelText = placeLabel(seg.Place)
+	}
+	whLabel := "Все склады"
+	if seg.WarehouseID != 0 {
+		whLabel = "Склад: " + b.warehouseName(ctx, seg.WarehouseID)
+	}
+
+	kb := tgbotapi.NewInlineKeyboardMarkup(
+		roleRow,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🏠 "+placeLabelText, "adm:bc:place"),
+			tgbotapi.NewInlineKeyboardButtonData("📦 "+whLabel, "adm:bc:wh"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🎫 Абонемент: "+broadcastSubsLabel(seg.Subs), "adm:bc:subs"),
+		),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("👁 Предпросмотр", "adm:bc:preview")),
+		navKeyboard(false, true).InlineKeyboard[0],
+	)
+	if editMsgID != nil {
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, *editMsgID, text, kb))
+		return
+	}
+	m := tgbotapi.NewMessage(chatID, text)
+	m.ReplyMarkup = kb
+	b.send(m)
+}
+
+// showBroadcastWarehousePicker — выбор склада для отбора получателей.
+func (b *Bot) showBroadcastWarehousePicker(ctx context.Context, chatID int64, msgID int) {
+	list, err := b.catalog.ListWarehouses(ctx)
+	if err != nil {
+		b.log.Error("list warehouses failed", "err", err)
+	}
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("Все склады", "adm:bc:setwh:0")),
+	}
+	for _, w := range list {
+		if !w.Active {
+			continue
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(w.Name, fmt.Sprintf("adm:bc:setwh:%d", w.ID))))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", "adm:bc:seg")))
+	b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, msgID,
+		"Склад по умолчанию в анкете получателя:", tgbotapi.NewInlineKeyboardMarkup(rows...)))
+}
+
+// sendBroadcastPreview показывает рассылку так, как её увидят получатели, и кнопки отправки.
+func (b *Bot) sendBroadcastPreview(ctx context.Context, chatID int64, p dialog.Payload) {
+	seg := broadcastSegment(p)
+	list, err := b.broadcasts.Recipients(ctx, seg, b.now().Format("2006-01"))
+	if err != nil {
+		b.log.Error("broadcast recipients failed", "err", err)
+		b.send(tgbotapi.NewMessage(chatID, "Не удалось подобрать получателей, попробуйте позже."))
+		return
+	}
+
+	b.send(broadcastMessage(chatID, payloadString(p, "text"),
+		broadcasts.MediaType(payloadString(p, "media_type")), payloadString(p, "media_file_id")))
+
+	text := fmt.Sprintf("☝️ Так рассылку увидят получатели.\n\nОтбор: %s\nПолучателей: %d",
+		b.broadcastSegmentText(ctx, seg), len(list))
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	if len(list) > 0 {
+		rows = append(rows,
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🚀 Отправить сейчас", "adm:bc:send")),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🕐 Через час", "adm:bc:at:1h"),
+				tgbotapi.NewInlineKeyboardButtonData("🌅 Завтра в 10:00", "adm:bc:at:tomorrow"),
+			),
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🗓 Указать дату и время", "adm:bc:at:custom")),
+		)
+	} else {
+		text += "\n\nПод выбранный отбор никто не подходит — измените получателей."
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("✏️ Изменить получателей", "adm:bc:seg")),
+		navKeyboard(false, true).InlineKeyboard[0],
+	)
+	m := tgbotapi.NewMessage(chatID, text)
+	m.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	b.send(m)
+}
+
+// handleBroadcastScheduleInput — дата и время отправки, введённые текстом.
+func (b *Bot) handleBroadcastScheduleInput(ctx context.Context, msg *tgbotapi.Message, st *dialog.Item) {
+	chatID := msg.Chat.ID
+	at, err := time.ParseInLocation(broadcastScheduleLayout, strings.TrimSpace(msg.Text), b.loc)
+	if err != nil {
+		b.send(tgbotapi.NewMessage(chatID, "Не удалось распознать дату. Формат: ДД.ММ.ГГГГ ЧЧ:ММ, например 25.12.2026 10:00."))
+		return
+	}
+	if !at.After(b.now()) {
+		b.send(tgbotapi.NewMessage(chatID, "Это время уже прошло. Укажите время в будущем."))
+		return
+	}
+	b.createBroadcast(ctx, chatID, nil, st.Payload, at)
+}
+
+// createBroadcast ставит черновик в очередь на время at.
+func (b *Bot) createBroadcast(ctx context.Context, chatID int64, editMsgID *int, p dialog.Payload, at time.Time) {
+	u := b.currentUser(ctx)
+	if u == nil {
+		return
+	}
+	bc := &broadcasts.Broadcast{
+		AuthorID:    u.ID,
+		Text:        payloadString(p, "text"),
+		MediaType:   broadcasts.MediaType(payloadString(p, "media_type")),
+		MediaFileID: payloadString(p, "media_file_id"),
+		Segment:     broadcastSegment(p),
+		ScheduledAt: at,
+	}
+	id, err := b.broadcasts.Create(ctx, bc)
+	if err != nil {
+		b.log.Error("create broadcast failed", "err", err)
+		b.send(tgbotapi.NewMessage(chatID, "Не удалось сохранить рассылку, попробуйте позже."))
+		return
+	}
+	_ = b.states.Reset(ctx, chatID)
+
+	text := fmt.Sprintf("Рассылка №%d поставлена в очередь. По завершении придёт отчёт о доставке.", id)
+	if at.After(b.now().Add(broadcastQueueTick)) {
+		text = fmt.Sprintf("Рассылка №%d запланирована на %s. Отменить её можно в разделе «Рассылки».",
+			id, at.In(b.loc).Format(broadcastScheduleLayout))
+	} else {
+		b.wakeBroadcastQueue()
+	}
+	kb := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("📋 Открыть рассылку", fmt.Sprintf("adm:bc:view:%d", id))))
+	if editMsgID != nil {
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, *editMsgID, text, kb))
+		return
+	}
+	m := tgbotapi.NewMessage(chatID, text)
+	m.ReplyMarkup = kb
+	b.send(m)
+}
+
+// showBroadcastList — последние рассылки со статусами доставки.
+func (b *Bot) showBroadcastList(ctx context.Context, chatID int64, msgID int) {
+	list, err := b.broadcasts.ListRecent(ctx, broadcastListLimit)
+	if err != nil {
+		b.log.Error("list broadcasts failed", "err", err)
+		b.editTextAndClear(chatID, msgID, "Не удалось загрузить рассылки.")
+		return
+	}
+	text := "Рассылки (последние):"
+	if len(list) == 0 {
+		text = "Рассылок ещё не было."
+	}
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, bc := range list {
+		label := fmt.Sprintf("№%d %s · %s · %s", bc.ID, strings.SplitN(broadcastStatusLabel(bc.Status), " ", 2)[0],
+			bc.ScheduledAt.In(b.loc).Format("02.01 15:04"), broadcastExcerpt(bc, 24))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("adm:bc:view:%d", bc.ID))))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", "adm:bc:menu")))
+	b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, msgID, text, tgbotapi.NewInlineKeyboardMarkup(rows...)))
+}
+
+// broadcastStatsText — счётчики доставки.
+func broadcastStatsText(s broadcasts.Stats) string {
+	return fmt.Sprintf("Получателей: %d\nДоставлено: %d\nЗаблокировали бота: %d\nОшибки: %d\nВ очереди: %d",
+		s.Total, s.Sent, s.Blocked, s.Failed, s.Pending)
+}
+
+// showBroadcastDetails — карточка рассылки.
+func (b *Bot) showBroadcastDetails(ctx context.Context, chatID int64, msgID int, id int64) {
+	bc, err := b.broadcasts.Get(ctx, id)
+	if err != nil || bc == nil {
+		b.editTextAndClear(chatID, msgID, "Рассылка не найдена.")
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Рассылка №%d — %s\n\n", bc.ID, broadcastStatusLabel(bc.Status)))
+	sb.WriteString(fmt.Sprintf("Автор: %s\n", b.userNameByID(ctx, bc.AuthorID)))
+	sb.WriteString(fmt.Sprintf("Отправка: %s\n", bc.ScheduledAt.In(b.loc).Format(broadcastScheduleLayout)))
+	if bc.FinishedAt != nil && bc.Status == broadcasts.StatusDone {
+		sb.WriteString(fmt.Sprintf("Завершена: %s\n", bc.FinishedAt.In(b.loc).Format(broadcastScheduleLayout)))
+	}
+	sb.WriteString(fmt.Sprintf("Отбор: %s\n", b.broadcastSegmentText(ctx, bc.Segment)))
+	if bc.MediaType != broadcasts.MediaNone {
+		sb.WriteString(fmt.Sprintf("Вложение: %s\n", broadcastMediaLabel(bc.MediaType)))
+	}
+	sb.WriteString("\n" + broadcastExcerpt(*bc, 300) + "\n\n")
+	if bc.Status == broadcasts.StatusScheduled {
+		sb.WriteString("Получатели будут определены в момент отправки.")
+	} else {
+		sb.WriteString(broadcastStatsText(bc.Stats))
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if bc.Status == broadcasts.StatusScheduled {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⛔ Отменить рассылку", fmt.Sprintf("adm:bc:cancel:%d", bc.ID))))
+	}
+	if bc.Stats.Blocked+bc.Stats.Failed > 0 {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⚠️ Кому не доставлено", fmt.Sprintf("adm:bc:fail:%d", bc.ID))))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔄 Обновить", fmt.Sprintf("adm:bc:view:%d", bc.ID)),
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ К списку", "adm:bc:list"),
+	))
+	b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, msgID, sb.String(), tgbotapi.NewInlineKeyboardMarkup(rows...)))
+}
+
+// userNameByID — ФИО пользователя по внутреннему id.
+func (b *Bot) userNameByID(ctx context.Context, id int64) string {
+	u, err := b.users.GetByID(ctx, id)
+	if err != nil || u == nil {
+		return fmt.Sprintf("id %d", id)
+	}
+	return b.userFullName(ctx, u)
+}
+
+// showBroadcastUndelivered — получатели, которым рассылка не доставлена.
+func (b *Bot) showBroadcastUndelivered(ctx context.Context, chatID int64, msgID int, id int64) {
+	list, err := b.broadcasts.ListUndelivered(ctx, id)
+	if err != nil {
+		b.log.Error("list undelivered failed", "broadcast", id, "err", err)
+		b.editTextAndClear(chatID, msgID, "Не удалось загрузить список.")
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Рассылка №%d — не доставлено (%d):\n", id, len(list)))
+	for i, d := range list {
+		if sb.Len() > 3500 {
+			sb.WriteString(fmt.Sprintf("\n…и ещё %d", len(list)-i))
+			break
+		}
+		reason := "заблокировал бота"
+		if d.Status == broadcasts.DeliveryFailed {
+			reason = "ошибка: " + d.Error
+		}
+		sb.WriteString(fmt.Sprintf("\n• %s (tg %d) — %s", orDash(d.Name), d.TelegramID, reason))
+	}
+	kb := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", fmt.Sprintf("adm:bc:view:%d", id))))
+	b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, msgID, sb.String(), kb))
+}
+
+// handleBroadcastCallback — кнопки рассылок (data без префикса "adm:bc:").
+func (b *Bot) handleBroadcastCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, data string) {
+	chatID := cb.Message.Chat.ID
+	msgID := cb.Message.MessageID
+
+	switch {
+	case data == "menu":
+		_ = b.answerCallback(cb, "", false)
+		b.showBroadcastMenu(ctx, chatID, &msgID)
+		return
+
+	case data == "new":
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmBroadcastContent, dialog.Payload{})
+		_ = b.answerCallback(cb, "", false)
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, msgID,
+			"Отправьте сообщение для рассылки: текст, фото или документ (текст — в подписи).",
+			navKeyboard(false, true)))
+		return
+
+	case data == "list":
+		_ = b.answerCallback(cb, "", false)
+		b.showBroadcastList(ctx, chatID, msgID)
+		return
+
+	case strings.HasPrefix(data, "view:"):
+		id, _ := strconv.ParseInt(strings.TrimPrefix(data, "view:"), 10, 64)
+		_ = b.answerCallback(cb, "", false)
+		b.showBroadcastDetails(ctx, chatID, msgID, id)
+		return
+
+	case strings.HasPrefix(data, "fail:"):
+		id, _ := strconv.ParseInt(strings.TrimPrefix(data, "fail:"), 10, 64)
+		_ = b.answerCallback(cb, "", false)
+		b.showBroadcastUndelivered(ctx, chatID, msgID, id)
+		return
+
+	case strings.HasPrefix(data, "cancel:"):
+		id, _ := strconv.ParseInt(strings.TrimPrefix(data, "cancel:"), 10, 64)
+		ok, err := b.broadcasts.Cancel(ctx, id)
+		switch {
+		case err != nil:
+			b.log.Error("cancel broadcast failed", "broadcast", id, "err", err)
+			_ = b.answerCallback(cb, "Ошибка", true)
+		case !ok:
+			_ = b.answerCallback(cb, "Рассылка уже отправляется или завершена", true)
+		default:
+			_ = b.answerCallback(cb, "Рассылка отменена", false)
+		}
+		b.showBroadcastDetails(ctx, chatID, msgID, id)
+		return
+	}
+
+	// дальше — шаги черновика рассылки
+	st, _ := b.states.Get(ctx, chatID)
+	if st == nil || (st.State != dialog.StateAdmBroadcastSegment && st.State != dialog.StateAdmBroadcastSchedule) {
+		_ = b.answerCallback(cb, "Черновик рассылки устарел, начните заново", true)
+		return
+	}
+	p := st.Payload
+	if p == nil {
+		p = dialog.Payload{}
+	}
+
+	switch {
+	case data == "seg":
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmBroadcastSegment, p)
+		_ = b.answerCallback(cb, "", false)
+		b.showBroadcastSegment(ctx, chatID, &msgID, p)
+
+	case strings.HasPrefix(data, "role:"):
+		role := strings.TrimPrefix(data, "role:")
+		roles := payloadStrings(p, "roles")
+		next := make([]string, 0, len(roles)+1)
+		found := false
+		for _, r := range roles {
+			if r == role {
+				found = true
+				continue
+			}
+			next = append(next, r)
+		}
+		if !found {
+			next = append(next, role)
+		}
+		p["roles"] = next
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmBroadcastSegment, p)
+		_ = b.answerCallback(cb, "", false)
+		b.showBroadcastSegment(ctx, chatID, &msgID, p)
+
+	case data == "place":
+		// по кругу: все → зал → кабинет
+		switch payloadString(p, "place") {
+		case "":
+			p["place"] = "hall"
+		case "hall":
+			p["place"] = "cabinet"
+		default:
+			p["place"] = ""
+		}
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmBroadcastSegment, p)
+		_ = b.answerCallback(cb, "", false)
+		b.showBroadcastSegment(ctx, chatID, &msgID, p)
+
+	case data == "subs":
+		switch payloadString(p, "subs") {
+		case broadcasts.SubsAny:
+			p["subs"] = broadcasts.SubsActive
+		case broadcasts.SubsActive:
+			p["subs"] = broadcasts.SubsNone
+		default:
+			p["subs"] = broadcasts.SubsAny
+		}
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmBroadcastSegment, p)
+		_ = b.answerCallback(cb, "", false)
+		b.showBroadcastSegment(ctx, chatID, &msgID, p)
+
+	case data == "wh":
+		_ = b.answerCallback(cb, "", false)
+		b.showBroadcastWarehousePicker(ctx, chatID, msgID)
+
+	case strings.HasPrefix(data, "setwh:"):
+		id, _ := strconv.ParseInt(strings.TrimPrefix(data, "setwh:"), 10, 64)
+		p["wh_id"] = id
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmBroadcastSegment, p)
+		_ = b.answerCallback(cb, "", false)
+		b.showBroadcastSegment(ctx, chatID, &msgID, p)
+
+	case data == "preview":
+		_ = b.answerCallback(cb, "", false)
+		b.send(tgbotapi.NewEditMessageReplyMarkup(chatID, msgID,
+			tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}))
+		b.sendBroadcastPreview(ctx, chatID, p)
+
+	case data == "send":
+		_ = b.answerCallback(cb, "Отправляем", false)
+		b.createBroadcast(ctx, chatID, &msgID, p, b.now())
+
+	case data == "at:1h":
+		_ = b.answerCallback(cb, "", false)
+		b.createBroadcast(ctx, chatID, &msgID, p, b.now().Add(time.Hour).Truncate(time.Minute))
+
+	case data == "at:tomorrow":
+		now := b.now()
+		at := time.Date(now.Year(), now.Month(), now.Day()+1, 10, 0, 0, 0, b.loc)
+		_ = b.answerCallback(cb, "", false)
+		b.createBroadcast(ctx, chatID, &msgID, p, at)
+
+	case data == "at:custom":
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmBroadcastSchedule, p)
+		_ = b.answerCallback(cb, "", false)
+		kb := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", "adm:bc:seg")),
+			navKeyboard(false, true).InlineKeyboard[0],
+		)
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, msgID,
+			"Введите дату и время отправки (часовой пояс салона) в формате ДД.ММ.ГГГГ ЧЧ:ММ, например 25.12.2026 10:00.", kb))
+
+	default:
+		_ = b.answerCallback(cb, "Неизвестная команда", true)
+	}
+}
+
+// wakeBroadcastQueue будит очередь рассылок, не дожидаясь очередной проверки.
+func (b *Bot) wakeBroadcastQueue() {
+	select {
+	case b.broadcastWake <- struct{}{}:
+	default:
+	}
+}
+
+// RunBroadcastQueue отправляет рассылки, время которых наступило, по одной и с ограничением скорости.
+// Прерванная перезапуском рассылка продолжается с неотправленных получателей.
+func (b *Bot) RunBroadcastQueue(ctx context.Context) {
+	t := time.NewTicker(broadcastQueueTick)
+	defer t.Stop()
+	for {
+		b.runBroadcasts(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		case <-b.broadcastWake:
+		}
+	}
+}
+
+func (b *Bot) runBroadcasts(ctx context.Context) {
+	list, err := b.broadcasts.ListRunnable(ctx)
+	if err != nil {
+		b.log.Error("list runnable broadcasts failed", "err", err)
+		return
+	}
+	for i := range list {
+		bc := &list[i]
+		if bc.Status == broadcasts.StatusScheduled {
+			n, err := b.broadcasts.Start(ctx, bc, b.now().Format("2006-01"))
+			if err != nil {
+				b.log.Error("start broadcast failed", "broadcast", bc.ID, "err", err)
+				continue
+			}
+			b.log.Info("broadcast started", "broadcast", bc.ID, "recipients", n)
+		}
+		if err := b.deliverBroadcast(ctx, bc); err != nil {
+			if ctx.Err() == nil {
+				b.log.Error("deliver broadcast failed", "broadcast", bc.ID, "err", err)
+			}
+			return
+		}
+		if err := b.broadcasts.Finish(ctx, bc.ID); err != nil {
+			b.log.Error("finish broadcast failed", "broadcast", bc.ID, "err", err)
+			continue
+		}
+		b.notifyBroadcastDone(ctx, bc.ID)
+	}
+}
+
+// deliverBroadcast отправляет рассылку всем получателям, которым она ещё не отправлена.
+func (b *Bot) deliverBroadcast(ctx context.Context, bc *broadcasts.Broadcast) error {
+	pause := time.NewTicker(broadcastSendInterval)
+	defer pause.Stop()
+	for {
+		batch, err := b.broadcasts.PendingDeliveries(ctx, bc.ID, broadcastBatch)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		for _, rc := range batch {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-pause.C:
+			}
+			status, errText := b.sendBroadcastTo(ctx, bc, rc.TelegramID)
+			if err := b.broadcasts.MarkDelivery(ctx, bc.ID, rc.UserID, status, errText); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// sendBroadcastTo отправляет сообщение одному получателю; при 429 ждёт, сколько просит Telegram, и повторяет.
+func (b *Bot) sendBroadcastTo(ctx context.Context, bc *broadcasts.Broadcast, chatID int64) (broadcasts.DeliveryStatus, string) {
+	for attempt := 0; ; attempt++ {
+		_, err := b.api.Send(broadcastMessage(chatID, bc.Text, bc.MediaType, bc.MediaFileID))
+		if err == nil {
+			return broadcasts.DeliverySent, ""
+		}
+		var tgErr *tgbotapi.Error
+		if errors.As(err, &tgErr) {
+			switch {
+			case tgErr.Code == 403:
+				return broadcasts.DeliveryBlocked, tgErr.Message
+			case tgErr.Code == 429 && attempt < 3:
+				wait := time.Duration(max(tgErr.RetryAfter, 1)) * time.Second
+				select {
+				case <-ctx.Done():
+					return broadcasts.DeliveryFailed, ctx.Err().Error()
+				case <-time.After(wait):
+				}
+				continue
+			}
+		}
+		return broadcasts.DeliveryFailed, err.Error()
+	}
+}
+
+// notifyBroadcastDone — отчёт о доставке автору рассылки.
+func (b *Bot) notifyBroadcastDone(ctx context.Context, id int64) {
+	bc, err := b.broadcasts.Get(ctx, id)
+	if err != nil || bc == nil || bc.Status != broadcasts.StatusDone {
+		return
+	}
+	author, err := b.users.GetByID(ctx, bc.AuthorID)
+	if err != nil || author == nil || author.TelegramID == 0 {
+		return
+	}
+	m := tgbotapi.NewMessage(author.TelegramID,
+		fmt.Sprintf("Рассылка №%d отправлена.\n\n%s", bc.ID, broadcastStatsText(bc.Stats)))
+	m.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("📋 Открыть рассылку", fmt.Sprintf("adm:bc:view:%d", bc.ID))))
+	b.send(m)
+}
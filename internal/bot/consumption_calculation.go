@@ -3,9 +3,12 @@ package bot
 import (
 	"context"
 	"fmt"
+	"math"
 
 	"github.com/Spok95/beauty-bot/internal/dialog"
 	"github.com/Spok95/beauty-bot/internal/domain/consumption"
+	"github.com/Spok95/beauty-bot/internal/domain/discounts"
+	"github.com/Spok95/beauty-bot/internal/domain/users"
 )
 
 func (b *Bot) calculateConsumptionReceiptPayload(
@@ -43,11 +46,18 @@ func (b *Bot) calculateConsumptionReceiptPayload(
 
 	var mats float64
 	for _, it := range items {
-		matID := int64(it["mat_id"].(float64))
-		q := int64(it["qty"].(float64))
-		price, _ := b.materials.GetPrice(ctx, matID)
-		mats += float64(q) * price
+		q, _ := it["qty"].(float64)
+		price, err := b.consItemPrice(ctx, it)
+		if err != nil {
+			return nil, "Не удалось загрузить цены материалов. Попробуйте ещё раз.", fmt.Errorf("material price: %w", err)
+		}
+		mats += q * price
 	}
+	// сумму материалов округляем до копеек один раз: из неё строятся и строка чека,
+	// и скидки на материалы, и итог — иначе строки чека не сходятся с итогом
+	mats = math.Round(mats*100) / 100
+
+	u, _ := b.users.GetByTelegramID(ctx, telegramID)
 
 	if noRent || studioClient {
 		rent := float64(0)
@@ -63,12 +73,13 @@ func (b *Bot) calculateConsumptionReceiptPayload(
 		payload["rent"] = rent
 		payload["total"] = mats + rent
 		payload["rent_parts"] = []map[string]any{}
+		delete(payload, "tariff_version_id")
+		// сбор за студийного клиента — не аренда, скидки действуют только на материалы
+		b.applyConsumptionDiscounts(ctx, u, payload, 0, mats)
 
 		return payload, "", nil
 	}
 
-	u, _ := b.users.GetByTelegramID(ctx, telegramID)
-
 	var metas []rentPartMeta
 	if u != nil {
 		metas, _ = b.splitQtyBySubscriptions(ctx, u.ID, place, unit, qty)
@@ -107,7 +118,7 @@ func (b *Bot) calculateConsumptionReceiptPayload(
 		parts = append(parts, p)
 	}
 
-	calcRent, rounded, needTotal, partResults, err := b.cons.ComputeRentSplit(ctx, place, unit, mats, parts)
+	calcRent, rounded, needTotal, partResults, err := b.cons.ComputeRentSplit(ctx, b.now(), place, unit, mats, parts)
 	if err != nil || len(partResults) == 0 {
 		text := fmt.Sprintf(
 			"⚠️ Нет активных тарифов для: %s / %s (%s). Настройте тарифы.",
@@ -134,6 +145,9 @@ func (b *Bot) calculateConsumptionReceiptPayload(
 	payload["rent_calc"] = calcRent
 	payload["rent"] = rentToPay
 	payload["total"] = total
+	if rate := partResults[0].Rate; rate != nil {
+		payload["tariff_version_id"] = rate.VersionID
+	}
 
 	partsPayload := make([]map[string]any, 0, len(partResults))
 	for i, pr := range partResults {
@@ -158,6 +172,49 @@ func (b *Bot) calculateConsumptionReceiptPayload(
 	}
 
 	payload["rent_parts"] = partsPayload
+	b.applyConsumptionDiscounts(ctx, u, payload, rentToPay, mats)
 
 	return payload, "", nil
 }
+
+// applyConsumptionDiscounts подбирает скидки к расчёту (аренда к оплате и материалы),
+// кладёт их в payload["discounts"] и уменьшает payload["total"].
+func (b *Bot) applyConsumptionDiscounts(ctx context.Context, u *users.User, payload dialog.Payload, rent, mats float64) {
+	delete(payload, "discounts")
+	payload["discount_total"] = float64(0)
+	if b.discounts == nil || u == nil {
+		return
+	}
+
+	list, err := b.discounts.ListActive(ctx)
+	if err != nil {
+		b.log.Warn("load discounts failed", "err", err)
+		return
+	}
+
+	now := b.now()
+	applied := discounts.Apply(list, discounts.Context{
+		UserID:     u.ID,
+		Place:      payloadString(payload, "place"),
+		At:         now,
+		PromoCode:  payloadString(payload, "promo_code"),
+		FirstMonth: u.ApprovedAt != nil && now.Before(u.ApprovedAt.AddDate(0, 1, 0)),
+	}, rent, mats)
+	if len(applied) == 0 {
+		return
+	}
+
+	out := make([]map[string]any, 0, len(applied))
+	for _, a := range applied {
+		out = append(out, map[string]any{
+			"id":     a.DiscountID,
+			"title":  a.Title,
+			"target": string(a.Target),
+			"amount": a.Amount,
+		})
+	}
+	total := discounts.Total(applied)
+	payload["discounts"] = out
+	payload["discount_total"] = total
+	payload["total"] = payloadFloat(payload, "total") - total
+}
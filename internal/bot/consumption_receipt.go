@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/Spok95/beauty-bot/internal/dialog"
+	"github.com/Spok95/beauty-bot/internal/domain/discounts"
 )
 
 func (b *Bot) buildConsumptionReceipt(ctx context.Context, payload dialog.Payload, title string) string {
@@ -72,6 +73,11 @@ func (b *Bot) buildConsumptionReceipt(ctx context.Context, payload dialog.Payloa
 	}
 
 	lines = append(lines, "Параметры записи:")
+	if u := b.currentUser(ctx); u != nil {
+		if name := b.profileOf(ctx, u).ShortName(); name != "" {
+			lines = append(lines, fmt.Sprintf("• Мастер: %s", name))
+		}
+	}
 	if studioClient {
 		lines = append(lines, "• Тип: студийный клиент")
 	} else if noRent {
@@ -106,7 +112,7 @@ func (b *Bot) buildConsumptionReceipt(ctx context.Context, payload dialog.Payloa
 	} else {
 		for _, it := range items {
 			matID := int64(it["mat_id"].(float64))
-			q := int64(it["qty"].(float64))
+			q := it["qty"].(float64)
 
 			name := fmt.Sprintf("ID:%d", matID)
 			unitLabel := "ед."
@@ -116,11 +122,15 @@ func (b *Bot) buildConsumptionReceipt(ctx context.Context, payload dialog.Payloa
 				unitLabel = materialUnitLabel(string(m.Unit))
 			}
 
-			price, _ := b.materials.GetPrice(ctx, matID)
-			line := float64(q) * price
+			price, err := b.consItemPrice(ctx, it)
+			if err != nil {
+				lines = append(lines, fmt.Sprintf("• %s — %s %s × цена не загрузилась", name, formatQty(q), unitLabel))
+				continue
+			}
+			line := q * price
 
 			lines = append(lines,
-				fmt.Sprintf("• %s — %d %s × %.2f ₽ = %.2f ₽", name, q, unitLabel, price, line),
+				fmt.Sprintf("• %s — %s %s × %.2f ₽ = %.2f ₽", name, formatQty(q), unitLabel, price, line),
 			)
 		}
 	}
@@ -212,6 +222,9 @@ func (b *Bot) buildConsumptionReceipt(ctx context.Context, payload dialog.Payloa
 	} else {
 		lines = append(lines, fmt.Sprintf("• Аренда: %.2f ₽", rent))
 	}
+	for _, d := range parseConsumptionDiscounts(payload) {
+		lines = append(lines, fmt.Sprintf("• Скидка «%s» %s: −%.2f ₽", d.Title, discountTargetLabel(d.Target), d.Amount))
+	}
 	lines = append(lines, fmt.Sprintf("• Всего к оплате: %.2f ₽", total))
 
 	if warn := buildSubscriptionReceiptWarning(payload); warn != "" {
@@ -257,6 +270,12 @@ func materialUnitLabel(unit string) string {
 		return "мл"
 	case "pcs":
 		return "шт"
+	case "pack":
+		return "уп"
+	case "tube":
+		return "туба"
+	case "bottle":
+		return "фл"
 	default:
 		return unit
 	}
@@ -286,6 +305,28 @@ func parseRentParts(v any) []map[string]any {
 	return out
 }
 
+// parseConsumptionDiscounts — скидки, применённые к расчёту (payload["discounts"]).
+func parseConsumptionDiscounts(payload dialog.Payload) []discounts.Applied {
+	raw := parseRentParts(payload["discounts"])
+	out := make([]discounts.Applied, 0, len(raw))
+	for _, d := range raw {
+		out = append(out, discounts.Applied{
+			DiscountID: payloadInt64(d["id"]),
+			Title:      payloadString(d, "title"),
+			Target:     discounts.Target(payloadString(d, "target")),
+			Amount:     payloadFloat(d, "amount"),
+		})
+	}
+	return out
+}
+
+func discountTargetLabel(t discounts.Target) string {
+	if t == discounts.TargetMaterials {
+		return "на материалы"
+	}
+	return "на аренду"
+}
+
 func payloadString(payload map[string]any, key string) string {
 	v, _ := payload[key].(string)
 	return v
@@ -2,8 +2,12 @@ package bot
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/Spok95/beauty-bot/internal/dialog"
+	"github.com/Spok95/beauty-bot/internal/domain/discounts"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -50,6 +54,16 @@ func (b *Bot) showConsumptionReceiptForConfirm(
 		}
 	}
 
+	if payloadString(calculatedPayload, "promo_code") != "" {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ Убрать промокод", "cons:promo:clear"),
+		))
+	} else {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🎟 Ввести промокод", "cons:promo"),
+		))
+	}
+
 	rows = append(rows, navKeyboard(true, true).InlineKeyboard[0])
 
 	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
@@ -63,3 +77,43 @@ func (b *Bot) showConsumptionReceiptForConfirm(
 	msg.ReplyMarkup = kb
 	b.send(msg)
 }
+
+// handleConsPromoCode — промокод, введённый мастером в сводке расхода/аренды.
+func (b *Bot) handleConsPromoCode(ctx context.Context, chatID, telegramID int64, payload dialog.Payload, text string) {
+	code := strings.TrimSpace(text)
+	d, err := b.discounts.CheckPromoCode(ctx, code, b.now())
+	switch {
+	case errors.Is(err, discounts.ErrNotFound):
+		b.send(tgbotapi.NewMessage(chatID, "Такого промокода нет. Проверьте написание или нажмите «Назад»."))
+		return
+	case errors.Is(err, discounts.ErrPromoInactive):
+		b.send(tgbotapi.NewMessage(chatID, "Этот промокод сейчас не действует. Введите другой или нажмите «Назад»."))
+		return
+	case err != nil:
+		b.log.Error("check promo code failed", "err", err)
+		b.send(tgbotapi.NewMessage(chatID, "Не удалось проверить промокод, попробуйте позже."))
+		return
+	}
+
+	payload["promo_code"] = d.PromoCode
+	calculated, userError, err := b.calculateConsumptionReceiptPayload(ctx, telegramID, payload)
+	if err != nil {
+		b.send(tgbotapi.NewMessage(chatID, userError))
+		return
+	}
+
+	applied := false
+	for _, a := range parseConsumptionDiscounts(calculated) {
+		if a.DiscountID == d.ID {
+			applied = true
+			break
+		}
+	}
+	if !applied {
+		delete(payload, "promo_code")
+		b.send(tgbotapi.NewMessage(chatID, "Промокод действует, но не подходит к этому расчёту (другое помещение, время или мастер)."))
+	} else {
+		b.send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Промокод применён: «%s».", d.Title)))
+	}
+	b.showConsumptionReceiptForConfirm(ctx, chatID, 0, telegramID, payload)
+}
@@ -1,6 +1,7 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -29,11 +30,19 @@ func (b *Bot) showConsumptionRentModeStep(chatID int64, editMsgID *int) {
 	b.send(msg)
 }
 
-func (b *Bot) showConsumptionPlaceStep(chatID int64, editMsgID *int) {
+func (b *Bot) showConsumptionPlaceStep(ctx context.Context, chatID int64, editMsgID *int) {
+	// помещение по умолчанию из профиля мастера помечаем звёздочкой
+	hall, cabinet := "Общий зал", "Кабинет"
+	switch b.profileOf(ctx, b.currentUser(ctx)).DefaultPlace {
+	case "hall":
+		hall = "⭐ " + hall
+	case "cabinet":
+		cabinet = "⭐ " + cabinet
+	}
 	kb := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Общий зал", "cons:place:hall"),
-			tgbotapi.NewInlineKeyboardButtonData("Кабинет", "cons:place:cabinet"),
+			tgbotapi.NewInlineKeyboardButtonData(hall, "cons:place:hall"),
+			tgbotapi.NewInlineKeyboardButtonData(cabinet, "cons:place:cabinet"),
 		),
 		navKeyboard(false, true).InlineKeyboard[0],
 	)
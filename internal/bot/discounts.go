@@ -0,0 +1,416 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Spok95/beauty-bot/internal/dialog"
+	"github.com/Spok95/beauty-bot/internal/domain/audit"
+	"github.com/Spok95/beauty-bot/internal/domain/discounts"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/xuri/excelize/v2"
+)
+
+// discountValueLabel — «10%» или «500 ₽».
+func discountValueLabel(d discounts.Discount) string {
+	if d.Kind == discounts.KindPercent {
+		return strconv.FormatFloat(d.Value, 'f', -1, 64) + "%"
+	}
+	return fmt.Sprintf("%.0f ₽", d.Value)
+}
+
+// discountConditions — условия скидки одной строкой.
+func (b *Bot) discountConditions(ctx context.Context, d discounts.Discount) string {
+	var parts []string
+	if d.UserID != nil {
+		name := fmt.Sprintf("id %d", *d.UserID)
+		if u, err := b.users.GetByID(ctx, *d.UserID); err == nil && u != nil {
+			name = b.userFullName(ctx, u)
+		}
+		parts = append(parts, "мастер "+name)
+	}
+	if d.Place != "" {
+		parts = append(parts, strings.ToLower(placeLabel(d.Place)))
+	}
+	if d.HourFrom != nil && d.HourTo != nil {
+		parts = append(parts, fmt.Sprintf("%02d:00–%02d:00", *d.HourFrom, *d.HourTo))
+	}
+	if d.PromoCode != "" {
+		parts = append(parts, "промокод "+d.PromoCode)
+	}
+	if d.FirstMonth {
+		parts = append(parts, "первый месяц")
+	}
+	if d.ValidFrom != nil {
+		parts = append(parts, "с "+d.ValidFrom.Format("02.01.2006"))
+	}
+	if d.ValidTo != nil {
+		parts = append(parts, "по "+d.ValidTo.Format("02.01.2006"))
+	}
+	if len(parts) == 0 {
+		return "для всех"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// showDiscountsMenu — список скидок: кнопка скидки включает/выключает её.
+func (b *Bot) showDiscountsMenu(ctx context.Context, chatID int64, editMsgID *int) {
+	list, err := b.discounts.List(ctx)
+	if err != nil {
+		b.send(tgbotapi.NewMessage(chatID, "Ошибка загрузки скидок"))
+		return
+	}
+
+	lines := []string{"Скидки и акции. Нажмите на скидку, чтобы включить или выключить её."}
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for _, d := range list {
+		lines = append(lines, fmt.Sprintf("%s #%d «%s»: %s %s (%s)",
+			badge(d.Active), d.ID, d.Title, discountValueLabel(d), discountTargetLabel(d.Target), b.discountConditions(ctx, d)))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("%s %s — %s", badge(d.Active), d.Title, discountValueLabel(d)),
+				fmt.Sprintf("price:disc:tg:%d", d.ID)),
+		))
+	}
+	if len(list) == 0 {
+		lines = append(lines, "", "Скидок пока нет. Выгрузите файл, заполните строки и загрузите его обратно.")
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬇️ Выгрузить скидки", "price:disc:export"),
+			tgbotapi.NewInlineKeyboardButtonData("⬆️ Загрузить скидки", "price:disc:import"),
+		),
+		navKeyboard(true, true).InlineKeyboard[0],
+	)
+
+	text := truncateRunes(strings.Join(lines, "\n"), 4000)
+	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	_ = b.states.Set(ctx, chatID, dialog.StatePriceDiscMenu, dialog.Payload{})
+	if editMsgID != nil {
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, *editMsgID, text, kb))
+		return
+	}
+	m := tgbotapi.NewMessage(chatID, text)
+	m.ReplyMarkup = kb
+	b.send(m)
+}
+
+var discountsExcelHeader = []interface{}{
+	"id",
+	"title",
+	"kind",
+	"target",
+	"value",
+	"master_id",
+	"master",
+	"place",
+	"hours",
+	"promo_code",
+	"first_month",
+	"valid_from",
+	"valid_to",
+	"active",
+}
+
+// exportDiscountsExcel выгружает скидки в Excel; пустой id в строке — новая скидка при загрузке.
+func (b *Bot) exportDiscountsExcel(ctx context.Context, chatID int64, msgID int) {
+	list, err := b.discounts.List(ctx)
+	if err != nil {
+		b.editTextAndClear(chatID, msgID, "Ошибка загрузки скидок")
+		return
+	}
+
+	f := excelize.NewFile()
+	defer func() { _ = f.Close() }()
+
+	sheet := f.GetSheetName(f.GetActiveSheetIndex())
+	if err := f.SetSheetRow(sheet, "A1", &discountsExcelHeader); err != nil {
+		b.editTextAndClear(chatID, msgID, "Ошибка формирования файла (заголовок)")
+		return
+	}
+
+	for i, d := range list {
+		var masterID, master, hours, validFrom, validTo any
+		if d.UserID != nil {
+			masterID = *d.UserID
+			if u, err := b.users.GetByID(ctx, *d.UserID); err == nil && u != nil {
+				master = b.userFullName(ctx, u)
+			}
+		}
+		if d.HourFrom != nil && d.HourTo != nil {
+			hours = fmt.Sprintf("%d-%d", *d.HourFrom, *d.HourTo)
+		}
+		if d.ValidFrom != nil {
+			validFrom = d.ValidFrom.Format("02.01.2006")
+		}
+		if d.ValidTo != nil {
+			validTo = d.ValidTo.Format("02.01.2006")
+		}
+		row := []interface{}{
+			d.ID,
+			d.Title,
+			string(d.Kind),
+			string(d.Target),
+			d.Value,
+			masterID,
+			master,
+			d.Place,
+			hours,
+			d.PromoCode,
+			map[bool]string{true: "yes", false: "no"}[d.FirstMonth],
+			validFrom,
+			validTo,
+			map[bool]string{true: "yes", false: "no"}[d.Active],
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			b.editTextAndClear(chatID, msgID, "Ошибка формирования файла (строки)")
+			return
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := f.Write(buf); err != nil {
+		b.editTextAndClear(chatID, msgID, "Ошибка записи файла")
+		return
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("discounts_%s.xlsx", b.now().Format("20060102_150405")),
+		Bytes: buf.Bytes(),
+	})
+	doc.Caption = "Скидки и акции. kind: percent|fixed; target: rent|materials; master_id — id мастера из админки; " +
+		"place: hall|cabinet; hours: 10-16 (время расчёта); first_month/active: yes|no; даты ДД.ММ.ГГГГ. " +
+		"Строка без id — новая скидка, пустые условия не ограничивают применение."
+	b.send(doc)
+	b.editTextWithNav(chatID, msgID, "Сформирован файл со скидками.")
+}
+
+func parseExcelBool(s string, def bool) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return def, nil
+	case "yes", "да", "1", "true":
+		return true, nil
+	case "no", "нет", "0", "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("ожидается yes или no")
+}
+
+// parseExcelDate разбирает дату из Excel как начало дня в часовом поясе салона (loc).
+func parseExcelDate(s string, loc *time.Location) (*time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{"02.01.2006", "2006-01-02", "01-02-06"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("ожидается дата ДД.ММ.ГГГГ")
+}
+
+// parseDiscountHours разбирает «10-16» в часы начала и окончания.
+func parseDiscountHours(s string) (from, to *int, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil, nil
+	}
+	a, c, ok := strings.Cut(strings.ReplaceAll(s, "–", "-"), "-")
+	if !ok {
+		return nil, nil, fmt.Errorf("ожидается диапазон часов, например 10-16")
+	}
+	h1, err1 := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(a, ":00")))
+	h2, err2 := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(c, ":00")))
+	if err1 != nil || err2 != nil || h1 < 0 || h1 > 23 || h2 < 0 || h2 > 24 || h1 == h2 {
+		return nil, nil, fmt.Errorf("ожидается диапазон часов, например 10-16")
+	}
+	return &h1, &h2, nil
+}
+
+// parseDiscountRow разбирает строку файла скидок.
+func (b *Bot) parseDiscountRow(ctx context.Context, row []string) (discounts.Discount, error) {
+	col := func(i int) string {
+		if i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var d discounts.Discount
+	if s := col(0); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return d, fmt.Errorf("некорректный id (%q)", s)
+		}
+		d.ID = id
+	}
+
+	d.Title = col(1)
+	if d.Title == "" {
+		return d, fmt.Errorf("не заполнено название (title)")
+	}
+
+	d.Kind = discounts.Kind(strings.ToLower(col(2)))
+	if d.Kind != discounts.KindPercent && d.Kind != discounts.KindFixed {
+		return d, fmt.Errorf("kind должен быть percent или fixed")
+	}
+	d.Target = discounts.Target(strings.ToLower(col(3)))
+	if d.Target != discounts.TargetRent && d.Target != discounts.TargetMaterials {
+		return d, fmt.Errorf("target должен быть rent или materials")
+	}
+
+	v, err := strconv.ParseFloat(strings.ReplaceAll(col(4), ",", "."), 64)
+	if err != nil || v <= 0 || (d.Kind == discounts.KindPercent && v > 100) {
+		return d, fmt.Errorf("некорректное значение скидки (%q)", col(4))
+	}
+	d.Value = v
+
+	if s := col(5); s != "" {
+		uid, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return d, fmt.Errorf("некорректный master_id (%q)", s)
+		}
+		if u, err := b.users.GetByID(ctx, uid); err != nil || u == nil {
+			return d, fmt.Errorf("мастер с id %d не найден", uid)
+		}
+		d.UserID = &uid
+	}
+
+	d.Place = strings.ToLower(col(7))
+	if d.Place != "" && d.Place != "hall" && d.Place != "cabinet" {
+		return d, fmt.Errorf("place должен быть hall, cabinet или пустым")
+	}
+
+	if d.HourFrom, d.HourTo, err = parseDiscountHours(col(8)); err != nil {
+		return d, err
+	}
+	d.PromoCode = col(9)
+
+	if d.FirstMonth, err = parseExcelBool(col(10), false); err != nil {
+		return d, fmt.Errorf("first_month: %w", err)
+	}
+	if d.ValidFrom, err = parseExcelDate(col(11), b.loc); err != nil {
+		return d, fmt.Errorf("valid_from: %w", err)
+	}
+	if d.ValidTo, err = parseExcelDate(col(12), b.loc); err != nil {
+		return d, fmt.Errorf("valid_to: %w", err)
+	}
+	if d.ValidFrom != nil && d.ValidTo != nil && d.ValidTo.Before(*d.ValidFrom) {
+		return d, fmt.Errorf("valid_to раньше valid_from")
+	}
+	if d.Active, err = parseExcelBool(col(13), true); err != nil {
+		return d, fmt.Errorf("active: %w", err)
+	}
+	return d, nil
+}
+
+// handlePriceDiscImportExcel читает файл скидок: строки с id обновляют скидки, без id — создают новые.
+// Файл сначала проверяется целиком; при ошибке ничего не сохраняется.
+func (b *Bot) handlePriceDiscImportExcel(ctx context.Context, chatID int64, data []byte) {
+	ctx = audit.WithSource(ctx, audit.SourceExcel)
+
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		b.send(tgbotapi.NewMessage(chatID, "Не удалось прочитать Excel-файл (повреждён или не .xlsx)."))
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	rows, err := f.GetRows(f.GetSheetName(f.GetActiveSheetIndex()))
+	if err != nil || len(rows) < 2 {
+		b.send(tgbotapi.NewMessage(chatID, "Файл не содержит данных (нет строк со скидками)."))
+		return
+	}
+	if len(rows[0]) < len(discountsExcelHeader) {
+		b.send(tgbotapi.NewMessage(chatID,
+			fmt.Sprintf("Некорректный формат файла: ожидается %d колонок (id ... active).", len(discountsExcelHeader))))
+		return
+	}
+
+	var list []discounts.Discount
+	for i := 1; i < len(rows); i++ {
+		if strings.TrimSpace(strings.Join(rows[i], "")) == "" {
+			continue
+		}
+		d, err := b.parseDiscountRow(ctx, rows[i])
+		if err != nil {
+			b.send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Ошибка в строке %d: %v. Файл не загружен.", i+1, err)))
+			return
+		}
+		list = append(list, d)
+	}
+
+	var created, updated int
+	for _, d := range list {
+		if d.ID == 0 {
+			if u := b.currentUser(ctx); u != nil {
+				d.CreatedBy = u.ID
+			}
+		}
+		_, err := b.discounts.Save(ctx, d)
+		switch {
+		case errors.Is(err, discounts.ErrPromoCodeTaken):
+			b.send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Скидка «%s»: промокод %s уже занят.", d.Title, d.PromoCode)))
+			continue
+		case errors.Is(err, discounts.ErrNotFound):
+			b.send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Скидка с id %d не найдена, строка пропущена.", d.ID)))
+			continue
+		case err != nil:
+			b.log.Error("save discount failed", "id", d.ID, "err", err)
+			b.send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Не удалось сохранить скидку «%s».", d.Title)))
+			continue
+		}
+		if d.ID == 0 {
+			created++
+		} else {
+			updated++
+		}
+	}
+
+	b.send(tgbotapi.NewMessage(chatID,
+		fmt.Sprintf("Скидки загружены из файла.\nСоздано: %d\nОбновлено: %d", created, updated)))
+	b.showDiscountsMenu(ctx, chatID, nil)
+}
+
+// handleDiscountCallback — кнопки экрана скидок (data без префикса "price:disc:").
+func (b *Bot) handleDiscountCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, data string) {
+	chatID := cb.Message.Chat.ID
+	msgID := cb.Message.MessageID
+
+	switch {
+	case data == "menu":
+		b.showDiscountsMenu(ctx, chatID, &msgID)
+
+	case data == "export":
+		b.exportDiscountsExcel(ctx, chatID, msgID)
+		_ = b.answerCallback(cb, "Файл сформирован", false)
+		return
+
+	case data == "import":
+		_ = b.states.Set(ctx, chatID, dialog.StatePriceDiscImportFile, dialog.Payload{})
+		b.editTextWithNav(chatID, msgID,
+			"Загрузите Excel-файл со скидками (тот, что вы выгрузили через «Выгрузить скидки» и дополнили).")
+
+	case strings.HasPrefix(data, "tg:"):
+		id, _ := strconv.ParseInt(strings.TrimPrefix(data, "tg:"), 10, 64)
+		d, err := b.discounts.GetByID(ctx, id)
+		if err != nil {
+			_ = b.answerCallback(cb, "Скидка не найдена", true)
+			return
+		}
+		if err := b.discounts.SetActive(ctx, id, !d.Active); err != nil {
+			_ = b.answerCallback(cb, "Ошибка сохранения", true)
+			return
+		}
+		b.showDiscountsMenu(ctx, chatID, &msgID)
+	}
+	_ = b.answerCallback(cb, "Ок", false)
+}
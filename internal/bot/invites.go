@@ -0,0 +1,376 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Spok95/beauty-bot/internal/dialog"
+	"github.com/Spok95/beauty-bot/internal/domain/invites"
+	"github.com/Spok95/beauty-bot/internal/domain/users"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const adminInvitesLimit = 20
+
+// варианты лимита использований и срока действия (0 — без ограничения)
+var (
+	inviteUsesOptions = []int{1, 5, 10, 0}
+	inviteDaysOptions = []int{1, 7, 30, 0}
+)
+
+// inviteLink — deep link на бота с кодом приглашения.
+func (b *Bot) inviteLink(code string) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s", b.api.Self.UserName, code)
+}
+
+func inviteUsesLabel(n int) string {
+	if n == 0 {
+		return "без ограничения"
+	}
+	return strconv.Itoa(n)
+}
+
+func inviteDaysLabel(n int) string {
+	if n == 0 {
+		return "бессрочно"
+	}
+	return fmt.Sprintf("%d дн.", n)
+}
+
+// inviteErrorText — понятная причина, по которой приглашением нельзя воспользоваться.
+func inviteErrorText(err error) string {
+	switch {
+	case errors.Is(err, invites.ErrNotFound), errors.Is(err, invites.ErrRevoked),
+		errors.Is(err, invites.ErrExpired), errors.Is(err, invites.ErrExhausted):
+		return "Приглашение недействительно: " + err.Error() + "."
+	case errors.Is(err, users.ErrNotPending):
+		return "Приглашение не применено: " + err.Error() + "."
+	default:
+		return "Не удалось проверить приглашение."
+	}
+}
+
+// nextOption — следующее значение по кругу.
+func nextOption(list []int, cur int) int {
+	for i, v := range list {
+		if v == cur {
+			return list[(i+1)%len(list)]
+		}
+	}
+	return list[0]
+}
+
+// showAdminInvites — активные приглашения.
+func (b *Bot) showAdminInvites(ctx context.Context, chatID int64, editMsgID int) {
+	list, err := b.invites.ListActive(ctx, adminInvitesLimit)
+	if err != nil {
+		b.editTextAndClear(chatID, editMsgID, "Ошибка загрузки приглашений")
+		return
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("➕ Новое приглашение", "adm:usr:inv:new"),
+		),
+	}
+	for _, inv := range list {
+		label := fmt.Sprintf("%s — %d/%s", roleLabel(inv.Role), inv.UsedCount, inviteUsesLabel(inv.MaxUses))
+		if inv.AutoApprove {
+			label += " ⚡"
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("adm:usr:inv:card:%d", inv.ID)),
+		))
+	}
+	rows = append(rows, navKeyboard(true, true).InlineKeyboard[0])
+
+	text := "🎟 Приглашения\n\nСсылка вида /start <код> ведёт сразу в регистрацию с заданной ролью."
+	if len(list) == 0 {
+		text += "\n\nАктивных приглашений нет."
+	} else {
+		text += "\n⚡ — без подтверждения админом."
+	}
+
+	_ = b.states.Set(ctx, chatID, dialog.StateAdmInvites, dialog.Payload{})
+	b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, editMsgID, text, tgbotapi.NewInlineKeyboardMarkup(rows...)))
+}
+
+// showAdminInviteNew — настройка нового приглашения (параметры в payload).
+func (b *Bot) showAdminInviteNew(ctx context.Context, chatID int64, editMsgID int, p dialog.Payload) {
+	role := users.Role(payloadString(p, "role"))
+	place := payloadString(p, "place")
+	whID := payloadInt64(p["warehouse_id"])
+	auto, _ := p["auto"].(bool)
+
+	whName := "—"
+	if whID > 0 {
+		if w, _ := b.catalog.GetWarehouseByID(ctx, whID); w != nil {
+			whName = w.Name
+		}
+	}
+	autoLabel := "нет, заявка админам"
+	if auto {
+		autoLabel = "да, сразу доступ"
+	}
+
+	text := fmt.Sprintf(
+		"Новое приглашение\n\nРоль: %s\nПомещение: %s\nСклад: %s\nАвтоодобрение: %s\nИспользований: %s\nСрок: %s",
+		roleLabel(role), placeLabel(place), whName, autoLabel,
+		inviteUsesLabel(payloadInt(p, "uses")), inviteDaysLabel(payloadInt(p, "days")),
+	)
+	if role == users.RoleAdmin {
+		text += "\n\nПриглашения с ролью «Админ» всегда требуют подтверждения."
+	}
+
+	kb := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("👤 Роль", "adm:usr:inv:set:role"),
+			tgbotapi.NewInlineKeyboardButtonData("🏠 Помещение", "adm:usr:inv:set:place"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🏬 Склад", "adm:usr:inv:wh"),
+			tgbotapi.NewInlineKeyboardButtonData("⚡ Автоодобрение", "adm:usr:inv:set:auto"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔢 Использований", "adm:usr:inv:set:uses"),
+			tgbotapi.NewInlineKeyboardButtonData("⏳ Срок", "adm:usr:inv:set:days"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Создать", "adm:usr:inv:create"),
+		),
+		navKeyboard(true, true).InlineKeyboard[0],
+	)
+
+	delete(p, "wh_pick")
+	_ = b.states.Set(ctx, chatID, dialog.StateAdmInviteNew, p)
+	b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, editMsgID, text, kb))
+}
+
+// showAdminInviteCard — приглашение: ссылка, параметры, кто зарегистрировался.
+func (b *Bot) showAdminInviteCard(ctx context.Context, chatID int64, editMsgID int, id int64) {
+	inv, err := b.invites.GetByID(ctx, id)
+	if err != nil {
+		b.editTextAndClear(chatID, editMsgID, "Приглашение не найдено")
+		return
+	}
+
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, "🎟 Приглашение #%d\n\n%s\n\n", inv.ID, b.inviteLink(inv.Code))
+	_, _ = fmt.Fprintf(&sb, "Роль: %s\n", roleLabel(inv.Role))
+	_, _ = fmt.Fprintf(&sb, "Помещение: %s\n", placeLabel(inv.Place))
+	if inv.WarehouseID != nil {
+		name := fmt.Sprintf("ID %d", *inv.WarehouseID)
+		if w, _ := b.catalog.GetWarehouseByID(ctx, *inv.WarehouseID); w != nil {
+			name = w.Name
+		}
+		_, _ = fmt.Fprintf(&sb, "Склад: %s\n", name)
+	}
+	if inv.AutoApprove {
+		sb.WriteString("Автоодобрение: да\n")
+	} else {
+		sb.WriteString("Автоодобрение: нет\n")
+	}
+	_, _ = fmt.Fprintf(&sb, "Использовано: %d из %s\n", inv.UsedCount, inviteUsesLabel(inv.MaxUses))
+	if inv.ExpiresAt != nil {
+		_, _ = fmt.Fprintf(&sb, "Действует до: %s\n", inv.ExpiresAt.In(b.loc).Format("02.01.2006 15:04"))
+	}
+	if err := inv.Check(b.now()); err != nil {
+		_, _ = fmt.Fprintf(&sb, "Статус: %s\n", err.Error())
+	} else {
+		sb.WriteString("Статус: активно\n")
+	}
+
+	if uses, err := b.invites.ListUses(ctx, inv.ID); err == nil && len(uses) > 0 {
+		sb.WriteString("\nЗарегистрировались:\n")
+		for _, u := range uses {
+			name := strings.TrimSpace(u.Name)
+			if name == "" {
+				name = fmt.Sprintf("id %d", u.UserID)
+			}
+			_, _ = fmt.Fprintf(&sb, "• %s — %s\n", name, u.UsedAt.In(b.loc).Format("02.01.2006"))
+		}
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	if inv.RevokedAt == nil {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⛔ Отозвать", fmt.Sprintf("adm:usr:inv:revoke:%d", inv.ID)),
+		))
+	}
+	rows = append(rows, navKeyboard(true, true).InlineKeyboard[0])
+
+	_ = b.states.Set(ctx, chatID, dialog.StateAdmInviteCard, dialog.Payload{"invite_id": inv.ID})
+	b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, editMsgID, sb.String(), tgbotapi.NewInlineKeyboardMarkup(rows...)))
+}
+
+// handleAdminInvitesCallback — колбэки приглашений (data без префикса adm:usr:inv:).
+func (b *Bot) handleAdminInvitesCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, data string) {
+	chatID := cb.Message.Chat.ID
+	msgID := cb.Message.MessageID
+
+	st, _ := b.states.Get(ctx, chatID)
+	p := dialog.Payload{}
+	if st != nil && st.State == dialog.StateAdmInviteNew && st.Payload != nil {
+		p = st.Payload
+	}
+
+	switch {
+	case data == "list":
+		b.showAdminInvites(ctx, chatID, msgID)
+		_ = b.answerCallback(cb, "Ок", false)
+
+	case data == "new":
+		b.showAdminInviteNew(ctx, chatID, msgID, dialog.Payload{
+			"role": string(users.RoleMaster),
+			"uses": 1,
+			"days": 7,
+		})
+		_ = b.answerCallback(cb, "Ок", false)
+
+	case strings.HasPrefix(data, "set:"):
+		switch strings.TrimPrefix(data, "set:") {
+		case "role":
+			cur := users.Role(payloadString(p, "role"))
+			next := users.AllRoles[0]
+			for i, r := range users.AllRoles {
+				if r == cur {
+					next = users.AllRoles[(i+1)%len(users.AllRoles)]
+				}
+			}
+			p["role"] = string(next)
+			if next == users.RoleAdmin {
+				p["auto"] = false
+			}
+		case "place":
+			switch payloadString(p, "place") {
+			case "":
+				p["place"] = "hall"
+			case "hall":
+				p["place"] = "cabinet"
+			default:
+				p["place"] = ""
+			}
+		case "auto":
+			if users.Role(payloadString(p, "role")) == users.RoleAdmin {
+				_ = b.answerCallback(cb, "Для роли «Админ» автоодобрение недоступно", true)
+				return
+			}
+			auto, _ := p["auto"].(bool)
+			p["auto"] = !auto
+		case "uses":
+			p["uses"] = nextOption(inviteUsesOptions, payloadInt(p, "uses"))
+		case "days":
+			p["days"] = nextOption(inviteDaysOptions, payloadInt(p, "days"))
+		}
+		b.showAdminInviteNew(ctx, chatID, msgID, p)
+		_ = b.answerCallback(cb, "Ок", false)
+
+	case data == "wh":
+		whs, err := b.catalog.ListWarehouses(ctx)
+		if err != nil {
+			_ = b.answerCallback(cb, "Ошибка загрузки складов", true)
+			return
+		}
+		rows := [][]tgbotapi.InlineKeyboardButton{}
+		for _, w := range whs {
+			if !w.Active {
+				continue
+			}
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(w.Name, fmt.Sprintf("adm:usr:inv:whset:%d", w.ID)),
+			))
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Без склада", "adm:usr:inv:whset:0"),
+		))
+		rows = append(rows, navKeyboard(true, true).InlineKeyboard[0])
+		p["wh_pick"] = true
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmInviteNew, p)
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, msgID, "Склад по умолчанию для нового мастера:",
+			tgbotapi.NewInlineKeyboardMarkup(rows...)))
+		_ = b.answerCallback(cb, "Ок", false)
+
+	case strings.HasPrefix(data, "whset:"):
+		whID, _ := strconv.ParseInt(strings.TrimPrefix(data, "whset:"), 10, 64)
+		p["warehouse_id"] = whID
+		b.showAdminInviteNew(ctx, chatID, msgID, p)
+		_ = b.answerCallback(cb, "Ок", false)
+
+	case data == "create":
+		in := invites.Invite{
+			Role:    users.Role(payloadString(p, "role")),
+			Place:   payloadString(p, "place"),
+			MaxUses: payloadInt(p, "uses"),
+		}
+		if in.Role == "" {
+			_ = b.answerCallback(cb, "Неактуально", false)
+			return
+		}
+		in.AutoApprove, _ = p["auto"].(bool)
+		if in.Role == users.RoleAdmin {
+			in.AutoApprove = false
+		}
+		if whID := payloadInt64(p["warehouse_id"]); whID > 0 {
+			in.WarehouseID = &whID
+		}
+		if days := payloadInt(p, "days"); days > 0 {
+			exp := b.now().AddDate(0, 0, days)
+			in.ExpiresAt = &exp
+		}
+		if u := b.currentUser(ctx); u != nil {
+			in.CreatedBy = u.ID
+		}
+		inv, err := b.invites.Create(ctx, in)
+		if err != nil {
+			b.log.Error("create invite failed", "err", err)
+			_ = b.answerCallback(cb, "Ошибка создания приглашения", true)
+			return
+		}
+		b.showAdminInviteCard(ctx, chatID, msgID, inv.ID)
+		_ = b.answerCallback(cb, "Создано", false)
+
+	case strings.HasPrefix(data, "card:"):
+		id, _ := strconv.ParseInt(strings.TrimPrefix(data, "card:"), 10, 64)
+		b.showAdminInviteCard(ctx, chatID, msgID, id)
+		_ = b.answerCallback(cb, "Ок", false)
+
+	case strings.HasPrefix(data, "revoke:"):
+		id, _ := strconv.ParseInt(strings.TrimPrefix(data, "revoke:"), 10, 64)
+		if err := b.invites.Revoke(ctx, id); err != nil {
+			_ = b.answerCallback(cb, "Ошибка", true)
+			return
+		}
+		b.showAdminInviteCard(ctx, chatID, msgID, id)
+		_ = b.answerCallback(cb, "Приглашение отозвано", false)
+
+	default:
+		_ = b.answerCallback(cb, "Неизвестное действие", false)
+	}
+}
+
+// applyInvite засчитывает приглашение при отправке анкеты и переносит его умолчания в профиль.
+// Возвращает nil, если приглашением воспользоваться нельзя — тогда анкета идёт обычной заявкой.
+func (b *Bot) applyInvite(ctx context.Context, chatID int64, code string, u *users.User) *invites.Invite {
+	if code == "" || u == nil {
+		return nil
+	}
+	inv, err := b.invites.Redeem(ctx, code, u.ID, b.now())
+	if err != nil {
+		b.send(tgbotapi.NewMessage(chatID, inviteErrorText(err)+" Заявка будет отправлена администратору."))
+		return nil
+	}
+	if inv.Place != "" {
+		if err := b.users.SetProfileField(ctx, u.ID, users.ProfileDefaultPlace, inv.Place); err != nil {
+			b.log.Warn("invite: set default place failed", "user_id", u.ID, "err", err)
+		}
+	}
+	if inv.WarehouseID != nil {
+		if err := b.users.SetDefaultWarehouse(ctx, u.ID, inv.WarehouseID); err != nil {
+			b.log.Warn("invite: set default warehouse failed", "user_id", u.ID, "err", err)
+		}
+	}
+	return inv
+}
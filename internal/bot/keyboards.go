@@ -41,6 +41,22 @@ func (b *Bot) unitKeyboard(id int64) tgbotapi.InlineKeyboardMarkup {
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("pcs", fmt.Sprintf("adm:mat:unit:set:%d:pcs", id)),
 			tgbotapi.NewInlineKeyboardButtonData("g", fmt.Sprintf("adm:mat:unit:set:%d:g", id)),
+			tgbotapi.NewInlineKeyboardButtonData("ml", fmt.Sprintf("adm:mat:unit:set:%d:ml", id)),
+		),
+		navKeyboard(true, true).InlineKeyboard[0],
+	)
+}
+
+// purchaseUnitKeyboard — выбор единицы закупки (упаковки) материала.
+func (b *Bot) purchaseUnitKeyboard(id int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("уп", fmt.Sprintf("adm:mat:pu:set:%d:pack", id)),
+			tgbotapi.NewInlineKeyboardButtonData("туба", fmt.Sprintf("adm:mat:pu:set:%d:tube", id)),
+			tgbotapi.NewInlineKeyboardButtonData("фл", fmt.Sprintf("adm:mat:pu:set:%d:bottle", id)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Без упаковки", fmt.Sprintf("adm:mat:pu:set:%d:none", id)),
 		),
 		navKeyboard(true, true).InlineKeyboard[0],
 	)
@@ -66,7 +82,9 @@ func adminReplyKeyboard() tgbotapi.ReplyKeyboardMarkup {
 			{tgbotapi.NewKeyboardButton("Инвентаризация"), tgbotapi.NewKeyboardButton("Поставки")},
 			{tgbotapi.NewKeyboardButton("Установка цен"), tgbotapi.NewKeyboardButton("Установка тарифов")},
 			{tgbotapi.NewKeyboardButton("Аренда и Расходы материалов по мастерам")},
-			{tgbotapi.NewKeyboardButton("Оповещение всем")},
+			{tgbotapi.NewKeyboardButton("Аналитика"), tgbotapi.NewKeyboardButton("Отчёты по расписанию")},
+			{tgbotapi.NewKeyboardButton("Рассылки"), tgbotapi.NewKeyboardButton("Пользователи")},
+			{tgbotapi.NewKeyboardButton("Журнал изменений")},
 			{tgbotapi.NewKeyboardButton("Чат с админом")},
 			{tgbotapi.NewKeyboardButton("История чата")},
 			{tgbotapi.NewKeyboardButton("Сменить роль")},
@@ -80,7 +98,7 @@ func masterReplyKeyboard() tgbotapi.ReplyKeyboardMarkup {
 		Keyboard: [][]tgbotapi.KeyboardButton{
 			{tgbotapi.NewKeyboardButton("Расход/Аренда"), tgbotapi.NewKeyboardButton("Текущий чек")},
 			{tgbotapi.NewKeyboardButton("Отменить последний расход")},
-			{tgbotapi.NewKeyboardButton("Просмотр остатков")},
+			{tgbotapi.NewKeyboardButton("Моя история"), tgbotapi.NewKeyboardButton("Просмотр остатков")},
 			{tgbotapi.NewKeyboardButton("Мои абонементы"), tgbotapi.NewKeyboardButton("Купить абонемент")},
 			{tgbotapi.NewKeyboardButton("Чат с админом")},
 			{tgbotapi.NewKeyboardButton("Сменить роль")},
@@ -0,0 +1,457 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Spok95/beauty-bot/internal/dialog"
+	"github.com/Spok95/beauty-bot/internal/domain/audit"
+	"github.com/Spok95/beauty-bot/internal/domain/templates"
+	"github.com/Spok95/beauty-bot/internal/domain/users"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/xuri/excelize/v2"
+)
+
+// showConsCartFromPayload перерисовывает корзину расхода по данным из payload.
+func (b *Bot) showConsCartFromPayload(ctx context.Context, chatID int64, editMsgID *int, payload dialog.Payload) {
+	b.showConsCart(
+		ctx,
+		chatID,
+		editMsgID,
+		payloadString(payload, "place"),
+		payloadString(payload, "unit"),
+		payloadInt(payload, "qty"),
+		b.consParseItems(payload["items"]),
+	)
+}
+
+// showConsTemplateList — список шаблонов расхода, доступных мастеру (личные + общие салона).
+func (b *Bot) showConsTemplateList(ctx context.Context, chatID int64, editMsgID int, u *users.User) {
+	list, err := b.templates.ListForUser(ctx, u.ID)
+	if err != nil {
+		b.editTextAndClear(chatID, editMsgID, "Ошибка загрузки шаблонов.")
+		return
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for _, t := range list {
+		label := "👤 " + t.Name
+		if t.IsShared() {
+			label = "🏠 " + t.Name
+		}
+
+		row := tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("cons:tpl:apply:%d", t.ID)),
+		)
+		// удалять можно только свои шаблоны
+		if t.OwnerUserID == u.ID {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData("🗑", fmt.Sprintf("cons:tpl:del:%d", t.ID)))
+		}
+		rows = append(rows, row)
+	}
+	rows = append(rows, navKeyboard(true, true).InlineKeyboard[0])
+
+	text := "Шаблоны расхода:\n🏠 — общие шаблоны салона, 👤 — ваши.\n\nВыберите шаблон — материалы добавятся в чек, количество можно будет изменить."
+	if len(list) == 0 {
+		text = "Шаблонов пока нет.\n\nСоберите чек и нажмите «Сохранить как шаблон», чтобы в следующий раз добавить материалы в одно касание."
+	}
+
+	b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, editMsgID, text, tgbotapi.NewInlineKeyboardMarkup(rows...)))
+}
+
+// applyConsTemplate добавляет материалы шаблона в корзину расхода.
+// Материалы, которые не относятся к выбранному складу или скрыты, пропускаются.
+func (b *Bot) applyConsTemplate(ctx context.Context, chatID int64, editMsgID int, u *users.User, templateID int64) error {
+	t, err := b.templates.GetByID(ctx, templateID)
+	if err != nil {
+		return err
+	}
+	if t == nil || !t.Active || (!t.IsShared() && t.OwnerUserID != u.ID) {
+		return fmt.Errorf("шаблон не найден")
+	}
+
+	st, _ := b.states.Get(ctx, chatID)
+	if st == nil || st.Payload == nil {
+		return fmt.Errorf("сессия устарела")
+	}
+
+	whID := payloadInt64(st.Payload["warehouse_id"])
+	if whID <= 0 {
+		return fmt.Errorf("склад не выбран")
+	}
+
+	tplItems, err := b.templates.ListItems(ctx, t.ID)
+	if err != nil {
+		return err
+	}
+
+	items := b.consParseItems(st.Payload["items"])
+	var skipped, noPrice []string
+	added := 0
+
+	for _, ti := range tplItems {
+		name := materialDisplayName(ti.Brand, ti.Name)
+
+		m, err := b.materials.GetByID(ctx, ti.MaterialID)
+		if err != nil || m == nil || !m.Active {
+			skipped = append(skipped, name)
+			continue
+		}
+		allowed, err := b.materials.IsMaterialAllowedInWarehouse(ctx, whID, ti.MaterialID)
+		if err != nil || !allowed {
+			skipped = append(skipped, name)
+			continue
+		}
+
+		// если материал уже есть в чеке — увеличиваем количество
+		merged := false
+		for _, it := range items {
+			if payloadInt64(it["mat_id"]) == ti.MaterialID {
+				q, _ := it["qty"].(float64)
+				it["qty"] = q + ti.Qty
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			item := map[string]any{
+				"mat_id": float64(ti.MaterialID),
+				"qty":    ti.Qty,
+			}
+			if _, err := b.consItemPrice(ctx, item); err != nil {
+				b.log.Error("material price load failed", "material_id", ti.MaterialID, "err", err)
+				noPrice = append(noPrice, name)
+				continue
+			}
+			items = append(items, item)
+		}
+		added++
+	}
+
+	st.Payload["items"] = items
+	_ = b.states.Set(ctx, chatID, dialog.StateConsCart, st.Payload)
+	b.showConsCartFromPayload(ctx, chatID, &editMsgID, st.Payload)
+
+	if len(skipped) > 0 {
+		b.send(tgbotapi.NewMessage(chatID,
+			fmt.Sprintf("Шаблон «%s»: добавлено позиций — %d.\nНе добавлены (нет на выбранном складе или скрыты):\n• %s",
+				t.Name, added, strings.Join(skipped, "\n• "))))
+	}
+	if len(noPrice) > 0 {
+		b.send(tgbotapi.NewMessage(chatID,
+			fmt.Sprintf("Не удалось загрузить цены, позиции не добавлены — попробуйте применить шаблон ещё раз:\n• %s",
+				strings.Join(noPrice, "\n• "))))
+	}
+	return nil
+}
+
+// showConsCartItems — список позиций корзины для изменения количества.
+func (b *Bot) showConsCartItems(ctx context.Context, chatID int64, editMsgID int, items []map[string]any) {
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for i, it := range items {
+		matID := payloadInt64(it["mat_id"])
+		q, _ := it["qty"].(float64)
+		name := fmt.Sprintf("ID:%d", matID)
+		unit := ""
+		if m, _ := b.materials.GetByID(ctx, matID); m != nil {
+			name = materialDisplayName(m.Brand, m.Name)
+			unit = materialUnitLabel(string(m.Unit))
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("✏️ %s — %s %s", name, formatQty(q), unit),
+				fmt.Sprintf("cons:item:%d", i),
+			),
+		))
+	}
+	rows = append(rows, navKeyboard(true, true).InlineKeyboard[0])
+
+	b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, editMsgID,
+		"Выберите позицию, чтобы изменить количество или удалить её из чека:",
+		tgbotapi.NewInlineKeyboardMarkup(rows...)))
+}
+
+// saveConsCartAsTemplate сохраняет текущую корзину как личный шаблон мастера.
+func (b *Bot) saveConsCartAsTemplate(ctx context.Context, u *users.User, name string, items []map[string]any) (*templates.Template, error) {
+	in := make([]templates.ItemInput, 0, len(items))
+	for _, it := range items {
+		matID := payloadInt64(it["mat_id"])
+		q, _ := it["qty"].(float64)
+		if matID <= 0 || q <= 0 {
+			continue
+		}
+		in = append(in, templates.ItemInput{MaterialID: matID, Qty: q})
+	}
+	if len(in) == 0 {
+		return nil, fmt.Errorf("в чеке нет материалов")
+	}
+	return b.templates.Create(ctx, name, u.ID, true, in)
+}
+
+func (b *Bot) showMaterialTemplatesMenu(chatID int64, editMsgID int) {
+	kb := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬇️ Выгрузить шаблоны", "adm:tpl:export"),
+			tgbotapi.NewInlineKeyboardButtonData("⬆️ Загрузить шаблоны", "adm:tpl:import"),
+		),
+		navKeyboard(true, true).InlineKeyboard[0],
+	)
+	b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, editMsgID,
+		"Шаблоны расхода (общие для салона) — выберите действие", kb))
+}
+
+// exportMaterialTemplatesExcel выгружает общие шаблоны салона: одна строка — одна позиция шаблона.
+func (b *Bot) exportMaterialTemplatesExcel(ctx context.Context, chatID int64, msgID int) {
+	list, err := b.templates.ListShared(ctx)
+	if err != nil {
+		b.editTextAndClear(chatID, msgID, "Ошибка загрузки шаблонов")
+		return
+	}
+
+	f := excelize.NewFile()
+	defer func() { _ = f.Close() }()
+
+	sheet := f.GetSheetName(f.GetActiveSheetIndex())
+
+	header := []interface{}{
+		"template_id", // пусто — новый шаблон
+		"template_name",
+		"active", // 1 — показывать мастерам, 0 — скрыть
+		"material_id",
+		"brand",
+		"material_name",
+		"unit",
+		"qty",
+	}
+	if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
+		b.editTextAndClear(chatID, msgID, "Ошибка формирования файла (заголовок)")
+		return
+	}
+
+	row := 2
+	for _, t := range list {
+		items, err := b.templates.ListItems(ctx, t.ID)
+		if err != nil {
+			b.editTextAndClear(chatID, msgID, "Ошибка загрузки позиций шаблона")
+			return
+		}
+		active := 0
+		if t.Active {
+			active = 1
+		}
+		for _, it := range items {
+			excelRow := []interface{}{
+				t.ID,
+				t.Name,
+				active,
+				it.MaterialID,
+				it.Brand,
+				it.Name,
+				it.Unit,
+				it.Qty,
+			}
+			cell, err := excelize.CoordinatesToCellName(1, row)
+			if err != nil {
+				b.editTextAndClear(chatID, msgID, "Ошибка формирования файла (ячейки)")
+				return
+			}
+			if err := f.SetSheetRow(sheet, cell, &excelRow); err != nil {
+				b.editTextAndClear(chatID, msgID, "Ошибка формирования файла (строки)")
+				return
+			}
+			row++
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := f.Write(buf); err != nil {
+		b.editTextAndClear(chatID, msgID, "Ошибка записи файла")
+		return
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("templates_%s.xlsx", b.now().Format("20060102_150405")),
+		Bytes: buf.Bytes(),
+	})
+	doc.Caption = "Шаблоны расхода салона.\n" +
+		"Одна строка — один материал шаблона. Для нового шаблона оставьте template_id пустым и укажите template_name.\n" +
+		"Измените файл и загрузите его через «Загрузить шаблоны»."
+	b.send(doc)
+
+	b.editTextWithNav(chatID, msgID, fmt.Sprintf("Сформирован файл с шаблонами (%d шт.).", len(list)))
+}
+
+type templateImportGroup struct {
+	id     int64
+	name   string
+	active bool
+	items  []templates.ItemInput
+}
+
+// handleMaterialTemplatesImportExcel читает файл шаблонов и создаёт/обновляет общие шаблоны салона.
+// Позиции существующего шаблона полностью заменяются содержимым файла.
+func (b *Bot) handleMaterialTemplatesImportExcel(ctx context.Context, chatID int64, data []byte) {
+	ctx = audit.WithSource(ctx, audit.SourceExcel)
+
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		b.send(tgbotapi.NewMessage(chatID, "Не удалось прочитать Excel-файл (повреждён или не .xlsx)."))
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	sheet := f.GetSheetName(f.GetActiveSheetIndex())
+	rows, err := f.GetRows(sheet)
+	if err != nil || len(rows) < 2 {
+		b.send(tgbotapi.NewMessage(chatID, "Файл не содержит данных (нет строк с шаблонами)."))
+		return
+	}
+
+	if len(rows[0]) < 8 {
+		b.send(tgbotapi.NewMessage(chatID, "Некорректный формат файла: ожидается минимум 8 колонок (template_id ... qty)."))
+		return
+	}
+
+	// сначала проверяем весь файл, потом сохраняем
+	groups := []*templateImportGroup{}
+	byKey := map[string]*templateImportGroup{}
+
+	for i := 1; i < len(rows); i++ {
+		row := rows[i]
+		for len(row) < 8 {
+			row = append(row, "")
+		}
+
+		idStr := strings.TrimSpace(row[0])
+		name := strings.TrimSpace(row[1])
+		activeStr := strings.ToLower(strings.TrimSpace(row[2]))
+		matIDStr := strings.TrimSpace(row[3])
+		qtyStr := strings.TrimSpace(row[7])
+
+		if idStr == "" && name == "" && matIDStr == "" {
+			continue
+		}
+
+		var id int64
+		if idStr != "" {
+			id, err = strconv.ParseInt(idStr, 10, 64)
+			if err != nil || id <= 0 {
+				b.send(tgbotapi.NewMessage(chatID,
+					fmt.Sprintf("Ошибка в строке %d: некорректный template_id (%q).", i+1, idStr)))
+				return
+			}
+		}
+		if name == "" {
+			b.send(tgbotapi.NewMessage(chatID,
+				fmt.Sprintf("Ошибка в строке %d: не указано название шаблона (template_name).", i+1)))
+			return
+		}
+
+		active := true
+		switch activeStr {
+		case "", "1", "true", "да":
+		case "0", "false", "нет":
+			active = false
+		default:
+			b.send(tgbotapi.NewMessage(chatID,
+				fmt.Sprintf("Ошибка в строке %d: некорректное значение active (%q). Используйте 1 или 0.", i+1, activeStr)))
+			return
+		}
+
+		key := "id:" + idStr
+		if id == 0 {
+			key = "name:" + strings.ToLower(name)
+		}
+		g, ok := byKey[key]
+		if !ok {
+			if id > 0 {
+				t, err := b.templates.GetByID(ctx, id)
+				if err != nil {
+					b.send(tgbotapi.NewMessage(chatID,
+						fmt.Sprintf("Ошибка проверки шаблона в строке %d.", i+1)))
+					return
+				}
+				if t == nil || !t.IsShared() {
+					b.send(tgbotapi.NewMessage(chatID,
+						fmt.Sprintf("Ошибка в строке %d: общий шаблон с template_id %d не найден.", i+1, id)))
+					return
+				}
+			}
+			g = &templateImportGroup{id: id, name: name, active: active}
+			byKey[key] = g
+			groups = append(groups, g)
+		} else if g.name != name || g.active != active {
+			b.send(tgbotapi.NewMessage(chatID,
+				fmt.Sprintf("Ошибка в строке %d: название или active шаблона расходятся с предыдущими строками этого шаблона.", i+1)))
+			return
+		}
+
+		// пустое количество — позиция не входит в шаблон
+		if qtyStr == "" {
+			continue
+		}
+
+		matID, err := strconv.ParseInt(matIDStr, 10, 64)
+		if err != nil || matID <= 0 {
+			b.send(tgbotapi.NewMessage(chatID,
+				fmt.Sprintf("Ошибка в строке %d: некорректный material_id (%q).", i+1, matIDStr)))
+			return
+		}
+		m, err := b.materials.GetByID(ctx, matID)
+		if err != nil || m == nil {
+			b.send(tgbotapi.NewMessage(chatID,
+				fmt.Sprintf("Ошибка в строке %d: материал %d не найден.", i+1, matID)))
+			return
+		}
+
+		qty, _, err := parseMaterialQty(qtyStr, m.Unit)
+		if err != nil {
+			b.send(tgbotapi.NewMessage(chatID,
+				fmt.Sprintf("Ошибка в строке %d: некорректное qty (%q): %v.", i+1, qtyStr, err)))
+			return
+		}
+
+		g.items = append(g.items, templates.ItemInput{MaterialID: matID, Qty: qty})
+	}
+
+	if len(groups) == 0 {
+		b.send(tgbotapi.NewMessage(chatID, "В файле не найдено ни одного шаблона."))
+		return
+	}
+	for _, g := range groups {
+		if len(g.items) == 0 {
+			b.send(tgbotapi.NewMessage(chatID,
+				fmt.Sprintf("Ошибка: в шаблоне «%s» нет ни одной позиции с заполненным qty.", g.name)))
+			return
+		}
+	}
+
+	var created, updated int
+	for _, g := range groups {
+		if g.id > 0 {
+			if err := b.templates.Update(ctx, g.id, g.name, g.active, g.items); err != nil {
+				b.send(tgbotapi.NewMessage(chatID,
+					fmt.Sprintf("Ошибка сохранения шаблона «%s»: %v", g.name, err)))
+				return
+			}
+			updated++
+			continue
+		}
+
+		if _, err := b.templates.Create(ctx, g.name, 0, g.active, g.items); err != nil {
+			b.send(tgbotapi.NewMessage(chatID,
+				fmt.Sprintf("Ошибка создания шаблона «%s»: %v", g.name, err)))
+			return
+		}
+		created++
+	}
+
+	b.send(tgbotapi.NewMessage(chatID,
+		fmt.Sprintf("Шаблоны загружены из файла.\nСоздано: %d\nОбновлено: %d", created, updated)))
+
+	_ = b.states.Set(ctx, chatID, dialog.StateAdmMatMenu, dialog.Payload{})
+	b.showMaterialMenu(chatID, nil)
+}
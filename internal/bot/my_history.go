@@ -0,0 +1,379 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Spok95/beauty-bot/internal/domain/analytics"
+	"github.com/Spok95/beauty-bot/internal/domain/consumption"
+	"github.com/Spok95/beauty-bot/internal/domain/users"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/xuri/excelize/v2"
+)
+
+// historyPageSize — сессий на странице «Моя история».
+const historyPageSize = 10
+
+var monthNamesRU = [...]string{"", "январь", "февраль", "март", "апрель", "май", "июнь",
+	"июль", "август", "сентябрь", "октябрь", "ноябрь", "декабрь"}
+
+// monthLabel — «октябрь 2026».
+func monthLabel(m time.Time) string {
+	return fmt.Sprintf("%s %d", monthNamesRU[m.Month()], m.Year())
+}
+
+// parseHistoryMonth разбирает месяц "YYYY-MM" в часовом поясе салона; пустой или неверный — текущий месяц.
+func (b *Bot) parseHistoryMonth(s string) time.Time {
+	if m, err := time.ParseInLocation("2006-01", s, b.loc); err == nil {
+		return m
+	}
+	now := b.now()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, b.loc)
+}
+
+// invoiceStatusIcon — значок оплаты счёта сессии в списке.
+func invoiceStatusIcon(status string) string {
+	switch status {
+	case "paid":
+		return "✅"
+	case "pending":
+		return "⏳"
+	default:
+		return "·"
+	}
+}
+
+// historyTotals — к оплате, оплачено и долг по сессиям.
+func historyTotals(list []consumption.MasterSessionReport) (total, paid, owed float64) {
+	for _, s := range list {
+		total += s.Total
+		switch s.InvoiceStatus {
+		case "paid":
+			paid += s.Total
+		case "pending":
+			owed += s.Total
+		}
+	}
+	return total, paid, owed
+}
+
+// showMyHistory — сессии мастера за месяц со ссылками на чеки.
+func (b *Bot) showMyHistory(ctx context.Context, chatID int64, editMsgID *int, month string, page int) {
+	u := b.currentUser(ctx)
+	if u == nil {
+		return
+	}
+	m := b.parseHistoryMonth(month)
+	month = m.Format("2006-01")
+
+	list, err := b.cons.ListUserSessionsReport(ctx, u.ID, m, m.AddDate(0, 1, 0))
+	if err != nil {
+		b.log.Error("list user sessions failed", "user", u.ID, "err", err)
+		b.send(tgbotapi.NewMessage(chatID, "Не удалось загрузить историю, попробуйте позже."))
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Моя история — %s\n\n", monthLabel(m)))
+	if len(list) == 0 {
+		sb.WriteString("Сессий за месяц нет.")
+	} else {
+		total, paid, owed := historyTotals(list)
+		sb.WriteString(fmt.Sprintf("Сессий: %d\nК оплате: %.2f ₽ (оплачено %.2f ₽, не оплачено %.2f ₽)\n\nВыберите сессию, чтобы посмотреть чек.",
+			len(list), total, paid, owed))
+	}
+
+	// новые сверху
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+	pages := (len(list) + historyPageSize - 1) / historyPageSize
+	if page >= pages {
+		page = pages - 1
+	}
+	if page < 0 {
+		page = 0
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, s := range list[min(page*historyPageSize, len(list)):min((page+1)*historyPageSize, len(list))] {
+		label := fmt.Sprintf("%s %s · %s · %.2f ₽",
+			invoiceStatusIcon(s.InvoiceStatus), s.CreatedAt.In(b.loc).Format("02.01 15:04"), historySessionShort(s), s.Total)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("hist:s:%d:%s:%d", s.SessionID, month, page))))
+	}
+	if pages > 1 {
+		var pager []tgbotapi.InlineKeyboardButton
+		if page > 0 {
+			pager = append(pager, tgbotapi.NewInlineKeyboardButtonData("⬅️", fmt.Sprintf("hist:m:%s:%d", month, page-1)))
+		}
+		pager = append(pager, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d/%d", page+1, pages), "noop"))
+		if page < pages-1 {
+			pager = append(pager, tgbotapi.NewInlineKeyboardButtonData("➡️", fmt.Sprintf("hist:m:%s:%d", month, page+1)))
+		}
+		rows = append(rows, pager)
+	}
+
+	monthNav := []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("« "+monthLabel(m.AddDate(0, -1, 0)), "hist:m:"+m.AddDate(0, -1, 0).Format("2006-01")+":0"),
+	}
+	if next := m.AddDate(0, 1, 0); !next.After(b.now()) {
+		monthNav = append(monthNav, tgbotapi.NewInlineKeyboardButtonData(monthLabel(next)+" »", "hist:m:"+next.Format("2006-01")+":0"))
+	}
+	rows = append(rows, monthNav)
+	if len(list) > 0 {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📄 Выписка за месяц (Excel)", "hist:st:"+month)))
+	}
+	rows = append(rows, navKeyboard(false, true).InlineKeyboard[0])
+
+	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if editMsgID != nil {
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, *editMsgID, sb.String(), kb))
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ReplyMarkup = kb
+	b.send(msg)
+}
+
+// historySessionShort — тип и объём аренды сессии: «Общий зал 3 ч».
+func historySessionShort(s consumption.MasterSessionReport) string {
+	if q := reportSessionQty(s); q != "—" {
+		return fmt.Sprintf("%s %s", placeLabel(s.Place), q)
+	}
+	return reportSessionKind(s)
+}
+
+// sessionReceiptText — чек сохранённой сессии.
+func (b *Bot) sessionReceiptText(s consumption.MasterSessionReport) string {
+	var lines []string
+	lines = append(lines,
+		fmt.Sprintf("Чек №%d от %s", s.SessionID, s.CreatedAt.In(b.loc).Format("02.01.2006 15:04")),
+		"",
+		"• Тип: "+reportSessionKind(s),
+	)
+	if q := reportSessionQty(s); q != "—" {
+		lines = append(lines, "• Количество: "+q)
+	}
+	if parts := reportRentPartsText(s); parts != "" {
+		lines = append(lines, "• Абонемент: "+parts)
+	}
+	if s.WarehouseName != "" {
+		lines = append(lines, "• Склад: "+s.WarehouseName)
+	}
+	if s.FinalComment != "" {
+		lines = append(lines, "• Комментарий мастера: "+s.FinalComment)
+	}
+
+	lines = append(lines, "", "Материалы:")
+	if len(s.Items) == 0 {
+		lines = append(lines, "• Материалы не внесены")
+	}
+	for _, it := range s.Items {
+		lines = append(lines, fmt.Sprintf("• %s — %s %s × %.2f ₽ = %.2f ₽",
+			materialDisplayName(it.BrandName, it.MaterialName), formatQty(it.Qty),
+			materialUnitLabel(it.MaterialUnit), it.UnitPrice, it.Cost))
+	}
+
+	lines = append(lines, "", "Итого:", fmt.Sprintf("• Материалы: %.2f ₽", s.MaterialsSum))
+	if s.RoundedMaterialsSum != s.MaterialsSum {
+		lines = append(lines, fmt.Sprintf("• В зачёт аренды: %.2f ₽", s.RoundedMaterialsSum))
+	}
+	lines = append(lines, fmt.Sprintf("• Аренда: %.2f ₽", s.Rent))
+	if s.Discount > 0 {
+		lines = append(lines, fmt.Sprintf("• Скидки: −%.2f ₽", s.Discount))
+	}
+	lines = append(lines,
+		fmt.Sprintf("• Всего к оплате: %.2f ₽", s.Total),
+		"• Счёт: "+invoiceStatusLabel(s.InvoiceStatus),
+	)
+	return strings.Join(lines, "\n")
+}
+
+// handleMyHistoryCallback — кнопки «Моя история» (data без префикса "hist:").
+func (b *Bot) handleMyHistoryCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, data string) {
+	chatID := cb.Message.Chat.ID
+	msgID := cb.Message.MessageID
+	u := b.currentUser(ctx)
+	if u == nil {
+		_ = b.answerCallback(cb, "Нет доступа", true)
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(data, "m:"):
+		// m:<YYYY-MM>:<page>
+		parts := strings.Split(strings.TrimPrefix(data, "m:"), ":")
+		page := 0
+		if len(parts) > 1 {
+			page, _ = strconv.Atoi(parts[1])
+		}
+		_ = b.answerCallback(cb, "", false)
+		b.showMyHistory(ctx, chatID, &msgID, parts[0], page)
+
+	case strings.HasPrefix(data, "s:"):
+		// s:<session_id>:<YYYY-MM>:<page> — месяц и страница нужны для возврата к списку
+		parts := strings.Split(strings.TrimPrefix(data, "s:"), ":")
+		if len(parts) != 3 {
+			_ = b.answerCallback(cb, "Некорректные данные", true)
+			return
+		}
+		sid, _ := strconv.ParseInt(parts[0], 10, 64)
+		s, err := b.cons.GetUserSessionReport(ctx, u.ID, sid)
+		if err != nil || s == nil {
+			_ = b.answerCallback(cb, "Сессия не найдена", true)
+			return
+		}
+		_ = b.answerCallback(cb, "", false)
+		pdfRow := []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData("📄 Чек (PDF)", fmt.Sprintf("hist:pdf:r:%d", sid)),
+		}
+		if s.InvoiceID != 0 {
+			pdfRow = append(pdfRow, tgbotapi.NewInlineKeyboardButtonData("🧾 Счёт (PDF)", fmt.Sprintf("hist:pdf:i:%d", sid)))
+		}
+		kb := tgbotapi.NewInlineKeyboardMarkup(pdfRow, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ К списку", fmt.Sprintf("hist:m:%s:%s", parts[1], parts[2]))))
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, msgID, b.sessionReceiptText(*s), kb))
+
+	case strings.HasPrefix(data, "pdf:"):
+		// pdf:r:<session_id> — чек, pdf:i:<session_id> — счёт
+		parts := strings.Split(strings.TrimPrefix(data, "pdf:"), ":")
+		if len(parts) != 2 {
+			_ = b.answerCallback(cb, "Некорректные данные", true)
+			return
+		}
+		sid, _ := strconv.ParseInt(parts[1], 10, 64)
+		s, err := b.cons.GetUserSessionReport(ctx, u.ID, sid)
+		if err != nil || s == nil {
+			_ = b.answerCallback(cb, "Сессия не найдена", true)
+			return
+		}
+		_ = b.answerCallback(cb, "Формирую PDF…", false)
+		if parts[0] == "i" {
+			if ok, err := b.sendSessionInvoicePDF(chatID, *s); err != nil {
+				b.log.Error("invoice pdf failed", "session_id", sid, "err", err)
+				b.send(tgbotapi.NewMessage(chatID, "Не удалось сформировать счёт, попробуйте позже."))
+			} else if !ok {
+				b.send(tgbotapi.NewMessage(chatID, "По этой сессии счёт не выставлялся."))
+			}
+			return
+		}
+		if err := b.sendSessionReceiptPDF(chatID, *s); err != nil {
+			b.log.Error("receipt pdf failed", "session_id", sid, "err", err)
+			b.send(tgbotapi.NewMessage(chatID, "Не удалось сформировать чек, попробуйте позже."))
+		}
+
+	case strings.HasPrefix(data, "st:"):
+		m := b.parseHistoryMonth(strings.TrimPrefix(data, "st:"))
+		_ = b.answerCallback(cb, "Формирую выписку…", false)
+		if err := b.sendMonthlyStatement(ctx, chatID, u, m); err != nil {
+			b.log.Error("monthly statement failed", "user", u.ID, "err", err)
+			b.send(tgbotapi.NewMessage(chatID, "Не удалось сформировать выписку, попробуйте позже."))
+		}
+	}
+}
+
+// sendMonthlyStatement — выписка мастера за месяц: аренда, материалы, абонементы, оплачено и долг.
+func (b *Bot) sendMonthlyStatement(ctx context.Context, chatID int64, u *users.User, m time.Time) error {
+	list, err := b.cons.ListUserSessionsReport(ctx, u.ID, m, m.AddDate(0, 1, 0))
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		b.send(tgbotapi.NewMessage(chatID, fmt.Sprintf("За %s сессий нет.", monthLabel(m))))
+		return nil
+	}
+	subs, err := b.subs.ListByUserMonth(ctx, u.ID, m.Format("2006-01"))
+	if err != nil {
+		return err
+	}
+	stats := analytics.Analyze(list, 0)
+	total, paid, owed := historyTotals(list)
+
+	f := excelize.NewFile()
+	defer func() { _ = f.Close() }()
+	const sheet = "Выписка"
+	if err := f.SetSheetName(f.GetSheetName(f.GetActiveSheetIndex()), sheet); err != nil {
+		return err
+	}
+
+	row := 1
+	put := func(values ...interface{}) error {
+		cell, _ := excelize.CoordinatesToCellName(1, row)
+		row++
+		return f.SetSheetRow(sheet, cell, &values)
+	}
+
+	if err := put(fmt.Sprintf("Выписка за %s", monthLabel(m))); err != nil {
+		return err
+	}
+	_ = put("Мастер", b.userFullName(ctx, u))
+	_ = put("Сформирована", b.now().Format("02.01.2006 15:04"))
+	row++
+
+	_ = put("Итоги")
+	_ = put("Сессий", len(list))
+	for _, usage := range stats.Usage {
+		_ = put(fmt.Sprintf("Аренда: %s, %s", placeLabel(usage.Place), reportUnitLabel(usage.Unit)), usage.Qty,
+			fmt.Sprintf("из них по абонементу: %d", usage.SubQty))
+	}
+	_ = put("Материалы, ₽", stats.Materials)
+	_ = put("Аренда, ₽", stats.Rent)
+	if stats.Discounts > 0 {
+		_ = put("Скидки, ₽", -stats.Discounts)
+	}
+	_ = put("К оплате, ₽", total)
+	_ = put("Оплачено, ₽", paid)
+	_ = put("Не оплачено, ₽", owed)
+	row++
+
+	if len(subs) > 0 {
+		_ = put("Абонементы")
+		_ = put("Помещение", "Ед.", "План", "Куплено", "Использовано", "Остаток", "Материалы", "Порог", "Порог выполнен")
+		for _, s := range subs {
+			left := max(s.TotalQty-s.UsedQty, 0)
+			met := "нет"
+			if s.ThresholdMet {
+				met = "да"
+			}
+			_ = put(placeLabel(s.Place), reportUnitLabel(s.Unit), s.PlanLimit, s.TotalQty, s.UsedQty, left,
+				s.MaterialsSumTotal, s.ThresholdMaterialsTotal, met)
+		}
+		row++
+	}
+
+	_ = put("Материалы за месяц")
+	_ = put("Бренд", "Материал", "Ед.", "Кол-во", "Сумма, ₽")
+	for _, it := range stats.TopByCost {
+		_ = put(it.BrandName, it.MaterialName, it.Unit, it.Qty, it.Cost)
+	}
+	row++
+
+	_ = put("Сессии")
+	_ = put("№", "Дата", "Тип", "Кол-во", "Абонемент", "Материалы", "В зачёт", "Аренда", "Скидка", "Итого", "Счёт")
+	for _, s := range list {
+		_ = put(s.SessionID, s.CreatedAt.In(b.loc).Format("02.01.2006 15:04"), reportSessionKind(s), reportSessionQty(s),
+			reportRentPartsText(s), s.MaterialsSum, s.RoundedMaterialsSum, s.Rent, s.Discount, s.Total,
+			invoiceStatusLabel(s.InvoiceStatus))
+	}
+	_ = f.SetColWidth(sheet, "A", "A", 28)
+	_ = f.SetColWidth(sheet, "B", "K", 16)
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		return err
+	}
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("statement_%s.xlsx", m.Format("2006_01")),
+		Bytes: buf.Bytes(),
+	})
+	doc.Caption = fmt.Sprintf("Выписка за %s: к оплате %.2f ₽, оплачено %.2f ₽, не оплачено %.2f ₽",
+		monthLabel(m), total, paid, owed)
+	b.send(doc)
+	return nil
+}
@@ -0,0 +1,168 @@
+package bot
+
+import (
+	"fmt"
+
+	"github.com/Spok95/beauty-bot/internal/domain/consumption"
+	"github.com/Spok95/beauty-bot/internal/domain/subscriptions"
+	"github.com/Spok95/beauty-bot/internal/infra/barcode"
+	"github.com/Spok95/beauty-bot/internal/infra/pdf"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// rub — сумма в рублях для документов.
+func rub(v float64) string {
+	return fmt.Sprintf("%.2f ₽", v)
+}
+
+// sessionFields — общие поля чека и счёта по сессии.
+func (b *Bot) sessionFields(d *pdf.Doc, s consumption.MasterSessionReport) {
+	d.Field("Дата:", s.CreatedAt.In(b.loc).Format("02.01.2006 15:04"))
+	d.Field("Мастер:", orDash(s.Username))
+	d.Field("Тип:", reportSessionKind(s))
+	if q := reportSessionQty(s); q != "—" {
+		d.Field("Количество:", q)
+	}
+	if parts := reportRentPartsText(s); parts != "" {
+		d.Field("Абонемент:", parts)
+	}
+}
+
+// receiptPDF — чек сессии расхода/аренды с позициями материалов.
+func (b *Bot) receiptPDF(s consumption.MasterSessionReport) ([]byte, error) {
+	d := pdf.New(fmt.Sprintf("Чек №%d", s.SessionID), b.now())
+	b.sessionFields(d, s)
+	if s.WarehouseName != "" {
+		d.Field("Склад:", s.WarehouseName)
+	}
+	if s.FinalComment != "" {
+		d.Field("Комментарий мастера:", s.FinalComment)
+	}
+
+	d.Section("Материалы")
+	if len(s.Items) == 0 {
+		d.Text("Материалы не внесены.")
+	} else {
+		rows := make([][]string, 0, len(s.Items))
+		for _, it := range s.Items {
+			rows = append(rows, []string{
+				materialDisplayName(it.BrandName, it.MaterialName),
+				formatQty(it.Qty),
+				materialUnitLabel(it.MaterialUnit),
+				rub(it.UnitPrice),
+				rub(it.Cost),
+			})
+		}
+		d.Table([]pdf.Column{
+			{Title: "Материал", Width: 85},
+			{Title: "Кол-во", Width: 20, Right: true},
+			{Title: "Ед.", Width: 15},
+			{Title: "Цена", Width: 30, Right: true},
+			{Title: "Сумма", Width: 30, Right: true},
+		}, rows)
+	}
+
+	d.Gap()
+	d.Total("Материалы:", rub(s.MaterialsSum), false)
+	if s.RoundedMaterialsSum != s.MaterialsSum {
+		d.Total("В зачёт аренды:", rub(s.RoundedMaterialsSum), false)
+	}
+	d.Total("Аренда:", rub(s.Rent), false)
+	if s.Discount > 0 {
+		d.Total("Скидки:", "−"+rub(s.Discount), false)
+	}
+	d.Total("Всего к оплате:", rub(s.Total), true)
+	d.Total("Счёт:", invoiceStatusLabel(s.InvoiceStatus), false)
+	return d.Bytes()
+}
+
+// invoicePDF — счёт по сессии; для неоплаченного счёта со ссылкой добавляется QR-код на оплату.
+func (b *Bot) invoicePDF(s consumption.MasterSessionReport) ([]byte, error) {
+	d := pdf.New(fmt.Sprintf("Счёт №%d", s.InvoiceID), b.now())
+	d.Field("Основание:", fmt.Sprintf("сессия №%d", s.SessionID))
+	b.sessionFields(d, s)
+	d.Field("Статус:", invoiceStatusLabel(s.InvoiceStatus))
+
+	rows := [][]string{
+		{"Аренда: " + reportSessionKind(s), reportSessionQty(s), rub(s.Rent)},
+		{fmt.Sprintf("Материалы (%d поз.)", len(s.Items)), "—", rub(s.MaterialsSum)},
+	}
+	if s.Discount > 0 {
+		rows = append(rows, []string{"Скидки", "—", "−" + rub(s.Discount)})
+	}
+	d.Section("Начисления")
+	d.Table([]pdf.Column{
+		{Title: "Наименование", Width: 110},
+		{Title: "Кол-во", Width: 30, Right: true},
+		{Title: "Сумма", Width: 40, Right: true},
+	}, rows)
+	d.Total("Итого к оплате:", rub(s.Total), true)
+
+	if s.PaymentLink != "" && s.InvoiceStatus == "pending" {
+		d.Section("Оплата")
+		d.Text("Отсканируйте QR-код камерой телефона или перейдите по ссылке:")
+		d.Link(s.PaymentLink, s.PaymentLink)
+		d.Gap()
+		qr, err := barcode.EncodeQR(s.PaymentLink, 300)
+		if err != nil {
+			return nil, err
+		}
+		d.Image("qr", qr, 45)
+	}
+	return d.Bytes()
+}
+
+// subscriptionPDF — подтверждение покупки абонемента.
+func (b *Bot) subscriptionPDF(s subscriptions.Subscription, master string) ([]byte, error) {
+	d := pdf.New(fmt.Sprintf("Подтверждение покупки абонемента №%d", s.ID), b.now())
+	d.Field("Дата:", s.CreatedAt.In(b.loc).Format("02.01.2006 15:04"))
+	d.Field("Мастер:", orDash(master))
+	d.Field("Помещение:", placeLabel(s.Place))
+	d.Field("Месяц:", monthLabel(b.parseHistoryMonth(s.Month)))
+	d.Field("Объём:", fmt.Sprintf("%d %s", s.PlanLimit, reportUnitLabel(s.Unit)))
+	if s.PricePerUnit > 0 {
+		d.Field(fmt.Sprintf("Цена за %s:", reportUnitLabel(s.Unit)), rub(s.PricePerUnit))
+	}
+	if s.ThresholdMaterialsTotal > 0 {
+		d.Field("Порог по материалам:", rub(s.ThresholdMaterialsTotal))
+		d.Gap()
+		d.Text("Условие абонемента — материалы за месяц на сумму не меньше порога; от его выполнения зависит цена следующего абонемента.")
+	}
+	if s.PricePerUnit > 0 {
+		d.Gap()
+		d.Total("Оплачено:", rub(s.PricePerUnit*float64(s.PlanLimit)), true)
+	}
+	return d.Bytes()
+}
+
+// sendPDF отправляет готовый PDF документом.
+func (b *Bot) sendPDF(chatID int64, name string, data []byte, caption string) {
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: name, Bytes: data})
+	doc.Caption = caption
+	b.send(doc)
+}
+
+// sendSessionReceiptPDF — чек сессии мастера в PDF.
+func (b *Bot) sendSessionReceiptPDF(chatID int64, s consumption.MasterSessionReport) error {
+	data, err := b.receiptPDF(s)
+	if err != nil {
+		return err
+	}
+	b.sendPDF(chatID, fmt.Sprintf("receipt_%d.pdf", s.SessionID), data,
+		fmt.Sprintf("Чек №%d от %s", s.SessionID, s.CreatedAt.In(b.loc).Format("02.01.2006")))
+	return nil
+}
+
+// sendSessionInvoicePDF — счёт по сессии в PDF; false — у сессии нет счёта.
+func (b *Bot) sendSessionInvoicePDF(chatID int64, s consumption.MasterSessionReport) (bool, error) {
+	if s.InvoiceID == 0 {
+		return false, nil
+	}
+	data, err := b.invoicePDF(s)
+	if err != nil {
+		return true, err
+	}
+	b.sendPDF(chatID, fmt.Sprintf("invoice_%d.pdf", s.InvoiceID), data,
+		fmt.Sprintf("Счёт №%d на %s (%s)", s.InvoiceID, rub(s.Total), invoiceStatusLabel(s.InvoiceStatus)))
+	return true, nil
+}
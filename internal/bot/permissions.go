@@ -0,0 +1,289 @@
+package bot
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Spok95/beauty-bot/internal/access"
+	"github.com/Spok95/beauty-bot/internal/dialog"
+	"github.com/Spok95/beauty-bot/internal/domain/audit"
+	"github.com/Spok95/beauty-bot/internal/domain/users"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// actor — автор текущего апдейта; пользователь загружается один раз на апдейт.
+type actor struct {
+	user *users.User
+}
+
+type actorKey struct{}
+
+// withActor загружает пользователя по Telegram ID и кладёт его в контекст апдейта;
+// он же становится автором изменений в журнале.
+func (b *Bot) withActor(ctx context.Context, from *tgbotapi.User) context.Context {
+	ctx = audit.WithSource(ctx, audit.SourceBot)
+	if from == nil {
+		return ctx
+	}
+	a := &actor{}
+	if u, err := b.users.GetByTelegramID(ctx, from.ID); err == nil {
+		a.user = u
+		ctx = audit.WithActor(ctx, u.ID)
+	}
+	return context.WithValue(ctx, actorKey{}, a)
+}
+
+// currentUser — пользователь текущего апдейта (nil, если не зарегистрирован).
+func (b *Bot) currentUser(ctx context.Context) *users.User {
+	if a, ok := ctx.Value(actorKey{}).(*actor); ok {
+		return a.user
+	}
+	return nil
+}
+
+// anyRoleCapabilities — права, которые проверяются по всем ролям пользователя, а не только
+// по активной: заявки приходят всем владельцам роли, и подтвердить их можно из любого меню.
+var anyRoleCapabilities = map[access.Capability]bool{
+	access.CapUsersApprove: true,
+	access.CapSubsApprove:  true,
+}
+
+// can — есть ли у автора апдейта право.
+func (b *Bot) can(ctx context.Context, c access.Capability) bool {
+	u := b.currentUser(ctx)
+	if u == nil || u.Status != users.StatusApproved {
+		return false
+	}
+	if b.policy.Allows(u.Role, c) {
+		return true
+	}
+	if anyRoleCapabilities[c] {
+		for _, r := range u.Roles {
+			if b.policy.Allows(r, c) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// recipientsWith — Telegram ID подтверждённых пользователей, у которых есть роль с правом c.
+func (b *Bot) recipientsWith(ctx context.Context, c access.Capability) []int64 {
+	var out []int64
+	for _, u := range b.usersWith(ctx, c) {
+		out = append(out, u.TelegramID)
+	}
+	return out
+}
+
+// usersWith — подтверждённые пользователи с Telegram ID, у которых есть право c.
+func (b *Bot) usersWith(ctx context.Context, c access.Capability) []*users.User {
+	seen := map[int64]struct{}{}
+	var out []*users.User
+	for _, role := range b.policy.RolesWith(c) {
+		list, err := b.users.ListByRole(ctx, role, users.StatusApproved)
+		if err != nil {
+			b.log.Warn("list users by role failed", "role", role, "err", err)
+			continue
+		}
+		for _, u := range list {
+			if _, ok := seen[u.TelegramID]; ok || u.TelegramID == 0 {
+				continue
+			}
+			seen[u.TelegramID] = struct{}{}
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+// notifyCapability рассылает сообщение всем, у кого есть право c. Если таких нет
+// (например, админы ещё не назначены), сообщение уходит в admin_chat_id из конфигурации.
+// Возвращает число получателей.
+func (b *Bot) notifyCapability(ctx context.Context, c access.Capability, text string, kb *tgbotapi.InlineKeyboardMarkup) int {
+	targets := b.recipientsWith(ctx, c)
+	if len(targets) == 0 && b.adminChat != 0 {
+		targets = []int64{b.adminChat}
+	}
+	for _, id := range targets {
+		m := tgbotapi.NewMessage(id, text)
+		if kb != nil {
+			m.ReplyMarkup = *kb
+		}
+		b.send(m)
+	}
+	return len(targets)
+}
+
+// commandCapabilities — права для команд.
+var commandCapabilities = map[string]access.Capability{
+	"admin": access.CapRatesEdit,
+	"rent":  access.CapConsUse,
+}
+
+// textCapabilities — права для кнопок нижней панели.
+var textCapabilities = map[string]access.Capability{
+	"Расход/Аренда": access.CapConsUse,
+	"/rent":         access.CapConsUse,
+	"/consumption":  access.CapConsUse,
+	"Текущий чек":   access.CapConsUse,
+	"Отменить последний расход": access.CapConsUse,
+	"Моя история":               access.CapConsUse,
+	"Просмотр остатков":         access.CapStockBrowse,
+	"Мои абонементы":            access.CapSubsBuy,
+	"Купить абонемент":          access.CapSubsBuy,
+	"Чат с админом":             access.CapChatUse,
+	"История чата":              access.CapChatHistory,
+	"Склады":                    access.CapWarehousesManage,
+	"Категории":                 access.CapCatalogManage,
+	"Материалы":                 access.CapCatalogManage,
+	"Инвентаризация":            access.CapStockManage,
+	"Поставки":                  access.CapSuppliesManage,
+	"Абонементы":                access.CapSubsManage,
+	"Установка цен":             access.CapPricesEdit,
+	"Установка тарифов":         access.CapRatesEdit,
+	"Рассылки":                  access.CapBroadcast,
+	"Пользователи":              access.CapUsersManage,
+	"Журнал изменений":          access.CapAuditView,
+	"Аренда и Расходы материалов по мастерам": access.CapReportsView,
+	"Отчёты по расписанию":                    access.CapReportsView,
+	"Аналитика":                               access.CapReportsView,
+}
+
+type prefixCapability struct {
+	prefix string
+	cap    access.Capability
+}
+
+// callbackCapabilities — права для inline-кнопок по префиксу data.
+// Проверяется первое совпадение, поэтому более частные префиксы — выше.
+var callbackCapabilities = []prefixCapability{
+	{"approve:", access.CapUsersApprove},
+	{"reject:", access.CapUsersApprove},
+	{"subrq:", access.CapSubsApprove},
+	{"adm:usr:", access.CapUsersManage},
+	{"adm:audit:", access.CapAuditView},
+	{"adm:rep:", access.CapReportsView},
+	{"adm:bc:", access.CapBroadcast},
+	{"adm:wh:", access.CapWarehousesManage},
+	{"adm:cat:", access.CapCatalogManage},
+	{"adm:mat:", access.CapCatalogManage},
+	{"adm:brand:", access.CapCatalogManage},
+	{"adm:tpl:", access.CapCatalogManage},
+	{"adm:subs:", access.CapSubsManage},
+	{"adminchat:", access.CapChatHistory},
+	{"stock:import", access.CapStockImport},
+	{"stock:", access.CapStockManage},
+	{"st:", access.CapStockManage},
+	{"sup:", access.CapSuppliesManage},
+	{"price:", access.CapPricesEdit},
+	{"rates:", access.CapRatesEdit},
+	{"mstock:", access.CapStockBrowse},
+	{"subbuy:", access.CapSubsBuy},
+	{"subs:pdf:", access.CapSubsBuy},
+	{"cons:", access.CapConsUse},
+	{"hist:", access.CapConsUse},
+}
+
+// stateCapabilities — права для текстового ввода в состояниях диалога (по префиксу).
+var stateCapabilities = []prefixCapability{
+	{"adm_wh_", access.CapWarehousesManage},
+	{"adm_cat_", access.CapCatalogManage},
+	{"adm_mat_", access.CapCatalogManage},
+	{"adm_brand_", access.CapCatalogManage},
+	{"adm_tpl_", access.CapCatalogManage},
+	{"adm_user", access.CapUsersManage},
+	{"adm_audit", access.CapAuditView},
+	{"adm_subs_", access.CapSubsManage},
+	{"adm:rates:", access.CapRatesEdit},
+	{"adm_report_", access.CapReportsView},
+	{"adm_broadcast", access.CapBroadcast},
+	{string(dialog.StateStockImportFile), access.CapStockImport},
+	{"stock_", access.CapStockManage},
+	{"sup_", access.CapSuppliesManage},
+	{"price_", access.CapPricesEdit},
+	{"master_stock", access.CapStockBrowse},
+	{"sub_buy", access.CapSubsBuy},
+	{"cons_", access.CapConsUse},
+	{string(dialog.StateChatAdmin), access.CapChatUse},
+	{"adm_chat_", access.CapChatHistory},
+}
+
+func matchCapability(table []prefixCapability, s string) (access.Capability, bool) {
+	for _, pc := range table {
+		if strings.HasPrefix(s, pc.prefix) {
+			return pc.cap, true
+		}
+	}
+	return "", false
+}
+
+// Доступно без проверки прав: регистрация, помощь, выбор роли и навигация. Всё, что не указано
+// здесь и не сопоставлено праву в таблицах выше, запрещено — новый обработчик без записи
+// в таблице прав не станет доступен всем ролям.
+var (
+	publicCommands = map[string]bool{"start": true, "help": true}
+	publicTexts    = map[string]bool{"Список команд": true, "Сменить роль": true}
+	publicStates   = map[dialog.State]bool{
+		"":                       true,
+		dialog.StateIdle:         true,
+		dialog.StateAwaitFIO:     true,
+		dialog.StateAwaitPhone:   true,
+		dialog.StateAwaitRole:    true,
+		dialog.StateAwaitSpec:    true,
+		dialog.StateAwaitConfirm: true,
+	}
+	publicCallbacks = []string{"nav:", "noop", "reg:", "rq:send", "role:"}
+)
+
+func isPublicCallback(data string) bool {
+	for _, p := range publicCallbacks {
+		if strings.HasPrefix(data, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizeMessage проверяет права на команду, кнопку панели или ввод в текущем состоянии.
+func (b *Bot) authorizeMessage(ctx context.Context, msg *tgbotapi.Message) bool {
+	var (
+		c      access.Capability
+		ok     bool
+		public bool
+	)
+	switch {
+	case msg.IsCommand():
+		c, ok = commandCapabilities[msg.Command()]
+		public = publicCommands[msg.Command()]
+	default:
+		c, ok = textCapabilities[msg.Text]
+		public = publicTexts[msg.Text]
+		if !ok && !public {
+			st, _ := b.states.Get(ctx, msg.Chat.ID)
+			if st == nil || publicStates[st.State] {
+				public = true
+			} else if c, ok = matchCapability(stateCapabilities, string(st.State)); !ok || !b.can(ctx, c) {
+				_ = b.states.Reset(ctx, msg.Chat.ID)
+			}
+		}
+	}
+	if public || (ok && b.can(ctx, c)) {
+		return true
+	}
+	b.send(tgbotapi.NewMessage(msg.Chat.ID, "Недостаточно прав."))
+	return false
+}
+
+// authorizeCallback проверяет права на inline-кнопку.
+func (b *Bot) authorizeCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) bool {
+	if c, ok := matchCapability(callbackCapabilities, cb.Data); ok {
+		if b.can(ctx, c) {
+			return true
+		}
+	} else if isPublicCallback(cb.Data) {
+		return true
+	}
+	_ = b.answerCallback(cb, "Недостаточно прав", true)
+	return false
+}
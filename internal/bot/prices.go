@@ -4,12 +4,16 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Spok95/beauty-bot/internal/dialog"
+	"github.com/Spok95/beauty-bot/internal/domain/audit"
 	"github.com/Spok95/beauty-bot/internal/domain/consumption"
+	"github.com/Spok95/beauty-bot/internal/domain/users"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/xuri/excelize/v2"
 )
@@ -23,6 +27,9 @@ func (b *Bot) showPriceMainMenu(chatID int64, editMsgID *int) {
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("Установить новые тарифы на аренду", "price:rent:menu"),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Скидки и акции", "price:disc:menu"),
+		),
 		navKeyboard(false, true).InlineKeyboard[0],
 	)
 
@@ -97,6 +104,9 @@ func (b *Bot) showPriceRentMenu(chatID int64, editMsgID *int) {
 			tgbotapi.NewInlineKeyboardButtonData("⬇️ Выгрузить цены на аренду", "price:rent:export"),
 			tgbotapi.NewInlineKeyboardButtonData("⬆️ Загрузить цены на аренду", "price:rent:import"),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗂 Версии тарифов", "price:rent:versions"),
+		),
 		navKeyboard(false, true).InlineKeyboard[0],
 	)
 
@@ -157,6 +167,10 @@ func (b *Bot) exportWarehouseMaterialPricesExcel(ctx context.Context, chatID int
 		"material_name",
 		"unit",
 		"price_per_unit",
+		"purchase_unit",           // справочно: единица закупки (упаковка)
+		"price_per_purchase_unit", // справочно: цена за упаковку, при загрузке не читается
+		"valid_from",              // с какого момента действует новая цена; пусто — сразу
+		"scheduled",               // справочно: уже запланированные цены, при загрузке не читается
 	}
 	if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
 		b.editTextAndClear(chatID, msgID, "Ошибка формирования файла (заголовок)")
@@ -179,6 +193,14 @@ func (b *Bot) exportWarehouseMaterialPricesExcel(ctx context.Context, chatID int
 			it.Name,
 			string(it.Unit),
 			price,
+			"",
+			"",
+			"",
+			b.scheduledPricesLabel(ctx, it.ID),
+		}
+		if m := it.AsMaterial(); m.HasPurchaseUnit() {
+			excelRow[9] = string(m.PurchaseUnit)
+			excelRow[10] = price * m.PurchaseFactor
 		}
 		cell, err := excelize.CoordinatesToCellName(1, row)
 		if err != nil {
@@ -200,7 +222,7 @@ func (b *Bot) exportWarehouseMaterialPricesExcel(ctx context.Context, chatID int
 
 	fileName := fmt.Sprintf("prices_%s_%s.xlsx",
 		wh.Name,
-		time.Now().Format("20060102_150405"),
+		b.now().Format("20060102_150405"),
 	)
 
 	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
@@ -208,7 +230,8 @@ func (b *Bot) exportWarehouseMaterialPricesExcel(ctx context.Context, chatID int
 		Bytes: buf.Bytes(),
 	})
 	doc.Caption = fmt.Sprintf(
-		"Цены материалов склада «%s».\nПри необходимости измените колонку price_per_unit и загрузите файл через «Загрузить цены на материалы».",
+		"Цены материалов склада «%s».\nПри необходимости измените колонку price_per_unit и загрузите файл через «Загрузить цены на материалы».\n"+
+			"Чтобы цена вступила в силу позже, укажите дату в колонке valid_from (ДД.ММ.ГГГГ или ДД.ММ.ГГГГ ЧЧ:ММ).",
 		wh.Name,
 	)
 
@@ -220,7 +243,7 @@ func (b *Bot) exportWarehouseMaterialPricesExcel(ctx context.Context, chatID int
 
 // exportRentRatesExcel выгружает тарифы аренды в Excel.
 func (b *Bot) exportRentRatesExcel(ctx context.Context, chatID int64, msgID int) {
-	rates, err := b.cons.ListRentRates(ctx)
+	rates, err := b.cons.ListRentRates(ctx, b.now())
 	if err != nil {
 		b.editTextAndClear(chatID, msgID, "Ошибка загрузки тарифов аренды")
 		return
@@ -280,22 +303,24 @@ func (b *Bot) exportRentRatesExcel(ctx context.Context, chatID int64, msgID int)
 		return
 	}
 
-	fileName := fmt.Sprintf("rent_rates_%s.xlsx", time.Now().Format("20060102_150405"))
+	fileName := fmt.Sprintf("rent_rates_%s.xlsx", b.now().Format("20060102_150405"))
 
 	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
 		Name:  fileName,
 		Bytes: buf.Bytes(),
 	})
-	doc.Caption = "Тарифы аренды. Измените при необходимости threshold_materials / price_with_materials / price_own_materials и загрузите файл обратно через «Загрузить цены на аренду»."
+	doc.Caption = "Тарифы аренды. Измените при необходимости threshold_materials / price_with_materials / price_own_materials и загрузите файл обратно через «Загрузить цены на аренду» — будет создан черновик новой версии тарифов."
 
 	b.send(doc)
 	b.editTextWithNav(chatID, msgID, "Сформирован файл с тарифами аренды.")
 }
 
 // handlePriceRentImportExcel читает Excel-файл с тарифами аренды и
-// обновляет threshold/price_with/price_own по id.
-// Пустая ячейка => значение не меняем.
+// создаёт черновик новой версии с изменёнными threshold/price_with/price_own.
+// Пустая ячейка => значение не меняем. Действующие тарифы не трогаем до публикации.
 func (b *Bot) handlePriceRentImportExcel(ctx context.Context, chatID int64, data []byte) {
+	ctx = audit.WithSource(ctx, audit.SourceExcel)
+
 	f, err := excelize.OpenReader(bytes.NewReader(data))
 	if err != nil {
 		b.send(tgbotapi.NewMessage(chatID, "Не удалось прочитать Excel-файл (повреждён или не .xlsx)."))
@@ -316,9 +341,24 @@ func (b *Bot) handlePriceRentImportExcel(ctx context.Context, chatID int64, data
 		return
 	}
 
+	curID, err := b.cons.CurrentTariffVersionID(ctx, b.now())
+	if err != nil || curID == 0 {
+		b.send(tgbotapi.NewMessage(chatID, "Нет действующей версии тарифов аренды."))
+		return
+	}
+	curRates, err := b.cons.ListVersionRates(ctx, curID)
+	if err != nil {
+		b.send(tgbotapi.NewMessage(chatID, "Ошибка загрузки тарифов аренды."))
+		return
+	}
+	known := make(map[int64]struct{}, len(curRates))
+	for _, r := range curRates {
+		known[r.ID] = struct{}{}
+	}
+
 	var (
-		totalRows    int
-		updatedCount int
+		totalRows int
+		changes   []consumption.RateChange
 	)
 
 	for i := 1; i < len(rows); i++ {
@@ -342,6 +382,11 @@ func (b *Bot) handlePriceRentImportExcel(ctx context.Context, chatID int64, data
 				fmt.Sprintf("Ошибка в строке %d: некорректный id тарифа (%q).", i+1, idStr)))
 			return
 		}
+		if _, ok := known[id]; !ok {
+			b.send(tgbotapi.NewMessage(chatID,
+				fmt.Sprintf("Ошибка в строке %d: тариф id=%d не относится к действующей версии. Выгрузите файл заново.", i+1, id)))
+			return
+		}
 
 		var (
 			thrPtr *float64
@@ -383,30 +428,47 @@ func (b *Bot) handlePriceRentImportExcel(ctx context.Context, chatID int64, data
 			continue
 		}
 
-		if err := b.cons.UpdateRentRatePartial(ctx, id, thrPtr, pwPtr, poPtr); err != nil {
-			b.send(tgbotapi.NewMessage(chatID,
-				fmt.Sprintf("Ошибка обновления тарифа в строке %d (id=%d): %v", i+1, id, err)))
-			return
-		}
-
 		totalRows++
-		updatedCount++
+		changes = append(changes, consumption.RateChange{RateID: id, Threshold: thrPtr, PriceWith: pwPtr, PriceOwn: poPtr})
 	}
 
-	msg := fmt.Sprintf(
-		"Тарифы аренды обновлены из файла.\nСтрок обработано: %d\nТарифов с изменёнными значениями: %d",
-		totalRows, updatedCount,
-	)
-	b.send(tgbotapi.NewMessage(chatID, msg))
+	if len(changes) == 0 {
+		b.send(tgbotapi.NewMessage(chatID,
+			fmt.Sprintf("В файле нет изменений (строк обработано: %d). Черновик не создан.", totalRows)))
+		_ = b.states.Set(ctx, chatID, dialog.StatePriceRentMenu, dialog.Payload{})
+		b.showPriceRentMenu(chatID, nil)
+		return
+	}
+
+	draftID, idMap, err := b.cons.CreateTariffDraft(ctx, curID, "Загрузка из Excel")
+	if err != nil {
+		b.log.Error("create tariff draft failed", "err", err)
+		b.send(tgbotapi.NewMessage(chatID, "Не удалось создать черновик тарифов."))
+		return
+	}
+	for i := range changes {
+		changes[i].RateID = idMap[changes[i].RateID]
+	}
+	if err := b.cons.ApplyDraftRates(ctx, draftID, changes); err != nil {
+		b.log.Error("apply draft rates failed", "version_id", draftID, "err", err)
+		b.send(tgbotapi.NewMessage(chatID, "Не удалось сохранить изменения в черновик тарифов."))
+		return
+	}
 
-	_ = b.states.Set(ctx, chatID, dialog.StatePriceRentMenu, dialog.Payload{})
-	b.showPriceRentMenu(chatID, nil)
+	b.send(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+		"Создан черновик тарифов #%d.\nСтрок обработано: %d\nТарифов с изменёнными значениями: %d\n"+
+			"Проверьте сравнение ниже и опубликуйте черновик с нужной даты.",
+		draftID, totalRows, len(changes))))
+	b.showRentTariffVersion(ctx, chatID, nil, draftID)
 }
 
 // handlePriceMatImportExcel читает Excel-файл с ценами материалов и
 // обновляет price_per_unit для указанных материалов.
 // Пустая ячейка price_per_unit означает "оставить старую цену".
+// Дата в valid_from планирует цену на будущее; пустая или прошедшая — цена меняется сразу.
 func (b *Bot) handlePriceMatImportExcel(ctx context.Context, chatID int64, data []byte) {
+	ctx = audit.WithSource(ctx, audit.SourceExcel)
+
 	f, err := excelize.OpenReader(bytes.NewReader(data))
 	if err != nil {
 		b.send(tgbotapi.NewMessage(chatID, "Не удалось прочитать Excel-файл (повреждён или не .xlsx)."))
@@ -428,10 +490,11 @@ func (b *Bot) handlePriceMatImportExcel(ctx context.Context, chatID int64, data
 	}
 
 	var (
-		totalRows     int
-		updatedCount  int
-		warehouseID   int64
-		warehouseName string
+		totalRows      int
+		updatedCount   int
+		scheduledCount int
+		warehouseID    int64
+		warehouseName  string
 	)
 
 	if len(rows[1]) >= 2 {
@@ -509,6 +572,33 @@ func (b *Bot) handlePriceMatImportExcel(ctx context.Context, chatID int64, data
 			return
 		}
 
+		var validFrom time.Time
+		if len(row) > 11 && strings.TrimSpace(row[11]) != "" {
+			validFrom, err = parsePriceValidFrom(row[11], b.loc)
+			if err != nil {
+				b.send(tgbotapi.NewMessage(chatID,
+					fmt.Sprintf("Ошибка в строке %d: некорректная дата valid_from (%q). Используйте ДД.ММ.ГГГГ или ДД.ММ.ГГГГ ЧЧ:ММ.", i+1, row[11])))
+				return
+			}
+		}
+
+		if validFrom.After(b.now()) {
+			if err := b.materials.SchedulePrice(ctx, matID, price, validFrom); err != nil {
+				b.send(tgbotapi.NewMessage(chatID,
+					fmt.Sprintf("Ошибка планирования цены в строке %d (материал %d): %v", i+1, matID, err)))
+				return
+			}
+			totalRows++
+			scheduledCount++
+			continue
+		}
+
+		// та же цена — не плодим записи в истории
+		if cur, err := b.materials.GetPrice(ctx, matID); err == nil && math.Abs(cur-price) < 0.005 {
+			totalRows++
+			continue
+		}
+
 		if _, err := b.materials.UpdatePrice(ctx, matID, price); err != nil {
 			b.send(tgbotapi.NewMessage(chatID,
 				fmt.Sprintf("Ошибка обновления цены в строке %d (материал %d): %v", i+1, matID, err)))
@@ -520,8 +610,8 @@ func (b *Bot) handlePriceMatImportExcel(ctx context.Context, chatID int64, data
 	}
 
 	msg := fmt.Sprintf(
-		"Цены материалов склада «%s» обновлены из файла.\nСтрок обработано: %d\nМатериалов с обновлённой ценой: %d",
-		warehouseName, totalRows, updatedCount,
+		"Цены материалов склада «%s» обновлены из файла.\nСтрок обработано: %d\nМатериалов с обновлённой ценой: %d\nЗапланировано изменений цен: %d",
+		warehouseName, totalRows, updatedCount, scheduledCount,
 	)
 	b.send(tgbotapi.NewMessage(chatID, msg))
 
@@ -536,78 +626,188 @@ func (b *Bot) handleAdmRentMaterialsReport(
 	chatID int64,
 	from, toExclusive time.Time,
 ) error {
-	rows, err := b.cons.ListMasterMaterialsReport(ctx, from, toExclusive)
+	data, _, err := b.buildRentMaterialsReport(ctx, from, toExclusive)
 	if err != nil {
 		return err
 	}
-	if len(rows) == 0 {
+	if data == nil {
 		msg := tgbotapi.NewMessage(chatID, "За указанный период нет данных по аренде и расходу материалов.")
 		b.send(msg)
 		return nil
 	}
 
+	msg := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  rentMaterialsReportName(from, toExclusive),
+		Bytes: data,
+	})
+	msg.Caption = "Отчёт по аренде и расходам материалов по мастерам"
+
+	b.send(msg)
+	return nil
+}
+
+// rentMaterialsTotals — итоги отчёта по мастерам за период.
+type rentMaterialsTotals struct {
+	Masters, Sessions int
+
+	Materials, Rounded, Rent, Discount, Total float64
+	Paid, Unpaid                              float64
+}
+
+func rentMaterialsReportName(from, toExclusive time.Time) string {
+	return fmt.Sprintf("rent_materials_%s_%s.xlsx",
+		from.Format("20060102"),
+		toExclusive.Add(-24*time.Hour).Format("20060102"),
+	)
+}
+
+// buildRentMaterialsReport собирает Excel "Аренда и Расходы материалов по мастерам" и итоги;
+// nil — за период нет сессий. Строки отчёта — сессии (в том числе аренда без материалов),
+// позиции материалов — под сессией.
+func (b *Bot) buildRentMaterialsReport(
+	ctx context.Context,
+	from, toExclusive time.Time,
+) ([]byte, rentMaterialsTotals, error) {
+	var grand rentMaterialsTotals
+
+	sessions, err := b.cons.ListMasterSessionsReport(ctx, from, toExclusive)
+	if err != nil || len(sessions) == 0 {
+		return nil, grand, err
+	}
+
 	f := excelize.NewFile()
 	defer func() { _ = f.Close() }()
 
-	// Группируем по мастеру
-	type sessionKey struct {
-		SessionID int64
-		Place     string
-		Unit      string
-	}
+	// Группируем по мастеру (сессии приходят отсортированными по мастеру и дате)
 	type usageKey struct {
 		Place string
 		Unit  string
 	}
 	type masterData struct {
-		Rows     []consumption.MasterMaterialsReportRow
-		Sessions map[sessionKey]struct{}
+		UserID   int64
+		Sessions []consumption.MasterSessionReport
+		Usage    []usageKey
 		ByUsage  map[usageKey]int // суммарное количество часов/дней по place/unit
-		Username string
-	}
-	masters := make(map[int64]*masterData)
-
-	for _, r := range rows {
-		md, ok := masters[r.UserID]
-		if !ok {
-			md = &masterData{
-				Rows:     make([]consumption.MasterMaterialsReportRow, 0),
-				Sessions: make(map[sessionKey]struct{}),
-				ByUsage:  make(map[usageKey]int),
-				Username: r.Username,
+		BySub    map[usageKey]int // из них по абонементу
+
+		Materials, Rounded, Rent, Discount, Total float64
+		Paid, Unpaid                              float64
+	}
+	var masters []*masterData
+	for _, s := range sessions {
+		if len(masters) == 0 || masters[len(masters)-1].UserID != s.UserID {
+			masters = append(masters, &masterData{
+				UserID:  s.UserID,
+				ByUsage: make(map[usageKey]int),
+				BySub:   make(map[usageKey]int),
+			})
+		}
+		md := masters[len(masters)-1]
+		md.Sessions = append(md.Sessions, s)
+
+		if s.Qty > 0 && s.Place != "no_rent" {
+			uk := usageKey{Place: s.Place, Unit: s.Unit}
+			if _, ok := md.ByUsage[uk]; !ok {
+				md.Usage = append(md.Usage, uk)
+			}
+			md.ByUsage[uk] += s.Qty
+			for _, p := range s.RentParts {
+				if p.WithSub {
+					md.BySub[uk] += p.Qty
+				}
 			}
-			masters[r.UserID] = md
 		}
-		md.Rows = append(md.Rows, r)
 
-		// учёт аренды: считаем сессию один раз
-		sk := sessionKey{SessionID: r.SessionID, Place: r.Place, Unit: r.Unit}
-		if _, exists := md.Sessions[sk]; !exists {
-			md.Sessions[sk] = struct{}{}
-			uk := usageKey{Place: r.Place, Unit: r.Unit}
-			md.ByUsage[uk] += r.Qty
+		md.Materials += s.MaterialsSum
+		md.Rounded += s.RoundedMaterialsSum
+		md.Rent += s.Rent
+		md.Discount += s.Discount
+		md.Total += s.Total
+		switch s.InvoiceStatus {
+		case "paid":
+			md.Paid += s.Total
+		case "pending":
+			md.Unpaid += s.Total
 		}
 	}
+	sort.SliceStable(masters, func(i, j int) bool {
+		return strings.ToLower(masters[i].Sessions[0].Username) < strings.ToLower(masters[j].Sessions[0].Username)
+	})
+
+	periodLabel := fmt.Sprintf("%s — %s",
+		from.Format("02.01.2006"),
+		toExclusive.Add(-24*time.Hour).Format("02.01.2006"),
+	)
 
-	// Удалим дефолтный лист
-	defaultSheet := f.GetSheetName(f.GetActiveSheetIndex())
-	if defaultSheet != "" {
-		_ = f.DeleteSheet(defaultSheet)
+	// Сводка по всем мастерам — первым листом вместо дефолтного
+	const summarySheet = "Сводка"
+	if err := f.SetSheetName(f.GetSheetName(f.GetActiveSheetIndex()), summarySheet); err != nil {
+		return nil, grand, err
+	}
+	_ = f.SetCellValue(summarySheet, "A1", "Аренда и расходы материалов по мастерам за период "+periodLabel)
+	_ = f.MergeCell(summarySheet, "A1", "L1")
+	summaryHeader := []interface{}{
+		"Мастер", "Телефон", "Сессий", "Аренда (часы/дни)", "Из них по абонементу",
+		"Материалы", "В зачёт", "Аренда", "Скидки", "Итого", "Оплачено", "Не оплачено",
+	}
+	if err := f.SetSheetRow(summarySheet, "A3", &summaryHeader); err != nil {
+		return nil, grand, err
+	}
+
+	grand.Masters = len(masters)
+	for i, md := range masters {
+		first := md.Sessions[0]
+		var usage, bySub []string
+		for _, uk := range md.Usage {
+			usage = append(usage, fmt.Sprintf("%s: %d %s", placeLabel(uk.Place), md.ByUsage[uk], reportUnitLabel(uk.Unit)))
+			if n := md.BySub[uk]; n > 0 {
+				bySub = append(bySub, fmt.Sprintf("%s: %d %s", placeLabel(uk.Place), n, reportUnitLabel(uk.Unit)))
+			}
+		}
+		row := []interface{}{
+			strings.TrimSpace(first.Username), first.Phone, len(md.Sessions),
+			strings.Join(usage, "; "), strings.Join(bySub, "; "),
+			md.Materials, md.Rounded, md.Rent, md.Discount, md.Total, md.Paid, md.Unpaid,
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, i+4)
+		if err := f.SetSheetRow(summarySheet, cell, &row); err != nil {
+			return nil, grand, err
+		}
+
+		grand.Sessions += len(md.Sessions)
+		grand.Materials += md.Materials
+		grand.Rounded += md.Rounded
+		grand.Rent += md.Rent
+		grand.Discount += md.Discount
+		grand.Total += md.Total
+		grand.Paid += md.Paid
+		grand.Unpaid += md.Unpaid
+	}
+	totalRow := []interface{}{
+		"Итого", nil, grand.Sessions, nil, nil,
+		grand.Materials, grand.Rounded, grand.Rent, grand.Discount, grand.Total, grand.Paid, grand.Unpaid,
+	}
+	cell, _ := excelize.CoordinatesToCellName(1, len(masters)+4)
+	if err := f.SetSheetRow(summarySheet, cell, &totalRow); err != nil {
+		return nil, grand, err
 	}
 
 	// Для каждого мастера свой лист
-	for userID, md := range masters {
+	for _, md := range masters {
+		first := md.Sessions[0]
+		userID := md.UserID
+
 		sheetName := fmt.Sprintf("user_%d", userID)
-		if len(md.Username) > 0 {
+		if len(first.Username) > 0 {
 			// чуть более человеко-читаемое имя (но не больше 31 символа, иначе Excel ругается)
-			base := md.Username
+			base := []rune(first.Username)
 			if len(base) > 20 {
 				base = base[:20]
 			}
-			sheetName = fmt.Sprintf("%s_%d", base, userID)
+			sheetName = fmt.Sprintf("%s_%d", string(base), userID)
 		}
-		if len(sheetName) > 31 {
-			sheetName = sheetName[:31]
+		if r := []rune(sheetName); len(r) > 31 {
+			sheetName = string(r[:31])
 		}
 
 		_, err := f.NewSheet(sheetName)
@@ -620,109 +820,227 @@ func (b *Bot) handleAdmRentMaterialsReport(
 		rowIdx := 1
 
 		// Заголовок: информация по мастеру и периоду
-		header := fmt.Sprintf("Отчёт по мастеру %s за период %s — %s",
-			strings.TrimSpace(md.Username),
-			from.Format("02.01.2006"),
-			toExclusive.Add(-24*time.Hour).Format("02.01.2006"),
-		)
+		header := fmt.Sprintf("Отчёт по мастеру %s за период %s", strings.TrimSpace(first.Username), periodLabel)
 		if err := f.SetCellValue(sheetName, "A1", header); err != nil {
-			return err
+			return nil, grand, err
 		}
-		if err := f.MergeCell(sheetName, "A1", "I1"); err != nil {
-			return err
+		if err := f.MergeCell(sheetName, "A1", "L1"); err != nil {
+			return nil, grand, err
 		}
+		rowIdx++
+
+		// Контакты и условия из профиля мастера
+		specs := make([]users.Specialization, 0, len(first.Specializations))
+		for _, s := range first.Specializations {
+			specs = append(specs, users.Specialization(s))
+		}
+		profileLine := fmt.Sprintf("Телефон: %s; специализация: %s; условия: %s",
+			orDash(first.Phone), specializationsLabel(specs), orDash(first.RentTerms))
+		_ = f.SetCellValue(sheetName, fmt.Sprintf("A%d", rowIdx), profileLine)
+		_ = f.MergeCell(sheetName, fmt.Sprintf("A%d", rowIdx), fmt.Sprintf("L%d", rowIdx))
 		rowIdx += 2
 
-		// Статистика по аренде: часы/дни по помещению
-		_ = f.SetCellValue(sheetName, fmt.Sprintf("A%d", rowIdx), "Помещение")
-		_ = f.SetCellValue(sheetName, fmt.Sprintf("B%d", rowIdx), "Ед.")
-		_ = f.SetCellValue(sheetName, fmt.Sprintf("C%d", rowIdx), "Кол-во")
+		// Статистика по аренде: часы/дни по помещению, из них по абонементу
+		usageHeader := []interface{}{"Помещение", "Ед.", "Кол-во", "По абонементу"}
+		_ = f.SetSheetRow(sheetName, fmt.Sprintf("A%d", rowIdx), &usageHeader)
 		rowIdx++
-
-		for uk, qty := range md.ByUsage {
-			var placeRU string
-			switch uk.Place {
-			case "hall":
-				placeRU = "Зал"
-			case "cabinet":
-				placeRU = "Кабинет"
-			default:
-				placeRU = uk.Place
-			}
-			var unitRU string
-			switch uk.Unit {
-			case "hour":
-				unitRU = "часы"
-			case "day":
-				unitRU = "дни"
-			default:
-				unitRU = uk.Unit
-			}
-			_ = f.SetCellValue(sheetName, fmt.Sprintf("A%d", rowIdx), placeRU)
-			_ = f.SetCellValue(sheetName, fmt.Sprintf("B%d", rowIdx), unitRU)
-			_ = f.SetCellValue(sheetName, fmt.Sprintf("C%d", rowIdx), qty)
+		for _, uk := range md.Usage {
+			row := []interface{}{placeLabel(uk.Place), reportUnitLabel(uk.Unit), md.ByUsage[uk], md.BySub[uk]}
+			_ = f.SetSheetRow(sheetName, fmt.Sprintf("A%d", rowIdx), &row)
 			rowIdx++
 		}
-
 		rowIdx += 2
 
-		// Таблица с материалами
-		_ = f.SetCellValue(sheetName, fmt.Sprintf("A%d", rowIdx), "Дата")
-		_ = f.SetCellValue(sheetName, fmt.Sprintf("B%d", rowIdx), "Склад")
-		_ = f.SetCellValue(sheetName, fmt.Sprintf("C%d", rowIdx), "Комментарий")
-		_ = f.SetCellValue(sheetName, fmt.Sprintf("D%d", rowIdx), "Бренд")
-		_ = f.SetCellValue(sheetName, fmt.Sprintf("E%d", rowIdx), "Материал")
-		_ = f.SetCellValue(sheetName, fmt.Sprintf("F%d", rowIdx), "Ед.")
-		_ = f.SetCellValue(sheetName, fmt.Sprintf("G%d", rowIdx), "Кол-во")
-		_ = f.SetCellValue(sheetName, fmt.Sprintf("H%d", rowIdx), "Цена за ед.")
-		_ = f.SetCellValue(sheetName, fmt.Sprintf("I%d", rowIdx), "Сумма")
+		// Таблица сессий, позиции материалов — строками под сессией
+		sessionsHeader := []interface{}{
+			"Дата", "Склад", "Тип", "Кол-во", "Абонемент", "Материалы",
+			"В зачёт", "Аренда", "Скидка", "Итого", "Счёт", "Комментарий",
+		}
+		_ = f.SetSheetRow(sheetName, fmt.Sprintf("A%d", rowIdx), &sessionsHeader)
 		rowIdx++
 
-		for _, r := range md.Rows {
-			dateStr := r.CreatedAt.Format("02.01.2006 15:04")
-
-			warehouseName := r.WarehouseName
-			if strings.TrimSpace(warehouseName) == "" && r.WarehouseID > 0 {
-				warehouseName = fmt.Sprintf("ID %d", r.WarehouseID)
+		for _, s := range md.Sessions {
+			warehouseName := s.WarehouseName
+			if strings.TrimSpace(warehouseName) == "" && s.WarehouseID > 0 {
+				warehouseName = fmt.Sprintf("ID %d", s.WarehouseID)
 			}
 
-			_ = f.SetCellValue(sheetName, fmt.Sprintf("A%d", rowIdx), dateStr)
-			_ = f.SetCellValue(sheetName, fmt.Sprintf("B%d", rowIdx), warehouseName)
-			_ = f.SetCellValue(sheetName, fmt.Sprintf("C%d", rowIdx), r.Comment)
-			_ = f.SetCellValue(sheetName, fmt.Sprintf("D%d", rowIdx), r.BrandName)
-			_ = f.SetCellValue(sheetName, fmt.Sprintf("E%d", rowIdx), r.MaterialName)
-			_ = f.SetCellValue(sheetName, fmt.Sprintf("F%d", rowIdx), r.MaterialUnit)
-			_ = f.SetCellValue(sheetName, fmt.Sprintf("G%d", rowIdx), r.MaterialQty)
-			_ = f.SetCellValue(sheetName, fmt.Sprintf("H%d", rowIdx), r.UnitPrice)
-			_ = f.SetCellValue(sheetName, fmt.Sprintf("I%d", rowIdx), r.Cost)
+			row := []interface{}{
+				s.CreatedAt.Format("02.01.2006 15:04"),
+				warehouseName,
+				reportSessionKind(s),
+				reportSessionQty(s),
+				reportRentPartsText(s),
+				s.MaterialsSum,
+				s.RoundedMaterialsSum,
+				s.Rent,
+				s.Discount,
+				s.Total,
+				invoiceStatusLabel(s.InvoiceStatus),
+				s.Comment,
+			}
+			_ = f.SetSheetRow(sheetName, fmt.Sprintf("A%d", rowIdx), &row)
 			rowIdx++
+
+			for _, it := range s.Items {
+				name := strings.TrimSpace(it.BrandName + " " + it.MaterialName)
+				itemRow := []interface{}{
+					nil,
+					"↳ " + name,
+					nil,
+					fmt.Sprintf("%s %s × %.2f", formatQty(it.Qty), it.MaterialUnit, it.UnitPrice),
+					nil,
+					it.Cost,
+				}
+				_ = f.SetSheetRow(sheetName, fmt.Sprintf("A%d", rowIdx), &itemRow)
+				rowIdx++
+			}
+		}
+
+		totals := []interface{}{
+			"Итого", nil, nil, nil, nil,
+			md.Materials, md.Rounded, md.Rent, md.Discount, md.Total,
+			fmt.Sprintf("оплачено %.2f, не оплачено %.2f", md.Paid, md.Unpaid),
 		}
+		_ = f.SetSheetRow(sheetName, fmt.Sprintf("A%d", rowIdx), &totals)
 	}
 
-	// активный лист — первый созданный
-	if sheets := f.GetSheetList(); len(sheets) > 0 {
-		if idx, err := f.GetSheetIndex(sheets[0]); err == nil {
-			f.SetActiveSheet(idx)
+	// Скидки по всем мастерам — отдельным листом
+	discountRows, err := b.cons.ListDiscountsReport(ctx, from, toExclusive)
+	if err != nil {
+		return nil, grand, err
+	}
+	if len(discountRows) > 0 {
+		const sheetName = "Скидки"
+		if _, err := f.NewSheet(sheetName); err != nil {
+			return nil, grand, err
+		}
+		header := []interface{}{"Дата", "Мастер", "Сессия", "Скидка", "На что", "Сумма"}
+		if err := f.SetSheetRow(sheetName, "A1", &header); err != nil {
+			return nil, grand, err
+		}
+		var sum float64
+		for i, r := range discountRows {
+			row := []interface{}{
+				r.CreatedAt.Format("02.01.2006 15:04"),
+				r.Username,
+				r.SessionID,
+				r.Title,
+				discountTargetLabel(r.Target),
+				r.Amount,
+			}
+			if err := f.SetSheetRow(sheetName, fmt.Sprintf("A%d", i+2), &row); err != nil {
+				return nil, grand, err
+			}
+			sum += r.Amount
+		}
+		total := []interface{}{"Итого", nil, nil, nil, nil, sum}
+		if err := f.SetSheetRow(sheetName, fmt.Sprintf("A%d", len(discountRows)+2), &total); err != nil {
+			return nil, grand, err
 		}
 	}
 
-	filename := fmt.Sprintf("rent_materials_%s_%s.xlsx",
-		from.Format("20060102"),
-		toExclusive.Add(-24*time.Hour).Format("20060102"),
-	)
+	// активный лист — сводка
+	if idx, err := f.GetSheetIndex(summarySheet); err == nil {
+		f.SetActiveSheet(idx)
+	}
 
 	var buf bytes.Buffer
 	if err := f.Write(&buf); err != nil {
-		return err
+		return nil, grand, err
+	}
+	return buf.Bytes(), grand, nil
+}
+
+// reportUnitLabel — единица аренды в отчёте.
+func reportUnitLabel(unit string) string {
+	switch unit {
+	case "hour":
+		return "ч"
+	case "day":
+		return "дн"
+	default:
+		return unit
 	}
+}
 
-	doc := tgbotapi.FileBytes{
-		Name:  filename,
-		Bytes: buf.Bytes(),
+// reportSessionKind — тип сессии: помещение, аренда без помещения или клиент студии.
+func reportSessionKind(s consumption.MasterSessionReport) string {
+	switch {
+	case s.StudioClient:
+		return "Клиент студии"
+	case s.Place == "no_rent" || s.Unit == "none":
+		return "Без аренды"
+	case len(s.Items) == 0:
+		return placeLabel(s.Place) + " (без материалов)"
+	default:
+		return placeLabel(s.Place)
 	}
-	msg := tgbotapi.NewDocument(chatID, doc)
-	msg.Caption = "Отчёт по аренде и расходам материалов по мастерам"
+}
 
-	b.send(msg)
-	return nil
+// reportSessionQty — количество часов/дней аренды сессии ("—" без аренды).
+func reportSessionQty(s consumption.MasterSessionReport) string {
+	if s.StudioClient || s.Place == "no_rent" || s.Unit == "none" || s.Qty == 0 {
+		return "—"
+	}
+	return fmt.Sprintf("%d %s", s.Qty, reportUnitLabel(s.Unit))
+}
+
+// reportRentPartsText — разбивка аренды по абонементу: «абон. 3 ч (план 20); 2 ч без абон.».
+func reportRentPartsText(s consumption.MasterSessionReport) string {
+	var parts []string
+	for _, p := range s.RentParts {
+		if p.WithSub {
+			parts = append(parts, fmt.Sprintf("абон. %d %s (план %d)", p.Qty, reportUnitLabel(s.Unit), p.PlanLimit))
+		} else if len(s.RentParts) > 1 {
+			parts = append(parts, fmt.Sprintf("%d %s без абон.", p.Qty, reportUnitLabel(s.Unit)))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// invoiceStatusLabel — статус оплаты счёта сессии.
+func invoiceStatusLabel(status string) string {
+	switch status {
+	case "pending":
+		return "не оплачен"
+	case "paid":
+		return "оплачен"
+	case "canceled":
+		return "отменён"
+	default:
+		return "—"
+	}
+}
+
+// parsePriceValidFrom разбирает дату вступления цены в силу (время салона, loc).
+func parsePriceValidFrom(s string, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	var lastErr error
+	for _, layout := range []string{"02.01.2006 15:04", "02.01.2006", "2006-01-02 15:04", "2006-01-02", "01-02-06"} {
+		t, err := time.ParseInLocation(layout, s, loc)
+		if err == nil {
+			return t, nil
+		}
+		lastErr = err
+	}
+	return time.Time{}, lastErr
+}
+
+// scheduledPricesLabel — запланированные цены материала одной строкой: «120.00 с 01.12.2025 00:00».
+func (b *Bot) scheduledPricesLabel(ctx context.Context, matID int64) string {
+	list, err := b.materials.ListPrices(ctx, matID, 20)
+	if err != nil {
+		return ""
+	}
+	now := b.now()
+	var parts []string
+	for i := len(list) - 1; i >= 0; i-- {
+		p := list[i]
+		if !p.Scheduled(now) {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%.2f с %s", p.Price, p.ValidFrom.In(b.loc).Format("02.01.2006 15:04")))
+	}
+	return strings.Join(parts, "; ")
 }
@@ -0,0 +1,366 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Spok95/beauty-bot/internal/dialog"
+	"github.com/Spok95/beauty-bot/internal/domain/users"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func specializationLabel(s users.Specialization) string {
+	switch s {
+	case users.SpecHair:
+		return "Волосы"
+	case users.SpecNails:
+		return "Ногти"
+	case users.SpecBrows:
+		return "Брови"
+	default:
+		return string(s)
+	}
+}
+
+func specializationsLabel(list []users.Specialization) string {
+	if len(list) == 0 {
+		return "—"
+	}
+	out := make([]string, 0, len(list))
+	for _, s := range list {
+		out = append(out, specializationLabel(s))
+	}
+	return strings.Join(out, ", ")
+}
+
+func placeLabel(place string) string {
+	switch place {
+	case "hall":
+		return "Общий зал"
+	case "cabinet":
+		return "Кабинет"
+	case "":
+		return "—"
+	default:
+		return place
+	}
+}
+
+// profileOf — профиль пользователя; при ошибке — пустой, чтобы экраны не падали.
+func (b *Bot) profileOf(ctx context.Context, u *users.User) *users.Profile {
+	if u == nil {
+		return &users.Profile{}
+	}
+	p, err := b.users.GetProfile(ctx, u.ID)
+	if err != nil || p == nil {
+		return &users.Profile{UserID: u.ID}
+	}
+	return p
+}
+
+// userFullName — ФИО из профиля, иначе отображаемое имя.
+func (b *Bot) userFullName(ctx context.Context, u *users.User) string {
+	if u == nil {
+		return ""
+	}
+	if name := b.profileOf(ctx, u).FullName(); name != "" {
+		return name
+	}
+	return userDisplayName(u)
+}
+
+// profileSummary — блок профиля для карточек; заметки админа — только при withNotes.
+func (b *Bot) profileSummary(ctx context.Context, p *users.Profile, withNotes bool) string {
+	var sb strings.Builder
+	if p.LastName != "" || p.FirstName != "" {
+		_, _ = fmt.Fprintf(&sb, "Фамилия: %s\nИмя: %s\n", orDash(p.LastName), orDash(p.FirstName))
+		if p.MiddleName != "" {
+			_, _ = fmt.Fprintf(&sb, "Отчество: %s\n", p.MiddleName)
+		}
+	}
+	_, _ = fmt.Fprintf(&sb, "Телефон: %s\n", orDash(p.Phone))
+	_, _ = fmt.Fprintf(&sb, "Специализация: %s\n", specializationsLabel(p.Specializations))
+	_, _ = fmt.Fprintf(&sb, "Помещение по умолчанию: %s\n", placeLabel(p.DefaultPlace))
+	whName := "—"
+	if p.DefaultWarehouseID != nil {
+		if wh, err := b.catalog.GetWarehouseByID(ctx, *p.DefaultWarehouseID); err == nil && wh != nil {
+			whName = wh.Name
+		}
+	}
+	_, _ = fmt.Fprintf(&sb, "Склад по умолчанию: %s\n", whName)
+	_, _ = fmt.Fprintf(&sb, "Условия аренды: %s\n", orDash(p.RentTerms))
+	if p.PhotoFileID != "" {
+		sb.WriteString("Фото: есть\n")
+	}
+	if withNotes && p.AdminNotes != "" {
+		_, _ = fmt.Fprintf(&sb, "📝 Заметки: %s\n", p.AdminNotes)
+	}
+	return sb.String()
+}
+
+func orDash(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "—"
+	}
+	return s
+}
+
+// showAdminUserProfile — редактор профиля пользователя (админ).
+func (b *Bot) showAdminUserProfile(ctx context.Context, chatID int64, editMsgID int, userID int64, listPayload dialog.Payload) {
+	u, err := b.users.GetByID(ctx, userID)
+	if err != nil || u == nil {
+		b.editTextAndClear(chatID, editMsgID, "Пользователь не найден")
+		return
+	}
+	p := b.profileOf(ctx, u)
+
+	text := fmt.Sprintf("📇 Профиль: %s\n\n%s", b.userFullName(ctx, u), b.profileSummary(ctx, p, true))
+
+	id := u.ID
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✏️ ФИО", fmt.Sprintf("adm:usr:rn:%d", id)),
+			tgbotapi.NewInlineKeyboardButtonData("📞 Телефон", fmt.Sprintf("adm:usr:pf:f:%d:%s", id, users.ProfilePhone)),
+		),
+	}
+
+	specRow := []tgbotapi.InlineKeyboardButton{}
+	for _, s := range users.AllSpecializations {
+		mark := "▫️ "
+		if p.HasSpecialization(s) {
+			mark = "✅ "
+		}
+		specRow = append(specRow, tgbotapi.NewInlineKeyboardButtonData(mark+specializationLabel(s),
+			fmt.Sprintf("adm:usr:pf:spec:%d:%s", id, s)))
+	}
+	rows = append(rows, specRow)
+
+	placeRow := []tgbotapi.InlineKeyboardButton{}
+	for _, pl := range []string{"hall", "cabinet", ""} {
+		label := placeLabel(pl)
+		code := pl
+		if pl == "" {
+			label, code = "Без умолчания", "none"
+		}
+		if p.DefaultPlace == pl {
+			label = "• " + label
+		}
+		placeRow = append(placeRow, tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("adm:usr:pf:place:%d:%s", id, code)))
+	}
+	rows = append(rows, placeRow)
+
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🏬 Склад по умолчанию", fmt.Sprintf("adm:usr:pf:wh:%d", id)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📄 Условия аренды", fmt.Sprintf("adm:usr:pf:f:%d:%s", id, users.ProfileRentTerms)),
+			tgbotapi.NewInlineKeyboardButtonData("📝 Заметки", fmt.Sprintf("adm:usr:pf:f:%d:%s", id, users.ProfileAdminNotes)),
+		),
+	)
+	photoRow := []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("🖼 Загрузить фото", fmt.Sprintf("adm:usr:pf:f:%d:%s", id, users.ProfilePhoto)),
+	}
+	if p.PhotoFileID != "" {
+		photoRow = append(photoRow, tgbotapi.NewInlineKeyboardButtonData("👁 Показать фото", fmt.Sprintf("adm:usr:pf:photo:%d", id)))
+	}
+	rows = append(rows, photoRow)
+	rows = append(rows, navKeyboard(true, true).InlineKeyboard[0])
+
+	payload := dialog.Payload{"user_id": id}
+	if listPayload != nil {
+		payload["query"] = listPayload["query"]
+		payload["page"] = listPayload["page"]
+	}
+	_ = b.states.Set(ctx, chatID, dialog.StateAdmUserProfile, payload)
+	b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, editMsgID, text, tgbotapi.NewInlineKeyboardMarkup(rows...)))
+}
+
+// profileFieldPrompt — подсказка для ввода поля профиля.
+func profileFieldPrompt(field users.ProfileField) string {
+	switch field {
+	case users.ProfilePhone:
+		return "Введите телефон, например +7 900 123-45-67. «-» — очистить."
+	case users.ProfileRentTerms:
+		return "Введите условия договора/аренды (ставка, скидка, срок). «-» — очистить."
+	case users.ProfileAdminNotes:
+		return "Введите заметку (видна только админам). «-» — очистить."
+	case users.ProfilePhoto:
+		return "Пришлите фото мастера. «-» — удалить текущее."
+	default:
+		return "Введите значение."
+	}
+}
+
+// handleAdminProfileCallback — колбэки редактора профиля (data без префикса adm:usr:pf:).
+func (b *Bot) handleAdminProfileCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, data string, listPayload dialog.Payload) {
+	chatID := cb.Message.Chat.ID
+	msgID := cb.Message.MessageID
+
+	// формат: <действие>:<user_id>[:<аргумент>] либо просто <user_id>
+	parts := strings.Split(data, ":")
+	if len(parts) == 1 {
+		id, _ := strconv.ParseInt(parts[0], 10, 64)
+		b.showAdminUserProfile(ctx, chatID, msgID, id, listPayload)
+		_ = b.answerCallback(cb, "Ок", false)
+		return
+	}
+	action := parts[0]
+	id, _ := strconv.ParseInt(parts[1], 10, 64)
+	arg := ""
+	if len(parts) > 2 {
+		arg = parts[2]
+	}
+
+	switch action {
+	case "f":
+		field := users.ProfileField(arg)
+		p := dialog.Payload{"user_id": id, "field": string(field), "query": listPayload["query"], "page": listPayload["page"]}
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmUserProfileField, p)
+		b.editTextWithNav(chatID, msgID, profileFieldPrompt(field))
+		_ = b.answerCallback(cb, "Ок", false)
+
+	case "spec":
+		u, _ := b.users.GetByID(ctx, id)
+		if u == nil {
+			_ = b.answerCallback(cb, "Пользователь не найден", true)
+			return
+		}
+		p := b.profileOf(ctx, u)
+		toggled := users.Specialization(arg)
+		var next []users.Specialization
+		for _, s := range users.AllSpecializations {
+			on := p.HasSpecialization(s)
+			if s == toggled {
+				on = !on
+			}
+			if on {
+				next = append(next, s)
+			}
+		}
+		if err := b.users.SetSpecializations(ctx, id, next); err != nil {
+			_ = b.answerCallback(cb, "Ошибка сохранения", true)
+			return
+		}
+		b.showAdminUserProfile(ctx, chatID, msgID, id, listPayload)
+		_ = b.answerCallback(cb, "Сохранено", false)
+
+	case "place":
+		place := arg
+		if place == "none" {
+			place = ""
+		}
+		if err := b.users.SetProfileField(ctx, id, users.ProfileDefaultPlace, place); err != nil {
+			_ = b.answerCallback(cb, "Ошибка сохранения", true)
+			return
+		}
+		b.showAdminUserProfile(ctx, chatID, msgID, id, listPayload)
+		_ = b.answerCallback(cb, "Сохранено", false)
+
+	case "wh":
+		whs, err := b.catalog.ListWarehouses(ctx)
+		if err != nil {
+			_ = b.answerCallback(cb, "Ошибка загрузки складов", true)
+			return
+		}
+		rows := [][]tgbotapi.InlineKeyboardButton{}
+		for _, w := range whs {
+			if !w.Active {
+				continue
+			}
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(w.Name, fmt.Sprintf("adm:usr:pf:whset:%d:%d", id, w.ID)),
+			))
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Без умолчания", fmt.Sprintf("adm:usr:pf:whset:%d:0", id)),
+		))
+		rows = append(rows, navKeyboard(true, true).InlineKeyboard[0])
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, msgID, "Склад по умолчанию для расхода:",
+			tgbotapi.NewInlineKeyboardMarkup(rows...)))
+		_ = b.answerCallback(cb, "Ок", false)
+
+	case "whset":
+		whID, _ := strconv.ParseInt(arg, 10, 64)
+		var ptr *int64
+		if whID > 0 {
+			ptr = &whID
+		}
+		if err := b.users.SetDefaultWarehouse(ctx, id, ptr); err != nil {
+			_ = b.answerCallback(cb, "Ошибка сохранения", true)
+			return
+		}
+		b.showAdminUserProfile(ctx, chatID, msgID, id, listPayload)
+		_ = b.answerCallback(cb, "Сохранено", false)
+
+	case "photo":
+		u, _ := b.users.GetByID(ctx, id)
+		p := b.profileOf(ctx, u)
+		if p.PhotoFileID == "" {
+			_ = b.answerCallback(cb, "Фото не загружено", true)
+			return
+		}
+		photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileID(p.PhotoFileID))
+		photo.Caption = b.userFullName(ctx, u)
+		b.send(photo)
+		_ = b.answerCallback(cb, "Ок", false)
+
+	default:
+		_ = b.answerCallback(cb, "Неизвестная команда", true)
+	}
+}
+
+// handleAdminProfileInput — ввод текстового поля профиля или фото.
+func (b *Bot) handleAdminProfileInput(ctx context.Context, msg *tgbotapi.Message, st *dialog.Item) {
+	chatID := msg.Chat.ID
+	userID := payloadInt64(st.Payload["user_id"])
+	field := users.ProfileField(payloadString(st.Payload, "field"))
+	text := strings.TrimSpace(msg.Text)
+	reset := text == "-"
+
+	var value string
+	switch field {
+	case users.ProfilePhoto:
+		switch {
+		case reset:
+		case len(msg.Photo) > 0:
+			value = msg.Photo[len(msg.Photo)-1].FileID
+		default:
+			b.send(tgbotapi.NewMessage(chatID, "Пришлите фото (не файлом) или «-», чтобы удалить."))
+			return
+		}
+	case users.ProfilePhone:
+		if !reset {
+			phone, err := users.NormalizePhone(text)
+			if err != nil {
+				b.send(tgbotapi.NewMessage(chatID, "Не похоже на номер телефона. Пример: +7 900 123-45-67."))
+				return
+			}
+			value = phone
+		}
+	default:
+		if text == "" {
+			b.send(tgbotapi.NewMessage(chatID, "Пустое значение. Введите текст или «-», чтобы очистить."))
+			return
+		}
+		if !reset {
+			value = text
+		}
+	}
+
+	if err := b.users.SetProfileField(ctx, userID, field, value); err != nil {
+		b.send(tgbotapi.NewMessage(chatID, "Не удалось сохранить: "+err.Error()))
+		return
+	}
+
+	m := tgbotapi.NewMessage(chatID, "Сохранено.")
+	m.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("📇 К профилю", fmt.Sprintf("adm:usr:pf:%d", userID)),
+	))
+	b.send(m)
+	_ = b.states.Set(ctx, chatID, dialog.StateAdmUserProfile, dialog.Payload{
+		"user_id": userID, "query": st.Payload["query"], "page": st.Payload["page"],
+	})
+}
@@ -1,6 +1,13 @@
 package bot
 
-import tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Spok95/beauty-bot/internal/dialog"
+	"github.com/Spok95/beauty-bot/internal/domain/users"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
 
 func (b *Bot) askFIO(chatID int64) {
 	kb := tgbotapi.NewInlineKeyboardMarkup(
@@ -8,7 +15,100 @@ func (b *Bot) askFIO(chatID int64) {
 			tgbotapi.NewInlineKeyboardButtonData("✖️ Отменить", "nav:cancel"),
 		),
 	)
-	m := tgbotapi.NewMessage(chatID, "Введите, пожалуйста, ФИО одной строкой.")
+	m := tgbotapi.NewMessage(chatID, "Введите, пожалуйста, ФИО одной строкой: фамилия, имя и (по желанию) отчество.")
 	m.ReplyMarkup = kb
 	b.send(m)
 }
+
+// askPhone — запрос телефона: кнопка отправки контакта или ручной ввод.
+func (b *Bot) askPhone(chatID int64) {
+	kb := tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButtonContact("📱 Отправить мой номер")),
+	)
+	kb.ResizeKeyboard = true
+	kb.OneTimeKeyboard = true
+	m := tgbotapi.NewMessage(chatID, "Укажите номер телефона: нажмите кнопку ниже или введите его вручную, например +7 900 123-45-67.")
+	m.ReplyMarkup = kb
+	b.send(m)
+}
+
+// registrationSpecKeyboard — мультивыбор специализаций мастера при регистрации.
+func registrationSpecKeyboard(selected []string) tgbotapi.InlineKeyboardMarkup {
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	row := []tgbotapi.InlineKeyboardButton{}
+	for _, s := range users.AllSpecializations {
+		label := "▫️ " + specializationLabel(s)
+		if containsString(selected, string(s)) {
+			label = "✅ " + specializationLabel(s)
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(label, "reg:spec:"+string(s)))
+	}
+	rows = append(rows, row)
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Готово", "reg:spec:done"),
+	))
+	rows = append(rows, navKeyboard(true, true).InlineKeyboard[0])
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// registrationAfterRole — шаг анкеты после роли: специализации мастера или подтверждение.
+func registrationAfterRole(p dialog.Payload) (dialog.State, string, tgbotapi.InlineKeyboardMarkup) {
+	if users.Role(payloadString(p, "role")) == users.RoleMaster {
+		return dialog.StateAwaitSpec, "Выберите специализацию (можно несколько):", registrationSpecKeyboard(payloadStrings(p, "specs"))
+	}
+	return dialog.StateAwaitConfirm, registrationConfirmText(p), confirmKeyboard()
+}
+
+// registrationConfirmText — сводка анкеты перед отправкой заявки.
+func registrationConfirmText(p dialog.Payload) string {
+	var sb strings.Builder
+	sb.WriteString("Проверьте данные:\n")
+	_, _ = fmt.Fprintf(&sb, "— ФИО: %s\n", payloadString(p, "fio"))
+	if phone := payloadString(p, "phone"); phone != "" {
+		_, _ = fmt.Fprintf(&sb, "— Телефон: %s\n", phone)
+	}
+	role := users.Role(payloadString(p, "role"))
+	_, _ = fmt.Fprintf(&sb, "— Роль: %s\n", roleLabel(role))
+	if role == users.RoleMaster {
+		_, _ = fmt.Fprintf(&sb, "— Специализация: %s\n", specializationsLabel(payloadSpecs(p)))
+	}
+	if payloadString(p, "invite_code") != "" {
+		sb.WriteString("— По приглашению\n")
+	}
+	sb.WriteString("\nОтправить заявку администратору?")
+	return sb.String()
+}
+
+// payloadStrings — список строк из payload (после JSON это []any).
+func payloadStrings(p dialog.Payload, key string) []string {
+	switch v := p[key].(type) {
+	case []string:
+		return v
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, x := range v {
+			if s, ok := x.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func payloadSpecs(p dialog.Payload) []users.Specialization {
+	var out []users.Specialization
+	for _, s := range payloadStrings(p, "specs") {
+		out = append(out, users.Specialization(s))
+	}
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
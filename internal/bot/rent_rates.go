@@ -0,0 +1,401 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Spok95/beauty-bot/internal/dialog"
+	"github.com/Spok95/beauty-bot/internal/domain/consumption"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ratesSet — набор ступеней из payload экрана "Установка тарифов".
+func ratesSet(p dialog.Payload) (place, unit string, withSub bool) {
+	place = payloadString(p, "place")
+	unit = payloadString(p, "unit")
+	withSub, _ = p["with_sub"].(bool)
+	return place, unit, withSub
+}
+
+func ratesSetTitle(place, unit string, withSub bool) string {
+	return fmt.Sprintf("%s / %s (%s)",
+		map[string]string{"hall": "Зал", "cabinet": "Кабинет"}[place],
+		map[string]string{"hour": "час", "day": "день"}[unit],
+		map[bool]string{true: "с абонементом", false: "без абонемента"}[withSub],
+	)
+}
+
+func tierRateLine(r consumption.TierRate) string {
+	status := "🟢"
+	if !r.Active {
+		status = "🚫"
+	}
+	return fmt.Sprintf("%s %s: порог %.0f; с мат. %.2f; свои %.2f",
+		status, consumption.QtyRange{Min: r.MinQty, Max: r.MaxQty}, r.Threshold, r.PriceWith, r.PriceOwn)
+}
+
+func tierGapsText(gaps []consumption.QtyRange) string {
+	if len(gaps) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(gaps))
+	for _, g := range gaps {
+		parts = append(parts, g.String())
+	}
+	return "⚠️ Нет тарифа для количества: " + strings.Join(parts, ", ")
+}
+
+// showRatesList — ступени набора place/unit/with_sub с кнопками карточек.
+func (b *Bot) showRatesList(ctx context.Context, chatID int64, editMsgID *int, payload dialog.Payload) {
+	place, unit, withSub := ratesSet(payload)
+	rates, err := b.cons.ListRates(ctx, b.now(), place, unit, withSub)
+	if err != nil {
+		b.send(tgbotapi.NewMessage(chatID, "Ошибка загрузки тарифов"))
+		return
+	}
+
+	lines := []string{"Тарифы: " + ratesSetTitle(place, unit, withSub)}
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for _, r := range rates {
+		lines = append(lines, tierRateLine(r))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(tierRateLine(r), fmt.Sprintf("rates:r:%d", r.ID)),
+		))
+	}
+	if len(rates) == 0 {
+		lines = append(lines, "Ступеней нет.")
+	}
+	if gaps := tierGapsText(consumption.TierGaps(rates)); gaps != "" {
+		lines = append(lines, "", gaps)
+	}
+	lines = append(lines, "", "Нажмите на ступень, чтобы изменить, отключить или удалить её.")
+
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("➕ Добавить ступень", "rates:add")),
+		navKeyboard(true, true).InlineKeyboard[0],
+	)
+	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	text := strings.Join(lines, "\n")
+
+	delete(payload, "rate_id")
+	_ = b.states.Set(ctx, chatID, dialog.StateAdmRatesList, payload)
+	if editMsgID != nil {
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, *editMsgID, text, kb))
+		return
+	}
+	m := tgbotapi.NewMessage(chatID, text)
+	m.ReplyMarkup = kb
+	b.send(m)
+}
+
+// showRateCard — карточка ступени с действиями.
+func (b *Bot) showRateCard(ctx context.Context, chatID int64, editMsgID int, payload dialog.Payload, id int64) {
+	r, err := b.cons.GetRate(ctx, b.now(), id)
+	if err != nil || r == nil {
+		b.editTextAndClear(chatID, editMsgID, "Ступень не найдена")
+		return
+	}
+
+	text := fmt.Sprintf(
+		"Ступень: %s\n— Диапазон: %s\n— Порог: %.0f\n— Цена с материалами: %.2f\n— Цена со своими: %.2f\n— Статус: %s",
+		ratesSetTitle(r.Place, r.Unit, r.WithSub),
+		consumption.QtyRange{Min: r.MinQty, Max: r.MaxQty},
+		r.Threshold, r.PriceWith, r.PriceOwn,
+		map[bool]string{true: "🟢 активна", false: "🚫 отключена"}[r.Active],
+	)
+
+	toggle := tgbotapi.NewInlineKeyboardButtonData("🚫 Отключить", fmt.Sprintf("rates:act:%d", r.ID))
+	if !r.Active {
+		toggle = tgbotapi.NewInlineKeyboardButtonData("🟢 Включить", fmt.Sprintf("rates:act:%d", r.ID))
+	}
+	kb := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✏️ Изменить", fmt.Sprintf("rates:edit:%d", r.ID)),
+			toggle,
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить", fmt.Sprintf("rates:del:%d", r.ID)),
+		),
+		navKeyboard(true, true).InlineKeyboard[0],
+	)
+
+	delete(payload, "rate_id")
+	payload["card_rate_id"] = r.ID
+	_ = b.states.Set(ctx, chatID, dialog.StateAdmRatesCard, payload)
+	b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, editMsgID, text, kb))
+}
+
+// rateChangeGaps — количества, которые останутся без тарифа после изменения ступени
+// (разрывы, которых в наборе раньше не было). next == nil — ступень будет отключена или удалена.
+func (b *Bot) rateChangeGaps(ctx context.Context, place, unit string, withSub bool, old *consumption.TierRate, next *consumption.TierRate) ([]consumption.QtyRange, error) {
+	rates, err := b.cons.ListRates(ctx, b.now(), place, unit, withSub)
+	if err != nil {
+		return nil, err
+	}
+	after := make([]consumption.TierRate, 0, len(rates)+1)
+	for _, r := range rates {
+		if old != nil && r.ID == old.ID {
+			continue
+		}
+		after = append(after, r)
+	}
+	if next != nil {
+		after = append(after, *next)
+	}
+	return consumption.NewTierGaps(rates, after), nil
+}
+
+func rateGapRefusal(gaps []consumption.QtyRange) string {
+	parts := make([]string, 0, len(gaps))
+	for _, g := range gaps {
+		parts = append(parts, g.String())
+	}
+	return "После изменения не будет тарифа для количества: " + strings.Join(parts, ", ")
+}
+
+// rateChangeWarnings — предупреждение к изменению ступени: абонементы, чей лимит плана
+// попадает в старый или новый диапазон ступени. next == nil — ступень будет отключена или удалена.
+func (b *Bot) rateChangeWarnings(ctx context.Context, place, unit string, withSub bool, old *consumption.TierRate, next *consumption.TierRate) string {
+	if !withSub {
+		return ""
+	}
+	var ranges []consumption.QtyRange
+	if old != nil {
+		ranges = append(ranges, consumption.QtyRange{Min: old.MinQty, Max: old.MaxQty})
+	}
+	if next != nil {
+		ranges = append(ranges, consumption.QtyRange{Min: next.MinQty, Max: next.MaxQty})
+	}
+	return b.affectedSubscriptionsText(ctx, place, unit, ranges)
+}
+
+// affectedSubscriptionsText — список действующих абонементов, чей лимит плана попадает в диапазоны.
+func (b *Bot) affectedSubscriptionsText(ctx context.Context, place, unit string, ranges []consumption.QtyRange) string {
+	const maxLines = 15
+
+	month := b.now().Format("2006-01")
+	seen := map[int64]bool{}
+	var lines []string
+	total := 0
+	for _, rg := range ranges {
+		list, err := b.subs.ListActiveByPlan(ctx, place, unit, month, rg.Min, rg.Max)
+		if err != nil {
+			b.log.Warn("list affected subscriptions failed", "err", err)
+			continue
+		}
+		for _, s := range list {
+			if seen[s.ID] {
+				continue
+			}
+			seen[s.ID] = true
+			total++
+			if len(lines) == maxLines {
+				continue
+			}
+			name := fmt.Sprintf("id %d", s.UserID)
+			if u, err := b.users.GetByID(ctx, s.UserID); err == nil && u != nil {
+				name = b.userFullName(ctx, u)
+			}
+			lines = append(lines, fmt.Sprintf("• %s — %s, план %d, осталось %d", name, s.Month, s.PlanLimit, s.TotalQty-s.UsedQty))
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+	if total > len(lines) {
+		lines = append(lines, fmt.Sprintf("… и ещё %d", total-len(lines)))
+	}
+	return fmt.Sprintf("⚠️ Изменение затронет действующие абонементы (%d):\n%s", total, strings.Join(lines, "\n"))
+}
+
+// rateEditHint — подсказка с текущим значением при изменении ступени.
+func rateEditHint(p dialog.Payload, key string) string {
+	if payloadInt64(p["rate_id"]) == 0 {
+		return ""
+	}
+	var cur string
+	switch v := p[key].(type) {
+	case nil:
+		cur = "∞"
+	case float64:
+		if key == "min" || key == "max" || key == "thr" {
+			cur = fmt.Sprintf("%.0f", v)
+		} else {
+			cur = fmt.Sprintf("%.2f", v)
+		}
+	}
+	return fmt.Sprintf("\nСейчас: %s. Отправьте «.», чтобы оставить без изменений.", cur)
+}
+
+// keepRateValue — при изменении ступени «.» оставляет прежнее значение.
+func keepRateValue(p dialog.Payload, text string) bool {
+	return text == "." && payloadInt64(p["rate_id"]) > 0
+}
+
+// rateFromPayload — ступень из введённых значений.
+func rateFromPayload(p dialog.Payload) consumption.TierRate {
+	place, unit, withSub := ratesSet(p)
+	r := consumption.TierRate{
+		ID:        payloadInt64(p["rate_id"]),
+		Place:     place,
+		Unit:      unit,
+		WithSub:   withSub,
+		MinQty:    payloadInt(p, "min"),
+		Threshold: payloadFloat(p, "thr"),
+		PriceWith: payloadFloat(p, "pwith"),
+		PriceOwn:  payloadFloat(p, "pown"),
+		Active:    true,
+	}
+	if p["max"] != nil {
+		m := payloadInt(p, "max")
+		r.MaxQty = &m
+	}
+	return r
+}
+
+// handleRatesCallback — действия со ступенями (data без префикса "rates:").
+func (b *Bot) handleRatesCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, data string) {
+	chatID := cb.Message.Chat.ID
+	msgID := cb.Message.MessageID
+
+	st, _ := b.states.Get(ctx, chatID)
+	payload := dialog.Payload{}
+	if st != nil && st.Payload != nil {
+		payload = st.Payload
+	}
+
+	cmd, idStr, _ := strings.Cut(data, ":")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		_ = b.answerCallback(cb, "Некорректные данные", true)
+		return
+	}
+
+	switch cmd {
+	case "r":
+		b.showRateCard(ctx, chatID, msgID, payload, id)
+
+	case "edit":
+		r, err := b.cons.GetRate(ctx, b.now(), id)
+		if err != nil || r == nil {
+			_ = b.answerCallback(cb, "Ступень не найдена", true)
+			return
+		}
+		payload["rate_id"] = r.ID
+		payload["min"] = float64(r.MinQty)
+		payload["max"] = nil
+		if r.MaxQty != nil {
+			payload["max"] = float64(*r.MaxQty)
+		}
+		payload["thr"] = r.Threshold
+		payload["pwith"] = r.PriceWith
+		payload["pown"] = r.PriceOwn
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmRatesCreateMin, payload)
+		b.editTextWithNav(chatID, msgID,
+			"Введите минимальное значение диапазона (целое число, например 1)"+rateEditHint(payload, "min"))
+
+	case "act":
+		r, err := b.cons.GetRate(ctx, b.now(), id)
+		if err != nil || r == nil {
+			_ = b.answerCallback(cb, "Ступень не найдена", true)
+			return
+		}
+		newID, err := b.cons.SetRateActive(ctx, b.now(), id, !r.Active)
+		switch {
+		case errors.Is(err, consumption.ErrTierOverlap):
+			_ = b.answerCallback(cb, "Нельзя включить: диапазон пересекается с активной ступенью", true)
+			return
+		case errors.Is(err, consumption.ErrTierGap):
+			_ = b.answerCallback(cb, "Нельзя отключить: для части количеств не останется тарифа", true)
+			return
+		case err != nil:
+			_ = b.answerCallback(cb, "Ошибка сохранения", true)
+			return
+		}
+
+		var next *consumption.TierRate // nil — ступень отключена
+		if !r.Active {
+			n := *r
+			n.Active = true
+			next = &n
+		}
+		if warn := b.rateChangeWarnings(ctx, r.Place, r.Unit, r.WithSub, r, next); warn != "" {
+			b.send(tgbotapi.NewMessage(chatID, warn))
+		}
+		b.showRateCard(ctx, chatID, msgID, payload, newID)
+
+	case "del":
+		r, err := b.cons.GetRate(ctx, b.now(), id)
+		if err != nil || r == nil {
+			_ = b.answerCallback(cb, "Ступень не найдена", true)
+			return
+		}
+		title := fmt.Sprintf("%s: %s", ratesSetTitle(r.Place, r.Unit, r.WithSub), consumption.QtyRange{Min: r.MinQty, Max: r.MaxQty})
+		if r.Active {
+			gaps, err := b.rateChangeGaps(ctx, r.Place, r.Unit, r.WithSub, r, nil)
+			if err != nil {
+				_ = b.answerCallback(cb, "Ошибка загрузки тарифов", true)
+				return
+			}
+			if len(gaps) > 0 {
+				kb := tgbotapi.NewInlineKeyboardMarkup(
+					tgbotapi.NewInlineKeyboardRow(
+						tgbotapi.NewInlineKeyboardButtonData("🔗 Объединить с соседней", fmt.Sprintf("rates:merge:%d", r.ID)),
+					),
+					tgbotapi.NewInlineKeyboardRow(
+						tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", fmt.Sprintf("rates:r:%d", r.ID)),
+					),
+				)
+				b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, msgID,
+					"Нельзя просто удалить ступень "+title+".\n"+rateGapRefusal(gaps)+
+						"\n\nМожно объединить её с соседней ступенью: та займёт освободившийся диапазон.", kb))
+				break
+			}
+		}
+		text := fmt.Sprintf("Удалить ступень %s?", title)
+		if r.Active {
+			if warn := b.rateChangeWarnings(ctx, r.Place, r.Unit, r.WithSub, r, nil); warn != "" {
+				text += "\n\n" + warn
+			}
+		}
+		kb := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить", fmt.Sprintf("rates:delok:%d", r.ID)),
+				tgbotapi.NewInlineKeyboardButtonData("Отмена", fmt.Sprintf("rates:r:%d", r.ID)),
+			),
+		)
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, msgID, text, kb))
+
+	case "merge":
+		err := b.cons.MergeRate(ctx, b.now(), id)
+		if errors.Is(err, consumption.ErrTierGap) {
+			_ = b.answerCallback(cb, "Нет соседней ступени, которая примыкает к этой", true)
+			return
+		}
+		if err != nil {
+			_ = b.answerCallback(cb, "Не удалось объединить ступени", true)
+			return
+		}
+		b.showRatesList(ctx, chatID, &msgID, payload)
+		_ = b.answerCallback(cb, "Ступени объединены", false)
+		return
+
+	case "delok":
+		err := b.cons.DeleteRate(ctx, b.now(), id)
+		if errors.Is(err, consumption.ErrTierGap) {
+			_ = b.answerCallback(cb, "Нельзя удалить: для части количеств не останется тарифа", true)
+			return
+		}
+		if err != nil {
+			_ = b.answerCallback(cb, "Не удалось удалить ступень", true)
+			return
+		}
+		b.showRatesList(ctx, chatID, &msgID, payload)
+		_ = b.answerCallback(cb, "Ступень удалена", false)
+		return
+	}
+	_ = b.answerCallback(cb, "Ок", false)
+}
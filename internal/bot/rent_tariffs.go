@@ -0,0 +1,278 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Spok95/beauty-bot/internal/dialog"
+	"github.com/Spok95/beauty-bot/internal/domain/consumption"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// rentSimulationDays — за сколько дней пересчитываем прошедшие сессии при сравнении версий.
+const rentSimulationDays = 30
+
+// rentSamples — типовые сессии для сравнения версий тарифов (без абонемента).
+var rentSamples = []struct {
+	Place string
+	Unit  string
+	Qty   int
+	Mats  float64
+}{
+	{"hall", "hour", 3, 300},
+	{"hall", "hour", 3, 0},
+	{"cabinet", "day", 1, 1000},
+	{"cabinet", "day", 1, 0},
+}
+
+func rentRateLabel(r consumption.RentRate) string {
+	maxTxt := "∞"
+	if r.MaxQty != nil {
+		maxTxt = strconv.Itoa(*r.MaxQty)
+	}
+	return fmt.Sprintf("%s/%s, %s, %d–%s",
+		map[string]string{"hall": "Зал", "cabinet": "Кабинет"}[r.Place],
+		map[string]string{"hour": "час", "day": "день"}[r.Unit],
+		map[bool]string{true: "абонемент", false: "без абонемента"}[r.WithSub],
+		r.MinQty, maxTxt)
+}
+
+func rentRateDiffLine(d consumption.RateDiff) string {
+	switch {
+	case d.Old == nil:
+		return fmt.Sprintf("➕ %s: порог %.0f; с мат. %.2f; свои %.2f",
+			rentRateLabel(*d.New), d.New.Threshold, d.New.PriceWith, d.New.PriceOwn)
+	case d.New == nil:
+		return fmt.Sprintf("➖ %s", rentRateLabel(*d.Old))
+	}
+	var changes []string
+	if d.Old.Threshold != d.New.Threshold {
+		changes = append(changes, fmt.Sprintf("порог %.0f → %.0f", d.Old.Threshold, d.New.Threshold))
+	}
+	if d.Old.PriceWith != d.New.PriceWith {
+		changes = append(changes, fmt.Sprintf("с мат. %.2f → %.2f", d.Old.PriceWith, d.New.PriceWith))
+	}
+	if d.Old.PriceOwn != d.New.PriceOwn {
+		changes = append(changes, fmt.Sprintf("свои %.2f → %.2f", d.Old.PriceOwn, d.New.PriceOwn))
+	}
+	if len(changes) == 0 {
+		changes = append(changes, "изменены прочие параметры")
+	}
+	return fmt.Sprintf("✏️ %s: %s", rentRateLabel(*d.New), strings.Join(changes, "; "))
+}
+
+func tariffVersionLabel(v consumption.TariffVersion, now time.Time) string {
+	if v.Status == consumption.TariffDraft || v.ActiveFrom == nil {
+		return fmt.Sprintf("📝 #%d черновик", v.ID)
+	}
+	from := v.ActiveFrom.Format("02.01.2006")
+	switch {
+	case v.ActiveOn(now):
+		return fmt.Sprintf("✅ #%d с %s (действует)", v.ID, from)
+	case v.ActiveTo != nil:
+		return fmt.Sprintf("📁 #%d %s–%s", v.ID, from, v.ActiveTo.Format("02.01.2006"))
+	default:
+		return fmt.Sprintf("⏳ #%d с %s", v.ID, from)
+	}
+}
+
+// showRentTariffVersions — список версий тарифов аренды.
+func (b *Bot) showRentTariffVersions(ctx context.Context, chatID int64, editMsgID *int) {
+	list, err := b.cons.ListTariffVersions(ctx, 15)
+	if err != nil {
+		b.send(tgbotapi.NewMessage(chatID, "Ошибка загрузки версий тарифов"))
+		return
+	}
+
+	now := b.now()
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	for _, v := range list {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(tariffVersionLabel(v, now), fmt.Sprintf("price:rent:ver:%d", v.ID)),
+		))
+	}
+	rows = append(rows, navKeyboard(true, true).InlineKeyboard[0])
+
+	text := "Версии тарифов аренды.\n" +
+		"Загрузка Excel создаёт черновик: его можно сравнить с действующей версией и опубликовать с нужной даты."
+	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	_ = b.states.Set(ctx, chatID, dialog.StatePriceRentVersions, dialog.Payload{})
+	if editMsgID != nil {
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, *editMsgID, text, kb))
+		return
+	}
+	m := tgbotapi.NewMessage(chatID, text)
+	m.ReplyMarkup = kb
+	b.send(m)
+}
+
+// showRentTariffVersion — версия тарифов: отличия от базовой версии, типовые сессии
+// и пересчёт сессий за последние дни по старой и новой версии.
+func (b *Bot) showRentTariffVersion(ctx context.Context, chatID int64, editMsgID *int, versionID int64) {
+	v, err := b.cons.GetTariffVersion(ctx, versionID)
+	if err != nil || v == nil {
+		b.send(tgbotapi.NewMessage(chatID, "Версия тарифов не найдена"))
+		return
+	}
+
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, "Тарифы аренды: %s\n", tariffVersionLabel(*v, b.now()))
+	if v.Comment != "" {
+		_, _ = fmt.Fprintf(&sb, "Комментарий: %s\n", v.Comment)
+	}
+
+	newRates, err := b.cons.ListVersionRates(ctx, v.ID)
+	if err != nil {
+		b.send(tgbotapi.NewMessage(chatID, "Ошибка загрузки ступеней"))
+		return
+	}
+	_, _ = fmt.Fprintf(&sb, "Ступеней: %d\n", len(newRates))
+
+	if v.BaseVersionID > 0 {
+		oldRates, err := b.cons.ListVersionRates(ctx, v.BaseVersionID)
+		if err != nil {
+			b.send(tgbotapi.NewMessage(chatID, "Ошибка загрузки ступеней"))
+			return
+		}
+
+		diff := consumption.DiffRates(oldRates, newRates)
+		_, _ = fmt.Fprintf(&sb, "\nОтличия от версии #%d:\n", v.BaseVersionID)
+		if len(diff) == 0 {
+			sb.WriteString("• изменений нет\n")
+		}
+		const maxLines = 20
+		for i, d := range diff {
+			if i == maxLines {
+				_, _ = fmt.Fprintf(&sb, "… и ещё %d\n", len(diff)-maxLines)
+				break
+			}
+			sb.WriteString(rentRateDiffLine(d) + "\n")
+		}
+
+		sb.WriteString("\nТиповые сессии (без абонемента), аренда было → станет:\n")
+		today := b.now()
+		for _, s := range rentSamples {
+			parts := []consumption.RentSplitPartInput{{Qty: s.Qty, SubLimitForPricing: s.Qty}}
+			oldRent, _, _, _, errOld := b.cons.ComputeRentSplitVersion(ctx, v.BaseVersionID, today, s.Place, s.Unit, s.Mats, parts)
+			newRent, _, _, _, errNew := b.cons.ComputeRentSplitVersion(ctx, v.ID, today, s.Place, s.Unit, s.Mats, parts)
+			oldTxt, newTxt := fmt.Sprintf("%.0f ₽", oldRent), fmt.Sprintf("%.0f ₽", newRent)
+			if errOld != nil {
+				oldTxt = "нет тарифа"
+			}
+			if errNew != nil {
+				newTxt = "нет тарифа"
+			}
+			_, _ = fmt.Fprintf(&sb, "• %s, %d %s, материалы %.0f ₽: %s → %s\n",
+				placeLabel(s.Place), s.Qty, map[string]string{"hour": "ч", "day": "дн"}[s.Unit], s.Mats, oldTxt, newTxt)
+		}
+
+		since := b.now().AddDate(0, 0, -rentSimulationDays)
+		if sim, err := b.cons.SimulateTariffs(ctx, v.BaseVersionID, v.ID, since); err == nil {
+			_, _ = fmt.Fprintf(&sb, "\nСессии за %d дней: %d", rentSimulationDays, sim.Sessions)
+			if sim.Sessions > 0 {
+				_, _ = fmt.Fprintf(&sb, ", аренда %.0f → %.0f ₽", sim.OldRent, sim.NewRent)
+				if sim.OldRent > 0 {
+					_, _ = fmt.Fprintf(&sb, " (%+.1f%%)", (sim.NewRent-sim.OldRent)/sim.OldRent*100)
+				}
+			}
+			if sim.Skipped > 0 {
+				_, _ = fmt.Fprintf(&sb, "\nНе удалось пересчитать: %d (нет подходящей ступени)", sim.Skipped)
+			}
+			sb.WriteString("\n")
+		} else {
+			b.log.Warn("tariff simulation failed", "version_id", v.ID, "err", err)
+		}
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	if v.Status == consumption.TariffDraft {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📅 Опубликовать", fmt.Sprintf("price:rent:ver:pub:%d", v.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить черновик", fmt.Sprintf("price:rent:ver:del:%d", v.ID)),
+		))
+	}
+	rows = append(rows, navKeyboard(true, true).InlineKeyboard[0])
+	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	_ = b.states.Set(ctx, chatID, dialog.StatePriceRentVersion, dialog.Payload{"version_id": v.ID})
+	text := truncateRunes(sb.String(), 4000)
+	if editMsgID != nil {
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, *editMsgID, text, kb))
+		return
+	}
+	m := tgbotapi.NewMessage(chatID, text)
+	m.ReplyMarkup = kb
+	b.send(m)
+}
+
+// handleRentTariffCallback — кнопки версий тарифов (data без префикса "price:rent:").
+func (b *Bot) handleRentTariffCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, data string) {
+	chatID := cb.Message.Chat.ID
+	msgID := cb.Message.MessageID
+
+	switch {
+	case data == "versions":
+		b.showRentTariffVersions(ctx, chatID, &msgID)
+
+	case strings.HasPrefix(data, "ver:pub:"):
+		id, _ := strconv.ParseInt(strings.TrimPrefix(data, "ver:pub:"), 10, 64)
+		_ = b.states.Set(ctx, chatID, dialog.StatePriceRentPublish, dialog.Payload{"version_id": id})
+		b.editTextWithNav(chatID, msgID,
+			"Введите дату начала действия новых тарифов в формате ДД.ММ.ГГГГ.\n"+
+				"0 — с сегодняшнего дня. Действующая версия закроется днём раньше.")
+
+	case strings.HasPrefix(data, "ver:del:"):
+		id, _ := strconv.ParseInt(strings.TrimPrefix(data, "ver:del:"), 10, 64)
+		if err := b.cons.DeleteTariffDraft(ctx, id); err != nil {
+			_ = b.answerCallback(cb, "Черновик уже опубликован или удалён", true)
+			return
+		}
+		b.showRentTariffVersions(ctx, chatID, &msgID)
+		_ = b.answerCallback(cb, "Черновик удалён", false)
+		return
+
+	case strings.HasPrefix(data, "ver:"):
+		id, _ := strconv.ParseInt(strings.TrimPrefix(data, "ver:"), 10, 64)
+		b.showRentTariffVersion(ctx, chatID, &msgID, id)
+	}
+	_ = b.answerCallback(cb, "Ок", false)
+}
+
+// publishRentTariffDraft — ввод даты публикации черновика.
+func (b *Bot) publishRentTariffDraft(ctx context.Context, chatID int64, st *dialog.Item, text string) {
+	versionID := payloadInt64(st.Payload["version_id"])
+
+	text = strings.TrimSpace(text)
+	today := b.now()
+	from := today
+	if text != "0" {
+		d, err := time.ParseInLocation("02.01.2006", text, b.loc)
+		if err != nil {
+			b.send(tgbotapi.NewMessage(chatID, "Не удалось разобрать дату. Введите ДД.ММ.ГГГГ или 0."))
+			return
+		}
+		from = d
+	}
+
+	err := b.cons.PublishTariffVersion(ctx, versionID, from, today)
+	switch {
+	case errors.Is(err, consumption.ErrTariffStartInPast), errors.Is(err, consumption.ErrTariffStartTooSoon):
+		b.send(tgbotapi.NewMessage(chatID, "Нельзя опубликовать с этой даты: "+err.Error()+". Введите другую дату."))
+		return
+	case errors.Is(err, consumption.ErrTariffNotDraft):
+		b.send(tgbotapi.NewMessage(chatID, "Эта версия уже опубликована."))
+	case err != nil:
+		b.log.Error("publish tariff version failed", "version_id", versionID, "err", err)
+		b.send(tgbotapi.NewMessage(chatID, "Не удалось опубликовать версию тарифов."))
+		return
+	default:
+		b.send(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+			"Тарифы #%d опубликованы и действуют с %s. Сессии до этой даты считаются по прежней версии.",
+			versionID, from.Format("02.01.2006"))))
+	}
+	b.showRentTariffVersions(ctx, chatID, nil)
+}
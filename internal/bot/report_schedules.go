@@ -0,0 +1,407 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Spok95/beauty-bot/internal/access"
+	"github.com/Spok95/beauty-bot/internal/dialog"
+	"github.com/Spok95/beauty-bot/internal/domain/reports"
+	"github.com/Spok95/beauty-bot/internal/domain/users"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/xuri/excelize/v2"
+)
+
+// reportSchedulerTick — как часто планировщик проверяет расписания.
+const reportSchedulerTick = time.Minute
+
+func reportKindLabel(k reports.Kind) string {
+	switch k {
+	case reports.KindDailyRevenue:
+		return "Выручка за день"
+	case reports.KindWeeklyMasters:
+		return "Отчёт по мастерам за неделю"
+	case reports.KindMonthlyStock:
+		return "Оценка остатков на складах"
+	case reports.KindMonthlySubs:
+		return "Пороги абонементов за месяц"
+	default:
+		return string(k)
+	}
+}
+
+// reportKindCadence — когда приходит отчёт: «ежедневно в 09:00».
+func reportKindCadence(k reports.Kind, hour int) string {
+	at := fmt.Sprintf("%02d:00", hour)
+	switch k {
+	case reports.KindWeeklyMasters:
+		return "по понедельникам в " + at
+	case reports.KindMonthlyStock:
+		return "1-го числа в " + at
+	case reports.KindMonthlySubs:
+		return "в последний день месяца в " + at
+	default:
+		return "ежедневно в " + at
+	}
+}
+
+// showReportSchedules — подписки текущего пользователя на отчёты по расписанию.
+func (b *Bot) showReportSchedules(ctx context.Context, chatID int64, editMsgID *int) {
+	u := b.currentUser(ctx)
+	if u == nil {
+		return
+	}
+	list, err := b.reports.ListByUser(ctx, u.ID)
+	if err != nil {
+		b.log.Error("list report schedules failed", "err", err)
+	}
+	byKind := make(map[reports.Kind]reports.Schedule, len(list))
+	for _, s := range list {
+		byKind[s.Kind] = s
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Отчёты по расписанию\n\nВключённые отчёты приходят вам в бот файлом Excel с кратким итогом. Время — по часовому поясу салона. Нажмите на отчёт, чтобы включить или выключить его.\n")
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, k := range reports.Kinds {
+		s, ok := byKind[k]
+		if !ok {
+			s = reports.Schedule{Kind: k, Hour: 9}
+		}
+		sb.WriteString(fmt.Sprintf("\n%s %s — %s", badge(s.Active), reportKindLabel(k), reportKindCadence(k, s.Hour)))
+		if s.LastSentAt != nil {
+			sb.WriteString(fmt.Sprintf(" (последний: %s)", s.LastSentAt.In(b.loc).Format("02.01.2006 15:04")))
+		}
+
+		rows = append(rows,
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("%s %s", badge(s.Active), reportKindLabel(k)), "adm:rep:tg:"+string(k))),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🕘 %02d:00", s.Hour), "adm:rep:hour:"+string(k)),
+				tgbotapi.NewInlineKeyboardButtonData("📤 Сейчас", "adm:rep:now:"+string(k)),
+			),
+		)
+	}
+	rows = append(rows, navKeyboard(false, true).InlineKeyboard[0])
+
+	text := sb.String()
+	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	_ = b.states.Set(ctx, chatID, dialog.StateAdmReportSchedules, dialog.Payload{})
+	if editMsgID != nil {
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, *editMsgID, text, kb))
+		return
+	}
+	m := tgbotapi.NewMessage(chatID, text)
+	m.ReplyMarkup = kb
+	b.send(m)
+}
+
+// showReportHourPicker — выбор часа отправки отчёта.
+func (b *Bot) showReportHourPicker(chatID int64, msgID int, k reports.Kind) {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for h := 0; h < 24; h += 6 {
+		row := make([]tgbotapi.InlineKeyboardButton, 0, 6)
+		for i := h; i < h+6; i++ {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("%02d", i), fmt.Sprintf("adm:rep:seth:%s:%d", k, i)))
+		}
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", "adm:rep:menu")))
+	b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, msgID,
+		fmt.Sprintf("«%s» — выберите час отправки:", reportKindLabel(k)), tgbotapi.NewInlineKeyboardMarkup(rows...)))
+}
+
+// handleReportScheduleCallback — кнопки отчётов по расписанию (data без префикса "adm:rep:").
+func (b *Bot) handleReportScheduleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery, data string) {
+	chatID := cb.Message.Chat.ID
+	msgID := cb.Message.MessageID
+	u := b.currentUser(ctx)
+	if u == nil {
+		_ = b.answerCallback(cb, "Нет доступа", true)
+		return
+	}
+
+	switch {
+	case data == "menu":
+		_ = b.answerCallback(cb, "", false)
+		b.showReportSchedules(ctx, chatID, &msgID)
+
+	case strings.HasPrefix(data, "tg:"):
+		k := reports.Kind(strings.TrimPrefix(data, "tg:"))
+		list, _ := b.reports.ListByUser(ctx, u.ID)
+		active := true
+		for _, s := range list {
+			if s.Kind == k {
+				active = !s.Active
+			}
+		}
+		if err := b.reports.SetActive(ctx, u.ID, k, active); err != nil {
+			b.log.Error("set report schedule active failed", "kind", k, "err", err)
+			_ = b.answerCallback(cb, "Ошибка сохранения", true)
+			return
+		}
+		_ = b.answerCallback(cb, "Сохранено", false)
+		b.showReportSchedules(ctx, chatID, &msgID)
+
+	case strings.HasPrefix(data, "hour:"):
+		_ = b.answerCallback(cb, "", false)
+		b.showReportHourPicker(chatID, msgID, reports.Kind(strings.TrimPrefix(data, "hour:")))
+
+	case strings.HasPrefix(data, "seth:"):
+		parts := strings.Split(strings.TrimPrefix(data, "seth:"), ":")
+		if len(parts) != 2 {
+			_ = b.answerCallback(cb, "Некорректные данные", true)
+			return
+		}
+		hour, err := strconv.Atoi(parts[1])
+		if err != nil || hour < 0 || hour > 23 {
+			_ = b.answerCallback(cb, "Некорректный час", true)
+			return
+		}
+		if err := b.reports.SetHour(ctx, u.ID, reports.Kind(parts[0]), hour); err != nil {
+			b.log.Error("set report schedule hour failed", "kind", parts[0], "err", err)
+			_ = b.answerCallback(cb, "Ошибка сохранения", true)
+			return
+		}
+		_ = b.answerCallback(cb, "Сохранено", false)
+		b.showReportSchedules(ctx, chatID, &msgID)
+
+	case strings.HasPrefix(data, "now:"):
+		// отправка по требованию: последний завершённый период, отметка расписания не меняется
+		k := reports.Kind(strings.TrimPrefix(data, "now:"))
+		_ = b.answerCallback(cb, "Формирую отчёт…", false)
+		now := b.now()
+		s := reports.Schedule{Kind: k, Hour: now.Hour()}
+		if err := b.sendScheduledReport(ctx, chatID, reports.PeriodAt(k, s.LastRun(now))); err != nil {
+			b.log.Error("report on demand failed", "kind", k, "err", err)
+			b.send(tgbotapi.NewMessage(chatID, fmt.Sprintf("%s: ошибка формирования отчёта: %v", reportKindLabel(k), err)))
+		}
+	}
+}
+
+// RunReportScheduler раз в минуту отправляет отчёты, время которых наступило.
+// Час отправки и границы периодов считаются в часовом поясе салона (b.loc).
+func (b *Bot) RunReportScheduler(ctx context.Context) {
+	t := time.NewTicker(reportSchedulerTick)
+	defer t.Stop()
+	for {
+		b.runDueReports(ctx, b.now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (b *Bot) runDueReports(ctx context.Context, now time.Time) {
+	list, err := b.reports.ListActive(ctx)
+	if err != nil {
+		b.log.Error("list report schedules failed", "err", err)
+		return
+	}
+	for _, s := range list {
+		p, ok := s.Due(now)
+		if !ok {
+			continue
+		}
+		u, err := b.users.GetByID(ctx, s.UserID)
+		if err != nil || u == nil || !b.canReceiveReports(u) {
+			continue
+		}
+		// период отмечается только после успешной отправки, иначе он повторится на следующем тике
+		if err := b.sendScheduledReport(ctx, u.TelegramID, p); err != nil {
+			b.log.Error("scheduled report failed", "schedule", s.ID, "kind", p.Kind, "period", p.Key, "err", err)
+			continue
+		}
+		if err := b.reports.MarkSent(ctx, s.ID, p.Key); err != nil {
+			b.log.Error("mark report sent failed", "schedule", s.ID, "err", err)
+		}
+	}
+}
+
+// canReceiveReports — подписка действует, пока у пользователя есть роль с правом на отчёты.
+func (b *Bot) canReceiveReports(u *users.User) bool {
+	if u.Status != users.StatusApproved || u.TelegramID == 0 {
+		return false
+	}
+	for _, r := range u.Roles {
+		if b.policy.Allows(r, access.CapReportsView) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendScheduledReport формирует отчёт за период и отправляет его документом с кратким итогом.
+// Ошибка — отчёт не сформирован или не доставлен.
+func (b *Bot) sendScheduledReport(ctx context.Context, chatID int64, p reports.Period) error {
+	var (
+		name, summary string
+		data          []byte
+		err           error
+	)
+	switch p.Kind {
+	case reports.KindDailyRevenue, reports.KindWeeklyMasters:
+		name, data, summary, err = b.buildRevenueScheduledReport(ctx, p)
+	case reports.KindMonthlyStock:
+		name, data, summary, err = b.buildStockValuationReport(ctx, p)
+	case reports.KindMonthlySubs:
+		name, data, summary, err = b.buildSubsThresholdReport(ctx, p)
+	default:
+		return fmt.Errorf("unknown report kind %q", p.Kind)
+	}
+	if err != nil {
+		return err
+	}
+	var msg tgbotapi.Chattable = tgbotapi.NewMessage(chatID, summary)
+	if data != nil {
+		doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: name, Bytes: data})
+		doc.Caption = summary
+		msg = doc
+	}
+	_, err = b.api.Send(msg)
+	return err
+}
+
+func reportPeriodLabel(p reports.Period) string {
+	last := p.To.AddDate(0, 0, -1)
+	if last.Equal(p.From) {
+		return p.From.Format("02.01.2006")
+	}
+	return fmt.Sprintf("%s — %s", p.From.Format("02.01.2006"), last.Format("02.01.2006"))
+}
+
+// buildRevenueScheduledReport — выручка за день или отчёт по мастерам за неделю:
+// тот же Excel, что и «Аренда и Расходы материалов по мастерам».
+func (b *Bot) buildRevenueScheduledReport(ctx context.Context, p reports.Period) (string, []byte, string, error) {
+	data, t, err := b.buildRentMaterialsReport(ctx, p.From, p.To)
+	if err != nil {
+		return "", nil, "", err
+	}
+	title := fmt.Sprintf("%s: %s", reportKindLabel(p.Kind), reportPeriodLabel(p))
+	if data == nil {
+		return "", nil, title + "\nСессий за период нет.", nil
+	}
+	summary := fmt.Sprintf("%s\nМастеров: %d, сессий: %d\nАренда: %.2f ₽\nМатериалы (в зачёт): %.2f ₽\nСкидки: %.2f ₽\nИтого: %.2f ₽\nОплачено: %.2f ₽, не оплачено: %.2f ₽",
+		title, t.Masters, t.Sessions, t.Rent, t.Rounded, t.Discount, t.Total, t.Paid, t.Unpaid)
+	return rentMaterialsReportName(p.From, p.To), data, summary, nil
+}
+
+// buildStockValuationReport — остатки на складах по текущим ценам материалов.
+func (b *Bot) buildStockValuationReport(ctx context.Context, p reports.Period) (string, []byte, string, error) {
+	items, err := b.inventory.ListStockValuation(ctx)
+	if err != nil {
+		return "", nil, "", err
+	}
+	now := b.now().In(p.From.Location())
+	title := fmt.Sprintf("%s на %s", reportKindLabel(p.Kind), now.Format("02.01.2006 15:04"))
+	if len(items) == 0 {
+		return "", nil, title + "\nОстатков нет.", nil
+	}
+
+	f := excelize.NewFile()
+	defer func() { _ = f.Close() }()
+	sheet := f.GetSheetName(f.GetActiveSheetIndex())
+
+	header := []interface{}{"Склад", "Бренд", "Материал", "Ед.", "Остаток", "Цена за ед.", "Сумма"}
+	if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
+		return "", nil, "", err
+	}
+	var total float64
+	var order []string
+	byWarehouse := map[string]float64{}
+	for i, it := range items {
+		row := []interface{}{it.WarehouseName, it.BrandName, it.MaterialName, it.Unit, it.Qty, it.Price, it.Value}
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			return "", nil, "", err
+		}
+		if _, ok := byWarehouse[it.WarehouseName]; !ok {
+			order = append(order, it.WarehouseName)
+		}
+		byWarehouse[it.WarehouseName] += it.Value
+		total += it.Value
+	}
+	totalRow := []interface{}{"Итого", nil, nil, nil, nil, nil, total}
+	cell, _ := excelize.CoordinatesToCellName(1, len(items)+2)
+	if err := f.SetSheetRow(sheet, cell, &totalRow); err != nil {
+		return "", nil, "", err
+	}
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		return "", nil, "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(title)
+	for _, wh := range order {
+		sb.WriteString(fmt.Sprintf("\n%s: %.2f ₽", wh, byWarehouse[wh]))
+	}
+	sb.WriteString(fmt.Sprintf("\nВсего: %.2f ₽", total))
+	return fmt.Sprintf("stock_valuation_%s.xlsx", now.Format("20060102")), buf.Bytes(), sb.String(), nil
+}
+
+// buildSubsThresholdReport — выполнение порогов материалов по абонементам месяца.
+func (b *Bot) buildSubsThresholdReport(ctx context.Context, p reports.Period) (string, []byte, string, error) {
+	month := p.From.Format("2006-01")
+	list, err := b.subs.ListByMonth(ctx, month)
+	if err != nil {
+		return "", nil, "", err
+	}
+	title := fmt.Sprintf("%s: %s", reportKindLabel(p.Kind), month)
+	if len(list) == 0 {
+		return "", nil, title + "\nАбонементов за месяц нет.", nil
+	}
+
+	f := excelize.NewFile()
+	defer func() { _ = f.Close() }()
+	sheet := f.GetSheetName(f.GetActiveSheetIndex())
+
+	header := []interface{}{"Мастер", "Помещение", "Ед.", "План", "Использовано", "Куплено", "Материалы", "Порог", "Осталось до порога", "Порог выполнен"}
+	if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
+		return "", nil, "", err
+	}
+	names := map[int64]string{}
+	var met int
+	for i, s := range list {
+		name, ok := names[s.UserID]
+		if !ok {
+			if u, err := b.users.GetByID(ctx, s.UserID); err == nil {
+				name = b.userFullName(ctx, u)
+			}
+			names[s.UserID] = name
+		}
+		left := s.ThresholdMaterialsTotal - s.MaterialsSumTotal
+		if left < 0 {
+			left = 0
+		}
+		status := "нет"
+		if s.ThresholdMet {
+			status = "да"
+			met++
+		}
+		row := []interface{}{
+			name, placeLabel(s.Place), reportUnitLabel(s.Unit), s.PlanLimit, s.UsedQty, s.TotalQty,
+			s.MaterialsSumTotal, s.ThresholdMaterialsTotal, left, status,
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			return "", nil, "", err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		return "", nil, "", err
+	}
+	summary := fmt.Sprintf("%s\nАбонементов: %d\nПорог выполнен: %d\nНе выполнен: %d",
+		title, len(list), met, len(list)-met)
+	return fmt.Sprintf("subs_thresholds_%s.xlsx", p.From.Format("200601")), buf.Bytes(), summary, nil
+}
@@ -10,7 +10,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Spok95/beauty-bot/internal/access"
 	"github.com/Spok95/beauty-bot/internal/dialog"
+	"github.com/Spok95/beauty-bot/internal/domain/adminchat"
 	"github.com/Spok95/beauty-bot/internal/domain/catalog"
 	"github.com/Spok95/beauty-bot/internal/domain/consumption"
 	"github.com/Spok95/beauty-bot/internal/domain/materials"
@@ -21,12 +23,15 @@ import (
 
 const materialSearchPageSize = 10
 
+// masterStockSearchLimit — сколько найденных материалов показывать в остатках склада одним сообщением.
+const masterStockSearchLimit = 30
+
 func (b *Bot) handleCommand(ctx context.Context, msg *tgbotapi.Message) {
 	chatID := msg.Chat.ID
 	tgID := msg.From.ID
 	switch msg.Command() {
 	case "start":
-		existing, _ := b.users.GetByTelegramID(ctx, tgID)
+		existing := b.currentUser(ctx)
 
 		defaultRole := users.RoleMaster
 		if existing != nil && existing.Role != "" {
@@ -39,13 +44,6 @@ func (b *Bot) handleCommand(ctx context.Context, msg *tgbotapi.Message) {
 			return
 		}
 
-		// авто-админ
-		if b.isAdminID(msg.From.ID) && (u.Role != users.RoleAdmin || u.Status != users.StatusApproved) {
-			if approved, err2 := b.users.Approve(ctx, msg.From.ID, users.RoleAdmin); err2 == nil {
-				u = approved
-			}
-		}
-
 		if u.Status == users.StatusApproved {
 			roles, _ := b.users.ListRoles(ctx, u.ID)
 			u.Roles = roles
@@ -59,13 +57,29 @@ func (b *Bot) handleCommand(ctx context.Context, msg *tgbotapi.Message) {
 			return
 		}
 
+		// /start <code> — регистрация по приглашению: роль задана заранее
+		payload := dialog.Payload{}
+		if code := strings.TrimSpace(msg.CommandArguments()); code != "" {
+			inv, err := b.invites.GetByCode(ctx, code)
+			if err == nil {
+				err = inv.Check(b.now())
+			}
+			if err != nil {
+				b.send(tgbotapi.NewMessage(chatID, inviteErrorText(err)+" Можно подать обычную заявку."))
+			} else {
+				payload["invite_code"] = inv.Code
+				payload["role"] = string(inv.Role)
+				b.send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Приглашение принято. Роль: %s.", roleLabel(inv.Role))))
+			}
+		}
+
 		switch u.Status {
 		case users.StatusRejected:
-			_ = b.states.Set(ctx, chatID, dialog.StateAwaitFIO, dialog.Payload{})
+			_ = b.states.Set(ctx, chatID, dialog.StateAwaitFIO, payload)
 			b.askFIO(chatID)
 
 		default:
-			_ = b.states.Set(ctx, chatID, dialog.StateAwaitFIO, dialog.Payload{})
+			_ = b.states.Set(ctx, chatID, dialog.StateAwaitFIO, payload)
 			b.askFIO(chatID)
 		}
 
@@ -77,22 +91,12 @@ func (b *Bot) handleCommand(ctx context.Context, msg *tgbotapi.Message) {
 		return
 
 	case "admin":
-		// Только для admin — показываем техсообщение без меню
-		u, _ := b.users.GetByTelegramID(ctx, tgID)
-		if u == nil || u.Role != users.RoleAdmin || u.Status != users.StatusApproved {
-			b.send(tgbotapi.NewMessage(chatID, "Доступ запрещён"))
-			return
-		}
+		// Права проверены в authorizeMessage — показываем техсообщение без меню
 		b.send(tgbotapi.NewMessage(chatID,
 			"Раздел администрирования временно выключен. Настройка тарифов будет доступна через кнопку «Установка тарифов»."))
 		return
 
 	case "rent":
-		u, _ := b.users.GetByTelegramID(ctx, tgID)
-		if u == nil || u.Status != users.StatusApproved || u.Role != users.RoleMaster {
-			b.send(tgbotapi.NewMessage(chatID, "Доступ запрещён."))
-			return
-		}
 		_ = b.states.Set(ctx, chatID, dialog.StateConsComment, dialog.Payload{})
 
 		kb := tgbotapi.NewInlineKeyboardMarkup(
@@ -121,18 +125,13 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 
 	// Нижняя панель мастера
 	if msg.Text == "Расход/Аренда" {
-		u, _ := b.users.GetByTelegramID(ctx, tgID)
-		if u == nil || u.Status != users.StatusApproved || u.Role != users.RoleMaster {
-			return
-		}
-
 		_ = b.states.Set(ctx, chatID, dialog.StateConsComment, dialog.Payload{})
 		b.showConsumptionCommentStep(chatID, nil)
 		return
 	}
 
 	if msg.Text == "Сменить роль" {
-		u, _ := b.users.GetByTelegramID(ctx, tgID)
+		u := b.currentUser(ctx)
 		if u == nil || u.Status != users.StatusApproved {
 			return
 		}
@@ -148,28 +147,18 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 	}
 
 	if msg.Text == "Текущий чек" {
-		u, _ := b.users.GetByTelegramID(ctx, tgID)
-		if u == nil || u.Status != users.StatusApproved || u.Role != users.RoleMaster {
-			return
-		}
-
 		b.showCurrentConsumptionCheck(ctx, chatID, tgID)
 		return
 	}
 
 	if msg.Text == "Отменить последний расход" {
-		u, _ := b.users.GetByTelegramID(ctx, tgID)
-		if u == nil || u.Status != users.StatusApproved || u.Role != users.RoleMaster {
-			return
-		}
-
-		b.showCancelLastConsumption(ctx, chatID, 0, tgID)
+		b.showCancelLastConsumption(ctx, chatID, 0)
 		return
 	}
 
 	if msg.Text == "Просмотр остатков" {
-		u, _ := b.users.GetByTelegramID(ctx, tgID)
-		if u == nil || u.Status != users.StatusApproved || u.Role != users.RoleMaster {
+		u := b.currentUser(ctx)
+		if u == nil {
 			return
 		}
 
@@ -177,12 +166,17 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 		return
 	}
 
+	if msg.Text == "Моя история" {
+		b.showMyHistory(ctx, chatID, nil, "", 0)
+		return
+	}
+
 	if msg.Text == "Мои абонементы" {
-		u, _ := b.users.GetByTelegramID(ctx, tgID)
-		if u == nil || u.Status != users.StatusApproved || u.Role != users.RoleMaster {
+		u := b.currentUser(ctx)
+		if u == nil {
 			return
 		}
-		month := time.Now().Format("2006-01")
+		month := b.now().Format("2006-01")
 		list, err := b.subs.ListByUserMonth(ctx, u.ID, month)
 		if err != nil || len(list) == 0 {
 			b.send(tgbotapi.NewMessage(chatID, "На текущий месяц абонементов нет."))
@@ -192,6 +186,7 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 		sb.WriteString("Мои абонементы (текущий месяц):\n")
 		placeRU := map[string]string{"hall": "Зал", "cabinet": "Кабинет"}
 		unitRU := map[string]string{"hour": "ч", "day": "дн"}
+		var rows [][]tgbotapi.InlineKeyboardButton
 		for _, s := range list {
 			left := s.TotalQty - s.UsedQty
 			if left < 0 {
@@ -199,14 +194,19 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 			}
 			sb.WriteString(fmt.Sprintf("— %s, %s: %d/%d (остаток %d)\n",
 				placeRU[s.Place], unitRU[s.Unit], s.UsedQty, s.TotalQty, left))
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("📄 %s, %d %s — подтверждение (PDF)", placeRU[s.Place], s.PlanLimit, unitRU[s.Unit]),
+				fmt.Sprintf("subs:pdf:%d", s.ID))))
 		}
-		b.send(tgbotapi.NewMessage(chatID, sb.String()))
+		m := tgbotapi.NewMessage(chatID, sb.String())
+		m.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+		b.send(m)
 		return
 	}
 
 	if msg.Text == "Купить абонемент" {
-		u, _ := b.users.GetByTelegramID(ctx, tgID)
-		if u == nil || u.Status != users.StatusApproved || u.Role != users.RoleMaster {
+		u := b.currentUser(ctx)
+		if u == nil {
 			return
 		}
 		_ = b.states.Set(ctx, chatID, dialog.StateSubBuyPlace, dialog.Payload{})
@@ -218,14 +218,6 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 
 	// Чат с админом — доступен мастеру и администратору
 	if msg.Text == "Чат с админом" {
-		u, _ := b.users.GetByTelegramID(ctx, tgID)
-		if u == nil || u.Status != users.StatusApproved {
-			return
-		}
-		if u.Role != users.RoleMaster && u.Role != users.RoleAdmin && u.Role != users.RoleAdministrator {
-			return
-		}
-
 		_ = b.states.Set(ctx, chatID, dialog.StateChatAdmin, dialog.Payload{})
 
 		kb := adminChatCancelKeyboard()
@@ -238,31 +230,20 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 	}
 
 	if msg.Text == "История чата" {
-		u, _ := b.users.GetByTelegramID(ctx, tgID)
-		if u == nil || u.Status != users.StatusApproved {
-			return
-		}
-
-		if u.Role != users.RoleAdmin && u.Role != users.RoleAdministrator {
-			b.send(tgbotapi.NewMessage(chatID,
-				"История чата доступна только администраторам."))
-			return
-		}
-
-		b.showAdminChatHistory(ctx, chatID, 0)
+		b.showAdminChatInbox(ctx, chatID, nil, "a", 0)
 		return
 	}
 
 	// "Список команд" — доступно всем подтверждённым
 	if msg.Text == "Список команд" {
-		u, _ := b.users.GetByTelegramID(ctx, tgID)
+		u := b.currentUser(ctx)
 		if u == nil || u.Status != users.StatusApproved {
 			b.send(tgbotapi.NewMessage(chatID, "Сначала пройдите регистрацию: /start"))
 			return
 		}
-		if u.Role == users.RoleMaster {
+		if b.can(ctx, access.CapConsUse) {
 			b.send(tgbotapi.NewMessage(chatID, "Команды мастера:\n/rent — расход/аренда\n/help — помощь"))
-		} else if u.Role == users.RoleAdmin {
+		} else if b.can(ctx, access.CapRatesEdit) {
 			b.send(tgbotapi.NewMessage(chatID, "Команды админа:\n/admin — админ-меню\n/help — помощь"))
 		} else {
 			b.send(tgbotapi.NewMessage(chatID, "Команды:\n/help — помощь"))
@@ -274,64 +255,37 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 	if msg.Text == "Склады" || msg.Text == "Категории" || msg.Text == "Материалы" ||
 		msg.Text == "Инвентаризация" || msg.Text == "Поставки" || msg.Text == "Абонементы" ||
 		msg.Text == "Установка цен" || msg.Text == "Аренда и Расходы материалов по мастерам" ||
-		msg.Text == "Оповещение всем" {
-		u, _ := b.users.GetByTelegramID(ctx, tgID)
-		if u == nil || u.Status != users.StatusApproved {
-			// игнорируем для не-админов
-			return
-		}
+		msg.Text == "Рассылки" || msg.Text == "Пользователи" || msg.Text == "Журнал изменений" ||
+		msg.Text == "Отчёты по расписанию" || msg.Text == "Аналитика" {
+		// права на каждую кнопку проверены в authorizeMessage (textCapabilities)
 		switch msg.Text {
 		case "Склады":
-			if u.Role != users.RoleAdmin {
-				return
-			}
 			_ = b.states.Set(ctx, chatID, dialog.StateAdmWhMenu, dialog.Payload{})
 			b.showWarehouseMenu(chatID, nil)
 		case "Категории":
-			if u.Role != users.RoleAdmin && u.Role != users.RoleAdministrator {
-				return
-			}
 			_ = b.states.Set(ctx, chatID, dialog.StateAdmCatMenu, dialog.Payload{})
 			b.showCategoryMenu(chatID, nil)
 		case "Материалы":
-			if u.Role != users.RoleAdmin && u.Role != users.RoleAdministrator {
-				return
-			}
 			_ = b.states.Set(ctx, chatID, dialog.StateAdmMatMenu, dialog.Payload{})
 			b.showMaterialMenu(chatID, nil)
 			return
 		case "Инвентаризация":
-			if u.Role != users.RoleAdmin && u.Role != users.RoleAdministrator {
-				return
-			}
 			_ = b.states.Set(ctx, chatID, dialog.StateStockMenu, dialog.Payload{})
 			b.showStocksMenu(chatID, nil)
 			return
 		case "Поставки":
-			if u.Role != users.RoleAdmin && u.Role != users.RoleAdministrator {
-				return
-			}
 			_ = b.states.Set(ctx, chatID, dialog.StateSupMenu, dialog.Payload{})
 			b.showSuppliesMenu(chatID, nil)
 			return
 		case "Абонементы":
-			if u.Role != users.RoleAdmin {
-				return
-			}
 			_ = b.states.Set(ctx, chatID, dialog.StateAdmSubsMenu, dialog.Payload{})
 			b.showSubsMenu(chatID, nil)
 			return
 		case "Установка цен":
-			if u.Role != users.RoleAdmin {
-				return
-			}
 			_ = b.states.Set(ctx, chatID, dialog.StatePriceMenu, dialog.Payload{})
 			b.showPriceMainMenu(chatID, nil)
 			return
 		case "Аренда и Расходы материалов по мастерам":
-			if u.Role != users.RoleAdmin {
-				return
-			}
 			_ = b.states.Set(ctx, chatID, dialog.StateAdmReportRentPeriod, dialog.Payload{})
 			msg := tgbotapi.NewMessage(chatID,
 				"Введите период для отчёта в формате ДД.ММ.ГГГГ-ДД.ММ.ГГГГ.\n"+
@@ -339,23 +293,30 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 					"Дата окончания включительно, данные будут взяты до конца этого дня.")
 			b.send(msg)
 			return
-		case "Оповещение всем":
-			if u.Role != users.RoleAdmin {
-				return
-			}
-			_ = b.states.Set(ctx, chatID, dialog.StateAdmBroadcastAll, dialog.Payload{})
+		case "Рассылки":
+			b.showBroadcastMenu(ctx, chatID, nil)
+			return
+		case "Пользователи":
+			b.showAdminUsersList(ctx, chatID, nil, "", 0)
+			return
+		case "Журнал изменений":
+			b.showAuditSections(ctx, chatID, nil)
+			return
+		case "Отчёты по расписанию":
+			b.showReportSchedules(ctx, chatID, nil)
+			return
+		case "Аналитика":
+			_ = b.states.Set(ctx, chatID, dialog.StateAdmReportAnalytics, dialog.Payload{})
 			b.send(tgbotapi.NewMessage(chatID,
-				"Введите текст оповещения. Оно будет отправлено всем подтверждённым пользователям бота."))
+				"Введите период для аналитики в формате ДД.ММ.ГГГГ-ДД.ММ.ГГГГ.\n"+
+					"Например: 01.11.2025-30.11.2025.\n"+
+					"Показатели сравниваются с тем же периодом месяцем раньше."))
 			return
 		}
 		return
 	}
 
 	if msg.Text == "Установка тарифов" {
-		u, _ := b.users.GetByTelegramID(ctx, tgID)
-		if u == nil || u.Role != users.RoleAdmin || u.Status != users.StatusApproved {
-			return
-		}
 		_ = b.states.Set(ctx, chatID, dialog.StateAdmRatesPickPU, dialog.Payload{
 			"place": "hall", "unit": "hour", "with_sub": false,
 		})
@@ -380,12 +341,6 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 
 	// Триггеры расхода/аренды по тексту (доступно всем подтверждённым ролям)
 	if msg.Text == "/rent" || msg.Text == "/consumption" {
-		u, _ := b.users.GetByTelegramID(ctx, tgID)
-		if u == nil || u.Status != users.StatusApproved || u.Role != users.RoleMaster {
-			b.send(tgbotapi.NewMessage(chatID, "Доступ запрещён."))
-			return
-		}
-
 		_ = b.states.Set(ctx, chatID, dialog.StateConsComment, dialog.Payload{})
 		b.showConsumptionCommentStep(chatID, nil)
 		return
@@ -393,9 +348,9 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 
 	switch st.State {
 	case dialog.StateAwaitFIO:
-		fio := strings.TrimSpace(msg.Text)
-		if fio == "" || len(fio) < 3 {
-			b.send(tgbotapi.NewMessage(chatID, "ФИО выглядит пустым. Введите корректно."))
+		fio := strings.Join(strings.Fields(msg.Text), " ")
+		if _, first, _ := users.SplitFullName(fio); first == "" {
+			b.send(tgbotapi.NewMessage(chatID, "Нужны как минимум фамилия и имя, например: Иванова Анна Сергеевна."))
 			return
 		}
 		if _, err := b.users.SetFIO(ctx, tgID, fio); err != nil {
@@ -404,6 +359,44 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 		}
 		p := st.Payload
 		p["fio"] = fio
+		_ = b.states.Set(ctx, chatID, dialog.StateAwaitPhone, p)
+		b.askPhone(chatID)
+		return
+
+	case dialog.StateAwaitPhone:
+		raw := strings.TrimSpace(msg.Text)
+		if msg.Contact != nil {
+			if msg.Contact.UserID != 0 && msg.Contact.UserID != tgID {
+				b.send(tgbotapi.NewMessage(chatID, "Отправьте, пожалуйста, свой номер."))
+				return
+			}
+			raw = msg.Contact.PhoneNumber
+		}
+		phone, err := users.NormalizePhone(raw)
+		if err != nil {
+			b.send(tgbotapi.NewMessage(chatID, "Не похоже на номер телефона. Пример: +7 900 123-45-67."))
+			return
+		}
+		if u := b.currentUser(ctx); u != nil {
+			if err := b.users.SetProfileField(ctx, u.ID, users.ProfilePhone, phone); err != nil {
+				b.send(tgbotapi.NewMessage(chatID, "Ошибка сохранения телефона, попробуйте ещё раз."))
+				return
+			}
+		}
+		p := st.Payload
+		p["phone"] = phone
+		done := tgbotapi.NewMessage(chatID, "Телефон сохранён: "+phone)
+		done.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+		b.send(done)
+		// по приглашению роль уже известна — выбор роли пропускаем
+		if payloadString(p, "invite_code") != "" {
+			next, text, kb := registrationAfterRole(p)
+			_ = b.states.Set(ctx, chatID, next, p)
+			m := tgbotapi.NewMessage(chatID, text)
+			m.ReplyMarkup = kb
+			b.send(m)
+			return
+		}
 		_ = b.states.Set(ctx, chatID, dialog.StateAwaitRole, p)
 		m := tgbotapi.NewMessage(chatID, "Выберите роль:")
 		m.ReplyMarkup = roleKeyboard()
@@ -414,6 +407,14 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 		b.handleAdminChatMessage(ctx, msg)
 		return
 
+	case dialog.StateAdmChatFind:
+		b.handleAdminChatFind(ctx, chatID, msg.Text)
+		return
+
+	case dialog.StateAdmChatSearch:
+		b.handleAdminChatSearchInput(ctx, chatID, st, msg.Text)
+		return
+
 	case dialog.StateAdmWhName:
 		// ввод названия склада
 		name := strings.TrimSpace(msg.Text)
@@ -586,6 +587,118 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 		b.send(tgbotapi.NewMessage(chatID, "Материал переименован."))
 		b.showMaterialMenu(chatID, nil)
 		return
+	case dialog.StateAdmUsersSearch:
+		query := strings.TrimSpace(msg.Text)
+		if query == "" {
+			b.send(tgbotapi.NewMessage(chatID, "Введите часть ФИО или Telegram ID."))
+			return
+		}
+		b.showAdminUsersList(ctx, chatID, nil, query, 0)
+		return
+
+	case dialog.StateAdmUserRename:
+		id := payloadInt64(st.Payload["user_id"])
+		name := strings.TrimSpace(msg.Text)
+		if name == "" {
+			b.send(tgbotapi.NewMessage(chatID, "ФИО не может быть пустым. Введите ещё раз."))
+			return
+		}
+		target, _ := b.users.GetByID(ctx, id)
+		if target == nil {
+			b.send(tgbotapi.NewMessage(chatID, "Пользователь не найден"))
+			b.showAdminUsersList(ctx, chatID, nil, "", 0)
+			return
+		}
+		if _, err := b.users.SetFIO(ctx, target.TelegramID, name); err != nil {
+			b.send(tgbotapi.NewMessage(chatID, "Ошибка при сохранении ФИО"))
+			return
+		}
+		b.send(tgbotapi.NewMessage(chatID, "ФИО обновлено."))
+		b.showAdminUserCard(ctx, chatID, nil, id, st.Payload)
+		return
+
+	case dialog.StateAdmUserProfileField:
+		b.handleAdminProfileInput(ctx, msg, st)
+		return
+
+	case dialog.StateAdmMatSearch:
+		query := strings.TrimSpace(msg.Text)
+		if query == "" {
+			b.send(tgbotapi.NewMessage(chatID, "Введите название материала или бренда."))
+			return
+		}
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmMatSearch, dialog.Payload{"q": query})
+		b.showAdminMaterialSearch(ctx, chatID, nil, query, 0)
+		return
+
+	case dialog.StateAdmBrandAlias:
+		id := payloadInt64(st.Payload["mat_id"])
+		m, _ := b.materials.GetByID(ctx, id)
+		if m == nil {
+			_ = b.states.Set(ctx, chatID, dialog.StateAdmMatMenu, dialog.Payload{})
+			b.send(tgbotapi.NewMessage(chatID, "Материал не найден."))
+			b.showMaterialMenu(chatID, nil)
+			return
+		}
+		alias := strings.TrimSpace(msg.Text)
+		if alias == "" {
+			b.send(tgbotapi.NewMessage(chatID, "Синоним не может быть пустым. Введите ещё раз."))
+			return
+		}
+		if err := b.brands.AddAlias(ctx, m.BrandID, alias); err != nil {
+			b.send(tgbotapi.NewMessage(chatID, "Ошибка при сохранении синонима"))
+			return
+		}
+		b.showBrandAliases(ctx, chatID, nil, id)
+		return
+
+	case dialog.StateAdmMatBarcode:
+		id := payloadInt64(st.Payload["mat_id"])
+		code, errText := b.readBarcode(ctx, msg)
+		if errText != "" {
+			b.send(tgbotapi.NewMessage(chatID, errText))
+			return
+		}
+		if err := b.materials.AddBarcode(ctx, id, code); err != nil {
+			if errors.Is(err, materials.ErrBarcodeTaken) {
+				text := fmt.Sprintf("Код %s уже привязан к другому материалу.", code)
+				if other, _ := b.materials.GetByBarcode(ctx, code); other != nil {
+					text = fmt.Sprintf("Код %s уже привязан к материалу «%s».", code, materialDisplayName(other.Brand, other.Name))
+				}
+				b.send(tgbotapi.NewMessage(chatID, text))
+				return
+			}
+			b.send(tgbotapi.NewMessage(chatID, "Ошибка при сохранении штрихкода"))
+			return
+		}
+		b.send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Штрихкод %s добавлен.", code)))
+		b.showMaterialBarcodes(ctx, chatID, nil, id)
+		return
+
+	case dialog.StateAdmMatPackFactor:
+		id := payloadInt64(st.Payload["mat_id"])
+		unit := materials.Unit(payloadString(st.Payload, "purchase_unit"))
+		m, _ := b.materials.GetByID(ctx, id)
+		if m == nil || !unit.Packaging() {
+			_ = b.states.Set(ctx, chatID, dialog.StateAdmMatMenu, dialog.Payload{})
+			b.send(tgbotapi.NewMessage(chatID, "Материал не найден."))
+			b.showMaterialMenu(chatID, nil)
+			return
+		}
+		factor, _, err := parseMaterialQty(msg.Text, m.Unit)
+		if err != nil || factor <= 0 {
+			b.send(tgbotapi.NewMessage(chatID, "Некорректное число. "+materialQtyHint(m.Unit)))
+			return
+		}
+		if err := b.materials.UpdatePurchaseUnit(ctx, id, unit, factor); err != nil {
+			b.send(tgbotapi.NewMessage(chatID, "Ошибка при сохранении упаковки"))
+			return
+		}
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmMatItem, dialog.Payload{"mat_id": id})
+		sent, _ := b.api.Send(tgbotapi.NewMessage(chatID, "Упаковка сохранена."))
+		b.showMaterialItemMenu(ctx, chatID, sent.MessageID, id)
+		return
+
 	case dialog.StateStockInQty:
 		qtyStr := strings.TrimSpace(msg.Text)
 		qty, err := strconv.ParseFloat(strings.ReplaceAll(qtyStr, ",", "."), 64)
@@ -596,7 +709,7 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 		wh := int64(st.Payload["wh_id"].(float64))
 		mat := int64(st.Payload["mat_id"].(float64))
 		// actorID — ID из users, получим по telegram_id
-		u, _ := b.users.GetByTelegramID(ctx, tgID)
+		u := b.currentUser(ctx)
 		if u == nil {
 			b.send(tgbotapi.NewMessage(chatID, "Пользователь не найден"))
 			return
@@ -621,7 +734,7 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 		}
 		wh := int64(st.Payload["wh_id"].(float64))
 		mat := int64(st.Payload["mat_id"].(float64))
-		u, _ := b.users.GetByTelegramID(ctx, tgID)
+		u := b.currentUser(ctx)
 		if u == nil {
 			b.send(tgbotapi.NewMessage(chatID, "Пользователь не найден"))
 			return
@@ -636,28 +749,36 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 		b.maybeNotifyLowOrNegative(ctx, chatID, wh, mat)
 		return
 
+	case dialog.StateSupBarcode:
+		b.clearPrevStep(ctx, chatID)
+		b.supPickByBarcode(ctx, chatID, st, msg)
+		return
+
 	case dialog.StateSupQty:
 		// Чистим прошлую клавиатуру под сообщением шага "количество"
 		b.clearPrevStep(ctx, chatID)
 
-		qtyStr := strings.TrimSpace(msg.Text)
-		qtyStr = strings.ReplaceAll(qtyStr, ",", ".")
-		// только целые числа: граммы/шт, без дробной части
-		if strings.Contains(qtyStr, ".") {
-			b.send(tgbotapi.NewMessage(chatID, "Введите целое число без дробной части (используем граммы/шт)."))
+		m, _ := b.materials.GetByID(ctx, payloadInt64(st.Payload["mat_id"]))
+		if m == nil {
+			_ = b.states.Set(ctx, chatID, dialog.StateSupPickWh, dialog.Payload{})
+			b.showSuppliesPickWarehouse(ctx, chatID, nil)
 			return
 		}
-		n, err := strconv.ParseInt(qtyStr, 10, 64)
+		// количество вводится в единицах закупки (если задана упаковка), храним — в единицах расхода
+		inUnit := m.Unit
+		if m.HasPurchaseUnit() {
+			inUnit = m.PurchaseUnit
+		}
+		n, _, err := parseMaterialQty(msg.Text, inUnit)
 		if err != nil || n <= 0 {
-			b.send(tgbotapi.NewMessage(chatID, "Некорректное число. Введите целое положительное значение."))
+			b.send(tgbotapi.NewMessage(chatID, "Некорректное количество. "+materialQtyHint(inUnit)))
 			return
 		}
-		// сохраняем целое значение; payload сериализуется как float64 — это ок
-		st.Payload["qty"] = float64(n)
+		st.Payload["qty"] = math.Round(m.ToUsage(n)*materialQtyPrecision) / materialQtyPrecision
 		_ = b.states.Set(ctx, chatID, dialog.StateSupUnitPrice, st.Payload)
-		m := tgbotapi.NewMessage(chatID, "Введите цену за единицу (руб)")
-		m.ReplyMarkup = navKeyboard(true, true)
-		sent, _ := b.api.Send(m)
+		pm := tgbotapi.NewMessage(chatID, supPricePrompt(*m))
+		pm.ReplyMarkup = navKeyboard(true, true)
+		sent, _ := b.api.Send(pm)
 
 		// сохраняем last_mid и переключаемся на шаг цены
 		b.saveLastStep(ctx, chatID, dialog.StateSupUnitPrice, st.Payload, sent.MessageID)
@@ -690,13 +811,17 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 			b.send(tgbotapi.NewMessage(chatID, "Некорректное число. Введите цену (руб)."))
 			return
 		}
-		qty := int64(st.Payload["qty"].(float64)) // мы сохраняли как float64, но значение целое
+		// цену за упаковку переводим в цену за единицу расхода
+		if m, _ := b.materials.GetByID(ctx, matID); m != nil && m.HasPurchaseUnit() {
+			price = math.Round(price/m.PurchaseFactor*100) / 100
+		}
+		qty := payloadFloat(st.Payload, "qty") // в единицах расхода
 
 		// Добавляем позицию в payload["items"]
 		items := b.parseSupItems(st.Payload["items"])
 		items = append(items, map[string]any{
 			"mat_id": float64(matID), // через float64, чтобы без проблем сериализовалось
-			"qty":    float64(qty),
+			"qty":    qty,
 			"price":  price,
 		})
 		st.Payload["items"] = items
@@ -741,14 +866,14 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 		}
 
 		// ищем пользователя
-		u, err := b.users.GetByTelegramID(ctx, msg.From.ID)
-		if err != nil || u == nil {
+		u := b.currentUser(ctx)
+		if u == nil {
 			b.send(tgbotapi.NewMessage(chatID, "Пользователь не найден или нет доступа."))
 			return
 		}
 
 		// скачиваем файл из Telegram
-		data, err := b.downloadTelegramFile(msg.Document.FileID)
+		data, err := b.downloadTelegramFile(ctx, msg.Document.FileID)
 		if err != nil {
 			b.send(tgbotapi.NewMessage(chatID, "Не удалось скачать файл из Telegram: "+err.Error()))
 			return
@@ -761,7 +886,7 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 	case dialog.StateSupJournalFrom:
 		// ввод даты начала
 		fromStr := strings.TrimSpace(msg.Text)
-		from, err := time.Parse("02.01.2006", fromStr)
+		from, err := time.ParseInLocation("02.01.2006", fromStr, b.loc)
 		if err != nil {
 			b.send(tgbotapi.NewMessage(chatID,
 				"Некорректная дата. Введите в формате ДД.ММ.ГГГГ, например 01.11.2025."))
@@ -784,7 +909,7 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 		}
 
 		toStr := strings.TrimSpace(msg.Text)
-		to, err := time.Parse("02.01.2006", toStr)
+		to, err := time.ParseInLocation("02.01.2006", toStr, b.loc)
 		if err != nil {
 			b.send(tgbotapi.NewMessage(chatID,
 				"Некорректная дата. Введите в формате ДД.ММ.ГГГГ, например 30.11.2025."))
@@ -822,13 +947,13 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 			return
 		}
 
-		u, err := b.users.GetByTelegramID(ctx, tgID)
-		if err != nil || u == nil {
+		u := b.currentUser(ctx)
+		if u == nil {
 			b.send(tgbotapi.NewMessage(chatID, "Пользователь не найден или нет доступа."))
 			return
 		}
 
-		data, err := b.downloadTelegramFile(msg.Document.FileID)
+		data, err := b.downloadTelegramFile(ctx, msg.Document.FileID)
 		if err != nil {
 			b.send(tgbotapi.NewMessage(chatID, "Не удалось скачать файл из Telegram: "+err.Error()))
 			return
@@ -844,13 +969,13 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 			return
 		}
 
-		u, err := b.users.GetByTelegramID(ctx, tgID)
-		if err != nil || u == nil {
+		u := b.currentUser(ctx)
+		if u == nil {
 			b.send(tgbotapi.NewMessage(chatID, "Пользователь не найден или нет доступа."))
 			return
 		}
 
-		data, err := b.downloadTelegramFile(msg.Document.FileID)
+		data, err := b.downloadTelegramFile(ctx, msg.Document.FileID)
 		if err != nil {
 			b.send(tgbotapi.NewMessage(chatID, "Не удалось скачать файл из Telegram: "+err.Error()))
 			return
@@ -866,13 +991,13 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 			return
 		}
 
-		u, err := b.users.GetByTelegramID(ctx, tgID)
-		if err != nil || u == nil {
+		u := b.currentUser(ctx)
+		if u == nil {
 			b.send(tgbotapi.NewMessage(chatID, "Пользователь не найден или нет доступа."))
 			return
 		}
 
-		data, err := b.downloadTelegramFile(msg.Document.FileID)
+		data, err := b.downloadTelegramFile(ctx, msg.Document.FileID)
 		if err != nil {
 			b.send(tgbotapi.NewMessage(chatID, "Не удалось скачать файл из Telegram: "+err.Error()))
 			return
@@ -881,6 +1006,30 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 		b.handlePriceRentImportExcel(ctx, chatID, data)
 		return
 
+	case dialog.StatePriceRentPublish:
+		b.publishRentTariffDraft(ctx, chatID, st, msg.Text)
+		return
+
+	case dialog.StatePriceDiscImportFile:
+		if msg.Document == nil {
+			b.send(tgbotapi.NewMessage(chatID,
+				"Пожалуйста, отправьте Excel-файл (.xlsx) со скидками, который был выгружен через «Выгрузить скидки»."))
+			return
+		}
+
+		data, err := b.downloadTelegramFile(ctx, msg.Document.FileID)
+		if err != nil {
+			b.send(tgbotapi.NewMessage(chatID, "Не удалось скачать файл из Telegram: "+err.Error()))
+			return
+		}
+
+		b.handlePriceDiscImportExcel(ctx, chatID, data)
+		return
+
+	case dialog.StateConsPromoCode:
+		b.handleConsPromoCode(ctx, chatID, msg.From.ID, st.Payload, msg.Text)
+		return
+
 	case dialog.StateConsComment:
 		text := strings.TrimSpace(msg.Text)
 		if text == "" {
@@ -965,9 +1114,25 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 		b.showConsCart(ctx, chatID, nil, st.Payload["place"].(string), st.Payload["unit"].(string), int(n), []map[string]any{})
 		return
 
+	case dialog.StateConsBarcode:
+		b.consPickByBarcode(ctx, chatID, st, msg)
+		return
+
 	case dialog.StateConsSearchByName:
 		delete(st.Payload, "mat_id")
 
+		// фото или цифры штрихкода — сразу ищем материал по коду
+		if hasBarcodeImage(msg) {
+			b.consPickByBarcode(ctx, chatID, st, msg)
+			return
+		}
+		if materials.LooksLikeBarcode(msg.Text) {
+			if m, _ := b.materials.GetByBarcode(ctx, msg.Text); m != nil {
+				b.consPickByBarcode(ctx, chatID, st, msg)
+				return
+			}
+		}
+
 		query := strings.TrimSpace(msg.Text)
 		if query == "" {
 			b.send(tgbotapi.NewMessage(chatID, "Введите часть названия материала."))
@@ -992,16 +1157,15 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 
 	case dialog.StateConsMatQty:
 		s := strings.TrimSpace(msg.Text)
-		s = strings.ReplaceAll(s, ",", ".")
 
-		if strings.Contains(s, ".") {
-			b.send(tgbotapi.NewMessage(chatID, "Введите целое число (граммы/шт)."))
-			return
+		unit := materials.UnitG
+		if m, _ := b.materials.GetByID(ctx, payloadInt64(st.Payload["mat_id"])); m != nil {
+			unit = m.Unit
 		}
 
-		n, err := strconv.ParseInt(s, 10, 64)
+		n, isNumber, err := parseMaterialQty(s, unit)
 
-		if loop, _ := st.Payload["cons_search_loop"].(bool); loop && err != nil {
+		if loop, _ := st.Payload["cons_search_loop"].(bool); loop && !isNumber {
 			delete(st.Payload, "mat_id")
 
 			st.Payload["cons_search_query"] = s
@@ -1022,15 +1186,22 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 		}
 
 		if err != nil || n <= 0 {
-			b.send(tgbotapi.NewMessage(chatID, "Некорректное значение. Введите целое положительное число."))
+			b.send(tgbotapi.NewMessage(chatID, "Некорректное значение. "+materialQtyHint(unit)))
 			return
 		}
 
-		items := b.consParseItems(st.Payload["items"])
-		items = append(items, map[string]any{
+		item := map[string]any{
 			"mat_id": st.Payload["mat_id"],
-			"qty":    float64(n),
-		})
+			"qty":    n,
+		}
+		// цена фиксируется в корзине до подтверждения
+		if _, err := b.consItemPrice(ctx, item); err != nil {
+			b.log.Error("material price load failed", "material_id", payloadInt64(item["mat_id"]), "err", err)
+			b.send(tgbotapi.NewMessage(chatID, "Не удалось загрузить цену материала, позиция не добавлена. Попробуйте ещё раз."))
+			return
+		}
+		items := b.consParseItems(st.Payload["items"])
+		items = append(items, item)
 		st.Payload["items"] = items
 
 		if loop, _ := st.Payload["cons_search_loop"].(bool); loop {
@@ -1077,6 +1248,77 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 
 		return
 
+	case dialog.StateConsItemQty:
+		items := b.consParseItems(st.Payload["items"])
+		idx := payloadInt(st.Payload, "cons_item_idx")
+		if idx < 0 || idx >= len(items) {
+			b.send(tgbotapi.NewMessage(chatID, "Позиция не найдена. Откройте чек заново."))
+			return
+		}
+
+		unit := materials.UnitG
+		if m, _ := b.materials.GetByID(ctx, payloadInt64(items[idx]["mat_id"])); m != nil {
+			unit = m.Unit
+		}
+
+		// 0 — удалить позицию
+		if strings.TrimSpace(msg.Text) == "0" {
+			items = append(items[:idx], items[idx+1:]...)
+		} else {
+			n, _, err := parseMaterialQty(msg.Text, unit)
+			if err != nil {
+				b.send(tgbotapi.NewMessage(chatID, "Некорректное значение. "+materialQtyHint(unit)+" 0 — удалить позицию."))
+				return
+			}
+			items[idx]["qty"] = n
+		}
+		st.Payload["items"] = items
+		delete(st.Payload, "cons_item_idx")
+
+		_ = b.states.Set(ctx, chatID, dialog.StateConsCart, st.Payload)
+		b.showConsCartFromPayload(ctx, chatID, nil, st.Payload)
+		return
+
+	case dialog.StateConsTplName:
+		name := strings.TrimSpace(msg.Text)
+		if name == "" {
+			b.send(tgbotapi.NewMessage(chatID, "Название не может быть пустым. Введите ещё раз."))
+			return
+		}
+
+		u := b.currentUser(ctx)
+		if u == nil || u.Status != users.StatusApproved {
+			b.send(tgbotapi.NewMessage(chatID, "Нет доступа."))
+			return
+		}
+
+		t, err := b.saveConsCartAsTemplate(ctx, u, name, b.consParseItems(st.Payload["items"]))
+		if err != nil {
+			b.send(tgbotapi.NewMessage(chatID, "Не удалось сохранить шаблон: "+err.Error()))
+			return
+		}
+
+		_ = b.states.Set(ctx, chatID, dialog.StateConsCart, st.Payload)
+		b.send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Шаблон «%s» сохранён.", t.Name)))
+		b.showConsCartFromPayload(ctx, chatID, nil, st.Payload)
+		return
+
+	case dialog.StateAdmTplImportFile:
+		if msg.Document == nil {
+			b.send(tgbotapi.NewMessage(chatID,
+				"Пожалуйста, отправьте Excel-файл (.xlsx) с шаблонами, который был выгружен через «Выгрузить шаблоны»."))
+			return
+		}
+
+		data, err := b.downloadTelegramFile(ctx, msg.Document.FileID)
+		if err != nil {
+			b.send(tgbotapi.NewMessage(chatID, "Не удалось скачать файл из Telegram: "+err.Error()))
+			return
+		}
+
+		b.handleMaterialTemplatesImportExcel(ctx, chatID, data)
+		return
+
 	case dialog.StateAdmSubsEnterQty:
 		s := strings.TrimSpace(msg.Text)
 		if strings.Contains(s, ",") {
@@ -1098,7 +1340,7 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 		place := st.Payload["place"].(string)
 		unit := st.Payload["unit"].(string)
 		uid := int64(st.Payload["uid"].(float64))
-		month := time.Now().Format("2006-01")
+		month := b.now().Format("2006-01")
 
 		// Для превью: найдём пользователя по uid
 		var title string
@@ -1131,24 +1373,28 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 
 	case dialog.StateAdmRatesCreateMin:
 		s := strings.TrimSpace(msg.Text)
-		n, err := strconv.ParseInt(s, 10, 64)
-		if err != nil || n <= 0 {
-			b.send(tgbotapi.NewMessage(chatID, "Введите целое положительное число"))
-			return
+		if !keepRateValue(st.Payload, s) {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || n <= 0 {
+				b.send(tgbotapi.NewMessage(chatID, "Введите целое положительное число"))
+				return
+			}
+			st.Payload["min"] = float64(n)
 		}
-		st.Payload["min"] = float64(n)
 		_ = b.states.Set(ctx, chatID, dialog.StateAdmRatesCreateMax, st.Payload)
 
-		m := tgbotapi.NewMessage(chatID, "Введите максимальное значение диапазона или «-» для бесконечности")
+		m := tgbotapi.NewMessage(chatID, "Введите максимальное значение диапазона или «-» для бесконечности"+rateEditHint(st.Payload, "max"))
 		m.ReplyMarkup = navKeyboard(true, true)
 		b.send(m)
 		return
 
 	case dialog.StateAdmRatesCreateMax:
 		s := strings.TrimSpace(msg.Text)
-		if s == "-" {
+		switch {
+		case keepRateValue(st.Payload, s):
+		case s == "-":
 			st.Payload["max"] = nil
-		} else {
+		default:
 			n, err := strconv.ParseInt(s, 10, 64)
 			if err != nil || n <= 0 {
 				b.send(tgbotapi.NewMessage(chatID, "Введите целое положительное число или «-»"))
@@ -1156,78 +1402,120 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 			}
 			st.Payload["max"] = float64(n)
 		}
+		if st.Payload["max"] != nil && payloadInt(st.Payload, "max") < payloadInt(st.Payload, "min") {
+			b.send(tgbotapi.NewMessage(chatID, "Максимум не может быть меньше минимума. Введите другое значение или «-»"))
+			return
+		}
 		_ = b.states.Set(ctx, chatID, dialog.StateAdmRatesCreateThreshold, st.Payload)
 
-		m := tgbotapi.NewMessage(chatID, "Введите порог материалов на единицу (например 100 или 1000)")
+		m := tgbotapi.NewMessage(chatID, "Введите порог материалов на единицу (например 100 или 1000)"+rateEditHint(st.Payload, "thr"))
 		m.ReplyMarkup = navKeyboard(true, true)
 		b.send(m)
 		return
 
 	case dialog.StateAdmRatesCreateThreshold:
 		s := strings.TrimSpace(msg.Text)
-		x, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", "."), 64)
-		if err != nil || x < 0 {
-			b.send(tgbotapi.NewMessage(chatID, "Введите число (>= 0)"))
-			return
+		if !keepRateValue(st.Payload, s) {
+			x, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", "."), 64)
+			if err != nil || x < 0 {
+				b.send(tgbotapi.NewMessage(chatID, "Введите число (>= 0)"))
+				return
+			}
+			st.Payload["thr"] = x
 		}
-		st.Payload["thr"] = x
 		_ = b.states.Set(ctx, chatID, dialog.StateAdmRatesCreatePriceWith, st.Payload)
 
-		m := tgbotapi.NewMessage(chatID, "Цена за ед., если порог выполнен (руб)")
+		m := tgbotapi.NewMessage(chatID, "Цена за ед., если порог выполнен (руб)"+rateEditHint(st.Payload, "pwith"))
 		m.ReplyMarkup = navKeyboard(true, true)
 		b.send(m)
 		return
 
 	case dialog.StateAdmRatesCreatePriceWith:
 		s := strings.TrimSpace(msg.Text)
-		x, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", "."), 64)
-		if err != nil || x < 0 {
-			b.send(tgbotapi.NewMessage(chatID, "Введите число (>= 0)"))
-			return
+		if !keepRateValue(st.Payload, s) {
+			x, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", "."), 64)
+			if err != nil || x < 0 {
+				b.send(tgbotapi.NewMessage(chatID, "Введите число (>= 0)"))
+				return
+			}
+			st.Payload["pwith"] = x
 		}
-		st.Payload["pwith"] = x
 		_ = b.states.Set(ctx, chatID, dialog.StateAdmRatesCreatePriceOwn, st.Payload)
 
-		m := tgbotapi.NewMessage(chatID, "Цена за ед., если порог НЕ выполнен (руб)")
+		m := tgbotapi.NewMessage(chatID, "Цена за ед., если порог НЕ выполнен (руб)"+rateEditHint(st.Payload, "pown"))
 		m.ReplyMarkup = navKeyboard(true, true)
 		b.send(m)
 		return
 
 	case dialog.StateAdmRatesCreatePriceOwn:
 		s := strings.TrimSpace(msg.Text)
-		x, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", "."), 64)
-		if err != nil || x < 0 {
-			b.send(tgbotapi.NewMessage(chatID, "Введите число (>= 0)"))
-			return
+		if !keepRateValue(st.Payload, s) {
+			x, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", "."), 64)
+			if err != nil || x < 0 {
+				b.send(tgbotapi.NewMessage(chatID, "Введите число (>= 0)"))
+				return
+			}
+			st.Payload["pown"] = x
 		}
-		st.Payload["pown"] = x
-		_ = b.states.Set(ctx, chatID, dialog.StateAdmRatesConfirm, st.Payload)
 
-		place := st.Payload["place"].(string)
-		unit := st.Payload["unit"].(string)
-		withSub := false
-		if v, ok := st.Payload["with_sub"].(bool); ok {
-			withSub = v
+		next := rateFromPayload(st.Payload)
+		rates, err := b.cons.ListRates(ctx, b.now(), next.Place, next.Unit, next.WithSub)
+		if err != nil {
+			b.send(tgbotapi.NewMessage(chatID, "Ошибка загрузки тарифов"))
+			return
 		}
 
-		minQty := int(st.Payload["min"].(float64))
-		var maxTxt string
-		if st.Payload["max"] == nil {
-			maxTxt = "∞"
-		} else {
-			maxTxt = fmt.Sprintf("%d", int(st.Payload["max"].(float64)))
+		var old *consumption.TierRate
+		for i := range rates {
+			if rates[i].ID == next.ID {
+				old = &rates[i]
+				next.Active = old.Active
+			}
 		}
-		thr := st.Payload["thr"].(float64)
-		pwith := st.Payload["pwith"].(float64)
-		pown := st.Payload["pown"].(float64)
+		rng := consumption.QtyRange{Min: next.MinQty, Max: next.MaxQty}
+		if next.Active {
+			if other := consumption.TierOverlap(rates, next.ID, rng); other != nil {
+				_ = b.states.Set(ctx, chatID, dialog.StateAdmRatesCreateMin, st.Payload)
+				m := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+					"Диапазон %s пересекается со ступенью %s. Введите минимальное значение диапазона заново."+rateEditHint(st.Payload, "min"),
+					rng, consumption.QtyRange{Min: other.MinQty, Max: other.MaxQty}))
+				m.ReplyMarkup = navKeyboard(true, true)
+				b.send(m)
+				return
+			}
+		}
+		gaps, err := b.rateChangeGaps(ctx, next.Place, next.Unit, next.WithSub, old, &next)
+		if err != nil {
+			b.send(tgbotapi.NewMessage(chatID, "Ошибка загрузки тарифов"))
+			return
+		}
+		if len(gaps) > 0 {
+			_ = b.states.Set(ctx, chatID, dialog.StateAdmRatesCreateMin, st.Payload)
+			m := tgbotapi.NewMessage(chatID, rateGapRefusal(gaps)+
+				". Введите минимальное значение диапазона заново."+rateEditHint(st.Payload, "min"))
+			m.ReplyMarkup = navKeyboard(true, true)
+			b.send(m)
+			return
+		}
+		_ = b.states.Set(ctx, chatID, dialog.StateAdmRatesConfirm, st.Payload)
 
+		title := "Ступень"
+		if old != nil {
+			title = "Изменение ступени"
+		}
 		preview := fmt.Sprintf(
-			"Ступень:\n— %s / %s (%s)\n— Диапазон: %d–%s\n— Порог: %.0f\n— Цена с материалами: %.2f\n— Цена со своими: %.2f\n\nСохранить?",
-			map[string]string{"hall": "Зал", "cabinet": "Кабинет"}[place],
-			map[string]string{"hour": "час", "day": "день"}[unit],
-			map[bool]string{true: "с абонементом", false: "без абонемента"}[withSub],
-			minQty, maxTxt, thr, pwith, pown,
+			"%s:\n— %s\n— Диапазон: %s\n— Порог: %.0f\n— Цена с материалами: %.2f\n— Цена со своими: %.2f",
+			title, ratesSetTitle(next.Place, next.Unit, next.WithSub), rng, next.Threshold, next.PriceWith, next.PriceOwn,
 		)
+		if old != nil {
+			preview += fmt.Sprintf("\n\nБыло: %s", tierRateLine(*old))
+		}
+		if next.Active {
+			if warn := b.rateChangeWarnings(ctx, next.Place, next.Unit, next.WithSub, old, &next); warn != "" {
+				preview += "\n\n" + warn
+			}
+		}
+		preview += "\n\nСохранить?"
 
 		kb := tgbotapi.NewInlineKeyboardMarkup(
 			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("💾 Сохранить", "rates:save")),
@@ -1239,30 +1527,12 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 		return
 
 	case dialog.StateAdmReportRentPeriod:
-		period := strings.TrimSpace(msg.Text)
-		dates := strings.Split(period, "-")
-		if len(dates) != 2 {
-			b.send(tgbotapi.NewMessage(chatID, "Неверный формат. Используйте ДД.ММ.ГГГГ-ДД.ММ.ГГГГ, например 01.11.2025-30.11.2025."))
-			return
-		}
-		const layout = "02.01.2006"
-		fromStr := strings.TrimSpace(dates[0])
-		toStr := strings.TrimSpace(dates[1])
-
-		from, err1 := time.Parse(layout, fromStr)
-		to, err2 := time.Parse(layout, toStr)
-		if err1 != nil || err2 != nil {
-			b.send(tgbotapi.NewMessage(chatID, "Не удалось разобрать дату. Проверьте формат ДД.ММ.ГГГГ."))
-			return
-		}
-		if !to.After(from) && !to.Equal(from) {
-			b.send(tgbotapi.NewMessage(chatID, "Дата окончания должна быть не раньше даты начала."))
+		from, toExclusive, errText := parsePeriod(msg.Text, b.loc)
+		if errText != "" {
+			b.send(tgbotapi.NewMessage(chatID, errText))
 			return
 		}
 
-		// делаем to эксклюзивной границей: +1 день
-		toExclusive := to.Add(24 * time.Hour)
-
 		if err := b.handleAdmRentMaterialsReport(ctx, chatID, from, toExclusive); err != nil {
 			b.send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Ошибка формирования отчёта: %v", err)))
 			return
@@ -1271,40 +1541,36 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 		_ = b.states.Set(ctx, chatID, dialog.StateIdle, dialog.Payload{})
 		return
 
-	case dialog.StateAdmBroadcastAll:
-		textToSend := strings.TrimSpace(msg.Text)
-		if textToSend == "" {
-			b.send(tgbotapi.NewMessage(chatID, "Текст оповещения не может быть пустым. Введите сообщение."))
+	case dialog.StateAdmReportAnalytics:
+		from, toExclusive, errText := parsePeriod(msg.Text, b.loc)
+		if errText != "" {
+			b.send(tgbotapi.NewMessage(chatID, errText))
 			return
 		}
 
-		u, _ := b.users.GetByTelegramID(ctx, tgID)
-		if u == nil || u.Status != users.StatusApproved || u.Role != users.RoleAdmin {
-			b.send(tgbotapi.NewMessage(chatID, "Недостаточно прав для рассылки."))
-			_ = b.states.Reset(ctx, chatID)
+		if err := b.handleAdmAnalytics(ctx, chatID, from, toExclusive); err != nil {
+			b.send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Ошибка расчёта аналитики: %v", err)))
 			return
 		}
 
-		ids, err := b.users.ListApprovedTelegramIDs(ctx)
-		if err != nil {
-			b.send(tgbotapi.NewMessage(chatID,
-				fmt.Sprintf("Не удалось получить список пользователей: %v", err)))
-			_ = b.states.Reset(ctx, chatID)
+		_ = b.states.Set(ctx, chatID, dialog.StateIdle, dialog.Payload{})
+		return
+
+	case dialog.StateAdmAuditExportPeriod:
+		from, toExclusive, errText := parsePeriod(msg.Text, b.loc)
+		if errText != "" {
+			b.send(tgbotapi.NewMessage(chatID, errText))
 			return
 		}
+		b.exportAuditExcel(ctx, chatID, from, toExclusive)
+		return
 
-		sent := 0
-		for _, id := range ids {
-			if id == 0 {
-				continue
-			}
-			b.send(tgbotapi.NewMessage(id, textToSend))
-			sent++
-		}
+	case dialog.StateAdmBroadcastContent:
+		b.handleBroadcastContent(ctx, msg)
+		return
 
-		b.send(tgbotapi.NewMessage(chatID,
-			fmt.Sprintf("Оповещение отправлено %d пользователям.", sent)))
-		_ = b.states.Reset(ctx, chatID)
+	case dialog.StateAdmBroadcastSchedule:
+		b.handleBroadcastScheduleInput(ctx, msg, st)
 		return
 
 	case dialog.StateMasterStockSearchByName:
@@ -1338,26 +1604,40 @@ func (b *Bot) handleStateMessage(ctx context.Context, msg *tgbotapi.Message) {
 			return
 		}
 
-		qLower := strings.ToLower(query)
+		opt := materials.SearchOptions{WarehouseID: whID, OnlyActive: true, Limit: masterStockSearchLimit}
+		if u := b.currentUser(ctx); u != nil {
+			opt.UserID = u.ID
+		}
+		mats, total, err := b.materials.Search(ctx, query, opt)
+		if err != nil {
+			b.send(tgbotapi.NewMessage(chatID, "Ошибка поиска материалов."))
+			return
+		}
+		byID := make(map[int64]materials.MatWithBal, len(items))
+		for _, it := range items {
+			byID[it.ID] = it
+		}
+
 		var sb strings.Builder
 		_, _ = fmt.Fprintf(&sb, "Склад: %s\nПоиск по названию: %s\n\n", warehouseName, query)
 
 		found := 0
-		for _, it := range items {
-			searchText := strings.ToLower(materialDisplayName(it.Brand, it.Name))
-			if !strings.Contains(searchText, qLower) {
+		for _, m := range mats {
+			it, ok := byID[m.ID]
+			if !ok {
 				continue
 			}
 			found++
-			_, _ = fmt.Fprintf(&sb, "• %s — %s %s\n",
+			_, _ = fmt.Fprintf(&sb, "• %s — %s\n",
 				materialDisplayName(it.Brand, it.Name),
-				formatQty(it.Balance),
-				materialUnitLabel(string(it.Unit)),
+				formatMaterialQty(it.AsMaterial(), it.Balance),
 			)
 		}
 
 		if found == 0 {
 			sb.WriteString("По этому запросу ничего не найдено.")
+		} else if total > len(mats) {
+			_, _ = fmt.Fprintf(&sb, "\nПоказаны первые %d из %d. Уточните запрос, чтобы сузить поиск.", len(mats), total)
 		}
 
 		kb := tgbotapi.NewInlineKeyboardMarkup(
@@ -1394,10 +1674,32 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		st, _ := b.states.Get(ctx, fromChat)
 		switch st.State {
 		case dialog.StateAwaitRole:
-			_ = b.states.Set(ctx, fromChat, dialog.StateAwaitFIO, dialog.Payload{})
-			b.editTextAndClear(fromChat, cb.Message.MessageID, "Измените ФИО и отправьте сообщением.")
-			b.askFIO(fromChat)
+			_ = b.states.Set(ctx, fromChat, dialog.StateAwaitPhone, st.Payload)
+			b.editTextAndClear(fromChat, cb.Message.MessageID, "Измените телефон.")
+			b.askPhone(fromChat)
+		case dialog.StateAwaitSpec:
+			if payloadString(st.Payload, "invite_code") != "" {
+				_ = b.states.Set(ctx, fromChat, dialog.StateAwaitPhone, st.Payload)
+				b.editTextAndClear(fromChat, cb.Message.MessageID, "Измените телефон.")
+				b.askPhone(fromChat)
+				break
+			}
+			_ = b.states.Set(ctx, fromChat, dialog.StateAwaitRole, st.Payload)
+			edit := tgbotapi.NewEditMessageTextAndMarkup(fromChat, cb.Message.MessageID, "Выберите роль:", roleKeyboard())
+			b.send(edit)
 		case dialog.StateAwaitConfirm:
+			if users.Role(payloadString(st.Payload, "role")) == users.RoleMaster {
+				_ = b.states.Set(ctx, fromChat, dialog.StateAwaitSpec, st.Payload)
+				b.send(tgbotapi.NewEditMessageTextAndMarkup(fromChat, cb.Message.MessageID,
+					"Выберите специализацию (можно несколько):", registrationSpecKeyboard(payloadStrings(st.Payload, "specs"))))
+				break
+			}
+			if payloadString(st.Payload, "invite_code") != "" {
+				_ = b.states.Set(ctx, fromChat, dialog.StateAwaitPhone, st.Payload)
+				b.editTextAndClear(fromChat, cb.Message.MessageID, "Измените телефон.")
+				b.askPhone(fromChat)
+				break
+			}
 			_ = b.states.Set(ctx, fromChat, dialog.StateAwaitRole, st.Payload)
 			edit := tgbotapi.NewEditMessageTextAndMarkup(fromChat, cb.Message.MessageID, "Выберите роль:", roleKeyboard())
 			b.send(edit)
@@ -1494,6 +1796,35 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 
 			b.showMaterialList(ctx, fromChat, cb.Message.MessageID)
 			_ = b.states.Set(ctx, fromChat, dialog.StateAdmMatList, dialog.Payload{})
+		case dialog.StateAdmUsersSearch, dialog.StateAdmUserCard, dialog.StateAdmInvites:
+			b.showAdminUsersList(ctx, fromChat, &cb.Message.MessageID,
+				payloadString(st.Payload, "query"), payloadInt(st.Payload, "page"))
+		case dialog.StateAdmUserRename, dialog.StateAdmUserSwitchRole, dialog.StateAdmUserProfile:
+			b.showAdminUserCard(ctx, fromChat, &cb.Message.MessageID, payloadInt64(st.Payload["user_id"]), st.Payload)
+		case dialog.StateAdmInviteNew:
+			if picking, _ := st.Payload["wh_pick"].(bool); picking {
+				b.showAdminInviteNew(ctx, fromChat, cb.Message.MessageID, st.Payload)
+				break
+			}
+			b.showAdminInvites(ctx, fromChat, cb.Message.MessageID)
+		case dialog.StateAdmInviteCard:
+			b.showAdminInvites(ctx, fromChat, cb.Message.MessageID)
+		case dialog.StateAdmAuditList:
+			if payloadInt64(st.Payload["entity_id"]) > 0 {
+				b.showAuditList(ctx, fromChat, &cb.Message.MessageID, payloadString(st.Payload, "entity"), 0, 0)
+				break
+			}
+			b.showAuditSections(ctx, fromChat, &cb.Message.MessageID)
+		case dialog.StateAdmAuditEvent:
+			b.showAuditList(ctx, fromChat, &cb.Message.MessageID, payloadString(st.Payload, "entity"),
+				payloadInt64(st.Payload["entity_id"]), payloadInt(st.Payload, "page"))
+		case dialog.StateAdmAuditExportPeriod:
+			b.showAuditSections(ctx, fromChat, &cb.Message.MessageID)
+		case dialog.StateAdmUserProfileField:
+			b.showAdminUserProfile(ctx, fromChat, cb.Message.MessageID, payloadInt64(st.Payload["user_id"]), st.Payload)
+		case dialog.StateAdmMatSearch:
+			b.showMaterialMenu(fromChat, &cb.Message.MessageID)
+			_ = b.states.Set(ctx, fromChat, dialog.StateAdmMatMenu, dialog.Payload{})
 		case dialog.StateAdmMatUnit:
 			// из выбора единицы — назад в карточку
 			if idAny, ok := st.Payload["mat_id"]; ok {
@@ -1504,6 +1835,16 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 				b.showMaterialMenu(fromChat, &cb.Message.MessageID)
 				_ = b.states.Set(ctx, fromChat, dialog.StateAdmMatMenu, dialog.Payload{})
 			}
+		case dialog.StateAdmMatPurchaseUnit, dialog.StateAdmMatPackFactor, dialog.StateAdmMatBarcode, dialog.StateAdmBrandAlias:
+			// из настройки упаковки — назад в карточку
+			if idAny, ok := st.Payload["mat_id"]; ok {
+				id := payloadInt64(idAny)
+				b.showMaterialItemMenu(ctx, fromChat, cb.Message.MessageID, id)
+				_ = b.states.Set(ctx, fromChat, dialog.StateAdmMatItem, dialog.Payload{"mat_id": id})
+			} else {
+				b.showMaterialMenu(fromChat, &cb.Message.MessageID)
+				_ = b.states.Set(ctx, fromChat, dialog.StateAdmMatMenu, dialog.Payload{})
+			}
 		case dialog.StateAdmMatRename:
 			// из переименования — назад в карточку
 			if idAny, ok := st.Payload["mat_id"]; ok {
@@ -1578,11 +1919,14 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 
 			_ = b.states.Set(ctx, fromChat, dialog.StateSupPickMat, st.Payload)
 			_ = b.states.Set(ctx, fromChat, dialog.StateSupPickMat, st.Payload)
+		case dialog.StateSupBarcode:
+			_ = b.states.Set(ctx, fromChat, dialog.StateSupPickMat, st.Payload)
+			b.showSuppliesPickMaterial(ctx, fromChat, cb.Message.MessageID, 0)
 		case dialog.StateSupUnitPrice:
-			b.editTextWithNav(fromChat, cb.Message.MessageID, "Введите количество (число, например 250)")
+			b.editTextWithNav(fromChat, cb.Message.MessageID, b.supQtyPromptByID(ctx, payloadInt64(st.Payload["mat_id"])))
 			_ = b.states.Set(ctx, fromChat, dialog.StateSupQty, st.Payload)
 		case dialog.StateSupConfirm:
-			b.editTextWithNav(fromChat, cb.Message.MessageID, "Введите цену за единицу (руб)")
+			b.editTextWithNav(fromChat, cb.Message.MessageID, b.supPricePromptByID(ctx, payloadInt64(st.Payload["mat_id"])))
 			_ = b.states.Set(ctx, fromChat, dialog.StateSupUnitPrice, st.Payload)
 		case dialog.StateSupCart:
 			// Возврат к редактированию последней добавленной позиции
@@ -1605,7 +1949,7 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 				"items":  items,
 			}
 			_ = b.states.Set(ctx, fromChat, dialog.StateSupUnitPrice, payload)
-			b.editTextWithNav(fromChat, cb.Message.MessageID, "Введите цену за единицу (руб)")
+			b.editTextWithNav(fromChat, cb.Message.MessageID, b.supPricePromptByID(ctx, payloadInt64(last["mat_id"])))
 			return
 		case dialog.StateConsStudioAmount:
 			_ = b.states.Set(ctx, fromChat, dialog.StateConsPlace, st.Payload)
@@ -1613,7 +1957,7 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		case dialog.StateConsQty:
 			// назад к выбору помещения
 			_ = b.states.Set(ctx, fromChat, dialog.StateConsPlace, st.Payload)
-			b.showConsumptionPlaceStep(fromChat, &cb.Message.MessageID)
+			b.showConsumptionPlaceStep(ctx, fromChat, &cb.Message.MessageID)
 		case dialog.StateConsCart:
 			if isConsumptionStudioClient(st.Payload) {
 				_ = b.states.Set(ctx, fromChat, dialog.StateConsStudioAmount, st.Payload)
@@ -1721,12 +2065,32 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 
 			_ = b.states.Set(ctx, fromChat, dialog.StateConsMatSearch, st.Payload)
 			b.showConsMaterialSearchMenu(fromChat, cb.Message.MessageID)
+		case dialog.StateConsPromoCode:
+			b.showConsumptionReceiptForConfirm(ctx, fromChat, cb.Message.MessageID, cb.From.ID, st.Payload)
+
 		case dialog.StateConsSummary:
 			// назад в корзину
 			items := b.consParseItems(st.Payload["items"])
 			_ = b.states.Set(ctx, fromChat, dialog.StateConsCart, st.Payload)
 			b.showConsCart(ctx, fromChat, &cb.Message.MessageID, st.Payload["place"].(string), st.Payload["unit"].(string), int(st.Payload["qty"].(float64)), items)
 
+		case dialog.StateConsTplPick, dialog.StateConsBarcode:
+			_ = b.states.Set(ctx, fromChat, dialog.StateConsMatSearch, st.Payload)
+			b.showConsMaterialSearchMenu(fromChat, cb.Message.MessageID)
+		case dialog.StateConsTplName, dialog.StateConsItemPick:
+			_ = b.states.Set(ctx, fromChat, dialog.StateConsCart, st.Payload)
+			b.showConsCartFromPayload(ctx, fromChat, &cb.Message.MessageID, st.Payload)
+		case dialog.StateConsItemQty:
+			delete(st.Payload, "cons_item_idx")
+			_ = b.states.Set(ctx, fromChat, dialog.StateConsItemPick, st.Payload)
+			b.showConsCartItems(ctx, fromChat, cb.Message.MessageID, b.consParseItems(st.Payload["items"]))
+		case dialog.StateAdmTplMenu:
+			b.showMaterialMenu(fromChat, &cb.Message.MessageID)
+			_ = b.states.Set(ctx, fromChat, dialog.StateAdmMatMenu, dialog.Payload{})
+		case dialog.StateAdmTplImportFile:
+			b.showMaterialTemplatesMenu(fromChat, cb.Message.MessageID)
+			_ = b.states.Set(ctx, fromChat, dialog.StateAdmTplMenu, dialog.Payload{})
+
 		case dialog.StateAdmSubsMenu:
 			b.showSubsMenu(fromChat, &cb.Message.MessageID)
 			_ = b.states.Set(ctx, fromChat, dialog.StateAdmSubsMenu, dialog.Payload{})
@@ -1783,41 +2147,180 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 			b.showPriceMainMenu(fromChat, &cb.Message.MessageID)
 			_ = b.states.Set(ctx, fromChat, dialog.StatePriceMenu, dialog.Payload{})
 
-		case dialog.StatePriceRentImportFile:
+		case dialog.StatePriceRentImportFile, dialog.StatePriceRentVersions:
 			b.showPriceRentMenu(fromChat, &cb.Message.MessageID)
 			_ = b.states.Set(ctx, fromChat, dialog.StatePriceRentMenu, dialog.Payload{})
 
+		case dialog.StatePriceRentVersion:
+			b.showRentTariffVersions(ctx, fromChat, &cb.Message.MessageID)
+
+		case dialog.StatePriceDiscMenu:
+			b.showPriceMainMenu(fromChat, &cb.Message.MessageID)
+			_ = b.states.Set(ctx, fromChat, dialog.StatePriceMenu, dialog.Payload{})
+
+		case dialog.StatePriceDiscImportFile:
+			b.showDiscountsMenu(ctx, fromChat, &cb.Message.MessageID)
+
+		case dialog.StateAdmRatesCard:
+			b.showRatesList(ctx, fromChat, &cb.Message.MessageID, st.Payload)
+
+		case dialog.StatePriceRentPublish:
+			b.showRentTariffVersion(ctx, fromChat, &cb.Message.MessageID, payloadInt64(st.Payload["version_id"]))
+
 		default:
 			b.editTextAndClear(fromChat, cb.Message.MessageID, "Действие неактуально.")
 		}
-		_ = b.answerCallback(cb, "Назад", false)
+		_ = b.answerCallback(cb, "Назад", false)
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(data, "adminchat:history:"):
+		pageStr := strings.TrimPrefix(data, "adminchat:history:")
+
+		page, err := strconv.Atoi(pageStr)
+		if err != nil {
+			_ = b.answerCallback(cb, "Некорректная страница", true)
+			return
+		}
+
+		// кнопки старого общего списка ведут в список обращений
+		b.showAdminChatInbox(ctx, fromChat, nil, "a", page)
+
+		_ = b.answerCallback(cb, "История обновлена", false)
+		return
+
+	case strings.HasPrefix(data, "adminchat:inbox:"):
+		parts := strings.Split(strings.TrimPrefix(data, "adminchat:inbox:"), ":")
+		if len(parts) != 2 {
+			_ = b.answerCallback(cb, "Некорректные данные", true)
+			return
+		}
+		page, _ := strconv.Atoi(parts[1])
+		b.showAdminChatInbox(ctx, fromChat, &cb.Message.MessageID, parts[0], page)
+		_ = b.answerCallback(cb, "", false)
+		return
+
+	case strings.HasPrefix(data, "adminchat:s:"):
+		b.handleAdminChatSearchCallback(ctx, cb, strings.TrimPrefix(data, "adminchat:s:"))
+		return
+
+	case strings.HasPrefix(data, "adminchat:exp:"):
+		threadID, _ := strconv.ParseInt(strings.TrimPrefix(data, "adminchat:exp:"), 10, 64)
+		if !b.startAdminChatExport(ctx, fromChat, threadID) {
+			_ = b.answerCallback(cb, "Выгрузка этого обращения уже готовится", false)
+			return
+		}
+		_ = b.answerCallback(cb, "Готовлю выгрузку — пришлю файл, когда будет готов", false)
+		return
+
+	case data == "adminchat:find":
+		_ = b.states.Set(ctx, fromChat, dialog.StateAdmChatFind, dialog.Payload{})
+		m := tgbotapi.NewMessage(fromChat, "Введите часть фамилии, имени или username мастера:")
+		m.ReplyMarkup = navKeyboard(false, true)
+		b.send(m)
+		_ = b.answerCallback(cb, "", false)
+		return
+
+	case strings.HasPrefix(data, "adminchat:open:"):
+		// из уведомления — карточка отдельным сообщением, уведомление остаётся
+		threadID, err := strconv.ParseInt(strings.TrimPrefix(data, "adminchat:open:"), 10, 64)
+		if err != nil {
+			_ = b.answerCallback(cb, "Некорректный ID", true)
+			return
+		}
+		b.showAdminChatThread(ctx, fromChat, nil, threadID, 0)
+		_ = b.answerCallback(cb, "", false)
+		return
+
+	case strings.HasPrefix(data, "adminchat:t:"):
+		parts := strings.Split(strings.TrimPrefix(data, "adminchat:t:"), ":")
+		if len(parts) != 2 {
+			_ = b.answerCallback(cb, "Некорректные данные", true)
+			return
+		}
+		threadID, _ := strconv.ParseInt(parts[0], 10, 64)
+		page, _ := strconv.Atoi(parts[1])
+		b.showAdminChatThread(ctx, fromChat, &cb.Message.MessageID, threadID, page)
+		_ = b.answerCallback(cb, "", false)
 		return
-	}
-
-	switch {
-	case strings.HasPrefix(data, "adminchat:history:"):
-		pageStr := strings.TrimPrefix(data, "adminchat:history:")
 
-		page, err := strconv.Atoi(pageStr)
-		if err != nil {
-			_ = b.answerCallback(cb, "Некорректная страница", true)
+	case strings.HasPrefix(data, "adminchat:tr:"):
+		threadID, _ := strconv.ParseInt(strings.TrimPrefix(data, "adminchat:tr:"), 10, 64)
+		m, err := b.adminChatRepo.LastAuthorMessage(ctx, threadID)
+		if err != nil || m == nil {
+			_ = b.answerCallback(cb, "В обращении нет сообщений автора", true)
 			return
 		}
+		b.startAdminChatReply(ctx, fromChat, m)
+		_ = b.answerCallback(cb, "Режим ответа включён", false)
+		return
 
-		u, _ := b.users.GetByTelegramID(ctx, cb.From.ID)
-		if u == nil || u.Status != users.StatusApproved {
-			_ = b.answerCallback(cb, "Нет доступа", true)
+	case strings.HasPrefix(data, "adminchat:take:"):
+		threadID, _ := strconv.ParseInt(strings.TrimPrefix(data, "adminchat:take:"), 10, 64)
+		u := b.currentUser(ctx)
+		if u == nil {
+			return
+		}
+		if err := b.assignAdminChatThread(ctx, threadID, u, u); err != nil {
+			b.log.Error("assign admin chat thread failed", "thread", threadID, "err", err)
+			_ = b.answerCallback(cb, "Не удалось назначить", true)
 			return
 		}
+		b.showAdminChatThread(ctx, fromChat, &cb.Message.MessageID, threadID, 0)
+		_ = b.answerCallback(cb, "Обращение ваше", false)
+		return
 
-		if u.Role != users.RoleAdmin && u.Role != users.RoleAdministrator {
-			_ = b.answerCallback(cb, "Недостаточно прав", true)
+	case strings.HasPrefix(data, "adminchat:asgto:"):
+		parts := strings.Split(strings.TrimPrefix(data, "adminchat:asgto:"), ":")
+		if len(parts) != 2 {
+			_ = b.answerCallback(cb, "Некорректные данные", true)
+			return
+		}
+		threadID, _ := strconv.ParseInt(parts[0], 10, 64)
+		userID, _ := strconv.ParseInt(parts[1], 10, 64)
+		u := b.currentUser(ctx)
+		assignee, err := b.users.GetByID(ctx, userID)
+		if u == nil || err != nil || assignee == nil {
+			_ = b.answerCallback(cb, "Пользователь не найден", true)
+			return
+		}
+		if err := b.assignAdminChatThread(ctx, threadID, assignee, u); err != nil {
+			b.log.Error("assign admin chat thread failed", "thread", threadID, "err", err)
+			_ = b.answerCallback(cb, "Не удалось назначить", true)
 			return
 		}
+		b.showAdminChatThread(ctx, fromChat, &cb.Message.MessageID, threadID, 0)
+		_ = b.answerCallback(cb, "Ответственный назначен", false)
+		return
 
-		b.showAdminChatHistory(ctx, fromChat, page)
+	case strings.HasPrefix(data, "adminchat:asg:"):
+		threadID, _ := strconv.ParseInt(strings.TrimPrefix(data, "adminchat:asg:"), 10, 64)
+		b.showAdminChatAssign(ctx, fromChat, cb.Message.MessageID, threadID)
+		_ = b.answerCallback(cb, "", false)
+		return
 
-		_ = b.answerCallback(cb, "История обновлена", false)
+	case strings.HasPrefix(data, "adminchat:st:"):
+		parts := strings.Split(strings.TrimPrefix(data, "adminchat:st:"), ":")
+		if len(parts) != 2 {
+			_ = b.answerCallback(cb, "Некорректные данные", true)
+			return
+		}
+		threadID, _ := strconv.ParseInt(parts[0], 10, 64)
+		status := adminchat.ThreadStatus(parts[1])
+		switch status {
+		case adminchat.ThreadOpen, adminchat.ThreadInProgress, adminchat.ThreadResolved:
+		default:
+			_ = b.answerCallback(cb, "Некорректный статус", true)
+			return
+		}
+		if err := b.setAdminChatThreadStatus(ctx, threadID, status); err != nil {
+			b.log.Error("set admin chat thread status failed", "thread", threadID, "err", err)
+			_ = b.answerCallback(cb, "Не удалось изменить статус", true)
+			return
+		}
+		b.showAdminChatThread(ctx, fromChat, &cb.Message.MessageID, threadID, 0)
+		_ = b.answerCallback(cb, "Статус: "+adminChatStatusLabel(status), false)
 		return
 
 	case strings.HasPrefix(data, "adminchat:media:"):
@@ -1829,17 +2332,6 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 			return
 		}
 
-		u, _ := b.users.GetByTelegramID(ctx, cb.From.ID)
-		if u == nil || u.Status != users.StatusApproved {
-			_ = b.answerCallback(cb, "Нет доступа", true)
-			return
-		}
-
-		if u.Role != users.RoleAdmin && u.Role != users.RoleAdministrator {
-			_ = b.answerCallback(cb, "Недостаточно прав", true)
-			return
-		}
-
 		m, err := b.adminChatRepo.GetByID(ctx, messageID)
 		if err != nil || m == nil {
 			_ = b.answerCallback(cb, "Сообщение не найдено", true)
@@ -1851,7 +2343,7 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 			return
 		}
 
-		b.sendAdminChatHistoryMedia(fromChat, m)
+		b.sendAdminChatHistoryMedia(ctx, fromChat, m)
 
 		_ = b.answerCallback(cb, "Вложение отправлено", false)
 		return
@@ -1871,27 +2363,7 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 			return
 		}
 
-		st, _ := b.states.Get(ctx, fromChat)
-
-		if st.Payload == nil {
-			st.Payload = dialog.Payload{}
-		}
-
-		st.Payload["reply_to_admin_chat_message_id"] = replyToID
-
-		_ = b.states.Set(ctx, fromChat, dialog.StateChatAdmin, st.Payload)
-
-		replyText := fmt.Sprintf(
-			"↩️ Ответ на сообщение #%d\n\nОт: %s\nТип: %s\n\nТеперь отправьте сообщение, фото или файл.",
-			msgReply.ID,
-			strings.TrimSpace(msgReply.SenderUsername),
-			adminChatMessageTypeLabel(msgReply.MessageType),
-		)
-
-		msg := tgbotapi.NewMessage(fromChat, replyText)
-		msg.ReplyMarkup = adminChatCancelKeyboard()
-
-		b.send(msg)
+		b.startAdminChatReply(ctx, fromChat, msgReply)
 
 		_ = b.answerCallback(cb, "Режим ответа включён", false)
 		return
@@ -1899,8 +2371,8 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 	case strings.HasPrefix(data, "role:switch:"):
 		role := users.Role(strings.TrimPrefix(data, "role:switch:"))
 
-		u, err := b.users.GetByTelegramID(ctx, cb.From.ID)
-		if err != nil || u == nil || u.Status != users.StatusApproved {
+		u := b.currentUser(ctx)
+		if u == nil || u.Status != users.StatusApproved {
 			_ = b.answerCallback(cb, "Пользователь не найден", true)
 			return
 		}
@@ -1948,13 +2420,51 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 			_ = b.answerCallback(cb, "Неактуально", false)
 			return
 		}
-		fio, _ := dialog.GetString(st.Payload, "fio")
 		p := st.Payload
 		p["role"] = string(role)
-		_ = b.states.Set(ctx, fromChat, dialog.StateAwaitConfirm, p)
-		confirmText := fmt.Sprintf("Проверьте данные:\n— ФИО: %s\n— Роль: %s\n\nОтправить заявку администратору?", fio, role)
-		edit := tgbotapi.NewEditMessageTextAndMarkup(fromChat, cb.Message.MessageID, confirmText, confirmKeyboard())
-		b.send(edit)
+		next, text, kb := registrationAfterRole(p)
+		_ = b.states.Set(ctx, fromChat, next, p)
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(fromChat, cb.Message.MessageID, text, kb))
+		_ = b.answerCallback(cb, "Ок", false)
+		return
+
+	case strings.HasPrefix(data, "reg:spec:"):
+		st, _ := b.states.Get(ctx, fromChat)
+		if st == nil || st.State != dialog.StateAwaitSpec {
+			_ = b.answerCallback(cb, "Неактуально", false)
+			return
+		}
+		p := st.Payload
+		selected := payloadStrings(p, "specs")
+		code := strings.TrimPrefix(data, "reg:spec:")
+		if code == "done" {
+			if len(selected) == 0 {
+				_ = b.answerCallback(cb, "Выберите хотя бы одну специализацию", true)
+				return
+			}
+			if u := b.currentUser(ctx); u != nil {
+				_ = b.users.SetSpecializations(ctx, u.ID, payloadSpecs(p))
+			}
+			_ = b.states.Set(ctx, fromChat, dialog.StateAwaitConfirm, p)
+			b.send(tgbotapi.NewEditMessageTextAndMarkup(fromChat, cb.Message.MessageID, registrationConfirmText(p), confirmKeyboard()))
+			_ = b.answerCallback(cb, "Ок", false)
+			return
+		}
+		next := make([]string, 0, len(selected)+1)
+		found := false
+		for _, s := range selected {
+			if s == code {
+				found = true
+				continue
+			}
+			next = append(next, s)
+		}
+		if !found {
+			next = append(next, code)
+		}
+		p["specs"] = next
+		_ = b.states.Set(ctx, fromChat, dialog.StateAwaitSpec, p)
+		b.send(tgbotapi.NewEditMessageReplyMarkup(fromChat, cb.Message.MessageID, registrationSpecKeyboard(next)))
 		_ = b.answerCallback(cb, "Ок", false)
 		return
 
@@ -1967,42 +2477,53 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		fio, _ := dialog.GetString(st.Payload, "fio")
 		roleStr, _ := dialog.GetString(st.Payload, "role")
 		role := users.Role(roleStr)
-		_, _ = b.users.UpsertByTelegram(ctx, cb.From.ID, role)
-		b.editTextAndClear(fromChat, cb.Message.MessageID, "Заявка отправлена администратору. Ожидайте решения.")
+		u, _ := b.users.UpsertByTelegram(ctx, cb.From.ID, role)
+		if err := b.users.Resubmit(ctx, cb.From.ID); err != nil {
+			b.log.Error("resubmit registration failed", "tg_id", cb.From.ID, "err", err)
+		}
 		_ = b.states.Reset(ctx, fromChat)
 
 		text := fmt.Sprintf(
-			"Новая заявка на доступ:\n— ФИО: %s\n— Telegram: @%s (id %d)\n— Роль: %s\n\nОдобрить?",
-			fio, cb.From.UserName, cb.From.ID, role,
+			"— ФИО: %s\n— Телефон: %s\n— Telegram: @%s (id %d)\n— Роль: %s\n",
+			fio, orDash(payloadString(st.Payload, "phone")), cb.From.UserName, cb.From.ID, roleLabel(role),
 		)
+		if role == users.RoleMaster {
+			text += fmt.Sprintf("— Специализация: %s\n", specializationsLabel(payloadSpecs(st.Payload)))
+		}
+
+		inv := b.applyInvite(ctx, fromChat, payloadString(st.Payload, "invite_code"), u)
+		if inv != nil && inv.AutoApprove {
+			if _, err := b.users.Approve(ctx, cb.From.ID, inv.Role); err != nil {
+				b.log.Error("invite auto-approve failed", "tg_id", cb.From.ID, "err", err)
+			} else {
+				b.editTextAndClear(fromChat, cb.Message.MessageID, "Регистрация завершена. Добро пожаловать!")
+				b.sendMenuForRole(fromChat, inv.Role)
+				b.notifyCapability(ctx, access.CapUsersApprove,
+					fmt.Sprintf("Новый пользователь по приглашению #%d:\n%s", inv.ID, text), nil)
+				_ = b.answerCallback(cb, "Готово", false)
+				return
+			}
+		}
+
+		b.editTextAndClear(fromChat, cb.Message.MessageID, "Заявка отправлена администратору. Ожидайте решения.")
+		header := "Новая заявка на доступ:\n"
+		if inv != nil {
+			header = fmt.Sprintf("Новая заявка на доступ (приглашение #%d):\n", inv.ID)
+		}
+		text = header + text + "\nОдобрить?"
 		kb := tgbotapi.NewInlineKeyboardMarkup(
 			tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("✅ Одобрить", fmt.Sprintf("approve:%d:%s", cb.From.ID, role)),
 				tgbotapi.NewInlineKeyboardButtonData("⛔ Отклонить", fmt.Sprintf("reject:%d", cb.From.ID)),
 			),
 		)
-		sent := false
-
-		for adminID := range b.adminIDs {
-			m := tgbotapi.NewMessage(adminID, text)
-			m.ReplyMarkup = kb
-			b.send(m)
-			sent = true
-		}
-
-		if !sent && b.adminChat != 0 {
-			m := tgbotapi.NewMessage(b.adminChat, text)
-			m.ReplyMarkup = kb
-			b.send(m)
+		if b.notifyCapability(ctx, access.CapUsersApprove, text, &kb) == 0 {
+			b.log.Warn("registration request has no recipients", "tg_id", cb.From.ID)
 		}
 		_ = b.answerCallback(cb, "Отправлено", false)
 		return
 
 	case strings.HasPrefix(data, "approve:"):
-		if !b.isAdminID(fromChat) {
-			_ = b.answerCallback(cb, "Недостаточно прав", true)
-			return
-		}
 		parts := strings.Split(strings.TrimPrefix(data, "approve:"), ":")
 		if len(parts) != 2 {
 			_ = b.answerCallback(cb, "Некорректные данные", true)
@@ -2010,7 +2531,15 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		}
 		tgID, _ := strconv.ParseInt(parts[0], 10, 64)
 		role := users.Role(parts[1])
-		if _, err := b.users.Approve(ctx, tgID, role); err != nil {
+		// заявка приходит всем админам — решение принимается только по заявке в ожидании:
+		// старая кнопка не снимает блокировку и не отменяет отказ
+		_, err := b.users.Approve(ctx, tgID, role)
+		if errors.Is(err, users.ErrNotPending) {
+			b.editTextAndClear(fromChat, cb.Message.MessageID, cb.Message.Text+"\n\nℹ️ Заявка уже обработана")
+			_ = b.answerCallback(cb, "Заявка уже обработана", true)
+			return
+		}
+		if err != nil {
 			_ = b.answerCallback(cb, "Ошибка при одобрении", true)
 			return
 		}
@@ -2021,12 +2550,14 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		return
 
 	case strings.HasPrefix(data, "reject:"):
-		if !b.isAdminID(fromChat) {
-			_ = b.answerCallback(cb, "Недостаточно прав", true)
+		tgID, _ := strconv.ParseInt(strings.TrimPrefix(data, "reject:"), 10, 64)
+		_, err := b.users.Reject(ctx, tgID)
+		if errors.Is(err, users.ErrNotPending) {
+			b.editTextAndClear(fromChat, cb.Message.MessageID, cb.Message.Text+"\n\nℹ️ Заявка уже обработана")
+			_ = b.answerCallback(cb, "Заявка уже обработана", true)
 			return
 		}
-		tgID, _ := strconv.ParseInt(strings.TrimPrefix(data, "reject:"), 10, 64)
-		if _, err := b.users.Reject(ctx, tgID); err != nil {
+		if err != nil {
 			_ = b.answerCallback(cb, "Ошибка при отклонении", true)
 			return
 		}
@@ -2039,45 +2570,45 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		return
 
 	case strings.HasPrefix(data, "subrq:approve:"):
-		if fromChat != b.adminChat {
-			_ = b.answerCallback(cb, "Недостаточно прав", true)
-			return
-		}
-		rest := strings.TrimPrefix(data, "subrq:approve:")
-		parts := strings.Split(rest, ":")
-		// tgID : place : unit : qty : thresholdTotal
-		if len(parts) != 5 {
-			_ = b.answerCallback(cb, "Некорректные данные", true)
+		// заявка приходит всем, кто может подтверждать, — абонемент создаётся только по первому нажатию
+		reqID, err := strconv.ParseInt(strings.TrimPrefix(data, "subrq:approve:"), 10, 64)
+		if err != nil {
+			// кнопки заявок, отправленных до хранения заявок в базе, с параметрами в callback
+			b.editTextAndClear(fromChat, cb.Message.MessageID, cb.Message.Text+"\n\nℹ️ Заявка устарела, попросите мастера отправить её заново.")
+			_ = b.answerCallback(cb, "Заявка устарела", true)
 			return
 		}
-
-		tgID, err1 := strconv.ParseInt(parts[0], 10, 64)
-		place := parts[1]
-		unit := parts[2]
-		qty, err2 := strconv.Atoi(parts[3])
-		thresholdTotal, err3 := strconv.ParseFloat(parts[4], 64)
-		if err1 != nil || err2 != nil || err3 != nil {
-			_ = b.answerCallback(cb, "Некорректные параметры", true)
+		admin := b.currentUser(ctx)
+		if admin == nil {
+			_ = b.answerCallback(cb, "Доступ запрещён", true)
 			return
 		}
 
-		u, err := b.users.GetByTelegramID(ctx, tgID)
-		if err != nil || u == nil {
-			_ = b.answerCallback(cb, "Мастер не найден", true)
+		req, sub, err := b.subs.ApproveRequest(ctx, reqID, admin.ID, b.now().Format("2006-01"))
+		if errors.Is(err, subsdomain.ErrRequestProcessed) {
+			b.editTextAndClear(fromChat, cb.Message.MessageID, cb.Message.Text+"\n\nℹ️ Заявка уже обработана")
+			_ = b.answerCallback(cb, "Заявка уже обработана", true)
 			return
 		}
-
-		month := time.Now().Format("2006-01")
-		if _, err := b.subs.AddOrCreateTotal(ctx, u.ID, place, unit, month, qty, thresholdTotal); err != nil {
+		if err != nil {
+			b.log.Error("approve subscription request failed", "request_id", reqID, "err", err)
 			_ = b.answerCallback(cb, "Ошибка при оформлении", true)
 			return
 		}
 
-		// мастеру — что абонемент оформлен
-		b.send(tgbotapi.NewMessage(
-			tgID,
-			"Абонемент оформлен/пополнен, посмотреть свои абонементы вы можете, нажав кнопку «Мои абонементы».",
-		))
+		u, err := b.users.GetByID(ctx, req.UserID)
+		if err == nil && u != nil {
+			// мастеру — что абонемент оформлен
+			b.send(tgbotapi.NewMessage(
+				u.TelegramID,
+				"Абонемент оформлен/пополнен, посмотреть свои абонементы вы можете, нажав кнопку «Мои абонементы».",
+			))
+			if pdfData, err := b.subscriptionPDF(*sub, b.userFullName(ctx, u)); err != nil {
+				b.log.Error("failed to build subscription pdf", "subscription_id", sub.ID, "err", err)
+			} else {
+				b.sendPDF(u.TelegramID, fmt.Sprintf("subscription_%d.pdf", sub.ID), pdfData, "Подтверждение покупки абонемента")
+			}
+		}
 
 		// админу — пометка в заявке
 		newText := cb.Message.Text + "\n\n✅ Приобретение абонемента подтверждено."
@@ -2086,22 +2617,36 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		return
 
 	case strings.HasPrefix(data, "subrq:reject:"):
-		if fromChat != b.adminChat {
-			_ = b.answerCallback(cb, "Недостаточно прав", true)
+		reqID, err := strconv.ParseInt(strings.TrimPrefix(data, "subrq:reject:"), 10, 64)
+		if err != nil {
+			_ = b.answerCallback(cb, "Некорректные данные", true)
+			return
+		}
+		admin := b.currentUser(ctx)
+		if admin == nil {
+			_ = b.answerCallback(cb, "Доступ запрещён", true)
+			return
+		}
+
+		req, err := b.subs.RejectRequest(ctx, reqID, admin.ID)
+		if errors.Is(err, subsdomain.ErrRequestProcessed) {
+			b.editTextAndClear(fromChat, cb.Message.MessageID, cb.Message.Text+"\n\nℹ️ Заявка уже обработана")
+			_ = b.answerCallback(cb, "Заявка уже обработана", true)
 			return
 		}
-		rest := strings.TrimPrefix(data, "subrq:reject:")
-		tgID, err := strconv.ParseInt(rest, 10, 64)
 		if err != nil {
-			_ = b.answerCallback(cb, "Некорректные данные", true)
+			b.log.Error("reject subscription request failed", "request_id", reqID, "err", err)
+			_ = b.answerCallback(cb, "Ошибка при отклонении", true)
 			return
 		}
 
 		// мастеру — отказ
-		b.send(tgbotapi.NewMessage(
-			tgID,
-			"Приобретение абонемента было отклонено, возможно не прошла ваша оплата, свяжитесь с администрацией для уточнения причины.",
-		))
+		if u, err := b.users.GetByID(ctx, req.UserID); err == nil && u != nil {
+			b.send(tgbotapi.NewMessage(
+				u.TelegramID,
+				"Приобретение абонемента было отклонено, возможно не прошла ваша оплата, свяжитесь с администрацией для уточнения причины.",
+			))
+		}
 
 		// админу — пометка в заявке
 		newText := cb.Message.Text + "\n\n⛔ Приобретение абонемента отклонено."
@@ -2646,6 +3191,172 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		_ = b.answerCallback(cb, "Ок", false)
 		return
 
+	case strings.HasPrefix(data, "adm:usr:"):
+		b.handleAdminUsersCallback(ctx, cb, strings.TrimPrefix(data, "adm:usr:"))
+		return
+	case strings.HasPrefix(data, "adm:audit:"):
+		b.handleAuditCallback(ctx, cb, strings.TrimPrefix(data, "adm:audit:"))
+		return
+	case strings.HasPrefix(data, "adm:bc:"):
+		b.handleBroadcastCallback(ctx, cb, strings.TrimPrefix(data, "adm:bc:"))
+		return
+
+	case strings.HasPrefix(data, "adm:rep:"):
+		b.handleReportScheduleCallback(ctx, cb, strings.TrimPrefix(data, "adm:rep:"))
+		return
+
+	case strings.HasPrefix(data, "adm:mat:search:page:"):
+		page, _ := strconv.Atoi(strings.TrimPrefix(data, "adm:mat:search:page:"))
+		st, _ := b.states.Get(ctx, fromChat)
+		query := ""
+		if st != nil && st.State == dialog.StateAdmMatSearch {
+			query = payloadString(st.Payload, "q")
+		}
+		if query == "" {
+			_ = b.answerCallback(cb, "Поиск устарел, введите запрос заново", true)
+			return
+		}
+		b.showAdminMaterialSearch(ctx, fromChat, &cb.Message.MessageID, query, page)
+		_ = b.answerCallback(cb, "", false)
+		return
+
+	case data == "adm:mat:search":
+		_ = b.states.Set(ctx, fromChat, dialog.StateAdmMatSearch, dialog.Payload{})
+		b.editTextWithNav(fromChat, cb.Message.MessageID,
+			"Введите название материала или бренда. Можно с опечатками, кириллицей или латиницей.")
+		_ = b.answerCallback(cb, "Ок", false)
+		return
+
+	case strings.HasPrefix(data, "adm:brand:alias:del:"):
+		// формат: adm:brand:alias:del:<mat_id>:<id синонима>
+		parts := strings.SplitN(strings.TrimPrefix(data, "adm:brand:alias:del:"), ":", 2)
+		if len(parts) != 2 {
+			_ = b.answerCallback(cb, "Некорректные данные", true)
+			return
+		}
+		id, _ := strconv.ParseInt(parts[0], 10, 64)
+		aliasID, _ := strconv.ParseInt(parts[1], 10, 64)
+		m, _ := b.materials.GetByID(ctx, id)
+		if m == nil {
+			b.editTextAndClear(fromChat, cb.Message.MessageID, "Материал не найден")
+			_ = b.answerCallback(cb, "Ошибка", true)
+			return
+		}
+		deleted, err := b.brands.DeleteAlias(ctx, m.BrandID, aliasID)
+		if err != nil {
+			_ = b.answerCallback(cb, "Ошибка", true)
+			return
+		}
+		if !deleted {
+			b.showBrandAliases(ctx, fromChat, &cb.Message.MessageID, id)
+			_ = b.answerCallback(cb, "Синоним не найден", true)
+			return
+		}
+		b.showBrandAliases(ctx, fromChat, &cb.Message.MessageID, id)
+		_ = b.answerCallback(cb, "Удалено", false)
+		return
+
+	case strings.HasPrefix(data, "adm:brand:alias:"):
+		id, err := strconv.ParseInt(strings.TrimPrefix(data, "adm:brand:alias:"), 10, 64)
+		if err != nil || id <= 0 {
+			_ = b.answerCallback(cb, "Некорректные данные", true)
+			return
+		}
+		_ = b.states.Set(ctx, fromChat, dialog.StateAdmBrandAlias, dialog.Payload{"mat_id": id})
+		b.showBrandAliases(ctx, fromChat, &cb.Message.MessageID, id)
+		_ = b.answerCallback(cb, "Ок", false)
+		return
+
+	case strings.HasPrefix(data, "adm:mat:bc:del:"):
+		// формат: adm:mat:bc:del:<id>:<id штрихкода>
+		parts := strings.SplitN(strings.TrimPrefix(data, "adm:mat:bc:del:"), ":", 2)
+		if len(parts) != 2 {
+			_ = b.answerCallback(cb, "Некорректные данные", true)
+			return
+		}
+		id, _ := strconv.ParseInt(parts[0], 10, 64)
+		bcID, _ := strconv.ParseInt(parts[1], 10, 64)
+		deleted, err := b.materials.DeleteBarcode(ctx, id, bcID)
+		if err != nil {
+			_ = b.answerCallback(cb, "Ошибка", true)
+			return
+		}
+		if !deleted {
+			b.showMaterialBarcodes(ctx, fromChat, &cb.Message.MessageID, id)
+			_ = b.answerCallback(cb, "Код не найден", true)
+			return
+		}
+		b.showMaterialBarcodes(ctx, fromChat, &cb.Message.MessageID, id)
+		_ = b.answerCallback(cb, "Удалено", false)
+		return
+
+	case strings.HasPrefix(data, "adm:mat:bc:"):
+		id, err := strconv.ParseInt(strings.TrimPrefix(data, "adm:mat:bc:"), 10, 64)
+		if err != nil || id <= 0 {
+			_ = b.answerCallback(cb, "Некорректные данные", true)
+			return
+		}
+		_ = b.states.Set(ctx, fromChat, dialog.StateAdmMatBarcode, dialog.Payload{"mat_id": id})
+		b.showMaterialBarcodes(ctx, fromChat, &cb.Message.MessageID, id)
+		_ = b.answerCallback(cb, "Ок", false)
+		return
+
+	case strings.HasPrefix(data, "adm:mat:pu:set:"):
+		// формат: adm:mat:pu:set:<id>:<unit>
+		parts := strings.SplitN(strings.TrimPrefix(data, "adm:mat:pu:set:"), ":", 2)
+		if len(parts) != 2 {
+			_ = b.answerCallback(cb, "Некорректные данные", true)
+			return
+		}
+		id, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil || id <= 0 {
+			_ = b.answerCallback(cb, "Некорректный ID", true)
+			return
+		}
+		m, _ := b.materials.GetByID(ctx, id)
+		if m == nil {
+			b.editTextAndClear(fromChat, cb.Message.MessageID, "Материал не найден")
+			_ = b.answerCallback(cb, "Ошибка", true)
+			return
+		}
+		if parts[1] == "none" {
+			if err := b.materials.UpdatePurchaseUnit(ctx, id, "", 1); err != nil {
+				_ = b.answerCallback(cb, "Ошибка", true)
+				return
+			}
+			b.showMaterialItemMenu(ctx, fromChat, cb.Message.MessageID, id)
+			_ = b.states.Set(ctx, fromChat, dialog.StateAdmMatItem, dialog.Payload{"mat_id": id})
+			_ = b.answerCallback(cb, "Обновлено", false)
+			return
+		}
+		unit := materials.Unit(parts[1])
+		if !unit.Packaging() {
+			_ = b.answerCallback(cb, "Некорректные данные", true)
+			return
+		}
+		_ = b.states.Set(ctx, fromChat, dialog.StateAdmMatPackFactor, dialog.Payload{
+			"mat_id":        id,
+			"purchase_unit": string(unit),
+		})
+		b.editTextWithNav(fromChat, cb.Message.MessageID, fmt.Sprintf(
+			"Сколько %s в 1 %s?\n%s",
+			materialUnitLabel(string(m.Unit)), materialUnitLabel(string(unit)), materialQtyHint(m.Unit),
+		))
+		_ = b.answerCallback(cb, "Ок", false)
+		return
+
+	case strings.HasPrefix(data, "adm:mat:pu:"):
+		id, err := strconv.ParseInt(strings.TrimPrefix(data, "adm:mat:pu:"), 10, 64)
+		if err != nil || id <= 0 {
+			_ = b.answerCallback(cb, "Некорректные данные", true)
+			return
+		}
+		_ = b.states.Set(ctx, fromChat, dialog.StateAdmMatPurchaseUnit, dialog.Payload{"mat_id": id})
+		b.send(tgbotapi.NewEditMessageTextAndMarkup(fromChat, cb.Message.MessageID,
+			"Выберите единицу закупки (в чём приходит материал в поставке):", b.purchaseUnitKeyboard(id)))
+		_ = b.answerCallback(cb, "Ок", false)
+		return
+
 	case data == "adm:subs:add":
 		_ = b.states.Set(ctx, fromChat, dialog.StateAdmSubsPickUser, dialog.Payload{})
 		b.showSubsPickUser(ctx, fromChat, cb.Message.MessageID)
@@ -2682,7 +3393,7 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		place := st.Payload["place"].(string)
 		unit := st.Payload["unit"].(string)
 		total := int(st.Payload["total"].(float64))
-		month := time.Now().Format("2006-01")
+		month := b.now().Format("2006-01")
 
 		if _, err := b.subs.CreateOrSetTotal(ctx, uid, place, unit, month, total); err != nil {
 			b.editTextAndClear(fromChat, cb.Message.MessageID, "Ошибка сохранения абонемента")
@@ -2720,8 +3431,8 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 
 		// Просмотр остатков мастером: выбор склада -> способ поиска -> остатки
 	case data == "mstock:warehouses":
-		u, _ := b.users.GetByTelegramID(ctx, cb.From.ID)
-		if u == nil || u.Role != users.RoleMaster || u.Status != users.StatusApproved {
+		u := b.currentUser(ctx)
+		if u == nil {
 			_ = b.answerCallback(cb, "Нет доступа", true)
 			return
 		}
@@ -2737,8 +3448,8 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 			return
 		}
 
-		u, _ := b.users.GetByTelegramID(ctx, cb.From.ID)
-		if u == nil || u.Role != users.RoleMaster || u.Status != users.StatusApproved {
+		u := b.currentUser(ctx)
+		if u == nil {
 			_ = b.answerCallback(cb, "Нет доступа", true)
 			return
 		}
@@ -2761,8 +3472,8 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 			return
 		}
 
-		u, _ := b.users.GetByTelegramID(ctx, cb.From.ID)
-		if u == nil || u.Role != users.RoleMaster || u.Status != users.StatusApproved {
+		u := b.currentUser(ctx)
+		if u == nil {
 			_ = b.answerCallback(cb, "Нет доступа", true)
 			return
 		}
@@ -2816,8 +3527,8 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 			return
 		}
 
-		u, _ := b.users.GetByTelegramID(ctx, cb.From.ID)
-		if u == nil || u.Role != users.RoleMaster || u.Status != users.StatusApproved {
+		u := b.currentUser(ctx)
+		if u == nil {
 			_ = b.answerCallback(cb, "Нет доступа", true)
 			return
 		}
@@ -2888,8 +3599,8 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 			return
 		}
 
-		u, _ := b.users.GetByTelegramID(ctx, cb.From.ID)
-		if u == nil || u.Role != users.RoleMaster || u.Status != users.StatusApproved {
+		u := b.currentUser(ctx)
+		if u == nil {
 			_ = b.answerCallback(cb, "Нет доступа", true)
 			return
 		}
@@ -2936,8 +3647,8 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 			return
 		}
 
-		u, _ := b.users.GetByTelegramID(ctx, cb.From.ID)
-		if u == nil || u.Role != users.RoleMaster || u.Status != users.StatusApproved {
+		u := b.currentUser(ctx)
+		if u == nil {
 			_ = b.answerCallback(cb, "Нет доступа", true)
 			return
 		}
@@ -3049,6 +3760,17 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		_ = b.answerCallback(cb, "Ок", false)
 		return
 
+	case data == "sup:bc":
+		st, _ := b.states.Get(ctx, fromChat)
+		if st == nil || st.Payload == nil || payloadInt64(st.Payload["wh_id"]) == 0 {
+			_ = b.answerCallback(cb, "Склад не выбран", true)
+			return
+		}
+		b.editTextWithNav(fromChat, cb.Message.MessageID, barcodePrompt)
+		b.saveLastStep(ctx, fromChat, dialog.StateSupBarcode, st.Payload, cb.Message.MessageID)
+		_ = b.answerCallback(cb, "Ок", false)
+		return
+
 	case strings.HasPrefix(data, "sup:mat:"):
 		matID, _ := strconv.ParseInt(strings.TrimPrefix(data, "sup:mat:"), 10, 64)
 		st, _ := b.states.Get(ctx, fromChat)
@@ -3062,7 +3784,7 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 			payload["items"] = items
 		}
 		_ = b.states.Set(ctx, fromChat, dialog.StateSupQty, payload)
-		b.editTextWithNav(fromChat, cb.Message.MessageID, "Введите количество (число, например 250)")
+		b.editTextWithNav(fromChat, cb.Message.MessageID, b.supQtyPromptByID(ctx, matID))
 		b.saveLastStep(ctx, fromChat, dialog.StateSupQty, payload, cb.Message.MessageID)
 		_ = b.answerCallback(cb, "Ок", false)
 		return
@@ -3095,8 +3817,8 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 			_ = b.answerCallback(cb, "Пусто", true)
 			return
 		}
-		u, err := b.users.GetByTelegramID(ctx, cb.From.ID)
-		if err != nil || u == nil {
+		u := b.currentUser(ctx)
+		if u == nil {
 			b.editTextAndClear(fromChat, cb.Message.MessageID, "Пользователь не найден")
 			_ = b.answerCallback(cb, "Ошибка", true)
 			return
@@ -3113,9 +3835,9 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		// Проводим каждую позицию одной транзакцией на позицию
 		for _, it := range items {
 			mat := int64(it["mat_id"].(float64))
-			qty := int64(it["qty"].(float64))
+			qty := it["qty"].(float64)
 			price := it["price"].(float64)
-			if err := b.inventory.ReceiveWithCost(ctx, u.ID, wh, mat, float64(qty), price, "supply", "", batchID); err != nil {
+			if err := b.inventory.ReceiveWithCost(ctx, u.ID, wh, mat, qty, price, "supply", "", batchID); err != nil {
 				b.editTextAndClear(fromChat, cb.Message.MessageID, "Ошибка приёмки: "+err.Error())
 				_ = b.answerCallback(cb, "Ошибка", true)
 				return
@@ -3150,10 +3872,28 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		_ = b.answerCallback(cb, "Файл сформирован", false)
 		return
 
+	case strings.HasPrefix(data, "price:mat:cancel:"):
+		// формат: price:mat:cancel:<mat_id>:<price_id>
+		parts := strings.Split(strings.TrimPrefix(data, "price:mat:cancel:"), ":")
+		if len(parts) != 2 {
+			_ = b.answerCallback(cb, "Некорректные данные", true)
+			return
+		}
+		matID, _ := strconv.ParseInt(parts[0], 10, 64)
+		priceID, _ := strconv.ParseInt(parts[1], 10, 64)
+		if err := b.materials.CancelScheduledPrice(ctx, matID, priceID); err != nil {
+			_ = b.answerCallback(cb, "Ошибка", true)
+			return
+		}
+		b.showMaterialItemMenu(ctx, fromChat, cb.Message.MessageID, matID)
+		_ = b.answerCallback(cb, "Запланированная цена отменена", false)
+		return
+
 	case data == "price:mat:import":
 		_ = b.states.Set(ctx, fromChat, dialog.StatePriceMatImportFile, dialog.Payload{})
 		b.editTextWithNav(fromChat, cb.Message.MessageID,
-			"Загрузите Excel-файл с ценами материалов (тот, что вы выгрузили через «Выгрузить цены на материалы» и отредактировали колонку price_per_unit).")
+			"Загрузите Excel-файл с ценами материалов (тот, что вы выгрузили через «Выгрузить цены на материалы» и отредактировали колонку price_per_unit).\n"+
+				"Дата в колонке valid_from запланирует цену на будущее.")
 		_ = b.answerCallback(cb, "Ок", false)
 		return
 
@@ -3172,10 +3912,19 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 	case data == "price:rent:import":
 		_ = b.states.Set(ctx, fromChat, dialog.StatePriceRentImportFile, dialog.Payload{})
 		b.editTextWithNav(fromChat, cb.Message.MessageID,
-			"Загрузите Excel-файл с тарифами аренды (тот, что вы выгрузили через «Выгрузить цены на аренду» и изменили threshold/price_with/price_own).")
+			"Загрузите Excel-файл с тарифами аренды (тот, что вы выгрузили через «Выгрузить цены на аренду» и изменили threshold/price_with/price_own).\n"+
+				"Изменения попадут в черновик: его можно сравнить с действующими тарифами и опубликовать с нужной даты.")
 		_ = b.answerCallback(cb, "Ок", false)
 		return
 
+	case strings.HasPrefix(data, "price:disc:"):
+		b.handleDiscountCallback(ctx, cb, strings.TrimPrefix(data, "price:disc:"))
+		return
+
+	case data == "price:rent:versions", strings.HasPrefix(data, "price:rent:ver:"):
+		b.handleRentTariffCallback(ctx, cb, strings.TrimPrefix(data, "price:rent:"))
+		return
+
 	case strings.HasPrefix(data, "cons:wh:"):
 		warehouseID, err := strconv.ParseInt(strings.TrimPrefix(data, "cons:wh:"), 10, 64)
 		if err != nil {
@@ -3183,8 +3932,8 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 			return
 		}
 
-		u, err := b.users.GetByTelegramID(ctx, cb.From.ID)
-		if err != nil || u == nil || u.Status != users.StatusApproved {
+		u := b.currentUser(ctx)
+		if u == nil || u.Status != users.StatusApproved {
 			_ = b.answerCallback(cb, "Нет доступа", true)
 			return
 		}
@@ -3271,7 +4020,7 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		delete(payload, "items")
 
 		_ = b.states.Set(ctx, fromChat, dialog.StateConsPlace, payload)
-		b.showConsumptionPlaceStep(fromChat, &cb.Message.MessageID)
+		b.showConsumptionPlaceStep(ctx, fromChat, &cb.Message.MessageID)
 		_ = b.answerCallback(cb, "Ок", false)
 		return
 
@@ -3395,8 +4144,8 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 
 		_ = b.states.Set(ctx, fromChat, dialog.StateConsWhPick, st.Payload)
 
-		u, err := b.users.GetByTelegramID(ctx, cb.From.ID)
-		if err != nil || u == nil || u.Status != users.StatusApproved {
+		u := b.currentUser(ctx)
+		if u == nil || u.Status != users.StatusApproved {
 			_ = b.answerCallback(cb, "Нет доступа", true)
 			return
 		}
@@ -3582,6 +4331,19 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		_ = b.answerCallback(cb, "Ок", false)
 		return
 
+	case data == "cons:bc":
+		st, _ := b.states.Get(ctx, fromChat)
+		if st == nil || st.Payload == nil {
+			b.editTextAndClear(fromChat, cb.Message.MessageID,
+				"Сессия устарела. Начните заново через кнопку «Расход/Аренда».")
+			_ = b.answerCallback(cb, "Ошибка", true)
+			return
+		}
+		_ = b.states.Set(ctx, fromChat, dialog.StateConsBarcode, st.Payload)
+		b.editTextWithNav(fromChat, cb.Message.MessageID, barcodePrompt)
+		_ = b.answerCallback(cb, "Ок", false)
+		return
+
 	case strings.HasPrefix(data, "cons:mat:"):
 		mid, _ := strconv.ParseInt(strings.TrimPrefix(data, "cons:mat:"), 10, 64)
 
@@ -3599,8 +4361,10 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		_ = b.states.Set(ctx, fromChat, dialog.StateConsMatQty, st.Payload)
 
 		name := "материала"
+		hint := materialQtyHint(materials.UnitG)
 		if m, _ := b.materials.GetByID(ctx, mid); m != nil {
 			name = materialDisplayName(m.Brand, m.Name)
+			hint = materialQtyHint(m.Unit)
 		}
 
 		kb := tgbotapi.NewInlineKeyboardMarkup(navKeyboard(true, true).InlineKeyboard[0])
@@ -3608,7 +4372,7 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		msg := tgbotapi.NewEditMessageTextAndMarkup(
 			fromChat,
 			cb.Message.MessageID,
-			fmt.Sprintf("Введите количество для:\n%s\n\nЦелое число, г/шт.", name),
+			fmt.Sprintf("Введите количество для:\n%s\n\n%s", name, hint),
 			kb,
 		)
 		b.send(msg)
@@ -3701,8 +4465,160 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		_ = b.answerCallback(cb, "Ок", false)
 		return
 
+	case data == "cons:tpl:list":
+		st, _ := b.states.Get(ctx, fromChat)
+		if st == nil || st.Payload == nil {
+			b.editTextAndClear(fromChat, cb.Message.MessageID, "Сессия устарела. Начните заново.")
+			_ = b.answerCallback(cb, "Ошибка", true)
+			return
+		}
+
+		u := b.currentUser(ctx)
+		if u == nil || u.Status != users.StatusApproved {
+			_ = b.answerCallback(cb, "Нет доступа", true)
+			return
+		}
+
+		_ = b.states.Set(ctx, fromChat, dialog.StateConsTplPick, st.Payload)
+		b.showConsTemplateList(ctx, fromChat, cb.Message.MessageID, u)
+		_ = b.answerCallback(cb, "Ок", false)
+		return
+
+	case strings.HasPrefix(data, "cons:tpl:apply:"):
+		templateID, err := strconv.ParseInt(strings.TrimPrefix(data, "cons:tpl:apply:"), 10, 64)
+		if err != nil {
+			_ = b.answerCallback(cb, "Некорректный шаблон", true)
+			return
+		}
+
+		u := b.currentUser(ctx)
+		if u == nil || u.Status != users.StatusApproved {
+			_ = b.answerCallback(cb, "Нет доступа", true)
+			return
+		}
+
+		if err := b.applyConsTemplate(ctx, fromChat, cb.Message.MessageID, u, templateID); err != nil {
+			_ = b.answerCallback(cb, "Не удалось применить шаблон: "+err.Error(), true)
+			return
+		}
+		_ = b.answerCallback(cb, "Шаблон добавлен", false)
+		return
+
+	case strings.HasPrefix(data, "cons:tpl:del:"):
+		templateID, err := strconv.ParseInt(strings.TrimPrefix(data, "cons:tpl:del:"), 10, 64)
+		if err != nil {
+			_ = b.answerCallback(cb, "Некорректный шаблон", true)
+			return
+		}
+
+		u := b.currentUser(ctx)
+		if u == nil || u.Status != users.StatusApproved {
+			_ = b.answerCallback(cb, "Нет доступа", true)
+			return
+		}
+
+		t, _ := b.templates.GetByID(ctx, templateID)
+		if t == nil || t.OwnerUserID != u.ID {
+			_ = b.answerCallback(cb, "Удалять можно только свои шаблоны", true)
+			return
+		}
+
+		if err := b.templates.Delete(ctx, templateID); err != nil {
+			_ = b.answerCallback(cb, "Ошибка удаления", true)
+			return
+		}
+
+		b.showConsTemplateList(ctx, fromChat, cb.Message.MessageID, u)
+		_ = b.answerCallback(cb, "Шаблон удалён", false)
+		return
+
+	case data == "cons:tpl:save":
+		st, _ := b.states.Get(ctx, fromChat)
+		if st == nil || st.Payload == nil || len(b.consParseItems(st.Payload["items"])) == 0 {
+			_ = b.answerCallback(cb, "В чеке нет материалов", true)
+			return
+		}
+
+		_ = b.states.Set(ctx, fromChat, dialog.StateConsTplName, st.Payload)
+		b.editTextWithNav(fromChat, cb.Message.MessageID,
+			"Введите название шаблона (например, «Окрашивание корней»).")
+		_ = b.answerCallback(cb, "Ок", false)
+		return
+
+	case data == "cons:items":
+		st, _ := b.states.Get(ctx, fromChat)
+		if st == nil || st.Payload == nil {
+			b.editTextAndClear(fromChat, cb.Message.MessageID, "Сессия устарела. Начните заново.")
+			_ = b.answerCallback(cb, "Ошибка", true)
+			return
+		}
+
+		items := b.consParseItems(st.Payload["items"])
+		if len(items) == 0 {
+			_ = b.answerCallback(cb, "В чеке нет материалов", true)
+			return
+		}
+
+		_ = b.states.Set(ctx, fromChat, dialog.StateConsItemPick, st.Payload)
+		b.showConsCartItems(ctx, fromChat, cb.Message.MessageID, items)
+		_ = b.answerCallback(cb, "Ок", false)
+		return
+
+	case strings.HasPrefix(data, "cons:item:"):
+		idx, err := strconv.Atoi(strings.TrimPrefix(data, "cons:item:"))
+		if err != nil {
+			_ = b.answerCallback(cb, "Некорректная позиция", true)
+			return
+		}
+
+		st, _ := b.states.Get(ctx, fromChat)
+		if st == nil || st.Payload == nil {
+			b.editTextAndClear(fromChat, cb.Message.MessageID, "Сессия устарела. Начните заново.")
+			_ = b.answerCallback(cb, "Ошибка", true)
+			return
+		}
+
+		items := b.consParseItems(st.Payload["items"])
+		if idx < 0 || idx >= len(items) {
+			_ = b.answerCallback(cb, "Позиция не найдена", true)
+			return
+		}
+
+		matID := payloadInt64(items[idx]["mat_id"])
+		name := fmt.Sprintf("ID:%d", matID)
+		hint := materialQtyHint(materials.UnitG)
+		if m, _ := b.materials.GetByID(ctx, matID); m != nil {
+			name = materialDisplayName(m.Brand, m.Name)
+			hint = materialQtyHint(m.Unit)
+		}
+
+		st.Payload["cons_item_idx"] = float64(idx)
+		_ = b.states.Set(ctx, fromChat, dialog.StateConsItemQty, st.Payload)
+		b.editTextWithNav(fromChat, cb.Message.MessageID,
+			fmt.Sprintf("Введите новое количество для:\n%s\n\n%s 0 — удалить позицию из чека.", name, hint))
+		_ = b.answerCallback(cb, "Ок", false)
+		return
+
+	case data == "adm:tpl:menu":
+		_ = b.states.Set(ctx, fromChat, dialog.StateAdmTplMenu, dialog.Payload{})
+		b.showMaterialTemplatesMenu(fromChat, cb.Message.MessageID)
+		_ = b.answerCallback(cb, "Ок", false)
+		return
+
+	case data == "adm:tpl:export":
+		b.exportMaterialTemplatesExcel(ctx, fromChat, cb.Message.MessageID)
+		_ = b.answerCallback(cb, "Файл сформирован", false)
+		return
+
+	case data == "adm:tpl:import":
+		_ = b.states.Set(ctx, fromChat, dialog.StateAdmTplImportFile, dialog.Payload{})
+		b.editTextWithNav(fromChat, cb.Message.MessageID,
+			"Загрузите Excel-файл с шаблонами (тот, что вы выгрузили через «Выгрузить шаблоны» и изменили).")
+		_ = b.answerCallback(cb, "Ок", false)
+		return
+
 	case data == "cons:cancel_last":
-		b.showCancelLastConsumption(ctx, fromChat, cb.Message.MessageID, cb.From.ID)
+		b.showCancelLastConsumption(ctx, fromChat, cb.Message.MessageID)
 		_ = b.answerCallback(cb, "Ок", false)
 		return
 
@@ -3713,7 +4629,7 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 			return
 		}
 
-		b.cancelLastConsumption(ctx, fromChat, cb.Message.MessageID, cb.From.ID, sessionID)
+		b.cancelLastConsumption(ctx, fromChat, cb.Message.MessageID, sessionID)
 		_ = b.answerCallback(cb, "Ок", false)
 		return
 
@@ -3727,7 +4643,7 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 			return
 		}
 
-		u, _ := b.users.GetByTelegramID(ctx, cb.From.ID)
+		u := b.currentUser(ctx)
 		if u == nil || u.Status != "approved" {
 			b.editTextAndClear(fromChat, cb.Message.MessageID, "Нет доступа")
 			_ = b.answerCallback(cb, "Ошибка", true)
@@ -3803,7 +4719,31 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 			sessionPayload["rent_parts"] = rentParts
 		}
 
-		sid, err := b.cons.CreateSession(ctx, u.ID, place, unit, qty, withSub, mats, rounded, rent, total, sessionPayload)
+		if code := payloadString(st.Payload, "promo_code"); code != "" {
+			sessionPayload["promo_code"] = code
+		}
+
+		// цены всех позиций — до любых записей: при ошибке повторное подтверждение
+		// не должно создать вторую сессию и дважды списать абонемент и склад
+		type consLine struct {
+			matID      int64
+			qty, price float64
+		}
+		lines := make([]consLine, 0, len(items))
+		for _, it := range items {
+			matID := int64(it["mat_id"].(float64))
+			price, err := b.consItemPrice(ctx, it)
+			if err != nil {
+				b.log.Error("material price load failed", "material_id", matID, "err", err)
+				b.editTextAndClear(fromChat, cb.Message.MessageID, "Не удалось загрузить цену материала. Попробуйте подтвердить ещё раз.")
+				_ = b.answerCallback(cb, "Ошибка", true)
+				return
+			}
+			lines = append(lines, consLine{matID: matID, qty: it["qty"].(float64), price: price})
+		}
+
+		sid, err := b.cons.CreateSession(ctx, u.ID, place, unit, qty, withSub, mats, rounded, rent, total,
+			payloadInt64(st.Payload["tariff_version_id"]), sessionPayload)
 
 		if err != nil {
 			b.editTextAndClear(fromChat, cb.Message.MessageID, "Не удалось создать сессию")
@@ -3814,7 +4754,7 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		if withSub && b.subs != nil {
 			// разбиваем сессию на части по тем же правилам (старые/новые абонементы + без абонемента)
 			metas, _ := b.splitQtyBySubscriptions(ctx, u.ID, place, unit, qty)
-			month := time.Now().Format("2006-01")
+			month := b.now().Format("2006-01")
 
 			for _, m := range metas {
 				if !m.WithSub || m.SubID == 0 || m.Qty <= 0 {
@@ -3822,15 +4762,15 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 				}
 
 				if err := b.subs.AddUsage(ctx, m.SubID, m.Qty); err != nil {
-					if errors.Is(err, subsdomain.ErrInsufficientLimit) && b.adminChat != 0 {
-						// сигнал админу, что по конкретному абонементу лимит уже выбит
-						b.send(tgbotapi.NewMessage(b.adminChat,
+					if errors.Is(err, subsdomain.ErrInsufficientLimit) {
+						// сигнал админам, что по конкретному абонементу лимит уже выбит
+						b.notifyCapability(ctx, access.CapSubsManage,
 							fmt.Sprintf("⚠️ Не удалось списать %d %s абонемента (id=%d) для мастера id %d: недостаточно лимита.",
 								m.Qty,
 								map[string]string{"hour": "часов", "day": "дней"}[unit],
 								m.SubID,
 								u.ID,
-							)))
+							), nil)
 					}
 				}
 			}
@@ -3899,22 +4839,24 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 			}
 		}
 
-		pairs := make([][2]int64, 0, len(items))
+		pairs := make([][2]int64, 0, len(lines))
 		// позиции + списание
-		for _, it := range items {
-			matID := int64(it["mat_id"].(float64))
-			q := int64(it["qty"].(float64))
-			price, _ := b.materials.GetPrice(ctx, matID)
-			cost := float64(q) * price
-
+		for _, l := range lines {
 			// списание (разрешено уходить в минус)
-			if err := b.inventory.Consume(ctx, u.ID, whID, matID, float64(q), "consumption"); err != nil {
+			if err := b.inventory.Consume(ctx, u.ID, whID, l.matID, l.qty, "consumption"); err != nil {
 				b.editTextAndClear(fromChat, cb.Message.MessageID, "Ошибка списания")
 				_ = b.answerCallback(cb, "Ошибка", true)
 				return
 			}
-			_ = b.cons.AddItem(ctx, sid, matID, float64(q), price, cost)
-			pairs = append(pairs, [2]int64{whID, matID})
+			if err := b.cons.AddItem(ctx, sid, l.matID, l.qty, l.price, l.qty*l.price); err != nil {
+				b.log.Error("failed to save session item", "session_id", sid, "material_id", l.matID, "err", err)
+			}
+			pairs = append(pairs, [2]int64{whID, l.matID})
+		}
+
+		applied := parseConsumptionDiscounts(st.Payload)
+		if err := b.cons.AddSessionDiscounts(ctx, sid, applied); err != nil {
+			b.log.Error("failed to save session discounts", "session_id", sid, "err", err)
 		}
 
 		// инвойс (pending)
@@ -3966,7 +4908,7 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		// Роль administrator сюда не включаем.
 		if admins, err := b.users.ListByRole(ctx, users.RoleAdmin, users.StatusApproved); err == nil && len(admins) > 0 {
 			// кто подтвердил
-			u, _ := b.users.GetByTelegramID(ctx, cb.From.ID)
+			u := b.currentUser(ctx)
 
 			// соберём удобочитаемый текст
 			placeRU := map[string]string{"hall": "Зал", "cabinet": "Кабинет"}
@@ -3975,7 +4917,10 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 
 			_, _ = fmt.Fprintf(&sb, "✅ Подтверждена сессия расхода/аренды\n")
 			if u != nil {
-				_, _ = fmt.Fprintf(&sb, "Мастер: %s (@%s, id %d)\n", strings.TrimSpace(u.Username), cb.From.UserName, cb.From.ID)
+				_, _ = fmt.Fprintf(&sb, "Мастер: %s (@%s, id %d)\n", b.userFullName(ctx, u), cb.From.UserName, cb.From.ID)
+				if phone := b.profileOf(ctx, u).Phone; phone != "" {
+					_, _ = fmt.Fprintf(&sb, "Телефон: %s\n", phone)
+				}
 			} else {
 				_, _ = fmt.Fprintf(&sb, "Мастер: @%s (id %d)\n", cb.From.UserName, cb.From.ID)
 			}
@@ -4002,15 +4947,21 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 			var matsSum float64
 			for _, it := range items {
 				matID := int64(it["mat_id"].(float64))
-				q := int64(it["qty"].(float64))
+				q := it["qty"].(float64)
 				name := fmt.Sprintf("ID:%d", matID)
+				unitLabel := "ед."
 				if m, _ := b.materials.GetByID(ctx, matID); m != nil { // repo уже есть
 					name = materialDisplayName(m.Brand, m.Name)
+					unitLabel = materialUnitLabel(string(m.Unit))
 				}
-				price, _ := b.materials.GetPrice(ctx, matID)
-				line := float64(q) * price
+				price, err := b.consItemPrice(ctx, it)
+				if err != nil {
+					_, _ = fmt.Fprintf(&sb, "• %s — %s %s × цена не загрузилась\n", name, formatQty(q), unitLabel)
+					continue
+				}
+				line := q * price
 				matsSum += line
-				_, _ = fmt.Fprintf(&sb, "• %s — %d × %.2f = %.2f ₽\n", name, q, price, line)
+				_, _ = fmt.Fprintf(&sb, "• %s — %s %s × %.2f = %.2f ₽\n", name, formatQty(q), unitLabel, price, line)
 			}
 
 			// финансы: округлённая сумма материалов, аренда, итого — у нас уже посчитаны
@@ -4024,6 +4975,9 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 				_, _ = fmt.Fprintf(&sb, "\nМатериалы (факт): %.2f ₽, округл.: %.2f ₽\nАренда: %.2f ₽\nИтого: %.2f ₽",
 					mats, rounded, rent, total)
 			}
+			for _, d := range applied {
+				_, _ = fmt.Fprintf(&sb, "\nСкидка «%s» %s: −%.2f ₽", d.Title, discountTargetLabel(d.Target), d.Amount)
+			}
 
 			notificationText := sb.String()
 			seen := map[int64]struct{}{}
@@ -4063,14 +5017,48 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 			b.send(msg)
 		}
 
+		// чек и счёт в PDF — их же можно получить позже в «Моя история»
+		if s, err := b.cons.GetUserSessionReport(ctx, u.ID, sid); err != nil || s == nil {
+			b.log.Error("failed to load session for pdf", "session_id", sid, "err", err)
+		} else {
+			if err := b.sendSessionReceiptPDF(fromChat, *s); err != nil {
+				b.log.Error("failed to build receipt pdf", "session_id", sid, "err", err)
+			}
+			if _, err := b.sendSessionInvoicePDF(fromChat, *s); err != nil {
+				b.log.Error("failed to build invoice pdf", "invoice_id", invoiceID, "err", err)
+			}
+		}
+
 		_ = b.states.Set(ctx, fromChat, dialog.StateIdle, dialog.Payload{})
 		_ = b.answerCallback(cb, "Готово", false)
 		return
 
 		// Покупка абонемента из сводки расхода/аренды
+	case data == "cons:promo":
+		st, _ := b.states.Get(ctx, fromChat)
+		if st == nil || st.State != dialog.StateConsSummary {
+			_ = b.answerCallback(cb, "Сводка устарела", true)
+			return
+		}
+		_ = b.states.Set(ctx, fromChat, dialog.StateConsPromoCode, st.Payload)
+		b.editTextWithNav(fromChat, cb.Message.MessageID, "Введите промокод:")
+		_ = b.answerCallback(cb, "Ок", false)
+		return
+
+	case data == "cons:promo:clear":
+		st, _ := b.states.Get(ctx, fromChat)
+		if st == nil || st.State != dialog.StateConsSummary {
+			_ = b.answerCallback(cb, "Сводка устарела", true)
+			return
+		}
+		delete(st.Payload, "promo_code")
+		b.showConsumptionReceiptForConfirm(ctx, fromChat, cb.Message.MessageID, cb.From.ID, st.Payload)
+		_ = b.answerCallback(cb, "Промокод убран", false)
+		return
+
 	case data == "cons:buy_sub":
-		u, _ := b.users.GetByTelegramID(ctx, cb.From.ID)
-		if u == nil || u.Status != users.StatusApproved || u.Role != users.RoleMaster {
+		u := b.currentUser(ctx)
+		if u == nil {
 			_ = b.answerCallback(cb, "Недоступно", true)
 			return
 		}
@@ -4086,6 +5074,32 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		return
 
 		// Покупка абонемента — выбор места
+	case strings.HasPrefix(data, "subs:pdf:"):
+		id, _ := strconv.ParseInt(strings.TrimPrefix(data, "subs:pdf:"), 10, 64)
+		u := b.currentUser(ctx)
+		if u == nil {
+			_ = b.answerCallback(cb, "Нет доступа", true)
+			return
+		}
+		sub, err := b.subs.GetByUser(ctx, u.ID, id)
+		if err != nil || sub == nil {
+			_ = b.answerCallback(cb, "Абонемент не найден", true)
+			return
+		}
+		pdfData, err := b.subscriptionPDF(*sub, b.userFullName(ctx, u))
+		if err != nil {
+			b.log.Error("failed to build subscription pdf", "subscription_id", id, "err", err)
+			_ = b.answerCallback(cb, "Не удалось сформировать PDF", true)
+			return
+		}
+		_ = b.answerCallback(cb, "", false)
+		b.sendPDF(fromChat, fmt.Sprintf("subscription_%d.pdf", id), pdfData, "Подтверждение покупки абонемента")
+		return
+
+	case strings.HasPrefix(data, "hist:"):
+		b.handleMyHistoryCallback(ctx, cb, strings.TrimPrefix(data, "hist:"))
+		return
+
 	case strings.HasPrefix(data, "subbuy:place:"):
 		place := strings.TrimPrefix(data, "subbuy:place:")
 		unit := "hour"
@@ -4094,15 +5108,15 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		}
 
 		// Текущий мастер
-		u, _ := b.users.GetByTelegramID(ctx, cb.From.ID)
-		if u == nil || u.Status != users.StatusApproved || u.Role != users.RoleMaster {
+		u := b.currentUser(ctx)
+		if u == nil {
 			b.editTextAndClear(fromChat, cb.Message.MessageID, "Доступ запрещён.")
 			_ = b.answerCallback(cb, "Ошибка", true)
 			return
 		}
 
 		// Проверяем, есть ли активный абонемент по этому помещению в текущем месяце
-		month := time.Now().Format("2006-01")
+		month := b.now().Format("2006-01")
 		subs, err := b.subs.ListByUserMonth(ctx, u.ID, month)
 		if err != nil {
 			b.editTextAndClear(fromChat, cb.Message.MessageID, "Ошибка загрузки абонементов.")
@@ -4134,7 +5148,7 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		}
 
 		// Тарифы-абонементы для выбранного помещения: одна строка = один конкретный объём
-		rates, err := b.cons.ListRates(ctx, place, unit, true)
+		rates, err := b.cons.ListRates(ctx, b.now(), place, unit, true)
 		if err != nil {
 			b.editTextAndClear(fromChat, cb.Message.MessageID, "Ошибка загрузки тарифов абонементов.")
 			_ = b.answerCallback(cb, "Ошибка", true)
@@ -4208,7 +5222,7 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		}
 
 		// Ищем выбранный тариф
-		rates, err := b.cons.ListRates(ctx, place, unit, true)
+		rates, err := b.cons.ListRates(ctx, b.now(), place, unit, true)
 		if err != nil {
 			b.editTextAndClear(fromChat, cb.Message.MessageID, "Ошибка загрузки тарифов.")
 			_ = b.answerCallback(cb, "Ошибка", true)
@@ -4231,8 +5245,8 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		qty := rate.MinQty
 
 		// Текущий мастер
-		u, _ := b.users.GetByTelegramID(ctx, cb.From.ID)
-		if u == nil || u.Status != users.StatusApproved || u.Role != users.RoleMaster {
+		u := b.currentUser(ctx)
+		if u == nil {
 			b.editTextAndClear(fromChat, cb.Message.MessageID, "Доступ запрещён.")
 			_ = b.answerCallback(cb, "Ошибка", true)
 			return
@@ -4299,8 +5313,8 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		// Покупка абонемента — подтверждение (мастер → заявка админу)
 	case data == "subbuy:confirm":
 		st, _ := b.states.Get(ctx, fromChat)
-		u, _ := b.users.GetByTelegramID(ctx, cb.From.ID)
-		if u == nil || u.Status != users.StatusApproved || u.Role != users.RoleMaster {
+		u := b.currentUser(ctx)
+		if u == nil {
 			b.editTextAndClear(fromChat, cb.Message.MessageID, "Доступ запрещён.")
 			_ = b.answerCallback(cb, "Ошибка", true)
 			return
@@ -4333,16 +5347,33 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 			totalCost = float64(qty) * pricePerUnit
 		}
 
+		req := &subsdomain.Request{
+			UserID:         u.ID,
+			Place:          place,
+			Unit:           unit,
+			Qty:            qty,
+			ThresholdTotal: thresholdTotal,
+			PricePerUnit:   pricePerUnit,
+		}
+		if err := b.subs.CreateRequest(ctx, req); err != nil {
+			b.log.Error("create subscription request failed", "user_id", u.ID, "err", err)
+			_ = b.answerCallback(cb, "Ошибка при отправке заявки", true)
+			return
+		}
+
 		// Сообщение мастеру
 		b.editTextAndClear(fromChat, cb.Message.MessageID,
 			"Запрос на приобретение абонемента отправлен администратору. Ожидайте подтверждения.")
 		_ = b.states.Set(ctx, fromChat, dialog.StateIdle, dialog.Payload{})
 
 		// Текст для админа
-		displayName := strings.TrimSpace(u.Username)
+		displayName := b.userFullName(ctx, u)
 		if displayName == "" {
 			displayName = fmt.Sprintf("id %d", u.ID)
 		}
+		if phone := b.profileOf(ctx, u).Phone; phone != "" {
+			displayName += " (" + phone + ")"
+		}
 
 		placeRU := map[string]string{"hall": "Общий зал", "cabinet": "Кабинет"}
 		unitRU := map[string]string{"hour": "ч", "day": "дн"}
@@ -4362,19 +5393,16 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		)
 
 		// коллбеки для админа
-		cbApprove := fmt.Sprintf("subrq:approve:%d:%s:%s:%d:%.2f",
-			cb.From.ID, place, unit, qty, thresholdTotal,
-		)
-		cbReject := fmt.Sprintf("subrq:reject:%d", cb.From.ID)
+		cbApprove := fmt.Sprintf("subrq:approve:%d", req.ID)
+		cbReject := fmt.Sprintf("subrq:reject:%d", req.ID)
 
-		msg := tgbotapi.NewMessage(b.adminChat, txt)
-		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		kb := tgbotapi.NewInlineKeyboardMarkup(
 			tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("Подтвердить", cbApprove),
 				tgbotapi.NewInlineKeyboardButtonData("Отклонить", cbReject),
 			),
 		)
-		b.send(msg)
+		b.notifyCapability(ctx, access.CapSubsApprove, txt, &kb)
 
 		_ = b.answerCallback(cb, "Отправлено админу", false)
 		return
@@ -4459,49 +5487,7 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		// Показ списка ступеней
 	case data == "rates:list":
 		st, _ := b.states.Get(ctx, fromChat)
-		place := st.Payload["place"].(string)
-		unit := st.Payload["unit"].(string)
-		withSub := false
-		if v, ok := st.Payload["with_sub"].(bool); ok {
-			withSub = v
-		}
-
-		rates, err := b.cons.ListRates(ctx, place, unit, withSub)
-		if err != nil {
-			b.editTextAndClear(fromChat, cb.Message.MessageID, "Ошибка загрузки тарифов")
-			_ = b.answerCallback(cb, "Ошибка", true)
-			return
-		}
-
-		lines := []string{
-			fmt.Sprintf("Тарифы: %s / %s (%s)",
-				map[string]string{"hall": "Зал", "cabinet": "Кабинет"}[place],
-				map[string]string{"hour": "час", "day": "день"}[unit],
-				map[bool]string{true: "с абонементом", false: "без абонемента"}[withSub],
-			),
-		}
-		for _, r := range rates {
-			maxTxt := "∞"
-			if r.MaxQty != nil {
-				maxTxt = fmt.Sprintf("%d", *r.MaxQty)
-			}
-			status := "🟢"
-			if !r.Active {
-				status = "🚫"
-			}
-			lines = append(lines,
-				fmt.Sprintf("%s %d–%s: порог %.0f; с мат. %.2f; свои %.2f",
-					status, r.MinQty, maxTxt, r.Threshold, r.PriceWith, r.PriceOwn),
-			)
-		}
-
-		text := strings.Join(lines, "\n")
-		kb := tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("➕ Добавить ступень", "rates:add")),
-			navKeyboard(true, true).InlineKeyboard[0],
-		)
-		b.send(tgbotapi.NewEditMessageTextAndMarkup(fromChat, cb.Message.MessageID, text, kb))
-		_ = b.states.Set(ctx, fromChat, dialog.StateAdmRatesList, st.Payload)
+		b.showRatesList(ctx, fromChat, &cb.Message.MessageID, st.Payload)
 		_ = b.answerCallback(cb, "Ок", false)
 		return
 
@@ -4511,6 +5497,7 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 		if st.Payload == nil {
 			st.Payload = dialog.Payload{}
 		}
+		delete(st.Payload, "rate_id")
 		_ = b.states.Set(ctx, fromChat, dialog.StateAdmRatesCreateMin, st.Payload)
 		b.editTextWithNav(fromChat, cb.Message.MessageID, "Введите минимальное значение диапазона (целое число, например 1)")
 		_ = b.answerCallback(cb, "Ок", false)
@@ -4518,58 +5505,43 @@ func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
 
 	case data == "rates:save":
 		st, _ := b.states.Get(ctx, fromChat)
-		place := st.Payload["place"].(string)
-		unit := st.Payload["unit"].(string)
-		withSub := false
-		if v, ok := st.Payload["with_sub"].(bool); ok {
-			withSub = v
-		}
-		minQty := int(st.Payload["min"].(float64))
+		r := rateFromPayload(st.Payload)
 
-		var maxPtr *int
-		if st.Payload["max"] != nil {
-			m := int(st.Payload["max"].(float64))
-			maxPtr = &m
+		var err error
+		if r.ID > 0 {
+			_, err = b.cons.UpdateRate(ctx, b.now(), r.ID, r.MinQty, r.MaxQty, r.Threshold, r.PriceWith, r.PriceOwn)
+		} else {
+			_, err = b.cons.CreateRate(ctx, b.now(), r.Place, r.Unit, r.WithSub, r.MinQty, r.MaxQty, r.Threshold, r.PriceWith, r.PriceOwn)
 		}
-		thr := st.Payload["thr"].(float64)
-		pwith := st.Payload["pwith"].(float64)
-		pown := st.Payload["pown"].(float64)
-
-		if _, err := b.cons.CreateRate(ctx, place, unit, withSub, minQty, maxPtr, thr, pwith, pown); err != nil {
+		switch {
+		case errors.Is(err, consumption.ErrTierOverlap):
+			b.editTextAndClear(fromChat, cb.Message.MessageID, "Не сохранено: диапазон пересекается с другой ступенью.")
+			_ = b.answerCallback(cb, "Пересечение диапазонов", true)
+			return
+		case errors.Is(err, consumption.ErrTierGap):
+			b.editTextAndClear(fromChat, cb.Message.MessageID, "Не сохранено: для части количеств не останется тарифа.")
+			_ = b.answerCallback(cb, "Разрыв в ступенях", true)
+			return
+		case errors.Is(err, consumption.ErrRateNotFound):
+			b.editTextAndClear(fromChat, cb.Message.MessageID, "Не сохранено: ступень уже изменили или удалили. Откройте список тарифов заново.")
+			_ = b.answerCallback(cb, "Ступень не найдена", true)
+			return
+		case err != nil:
 			b.editTextAndClear(fromChat, cb.Message.MessageID, "Ошибка сохранения тарифной ступени")
 			_ = b.answerCallback(cb, "Ошибка", true)
 			return
 		}
 
 		b.editTextAndClear(fromChat, cb.Message.MessageID, "Ступень сохранена.")
-
-		rates, _ := b.cons.ListRates(ctx, place, unit, withSub)
-		lines := []string{"Обновлённый список:"}
-		for _, r := range rates {
-			maxTxt := "∞"
-			if r.MaxQty != nil {
-				maxTxt = fmt.Sprintf("%d", *r.MaxQty)
-			}
-			status := "🟢"
-			if !r.Active {
-				status = "🚫"
-			}
-			lines = append(lines,
-				fmt.Sprintf("%s %d–%s: порог %.0f; с мат. %.2f; свои %.2f",
-					status, r.MinQty, maxTxt, r.Threshold, r.PriceWith, r.PriceOwn),
-			)
-		}
-		text := strings.Join(lines, "\n")
-		kb := tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("➕ Добавить ступень", "rates:add")),
-			navKeyboard(true, true).InlineKeyboard[0],
-		)
-		m := tgbotapi.NewMessage(fromChat, text)
-		m.ReplyMarkup = kb
-		b.send(m)
-		_ = b.states.Set(ctx, fromChat, dialog.StateAdmRatesList, st.Payload)
+		b.showRatesList(ctx, fromChat, nil, st.Payload)
 		_ = b.answerCallback(cb, "Сохранено", false)
 		return
+
+	case strings.HasPrefix(data, "rates:r:"), strings.HasPrefix(data, "rates:edit:"),
+		strings.HasPrefix(data, "rates:act:"), strings.HasPrefix(data, "rates:del:"),
+		strings.HasPrefix(data, "rates:delok:"), strings.HasPrefix(data, "rates:merge:"):
+		b.handleRatesCallback(ctx, cb, strings.TrimPrefix(data, "rates:"))
+		return
 	}
 }
 
@@ -4582,20 +5554,26 @@ func (b *Bot) showMaterialSearchPage(ctx context.Context, chatID int64, editMsgI
 
 	warehouseID := payloadInt64(payload["warehouse_id"])
 
-	var mats []materials.Material
-	var err error
+	opt := materials.SearchOptions{WarehouseID: warehouseID, OnlyActive: true}
+	// недавно расходованные мастером материалы — выше в выдаче
+	if u := b.currentUser(ctx); u != nil {
+		opt.UserID = u.ID
+	}
 
-	if warehouseID > 0 {
-		mats, err = b.materials.SearchByNameInWarehouse(ctx, warehouseID, query, true)
-	} else {
-		mats, err = b.materials.SearchByName(ctx, query, true)
+	if page < 0 {
+		page = 0
 	}
+	opt.Limit = materialSearchPageSize
+	opt.Offset = page * materialSearchPageSize
+	mats, total, err := b.materials.Search(ctx, query, opt)
 	if err != nil {
 		b.editTextAndClear(chatID, editMsgID, "Ошибка поиска материалов. Попробуйте позже.")
 		return
 	}
+	// страница за пределами выдачи (список изменился) — Search вернул последнюю
+	page = materials.SearchPage(page, materialSearchPageSize, total)
 
-	if len(mats) == 0 {
+	if total == 0 {
 		kb := tgbotapi.NewInlineKeyboardMarkup(
 			tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData("🔎 Искать другой материал", "cons:search:name"),
@@ -4614,24 +5592,11 @@ func (b *Bot) showMaterialSearchPage(ctx context.Context, chatID int64, editMsgI
 		return
 	}
 
-	totalPages := (len(mats) + materialSearchPageSize - 1) / materialSearchPageSize
-
-	if page < 0 {
-		page = 0
-	}
-	if page >= totalPages {
-		page = totalPages - 1
-	}
-
-	start := page * materialSearchPageSize
-	end := start + materialSearchPageSize
-	if end > len(mats) {
-		end = len(mats)
-	}
+	totalPages := (total + materialSearchPageSize - 1) / materialSearchPageSize
 
 	rows := [][]tgbotapi.InlineKeyboardButton{}
 
-	for _, m := range mats[start:end] {
+	for _, m := range mats {
 		label := materialDisplayName(m.Brand, m.Name)
 		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("cons:mat:%d", m.ID)),
@@ -4686,7 +5651,7 @@ func (b *Bot) showMaterialSearchPage(ctx context.Context, chatID int64, editMsgI
 
 	text := fmt.Sprintf(
 		"Выберите материал:\n\nНайдено: %d\nСтраница: %d/%d",
-		len(mats),
+		total,
 		page+1,
 		totalPages,
 	)
@@ -4703,6 +5668,12 @@ func (b *Bot) showConsMaterialSearchMenu(chatID int64, editMsgID int) {
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("Поиск по параметрам", "cons:search:params"),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📋 Из шаблона", "cons:tpl:list"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔢 По штрихкоду", "cons:bc"),
+		),
 		navKeyboard(true, true).InlineKeyboard[0],
 	}
 
@@ -5141,10 +6112,9 @@ func (b *Bot) showMasterStockCategoryItemsPage(ctx context.Context, chatID int64
 	for _, it := range items[start:end] {
 		_, _ = fmt.Fprintf(
 			&sb,
-			"• %s — %s %s\n",
+			"• %s — %s\n",
 			materialDisplayName(it.Brand, it.Name),
-			formatQty(it.Balance),
-			materialUnitLabel(string(it.Unit)),
+			formatMaterialQty(it.AsMaterial(), it.Balance),
 		)
 	}
 
@@ -5202,15 +6172,15 @@ func formatQty(v any) string {
 		if x == float64(int64(x)) {
 			return fmt.Sprintf("%d", int64(x))
 		}
-		return fmt.Sprintf("%.2f", x)
+		return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.3f", x), "0"), ".")
 	default:
 		return fmt.Sprintf("%v", x)
 	}
 }
 
-func (b *Bot) showCancelLastConsumption(ctx context.Context, chatID int64, editMsgID int, telegramID int64) {
-	u, _ := b.users.GetByTelegramID(ctx, telegramID)
-	if u == nil || u.Status != users.StatusApproved || u.Role != users.RoleMaster {
+func (b *Bot) showCancelLastConsumption(ctx context.Context, chatID int64, editMsgID int) {
+	u := b.currentUser(ctx)
+	if u == nil {
 		if editMsgID != 0 {
 			b.editTextAndClear(chatID, editMsgID, "Нет доступа")
 		} else {
@@ -5269,9 +6239,9 @@ func (b *Bot) showCancelLastConsumption(ctx context.Context, chatID int64, editM
 	b.send(msg)
 }
 
-func (b *Bot) cancelLastConsumption(ctx context.Context, chatID int64, editMsgID int, telegramID int64, sessionID int64) {
-	u, _ := b.users.GetByTelegramID(ctx, telegramID)
-	if u == nil || u.Status != users.StatusApproved || u.Role != users.RoleMaster {
+func (b *Bot) cancelLastConsumption(ctx context.Context, chatID int64, editMsgID int, sessionID int64) {
+	u := b.currentUser(ctx)
+	if u == nil {
 		b.editTextAndClear(chatID, editMsgID, "Нет доступа")
 		return
 	}
@@ -6,9 +6,10 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
-	"time"
 
+	"github.com/Spok95/beauty-bot/internal/access"
 	"github.com/Spok95/beauty-bot/internal/dialog"
+	"github.com/Spok95/beauty-bot/internal/domain/audit"
 	"github.com/Spok95/beauty-bot/internal/domain/catalog"
 	"github.com/Spok95/beauty-bot/internal/domain/materials"
 	"github.com/Spok95/beauty-bot/internal/domain/users"
@@ -50,9 +51,12 @@ func (b *Bot) showStockItem(ctx context.Context, chatID int64, editMsgID int, wh
 	matName := materialDisplayName(m.Brand, m.Name)
 
 	text := fmt.Sprintf(
-		"Склад: %s\nМатериал: %s\nОстаток: %.3f %s",
-		whTitle, matName, qty, m.Unit,
+		"Склад: %s\nМатериал: %s\nОстаток: %s",
+		whTitle, matName, formatMaterialQty(*m, qty),
 	)
+	if m.HasPurchaseUnit() {
+		text += fmt.Sprintf("\nУпаковка: %s\nЦена: %s", materialPackLabel(*m), formatMaterialPrice(*m, m.PricePerUnit))
+	}
 
 	b.send(tgbotapi.NewEditMessageTextAndMarkup(chatID, editMsgID, text, kb))
 }
@@ -150,7 +154,7 @@ func (b *Bot) showStockMaterialList(ctx context.Context, chatID int64, editMsgID
 	rows := [][]tgbotapi.InlineKeyboardButton{}
 	for _, it := range items {
 		name := materialDisplayName(it.Brand, it.Name)
-		label := fmt.Sprintf("%s: %d %s", name, it.Balance, it.Unit)
+		label := fmt.Sprintf("%s: %s", name, formatMaterialQty(it.AsMaterial(), it.Balance))
 		if it.Unit == materials.UnitG {
 			if it.Balance <= 0 {
 				label = "⚠️ " + label + " — закончились"
@@ -196,6 +200,11 @@ func (b *Bot) exportWarehouseMaterialsExcel(ctx context.Context, chatID int64, m
 	for _, c := range cats {
 		catNames[c.ID] = c.Name
 	}
+	barcodes, err := b.materials.ListBarcodesByWarehouse(ctx, whID)
+	if err != nil {
+		b.editTextAndClear(chatID, msgID, "Ошибка загрузки штрихкодов")
+		return
+	}
 
 	// 4) Excel
 	f := excelize.NewFile()
@@ -214,6 +223,8 @@ func (b *Bot) exportWarehouseMaterialsExcel(ctx context.Context, chatID int64, m
 		"material_name",
 		"unit",
 		"Количество", // эту колонку админ будет заполнять сам
+		"qty_unit",   // в чём указано количество: единица закупки (упаковка) или unit
+		"barcodes",   // штрихкоды через запятую; для новой строки без material_id достаточно кода
 	}
 	if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
 		b.editTextAndClear(chatID, msgID, "Ошибка формирования файла (заголовок)")
@@ -234,6 +245,8 @@ func (b *Bot) exportWarehouseMaterialsExcel(ctx context.Context, chatID int64, m
 			m.Name,
 			string(m.Unit),
 			"", // Количество — пусто
+			string(supplyQtyUnit(m.AsMaterial())),
+			strings.Join(barcodes[m.ID], ", "),
 		}
 		cell, err := excelize.CoordinatesToCellName(1, row)
 		if err != nil {
@@ -257,7 +270,7 @@ func (b *Bot) exportWarehouseMaterialsExcel(ctx context.Context, chatID int64, m
 	// 6) Отправляем документ в Telegram
 	fileName := fmt.Sprintf("materials_%s_%s.xlsx",
 		wh.Name,
-		time.Now().Format("20060102_150405"),
+		b.now().Format("20060102_150405"),
 	)
 
 	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
@@ -281,6 +294,8 @@ func (b *Bot) exportWarehouseMaterialsExcel(ctx context.Context, chatID int64, m
 // если qty > текущего остатка — делаем приход,
 // если qty < текущего остатка — списываем разницу.
 func (b *Bot) handleStocksImportExcel(ctx context.Context, chatID int64, u *users.User, data []byte) {
+	ctx = audit.WithSource(ctx, audit.SourceExcel)
+
 	f, err := excelize.OpenReader(bytes.NewReader(data))
 	if err != nil {
 		b.send(tgbotapi.NewMessage(chatID, "Не удалось прочитать Excel-файл (повреждён или не .xlsx)."))
@@ -474,7 +489,7 @@ func (b *Bot) maybeNotifyLowOrNegative(ctx context.Context, _ int64, whID, matID
 	b.notifyStockRecipients(ctx, text)
 }
 
-// notifyStockRecipients Шлём оповещение в админ-чат и всем администраторам (role=administrator) + дублируем админам (role=admin) на всякий случай.
+// notifyStockRecipients Шлём оповещение в админ-чат и всем, кто ведёт остатки (право stock.manage).
 func (b *Bot) notifyStockRecipients(ctx context.Context, text string) {
 	// не шлём одному и тому же chat_id дважды
 	sent := map[int64]struct{}{}
@@ -492,18 +507,9 @@ func (b *Bot) notifyStockRecipients(ctx context.Context, text string) {
 	// 1) админ-чат (может быть личка или группа)
 	sendOnce(b.adminChat)
 
-	// 2) подтверждённые администраторы
-	if list, err := b.users.ListByRole(ctx, users.RoleAdministrator, users.StatusApproved); err == nil {
-		for _, u := range list {
-			sendOnce(u.TelegramID)
-		}
-	}
-
-	// 3) подтверждённые админы
-	if list, err := b.users.ListByRole(ctx, users.RoleAdmin, users.StatusApproved); err == nil {
-		for _, u := range list {
-			sendOnce(u.TelegramID)
-		}
+	// 2) подтверждённые пользователи с правом на остатки (admin, administrator)
+	for _, tgID := range b.recipientsWith(ctx, access.CapStockManage) {
+		sendOnce(tgID)
 	}
 }
 
@@ -537,6 +543,11 @@ func (b *Bot) exportWarehouseStocksExcel(ctx context.Context, chatID int64, msgI
 	for _, c := range cats {
 		catNames[c.ID] = c.Name
 	}
+	barcodes, err := b.materials.ListBarcodesByWarehouse(ctx, whID)
+	if err != nil {
+		b.editTextAndClear(chatID, msgID, "Ошибка загрузки штрихкодов")
+		return
+	}
 
 	// 4) Excel
 	f := excelize.NewFile()
@@ -554,7 +565,8 @@ func (b *Bot) exportWarehouseStocksExcel(ctx context.Context, chatID int64, msgI
 		"material_id",
 		"material_name",
 		"unit",
-		"qty", // текущий остаток; админ может изменить на фактический
+		"qty",      // текущий остаток; админ может изменить на фактический
+		"barcodes", // справочно: штрихкоды материала через запятую
 	}
 	if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
 		b.editTextAndClear(chatID, msgID, "Ошибка формирования файла (заголовок)")
@@ -575,6 +587,7 @@ func (b *Bot) exportWarehouseStocksExcel(ctx context.Context, chatID int64, msgI
 			it.Name,
 			string(it.Unit),
 			it.Balance, // текущий остаток
+			strings.Join(barcodes[it.ID], ", "),
 		}
 		cell, err := excelize.CoordinatesToCellName(1, row)
 		if err != nil {
@@ -598,7 +611,7 @@ func (b *Bot) exportWarehouseStocksExcel(ctx context.Context, chatID int64, msgI
 	// 6) отправка в Telegram
 	fileName := fmt.Sprintf("stocks_%s_%s.xlsx",
 		wh.Name,
-		time.Now().Format("20060102_150405"),
+		b.now().Format("20060102_150405"),
 	)
 
 	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/Spok95/beauty-bot/internal/domain/users"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -84,7 +83,7 @@ func (b *Bot) splitQtyBySubscriptions(
 
 	// 1) части по абонементам (если есть)
 	if b.subs != nil {
-		month := time.Now().Format("2006-01")
+		month := b.now().Format("2006-01")
 		subs, err := b.subs.ListActiveByPlaceUnitMonth(ctx, userID, place, unit, month)
 		if err == nil {
 			for _, s := range subs {
@@ -136,24 +135,39 @@ func (b *Bot) showConsCart(ctx context.Context, chatID int64, editMsgID *int, pl
 	var sum float64
 	for _, it := range items {
 		matID := int64(it["mat_id"].(float64))
-		q := int64(it["qty"].(float64))
+		q := it["qty"].(float64)
 		name := fmt.Sprintf("ID:%d", matID)
+		unitLabel := "ед."
 		if m, _ := b.materials.GetByID(ctx, matID); m != nil {
 			name = materialDisplayName(m.Brand, m.Name)
+			unitLabel = materialUnitLabel(string(m.Unit))
 		}
 
-		price, _ := b.materials.GetPrice(ctx, matID)
-		line := float64(q) * price
+		price, err := b.consItemPrice(ctx, it)
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("• %s — %s %s × цена не загрузилась", name, formatQty(q), unitLabel))
+			continue
+		}
+		line := q * price
 		sum += line
-		lines = append(lines, fmt.Sprintf("• %s — %d × %.2f = %.2f ₽", name, q, price, line))
+		lines = append(lines, fmt.Sprintf("• %s — %s %s × %.2f = %.2f ₽", name, formatQty(q), unitLabel, price, line))
 	}
 	lines = append(lines, fmt.Sprintf("\nСумма материалов: %.2f ₽", sum))
 
-	kb := tgbotapi.NewInlineKeyboardMarkup(
+	rows := [][]tgbotapi.InlineKeyboardButton{
 		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("➕ Добавить материал", "cons:additem")),
+	}
+	if len(items) > 0 {
+		rows = append(rows,
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("✏️ Изменить позиции", "cons:items")),
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("💾 Сохранить как шаблон", "cons:tpl:save")),
+		)
+	}
+	rows = append(rows,
 		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🧮 Посчитать", "cons:calc")),
 		navKeyboard(true, true).InlineKeyboard[0],
 	)
+	kb := tgbotapi.NewInlineKeyboardMarkup(rows...)
 
 	text := strings.Join(lines, "\n")
 	if editMsgID != nil {
@@ -9,6 +9,9 @@ import (
 	"time"
 
 	"github.com/Spok95/beauty-bot/internal/dialog"
+	"github.com/Spok95/beauty-bot/internal/domain/audit"
+	"github.com/Spok95/beauty-bot/internal/domain/inventory"
+	"github.com/Spok95/beauty-bot/internal/domain/materials"
 	"github.com/Spok95/beauty-bot/internal/domain/users"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/xuri/excelize/v2"
@@ -227,6 +230,12 @@ func (b *Bot) showSuppliesPickMaterial(
 
 	rows = append(rows, navRow)
 
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔢 По штрихкоду", "sup:bc"),
+		),
+	)
+
 	rows = append(rows,
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData(
@@ -259,6 +268,8 @@ func (b *Bot) showSuppliesPickMaterial(
 }
 
 func (b *Bot) handleSuppliesImportExcel(ctx context.Context, chatID int64, u *users.User, data []byte, comment string) {
+	ctx = audit.WithSource(ctx, audit.SourceExcel)
+
 	// 1) открываем Excel из байтов
 	f, err := excelize.OpenReader(bytes.NewReader(data))
 	if err != nil {
@@ -307,14 +318,8 @@ func (b *Bot) handleSuppliesImportExcel(ctx context.Context, chatID int64, u *us
 		return
 	}
 
-	// 4) создаём batch для всей поставки из файла
-	batchID, err := b.inventory.CreateSupplyBatch(ctx, u.ID, warehouseID, comment)
-	if err != nil {
-		b.send(tgbotapi.NewMessage(chatID, "Не удалось создать запись поставки (batch)."))
-		return
-	}
-
-	// 5) проходим по всем строкам, начиная со 2-й (индекс 1)
+	// 4) сначала проверяем все строки, начиная со 2-й (индекс 1): при ошибке в любой ничего не проводится
+	var lines []inventory.SupplyLine
 	for i := 1; i < len(rows); i++ {
 		row := rows[i]
 		if len(row) < 9 {
@@ -323,6 +328,19 @@ func (b *Bot) handleSuppliesImportExcel(ctx context.Context, chatID int64, u *us
 		matIDStr := strings.TrimSpace(row[5])
 		qtyStr := strings.TrimSpace(row[8])
 
+		// строка без material_id, но со штрихкодом — ищем материал по первому коду
+		if matIDStr == "" && qtyStr != "" && len(row) > 10 {
+			if code := strings.TrimSpace(strings.Split(row[10], ",")[0]); code != "" {
+				m, err := b.materials.GetByBarcode(ctx, code)
+				if err != nil || m == nil {
+					b.send(tgbotapi.NewMessage(chatID,
+						fmt.Sprintf("Ошибка в строке %d: материал со штрихкодом %q не найден.", i+1, code)))
+					return
+				}
+				matIDStr = strconv.FormatInt(m.ID, 10)
+			}
+		}
+
 		if matIDStr == "" || qtyStr == "" {
 			// пустая строка или количество не задано — пропускаем
 			continue
@@ -355,20 +373,43 @@ func (b *Bot) handleSuppliesImportExcel(ctx context.Context, chatID int64, u *us
 			return
 		}
 
-		// 5) приёмка на склад. Цена нам в файле не задана — ставим 0, это чисто количественная корректировка.
-		note := "supply_excel"
-		if comment != "" {
-			note = fmt.Sprintf("supply_excel: %s", comment)
-		}
-		if err := b.inventory.ReceiveWithCost(ctx, u.ID, warehouseID, matID, qty, 0, note, comment, batchID); err != nil {
-			b.send(tgbotapi.NewMessage(chatID,
-				fmt.Sprintf("Ошибка приёмки в строке %d (материал %d): %v", i+1, matID, err)))
-			return
+		// количество в упаковках (колонка qty_unit) переводим в единицы расхода
+		if len(row) > 9 {
+			if qu := materials.Unit(strings.TrimSpace(row[9])); qu != "" {
+				m, _ := b.materials.GetByID(ctx, matID)
+				switch {
+				case m == nil:
+				case qu == m.Unit:
+				case m.HasPurchaseUnit() && qu == m.PurchaseUnit:
+					qty = m.ToUsage(qty)
+				default:
+					b.send(tgbotapi.NewMessage(chatID,
+						fmt.Sprintf("Ошибка в строке %d: единица %q не подходит материалу %d (ожидается %s).", i+1, string(qu), matID, supplyQtyUnit(*m))))
+					return
+				}
+			}
 		}
 
+		// цена в файле не задана — ставим 0, это чисто количественная корректировка
+		lines = append(lines, inventory.SupplyLine{MaterialID: matID, Qty: qty})
 		totalRows++
 		totalQty += qty
 	}
+	if len(lines) == 0 {
+		b.send(tgbotapi.NewMessage(chatID, "Файл не содержит данных (нет строк с материалами)."))
+		return
+	}
+
+	// 5) приёмка на склад: вся поставка одной транзакцией
+	note := "supply_excel"
+	if comment != "" {
+		note = fmt.Sprintf("supply_excel: %s", comment)
+	}
+	if _, err := b.inventory.ReceiveSupply(ctx, u.ID, warehouseID, lines, note, comment); err != nil {
+		b.log.Error("supply excel import failed", "warehouse_id", warehouseID, "err", err)
+		b.send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Ошибка приёмки поставки, ничего не проведено: %v", err)))
+		return
+	}
 
 	if warehouseName == "" {
 		warehouseName = fmt.Sprintf("ID %d", warehouseID)
@@ -485,7 +526,7 @@ func (b *Bot) exportSupplyExcel(ctx context.Context, chatID int64, msgID int, su
 
 	fileName := fmt.Sprintf("supply_%d_%s.xlsx",
 		supplyID,
-		time.Now().Format("20060102_150405"),
+		b.now().Format("20060102_150405"),
 	)
 
 	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
@@ -511,19 +552,22 @@ func (b *Bot) showSuppliesCart(ctx context.Context, chatID int64, editMsgID *int
 	var total float64
 	for _, it := range items {
 		matID := int64(it["mat_id"].(float64))
-		qty := int64(it["qty"].(float64))
+		qty := it["qty"].(float64)
 		price := it["price"].(float64)
-		name := fmt.Sprintf("ID:%d", matID)
-		if m, _ := b.materials.GetByID(ctx, matID); m != nil {
-			if m.Brand != "" {
-				name = fmt.Sprintf("%s / %s", m.Brand, m.Name)
-			} else {
-				name = m.Name
-			}
-		}
-		lineTotal := float64(qty) * price
+		lineTotal := qty * price
 		total += lineTotal
-		lines = append(lines, fmt.Sprintf("• %s — %d × %.2f = %.2f ₽", name, qty, price, lineTotal))
+
+		m, _ := b.materials.GetByID(ctx, matID)
+		if m == nil {
+			lines = append(lines, fmt.Sprintf("• ID:%d — %s × %.2f = %.2f ₽", matID, formatQty(qty), price, lineTotal))
+			continue
+		}
+		name := m.Name
+		if m.Brand != "" {
+			name = fmt.Sprintf("%s / %s", m.Brand, m.Name)
+		}
+		lines = append(lines, fmt.Sprintf("• %s — %s × %s = %.2f ₽",
+			name, formatMaterialQty(*m, qty), formatMaterialPrice(*m, price), lineTotal))
 	}
 	lines = append(lines, fmt.Sprintf("\nИтого: %.2f ₽", total))
 
@@ -554,3 +598,42 @@ func (b *Bot) showSuppliesCart(ctx context.Context, chatID int64, editMsgID *int
 		b.saveLastStep(ctx, chatID, dialog.StateSupCart, st.Payload, sent.MessageID)
 	}
 }
+
+// supQtyPrompt — подсказка к вводу количества в поставке: в упаковках, если они заданы.
+func supQtyPrompt(m materials.Material) string {
+	if m.HasPurchaseUnit() {
+		return fmt.Sprintf("Введите количество в упаковках (%s).\n%s",
+			materialPackLabel(m), materialQtyHint(m.PurchaseUnit))
+	}
+	return "Введите количество.\n" + materialQtyHint(m.Unit)
+}
+
+// supPricePrompt — подсказка к вводу цены: за упаковку, если она задана.
+func supPricePrompt(m materials.Material) string {
+	if m.HasPurchaseUnit() {
+		return fmt.Sprintf("Введите цену за 1 %s (руб)", materialUnitLabel(string(m.PurchaseUnit)))
+	}
+	return fmt.Sprintf("Введите цену за 1 %s (руб)", materialUnitLabel(string(m.Unit)))
+}
+
+func (b *Bot) supQtyPromptByID(ctx context.Context, matID int64) string {
+	if m, _ := b.materials.GetByID(ctx, matID); m != nil {
+		return supQtyPrompt(*m)
+	}
+	return "Введите количество (число, например 250)"
+}
+
+func (b *Bot) supPricePromptByID(ctx context.Context, matID int64) string {
+	if m, _ := b.materials.GetByID(ctx, matID); m != nil {
+		return supPricePrompt(*m)
+	}
+	return "Введите цену за единицу (руб)"
+}
+
+// supplyQtyUnit — единица, в которой указывается количество в поставке.
+func supplyQtyUnit(m materials.Material) materials.Unit {
+	if m.HasPurchaseUnit() {
+		return m.PurchaseUnit
+	}
+	return m.Unit
+}
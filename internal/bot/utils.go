@@ -4,10 +4,14 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Spok95/beauty-bot/internal/dialog"
+	"github.com/Spok95/beauty-bot/internal/domain/materials"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -20,6 +24,21 @@ func (b *Bot) answerCallback(cb *tgbotapi.CallbackQuery, text string, alert bool
 	return err
 }
 
+// consItemPrice — цена позиции корзины расхода. Цена фиксируется при добавлении позиции
+// и не меняется до подтверждения, даже если в это время загрузили новые цены.
+// Если цену загрузить не удалось, в позицию ничего не записывается.
+func (b *Bot) consItemPrice(ctx context.Context, it map[string]any) (float64, error) {
+	if p, ok := it["price"].(float64); ok {
+		return p, nil
+	}
+	price, err := b.materials.GetPrice(ctx, payloadInt64(it["mat_id"]))
+	if err != nil {
+		return 0, err
+	}
+	it["price"] = price
+	return price, nil
+}
+
 func (b *Bot) consParseItems(v any) []map[string]any {
 	arr, ok := v.([]any)
 	if !ok {
@@ -84,14 +103,21 @@ func (b *Bot) send(msg tgbotapi.Chattable) {
 	}
 }
 
-// downloadTelegramFile скачивает файл по FileID через Telegram API.
-func (b *Bot) downloadTelegramFile(fileID string) ([]byte, error) {
+// telegramFileClient скачивает файлы из Telegram; таймаут — на один файл целиком.
+var telegramFileClient = &http.Client{Timeout: 2 * time.Minute}
+
+// downloadTelegramFile скачивает файл по FileID через Telegram API; прерывается вместе с ctx.
+func (b *Bot) downloadTelegramFile(ctx context.Context, fileID string) ([]byte, error) {
 	url, err := b.api.GetFileDirectURL(fileID)
 	if err != nil {
 		return nil, fmt.Errorf("get file url: %w", err)
 	}
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("download file: %w", err)
+	}
+	resp, err := telegramFileClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("download file: %w", err)
 	}
@@ -150,3 +176,88 @@ func materialDisplayName(brand, name string) string {
 
 	return brand + " " + name
 }
+
+// materialQtyPrecision — сколько знаков после запятой храним в количестве (NUMERIC(18,3)).
+const materialQtyPrecision = 1000
+
+// parseMaterialQty разбирает количество материала с учётом единицы измерения:
+// для штук — только целое, для г/мл — дробное (до 3 знаков после запятой).
+// Количество должно быть больше нуля. ok=false — строка вообще не похожа на число.
+func parseMaterialQty(s string, unit materials.Unit) (qty float64, ok bool, err error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ",", ".")
+	x, perr := strconv.ParseFloat(s, 64)
+	if perr != nil || math.IsNaN(x) || math.IsInf(x, 0) {
+		return 0, false, fmt.Errorf("некорректное число")
+	}
+	if x < 0 {
+		return 0, true, fmt.Errorf("количество не может быть отрицательным")
+	}
+	if x == 0 {
+		return 0, true, fmt.Errorf("количество должно быть больше нуля")
+	}
+	if !unit.Fractional() && x != math.Trunc(x) {
+		return 0, true, fmt.Errorf("для единицы «%s» допускается только целое число", materialUnitLabel(string(unit)))
+	}
+	if i := strings.IndexByte(s, '.'); i >= 0 && len(s)-i-1 > 3 {
+		return 0, true, fmt.Errorf("допускается не больше 3 знаков после запятой")
+	}
+	return math.Round(x*materialQtyPrecision) / materialQtyPrecision, true, nil
+}
+
+// materialQtyHint — подсказка к вводу количества для единицы материала.
+func materialQtyHint(unit materials.Unit) string {
+	if unit.Fractional() {
+		return fmt.Sprintf("Число, %s. Можно дробное, например 7.5.", materialUnitLabel(string(unit)))
+	}
+	return fmt.Sprintf("Целое число, %s.", materialUnitLabel(string(unit)))
+}
+
+// materialPackLabel — описание упаковки материала, например «1 туба = 60 г».
+func materialPackLabel(m materials.Material) string {
+	if !m.HasPurchaseUnit() {
+		return "—"
+	}
+	return fmt.Sprintf("1 %s = %s %s",
+		materialUnitLabel(string(m.PurchaseUnit)),
+		formatQty(m.PurchaseFactor),
+		materialUnitLabel(string(m.Unit)),
+	)
+}
+
+// formatMaterialQty — количество в единице расхода, а при заданной упаковке ещё и в единицах закупки.
+func formatMaterialQty(m materials.Material, usageQty float64) string {
+	s := fmt.Sprintf("%s %s", formatQty(usageQty), materialUnitLabel(string(m.Unit)))
+	if m.HasPurchaseUnit() {
+		s += fmt.Sprintf(" (≈ %s %s)", formatQty(m.ToPurchase(usageQty)), materialUnitLabel(string(m.PurchaseUnit)))
+	}
+	return s
+}
+
+// formatMaterialPrice — цена за единицу расхода, а при заданной упаковке ещё и за единицу закупки.
+func formatMaterialPrice(m materials.Material, pricePerUnit float64) string {
+	s := fmt.Sprintf("%.2f ₽/%s", pricePerUnit, materialUnitLabel(string(m.Unit)))
+	if m.HasPurchaseUnit() {
+		s += fmt.Sprintf(" (%.2f ₽/%s)", pricePerUnit*m.PurchaseFactor, materialUnitLabel(string(m.PurchaseUnit)))
+	}
+	return s
+}
+
+// parsePeriod разбирает период «ДД.ММ.ГГГГ-ДД.ММ.ГГГГ»; дата окончания включительно,
+// поэтому возвращается исключающая граница (+1 день). Даты — в часовом поясе салона (loc).
+// errText — текст ошибки для пользователя.
+func parsePeriod(s string, loc *time.Location) (from, toExclusive time.Time, errText string) {
+	dates := strings.Split(strings.TrimSpace(s), "-")
+	if len(dates) != 2 {
+		return from, toExclusive, "Неверный формат. Используйте ДД.ММ.ГГГГ-ДД.ММ.ГГГГ, например 01.11.2025-30.11.2025."
+	}
+	const layout = "02.01.2006"
+	from, err1 := time.ParseInLocation(layout, strings.TrimSpace(dates[0]), loc)
+	to, err2 := time.ParseInLocation(layout, strings.TrimSpace(dates[1]), loc)
+	if err1 != nil || err2 != nil {
+		return from, toExclusive, "Не удалось разобрать дату. Проверьте формат ДД.ММ.ГГГГ."
+	}
+	if to.Before(from) {
+		return from, toExclusive, "Дата окончания должна быть не раньше даты начала."
+	}
+	return from, to.AddDate(0, 0, 1), ""
+}
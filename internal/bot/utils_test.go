@@ -0,0 +1,65 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/Spok95/beauty-bot/internal/domain/materials"
+)
+
+func TestParseMaterialQty(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		unit    materials.Unit
+		want    float64
+		wantOK  bool
+		wantErr bool
+	}{
+		{name: "граммы с запятой", in: "7,5", unit: materials.UnitG, want: 7.5, wantOK: true},
+		{name: "граммы с точкой", in: "7.5", unit: materials.UnitG, want: 7.5, wantOK: true},
+		{name: "миллилитры, три знака", in: " 0,125 ", unit: materials.UnitMl, want: 0.125, wantOK: true},
+		{name: "штуки, целое", in: "3", unit: materials.UnitPcs, want: 3, wantOK: true},
+		{name: "штуки, дробное", in: "7.5", unit: materials.UnitPcs, wantOK: true, wantErr: true},
+		{name: "больше трёх знаков после запятой", in: "7,1234", unit: materials.UnitG, wantOK: true, wantErr: true},
+		{name: "ноль", in: "0", unit: materials.UnitG, wantOK: true, wantErr: true},
+		{name: "ноль с дробной частью", in: "0,000", unit: materials.UnitG, wantOK: true, wantErr: true},
+		{name: "отрицательное", in: "-2", unit: materials.UnitG, wantOK: true, wantErr: true},
+		{name: "не число", in: "крем", unit: materials.UnitG, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := parseMaterialQty(tt.in, tt.unit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMaterialQty(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if ok != tt.wantOK {
+				t.Errorf("parseMaterialQty(%q) ok = %v, want %v", tt.in, ok, tt.wantOK)
+			}
+			if got != tt.want {
+				t.Errorf("parseMaterialQty(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatQty(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+		want string
+	}{
+		{name: "целое int", in: 3, want: "3"},
+		{name: "целое float", in: 7.0, want: "7"},
+		{name: "половина", in: 7.5, want: "7.5"},
+		{name: "хвостовые нули", in: 7.120, want: "7.12"},
+		{name: "три знака", in: 0.125, want: "0.125"},
+		{name: "лишние знаки округляются", in: 7.1234, want: "7.123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatQty(tt.in); got != tt.want {
+				t.Errorf("formatQty(%v) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
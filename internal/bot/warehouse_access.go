@@ -3,6 +3,7 @@ package bot
 import (
 	"context"
 	"fmt"
+	"sort"
 
 	"github.com/Spok95/beauty-bot/internal/dialog"
 	"github.com/Spok95/beauty-bot/internal/domain/catalog"
@@ -67,10 +68,22 @@ func (b *Bot) showConsumptionWarehousePick(ctx context.Context, chatID int64, ed
 		return
 	}
 
+	// склад по умолчанию из профиля — первым и со звёздочкой
+	var defaultWh int64
+	if id := b.profileOf(ctx, u).DefaultWarehouseID; id != nil {
+		defaultWh = *id
+		sort.SliceStable(warehouses, func(i, j int) bool {
+			return warehouses[i].ID == defaultWh && warehouses[j].ID != defaultWh
+		})
+	}
+
 	rows := [][]tgbotapi.InlineKeyboardButton{}
 
 	for _, w := range warehouses {
 		label := fmt.Sprintf("%s (%s)", w.Name, warehouseTypeLabel(w.Type))
+		if w.ID == defaultWh {
+			label = "⭐ " + label
+		}
 		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("cons:wh:%d", w.ID)),
 		))
@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// webhookSecretHeader — заголовок, в котором Telegram присылает secret_token из setWebhook.
+const webhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// webhookAllowedUpdates — обновления, которые бот обрабатывает; остальные Telegram не присылает.
+var webhookAllowedUpdates = []string{"message", "callback_query"}
+
+// WebhookOptions — приём обновлений через webhook на общем HTTP-сервере.
+type WebhookOptions struct {
+	URL            string // публичный https-адрес эндпоинта целиком
+	Secret         string // secret_token: Telegram присылает его в каждом запросе
+	MaxConnections int    // 0 — по умолчанию Telegram (40)
+}
+
+// NewWebhookSecret — случайный secret_token, если он не задан в конфигурации.
+func NewWebhookSecret() string {
+	buf := make([]byte, 32)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// WebhookHandler — эндпоинт для обновлений от Telegram. Запросы без верного secret_token
+// отклоняются; обновления обрабатываются тем же циклом, что и при long polling, по одному.
+func (b *Bot) WebhookHandler(secret string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := r.Header.Get(webhookSecretHeader)
+		if subtle.ConstantTimeCompare([]byte(got), []byte(secret)) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		upd, err := b.api.HandleUpdate(r)
+		if err != nil {
+			b.log.Warn("webhook: bad update", "err", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		select {
+		case b.webhookUpdates <- *upd:
+			w.WriteHeader(http.StatusOK)
+		case <-r.Context().Done():
+			// очередь не разобрана — Telegram повторит доставку
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+}
+
+// RunWebhook регистрирует webhook в Telegram и обрабатывает обновления из WebhookHandler.
+// Если зарегистрировать webhook не удалось, бот переходит на long polling.
+func (b *Bot) RunWebhook(ctx context.Context, opts WebhookOptions, pollTimeoutSec int) error {
+	if err := b.setWebhook(opts); err != nil {
+		b.log.Error("setWebhook failed, falling back to long polling", "err", err)
+		return b.Run(ctx, pollTimeoutSec)
+	}
+	b.log.Info("telegram webhook registered", "url", opts.URL)
+	return b.serve(ctx, b.webhookUpdates)
+}
+
+func (b *Bot) setWebhook(opts WebhookOptions) error {
+	if opts.URL == "" {
+		return fmt.Errorf("webhook url is empty")
+	}
+	params := tgbotapi.Params{}
+	params["url"] = opts.URL
+	params.AddNonEmpty("secret_token", opts.Secret)
+	params.AddNonZero("max_connections", opts.MaxConnections)
+	if err := params.AddInterface("allowed_updates", webhookAllowedUpdates); err != nil {
+		return err
+	}
+	_, err := b.api.MakeRequest("setWebhook", params)
+	return err
+}
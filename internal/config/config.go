@@ -16,8 +16,17 @@ type Config struct {
 	Telegram struct {
 		Token             string
 		AdminChatID       int64   `mapstructure:"admin_chat_id"`
-		AdminIDs          []int64 `mapstructure:"-"`
+		AdminIDs          []int64 `mapstructure:"-"` // только первичная инициализация админов в БД
 		RequestTimeoutSec int     `mapstructure:"request_timeout_sec"`
+
+		// Webhook — приём обновлений через эндпоинт HTTP-сервера вместо long polling.
+		Webhook struct {
+			Enabled        bool
+			URL            string // публичный https-адрес сервера (за прокси), без пути
+			Path           string // путь эндпоинта на HTTP-сервере
+			Secret         string // secret_token; пусто — генерируется при запуске
+			MaxConnections int    `mapstructure:"max_connections"`
+		} `mapstructure:"webhook"`
 	} `mapstructure:"telegram"`
 
 	HTTP struct {
@@ -35,6 +44,15 @@ type Config struct {
 	Payments struct {
 		BaseURL string `mapstructure:"base_url"`
 	} `mapstructure:"payments"`
+
+	// AdminChat — обращения в админ-чат: через сколько минут без ответа напоминать ответственному (0 — не напоминать).
+	AdminChat struct {
+		SLAMinutes int `mapstructure:"sla_minutes"`
+	} `mapstructure:"admin_chat"`
+
+	// Permissions — переопределение прав ролей: роль → список прав (см. internal/access).
+	// Роль, указанная здесь, получает ровно перечисленные права вместо набора по умолчанию.
+	Permissions map[string][]string `mapstructure:"permissions"`
 }
 
 func Load(path string) (Config, error) {
@@ -48,10 +66,15 @@ func Load(path string) (Config, error) {
 	_ = v.BindEnv("telegram.admin_chat_id", "TELEGRAM_ADMIN_CHAT_ID")
 	_ = v.BindEnv("telegram.admin_ids", "TELEGRAM_ADMIN_IDS")
 	_ = v.BindEnv("postgres.dsn", "POSTGRES_DSN", "APP_POSTGRES_DSN")
+	_ = v.BindEnv("telegram.webhook.enabled", "TELEGRAM_WEBHOOK_ENABLED")
+	_ = v.BindEnv("telegram.webhook.url", "TELEGRAM_WEBHOOK_URL")
+	_ = v.BindEnv("telegram.webhook.secret", "TELEGRAM_WEBHOOK_SECRET")
 
 	// Дефолты
 	v.SetDefault("telegram.request_timeout_sec", 30)
 	v.SetDefault("telegram.admin_ids", "")
+	v.SetDefault("admin_chat.sla_minutes", 120)
+	v.SetDefault("telegram.webhook.path", "/telegram/webhook")
 
 	var c Config
 	if err := v.ReadInConfig(); err != nil {
@@ -8,6 +8,8 @@ const (
 	StateAwaitFIO     State = "await_fio"
 	StateAwaitRole    State = "await_role"
 	StateAwaitConfirm State = "await_confirm"
+	StateAwaitPhone   State = "await_phone" // телефон: кнопка «Отправить номер» или ввод
+	StateAwaitSpec    State = "await_spec"  // специализация мастера (мультивыбор)
 
 	// Склады
 	StateAdmWhMenu   State = "adm_wh_menu"
@@ -29,8 +31,35 @@ const (
 	StateAdmMatName          State = "adm_mat_name"
 	StateAdmMatUnit          State = "adm_mat_unit"
 	StateAdmMatRename        State = "adm_mat_rename"
+	StateAdmMatPurchaseUnit  State = "adm_mat_purchase_unit" // выбор единицы закупки (упаковки)
+	StateAdmMatPackFactor    State = "adm_mat_pack_factor"   // ввод: сколько единиц расхода в упаковке
+	StateAdmMatBarcode       State = "adm_mat_barcode"       // штрихкоды материала: ожидание кода/фото
+	StateAdmMatSearch        State = "adm_mat_search"        // поиск материала по названию
+	StateAdmBrandAlias       State = "adm_brand_alias"       // синонимы бренда: ожидание нового синонима
 	StateAdmMatPickWarehouse State = "adm_mat_pick_wh"
 
+	// Пользователи (админ)
+	StateAdmUsersList        State = "adm_users_list"         // список пользователей (payload: query, page)
+	StateAdmUsersSearch      State = "adm_users_search"       // ввод строки поиска
+	StateAdmUserCard         State = "adm_user_card"          // карточка пользователя (payload: user_id)
+	StateAdmUserRename       State = "adm_user_rename"        // ввод нового ФИО
+	StateAdmUserSwitchRole   State = "adm_user_switch_role"   // выбор роли для принудительного переключения
+	StateAdmUserProfile      State = "adm_user_profile"       // редактор профиля (payload: user_id)
+	StateAdmUserProfileField State = "adm_user_profile_field" // ввод поля профиля (payload: user_id, field)
+	StateAdmInvites          State = "adm_user_invites"       // список приглашений
+	StateAdmInviteNew        State = "adm_user_invite_new"    // настройка приглашения (payload: role, place, warehouse_id, auto, uses, days)
+	StateAdmInviteCard       State = "adm_user_invite_card"   // карточка приглашения (payload: invite_id)
+
+	// Журнал изменений (админ)
+	StateAdmAudit             State = "adm_audit"               // выбор раздела журнала
+	StateAdmAuditList         State = "adm_audit_list"          // события (payload: entity, entity_id, page)
+	StateAdmAuditEvent        State = "adm_audit_event"         // событие (payload как у списка + event_id)
+	StateAdmAuditExportPeriod State = "adm_audit_export_period" // ввод периода выгрузки в Excel
+
+	// Шаблоны расхода (админ)
+	StateAdmTplMenu       State = "adm_tpl_menu"
+	StateAdmTplImportFile State = "adm_tpl_import_file" // ожидание Excel с шаблонами
+
 	// Остатки/движения
 	StateStockPickWh       State = "stock_pick_wh"
 	StateStockList         State = "stock_list"        // список материалов с остатком в выбранном складе
@@ -45,6 +74,7 @@ const (
 	StateSupMenu          State = "sup_menu"
 	StateSupPickWh        State = "sup_pick_wh"
 	StateSupPickMat       State = "sup_pick_mat"
+	StateSupBarcode       State = "sup_barcode" // выбор материала по штрихкоду (код или фото)
 	StateSupQty           State = "sup_qty"
 	StateSupUnitPrice     State = "sup_unit_price"
 	StateSupCart          State = "sup_cart" // корзина с позициями (старая логика)
@@ -66,10 +96,16 @@ const (
 	StateConsMatSearch    State = "cons_mat_search"    // меню: выбор способа поиска материала
 	StateConsSearchByName State = "cons_search_name"   // ввод строки для поиска по названию
 	StateConsMatPick      State = "cons_mat_pick"      // список найденных материалов
-	StateConsMatQty       State = "cons_mat_qty"       // int (г/шт)
+	StateConsBarcode      State = "cons_barcode"       // выбор материала по штрихкоду (код или фото)
+	StateConsMatQty       State = "cons_mat_qty"       // количество материала (г/мл — дробное, шт — целое)
 	StateConsCart         State = "cons_cart"          // корзина материалов
 	StateConsFinalComment State = "cons_final_comment" // комментарий перед итоговым чеком
 	StateConsSummary      State = "cons_summary"
+	StateConsPromoCode    State = "cons_promo_code" // ввод промокода из сводки
+	StateConsTplPick      State = "cons_tpl_pick"   // выбор шаблона расхода
+	StateConsTplName      State = "cons_tpl_name"   // ввод названия нового шаблона из корзины
+	StateConsItemPick     State = "cons_item_pick"  // выбор позиции корзины для изменения
+	StateConsItemQty      State = "cons_item_qty"   // ввод нового количества позиции корзины
 
 	// Абонементы (админ)
 	StateAdmSubsMenu          State = "adm_subs_menu"
@@ -83,8 +119,9 @@ const (
 	StateAdmRatesPickPU  State = "adm:rates:pick_pu"  // выбор место/единица
 	StateAdmRatesPickSub State = "adm:rates:pick_sub" // тумблер абонемента
 	StateAdmRatesList    State = "adm:rates:list"     // список ступеней
+	StateAdmRatesCard    State = "adm:rates:card"     // карточка ступени
 
-	// Создание ступени
+	// Создание и изменение ступени (при изменении в payload есть rate_id)
 	StateAdmRatesCreateMin       State = "adm:rates:create:min"
 	StateAdmRatesCreateMax       State = "adm:rates:create:max"
 	StateAdmRatesCreateThreshold State = "adm:rates:create:thr"
@@ -108,15 +145,29 @@ const (
 	// Тарифы аренды
 	StatePriceRentMenu       State = "price_rent_menu"
 	StatePriceRentImportFile State = "price_rent_import_file"
+	StatePriceRentVersions   State = "price_rent_versions"     // список версий тарифов
+	StatePriceRentVersion    State = "price_rent_version"      // версия: сравнение и симуляция (payload: version_id)
+	StatePriceRentPublish    State = "price_rent_publish_date" // ввод даты начала действия черновика (payload: version_id)
+
+	// Скидки и акции
+	StatePriceDiscMenu       State = "price_disc_menu"
+	StatePriceDiscImportFile State = "price_disc_import_file"
 
 	StateAdmReportRentPeriod State = "adm_report_rent_period"
+	StateAdmReportSchedules  State = "adm_report_schedules" // подписки на отчёты по расписанию
+	StateAdmReportAnalytics  State = "adm_report_analytics" // ввод периода для аналитики
 
 	// Мастер: ввод строки для поиска остатков по названию материала
 	StateMasterStockSearchByName State = "master_stock_search_by_name"
 
-	// Чат с админом и рассылка
-	StateChatAdmin       State = "chat_admin"
-	StateAdmBroadcastAll State = "adm_broadcast_all"
+	// Чат с админом и рассылки
+	StateChatAdmin            State = "chat_admin"
+	StateAdmChatFind          State = "adm_chat_find"          // поиск обращения по имени мастера
+	StateAdmChatSearch        State = "adm_chat_search"        // поиск по сообщениям: запрос и фильтры
+	StateAdmBroadcastMenu     State = "adm_broadcast_menu"     // меню рассылок
+	StateAdmBroadcastContent  State = "adm_broadcast_content"  // ввод текста, фото или документа
+	StateAdmBroadcastSegment  State = "adm_broadcast_segment"  // выбор получателей и предпросмотр
+	StateAdmBroadcastSchedule State = "adm_broadcast_schedule" // ввод даты и времени отправки
 )
 
 type Payload map[string]any
@@ -19,6 +19,7 @@ type Message struct {
 	TelegramMessageID    int64
 	TelegramMediaGroupID string
 	ReplyToMessageID     int64
+	ThreadID             int64
 	CreatedAt            time.Time
 }
 
@@ -38,4 +39,55 @@ type CreateMessageInput struct {
 	TelegramMessageID    int64
 	TelegramMediaGroupID string
 	ReplyToMessageID     int64
+	ThreadID             int64
+}
+
+// ThreadStatus — статус обращения.
+type ThreadStatus string
+
+const (
+	ThreadOpen       ThreadStatus = "open"        // новое, никто не взял
+	ThreadInProgress ThreadStatus = "in_progress" // назначен ответственный или уже ответили
+	ThreadResolved   ThreadStatus = "resolved"    // вопрос закрыт; новое сообщение автора открывает его снова
+)
+
+// Thread — обращение: все сообщения одного автора и ответы на них.
+type Thread struct {
+	ID                int64
+	UserID            int64
+	UserTelegramID    int64
+	UserName          string // ФИО из анкеты или username
+	Status            ThreadStatus
+	AssigneeID        int64
+	AssigneeName      string
+	AwaitingReply     bool
+	LastMessageAt     time.Time
+	LastUserMessageAt *time.Time
+	LastReplyAt       *time.Time
+	CreatedAt         time.Time
+
+	Unread int // непрочитанных сообщений для того, кто запрашивал список
+}
+
+// ThreadFilter — отбор обращений. Пустое поле — без ограничения.
+type ThreadFilter struct {
+	Status     ThreadStatus
+	AssigneeID int64
+	UnreadOnly bool
+	Query      string // часть ФИО или username автора
+}
+
+// SearchFilter — отбор сообщений при поиске. Пустое поле — без ограничения.
+type SearchFilter struct {
+	Query       string    // полнотекстовый запрос по тексту, подписи и имени файла
+	Sender      string    // часть ФИО или username отправителя
+	MessageType string    // text|photo|document|video|audio|voice
+	From, To    time.Time // To — исключающая граница
+}
+
+// SearchHit — найденное сообщение с именем отправителя и фрагментом, где нашлось совпадение.
+type SearchHit struct {
+	Message
+	SenderName string
+	Snippet    string // совпадения выделены «…»; пусто без текстового запроса
 }
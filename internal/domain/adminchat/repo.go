@@ -2,7 +2,11 @@ package adminchat
 
 import (
 	"context"
+	"time"
 
+	"github.com/Spok95/beauty-bot/internal/infra/db"
+
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -23,6 +27,10 @@ func (r *Repo) Create(ctx context.Context, in CreateMessageInput) (*Message, err
 	if in.ReplyToMessageID > 0 {
 		replyToMessageID = in.ReplyToMessageID
 	}
+	var threadID any
+	if in.ThreadID > 0 {
+		threadID = in.ThreadID
+	}
 
 	row := r.pool.QueryRow(ctx, `
 		INSERT INTO admin_chat_messages (
@@ -39,14 +47,15 @@ func (r *Repo) Create(ctx context.Context, in CreateMessageInput) (*Message, err
 			mime_type,
 			file_size,
 			telegram_message_id,
-		    telegram_media_group_id,
-		    reply_to_message_id
+			telegram_media_group_id,
+			reply_to_message_id,
+			thread_id
 		)
 		VALUES (
 			$1, $2, $3, $4,
 			$5, $6, $7,
 			$8, $9, $10, $11, $12,
-			$13, $14, $15
+			$13, $14, $15, $16
 		)
 		RETURNING
 			id,
@@ -65,6 +74,7 @@ func (r *Repo) Create(ctx context.Context, in CreateMessageInput) (*Message, err
 			telegram_message_id,
 			telegram_media_group_id,
 			COALESCE(reply_to_message_id, 0),
+			COALESCE(thread_id, 0),
 			created_at
 	`,
 		senderUserID,
@@ -82,6 +92,7 @@ func (r *Repo) Create(ctx context.Context, in CreateMessageInput) (*Message, err
 		in.TelegramMessageID,
 		in.TelegramMediaGroupID,
 		replyToMessageID,
+		threadID,
 	)
 
 	var m Message
@@ -102,6 +113,7 @@ func (r *Repo) Create(ctx context.Context, in CreateMessageInput) (*Message, err
 		&m.TelegramMessageID,
 		&m.TelegramMediaGroupID,
 		&m.ReplyToMessageID,
+		&m.ThreadID,
 		&m.CreatedAt,
 	); err != nil {
 		return nil, err
@@ -189,6 +201,7 @@ func (r *Repo) GetByID(ctx context.Context, id int64) (*Message, error) {
 			telegram_message_id,
 			telegram_media_group_id,
 			COALESCE(reply_to_message_id, 0),
+			COALESCE(thread_id, 0),
 			created_at
 		FROM admin_chat_messages
 		WHERE id = $1
@@ -213,6 +226,7 @@ func (r *Repo) GetByID(ctx context.Context, id int64) (*Message, error) {
 		&m.TelegramMessageID,
 		&m.TelegramMediaGroupID,
 		&m.ReplyToMessageID,
+		&m.ThreadID,
 		&m.CreatedAt,
 	); err != nil {
 		return nil, err
@@ -220,3 +234,323 @@ func (r *Repo) GetByID(ctx context.Context, id int64) (*Message, error) {
 
 	return &m, nil
 }
+
+const messageColumns = `
+	m.id,
+	COALESCE(m.sender_user_id, 0),
+	m.sender_telegram_id,
+	m.sender_username,
+	m.sender_role,
+	m.message_type,
+	m.text,
+	m.caption,
+	m.telegram_file_id,
+	m.telegram_file_unique_id,
+	m.file_name,
+	m.mime_type,
+	m.file_size,
+	m.telegram_message_id,
+	m.telegram_media_group_id,
+	COALESCE(m.reply_to_message_id, 0),
+	COALESCE(m.thread_id, 0),
+	m.created_at
+`
+
+func scanMessages(rows pgx.Rows) ([]Message, error) {
+	defer rows.Close()
+	var out []Message
+	for rows.Next() {
+		var m Message
+		if err := rows.Scan(
+			&m.ID, &m.SenderUserID, &m.SenderTelegramID, &m.SenderUsername, &m.SenderRole,
+			&m.MessageType, &m.Text, &m.Caption,
+			&m.TelegramFileID, &m.TelegramFileUniqueID, &m.FileName, &m.MimeType, &m.FileSize,
+			&m.TelegramMessageID, &m.TelegramMediaGroupID, &m.ReplyToMessageID, &m.ThreadID, &m.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// EnsureThread возвращает обращение автора userID, создавая его при первом сообщении.
+func (r *Repo) EnsureThread(ctx context.Context, userID int64) (int64, error) {
+	var id int64
+	err := r.pool.QueryRow(ctx, `
+INSERT INTO admin_chat_threads (user_id) VALUES ($1)
+ON CONFLICT (user_id) DO UPDATE SET user_id = EXCLUDED.user_id
+RETURNING id`, userID).Scan(&id)
+	return id, err
+}
+
+// TouchByAuthor отмечает новое сообщение автора: решённое обращение открывается снова,
+// SLA считается от первого неотвеченного сообщения.
+func (r *Repo) TouchByAuthor(ctx context.Context, threadID int64) error {
+	_, err := r.pool.Exec(ctx, `
+UPDATE admin_chat_threads
+SET status               = CASE WHEN status = 'resolved' THEN 'open' ELSE status END,
+    last_message_at      = now(),
+    last_user_message_at = CASE WHEN awaiting_reply THEN last_user_message_at ELSE now() END,
+    sla_notified_at      = CASE WHEN awaiting_reply THEN sla_notified_at END,
+    awaiting_reply       = TRUE
+WHERE id = $1`, threadID)
+	return err
+}
+
+// TouchByStaff отмечает ответ администрации: новое обращение переходит в работу,
+// а без ответственного им становится ответивший.
+func (r *Repo) TouchByStaff(ctx context.Context, threadID, staffID int64) error {
+	_, err := r.pool.Exec(ctx, `
+UPDATE admin_chat_threads
+SET status          = CASE WHEN status = 'open' THEN 'in_progress' ELSE status END,
+    assignee_id     = COALESCE(assignee_id, $2),
+    last_message_at = now(),
+    last_reply_at   = now(),
+    awaiting_reply  = FALSE,
+    sla_notified_at = NULL
+WHERE id = $1`, threadID, staffID)
+	return err
+}
+
+// threadSelect — обращения с именами автора и ответственного и счётчиком непрочитанного для $1.
+const threadSelect = `
+SELECT t.id, t.user_id, u.telegram_id,
+       COALESCE(NULLIF(concat_ws(' ', NULLIF(p.last_name, ''), NULLIF(p.first_name, ''), NULLIF(p.middle_name, '')), ''),
+                u.username, '') AS name,
+       t.status, COALESCE(t.assignee_id, 0),
+       COALESCE(NULLIF(concat_ws(' ', NULLIF(ap.last_name, ''), NULLIF(ap.first_name, '')), ''), a.username, ''),
+       t.awaiting_reply, t.last_message_at, t.last_user_message_at, t.last_reply_at, t.created_at,
+       (SELECT COUNT(*) FROM admin_chat_messages AS m
+        WHERE m.thread_id = t.id
+          AND m.id > COALESCE(rd.last_read_message_id, 0)
+          AND m.sender_user_id IS DISTINCT FROM $1) AS unread
+FROM admin_chat_threads AS t
+JOIN users AS u ON u.id = t.user_id
+LEFT JOIN user_profiles AS p ON p.user_id = t.user_id
+LEFT JOIN users AS a ON a.id = t.assignee_id
+LEFT JOIN user_profiles AS ap ON ap.user_id = t.assignee_id
+LEFT JOIN admin_chat_reads AS rd ON rd.thread_id = t.id AND rd.user_id = $1
+`
+
+func scanThreads(rows pgx.Rows) ([]Thread, error) {
+	defer rows.Close()
+	var out []Thread
+	for rows.Next() {
+		var t Thread
+		if err := rows.Scan(
+			&t.ID, &t.UserID, &t.UserTelegramID, &t.UserName,
+			&t.Status, &t.AssigneeID, &t.AssigneeName,
+			&t.AwaitingReply, &t.LastMessageAt, &t.LastUserMessageAt, &t.LastReplyAt, &t.CreatedAt,
+			&t.Unread,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// GetThread — обращение со счётчиком непрочитанного для readerID; nil — не найдено.
+func (r *Repo) GetThread(ctx context.Context, id, readerID int64) (*Thread, error) {
+	rows, err := r.pool.Query(ctx, threadSelect+`WHERE t.id = $2`, readerID, id)
+	if err != nil {
+		return nil, err
+	}
+	list, err := scanThreads(rows)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// ListThreads — обращения по фильтру, сначала ждущие ответа, затем по последней активности.
+func (r *Repo) ListThreads(ctx context.Context, f ThreadFilter, readerID int64, limit, offset int) ([]Thread, error) {
+	rows, err := r.pool.Query(ctx, `SELECT * FROM (`+threadSelect+`
+WHERE ($2::text = '' OR t.status = $2::text)
+  AND ($3::bigint = 0 OR t.assignee_id = $3::bigint)
+  AND ($4::text = '' OR concat_ws(' ', p.last_name, p.first_name, p.middle_name, u.username) ILIKE '%' || $4::text || '%' ESCAPE '\')
+) AS x
+WHERE NOT $5::bool OR x.unread > 0
+ORDER BY x.status = 'resolved', x.awaiting_reply DESC, x.last_message_at DESC
+LIMIT $6 OFFSET $7`,
+		readerID, string(f.Status), f.AssigneeID, db.EscapeLike(f.Query), f.UnreadOnly, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return scanThreads(rows)
+}
+
+// ThreadMessages — сообщения обращения, новые сверху.
+func (r *Repo) ThreadMessages(ctx context.Context, threadID int64, limit, offset int) ([]Message, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+messageColumns+`
+FROM admin_chat_messages AS m
+WHERE m.thread_id = $1
+ORDER BY m.id DESC
+LIMIT $2 OFFSET $3`, threadID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return scanMessages(rows)
+}
+
+// LastAuthorMessage — последнее сообщение автора обращения (на него уходит ответ из карточки); nil — нет.
+func (r *Repo) LastAuthorMessage(ctx context.Context, threadID int64) (*Message, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+messageColumns+`
+FROM admin_chat_messages AS m
+WHERE m.thread_id = $1
+  AND m.sender_user_id = (SELECT user_id FROM admin_chat_threads WHERE id = $1)
+ORDER BY m.id DESC
+LIMIT 1`, threadID)
+	if err != nil {
+		return nil, err
+	}
+	list, err := scanMessages(rows)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// MarkRead отмечает обращение прочитанным пользователем userID до последнего сообщения.
+func (r *Repo) MarkRead(ctx context.Context, threadID, userID int64) error {
+	_, err := r.pool.Exec(ctx, `
+INSERT INTO admin_chat_reads (thread_id, user_id, last_read_message_id)
+SELECT $1, $2, COALESCE(MAX(id), 0) FROM admin_chat_messages WHERE thread_id = $1
+ON CONFLICT (thread_id, user_id) DO UPDATE
+SET last_read_message_id = GREATEST(admin_chat_reads.last_read_message_id, EXCLUDED.last_read_message_id)`,
+		threadID, userID)
+	return err
+}
+
+// UnreadTotal — сколько обращений и сообщений в них не прочитано пользователем userID.
+func (r *Repo) UnreadTotal(ctx context.Context, userID int64) (threads, messages int, err error) {
+	err = r.pool.QueryRow(ctx, `
+SELECT COUNT(DISTINCT m.thread_id), COUNT(*)
+FROM admin_chat_messages AS m
+LEFT JOIN admin_chat_reads AS rd ON rd.thread_id = m.thread_id AND rd.user_id = $1
+WHERE m.thread_id IS NOT NULL
+  AND m.id > COALESCE(rd.last_read_message_id, 0)
+  AND m.sender_user_id IS DISTINCT FROM $1`, userID).Scan(&threads, &messages)
+	return threads, messages, err
+}
+
+// SetStatus меняет статус обращения; решённое больше не ждёт ответа.
+func (r *Repo) SetStatus(ctx context.Context, id int64, status ThreadStatus) error {
+	_, err := r.pool.Exec(ctx, `
+UPDATE admin_chat_threads
+SET status          = $2,
+    awaiting_reply  = awaiting_reply AND $2 <> 'resolved',
+    sla_notified_at = CASE WHEN $2 = 'resolved' THEN NULL ELSE sla_notified_at END
+WHERE id = $1`, id, string(status))
+	return err
+}
+
+// Assign назначает ответственного; новое обращение при этом переходит в работу.
+func (r *Repo) Assign(ctx context.Context, id, assigneeID int64) error {
+	_, err := r.pool.Exec(ctx, `
+UPDATE admin_chat_threads
+SET assignee_id = $2,
+    status      = CASE WHEN status = 'open' THEN 'in_progress' ELSE status END
+WHERE id = $1`, id, assigneeID)
+	return err
+}
+
+// ListOverdue — обращения без ответа дольше sla, по которым ещё не напоминали
+// (или напоминали раньше, чем sla назад).
+func (r *Repo) ListOverdue(ctx context.Context, sla time.Duration) ([]Thread, error) {
+	rows, err := r.pool.Query(ctx, threadSelect+`
+WHERE t.status <> 'resolved'
+  AND t.awaiting_reply
+  AND t.last_user_message_at <= now() - $2::bigint * interval '1 second'
+  AND (t.sla_notified_at IS NULL OR t.sla_notified_at <= now() - $2::bigint * interval '1 second')
+ORDER BY t.last_user_message_at`, int64(0), int64(sla/time.Second))
+	if err != nil {
+		return nil, err
+	}
+	return scanThreads(rows)
+}
+
+// MarkSLANotified запоминает время напоминания о просроченном обращении.
+func (r *Repo) MarkSLANotified(ctx context.Context, id int64) error {
+	_, err := r.pool.Exec(ctx, `UPDATE admin_chat_threads SET sla_notified_at = now() WHERE id = $1`, id)
+	return err
+}
+
+// Search ищет сообщения по фильтру: с запросом — по релевантности, без него — новые сверху.
+// total — сколько всего подходит под фильтр.
+func (r *Repo) Search(ctx context.Context, f SearchFilter, limit, offset int) (hits []SearchHit, total int, err error) {
+	var from, to any
+	if !f.From.IsZero() {
+		from = f.From
+	}
+	if !f.To.IsZero() {
+		to = f.To
+	}
+	rows, err := r.pool.Query(ctx, `
+WITH q AS (SELECT websearch_to_tsquery('russian', $1::text) AS query)
+SELECT `+messageColumns+`,
+       COALESCE(NULLIF(concat_ws(' ', NULLIF(p.last_name, ''), NULLIF(p.first_name, ''), NULLIF(p.middle_name, '')), ''),
+                NULLIF(u.username, ''), m.sender_username) AS sender_name,
+       CASE WHEN $1::text = '' THEN ''
+            ELSE ts_headline('russian', concat_ws(' ', m.text, m.caption, m.file_name), q.query,
+                             'StartSel="«", StopSel="»", MaxWords=25, MinWords=8, MaxFragments=2')
+       END,
+       COUNT(*) OVER ()
+FROM admin_chat_messages AS m
+CROSS JOIN q
+LEFT JOIN users AS u ON u.id = m.sender_user_id
+LEFT JOIN user_profiles AS p ON p.user_id = m.sender_user_id
+WHERE ($1::text = '' OR m.search_tsv @@ q.query)
+  AND ($2::text = '' OR concat_ws(' ', p.last_name, p.first_name, p.middle_name, u.username, m.sender_username)
+                        ILIKE '%' || $2::text || '%' ESCAPE '\')
+  AND ($3::text = '' OR m.message_type = $3::text)
+  AND ($4::timestamptz IS NULL OR m.created_at >= $4::timestamptz)
+  AND ($5::timestamptz IS NULL OR m.created_at < $5::timestamptz)
+ORDER BY CASE WHEN $1::text = '' THEN 0 ELSE ts_rank(m.search_tsv, q.query) END DESC, m.id DESC
+LIMIT $6 OFFSET $7`,
+		f.Query, db.EscapeLike(f.Sender), f.MessageType, from, to, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var h SearchHit
+		m := &h.Message
+		if err := rows.Scan(
+			&m.ID, &m.SenderUserID, &m.SenderTelegramID, &m.SenderUsername, &m.SenderRole,
+			&m.MessageType, &m.Text, &m.Caption,
+			&m.TelegramFileID, &m.TelegramFileUniqueID, &m.FileName, &m.MimeType, &m.FileSize,
+			&m.TelegramMessageID, &m.TelegramMediaGroupID, &m.ReplyToMessageID, &m.ThreadID, &m.CreatedAt,
+			&h.SenderName, &h.Snippet, &total,
+		); err != nil {
+			return nil, 0, err
+		}
+		hits = append(hits, h)
+	}
+	return hits, total, rows.Err()
+}
+
+// ThreadHistory — вся переписка обращения по порядку (для выгрузки).
+func (r *Repo) ThreadHistory(ctx context.Context, threadID int64) ([]Message, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+messageColumns+`
+FROM admin_chat_messages AS m
+WHERE m.thread_id = $1
+ORDER BY m.id`, threadID)
+	if err != nil {
+		return nil, err
+	}
+	return scanMessages(rows)
+}
+
+// MediaGroup — все сообщения альбома отправителя по порядку.
+func (r *Repo) MediaGroup(ctx context.Context, senderTelegramID int64, groupID string) ([]Message, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+messageColumns+`
+FROM admin_chat_messages AS m
+WHERE m.sender_telegram_id = $1 AND m.telegram_media_group_id = $2
+ORDER BY m.id`, senderTelegramID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return scanMessages(rows)
+}
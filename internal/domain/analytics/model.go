@@ -0,0 +1,202 @@
+// Package analytics считает сводные показатели раздела "Аналитика" по сессиям расхода/аренды:
+// выручку, загрузку помещений, топ материалов и расход материалов мастеров.
+package analytics
+
+import (
+	"sort"
+
+	"github.com/Spok95/beauty-bot/internal/domain/consumption"
+)
+
+// Summary — сводные показатели по сессиям за период (раздел "Аналитика").
+type Summary struct {
+	Sessions int
+	Masters  int
+
+	Rent      float64 // аренда к оплате
+	Materials float64 // материалы
+	SubRent   float64 // использовано по абонементам: часы/дни по тарифу абонемента (оплачены при покупке)
+	SubSold   int     // продано абонементов; Analyze видит только сессии — заполняет вызывающий
+	SubSales  float64 // выручка от продажи абонементов за период
+	Discounts float64
+	Total     float64 // к оплате: аренда + материалы − скидки
+
+	Usage     []PlaceUsage    // загрузка помещений
+	TopByQty  []MaterialUsage // топ материалов по количеству
+	TopByCost []MaterialUsage // топ материалов по сумме
+	Spend     []MasterSpend   // расход материалов на час/день аренды по мастерам
+
+	ThresholdSessions int // сессии аренды, для которых считается порог материалов
+	ThresholdMet      int // из них порог выполнен по всем частям
+}
+
+// PlaceUsage — сколько часов/дней помещения занято за период.
+type PlaceUsage struct {
+	Place    string
+	Unit     string
+	Sessions int
+	Qty      int
+	SubQty   int // из них по абонементу
+}
+
+// MaterialUsage — расход материала за период.
+type MaterialUsage struct {
+	BrandName    string
+	MaterialName string
+	Unit         string
+	Qty          float64
+	Cost         float64
+}
+
+// MasterSpend — средний расход материалов мастера на час/день аренды.
+type MasterSpend struct {
+	UserID        int64
+	Username      string
+	Hours         int
+	HourMaterials float64 // материалы в сессиях с почасовой арендой
+	Days          int
+	DayMaterials  float64 // материалы в сессиях с посуточной арендой
+}
+
+// PerHour — материалы на час аренды (0, если часов не было).
+func (m MasterSpend) PerHour() float64 {
+	if m.Hours == 0 {
+		return 0
+	}
+	return m.HourMaterials / float64(m.Hours)
+}
+
+// PerDay — материалы на день аренды (0, если дней не было).
+func (m MasterSpend) PerDay() float64 {
+	if m.Days == 0 {
+		return 0
+	}
+	return m.DayMaterials / float64(m.Days)
+}
+
+// ThresholdShare — доля сессий аренды с выполненным порогом, %.
+func (a Summary) ThresholdShare() float64 {
+	if a.ThresholdSessions == 0 {
+		return 0
+	}
+	return float64(a.ThresholdMet) * 100 / float64(a.ThresholdSessions)
+}
+
+// UsageQty — часы/дни помещения за период.
+func (a Summary) UsageQty(place, unit string) int {
+	for _, u := range a.Usage {
+		if u.Place == place && u.Unit == unit {
+			return u.Qty
+		}
+	}
+	return 0
+}
+
+// Analyze считает показатели по сессиям отчёта; top — размер топа материалов.
+func Analyze(sessions []consumption.MasterSessionReport, top int) Summary {
+	var a Summary
+	usage := map[[2]string]*PlaceUsage{}
+	materials := map[[2]string]*MaterialUsage{}
+	masters := map[int64]*MasterSpend{}
+	var masterOrder []int64
+
+	for _, s := range sessions {
+		a.Sessions++
+		a.Rent += s.Rent
+		a.Materials += s.MaterialsSum
+		a.Discounts += s.Discount
+		a.Total += s.Total
+
+		m, ok := masters[s.UserID]
+		if !ok {
+			m = &MasterSpend{UserID: s.UserID, Username: s.Username}
+			masters[s.UserID] = m
+			masterOrder = append(masterOrder, s.UserID)
+		}
+
+		rented := !s.StudioClient && s.Place != "no_rent" && s.Unit != "none" && s.Qty > 0
+		if rented {
+			key := [2]string{s.Place, s.Unit}
+			u, ok := usage[key]
+			if !ok {
+				u = &PlaceUsage{Place: s.Place, Unit: s.Unit}
+				usage[key] = u
+			}
+			u.Sessions++
+			u.Qty += s.Qty
+
+			switch s.Unit {
+			case "hour":
+				m.Hours += s.Qty
+				m.HourMaterials += s.MaterialsSum
+			case "day":
+				m.Days += s.Qty
+				m.DayMaterials += s.MaterialsSum
+			}
+
+			met := len(s.RentParts) > 0
+			for _, p := range s.RentParts {
+				if p.WithSub {
+					u.SubQty += p.Qty
+					a.SubRent += p.Rent
+				}
+				met = met && p.ThresholdMet
+			}
+			if len(s.RentParts) > 0 {
+				a.ThresholdSessions++
+				if met {
+					a.ThresholdMet++
+				}
+			}
+		}
+
+		for _, it := range s.Items {
+			key := [2]string{it.BrandName, it.MaterialName}
+			mu, ok := materials[key]
+			if !ok {
+				mu = &MaterialUsage{BrandName: it.BrandName, MaterialName: it.MaterialName, Unit: it.MaterialUnit}
+				materials[key] = mu
+			}
+			mu.Qty += it.Qty
+			mu.Cost += it.Cost
+		}
+	}
+
+	a.Masters = len(masterOrder)
+	for _, id := range masterOrder {
+		a.Spend = append(a.Spend, *masters[id])
+	}
+	sort.SliceStable(a.Spend, func(i, j int) bool { return a.Spend[i].PerHour() > a.Spend[j].PerHour() })
+
+	for _, u := range usage {
+		a.Usage = append(a.Usage, *u)
+	}
+	sort.Slice(a.Usage, func(i, j int) bool {
+		if a.Usage[i].Place != a.Usage[j].Place {
+			return a.Usage[i].Place > a.Usage[j].Place // hall, cabinet
+		}
+		return a.Usage[i].Unit > a.Usage[j].Unit
+	})
+
+	all := make([]MaterialUsage, 0, len(materials))
+	for _, mu := range materials {
+		all = append(all, *mu)
+	}
+	a.TopByQty = topMaterials(all, top, func(x, y MaterialUsage) bool { return x.Qty > y.Qty })
+	a.TopByCost = topMaterials(all, top, func(x, y MaterialUsage) bool { return x.Cost > y.Cost })
+	return a
+}
+
+func topMaterials(all []MaterialUsage, top int, less func(x, y MaterialUsage) bool) []MaterialUsage {
+	list := append([]MaterialUsage(nil), all...)
+	sort.Slice(list, func(i, j int) bool {
+		if less(list[i], list[j]) != less(list[j], list[i]) {
+			return less(list[i], list[j])
+		}
+		return list[i].MaterialName < list[j].MaterialName
+	})
+	if top > 0 && len(list) > top {
+		list = list[:top]
+	}
+	return list
+}
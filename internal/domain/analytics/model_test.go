@@ -0,0 +1,106 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/Spok95/beauty-bot/internal/domain/consumption"
+)
+
+func session(userID int64, place, unit string, qty int, mats float64, parts ...consumption.ReportRentPart) consumption.MasterSessionReport {
+	return consumption.MasterSessionReport{UserID: userID, Place: place, Unit: unit, Qty: qty, MaterialsSum: mats, RentParts: parts}
+}
+
+func part(qty int, met bool) consumption.ReportRentPart {
+	return consumption.ReportRentPart{Qty: qty, ThresholdMet: met}
+}
+
+func TestThresholdShare(t *testing.T) {
+	tests := []struct {
+		name             string
+		sessions         []consumption.MasterSessionReport
+		wantTotal        int
+		wantMet          int
+		wantShare        float64
+		wantUsageHallHrs int
+	}{
+		{
+			name: "нет сессий",
+		},
+		{
+			name: "половина с порогом",
+			sessions: []consumption.MasterSessionReport{
+				session(1, "hall", "hour", 2, 500, part(2, true)),
+				session(1, "hall", "hour", 3, 100, part(3, false)),
+			},
+			wantTotal: 2, wantMet: 1, wantShare: 50, wantUsageHallHrs: 5,
+		},
+		{
+			name: "порог не выполнен в одной из частей",
+			sessions: []consumption.MasterSessionReport{
+				session(1, "hall", "hour", 4, 800, part(2, true), part(2, false)),
+				session(2, "hall", "hour", 1, 300, part(1, true)),
+				session(2, "hall", "hour", 1, 300, part(1, true)),
+			},
+			wantTotal: 3, wantMet: 2, wantShare: 200.0 / 3, wantUsageHallHrs: 6,
+		},
+		{
+			name: "без частей аренды и без аренды порог не считается",
+			sessions: []consumption.MasterSessionReport{
+				session(1, "hall", "hour", 2, 500),
+				session(1, "no_rent", "none", 0, 200),
+				{UserID: 2, Place: "cabinet", Unit: "day", Qty: 1, StudioClient: true, RentParts: []consumption.ReportRentPart{part(1, true)}},
+			},
+			wantUsageHallHrs: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Analyze(tt.sessions, 0)
+			if a.ThresholdSessions != tt.wantTotal || a.ThresholdMet != tt.wantMet {
+				t.Errorf("threshold = %d/%d, want %d/%d", a.ThresholdMet, a.ThresholdSessions, tt.wantMet, tt.wantTotal)
+			}
+			if got := a.ThresholdShare(); got != tt.wantShare {
+				t.Errorf("ThresholdShare() = %v, want %v", got, tt.wantShare)
+			}
+			if got := a.UsageQty("hall", "hour"); got != tt.wantUsageHallHrs {
+				t.Errorf("UsageQty(hall, hour) = %d, want %d", got, tt.wantUsageHallHrs)
+			}
+		})
+	}
+}
+
+func TestMasterSpend(t *testing.T) {
+	sessions := []consumption.MasterSessionReport{
+		session(1, "hall", "hour", 2, 300),
+		session(1, "hall", "hour", 4, 600),
+		session(1, "cabinet", "day", 1, 1000),
+		session(2, "hall", "day", 2, 500),
+		session(3, "no_rent", "none", 0, 700),
+	}
+	want := map[int64]struct{ perHour, perDay float64 }{
+		1: {perHour: 150, perDay: 1000},
+		2: {perDay: 250},
+		3: {},
+	}
+
+	a := Analyze(sessions, 0)
+	if a.Masters != len(want) {
+		t.Fatalf("Masters = %d, want %d", a.Masters, len(want))
+	}
+	for _, m := range a.Spend {
+		w, ok := want[m.UserID]
+		if !ok {
+			t.Errorf("unexpected master %d", m.UserID)
+			continue
+		}
+		if got := m.PerHour(); got != w.perHour {
+			t.Errorf("master %d: PerHour() = %v, want %v", m.UserID, got, w.perHour)
+		}
+		if got := m.PerDay(); got != w.perDay {
+			t.Errorf("master %d: PerDay() = %v, want %v", m.UserID, got, w.perDay)
+		}
+	}
+	if a.Spend[0].UserID != 1 {
+		t.Errorf("Spend[0] = master %d, want the highest per-hour spend first", a.Spend[0].UserID)
+	}
+}
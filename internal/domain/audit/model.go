@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Source — откуда пришло изменение.
+type Source string
+
+const (
+	SourceBot    Source = "bot"    // кнопки и ввод в боте
+	SourceExcel  Source = "excel"  // загрузка Excel-файла
+	SourceAPI    Source = "api"    // HTTP-обработчики (оплата и т.п.)
+	SourceSystem Source = "system" // запуск, миграции данных, фоновые задачи
+)
+
+// Entity — тип изменяемого объекта.
+type Entity string
+
+const (
+	EntityWarehouse      Entity = "warehouse" // вместе со списком привязанных категорий
+	EntityCategory       Entity = "category"
+	EntityBrand          Entity = "brand"    // вместе с алиасами
+	EntityMaterial       Entity = "material" // вместе со штрихкодами и запланированными ценами
+	EntityRentRate       Entity = "rent_rate"
+	EntityTariffVersion  Entity = "tariff_version" // вместе со ступенями
+	EntitySubscription   Entity = "subscription"
+	EntityInvoice        Entity = "invoice"
+	EntityTemplate       Entity = "template" // вместе с позициями
+	EntityUser           Entity = "user"     // вместе с ролями и профилем
+	EntityInvite         Entity = "invite"
+	EntityDiscount       Entity = "discount"
+	EntityReportSchedule Entity = "report_schedule"
+	EntityBroadcast      Entity = "broadcast"
+	EntitySession        Entity = "session" // сессия расхода/аренды вместе с применёнными скидками
+)
+
+// Action — вид изменения.
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+// Event — запись журнала изменений.
+type Event struct {
+	ID        int64
+	CreatedAt time.Time
+	ActorID   int64  // 0 — система или внешний вызов
+	ActorName string // для отображения
+	Source    Source
+	Entity    Entity
+	EntityID  int64
+	Action    Action
+	Before    json.RawMessage // nil для создания
+	After     json.RawMessage // nil для удаления
+}
+
+// Filter — отбор событий; нулевые поля не ограничивают выборку.
+type Filter struct {
+	Entities []Entity
+	EntityID int64
+	From     time.Time
+	To       time.Time // не включительно
+}
+
+type actorKey struct{}
+type sourceKey struct{}
+
+// WithActor запоминает в контексте пользователя, от имени которого идут изменения.
+func WithActor(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, actorKey{}, userID)
+}
+
+// WithSource задаёт источник изменений для всех вызовов с этим контекстом.
+func WithSource(ctx context.Context, s Source) context.Context {
+	return context.WithValue(ctx, sourceKey{}, s)
+}
+
+// ActorFrom — пользователь и источник из контекста (по умолчанию — система).
+func ActorFrom(ctx context.Context) (int64, Source) {
+	id, _ := ctx.Value(actorKey{}).(int64)
+	src, ok := ctx.Value(sourceKey{}).(Source)
+	if !ok {
+		src = SourceSystem
+	}
+	return id, src
+}
@@ -0,0 +1,282 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DB — то, через что пишут репозитории: пул или транзакция.
+type DB interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// snapshots — как получить состояние объекта в JSON. Связанные таблицы (роли, штрихкоды,
+// позиции шаблона…) входят в снимок родителя, поэтому их изменения видны как update родителя.
+var snapshots = map[Entity]string{
+	EntityWarehouse: `
+		SELECT to_jsonb(t) || jsonb_build_object('categories', COALESCE((
+			SELECT jsonb_agg(c.name ORDER BY c.name)
+			FROM warehouse_material_categories wmc
+			JOIN material_categories c ON c.id = wmc.category_id
+			WHERE wmc.warehouse_id = t.id), '[]'::jsonb))
+		FROM warehouses t WHERE t.id = $1`,
+	EntityCategory: `SELECT to_jsonb(t) FROM material_categories t WHERE t.id = $1`,
+	EntityBrand: `
+		SELECT to_jsonb(t) || jsonb_build_object('aliases', COALESCE((
+			SELECT jsonb_agg(a.alias ORDER BY a.alias)
+			FROM material_brand_aliases a WHERE a.brand_id = t.id), '[]'::jsonb))
+		FROM material_brands t WHERE t.id = $1`,
+	EntityMaterial: `
+		SELECT to_jsonb(t)
+			|| jsonb_build_object('barcodes', COALESCE((
+				SELECT jsonb_agg(b.code ORDER BY b.code)
+				FROM material_barcodes b WHERE b.material_id = t.id), '[]'::jsonb))
+			|| jsonb_build_object('scheduled_prices', COALESCE((
+				SELECT jsonb_agg(jsonb_build_object('price', p.price, 'valid_from', p.valid_from) ORDER BY p.valid_from)
+				FROM material_prices p WHERE p.material_id = t.id AND p.valid_from > now()), '[]'::jsonb))
+		FROM materials t WHERE t.id = $1`,
+	EntityRentRate: `SELECT to_jsonb(t) FROM rent_rates t WHERE t.id = $1`,
+	EntityTariffVersion: `
+		SELECT to_jsonb(t) || jsonb_build_object('rates', COALESCE((
+			SELECT jsonb_agg(to_jsonb(r) - 'version_id' - 'id' ORDER BY r.place, r.unit, r.with_subscription, r.min_qty)
+			FROM rent_rates r WHERE r.version_id = t.id), '[]'::jsonb))
+		FROM rent_tariff_versions t WHERE t.id = $1`,
+	EntitySubscription: `SELECT to_jsonb(t) FROM subscriptions t WHERE t.id = $1`,
+	EntityInvoice:      `SELECT to_jsonb(t) FROM invoices t WHERE t.id = $1`,
+	EntityTemplate: `
+		SELECT to_jsonb(t) || jsonb_build_object('items', COALESCE((
+			SELECT jsonb_agg(jsonb_build_object('material_id', i.material_id, 'qty', i.qty) ORDER BY i.material_id)
+			FROM material_template_items i WHERE i.template_id = t.id), '[]'::jsonb))
+		FROM material_templates t WHERE t.id = $1`,
+	EntityUser: `
+		SELECT to_jsonb(t)
+			|| jsonb_build_object('roles', COALESCE((
+				SELECT jsonb_agg(r.role ORDER BY r.role) FROM user_roles r WHERE r.user_id = t.id), '[]'::jsonb))
+			|| jsonb_build_object('profile', (
+				SELECT to_jsonb(p) - 'user_id' - 'updated_at' FROM user_profiles p WHERE p.user_id = t.id))
+		FROM users t WHERE t.id = $1`,
+	EntityInvite:         `SELECT to_jsonb(t) FROM invites t WHERE t.id = $1`,
+	EntityDiscount:       `SELECT to_jsonb(t) FROM discounts t WHERE t.id = $1`,
+	EntityReportSchedule: `SELECT to_jsonb(t) FROM report_schedules t WHERE t.id = $1`,
+	EntityBroadcast:      `SELECT to_jsonb(t) FROM broadcasts t WHERE t.id = $1`,
+	EntitySession: `
+		SELECT to_jsonb(t) || jsonb_build_object('discounts', COALESCE((
+			SELECT jsonb_agg(jsonb_build_object('title', d.title, 'target', d.target, 'amount', d.amount) ORDER BY d.id)
+			FROM consumption_discounts d WHERE d.session_id = t.id), '[]'::jsonb))
+		FROM consumption_sessions t WHERE t.id = $1`,
+}
+
+// Snapshot — текущее состояние объекта в JSON (nil, если объекта нет).
+func Snapshot(ctx context.Context, db DB, entity Entity, id int64) (json.RawMessage, error) {
+	q, ok := snapshots[entity]
+	if !ok {
+		return nil, fmt.Errorf("audit: unknown entity %q", entity)
+	}
+	var raw []byte
+	err := db.QueryRow(ctx, q, id).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// Record пишет событие; вид действия определяется по наличию снимков до и после.
+// Изменение без разницы в снимках не записывается. Автор и источник берутся из ctx.
+func Record(ctx context.Context, db DB, entity Entity, id int64, before, after json.RawMessage) error {
+	if before == nil && after == nil {
+		return nil
+	}
+	action := ActionUpdate
+	switch {
+	case before == nil:
+		action = ActionCreate
+	case after == nil:
+		action = ActionDelete
+	case bytes.Equal(before, after):
+		return nil
+	}
+
+	actorID, source := ActorFrom(ctx)
+	var actor any
+	if actorID > 0 {
+		actor = actorID
+	}
+	_, err := db.Exec(ctx, `
+		INSERT INTO audit_events (actor_id, source, entity, entity_id, action, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, actor, source, entity, id, action, nullJSON(before), nullJSON(after))
+	return err
+}
+
+func nullJSON(raw json.RawMessage) any {
+	if raw == nil {
+		return nil
+	}
+	return []byte(raw)
+}
+
+// TrackTx снимает состояние объекта до и после fn внутри уже открытой транзакции.
+func TrackTx(ctx context.Context, tx pgx.Tx, entity Entity, id int64, fn func() error) error {
+	before, err := Snapshot(ctx, tx, entity, id)
+	if err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	after, err := Snapshot(ctx, tx, entity, id)
+	if err != nil {
+		return err
+	}
+	return Record(ctx, tx, entity, id, before, after)
+}
+
+// Track выполняет изменение объекта в транзакции и записывает его в журнал.
+func Track(ctx context.Context, pool *pgxpool.Pool, entity Entity, id int64, fn func(tx pgx.Tx) error) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := TrackTx(ctx, tx, entity, id, func() error { return fn(tx) }); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// TrackCreate — то же для создания: fn возвращает id нового объекта (0 — ничего не создано).
+func TrackCreate(ctx context.Context, pool *pgxpool.Pool, entity Entity, fn func(tx pgx.Tx) (int64, error)) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	id, err := fn(tx)
+	if err != nil {
+		return err
+	}
+	if id > 0 {
+		after, err := Snapshot(ctx, tx, entity, id)
+		if err != nil {
+			return err
+		}
+		if err := Record(ctx, tx, entity, id, nil, after); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+type Repo struct{ pool *pgxpool.Pool }
+
+func NewRepo(pool *pgxpool.Pool) *Repo { return &Repo{pool: pool} }
+
+const eventColumns = `
+	e.id, e.created_at, COALESCE(e.actor_id, 0), COALESCE(u.username, ''), e.source,
+	e.entity, e.entity_id, e.action, e.before, e.after`
+
+func scanEvent(row pgx.Row) (*Event, error) {
+	var e Event
+	var before, after []byte
+	if err := row.Scan(&e.ID, &e.CreatedAt, &e.ActorID, &e.ActorName, &e.Source,
+		&e.Entity, &e.EntityID, &e.Action, &before, &after); err != nil {
+		return nil, err
+	}
+	if before != nil {
+		e.Before = before
+	}
+	if after != nil {
+		e.After = after
+	}
+	return &e, nil
+}
+
+func (f Filter) where() (string, []any) {
+	var conds []string
+	var args []any
+	add := func(cond string, v any) {
+		args = append(args, v)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if len(f.Entities) > 0 {
+		list := make([]string, 0, len(f.Entities))
+		for _, e := range f.Entities {
+			list = append(list, string(e))
+		}
+		add("e.entity = ANY($%d)", list)
+	}
+	if f.EntityID > 0 {
+		add("e.entity_id = $%d", f.EntityID)
+	}
+	if !f.From.IsZero() {
+		add("e.created_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("e.created_at < $%d", f.To)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
+
+// List — события по фильтру, новые сверху; limit = 0 — без ограничения. Возвращает и общее число.
+func (r *Repo) List(ctx context.Context, f Filter, limit, offset int) ([]Event, int, error) {
+	where, args := f.where()
+
+	var total int
+	if err := r.pool.QueryRow(ctx, `SELECT COUNT(*) FROM audit_events e `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	q := `SELECT` + eventColumns + `
+		FROM audit_events e
+		LEFT JOIN users u ON u.id = e.actor_id
+		` + where + `
+		ORDER BY e.created_at DESC, e.id DESC`
+	if limit > 0 {
+		q += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+	}
+
+	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var out []Event
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, *e)
+	}
+	return out, total, rows.Err()
+}
+
+// GetByID — событие журнала (nil, если нет).
+func (r *Repo) GetByID(ctx context.Context, id int64) (*Event, error) {
+	e, err := scanEvent(r.pool.QueryRow(ctx, `SELECT`+eventColumns+`
+		FROM audit_events e
+		LEFT JOIN users u ON u.id = e.actor_id
+		WHERE e.id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return e, err
+}
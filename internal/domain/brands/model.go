@@ -9,3 +9,9 @@ type Brand struct {
 	Active     bool
 	CreatedAt  time.Time
 }
+
+// Alias — синоним бренда для поиска; ID — строка material_brand_aliases.
+type Alias struct {
+	ID    int64
+	Alias string
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"strings"
 
+	"github.com/Spok95/beauty-bot/internal/domain/audit"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -50,12 +51,15 @@ func (r *Repo) GetOrCreate(ctx context.Context, categoryID int64, name string) (
 	}
 
 	// создаём
-	row = r.pool.QueryRow(ctx, `
-		INSERT INTO material_brands (category_id, name, active)
-		VALUES ($1, $2, TRUE)
-		RETURNING id, category_id, name, active, created_at
-	`, categoryID, name)
-	if err := row.Scan(&b.ID, &b.CategoryID, &b.Name, &b.Active, &b.CreatedAt); err != nil {
+	err = audit.TrackCreate(ctx, r.pool, audit.EntityBrand, func(tx pgx.Tx) (int64, error) {
+		err := tx.QueryRow(ctx, `
+			INSERT INTO material_brands (category_id, name, active)
+			VALUES ($1, $2, TRUE)
+			RETURNING id, category_id, name, active, created_at
+		`, categoryID, name).Scan(&b.ID, &b.CategoryID, &b.Name, &b.Active, &b.CreatedAt)
+		return b.ID, err
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &b, nil
@@ -98,10 +102,12 @@ func (r *Repo) ListByCategory(ctx context.Context, categoryID int64, onlyActive
 }
 
 func (r *Repo) SetActive(ctx context.Context, id int64, active bool) error {
-	_, err := r.pool.Exec(ctx, `
-		UPDATE material_brands SET active = $2 WHERE id = $1
-	`, id, active)
-	return err
+	return audit.Track(ctx, r.pool, audit.EntityBrand, id, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			UPDATE material_brands SET active = $2 WHERE id = $1
+		`, id, active)
+		return err
+	})
 }
 
 func (r *Repo) Rename(ctx context.Context, id int64, newName string) error {
@@ -109,8 +115,60 @@ func (r *Repo) Rename(ctx context.Context, id int64, newName string) error {
 	if newName == "" {
 		return nil
 	}
-	_, err := r.pool.Exec(ctx, `
-		UPDATE material_brands SET name = $2 WHERE id = $1
-	`, id, newName)
-	return err
+	return audit.Track(ctx, r.pool, audit.EntityBrand, id, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			UPDATE material_brands SET name = $2 WHERE id = $1
+		`, id, newName)
+		return err
+	})
+}
+
+// ListAliases — синонимы бренда для поиска (например, «лореаль» для L'Oreal).
+func (r *Repo) ListAliases(ctx context.Context, brandID int64) ([]Alias, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, alias FROM material_brand_aliases WHERE brand_id = $1 ORDER BY id
+	`, brandID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Alias
+	for rows.Next() {
+		var a Alias
+		if err := rows.Scan(&a.ID, &a.Alias); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// AddAlias добавляет синоним бренда; повтор не считается ошибкой.
+func (r *Repo) AddAlias(ctx context.Context, brandID int64, alias string) error {
+	alias = strings.TrimSpace(alias)
+	if alias == "" {
+		return nil
+	}
+	return audit.Track(ctx, r.pool, audit.EntityBrand, brandID, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO material_brand_aliases (brand_id, alias)
+			VALUES ($1, $2)
+			ON CONFLICT (brand_id, alias) DO NOTHING
+		`, brandID, alias)
+		return err
+	})
+}
+
+// DeleteAlias удаляет синоним (строку material_brand_aliases) бренда; false — его уже нет.
+func (r *Repo) DeleteAlias(ctx context.Context, brandID, aliasID int64) (bool, error) {
+	var deleted bool
+	err := audit.Track(ctx, r.pool, audit.EntityBrand, brandID, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+			DELETE FROM material_brand_aliases WHERE id = $1 AND brand_id = $2
+		`, aliasID, brandID)
+		deleted = tag.RowsAffected() > 0
+		return err
+	})
+	return deleted, err
 }
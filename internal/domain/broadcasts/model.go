@@ -0,0 +1,87 @@
+package broadcasts
+
+import "time"
+
+type Status string
+
+const (
+	StatusScheduled Status = "scheduled" // ждёт времени отправки
+	StatusSending   Status = "sending"   // получатели зафиксированы, идёт отправка
+	StatusDone      Status = "done"
+	StatusCanceled  Status = "canceled"
+)
+
+type MediaType string
+
+const (
+	MediaNone     MediaType = ""
+	MediaPhoto    MediaType = "photo"
+	MediaDocument MediaType = "document"
+)
+
+// DeliveryStatus — результат отправки одному получателю.
+type DeliveryStatus string
+
+const (
+	DeliveryPending DeliveryStatus = "pending"
+	DeliverySent    DeliveryStatus = "sent"
+	DeliveryBlocked DeliveryStatus = "blocked" // пользователь заблокировал бота
+	DeliveryFailed  DeliveryStatus = "failed"
+)
+
+// Отбор по абонементу в текущем месяце.
+const (
+	SubsAny    = ""
+	SubsActive = "active" // есть абонемент с остатком
+	SubsNone   = "none"   // нет абонемента с остатком
+)
+
+// Segment — кому отправлять. Пустое поле — без ограничения.
+type Segment struct {
+	Roles       []string `json:"roles,omitempty"`        // хотя бы одна из ролей
+	Place       string   `json:"place,omitempty"`        // помещение по умолчанию в анкете: hall|cabinet
+	WarehouseID int64    `json:"warehouse_id,omitempty"` // склад по умолчанию в анкете
+	Subs        string   `json:"subs,omitempty"`         // SubsAny|SubsActive|SubsNone
+}
+
+// Broadcast — рассылка.
+type Broadcast struct {
+	ID          int64
+	AuthorID    int64
+	Text        string
+	MediaType   MediaType
+	MediaFileID string
+	Segment     Segment
+	Status      Status
+	ScheduledAt time.Time
+	StartedAt   *time.Time
+	FinishedAt  *time.Time
+	CreatedAt   time.Time
+
+	Stats Stats
+}
+
+// Stats — счётчики доставки рассылки.
+type Stats struct {
+	Total   int
+	Pending int
+	Sent    int
+	Blocked int
+	Failed  int
+}
+
+// Recipient — получатель рассылки.
+type Recipient struct {
+	UserID     int64
+	TelegramID int64
+}
+
+// Delivery — доставка одному получателю (для просмотра недоставленных).
+type Delivery struct {
+	UserID     int64
+	TelegramID int64
+	Name       string
+	Status     DeliveryStatus
+	Error      string
+	SentAt     *time.Time
+}
@@ -0,0 +1,270 @@
+package broadcasts
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/Spok95/beauty-bot/internal/domain/audit"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repo struct{ pool *pgxpool.Pool }
+
+func NewRepo(pool *pgxpool.Pool) *Repo { return &Repo{pool: pool} }
+
+// recipientsSelect — подтверждённые пользователи, подходящие под сегмент.
+// Параметры: $1 роли, $2 помещение, $3 склад, $4 отбор по абонементу, $5 месяц "YYYY-MM".
+const recipientsSelect = `
+SELECT u.id, u.telegram_id
+FROM users AS u
+LEFT JOIN user_profiles AS p ON p.user_id = u.id
+WHERE u.status = 'approved'
+  AND u.telegram_id <> 0
+  AND (cardinality($1::text[]) = 0
+       OR EXISTS (SELECT 1 FROM user_roles AS r WHERE r.user_id = u.id AND r.role = ANY($1::text[])))
+  AND ($2::text = '' OR p.default_place = $2::text)
+  AND ($3::bigint = 0 OR p.default_warehouse_id = $3::bigint)
+  AND ($4::text = '' OR ($4::text = 'active') = EXISTS (
+        SELECT 1 FROM subscriptions AS s
+        WHERE s.user_id = u.id AND s.month = $5::text AND s.used_qty < s.total_qty))
+ORDER BY u.id
+`
+
+// querier — пул или транзакция.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+func listRecipients(ctx context.Context, q querier, seg Segment, month string) ([]Recipient, error) {
+	roles := seg.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	rows, err := q.Query(ctx, recipientsSelect, roles, seg.Place, seg.WarehouseID, seg.Subs, month)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Recipient
+	for rows.Next() {
+		var rc Recipient
+		if err := rows.Scan(&rc.UserID, &rc.TelegramID); err != nil {
+			return nil, err
+		}
+		out = append(out, rc)
+	}
+	return out, rows.Err()
+}
+
+// Recipients — получатели сегмента на текущий момент (для предпросмотра).
+func (r *Repo) Recipients(ctx context.Context, seg Segment, month string) ([]Recipient, error) {
+	return listRecipients(ctx, r.pool, seg, month)
+}
+
+// Create сохраняет запланированную рассылку.
+func (r *Repo) Create(ctx context.Context, b *Broadcast) (int64, error) {
+	seg, err := json.Marshal(b.Segment)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = audit.TrackCreate(ctx, r.pool, audit.EntityBroadcast, func(tx pgx.Tx) (int64, error) {
+		err := tx.QueryRow(ctx, `
+INSERT INTO broadcasts (author_id, text, media_type, media_file_id, segment, status, scheduled_at)
+VALUES ($1, $2, $3, $4, $5, 'scheduled', $6)
+RETURNING id
+`, b.AuthorID, b.Text, b.MediaType, b.MediaFileID, seg, b.ScheduledAt).Scan(&id)
+		return id, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+const broadcastColumns = `
+    b.id, b.author_id, b.text, b.media_type, b.media_file_id, b.segment, b.status,
+    b.scheduled_at, b.started_at, b.finished_at, b.created_at,
+    COUNT(d.user_id),
+    COUNT(d.user_id) FILTER (WHERE d.status = 'pending'),
+    COUNT(d.user_id) FILTER (WHERE d.status = 'sent'),
+    COUNT(d.user_id) FILTER (WHERE d.status = 'blocked'),
+    COUNT(d.user_id) FILTER (WHERE d.status = 'failed')
+FROM broadcasts AS b
+LEFT JOIN broadcast_deliveries AS d ON d.broadcast_id = b.id
+`
+
+func scanBroadcasts(rows pgx.Rows) ([]Broadcast, error) {
+	defer rows.Close()
+	var out []Broadcast
+	for rows.Next() {
+		var b Broadcast
+		var seg []byte
+		if err := rows.Scan(
+			&b.ID, &b.AuthorID, &b.Text, &b.MediaType, &b.MediaFileID, &seg, &b.Status,
+			&b.ScheduledAt, &b.StartedAt, &b.FinishedAt, &b.CreatedAt,
+			&b.Stats.Total, &b.Stats.Pending, &b.Stats.Sent, &b.Stats.Blocked, &b.Stats.Failed,
+		); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(seg, &b.Segment); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// Get — рассылка со счётчиками доставки; nil — не найдена.
+func (r *Repo) Get(ctx context.Context, id int64) (*Broadcast, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+broadcastColumns+` WHERE b.id = $1 GROUP BY b.id`, id)
+	if err != nil {
+		return nil, err
+	}
+	list, err := scanBroadcasts(rows)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// ListRecent — последние рассылки, новые сверху.
+func (r *Repo) ListRecent(ctx context.Context, limit int) ([]Broadcast, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+broadcastColumns+` GROUP BY b.id ORDER BY b.id DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanBroadcasts(rows)
+}
+
+// ListRunnable — рассылки, которые пора запускать, и прерванные (например, перезапуском бота) в процессе отправки.
+func (r *Repo) ListRunnable(ctx context.Context) ([]Broadcast, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+broadcastColumns+`
+WHERE b.status = 'sending' OR (b.status = 'scheduled' AND b.scheduled_at <= now())
+GROUP BY b.id
+ORDER BY b.scheduled_at, b.id`)
+	if err != nil {
+		return nil, err
+	}
+	return scanBroadcasts(rows)
+}
+
+// Cancel отменяет рассылку, которая ещё не начала отправляться; false — уже поздно.
+func (r *Repo) Cancel(ctx context.Context, id int64) (bool, error) {
+	var canceled bool
+	err := audit.Track(ctx, r.pool, audit.EntityBroadcast, id, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx,
+			`UPDATE broadcasts SET status = 'canceled', finished_at = now() WHERE id = $1 AND status = 'scheduled'`, id)
+		canceled = tag.RowsAffected() > 0
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return canceled, nil
+}
+
+// Start фиксирует получателей сегмента и переводит рассылку в отправку; возвращает число получателей.
+// Повторный вызов для уже запущенной рассылки ничего не меняет.
+func (r *Repo) Start(ctx context.Context, b *Broadcast, month string) (int, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	tag, err := tx.Exec(ctx,
+		`UPDATE broadcasts SET status = 'sending', started_at = now() WHERE id = $1 AND status = 'scheduled'`, b.ID)
+	if err != nil {
+		return 0, err
+	}
+	if tag.RowsAffected() == 0 {
+		return 0, nil
+	}
+
+	list, err := listRecipients(ctx, tx, b.Segment, month)
+	if err != nil {
+		return 0, err
+	}
+	userIDs := make([]int64, len(list))
+	tgIDs := make([]int64, len(list))
+	for i, rc := range list {
+		userIDs[i], tgIDs[i] = rc.UserID, rc.TelegramID
+	}
+	if _, err := tx.Exec(ctx, `
+INSERT INTO broadcast_deliveries (broadcast_id, user_id, telegram_id)
+SELECT $1, u, t FROM unnest($2::bigint[], $3::bigint[]) AS x(u, t)
+ON CONFLICT DO NOTHING`, b.ID, userIDs, tgIDs); err != nil {
+		return 0, err
+	}
+	return len(list), tx.Commit(ctx)
+}
+
+// PendingDeliveries — очередная порция получателей, которым ещё не отправляли.
+func (r *Repo) PendingDeliveries(ctx context.Context, id int64, limit int) ([]Recipient, error) {
+	rows, err := r.pool.Query(ctx, `
+SELECT user_id, telegram_id
+FROM broadcast_deliveries
+WHERE broadcast_id = $1 AND status = 'pending'
+ORDER BY user_id
+LIMIT $2`, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Recipient
+	for rows.Next() {
+		var rc Recipient
+		if err := rows.Scan(&rc.UserID, &rc.TelegramID); err != nil {
+			return nil, err
+		}
+		out = append(out, rc)
+	}
+	return out, rows.Err()
+}
+
+// MarkDelivery сохраняет результат отправки получателю.
+func (r *Repo) MarkDelivery(ctx context.Context, id, userID int64, status DeliveryStatus, errText string) error {
+	_, err := r.pool.Exec(ctx, `
+UPDATE broadcast_deliveries
+SET status = $3, error = $4, sent_at = now()
+WHERE broadcast_id = $1 AND user_id = $2`, id, userID, status, errText)
+	return err
+}
+
+// Finish завершает рассылку, если неотправленных получателей не осталось.
+func (r *Repo) Finish(ctx context.Context, id int64) error {
+	_, err := r.pool.Exec(ctx, `
+UPDATE broadcasts SET status = 'done', finished_at = now()
+WHERE id = $1 AND status = 'sending'
+  AND NOT EXISTS (SELECT 1 FROM broadcast_deliveries WHERE broadcast_id = $1 AND status = 'pending')`, id)
+	return err
+}
+
+// ListUndelivered — получатели, которым не удалось доставить рассылку.
+func (r *Repo) ListUndelivered(ctx context.Context, id int64) ([]Delivery, error) {
+	rows, err := r.pool.Query(ctx, `
+SELECT d.user_id, d.telegram_id,
+       COALESCE(NULLIF(concat_ws(' ', NULLIF(p.last_name, ''), NULLIF(p.first_name, ''), NULLIF(p.middle_name, '')), ''),
+                u.username, '') AS name,
+       d.status, d.error, d.sent_at
+FROM broadcast_deliveries AS d
+JOIN users AS u ON u.id = d.user_id
+LEFT JOIN user_profiles AS p ON p.user_id = d.user_id
+WHERE d.broadcast_id = $1 AND d.status IN ('blocked', 'failed')
+ORDER BY d.status, name`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Delivery
+	for rows.Next() {
+		var d Delivery
+		if err := rows.Scan(&d.UserID, &d.TelegramID, &d.Name, &d.Status, &d.Error, &d.SentAt); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
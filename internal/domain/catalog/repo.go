@@ -3,6 +3,7 @@ package catalog
 import (
 	"context"
 
+	"github.com/Spok95/beauty-bot/internal/domain/audit"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -14,13 +15,15 @@ func NewRepo(pool *pgxpool.Pool) *Repo { return &Repo{pool: pool} }
 /* Warehouses */
 
 func (r *Repo) CreateWarehouse(ctx context.Context, name string, t WarehouseType) (*Warehouse, error) {
-	row := r.pool.QueryRow(ctx, `
-		INSERT INTO warehouses (name, type) VALUES ($1,$2)
-		ON CONFLICT (name) DO NOTHING
-		RETURNING id, name, type, active, created_at
-	`, name, string(t))
 	var w Warehouse
-	err := row.Scan(&w.ID, &w.Name, &w.Type, &w.Active, &w.CreatedAt)
+	err := audit.TrackCreate(ctx, r.pool, audit.EntityWarehouse, func(tx pgx.Tx) (int64, error) {
+		err := tx.QueryRow(ctx, `
+			INSERT INTO warehouses (name, type) VALUES ($1,$2)
+			ON CONFLICT (name) DO NOTHING
+			RETURNING id, name, type, active, created_at
+		`, name, string(t)).Scan(&w.ID, &w.Name, &w.Type, &w.Active, &w.CreatedAt)
+		return w.ID, err
+	})
 	if err == pgx.ErrNoRows {
 		// Уже есть — вернём существующий
 		return r.GetWarehouseByName(ctx, name)
@@ -70,13 +73,15 @@ func (r *Repo) ListWarehouses(ctx context.Context) ([]Warehouse, error) {
 /* Categories */
 
 func (r *Repo) CreateCategory(ctx context.Context, name string) (*Category, error) {
-	row := r.pool.QueryRow(ctx, `
-		INSERT INTO material_categories (name) VALUES ($1)
-		ON CONFLICT (name) DO NOTHING
-		RETURNING id, name, active, created_at
-	`, name)
 	var c Category
-	err := row.Scan(&c.ID, &c.Name, &c.Active, &c.CreatedAt)
+	err := audit.TrackCreate(ctx, r.pool, audit.EntityCategory, func(tx pgx.Tx) (int64, error) {
+		err := tx.QueryRow(ctx, `
+			INSERT INTO material_categories (name) VALUES ($1)
+			ON CONFLICT (name) DO NOTHING
+			RETURNING id, name, active, created_at
+		`, name).Scan(&c.ID, &c.Name, &c.Active, &c.CreatedAt)
+		return c.ID, err
+	})
 	if err == pgx.ErrNoRows {
 		// Уже существует
 		return r.GetCategoryByName(ctx, name)
@@ -140,24 +145,28 @@ func (r *Repo) GetWarehouseByID(ctx context.Context, id int64) (*Warehouse, erro
 }
 
 func (r *Repo) UpdateWarehouseName(ctx context.Context, id int64, name string) (*Warehouse, error) {
-	row := r.pool.QueryRow(ctx, `
-		UPDATE warehouses SET name=$2 WHERE id=$1
-		RETURNING id, name, type, active, created_at
-	`, id, name)
 	var w Warehouse
-	if err := row.Scan(&w.ID, &w.Name, &w.Type, &w.Active, &w.CreatedAt); err != nil {
+	err := audit.Track(ctx, r.pool, audit.EntityWarehouse, id, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, `
+			UPDATE warehouses SET name=$2 WHERE id=$1
+			RETURNING id, name, type, active, created_at
+		`, id, name).Scan(&w.ID, &w.Name, &w.Type, &w.Active, &w.CreatedAt)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &w, nil
 }
 
 func (r *Repo) SetWarehouseActive(ctx context.Context, id int64, active bool) (*Warehouse, error) {
-	row := r.pool.QueryRow(ctx, `
-		UPDATE warehouses SET active=$2 WHERE id=$1
-		RETURNING id, name, type, active, created_at
-	`, id, active)
 	var w Warehouse
-	if err := row.Scan(&w.ID, &w.Name, &w.Type, &w.Active, &w.CreatedAt); err != nil {
+	err := audit.Track(ctx, r.pool, audit.EntityWarehouse, id, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, `
+			UPDATE warehouses SET active=$2 WHERE id=$1
+			RETURNING id, name, type, active, created_at
+		`, id, active).Scan(&w.ID, &w.Name, &w.Type, &w.Active, &w.CreatedAt)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &w, nil
@@ -180,24 +189,28 @@ func (r *Repo) GetCategoryByID(ctx context.Context, id int64) (*Category, error)
 }
 
 func (r *Repo) UpdateCategoryName(ctx context.Context, id int64, name string) (*Category, error) {
-	row := r.pool.QueryRow(ctx, `
-		UPDATE material_categories SET name=$2 WHERE id=$1
-		RETURNING id, name, active, created_at
-	`, id, name)
 	var c Category
-	if err := row.Scan(&c.ID, &c.Name, &c.Active, &c.CreatedAt); err != nil {
+	err := audit.Track(ctx, r.pool, audit.EntityCategory, id, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, `
+			UPDATE material_categories SET name=$2 WHERE id=$1
+			RETURNING id, name, active, created_at
+		`, id, name).Scan(&c.ID, &c.Name, &c.Active, &c.CreatedAt)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &c, nil
 }
 
 func (r *Repo) SetCategoryActive(ctx context.Context, id int64, active bool) (*Category, error) {
-	row := r.pool.QueryRow(ctx, `
-		UPDATE material_categories SET active=$2 WHERE id=$1
-		RETURNING id, name, active, created_at
-	`, id, active)
 	var c Category
-	if err := row.Scan(&c.ID, &c.Name, &c.Active, &c.CreatedAt); err != nil {
+	err := audit.Track(ctx, r.pool, audit.EntityCategory, id, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, `
+			UPDATE material_categories SET active=$2 WHERE id=$1
+			RETURNING id, name, active, created_at
+		`, id, active).Scan(&c.ID, &c.Name, &c.Active, &c.CreatedAt)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &c, nil
@@ -281,20 +294,22 @@ func (r *Repo) IsCategoryLinkedToWarehouse(ctx context.Context, warehouseID, cat
 }
 
 func (r *Repo) LinkCategoryToWarehouse(ctx context.Context, warehouseID, categoryID int64) error {
-	_, err := r.pool.Exec(ctx, `
-		INSERT INTO warehouse_material_categories (warehouse_id, category_id)
-		VALUES ($1, $2)
-		ON CONFLICT (warehouse_id, category_id) DO NOTHING
-	`, warehouseID, categoryID)
-
-	return err
+	return audit.Track(ctx, r.pool, audit.EntityWarehouse, warehouseID, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO warehouse_material_categories (warehouse_id, category_id)
+			VALUES ($1, $2)
+			ON CONFLICT (warehouse_id, category_id) DO NOTHING
+		`, warehouseID, categoryID)
+		return err
+	})
 }
 
 func (r *Repo) UnlinkCategoryFromWarehouse(ctx context.Context, warehouseID, categoryID int64) error {
-	_, err := r.pool.Exec(ctx, `
-		DELETE FROM warehouse_material_categories
-		WHERE warehouse_id = $1 AND category_id = $2
-	`, warehouseID, categoryID)
-
-	return err
+	return audit.Track(ctx, r.pool, audit.EntityWarehouse, warehouseID, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			DELETE FROM warehouse_material_categories
+			WHERE warehouse_id = $1 AND category_id = $2
+		`, warehouseID, categoryID)
+		return err
+	})
 }
@@ -1,6 +1,13 @@
 package consumption
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Spok95/beauty-bot/internal/domain/discounts"
+)
 
 type Session struct {
 	ID                  int64
@@ -30,6 +37,7 @@ type Item struct {
 // Ступенчатая ставка
 type TierRate struct {
 	ID        int64
+	VersionID int64
 	Place     string
 	Unit      string
 	WithSub   bool
@@ -41,8 +49,97 @@ type TierRate struct {
 	Active    bool
 }
 
+// Covers — попадает ли количество в диапазон ступени.
+func (t TierRate) Covers(qty int) bool {
+	return t.MinQty <= qty && (t.MaxQty == nil || qty <= *t.MaxQty)
+}
+
+// QtyRange — диапазон количества (часов/дней); Max == nil — без верхней границы.
+type QtyRange struct {
+	Min int
+	Max *int
+}
+
+// Overlaps — пересекаются ли два диапазона.
+func (q QtyRange) Overlaps(o QtyRange) bool {
+	return (q.Max == nil || o.Min <= *q.Max) && (o.Max == nil || q.Min <= *o.Max)
+}
+
+func (q QtyRange) String() string {
+	if q.Max == nil {
+		return fmt.Sprintf("%d–∞", q.Min)
+	}
+	if *q.Max == q.Min {
+		return fmt.Sprintf("%d", q.Min)
+	}
+	return fmt.Sprintf("%d–%d", q.Min, *q.Max)
+}
+
+// TierOverlap — первая активная ступень, кроме skipID, чей диапазон пересекается с r.
+func TierOverlap(rates []TierRate, skipID int64, r QtyRange) *TierRate {
+	for i := range rates {
+		t := rates[i]
+		if !t.Active || t.ID == skipID {
+			continue
+		}
+		if r.Overlaps(QtyRange{Min: t.MinQty, Max: t.MaxQty}) {
+			return &rates[i]
+		}
+	}
+	return nil
+}
+
+// TierGaps — диапазоны количества, для которых среди активных ступеней нет тарифа
+// (начиная с 1 и до бесконечности).
+func TierGaps(rates []TierRate) []QtyRange {
+	active := make([]TierRate, 0, len(rates))
+	for _, t := range rates {
+		if t.Active {
+			active = append(active, t)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].MinQty < active[j].MinQty })
+
+	var gaps []QtyRange
+	next := 1
+	for _, t := range active {
+		if t.MinQty > next {
+			to := t.MinQty - 1
+			gaps = append(gaps, QtyRange{Min: next, Max: &to})
+		}
+		if t.MaxQty == nil {
+			return gaps
+		}
+		if *t.MaxQty+1 > next {
+			next = *t.MaxQty + 1
+		}
+	}
+	return append(gaps, QtyRange{Min: next})
+}
+
+// NewTierGaps — разрывы набора after, которых не было в before: количества, которые
+// останутся без тарифа из-за изменения. Уже существовавшие разрывы не учитываются.
+func NewTierGaps(before, after []TierRate) []QtyRange {
+	old := TierGaps(before)
+	var out []QtyRange
+	for _, g := range TierGaps(after) {
+		covered := false
+		for _, o := range old {
+			if o.Min <= g.Min && (o.Max == nil || (g.Max != nil && *g.Max <= *o.Max)) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
 type RentRate struct {
 	ID         int64
+	VersionID  int64 // версия тарифов, к которой относится ступень
 	Place      string
 	Unit       string
 	WithSub    bool
@@ -56,6 +153,116 @@ type RentRate struct {
 	ActiveTo   *time.Time
 }
 
+// TariffStatus — состояние версии тарифов.
+type TariffStatus string
+
+const (
+	TariffDraft     TariffStatus = "draft"     // черновик: не участвует в расчётах
+	TariffPublished TariffStatus = "published" // действует в период ActiveFrom–ActiveTo
+)
+
+var (
+	ErrTariffNotDraft     = errors.New("версия тарифов уже опубликована")
+	ErrTariffStartInPast  = errors.New("дата начала не может быть в прошлом")
+	ErrTariffStartTooSoon = errors.New("дата начала должна быть позже начала действующей версии")
+	ErrNoTariffVersion    = errors.New("нет действующей версии тарифов")
+	ErrTierOverlap        = errors.New("диапазон пересекается с другой ступенью")
+	ErrRateNotFound       = errors.New("ступень не найдена в действующей версии тарифов")
+	ErrTierGap            = errors.New("после изменения останутся количества без тарифа")
+)
+
+// TariffVersion — набор ступеней аренды, действующий целиком с ActiveFrom до ActiveTo (включительно).
+type TariffVersion struct {
+	ID            int64
+	Status        TariffStatus
+	BaseVersionID int64      // из какой версии сделан черновик; 0 — нет
+	ActiveFrom    *time.Time // nil у черновика
+	ActiveTo      *time.Time // nil — бессрочно
+	Comment       string
+	CreatedBy     int64
+	CreatedAt     time.Time
+	PublishedAt   *time.Time
+}
+
+// ActiveOn — действует ли опубликованная версия в день d.
+func (v TariffVersion) ActiveOn(d time.Time) bool {
+	if v.Status != TariffPublished || v.ActiveFrom == nil {
+		return false
+	}
+	day := time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.UTC)
+	if v.ActiveFrom.After(day) {
+		return false
+	}
+	return v.ActiveTo == nil || !v.ActiveTo.Before(day)
+}
+
+// RateChange — правка ступени черновика; nil — значение не меняется.
+type RateChange struct {
+	RateID    int64
+	Threshold *float64
+	PriceWith *float64
+	PriceOwn  *float64
+}
+
+// RateDiff — различие ступени между версиями: Old == nil — ступень добавлена, New == nil — удалена.
+type RateDiff struct {
+	Old *RentRate
+	New *RentRate
+}
+
+func rateKey(r RentRate) string {
+	maxQty := "inf"
+	if r.MaxQty != nil {
+		maxQty = fmt.Sprint(*r.MaxQty)
+	}
+	return fmt.Sprintf("%s|%s|%v|%d|%s", r.Place, r.Unit, r.WithSub, r.MinQty, maxQty)
+}
+
+func sameRate(a, b RentRate) bool {
+	activeA := a.ActiveTo == nil
+	activeB := b.ActiveTo == nil
+	return a.PerUnit == b.PerUnit && a.Threshold == b.Threshold &&
+		a.PriceWith == b.PriceWith && a.PriceOwn == b.PriceOwn && activeA == activeB
+}
+
+// DiffRates сопоставляет ступени двух версий по place/unit/абонементу/диапазону
+// и возвращает только изменённые, добавленные и удалённые.
+func DiffRates(oldRates, newRates []RentRate) []RateDiff {
+	oldByKey := make(map[string]*RentRate, len(oldRates))
+	for i := range oldRates {
+		oldByKey[rateKey(oldRates[i])] = &oldRates[i]
+	}
+
+	var out []RateDiff
+	seen := map[string]struct{}{}
+	for i := range newRates {
+		n := &newRates[i]
+		k := rateKey(*n)
+		seen[k] = struct{}{}
+		o, ok := oldByKey[k]
+		switch {
+		case !ok:
+			out = append(out, RateDiff{New: n})
+		case !sameRate(*o, *n):
+			out = append(out, RateDiff{Old: o, New: n})
+		}
+	}
+	for i := range oldRates {
+		if _, ok := seen[rateKey(oldRates[i])]; !ok {
+			out = append(out, RateDiff{Old: &oldRates[i]})
+		}
+	}
+	return out
+}
+
+// TariffSimulation — пересчёт прошедших сессий по двум версиям тарифов (только аренда без абонемента).
+type TariffSimulation struct {
+	Sessions int     // пересчитано сессий
+	Skipped  int     // не удалось посчитать по одной из версий (нет подходящей ступени)
+	OldRent  float64 // аренда по старой версии
+	NewRent  float64 // аренда по новой версии
+}
+
 type RentSplitPartInput struct {
 	WithSub            bool // true — часть по абонементу, false — без абонемента
 	Qty                int  // сколько часов/дней в этой части
@@ -75,23 +282,74 @@ type RentSplitPartResult struct {
 	Rate          *RentRate // сам тариф
 }
 
-type MasterMaterialsReportRow struct {
-	UserID    int64
-	Username  string
-	SessionID int64
+// MasterSessionReport — сессия расхода/аренды для отчёта "Аренда и Расходы материалов по мастерам".
+// Позиции материалов — в Items (у аренды без материалов список пуст).
+type MasterSessionReport struct {
+	UserID   int64
+	Username string // ФИО из профиля, иначе users.username
 
+	Phone           string   // из профиля мастера
+	Specializations []string // hair|nails|brows
+	RentTerms       string   // условия аренды из профиля
+
+	SessionID     int64
 	CreatedAt     time.Time
-	Place         string // hall|cabinet
-	Unit          string // hour|day
+	Place         string // hall|cabinet|no_rent
+	Unit          string // hour|day|none
 	Qty           int    // количество часов/дней в сессии
+	StudioClient  bool
 	Comment       string // комментарий из инвойса (дата/примечание сессии)
+	FinalComment  string // комментарий мастера при подтверждении
 	WarehouseID   int64
 	WarehouseName string
 
-	BrandName    string // название бренда материала
+	MaterialsSum        float64
+	RoundedMaterialsSum float64
+	Rent                float64 // аренда к оплате (для студийного клиента — сбор)
+	Discount            float64 // сумма применённых скидок
+	Total               float64
+
+	InvoiceID     int64
+	InvoiceStatus string // ''|pending|paid|canceled
+	PaymentLink   string
+
+	RentParts []ReportRentPart
+	Items     []ReportItem
+}
+
+// ReportRentPart — часть аренды сессии из payload.rent_parts.
+type ReportRentPart struct {
+	WithSub      bool    `json:"with_sub"`
+	Qty          int     `json:"qty"`
+	PlanLimit    int     `json:"plan_limit"`
+	Rent         float64 `json:"rent"`
+	ThresholdMet bool    `json:"threshold_met"`
+}
+
+// ReportItem — позиция материалов сессии.
+type ReportItem struct {
+	BrandName    string
 	MaterialName string
 	MaterialUnit string
-	MaterialQty  float64
+	Qty          float64
 	UnitPrice    float64
 	Cost         float64
 }
+
+// DiscountReportRow — скидка, применённая к сессии (для отчёта по мастерам).
+type DiscountReportRow struct {
+	UserID    int64
+	Username  string // ФИО из профиля, иначе users.username
+	SessionID int64
+	CreatedAt time.Time
+	Title     string
+	Target    discounts.Target
+	Amount    float64
+}
+
+// UserActivity — сводка по сессиям расхода/аренды пользователя за период (для карточки в админке).
+type UserActivity struct {
+	Sessions int        // неотменённых сессий за период
+	Total    float64    // сумма к оплате по ним
+	LastAt   *time.Time // последняя неотменённая сессия за всё время; nil — не было
+}
@@ -0,0 +1,144 @@
+package consumption
+
+import (
+	"reflect"
+	"testing"
+)
+
+func intPtr(n int) *int { return &n }
+
+func tier(id int64, minQty int, maxQty *int, active bool) TierRate {
+	return TierRate{ID: id, MinQty: minQty, MaxQty: maxQty, Active: active}
+}
+
+func TestTierGaps(t *testing.T) {
+	tests := []struct {
+		name  string
+		rates []TierRate
+		want  []QtyRange
+	}{
+		{
+			name: "нет ступеней",
+			want: []QtyRange{{Min: 1}},
+		},
+		{
+			name:  "одна открытая ступень с 1",
+			rates: []TierRate{tier(1, 1, nil, true)},
+		},
+		{
+			name:  "вплотную, без порядка",
+			rates: []TierRate{tier(2, 4, nil, true), tier(1, 1, intPtr(3), true)},
+		},
+		{
+			name:  "разрыв в начале",
+			rates: []TierRate{tier(1, 3, nil, true)},
+			want:  []QtyRange{{Min: 1, Max: intPtr(2)}},
+		},
+		{
+			name:  "разрыв в середине и нет верхней ступени",
+			rates: []TierRate{tier(1, 1, intPtr(3), true), tier(2, 6, intPtr(10), true)},
+			want:  []QtyRange{{Min: 4, Max: intPtr(5)}, {Min: 11}},
+		},
+		{
+			name:  "отключённая ступень не закрывает разрыв",
+			rates: []TierRate{tier(1, 1, intPtr(3), true), tier(2, 4, intPtr(5), false), tier(3, 6, nil, true)},
+			want:  []QtyRange{{Min: 4, Max: intPtr(5)}},
+		},
+		{
+			name:  "вложенная ступень не сдвигает границу назад",
+			rates: []TierRate{tier(1, 1, intPtr(10), true), tier(2, 2, intPtr(3), true), tier(3, 11, nil, true)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TierGaps(tt.rates); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TierGaps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTierOverlap(t *testing.T) {
+	rates := []TierRate{
+		tier(1, 1, intPtr(3), true),
+		tier(2, 4, intPtr(8), true),
+		tier(3, 9, nil, true),
+		tier(4, 20, intPtr(30), false),
+	}
+	tests := []struct {
+		name   string
+		skipID int64
+		r      QtyRange
+		want   int64 // 0 — пересечений нет
+	}{
+		{name: "внутри ступени", r: QtyRange{Min: 5, Max: intPtr(6)}, want: 2},
+		{name: "касается верхней границы", r: QtyRange{Min: 3, Max: intPtr(3)}, want: 1},
+		{name: "открытый диапазон", r: QtyRange{Min: 100}, want: 3},
+		{name: "своя ступень пропускается", skipID: 2, r: QtyRange{Min: 4, Max: intPtr(8)}},
+		{name: "отключённая ступень не мешает", skipID: 3, r: QtyRange{Min: 20, Max: intPtr(25)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TierOverlap(rates, tt.skipID, tt.r)
+			var id int64
+			if got != nil {
+				id = got.ID
+			}
+			if id != tt.want {
+				t.Errorf("TierOverlap() = %d, want %d", id, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewTierGaps(t *testing.T) {
+	full := []TierRate{tier(1, 1, intPtr(3), true), tier(2, 4, intPtr(8), true), tier(3, 9, nil, true)}
+	tests := []struct {
+		name   string
+		before []TierRate
+		after  []TierRate
+		want   []QtyRange
+	}{
+		{
+			name:   "без изменений",
+			before: full,
+			after:  full,
+		},
+		{
+			name:   "удалили среднюю ступень",
+			before: full,
+			after:  []TierRate{full[0], full[2]},
+			want:   []QtyRange{{Min: 4, Max: intPtr(8)}},
+		},
+		{
+			name:   "отключили верхнюю ступень",
+			before: full,
+			after:  []TierRate{full[0], full[1], tier(3, 9, nil, false)},
+			want:   []QtyRange{{Min: 9}},
+		},
+		{
+			name:   "старый разрыв не мешает",
+			before: []TierRate{full[0], full[2]},
+			after:  []TierRate{full[0], tier(2, 5, intPtr(8), true), full[2]},
+		},
+		{
+			name:   "старый разрыв расширился",
+			before: []TierRate{tier(1, 1, intPtr(3), true), tier(2, 5, nil, true)},
+			after:  []TierRate{tier(1, 1, intPtr(2), true), tier(2, 5, nil, true)},
+			want:   []QtyRange{{Min: 3, Max: intPtr(4)}},
+		},
+		{
+			name:   "сузили открытую ступень",
+			before: full,
+			after:  []TierRate{full[0], full[1], tier(3, 9, intPtr(20), true)},
+			want:   []QtyRange{{Min: 21}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewTierGaps(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewTierGaps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/Spok95/beauty-bot/internal/domain/audit"
+	"github.com/Spok95/beauty-bot/internal/domain/discounts"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -16,21 +18,48 @@ type Repo struct{ pool *pgxpool.Pool }
 
 func NewRepo(pool *pgxpool.Pool) *Repo { return &Repo{pool: pool} }
 
+// CreateSession сохраняет сессию; tariffVersionID — версия тарифов, по которой посчитана аренда (0 — без аренды).
 func (r *Repo) CreateSession(ctx context.Context, userID int64, place, unit string, qty int, withSub bool,
-	mats, rounded, rent, total float64, payload map[string]any) (int64, error) {
+	mats, rounded, rent, total float64, tariffVersionID int64, payload map[string]any) (int64, error) {
 
+	var version any
+	if tariffVersionID > 0 {
+		version = tariffVersionID
+	}
 	pb, _ := json.Marshal(payload)
 	row := r.pool.QueryRow(ctx, `
 		INSERT INTO consumption_sessions
-		(user_id, place, unit, qty, with_subscription, materials_sum, rounded_materials_sum, rent, total, payload, status)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,'draft')
+		(user_id, place, unit, qty, with_subscription, materials_sum, rounded_materials_sum, rent, total, payload, status, tariff_version_id)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,'draft',$11)
 		RETURNING id
-	`, userID, place, unit, qty, withSub, mats, rounded, rent, total, pb)
+	`, userID, place, unit, qty, withSub, mats, rounded, rent, total, pb, version)
 
 	var id int64
 	return id, row.Scan(&id)
 }
 
+// AddSessionDiscounts сохраняет скидки, применённые к сессии.
+func (r *Repo) AddSessionDiscounts(ctx context.Context, sessionID int64, list []discounts.Applied) error {
+	if len(list) == 0 {
+		return nil
+	}
+	return audit.Track(ctx, r.pool, audit.EntitySession, sessionID, func(tx pgx.Tx) error {
+		for _, a := range list {
+			var discountID any
+			if a.DiscountID > 0 {
+				discountID = a.DiscountID
+			}
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO consumption_discounts (session_id, discount_id, title, target, amount)
+				VALUES ($1, $2, $3, $4, $5)`,
+				sessionID, discountID, a.Title, a.Target, a.Amount); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 func (r *Repo) AddItem(ctx context.Context, sessionID, materialID int64, qty, unitPrice, cost float64) error {
 	_, err := r.pool.Exec(ctx, `
 		INSERT INTO consumption_items (session_id, material_id, qty, unit_price, cost)
@@ -127,30 +156,53 @@ func (r *Repo) ListItemsBySession(ctx context.Context, sessionID int64) ([]Item,
 }
 
 func (r *Repo) CancelSession(ctx context.Context, sessionID int64) error {
-	tag, err := r.pool.Exec(ctx, `
-		UPDATE consumption_sessions
-		SET status = 'canceled',
-		    payload = jsonb_set(payload, '{cancelled_at}', to_jsonb(now()::text), true)
-		WHERE id = $1
-		  AND status <> 'canceled'
-	`, sessionID)
+	return audit.Track(ctx, r.pool, audit.EntitySession, sessionID, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+			UPDATE consumption_sessions
+			SET status = 'canceled',
+			    payload = jsonb_set(payload, '{cancelled_at}', to_jsonb(now()::text), true)
+			WHERE id = $1
+			  AND status <> 'canceled'
+		`, sessionID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+		return nil
+	})
+}
+
+// CancelInvoicesBySession отменяет неотменённые счета сессии; каждый счёт попадает в журнал.
+func (r *Repo) CancelInvoicesBySession(ctx context.Context, sessionID int64) error {
+	tx, err := r.pool.Begin(ctx)
 	if err != nil {
 		return err
 	}
-	if tag.RowsAffected() == 0 {
-		return pgx.ErrNoRows
-	}
-	return nil
-}
+	defer func() { _ = tx.Rollback(ctx) }()
 
-func (r *Repo) CancelInvoicesBySession(ctx context.Context, sessionID int64) error {
-	_, err := r.pool.Exec(ctx, `
-		UPDATE invoices
-		SET status = 'canceled'
-		WHERE session_id = $1
-		  AND status <> 'canceled'
-	`, sessionID)
-	return err
+	rows, err := tx.Query(ctx, `
+		SELECT id FROM invoices
+		WHERE session_id = $1 AND status <> 'canceled'
+		FOR UPDATE`, sessionID)
+	if err != nil {
+		return err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		err := audit.TrackTx(ctx, tx, audit.EntityInvoice, id, func() error {
+			_, err := tx.Exec(ctx, `UPDATE invoices SET status = 'canceled' WHERE id = $1`, id)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
 }
 
 func (r *Repo) CreateInvoice(ctx context.Context, userID, sessionID int64, amount float64, comment string) (int64, error) {
@@ -163,11 +215,23 @@ func (r *Repo) CreateInvoice(ctx context.Context, userID, sessionID int64, amoun
 	return id, row.Scan(&id)
 }
 
+// versionOnSQL — подзапрос: id версии тарифов, действующей в день $1 (YYYY-MM-DD).
+// Если в один день действуют две версии (ступени правили после сессий), берётся более новая.
+// День передаётся из бота по часовому поясу салона, а не берётся из часов базы (CURRENT_DATE).
+const versionOnSQL = `(
+    SELECT v.id FROM rent_tariff_versions v
+    WHERE v.status = 'published'
+      AND v.active_from <= $1::date
+      AND (v.active_to IS NULL OR v.active_to >= $1::date)
+    ORDER BY v.active_from DESC, v.id DESC
+    LIMIT 1)`
+
 // GetTier подбирает ступень по qty (для админки, старый интерфейс).
 // Работает с новой схемой rent_rates (with_subscription, threshold_materials и т.п.).
-func (r *Repo) GetTier(ctx context.Context, place, unit string, withSub bool, qty int) (TierRate, bool, error) {
+func (r *Repo) GetTier(ctx context.Context, day time.Time, place, unit string, withSub bool, qty int) (TierRate, bool, error) {
 	const q = `
 SELECT id,
+       version_id,
        place,
        unit,
        with_subscription,
@@ -176,20 +240,22 @@ SELECT id,
        threshold_materials,
        price_with_materials,
        price_own_materials,
-       (active_to IS NULL OR active_to >= CURRENT_DATE) AS active
+       (active_to IS NULL OR active_to >= $1::date) AS active
 FROM rent_rates
-WHERE place=$1
-  AND unit=$2
-  AND with_subscription=$3
-  AND min_qty <= $4
-  AND (max_qty IS NULL OR max_qty >= $4)
+WHERE version_id = ` + versionOnSQL + `
+  AND place=$2
+  AND unit=$3
+  AND with_subscription=$4
+  AND min_qty <= $5
+  AND (max_qty IS NULL OR max_qty >= $5)
 ORDER BY min_qty DESC
 LIMIT 1`
 	var tr TierRate
 	var maxSQL sql.NullInt32
 
-	err := r.pool.QueryRow(ctx, q, place, unit, withSub, qty).Scan(
+	err := r.pool.QueryRow(ctx, q, day.Format("2006-01-02"), place, unit, withSub, qty).Scan(
 		&tr.ID,
+		&tr.VersionID,
 		&tr.Place,
 		&tr.Unit,
 		&tr.WithSub,
@@ -213,10 +279,20 @@ LIMIT 1`
 	return tr, true, nil
 }
 
-// ListRates — список ступеней для place/unit/withSub (для экрана "Установка тарифов").
-func (r *Repo) ListRates(ctx context.Context, place, unit string, withSub bool) ([]TierRate, error) {
+// querier — пул или транзакция.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// ListRates — ступени версии, действующей в день day, для place/unit/withSub (для экрана "Установка тарифов").
+func (r *Repo) ListRates(ctx context.Context, day time.Time, place, unit string, withSub bool) ([]TierRate, error) {
+	return listRates(ctx, r.pool, day.Format("2006-01-02"), place, unit, withSub)
+}
+
+func listRates(ctx context.Context, db querier, day, place, unit string, withSub bool) ([]TierRate, error) {
 	const q = `
 SELECT id,
+       version_id,
        place,
        unit,
        with_subscription,
@@ -225,13 +301,14 @@ SELECT id,
        threshold_materials,
        price_with_materials,
        price_own_materials,
-       (active_to IS NULL OR active_to >= CURRENT_DATE) AS active
+       (active_to IS NULL OR active_to >= $1::date) AS active
 FROM rent_rates
-WHERE place=$1
-  AND unit=$2
-  AND with_subscription=$3
+WHERE version_id = ` + versionOnSQL + `
+  AND place=$2
+  AND unit=$3
+  AND with_subscription=$4
 ORDER BY active DESC, min_qty ASC`
-	rows, err := r.pool.Query(ctx, q, place, unit, withSub)
+	rows, err := db.Query(ctx, q, day, place, unit, withSub)
 	if err != nil {
 		return nil, err
 	}
@@ -243,6 +320,7 @@ ORDER BY active DESC, min_qty ASC`
 		var maxSQL sql.NullInt32
 		if err := rows.Scan(
 			&tr.ID,
+			&tr.VersionID,
 			&tr.Place,
 			&tr.Unit,
 			&tr.WithSub,
@@ -261,14 +339,17 @@ ORDER BY active DESC, min_qty ASC`
 		}
 		out = append(out, tr)
 	}
-	return out, nil
+	return out, rows.Err()
 }
 
-// CreateRate — создать новую ступень тарифа (для экрана "Установка тарифов").
+// CreateRate — создать новую ступень в версии тарифов, действующей в день day (для экрана "Установка тарифов").
 // per_unit для таких ступеней считаем всегда TRUE (порог "на единицу").
-func (r *Repo) CreateRate(ctx context.Context, place, unit string, withSub bool, minQty int, maxQty *int, threshold, priceWith, priceOwn float64) (int64, error) {
+// Диапазон не должен пересекаться с активными ступенями того же набора (ErrTierOverlap).
+// Если по действующей версии уже были сессии, ступень добавляется в её копию (см. editableVersionTx).
+func (r *Repo) CreateRate(ctx context.Context, day time.Time, place, unit string, withSub bool, minQty int, maxQty *int, threshold, priceWith, priceOwn float64) (int64, error) {
 	const q = `
 INSERT INTO rent_rates(
+    version_id,
     place,
     unit,
     with_subscription,
@@ -280,36 +361,317 @@ INSERT INTO rent_rates(
     price_own_materials,
     active_from,
     active_to
-) VALUES ($1,$2,$3,$4,$5,TRUE,$6,$7,$8,CURRENT_DATE,NULL)
+) VALUES (` + versionOnSQL + `,$2,$3,$4,$5,$6,TRUE,$7,$8,$9,$1::date,NULL)
 RETURNING id`
+	d := day.Format("2006-01-02")
 	var id int64
-	var maxAny any
-	if maxQty == nil {
-		maxAny = nil
-	} else {
-		maxAny = *maxQty
-	}
-	err := r.pool.QueryRow(ctx, q,
-		place,
-		unit,
-		withSub,
-		minQty,
-		maxAny,
-		threshold,
-		priceWith,
-		priceOwn,
-	).Scan(&id)
+	err := audit.TrackCreate(ctx, r.pool, audit.EntityRentRate, func(tx pgx.Tx) (int64, error) {
+		if _, _, err := editableVersionTx(ctx, tx, d); err != nil {
+			return 0, err
+		}
+		if err := checkTierOverlap(ctx, tx, d, place, unit, withSub, 0, minQty, maxQty); err != nil {
+			return 0, err
+		}
+		err := tx.QueryRow(ctx, q,
+			d,
+			place,
+			unit,
+			withSub,
+			minQty,
+			maxQty,
+			threshold,
+			priceWith,
+			priceOwn,
+		).Scan(&id)
+		return id, err
+	})
 	return id, err
 }
 
-func (r *Repo) pickTier(ctx context.Context, place, unit string, withSub bool, qty int) (*RentRate, error) {
+// checkTierOverlap — есть ли в версии, действующей в день day, активная ступень (кроме skipID),
+// пересекающаяся с диапазоном [minQty; maxQty].
+func checkTierOverlap(ctx context.Context, tx pgx.Tx, day, place, unit string, withSub bool, skipID int64, minQty int, maxQty *int) error {
+	const q = `
+SELECT EXISTS (
+    SELECT 1 FROM rent_rates
+    WHERE version_id = ` + versionOnSQL + `
+      AND place = $2 AND unit = $3 AND with_subscription = $4
+      AND id <> $5
+      AND (active_to IS NULL OR active_to >= $1::date)
+      AND ($7::int IS NULL OR min_qty <= $7)
+      AND (max_qty IS NULL OR $6 <= max_qty))`
+	var exists bool
+	if err := tx.QueryRow(ctx, q, day, place, unit, withSub, skipID, minQty, maxQty).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrTierOverlap
+	}
+	return nil
+}
+
+// checkTierGaps — не появилось ли после изменения количеств без тарифа (ErrTierGap).
+// before — ступени набора до изменения: разрывы, которые были и раньше, не мешают сохранению.
+func checkTierGaps(ctx context.Context, tx pgx.Tx, day, place, unit string, withSub bool, before []TierRate) error {
+	after, err := listRates(ctx, tx, day, place, unit, withSub)
+	if err != nil {
+		return err
+	}
+	if len(NewTierGaps(before, after)) > 0 {
+		return ErrTierGap
+	}
+	return nil
+}
+
+// GetRate — ступень версии тарифов, действующей в день day, по id (nil — не найдена).
+func (r *Repo) GetRate(ctx context.Context, day time.Time, id int64) (*TierRate, error) {
 	const q = `
-SELECT id, place, unit, with_subscription, min_qty, max_qty, per_unit,
+SELECT id,
+       version_id,
+       place,
+       unit,
+       with_subscription,
+       min_qty,
+       max_qty,
+       threshold_materials,
+       price_with_materials,
+       price_own_materials,
+       (active_to IS NULL OR active_to >= $1::date) AS active
+FROM rent_rates
+WHERE id = $2 AND version_id = ` + versionOnSQL
+	var tr TierRate
+	err := r.pool.QueryRow(ctx, q, day.Format("2006-01-02"), id).Scan(
+		&tr.ID, &tr.VersionID, &tr.Place, &tr.Unit, &tr.WithSub, &tr.MinQty, &tr.MaxQty,
+		&tr.Threshold, &tr.PriceWith, &tr.PriceOwn, &tr.Active,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tr, nil
+}
+
+// editRate — общая часть правки ступени: находит ступень в версии, которую можно менять в день day
+// (дата салона), блокирует её и выполняет fn. Изменение не должно оставлять количеств без тарифа (ErrTierGap).
+// Возвращает id ступени после изменения: если версия была скопирована, это id копии.
+func (r *Repo) editRate(ctx context.Context, day time.Time, id int64, fn func(tx pgx.Tx, d string, cur *TierRate) error) (int64, error) {
+	d := day.Format("2006-01-02")
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	_, idMap, err := editableVersionTx(ctx, tx, d)
+	if err != nil {
+		return 0, err
+	}
+	if idMap != nil {
+		newID, ok := idMap[id]
+		if !ok {
+			return 0, ErrRateNotFound
+		}
+		id = newID
+	}
+
+	err = audit.TrackTx(ctx, tx, audit.EntityRentRate, id, func() error {
+		cur, err := lockRate(ctx, tx, d, id)
+		if err != nil {
+			return err
+		}
+		before, err := listRates(ctx, tx, d, cur.Place, cur.Unit, cur.WithSub)
+		if err != nil {
+			return err
+		}
+		if err := fn(tx, d, cur); err != nil {
+			return err
+		}
+		return checkTierGaps(ctx, tx, d, cur.Place, cur.Unit, cur.WithSub, before)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit(ctx)
+}
+
+// UpdateRate меняет диапазон и цены ступени действующей версии и возвращает id ступени после изменения.
+// Для активной ступени новый диапазон проверяется на пересечения (ErrTierOverlap).
+func (r *Repo) UpdateRate(ctx context.Context, day time.Time, id int64, minQty int, maxQty *int, threshold, priceWith, priceOwn float64) (int64, error) {
+	return r.editRate(ctx, day, id, func(tx pgx.Tx, d string, cur *TierRate) error {
+		if cur.Active {
+			if err := checkTierOverlap(ctx, tx, d, cur.Place, cur.Unit, cur.WithSub, cur.ID, minQty, maxQty); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(ctx, `
+UPDATE rent_rates
+SET min_qty              = $2,
+    max_qty              = $3,
+    threshold_materials  = $4,
+    price_with_materials = $5,
+    price_own_materials  = $6
+WHERE id = $1`, cur.ID, minQty, maxQty, threshold, priceWith, priceOwn)
+		return err
+	})
+}
+
+// SetRateActive включает или отключает ступень действующей версии.
+// Отключённая ступень (active_to = вчера) остаётся в списке, но сразу перестаёт участвовать в расчётах.
+// При включении диапазон проверяется на пересечения. Возвращает id ступени после изменения.
+func (r *Repo) SetRateActive(ctx context.Context, day time.Time, id int64, active bool) (int64, error) {
+	return r.editRate(ctx, day, id, func(tx pgx.Tx, d string, cur *TierRate) error {
+		if cur.Active == active {
+			return nil
+		}
+		if active {
+			if err := checkTierOverlap(ctx, tx, d, cur.Place, cur.Unit, cur.WithSub, cur.ID, cur.MinQty, cur.MaxQty); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `UPDATE rent_rates SET active_to = NULL WHERE id = $1`, cur.ID)
+			return err
+		}
+		_, err := tx.Exec(ctx, `UPDATE rent_rates SET active_to = $2::date - 1 WHERE id = $1`, cur.ID, d)
+		return err
+	})
+}
+
+// DeleteRate удаляет ступень действующей версии.
+func (r *Repo) DeleteRate(ctx context.Context, day time.Time, id int64) error {
+	_, err := r.editRate(ctx, day, id, func(tx pgx.Tx, _ string, cur *TierRate) error {
+		_, err := tx.Exec(ctx, `DELETE FROM rent_rates WHERE id = $1`, cur.ID)
+		return err
+	})
+	return err
+}
+
+// MergeRate удаляет ступень, отдавая её диапазон соседней активной ступени: нижней, если она
+// примыкает вплотную, иначе верхней. Так можно убрать ступень из середины, не оставив разрыва.
+// Соседней ступени нет — ErrTierGap.
+func (r *Repo) MergeRate(ctx context.Context, day time.Time, id int64) error {
+	_, err := r.editRate(ctx, day, id, func(tx pgx.Tx, d string, cur *TierRate) error {
+		rates, err := listRates(ctx, tx, d, cur.Place, cur.Unit, cur.WithSub)
+		if err != nil {
+			return err
+		}
+		var nb *TierRate
+		minQty, maxQty := 0, cur.MaxQty
+		for i := range rates {
+			n := &rates[i]
+			if !n.Active || n.ID == cur.ID {
+				continue
+			}
+			if n.MaxQty != nil && *n.MaxQty+1 == cur.MinQty {
+				nb, minQty = n, n.MinQty
+				break
+			}
+			if cur.MaxQty != nil && n.MinQty == *cur.MaxQty+1 {
+				nb, minQty, maxQty = n, cur.MinQty, n.MaxQty
+			}
+		}
+		if nb == nil {
+			return ErrTierGap
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM rent_rates WHERE id = $1`, cur.ID); err != nil {
+			return err
+		}
+		return audit.TrackTx(ctx, tx, audit.EntityRentRate, nb.ID, func() error {
+			_, err := tx.Exec(ctx, `UPDATE rent_rates SET min_qty = $2, max_qty = $3 WHERE id = $1`, nb.ID, minQty, maxQty)
+			return err
+		})
+	})
+	return err
+}
+
+// lockRate блокирует ступень действующей версии до конца транзакции.
+func lockRate(ctx context.Context, tx pgx.Tx, day string, id int64) (*TierRate, error) {
+	const q = `
+SELECT id, version_id, place, unit, with_subscription, min_qty, max_qty,
+       threshold_materials, price_with_materials, price_own_materials,
+       (active_to IS NULL OR active_to >= $1::date) AS active
+FROM rent_rates
+WHERE id = $2 AND version_id = ` + versionOnSQL + `
+FOR UPDATE`
+	var tr TierRate
+	err := tx.QueryRow(ctx, q, day, id).Scan(
+		&tr.ID, &tr.VersionID, &tr.Place, &tr.Unit, &tr.WithSub, &tr.MinQty, &tr.MaxQty,
+		&tr.Threshold, &tr.PriceWith, &tr.PriceOwn, &tr.Active,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrRateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tr, nil
+}
+
+// rateEditComment — комментарий копии версии, созданной правкой ступеней.
+const rateEditComment = "Изменение ступеней"
+
+// editableVersionTx — версия тарифов, ступени которой можно менять в день day (дата салона, YYYY-MM-DD).
+// Пока по действующей версии нет сессий, она меняется на месте (idMap = nil). Иначе создаётся копия
+// и публикуется с day — даже если действующая версия сама копия, созданная правкой в этот же день:
+// проведённые раньше сессии остаются со своей версией, а idMap переводит id ступени действующей
+// версии в id её копии. Несколько версий с одного дня допустимы: действующей считается более новая.
+// Блокировка версии задерживает создание сессий с ней до конца транзакции.
+func editableVersionTx(ctx context.Context, tx pgx.Tx, day string) (int64, map[int64]int64, error) {
+	var curID int64
+	var used bool
+	err := tx.QueryRow(ctx, `
+SELECT id,
+       EXISTS (SELECT 1 FROM consumption_sessions s WHERE s.tariff_version_id = v.id)
+FROM rent_tariff_versions v
+WHERE id = `+versionOnSQL+`
+FOR UPDATE`, day).Scan(&curID, &used)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil, ErrNoTariffVersion
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	if !used {
+		return curID, nil, nil
+	}
+
+	actorID, _ := audit.ActorFrom(ctx)
+	var createdBy any
+	if actorID > 0 {
+		createdBy = actorID
+	}
+	var newID int64
+	if err := tx.QueryRow(ctx, `
+INSERT INTO rent_tariff_versions (status, base_version_id, comment, created_by)
+VALUES ('draft', $1, $2, $3)
+RETURNING id`, curID, rateEditComment, createdBy).Scan(&newID); err != nil {
+		return 0, nil, err
+	}
+	idMap, err := copyRatesTx(ctx, tx, curID, newID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := publishVersionTx(ctx, tx, newID, day); err != nil {
+		return 0, nil, err
+	}
+	after, err := audit.Snapshot(ctx, tx, audit.EntityTariffVersion, newID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := audit.Record(ctx, tx, audit.EntityTariffVersion, newID, nil, after); err != nil {
+		return 0, nil, err
+	}
+	return newID, idMap, nil
+}
+
+func (r *Repo) pickTier(ctx context.Context, versionID int64, day string, place, unit string, withSub bool, qty int) (*RentRate, error) {
+	const q = `
+SELECT id, version_id, place, unit, with_subscription, min_qty, max_qty, per_unit,
        threshold_materials, price_with_materials, price_own_materials,
        active_from, active_to
 FROM rent_rates
-WHERE place=$1 AND unit=$2 AND with_subscription=$3
-  AND (active_to IS NULL OR active_to >= CURRENT_DATE)
+WHERE version_id=$5 AND place=$1 AND unit=$2 AND with_subscription=$3
+  AND (active_to IS NULL OR active_to >= $6::date)
   AND min_qty <= $4
   AND (max_qty IS NULL OR $4 <= max_qty)
 ORDER BY min_qty DESC
@@ -317,8 +679,8 @@ LIMIT 1`
 	var rr RentRate
 	var maxQty *int
 	var activeTo *time.Time
-	err := r.pool.QueryRow(ctx, q, place, unit, withSub, qty).Scan(
-		&rr.ID, &rr.Place, &rr.Unit, &rr.WithSub, &rr.MinQty, &maxQty, &rr.PerUnit,
+	err := r.pool.QueryRow(ctx, q, place, unit, withSub, qty, versionID, day).Scan(
+		&rr.ID, &rr.VersionID, &rr.Place, &rr.Unit, &rr.WithSub, &rr.MinQty, &maxQty, &rr.PerUnit,
 		&rr.Threshold, &rr.PriceWith, &rr.PriceOwn, &rr.ActiveFrom, &activeTo,
 	)
 	if err != nil {
@@ -339,6 +701,27 @@ LIMIT 1`
 //   - из остатка проверяем порог второй части и т.д.
 func (r *Repo) ComputeRentSplit(
 	ctx context.Context,
+	day time.Time,
+	place, unit string,
+	matsSum float64,
+	parts []RentSplitPartInput,
+) (totalRent float64, rounded float64, totalNeed float64, results []RentSplitPartResult, err error) {
+	versionID, err := r.CurrentTariffVersionID(ctx, day)
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+	if versionID == 0 {
+		return 0, 0, 0, nil, ErrNoTariffVersion
+	}
+	return r.ComputeRentSplitVersion(ctx, versionID, day, place, unit, matsSum, parts)
+}
+
+// ComputeRentSplitVersion — то же, что ComputeRentSplit, по заданной версии тарифов
+// (для предпросмотра черновика и сравнения версий).
+func (r *Repo) ComputeRentSplitVersion(
+	ctx context.Context,
+	versionID int64,
+	day time.Time,
 	place, unit string,
 	matsSum float64,
 	parts []RentSplitPartInput,
@@ -362,7 +745,7 @@ func (r *Repo) ComputeRentSplit(
 			qtyForTier = in.SubLimitForPricing
 		}
 
-		rate, errPick := r.pickTier(ctx, place, unit, in.WithSub, qtyForTier)
+		rate, errPick := r.pickTier(ctx, versionID, day.Format("2006-01-02"), place, unit, in.WithSub, qtyForTier)
 		if errPick != nil || rate == nil {
 			return 0, 0, 0, nil, fmt.Errorf(
 				"нет активного тарифа для place=%s unit=%s withSub=%v qty=%d",
@@ -431,6 +814,7 @@ func (r *Repo) ComputeRentSplit(
 // ComputeRent — совместимая обёртка над ComputeRentSplit для одной части сессии.
 func (r *Repo) ComputeRent(
 	ctx context.Context,
+	day time.Time,
 	place, unit string,
 	withSub bool,
 	sessionQty int,
@@ -452,7 +836,7 @@ func (r *Repo) ComputeRent(
 		part.SubLimitForPricing = sessionQty
 	}
 
-	totalRent, rounded, totalNeed, parts, err := r.ComputeRentSplit(ctx, place, unit, matsSum, []RentSplitPartInput{part})
+	totalRent, rounded, totalNeed, parts, err := r.ComputeRentSplit(ctx, day, place, unit, matsSum, []RentSplitPartInput{part})
 	if err != nil {
 		return 0, "", 0, 0, nil, err
 	}
@@ -464,8 +848,8 @@ func (r *Repo) ComputeRent(
 	return totalRent, p.Tariff, rounded, totalNeed, p.Rate, nil
 }
 
-// ListRentRates возвращает все тарифы аренды из rent_rates.
-func (r *Repo) ListRentRates(ctx context.Context) ([]RentRate, error) {
+// ListRentRates возвращает ступени версии тарифов, действующей в день day.
+func (r *Repo) ListRentRates(ctx context.Context, day time.Time) ([]RentRate, error) {
 	const q = `
 SELECT
     id,
@@ -477,10 +861,11 @@ SELECT
     price_with_materials,
     price_own_materials
 FROM rent_rates
+WHERE version_id = ` + versionOnSQL + `
 ORDER BY id;
 `
 
-	rows, err := r.pool.Query(ctx, q)
+	rows, err := r.pool.Query(ctx, q, day.Format("2006-01-02"))
 	if err != nil {
 		return nil, err
 	}
@@ -506,102 +891,555 @@ ORDER BY id;
 	return res, rows.Err()
 }
 
-// UpdateRentRatePartial обновляет threshold/price_with/price_own для тарифа.
-// Если какое-то значение равно nil, оно не трогается.
-func (r *Repo) UpdateRentRatePartial(
-	ctx context.Context,
-	id int64,
-	threshold, priceWith, priceOwn *float64,
-) error {
-	const q = `
+const tariffVersionColumns = `
+    id, status, COALESCE(base_version_id, 0), active_from, active_to, comment,
+    COALESCE(created_by, 0), created_at, published_at`
+
+func scanTariffVersion(row pgx.Row) (*TariffVersion, error) {
+	var v TariffVersion
+	if err := row.Scan(&v.ID, &v.Status, &v.BaseVersionID, &v.ActiveFrom, &v.ActiveTo, &v.Comment,
+		&v.CreatedBy, &v.CreatedAt, &v.PublishedAt); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// CurrentTariffVersionID — id версии тарифов, действующей в день day (0 — нет ни одной).
+func (r *Repo) CurrentTariffVersionID(ctx context.Context, day time.Time) (int64, error) {
+	var id int64
+	err := r.pool.QueryRow(ctx, `SELECT COALESCE(`+versionOnSQL+`, 0)`, day.Format("2006-01-02")).Scan(&id)
+	return id, err
+}
+
+// GetTariffVersion возвращает версию тарифов (nil, если нет).
+func (r *Repo) GetTariffVersion(ctx context.Context, id int64) (*TariffVersion, error) {
+	v, err := scanTariffVersion(r.pool.QueryRow(ctx,
+		`SELECT`+tariffVersionColumns+` FROM rent_tariff_versions WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return v, err
+}
+
+// ListTariffVersions — версии тарифов: черновики и запланированные сверху, затем по дате начала.
+func (r *Repo) ListTariffVersions(ctx context.Context, limit int) ([]TariffVersion, error) {
+	rows, err := r.pool.Query(ctx, `
+SELECT`+tariffVersionColumns+`
+FROM rent_tariff_versions
+ORDER BY status = 'draft' DESC, active_from DESC NULLS FIRST, id DESC
+LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []TariffVersion
+	for rows.Next() {
+		v, err := scanTariffVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *v)
+	}
+	return out, rows.Err()
+}
+
+// ListVersionRates — все ступени версии тарифов.
+func (r *Repo) ListVersionRates(ctx context.Context, versionID int64) ([]RentRate, error) {
+	rows, err := r.pool.Query(ctx, `
+SELECT id, version_id, place, unit, with_subscription, min_qty, max_qty, per_unit,
+       threshold_materials, price_with_materials, price_own_materials,
+       active_from, active_to
+FROM rent_rates
+WHERE version_id = $1
+ORDER BY place, unit, with_subscription, min_qty`, versionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []RentRate
+	for rows.Next() {
+		var rr RentRate
+		if err := rows.Scan(&rr.ID, &rr.VersionID, &rr.Place, &rr.Unit, &rr.WithSub, &rr.MinQty, &rr.MaxQty, &rr.PerUnit,
+			&rr.Threshold, &rr.PriceWith, &rr.PriceOwn, &rr.ActiveFrom, &rr.ActiveTo); err != nil {
+			return nil, err
+		}
+		out = append(out, rr)
+	}
+	return out, rows.Err()
+}
+
+// CreateTariffDraft создаёт черновик — копию ступеней версии baseVersionID — и возвращает
+// его id и соответствие «id ступени в базовой версии → id копии». Прежние черновики удаляются:
+// одновременно готовится только один.
+func (r *Repo) CreateTariffDraft(ctx context.Context, baseVersionID int64, comment string) (int64, map[int64]int64, error) {
+	actorID, _ := audit.ActorFrom(ctx)
+	var createdBy any
+	if actorID > 0 {
+		createdBy = actorID
+	}
+	var base any
+	if baseVersionID > 0 {
+		base = baseVersionID
+	}
+
+	var idMap map[int64]int64
+	var draftID int64
+	err := audit.TrackCreate(ctx, r.pool, audit.EntityTariffVersion, func(tx pgx.Tx) (int64, error) {
+		rows, err := tx.Query(ctx, `SELECT id FROM rent_tariff_versions WHERE status = 'draft'`)
+		if err != nil {
+			return 0, err
+		}
+		oldDrafts, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+		if err != nil {
+			return 0, err
+		}
+		for _, id := range oldDrafts {
+			err := audit.TrackTx(ctx, tx, audit.EntityTariffVersion, id, func() error {
+				_, err := tx.Exec(ctx, `DELETE FROM rent_tariff_versions WHERE id = $1`, id)
+				return err
+			})
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		if err := tx.QueryRow(ctx, `
+INSERT INTO rent_tariff_versions (status, base_version_id, comment, created_by)
+VALUES ('draft', $1, $2, $3)
+RETURNING id`, base, comment, createdBy).Scan(&draftID); err != nil {
+			return 0, err
+		}
+
+		idMap, err = copyRatesTx(ctx, tx, baseVersionID, draftID)
+		if err != nil {
+			return 0, err
+		}
+		return draftID, nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return draftID, idMap, nil
+}
+
+// copyRatesTx копирует ступени версии fromID в версию toID и возвращает соответствие «старый id → новый».
+func copyRatesTx(ctx context.Context, tx pgx.Tx, fromID, toID int64) (map[int64]int64, error) {
+	rows, err := tx.Query(ctx, `SELECT id FROM rent_rates WHERE version_id = $1 ORDER BY id`, fromID)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, err
+	}
+	idMap := make(map[int64]int64, len(ids))
+	for _, oldID := range ids {
+		var newID int64
+		if err := tx.QueryRow(ctx, `
+INSERT INTO rent_rates (version_id, place, unit, with_subscription, min_qty, max_qty, per_unit,
+                        threshold_materials, price_with_materials, price_own_materials, active_from, active_to)
+SELECT $1, place, unit, with_subscription, min_qty, max_qty, per_unit,
+       threshold_materials, price_with_materials, price_own_materials, active_from, active_to
+FROM rent_rates
+WHERE id = $2
+RETURNING id`, toID, oldID).Scan(&newID); err != nil {
+			return nil, err
+		}
+		idMap[oldID] = newID
+	}
+	return idMap, nil
+}
+
+// ApplyDraftRates меняет ступени черновика одной операцией (одно событие в журнале).
+func (r *Repo) ApplyDraftRates(ctx context.Context, versionID int64, changes []RateChange) error {
+	return audit.Track(ctx, r.pool, audit.EntityTariffVersion, versionID, func(tx pgx.Tx) error {
+		var status TariffStatus
+		if err := tx.QueryRow(ctx, `SELECT status FROM rent_tariff_versions WHERE id = $1 FOR UPDATE`, versionID).Scan(&status); err != nil {
+			return err
+		}
+		if status != TariffDraft {
+			return ErrTariffNotDraft
+		}
+		for _, c := range changes {
+			tag, err := tx.Exec(ctx, `
 UPDATE rent_rates
-SET
-    threshold_materials   = COALESCE($2, threshold_materials),
-    price_with_materials  = COALESCE($3, price_with_materials),
-    price_own_materials   = COALESCE($4, price_own_materials)
-WHERE id = $1;
-`
+SET threshold_materials  = COALESCE($3, threshold_materials),
+    price_with_materials = COALESCE($4, price_with_materials),
+    price_own_materials  = COALESCE($5, price_own_materials)
+WHERE id = $1 AND version_id = $2`, c.RateID, versionID, c.Threshold, c.PriceWith, c.PriceOwn)
+			if err != nil {
+				return err
+			}
+			if tag.RowsAffected() == 0 {
+				return fmt.Errorf("ступень %d не относится к версии %d", c.RateID, versionID)
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteTariffDraft удаляет черновик вместе со ступенями.
+func (r *Repo) DeleteTariffDraft(ctx context.Context, versionID int64) error {
+	return audit.Track(ctx, r.pool, audit.EntityTariffVersion, versionID, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `DELETE FROM rent_tariff_versions WHERE id = $1 AND status = 'draft'`, versionID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrTariffNotDraft
+		}
+		return nil
+	})
+}
+
+// PublishTariffVersion публикует черновик с даты from (не раньше дня today и позже начала
+// последней опубликованной версии). Предыдущие версии автоматически закрываются днём раньше.
+func (r *Repo) PublishTariffVersion(ctx context.Context, versionID int64, from, today time.Time) error {
+	day := from.Format("2006-01-02")
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var status TariffStatus
+	if err := tx.QueryRow(ctx, `SELECT status FROM rent_tariff_versions WHERE id = $1 FOR UPDATE`, versionID).Scan(&status); err != nil {
+		return err
+	}
+	if status != TariffDraft {
+		return ErrTariffNotDraft
+	}
+
+	var inPast, tooSoon bool
+	if err := tx.QueryRow(ctx, `
+SELECT $1::date < $2::date,
+       EXISTS (SELECT 1 FROM rent_tariff_versions WHERE status = 'published' AND active_from >= $1::date)`,
+		day, today.Format("2006-01-02")).Scan(&inPast, &tooSoon); err != nil {
+		return err
+	}
+	if inPast {
+		return ErrTariffStartInPast
+	}
+	if tooSoon {
+		return ErrTariffStartTooSoon
+	}
+
+	err = audit.TrackTx(ctx, tx, audit.EntityTariffVersion, versionID, func() error {
+		return publishVersionTx(ctx, tx, versionID, day)
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// publishVersionTx публикует версию с дня day (YYYY-MM-DD) и закрывает открытые версии днём раньше.
+// Версия, начавшаяся в тот же день, закрывается этим днём: действующей считается более новая.
+// Если уже запланирована версия с более поздней даты, новая действует до её начала.
+func publishVersionTx(ctx context.Context, tx pgx.Tx, versionID int64, day string) error {
+	rows, err := tx.Query(ctx, `
+SELECT id FROM rent_tariff_versions
+WHERE status = 'published' AND id <> $2
+  AND active_from <= $1::date AND (active_to IS NULL OR active_to >= $1::date)`, day, versionID)
+	if err != nil {
+		return err
+	}
+	open, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return err
+	}
+	for _, id := range open {
+		err := audit.TrackTx(ctx, tx, audit.EntityTariffVersion, id, func() error {
+			_, err := tx.Exec(ctx, `
+UPDATE rent_tariff_versions SET active_to = GREATEST($2::date - 1, active_from) WHERE id = $1`, id, day)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
 
-	_, err := r.pool.Exec(ctx, q, id, threshold, priceWith, priceOwn)
+	_, err = tx.Exec(ctx, `
+UPDATE rent_tariff_versions
+SET status = 'published', active_from = $2::date, published_at = now(),
+    active_to = (SELECT MIN(n.active_from) - 1 FROM rent_tariff_versions n
+                 WHERE n.status = 'published' AND n.active_from > $2::date)
+WHERE id = $1`, versionID, day)
 	return err
 }
 
+// SimulateTariffs пересчитывает аренду неотменённых сессий с since по версиям oldID и newID.
+// Части по абонементу не оплачиваются отдельно, поэтому в сумму не входят.
+// Ступени берутся на день сессии в часовом поясе since.
+func (r *Repo) SimulateTariffs(ctx context.Context, oldID, newID int64, since time.Time) (TariffSimulation, error) {
+	rows, err := r.pool.Query(ctx, `
+SELECT place, unit, qty, with_subscription, materials_sum::float8,
+       COALESCE(payload->'rent_parts', '[]'::jsonb), created_at
+FROM consumption_sessions
+WHERE status <> 'canceled'
+  AND created_at >= $1
+  AND place IN ('hall', 'cabinet')
+  AND COALESCE(payload->>'rent_mode', '') <> 'studio_client'
+ORDER BY created_at`, since)
+	if err != nil {
+		return TariffSimulation{}, err
+	}
+
+	type simSession struct {
+		place, unit string
+		parts       []RentSplitPartInput
+		mats        float64
+		day         time.Time
+	}
+	var sessions []simSession
+	for rows.Next() {
+		var (
+			place, unit string
+			qty         int
+			withSub     bool
+			mats        float64
+			rawParts    []byte
+			createdAt   time.Time
+		)
+		if err := rows.Scan(&place, &unit, &qty, &withSub, &mats, &rawParts, &createdAt); err != nil {
+			rows.Close()
+			return TariffSimulation{}, err
+		}
+		var stored []struct {
+			WithSub   bool `json:"with_sub"`
+			Qty       int  `json:"qty"`
+			PlanLimit int  `json:"plan_limit"`
+		}
+		_ = json.Unmarshal(rawParts, &stored)
+
+		var parts []RentSplitPartInput
+		for _, p := range stored {
+			in := RentSplitPartInput{WithSub: p.WithSub, Qty: p.Qty, SubLimitForPricing: p.Qty}
+			if p.WithSub && p.PlanLimit > 0 {
+				in.SubLimitForPricing = p.PlanLimit
+			}
+			parts = append(parts, in)
+		}
+		if len(parts) == 0 {
+			parts = []RentSplitPartInput{{WithSub: withSub, Qty: qty, SubLimitForPricing: qty}}
+		}
+		sessions = append(sessions, simSession{place: place, unit: unit, parts: parts, mats: mats, day: createdAt.In(since.Location())})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return TariffSimulation{}, err
+	}
+
+	payable := func(results []RentSplitPartResult) float64 {
+		var sum float64
+		for _, p := range results {
+			if !p.WithSub {
+				sum += p.Rent
+			}
+		}
+		return sum
+	}
+
+	var sim TariffSimulation
+	for _, s := range sessions {
+		_, _, _, oldRes, errOld := r.ComputeRentSplitVersion(ctx, oldID, s.day, s.place, s.unit, s.mats, s.parts)
+		_, _, _, newRes, errNew := r.ComputeRentSplitVersion(ctx, newID, s.day, s.place, s.unit, s.mats, s.parts)
+		if errOld != nil || errNew != nil {
+			sim.Skipped++
+			continue
+		}
+		sim.Sessions++
+		sim.OldRent += payable(oldRes)
+		sim.NewRent += payable(newRes)
+	}
+	return sim, nil
+}
+
 func roundTo10(x float64) float64 {
 	// до ближайшего десятка
 	return float64(int((x+5)/10) * 10)
 }
 
-// ListMasterMaterialsReport возвращает строки для отчёта
+// ListMasterSessionsReport возвращает неотменённые сессии с позициями для отчёта
 // "Аренда и Расходы материалов по мастерам" за период [from; to).
 // to — НЕ включительно (поэтому для "до конца дня" мы будем прибавлять 1 день в боте).
-func (r *Repo) ListMasterMaterialsReport(
+func (r *Repo) ListMasterSessionsReport(
 	ctx context.Context,
 	from, to time.Time,
-) ([]MasterMaterialsReportRow, error) {
-	const q = `
+) ([]MasterSessionReport, error) {
+	return r.listSessionsReport(ctx, `s.created_at >= $1 AND s.created_at < $2`, from, to)
+}
+
+// ListUserSessionsReport — неотменённые сессии одного мастера за период [from; to) (раздел "Моя история").
+func (r *Repo) ListUserSessionsReport(ctx context.Context, userID int64, from, to time.Time) ([]MasterSessionReport, error) {
+	return r.listSessionsReport(ctx, `s.user_id = $1 AND s.created_at >= $2 AND s.created_at < $3`, userID, from, to)
+}
+
+// GetUserSessionReport — неотменённая сессия мастера с позициями; nil — не найдена или чужая.
+func (r *Repo) GetUserSessionReport(ctx context.Context, userID, sessionID int64) (*MasterSessionReport, error) {
+	list, err := r.listSessionsReport(ctx, `s.user_id = $1 AND s.id = $2`, userID, sessionID)
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// listSessionsReport — сессии с позициями по условию where (аргументы — args).
+func (r *Repo) listSessionsReport(ctx context.Context, where string, args ...any) ([]MasterSessionReport, error) {
+	q := `
 SELECT
     s.user_id,
-    COALESCE(u.username, '') AS username,
-    s.id       AS session_id,
+    COALESCE(NULLIF(concat_ws(' ', NULLIF(p.last_name, ''), NULLIF(p.first_name, ''), NULLIF(p.middle_name, '')), ''),
+             u.username, '') AS username,
+    COALESCE(p.phone, '')           AS phone,
+    COALESCE(p.specializations, '{}') AS specializations,
+    COALESCE(p.rent_terms, '')      AS rent_terms,
+    s.id,
     s.created_at,
     s.place,
     s.unit,
     s.qty,
+    (COALESCE((s.payload->>'studio_client')::BOOLEAN, FALSE)
+        OR s.payload->>'rent_mode' = 'studio_client') IS TRUE AS studio_client,
     COALESCE(inv.comment, '') AS comment,
+    COALESCE(s.payload->>'final_comment', '') AS final_comment,
     COALESCE((s.payload->>'warehouse_id')::BIGINT, 0) AS warehouse_id,
     COALESCE(s.payload->>'warehouse_name', '') AS warehouse_name,
-    COALESCE(b.name, '')      AS brand_name,
-    m.name                    AS material_name,
-    m.unit                    AS material_unit,
-    i.qty                     AS material_qty,
-    i.unit_price,
-    i.cost
+    s.materials_sum::float8,
+    s.rounded_materials_sum::float8,
+    s.rent::float8,
+    COALESCE((SELECT SUM(d.amount) FROM consumption_discounts d WHERE d.session_id = s.id), 0)::float8 AS discount,
+    s.total::float8,
+    COALESCE(inv.id, 0) AS invoice_id,
+    COALESCE(inv.status, '') AS invoice_status,
+    COALESCE(inv.payment_link, '') AS payment_link,
+    COALESCE(s.payload->'rent_parts', '[]'::jsonb) AS rent_parts
 FROM consumption_sessions AS s
 JOIN users             AS u   ON u.id = s.user_id
+LEFT JOIN user_profiles AS p  ON p.user_id = s.user_id
 LEFT JOIN invoices     AS inv ON inv.session_id = s.id
-JOIN consumption_items AS i   ON i.session_id = s.id
-JOIN materials         AS m   ON m.id = i.material_id
-JOIN material_brands   AS b   ON b.id = m.brand_id
-WHERE
-    s.created_at >= $1
-    AND s.created_at <  $2
-    AND s.status <> 'canceled'
+WHERE s.status <> 'canceled' AND ` + where + `
 ORDER BY
     s.user_id,
     s.created_at,
-    s.id,
-    m.name;
+    s.id;
 `
 
-	rows, err := r.pool.Query(ctx, q, from, to)
+	rows, err := r.pool.Query(ctx, q, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var res []MasterMaterialsReportRow
+	var res []MasterSessionReport
+	var ids []int64
+	index := map[int64]int{}
 	for rows.Next() {
-		var row MasterMaterialsReportRow
+		var row MasterSessionReport
+		var rentParts []byte
 		if err := rows.Scan(
 			&row.UserID,
 			&row.Username,
+			&row.Phone,
+			&row.Specializations,
+			&row.RentTerms,
 			&row.SessionID,
 			&row.CreatedAt,
 			&row.Place,
 			&row.Unit,
 			&row.Qty,
+			&row.StudioClient,
 			&row.Comment,
+			&row.FinalComment,
 			&row.WarehouseID,
 			&row.WarehouseName,
-			&row.BrandName,
-			&row.MaterialName,
-			&row.MaterialUnit,
-			&row.MaterialQty,
-			&row.UnitPrice,
-			&row.Cost,
+			&row.MaterialsSum,
+			&row.RoundedMaterialsSum,
+			&row.Rent,
+			&row.Discount,
+			&row.Total,
+			&row.InvoiceID,
+			&row.InvoiceStatus,
+			&row.PaymentLink,
+			&rentParts,
 		); err != nil {
 			return nil, err
 		}
+		if err := json.Unmarshal(rentParts, &row.RentParts); err != nil {
+			return nil, fmt.Errorf("session %d rent_parts: %w", row.SessionID, err)
+		}
+		index[row.SessionID] = len(res)
+		ids = append(ids, row.SessionID)
+		res = append(res, row)
+	}
+	if err := rows.Err(); err != nil || len(res) == 0 {
+		return res, err
+	}
+
+	const qi = `
+SELECT
+    i.session_id,
+    COALESCE(b.name, '') AS brand_name,
+    m.name,
+    m.unit,
+    i.qty::float8,
+    i.unit_price::float8,
+    i.cost::float8
+FROM consumption_items AS i
+JOIN materials         AS m   ON m.id = i.material_id
+LEFT JOIN material_brands AS b ON b.id = m.brand_id
+WHERE i.session_id = ANY($1)
+ORDER BY i.session_id, m.name;
+`
+	itemRows, err := r.pool.Query(ctx, qi, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var sessionID int64
+		var it ReportItem
+		if err := itemRows.Scan(&sessionID, &it.BrandName, &it.MaterialName, &it.MaterialUnit,
+			&it.Qty, &it.UnitPrice, &it.Cost); err != nil {
+			return nil, err
+		}
+		if i, ok := index[sessionID]; ok {
+			res[i].Items = append(res[i].Items, it)
+		}
+	}
+	return res, itemRows.Err()
+}
+
+// ListDiscountsReport — скидки по неотменённым сессиям за период [from; to).
+func (r *Repo) ListDiscountsReport(ctx context.Context, from, to time.Time) ([]DiscountReportRow, error) {
+	rows, err := r.pool.Query(ctx, `
+SELECT s.user_id,
+       COALESCE(NULLIF(concat_ws(' ', NULLIF(p.last_name, ''), NULLIF(p.first_name, ''), NULLIF(p.middle_name, '')), ''),
+                u.username, '') AS username,
+       s.id, s.created_at, d.title, d.target, d.amount
+FROM consumption_discounts d
+JOIN consumption_sessions s ON s.id = d.session_id
+JOIN users u ON u.id = s.user_id
+LEFT JOIN user_profiles p ON p.user_id = s.user_id
+WHERE s.created_at >= $1
+  AND s.created_at <  $2
+  AND s.status <> 'canceled'
+ORDER BY s.user_id, s.created_at, d.id`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []DiscountReportRow
+	for rows.Next() {
+		var row DiscountReportRow
+		if err := rows.Scan(&row.UserID, &row.Username, &row.SessionID, &row.CreatedAt, &row.Title, &row.Target, &row.Amount); err != nil {
+			return nil, err
+		}
 		res = append(res, row)
 	}
 	return res, rows.Err()
@@ -609,20 +1447,38 @@ ORDER BY
 
 // SetInvoicePaymentLink устанавливает/обновляет payment_link для инвойса.
 func (r *Repo) SetInvoicePaymentLink(ctx context.Context, invoiceID int64, link string) error {
-	_, err := r.pool.Exec(ctx, `
-		UPDATE invoices
-		SET payment_link = $1
-		WHERE id = $2
-	`, link, invoiceID)
-	return err
+	return audit.Track(ctx, r.pool, audit.EntityInvoice, invoiceID, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			UPDATE invoices
+			SET payment_link = $1
+			WHERE id = $2
+		`, link, invoiceID)
+		return err
+	})
 }
 
 // SetInvoiceStatus меняет статус инвойса (pending/paid/canceled).
 func (r *Repo) SetInvoiceStatus(ctx context.Context, invoiceID int64, status string) error {
-	_, err := r.pool.Exec(ctx, `
-		UPDATE invoices
-		SET status = $1
-		WHERE id = $2
-	`, status, invoiceID)
-	return err
+	return audit.Track(ctx, r.pool, audit.EntityInvoice, invoiceID, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			UPDATE invoices
+			SET status = $1
+			WHERE id = $2
+		`, status, invoiceID)
+		return err
+	})
+}
+
+// UserActivity — сколько сессий и на какую сумму было у пользователя с даты since.
+func (r *Repo) UserActivity(ctx context.Context, userID int64, since time.Time) (UserActivity, error) {
+	var a UserActivity
+	err := r.pool.QueryRow(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE created_at >= $2),
+			COALESCE(SUM(total) FILTER (WHERE created_at >= $2), 0)::float8,
+			MAX(created_at)
+		FROM consumption_sessions
+		WHERE user_id = $1 AND status <> 'canceled'
+	`, userID, since).Scan(&a.Sessions, &a.Total, &a.LastAt)
+	return a, err
 }
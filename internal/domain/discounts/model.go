@@ -0,0 +1,143 @@
+package discounts
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound       = errors.New("скидка не найдена")
+	ErrPromoCodeTaken = errors.New("такой промокод уже есть у другой скидки")
+	ErrPromoInactive  = errors.New("промокод сейчас не действует")
+)
+
+// Kind — способ расчёта скидки.
+type Kind string
+
+const (
+	KindPercent Kind = "percent" // процент от суммы
+	KindFixed   Kind = "fixed"   // фиксированная сумма, ₽
+)
+
+// Target — на что действует скидка.
+type Target string
+
+const (
+	TargetRent      Target = "rent"      // аренда к оплате (без части по абонементу)
+	TargetMaterials Target = "materials" // сумма материалов
+)
+
+// Discount — правило скидки или акции. Пустые условия не ограничивают применение.
+type Discount struct {
+	ID         int64
+	Title      string
+	Kind       Kind
+	Target     Target
+	Value      float64    // процент (0; 100] или сумма в ₽
+	UserID     *int64     // персональная скидка мастера
+	Place      string     // ''|hall|cabinet
+	HourFrom   *int       // время суток расчёта: [HourFrom; HourTo),
+	HourTo     *int       // HourTo < HourFrom — через полночь
+	PromoCode  string     // применяется только при вводе кода
+	FirstMonth bool       // только в первый месяц после одобрения заявки мастера
+	ValidFrom  *time.Time // дата начала (включительно)
+	ValidTo    *time.Time // дата окончания (включительно)
+	Active     bool
+	CreatedBy  int64
+	CreatedAt  time.Time
+}
+
+// Context — условия расчёта, по которым подбираются скидки.
+type Context struct {
+	UserID     int64
+	Place      string // hall|cabinet|no_rent
+	At         time.Time
+	PromoCode  string
+	FirstMonth bool
+}
+
+// ValidOn — действует ли скидка в день at (без учёта прочих условий).
+func (d Discount) ValidOn(at time.Time) bool {
+	day := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+	if d.ValidFrom != nil && d.ValidFrom.After(day) {
+		return false
+	}
+	return d.ValidTo == nil || !d.ValidTo.Before(day)
+}
+
+// Matches — подходит ли скидка под условия расчёта.
+func (d Discount) Matches(c Context) bool {
+	if !d.Active || !d.ValidOn(c.At) {
+		return false
+	}
+	if d.UserID != nil && *d.UserID != c.UserID {
+		return false
+	}
+	if d.Place != "" && d.Place != c.Place {
+		return false
+	}
+	if d.PromoCode != "" && !strings.EqualFold(d.PromoCode, strings.TrimSpace(c.PromoCode)) {
+		return false
+	}
+	if d.FirstMonth && !c.FirstMonth {
+		return false
+	}
+	if d.HourFrom != nil && d.HourTo != nil {
+		h, from, to := c.At.Hour(), *d.HourFrom, *d.HourTo
+		if from < to && (h < from || h >= to) {
+			return false
+		}
+		if from > to && h < from && h >= to {
+			return false
+		}
+	}
+	return true
+}
+
+// Applied — скидка, применённая к расчёту.
+type Applied struct {
+	DiscountID int64
+	Title      string
+	Target     Target
+	Amount     float64
+}
+
+// Apply подбирает скидки под условия и считает их суммы.
+// Скидки применяются по очереди: процент считается от остатка после предыдущих,
+// сумма скидок по каждой цели не превышает её базу (аренда или материалы).
+func Apply(list []Discount, c Context, rent, materials float64) []Applied {
+	left := map[Target]float64{TargetRent: rent, TargetMaterials: materials}
+
+	var out []Applied
+	for _, d := range list {
+		if !d.Matches(c) {
+			continue
+		}
+		base := left[d.Target]
+		if base <= 0 {
+			continue
+		}
+		amount := d.Value
+		if d.Kind == KindPercent {
+			amount = base * d.Value / 100
+		}
+		amount = math.Min(math.Round(amount*100)/100, base)
+		if amount <= 0 {
+			continue
+		}
+		left[d.Target] = base - amount
+		out = append(out, Applied{DiscountID: d.ID, Title: d.Title, Target: d.Target, Amount: amount})
+	}
+	return out
+}
+
+// Total — сумма применённых скидок.
+func Total(list []Applied) float64 {
+	var sum float64
+	for _, a := range list {
+		sum += a.Amount
+	}
+	return sum
+}
@@ -0,0 +1,114 @@
+package discounts
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func intPtr(n int) *int { return &n }
+
+func TestApply(t *testing.T) {
+	at := time.Date(2026, 3, 10, 14, 0, 0, 0, time.UTC)
+	ctx := Context{UserID: 7, Place: "hall", At: at}
+
+	pct := func(id int64, target Target, v float64) Discount {
+		return Discount{ID: id, Kind: KindPercent, Target: target, Value: v, Active: true}
+	}
+	fixed := func(id int64, target Target, v float64) Discount {
+		return Discount{ID: id, Kind: KindFixed, Target: target, Value: v, Active: true}
+	}
+
+	tests := []struct {
+		name      string
+		list      []Discount
+		c         Context
+		rent, mat float64
+		want      []Applied
+	}{
+		{
+			name: "процент от аренды",
+			list: []Discount{pct(1, TargetRent, 10)},
+			c:    ctx, rent: 1000, mat: 500,
+			want: []Applied{{DiscountID: 1, Target: TargetRent, Amount: 100}},
+		},
+		{
+			name: "процент от остатка после фиксированной",
+			list: []Discount{fixed(1, TargetRent, 200), pct(2, TargetRent, 10)},
+			c:    ctx, rent: 1000,
+			want: []Applied{
+				{DiscountID: 1, Target: TargetRent, Amount: 200},
+				{DiscountID: 2, Target: TargetRent, Amount: 80},
+			},
+		},
+		{
+			name: "не больше базы",
+			list: []Discount{fixed(1, TargetMaterials, 300), fixed(2, TargetMaterials, 300)},
+			c:    ctx, mat: 500,
+			want: []Applied{
+				{DiscountID: 1, Target: TargetMaterials, Amount: 300},
+				{DiscountID: 2, Target: TargetMaterials, Amount: 200},
+			},
+		},
+		{
+			name: "база исчерпана — скидка пропускается",
+			list: []Discount{pct(1, TargetRent, 100), fixed(2, TargetRent, 50)},
+			c:    ctx, rent: 300,
+			want: []Applied{{DiscountID: 1, Target: TargetRent, Amount: 300}},
+		},
+		{
+			name: "округление до копеек",
+			list: []Discount{pct(1, TargetRent, 15)},
+			c:    ctx, rent: 333.33,
+			want: []Applied{{DiscountID: 1, Target: TargetRent, Amount: 50}},
+		},
+		{
+			name: "цели считаются отдельно",
+			list: []Discount{pct(1, TargetRent, 50), pct(2, TargetMaterials, 50)},
+			c:    ctx, rent: 100, mat: 40,
+			want: []Applied{
+				{DiscountID: 1, Target: TargetRent, Amount: 50},
+				{DiscountID: 2, Target: TargetMaterials, Amount: 20},
+			},
+		},
+		{
+			name: "неподходящие условия",
+			list: []Discount{
+				{ID: 1, Kind: KindFixed, Target: TargetRent, Value: 10},
+				{ID: 2, Kind: KindFixed, Target: TargetRent, Value: 10, Active: true, Place: "cabinet"},
+				{ID: 3, Kind: KindFixed, Target: TargetRent, Value: 10, Active: true, PromoCode: "SPRING"},
+				{ID: 4, Kind: KindFixed, Target: TargetRent, Value: 10, Active: true, HourFrom: intPtr(18), HourTo: intPtr(22)},
+				{ID: 5, Kind: KindFixed, Target: TargetRent, Value: 10, Active: true, FirstMonth: true},
+			},
+			c: ctx, rent: 1000,
+		},
+		{
+			name: "промокод без учёта регистра и ночное окно",
+			list: []Discount{
+				{ID: 1, Kind: KindFixed, Target: TargetRent, Value: 10, Active: true, PromoCode: "SPRING"},
+				{ID: 2, Kind: KindFixed, Target: TargetRent, Value: 20, Active: true, HourFrom: intPtr(22), HourTo: intPtr(8)},
+			},
+			c:    Context{UserID: 7, Place: "hall", At: at.Add(9 * time.Hour), PromoCode: " spring "},
+			rent: 1000,
+			want: []Applied{
+				{DiscountID: 1, Target: TargetRent, Amount: 10},
+				{DiscountID: 2, Target: TargetRent, Amount: 20},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Apply(tt.list, tt.c, tt.rent, tt.mat)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTotal(t *testing.T) {
+	list := []Applied{{Amount: 100}, {Amount: 20.5}, {Amount: 0.25}}
+	if got := Total(list); got != 120.75 {
+		t.Errorf("Total() = %v, want 120.75", got)
+	}
+}
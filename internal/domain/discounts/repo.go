@@ -0,0 +1,177 @@
+package discounts
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Spok95/beauty-bot/internal/domain/audit"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repo struct{ pool *pgxpool.Pool }
+
+func NewRepo(pool *pgxpool.Pool) *Repo { return &Repo{pool: pool} }
+
+const discountColumns = `
+	id, title, kind, target, value, user_id, COALESCE(place, ''), hour_from, hour_to,
+	COALESCE(promo_code, ''), first_month, valid_from, valid_to, active, COALESCE(created_by, 0), created_at`
+
+func scanDiscount(row pgx.Row) (*Discount, error) {
+	var d Discount
+	var hourFrom, hourTo *int16
+	if err := row.Scan(
+		&d.ID, &d.Title, &d.Kind, &d.Target, &d.Value, &d.UserID, &d.Place, &hourFrom, &hourTo,
+		&d.PromoCode, &d.FirstMonth, &d.ValidFrom, &d.ValidTo, &d.Active, &d.CreatedBy, &d.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if hourFrom != nil && hourTo != nil {
+		from, to := int(*hourFrom), int(*hourTo)
+		d.HourFrom, d.HourTo = &from, &to
+	}
+	return &d, nil
+}
+
+func (r *Repo) list(ctx context.Context, where string, args ...any) ([]Discount, error) {
+	rows, err := r.pool.Query(ctx, `SELECT`+discountColumns+` FROM discounts `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Discount
+	for rows.Next() {
+		d, err := scanDiscount(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *d)
+	}
+	return out, rows.Err()
+}
+
+// List — все скидки: сначала включённые.
+func (r *Repo) List(ctx context.Context) ([]Discount, error) {
+	return r.list(ctx, `ORDER BY active DESC, id`)
+}
+
+// ListActive — включённые скидки в порядке применения.
+func (r *Repo) ListActive(ctx context.Context) ([]Discount, error) {
+	return r.list(ctx, `WHERE active ORDER BY id`)
+}
+
+// GetByID возвращает скидку или ErrNotFound.
+func (r *Repo) GetByID(ctx context.Context, id int64) (*Discount, error) {
+	d, err := scanDiscount(r.pool.QueryRow(ctx, `SELECT`+discountColumns+` FROM discounts WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return d, err
+}
+
+// CheckPromoCode проверяет промокод, введённый мастером: ErrNotFound — такого нет,
+// ErrPromoInactive — скидка выключена или вне срока действия.
+func (r *Repo) CheckPromoCode(ctx context.Context, code string, at time.Time) (*Discount, error) {
+	d, err := scanDiscount(r.pool.QueryRow(ctx,
+		`SELECT`+discountColumns+` FROM discounts WHERE lower(promo_code) = lower($1)`, strings.TrimSpace(code)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !d.Active || !d.ValidOn(at) {
+		return d, ErrPromoInactive
+	}
+	return d, nil
+}
+
+// Save создаёт скидку (ID == 0) или обновляет существующую.
+func (r *Repo) Save(ctx context.Context, d Discount) (int64, error) {
+	var (
+		place, promo     any
+		hourFrom, hourTo any
+		createdBy        any
+	)
+	if d.Place != "" {
+		place = d.Place
+	}
+	if code := strings.TrimSpace(d.PromoCode); code != "" {
+		promo = code
+	}
+	if d.HourFrom != nil && d.HourTo != nil {
+		hourFrom, hourTo = *d.HourFrom, *d.HourTo
+	}
+	if d.CreatedBy > 0 {
+		createdBy = d.CreatedBy
+	}
+
+	checkPromo := func(tx pgx.Tx) error {
+		if promo == nil {
+			return nil
+		}
+		var taken bool
+		if err := tx.QueryRow(ctx,
+			`SELECT EXISTS (SELECT 1 FROM discounts WHERE lower(promo_code) = lower($1) AND id <> $2)`,
+			promo, d.ID).Scan(&taken); err != nil {
+			return err
+		}
+		if taken {
+			return ErrPromoCodeTaken
+		}
+		return nil
+	}
+
+	if d.ID == 0 {
+		var id int64
+		err := audit.TrackCreate(ctx, r.pool, audit.EntityDiscount, func(tx pgx.Tx) (int64, error) {
+			if err := checkPromo(tx); err != nil {
+				return 0, err
+			}
+			err := tx.QueryRow(ctx, `
+				INSERT INTO discounts (title, kind, target, value, user_id, place, hour_from, hour_to,
+				                       promo_code, first_month, valid_from, valid_to, active, created_by)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+				RETURNING id`,
+				d.Title, d.Kind, d.Target, d.Value, d.UserID, place, hourFrom, hourTo,
+				promo, d.FirstMonth, d.ValidFrom, d.ValidTo, d.Active, createdBy,
+			).Scan(&id)
+			return id, err
+		})
+		return id, err
+	}
+
+	err := audit.Track(ctx, r.pool, audit.EntityDiscount, d.ID, func(tx pgx.Tx) error {
+		if err := checkPromo(tx); err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, `
+			UPDATE discounts
+			SET title = $2, kind = $3, target = $4, value = $5, user_id = $6, place = $7,
+			    hour_from = $8, hour_to = $9, promo_code = $10, first_month = $11,
+			    valid_from = $12, valid_to = $13, active = $14
+			WHERE id = $1`,
+			d.ID, d.Title, d.Kind, d.Target, d.Value, d.UserID, place, hourFrom, hourTo,
+			promo, d.FirstMonth, d.ValidFrom, d.ValidTo, d.Active,
+		)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+	return d.ID, err
+}
+
+// SetActive включает или выключает скидку.
+func (r *Repo) SetActive(ctx context.Context, id int64, active bool) error {
+	return audit.Track(ctx, r.pool, audit.EntityDiscount, id, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `UPDATE discounts SET active = $2 WHERE id = $1`, id, active)
+		return err
+	})
+}
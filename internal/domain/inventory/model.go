@@ -44,6 +44,13 @@ type SupplyDetail struct {
 	Comment       string
 }
 
+// SupplyLine — строка поставки для ReceiveSupply.
+type SupplyLine struct {
+	MaterialID int64
+	Qty        float64 // в единицах расхода
+	UnitCost   float64
+}
+
 type SupplyBatch struct {
 	ID          int64
 	CreatedAt   time.Time
@@ -51,3 +58,15 @@ type SupplyBatch struct {
 	WarehouseID int64
 	Comment     string
 }
+
+// StockValue — остаток материала на складе с оценкой по текущей цене.
+type StockValue struct {
+	WarehouseID   int64
+	WarehouseName string
+	BrandName     string
+	MaterialName  string
+	Unit          string
+	Qty           float64
+	Price         float64 // ₽ за единицу
+	Value         float64 // Qty × Price
+}
@@ -73,8 +73,56 @@ func (r *Repo) ReceiveWithCost(
 	}
 	defer func() { _ = tx.Rollback(ctx) }()
 
+	if err := receiveTx(ctx, tx, actorID, warehouseID, materialID, qty, unitCost, note, comment, batchID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ReceiveSupply проводит поставку целиком в одной транзакции: создаёт batch и принимает все строки.
+// При ошибке в любой строке ничего не проводится.
+func (r *Repo) ReceiveSupply(
+	ctx context.Context,
+	actorID, warehouseID int64,
+	lines []SupplyLine,
+	note string, comment string,
+) (int64, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var batchID int64
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO supply_batches (added_by, warehouse_id, comment)
+		VALUES ($1,$2,$3)
+		RETURNING id
+	`, actorID, warehouseID, comment).Scan(&batchID); err != nil {
+		return 0, err
+	}
+	for _, l := range lines {
+		if l.Qty <= 0 {
+			return 0, fmt.Errorf("material %d: qty must be > 0", l.MaterialID)
+		}
+		if err := receiveTx(ctx, tx, actorID, warehouseID, l.MaterialID, l.Qty, l.UnitCost, note, comment, batchID); err != nil {
+			return 0, fmt.Errorf("material %d: %w", l.MaterialID, err)
+		}
+	}
+	return batchID, tx.Commit(ctx)
+}
+
+// receiveTx — приход материала с записью стоимости поставки в транзакции tx.
+func receiveTx(
+	ctx context.Context,
+	tx pgx.Tx,
+	actorID, warehouseID, materialID int64,
+	qty float64, unitCost float64,
+	note string, comment string,
+	batchID int64,
+) error {
 	// balances
-	_, err = tx.Exec(ctx, `
+	_, err := tx.Exec(ctx, `
 		INSERT INTO balances (warehouse_id, material_id, qty)
 		VALUES ($1,$2,$3)
 		ON CONFLICT (warehouse_id, material_id)
@@ -106,11 +154,7 @@ func (r *Repo) ReceiveWithCost(
 		INSERT INTO supplies (added_by, warehouse_id, material_id, qty, unit_cost, total_cost, comment, batch_id)
 		VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
 	`, actorID, warehouseID, materialID, qty, unitCost, total, comment, batchVal)
-	if err != nil {
-		return err
-	}
-
-	return tx.Commit(ctx)
+	return err
 }
 
 func (r *Repo) Consume(ctx context.Context, actorID, warehouseID, materialID int64, qty float64, note string) error {
@@ -237,3 +281,49 @@ func (r *Repo) InitMaterialForWarehouse(ctx context.Context, warehouseID, materi
     `, warehouseID, materialID)
 	return err
 }
+
+// ListStockValuation возвращает ненулевые остатки активных складов с оценкой по текущим ценам материалов
+// (с учётом истории цен: запланированные цены ещё не действуют).
+func (r *Repo) ListStockValuation(ctx context.Context) ([]StockValue, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT
+			w.id,
+			w.name,
+			COALESCE(br.name, '') AS brand,
+			m.name,
+			m.unit,
+			b.qty::float8,
+			p.price::float8,
+			(b.qty * p.price)::float8 AS value
+		FROM balances b
+		JOIN warehouses w ON w.id = b.warehouse_id
+		JOIN materials m  ON m.id = b.material_id
+		CROSS JOIN LATERAL (SELECT material_price_at(m.id, now()) AS price) p
+		LEFT JOIN material_brands br ON br.id = m.brand_id
+		WHERE w.active = TRUE AND b.qty <> 0
+		ORDER BY w.name, br.name, m.name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []StockValue
+	for rows.Next() {
+		var v StockValue
+		if err := rows.Scan(
+			&v.WarehouseID,
+			&v.WarehouseName,
+			&v.BrandName,
+			&v.MaterialName,
+			&v.Unit,
+			&v.Qty,
+			&v.Price,
+			&v.Value,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
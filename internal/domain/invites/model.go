@@ -0,0 +1,61 @@
+package invites
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Spok95/beauty-bot/internal/domain/users"
+)
+
+var (
+	ErrNotFound  = errors.New("приглашение не найдено")
+	ErrRevoked   = errors.New("приглашение отозвано")
+	ErrExpired   = errors.New("срок действия приглашения истёк")
+	ErrExhausted = errors.New("приглашение уже использовано")
+)
+
+// Invite — приглашение для регистрации по ссылке /start <code>.
+type Invite struct {
+	ID          int64
+	Code        string
+	Role        users.Role
+	Place       string // ''|hall|cabinet — помещение по умолчанию для профиля
+	WarehouseID *int64 // склад по умолчанию для профиля
+	AutoApprove bool   // сразу одобрять, без заявки админам
+	MaxUses     int    // 0 — без ограничения
+	UsedCount   int
+	ExpiresAt   *time.Time
+	RevokedAt   *time.Time
+	CreatedBy   int64
+	CreatedAt   time.Time
+}
+
+// Check — можно ли воспользоваться приглашением в момент now.
+func (i *Invite) Check(now time.Time) error {
+	if err := i.CheckReuse(now); err != nil {
+		return err
+	}
+	if i.MaxUses > 0 && i.UsedCount >= i.MaxUses {
+		return ErrExhausted
+	}
+	return nil
+}
+
+// CheckReuse — можно ли повторно зарегистрироваться по приглашению, которое пользователь
+// уже использовал: лимит использований не проверяется, отзыв и срок — да.
+func (i *Invite) CheckReuse(now time.Time) error {
+	switch {
+	case i.RevokedAt != nil:
+		return ErrRevoked
+	case i.ExpiresAt != nil && !now.Before(*i.ExpiresAt):
+		return ErrExpired
+	}
+	return nil
+}
+
+// Use — регистрация по приглашению.
+type Use struct {
+	UserID int64
+	Name   string
+	UsedAt time.Time
+}
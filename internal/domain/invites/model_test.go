@@ -0,0 +1,37 @@
+package invites
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		inv       Invite
+		want      error
+		wantReuse error
+	}{
+		{name: "без ограничений", inv: Invite{}},
+		{name: "лимит не исчерпан", inv: Invite{MaxUses: 3, UsedCount: 2, ExpiresAt: &future}},
+		{name: "лимит исчерпан", inv: Invite{MaxUses: 1, UsedCount: 1}, want: ErrExhausted},
+		{name: "истёк", inv: Invite{ExpiresAt: &past}, want: ErrExpired, wantReuse: ErrExpired},
+		{name: "истекает ровно сейчас", inv: Invite{ExpiresAt: &now}, want: ErrExpired, wantReuse: ErrExpired},
+		{name: "отозван", inv: Invite{RevokedAt: &past, ExpiresAt: &future}, want: ErrRevoked, wantReuse: ErrRevoked},
+		{name: "отозван и исчерпан", inv: Invite{RevokedAt: &past, MaxUses: 1, UsedCount: 1}, want: ErrRevoked, wantReuse: ErrRevoked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.inv.Check(now); !errors.Is(err, tt.want) {
+				t.Errorf("Check() = %v, want %v", err, tt.want)
+			}
+			if err := tt.inv.CheckReuse(now); !errors.Is(err, tt.wantReuse) {
+				t.Errorf("CheckReuse() = %v, want %v", err, tt.wantReuse)
+			}
+		})
+	}
+}
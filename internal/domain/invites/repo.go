@@ -0,0 +1,211 @@
+package invites
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Spok95/beauty-bot/internal/domain/audit"
+	"github.com/Spok95/beauty-bot/internal/domain/users"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repo struct{ pool *pgxpool.Pool }
+
+func NewRepo(pool *pgxpool.Pool) *Repo { return &Repo{pool: pool} }
+
+const inviteColumns = `
+	id, code, role, place, warehouse_id, auto_approve, max_uses, used_count,
+	expires_at, revoked_at, COALESCE(created_by, 0), created_at`
+
+func scanInvite(row pgx.Row) (*Invite, error) {
+	var i Invite
+	if err := row.Scan(
+		&i.ID, &i.Code, &i.Role, &i.Place, &i.WarehouseID, &i.AutoApprove, &i.MaxUses, &i.UsedCount,
+		&i.ExpiresAt, &i.RevokedAt, &i.CreatedBy, &i.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// newCode — случайный код для deep link (в /start допустимы только [A-Za-z0-9_-]).
+func newCode() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf)), nil
+}
+
+// Create сохраняет приглашение; код генерируется автоматически.
+func (r *Repo) Create(ctx context.Context, in Invite) (*Invite, error) {
+	code, err := newCode()
+	if err != nil {
+		return nil, err
+	}
+	var createdBy any
+	if in.CreatedBy > 0 {
+		createdBy = in.CreatedBy
+	}
+	var out *Invite
+	err = audit.TrackCreate(ctx, r.pool, audit.EntityInvite, func(tx pgx.Tx) (int64, error) {
+		i, err := scanInvite(tx.QueryRow(ctx, `
+			INSERT INTO invites (code, role, place, warehouse_id, auto_approve, max_uses, expires_at, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING`+inviteColumns,
+			code, in.Role, in.Place, in.WarehouseID, in.AutoApprove, in.MaxUses, in.ExpiresAt, createdBy,
+		))
+		if err != nil {
+			return 0, err
+		}
+		out = i
+		return i.ID, nil
+	})
+	return out, err
+}
+
+// GetByID возвращает приглашение или ErrNotFound.
+func (r *Repo) GetByID(ctx context.Context, id int64) (*Invite, error) {
+	i, err := scanInvite(r.pool.QueryRow(ctx, `SELECT`+inviteColumns+` FROM invites WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return i, err
+}
+
+// GetByCode возвращает приглашение по коду из ссылки или ErrNotFound.
+func (r *Repo) GetByCode(ctx context.Context, code string) (*Invite, error) {
+	i, err := scanInvite(r.pool.QueryRow(ctx, `SELECT`+inviteColumns+` FROM invites WHERE code = $1`,
+		strings.ToLower(strings.TrimSpace(code))))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return i, err
+}
+
+// ListActive — неотозванные, неистёкшие и неисчерпанные приглашения, новые сверху.
+func (r *Repo) ListActive(ctx context.Context, limit int) ([]Invite, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT`+inviteColumns+`
+		FROM invites
+		WHERE revoked_at IS NULL
+		  AND (expires_at IS NULL OR expires_at > now())
+		  AND (max_uses = 0 OR used_count < max_uses)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Invite
+	for rows.Next() {
+		i, err := scanInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *i)
+	}
+	return out, rows.Err()
+}
+
+// Revoke отзывает приглашение; уже зарегистрированных пользователей это не затрагивает.
+func (r *Repo) Revoke(ctx context.Context, id int64) error {
+	return audit.Track(ctx, r.pool, audit.EntityInvite, id, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `UPDATE invites SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`, id)
+		return err
+	})
+}
+
+// Redeem засчитывает использование приглашения пользователем в момент now (часы салона).
+// Повторное использование тем же пользователем не увеличивает счётчик, но тоже невозможно
+// после отзыва или истечения срока. Засчитывается только для заявки в ожидании: одобрить
+// или отклонить уже рассмотренную нельзя, и использование сгорело бы впустую.
+// Ошибки — ErrNotFound/ErrRevoked/ErrExpired/ErrExhausted, users.ErrNotPending.
+func (r *Repo) Redeem(ctx context.Context, code string, userID int64, now time.Time) (*Invite, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	i, err := scanInvite(tx.QueryRow(ctx, `SELECT`+inviteColumns+` FROM invites WHERE code = $1 FOR UPDATE`,
+		strings.ToLower(strings.TrimSpace(code))))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var status users.Status
+	if err := tx.QueryRow(ctx, `SELECT status FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&status); err != nil {
+		return nil, err
+	}
+	if status != users.StatusPending {
+		return nil, users.ErrNotPending
+	}
+
+	var already bool
+	if err := tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM invite_uses WHERE invite_id = $1 AND user_id = $2)
+	`, i.ID, userID).Scan(&already); err != nil {
+		return nil, err
+	}
+	if already {
+		// своё прошлое использование уже в счётчике, но отозванное или истёкшее
+		// приглашение не должно снова давать роль и автоподтверждение
+		if err := i.CheckReuse(now); err != nil {
+			return nil, err
+		}
+		return i, tx.Commit(ctx)
+	}
+
+	if err := i.Check(now); err != nil {
+		return nil, err
+	}
+
+	err = audit.TrackTx(ctx, tx, audit.EntityInvite, i.ID, func() error {
+		if _, err := tx.Exec(ctx, `INSERT INTO invite_uses (invite_id, user_id) VALUES ($1, $2)`, i.ID, userID); err != nil {
+			return err
+		}
+		return tx.QueryRow(ctx, `
+			UPDATE invites SET used_count = used_count + 1 WHERE id = $1 RETURNING used_count
+		`, i.ID).Scan(&i.UsedCount)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return i, tx.Commit(ctx)
+}
+
+// ListUses — кто зарегистрировался по приглашению.
+func (r *Repo) ListUses(ctx context.Context, inviteID int64) ([]Use, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT iu.user_id, COALESCE(u.username, ''), iu.used_at
+		FROM invite_uses iu
+		JOIN users u ON u.id = iu.user_id
+		WHERE iu.invite_id = $1
+		ORDER BY iu.used_at
+	`, inviteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Use
+	for rows.Next() {
+		var u Use
+		if err := rows.Scan(&u.UserID, &u.Name, &u.UsedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
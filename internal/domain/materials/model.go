@@ -1,14 +1,36 @@
 package materials
 
-import "time"
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
 
 type Unit string
 
 const (
 	UnitPcs Unit = "pcs"
 	UnitG   Unit = "g"
+	UnitMl  Unit = "ml"
+
+	// Единицы закупки (упаковки). Пересчитываются в единицу расхода через PurchaseFactor.
+	UnitPack   Unit = "pack"
+	UnitTube   Unit = "tube"
+	UnitBottle Unit = "bottle"
 )
 
+// Fractional — допускает ли единица дробное количество (г/мл — да, шт и упаковки — нет).
+func (u Unit) Fractional() bool {
+	return u == UnitG || u == UnitMl
+}
+
+// Packaging — единица упаковки, которую можно выбрать единицей закупки.
+func (u Unit) Packaging() bool {
+	return u == UnitPack || u == UnitTube || u == UnitBottle
+}
+
 type Material struct {
 	ID           int64
 	Name         string
@@ -19,6 +41,45 @@ type Material struct {
 	Active       bool
 	CreatedAt    time.Time
 	PricePerUnit float64 // ₽ за g / шт
+
+	PurchaseUnit   Unit    // единица закупки (pack/tube/bottle), пусто — закупаем в Unit
+	PurchaseFactor float64 // сколько Unit в одной PurchaseUnit (1 туба = 60 g)
+}
+
+// HasPurchaseUnit — задана ли отдельная единица закупки.
+func (m Material) HasPurchaseUnit() bool {
+	return m.PurchaseUnit != "" && m.PurchaseUnit != m.Unit && m.PurchaseFactor > 0
+}
+
+// ToUsage переводит количество в единицах закупки в единицы расхода.
+func (m Material) ToUsage(purchaseQty float64) float64 {
+	if !m.HasPurchaseUnit() {
+		return purchaseQty
+	}
+	return purchaseQty * m.PurchaseFactor
+}
+
+// ToPurchase переводит количество в единицах расхода в единицы закупки.
+func (m Material) ToPurchase(usageQty float64) float64 {
+	if !m.HasPurchaseUnit() {
+		return usageQty
+	}
+	return usageQty / m.PurchaseFactor
+}
+
+// Price — запись истории цен: цена действует с ValidFrom до следующей записи.
+type Price struct {
+	ID         int64
+	MaterialID int64
+	Price      float64
+	ValidFrom  time.Time
+	CreatedBy  int64 // users.id, 0 — неизвестно
+	CreatedAt  time.Time
+}
+
+// Scheduled — цена ещё не вступила в силу.
+func (p Price) Scheduled(now time.Time) bool {
+	return p.ValidFrom.After(now)
 }
 
 type Balance struct {
@@ -38,3 +99,148 @@ type AdminMaterialItem struct {
 	Active       bool
 	PricePerUnit float64
 }
+
+// Barcode — штрихкод материала; ID — строка material_barcodes (для удаления по кнопке).
+type Barcode struct {
+	ID   int64
+	Code string
+}
+
+// ErrBarcodeTaken — штрихкод уже привязан к другому материалу.
+var ErrBarcodeTaken = errors.New("штрихкод уже привязан к другому материалу")
+
+// NormalizeBarcode приводит введённый код к хранимому виду: без пробелов и дефисов, в верхнем регистре.
+func NormalizeBarcode(s string) string {
+	var sb strings.Builder
+	for _, r := range strings.TrimSpace(s) {
+		if unicode.IsSpace(r) || r == '-' {
+			continue
+		}
+		sb.WriteRune(unicode.ToUpper(r))
+	}
+	return sb.String()
+}
+
+// LooksLikeBarcode — похожа ли строка на штрихкод (EAN/UPC: только цифры, 8–14 знаков).
+func LooksLikeBarcode(s string) bool {
+	code := NormalizeBarcode(s)
+	if len(code) < 8 || len(code) > 14 {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// SearchOptions — параметры нечёткого поиска материалов.
+type SearchOptions struct {
+	WarehouseID int64 // 0 — по всем складам
+	UserID      int64 // users.id мастера: его недавний расход поднимает материал выше; 0 — без учёта
+	OnlyActive  bool
+	Limit       int // 0 — searchDefaultLimit
+	Offset      int // за концом выдачи — последняя страница
+}
+
+// SearchPage — номер страницы, которую вернул Search для запрошенной page:
+// если страница за концом выдачи (список изменился), это последняя страница.
+func SearchPage(page, pageSize, total int) int {
+	if page < 0 || total == 0 {
+		return 0
+	}
+	if last := (total - 1) / pageSize; page > last {
+		return last
+	}
+	return page
+}
+
+// NormalizeSearch приводит строку к виду для поиска (как SQL-функция search_norm):
+// нижний регистр, ё → е, без апострофов, кавычек и знаков препинания, пробелы схлопнуты.
+func NormalizeSearch(s string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		switch r {
+		case 'ё':
+			sb.WriteRune('е')
+		case '\'', '’', '`', '´', '"', '.', ',', '-', '_', '/', '(', ')':
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// Транслитерация для поиска: «лореаль» ↔ «loreal». Правила упрощённые — важна похожесть, а не точность.
+var cyrToLat = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ж': "zh", 'з': "z", 'и': "i",
+	'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s",
+	'т': "t", 'у': "u", 'ф': "f", 'х': "h", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "sch", 'ъ': "",
+	'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+}
+
+// latToCyr — сначала многобуквенные сочетания, затем одиночные буквы.
+var latToCyr = []struct{ lat, cyr string }{
+	{"sch", "щ"}, {"sh", "ш"}, {"ch", "ч"}, {"zh", "ж"}, {"kh", "х"}, {"ts", "ц"},
+	{"yu", "ю"}, {"ya", "я"}, {"yo", "е"}, {"ph", "ф"}, {"th", "т"}, {"ck", "к"}, {"ee", "и"}, {"oo", "у"},
+	{"a", "а"}, {"b", "б"}, {"c", "к"}, {"d", "д"}, {"e", "е"}, {"f", "ф"}, {"g", "г"}, {"h", "х"},
+	{"i", "и"}, {"j", "дж"}, {"k", "к"}, {"l", "л"}, {"m", "м"}, {"n", "н"}, {"o", "о"}, {"p", "п"},
+	{"q", "к"}, {"r", "р"}, {"s", "с"}, {"t", "т"}, {"u", "у"}, {"v", "в"}, {"w", "в"}, {"x", "кс"},
+	{"y", "й"}, {"z", "з"},
+}
+
+func translitToLat(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if lat, ok := cyrToLat[r]; ok {
+			sb.WriteString(lat)
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func translitToCyr(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		matched := false
+		for _, p := range latToCyr {
+			if strings.HasPrefix(s[i:], p.lat) {
+				sb.WriteString(p.cyr)
+				i += len(p.lat)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			sb.WriteRune(r)
+			i += size
+		}
+	}
+	return sb.String()
+}
+
+// SearchVariants — варианты запроса для поиска: как ввели, латиницей и кириллицей (без повторов).
+func SearchVariants(q string) []string {
+	norm := NormalizeSearch(q)
+	if norm == "" {
+		return nil
+	}
+	out := []string{norm}
+	for _, v := range []string{translitToLat(norm), translitToCyr(norm)} {
+		dup := false
+		for _, o := range out {
+			if o == v {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,90 @@
+package materials
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnitConversion(t *testing.T) {
+	tube := Material{Unit: UnitG, PurchaseUnit: UnitTube, PurchaseFactor: 60}
+	tests := []struct {
+		name     string
+		m        Material
+		purchase float64
+		usage    float64
+	}{
+		{name: "туба 60 г", m: tube, purchase: 2, usage: 120},
+		{name: "часть тубы", m: tube, purchase: 0.5, usage: 30},
+		{name: "без единицы закупки", m: Material{Unit: UnitPcs}, purchase: 3, usage: 3},
+		{name: "единица закупки совпадает с расходом", m: Material{Unit: UnitMl, PurchaseUnit: UnitMl, PurchaseFactor: 100}, purchase: 5, usage: 5},
+		{name: "нулевой коэффициент", m: Material{Unit: UnitG, PurchaseUnit: UnitPack}, purchase: 4, usage: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.m.ToUsage(tt.purchase); got != tt.usage {
+				t.Errorf("ToUsage(%v) = %v, want %v", tt.purchase, got, tt.usage)
+			}
+			if got := tt.m.ToPurchase(tt.usage); got != tt.purchase {
+				t.Errorf("ToPurchase(%v) = %v, want %v", tt.usage, got, tt.purchase)
+			}
+		})
+	}
+}
+
+func TestNormalizeSearch(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"L'Oréal", "loréal"},
+		{"  Ёлочка  ", "елочка"},
+		{"Крем (для рук), 50 мл.", "крем для рук 50 мл"},
+		{"L' Oreal   Paris", "l oreal paris"},
+		{"a - b", "a b"},
+		{"\t\n", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeSearch(tt.in); got != tt.want {
+			t.Errorf("NormalizeSearch(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSearchVariants(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"Лореаль", []string{"лореаль", "loreal"}},
+		{"loreal", []string{"loreal", "лореал"}},
+		{"shampoo", []string{"shampoo", "шампу"}},
+		{"123", []string{"123"}},
+		{"  ", nil},
+	}
+	for _, tt := range tests {
+		if got := SearchVariants(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SearchVariants(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSearchPage(t *testing.T) {
+	tests := []struct {
+		name                  string
+		page, pageSize, total int
+		want                  int
+	}{
+		{name: "внутри выдачи", page: 1, pageSize: 10, total: 25, want: 1},
+		{name: "последняя неполная", page: 2, pageSize: 10, total: 25, want: 2},
+		{name: "за концом выдачи", page: 5, pageSize: 10, total: 25, want: 2},
+		{name: "ровно кратно странице", page: 3, pageSize: 10, total: 30, want: 2},
+		{name: "ничего не найдено", page: 3, pageSize: 10},
+		{name: "отрицательная", page: -1, pageSize: 10, total: 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SearchPage(tt.page, tt.pageSize, tt.total); got != tt.want {
+				t.Errorf("SearchPage(%d, %d, %d) = %d, want %d", tt.page, tt.pageSize, tt.total, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,8 +2,9 @@ package materials
 
 import (
 	"context"
-	"strings"
+	"time"
 
+	"github.com/Spok95/beauty-bot/internal/domain/audit"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -15,23 +16,25 @@ func NewRepo(pool *pgxpool.Pool) *Repo { return &Repo{pool: pool} }
 /* Materials CRUD */
 
 func (r *Repo) Create(ctx context.Context, name string, categoryID, brandID int64, unit Unit) (*Material, error) {
-	row := r.pool.QueryRow(ctx, `
-		INSERT INTO materials (name, category_id, brand_id, unit, active)
-		VALUES ($1,$2,$3,$4,TRUE)
-		RETURNING id, name, category_id, brand_id, unit, active, created_at, price_per_unit
-	`, name, categoryID, brandID, unit)
-
 	var m Material
-	if err := row.Scan(
-		&m.ID,
-		&m.Name,
-		&m.CategoryID,
-		&m.BrandID,
-		&m.Unit,
-		&m.Active,
-		&m.CreatedAt,
-		&m.PricePerUnit,
-	); err != nil {
+	err := audit.TrackCreate(ctx, r.pool, audit.EntityMaterial, func(tx pgx.Tx) (int64, error) {
+		err := tx.QueryRow(ctx, `
+			INSERT INTO materials (name, category_id, brand_id, unit, active)
+			VALUES ($1,$2,$3,$4,TRUE)
+			RETURNING id, name, category_id, brand_id, unit, active, created_at, price_per_unit
+		`, name, categoryID, brandID, unit).Scan(
+			&m.ID,
+			&m.Name,
+			&m.CategoryID,
+			&m.BrandID,
+			&m.Unit,
+			&m.Active,
+			&m.CreatedAt,
+			&m.PricePerUnit,
+		)
+		return m.ID, err
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &m, nil
@@ -39,7 +42,8 @@ func (r *Repo) Create(ctx context.Context, name string, categoryID, brandID int6
 
 func (r *Repo) GetByID(ctx context.Context, id int64) (*Material, error) {
 	row := r.pool.QueryRow(ctx, `
-		SELECT m.id, m.name, m.category_id, m.brand_id, COALESCE(b.name,''), m.unit, m.active, m.created_at, m.price_per_unit
+		SELECT m.id, m.name, m.category_id, m.brand_id, COALESCE(b.name,''), m.unit, m.active, m.created_at, material_price_at(m.id, now()),
+		       m.purchase_unit, m.purchase_factor::float8
 		FROM materials m
 		LEFT JOIN material_brands b ON b.id = m.brand_id
 		WHERE m.id = $1
@@ -55,6 +59,8 @@ func (r *Repo) GetByID(ctx context.Context, id int64) (*Material, error) {
 		&m.Active,
 		&m.CreatedAt,
 		&m.PricePerUnit,
+		&m.PurchaseUnit,
+		&m.PurchaseFactor,
 	); err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil
@@ -64,37 +70,156 @@ func (r *Repo) GetByID(ctx context.Context, id int64) (*Material, error) {
 	return &m, nil
 }
 
+// GetByBarcode ищет материал по штрихкоду. nil, nil — код не найден.
+func (r *Repo) GetByBarcode(ctx context.Context, code string) (*Material, error) {
+	var id int64
+	err := r.pool.QueryRow(ctx, `
+		SELECT material_id FROM material_barcodes WHERE code = $1
+	`, NormalizeBarcode(code)).Scan(&id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return r.GetByID(ctx, id)
+}
+
+// ListBarcodes — штрихкоды материала в порядке добавления.
+func (r *Repo) ListBarcodes(ctx context.Context, materialID int64) ([]Barcode, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, code FROM material_barcodes WHERE material_id = $1 ORDER BY id
+	`, materialID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Barcode
+	for rows.Next() {
+		var bc Barcode
+		if err := rows.Scan(&bc.ID, &bc.Code); err != nil {
+			return nil, err
+		}
+		out = append(out, bc)
+	}
+	return out, rows.Err()
+}
+
+// ListBarcodesByWarehouse — штрихкоды всех материалов склада (для Excel-выгрузок).
+func (r *Repo) ListBarcodesByWarehouse(ctx context.Context, warehouseID int64) (map[int64][]string, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT mb.material_id, mb.code
+		FROM material_barcodes mb
+		JOIN materials m ON m.id = mb.material_id
+		JOIN warehouse_material_categories wmc
+		  ON wmc.category_id = m.category_id AND wmc.warehouse_id = $1
+		ORDER BY mb.material_id, mb.id
+	`, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[int64][]string)
+	for rows.Next() {
+		var matID int64
+		var code string
+		if err := rows.Scan(&matID, &code); err != nil {
+			return nil, err
+		}
+		out[matID] = append(out[matID], code)
+	}
+	return out, rows.Err()
+}
+
+// AddBarcode привязывает штрихкод к материалу.
+// Повторная привязка к тому же материалу — не ошибка; к другому — ErrBarcodeTaken.
+func (r *Repo) AddBarcode(ctx context.Context, materialID int64, code string) error {
+	code = NormalizeBarcode(code)
+	return audit.Track(ctx, r.pool, audit.EntityMaterial, materialID, func(tx pgx.Tx) error {
+		var owner int64
+		err := tx.QueryRow(ctx, `
+			INSERT INTO material_barcodes (material_id, code)
+			VALUES ($1, $2)
+			ON CONFLICT (code) DO UPDATE SET code = EXCLUDED.code
+			RETURNING material_id
+		`, materialID, code).Scan(&owner)
+		if err != nil {
+			return err
+		}
+		if owner != materialID {
+			return ErrBarcodeTaken
+		}
+		return nil
+	})
+}
+
+// DeleteBarcode отвязывает штрихкод (строку material_barcodes) от материала.
+// false — кода уже нет: его удалили или перепривязали.
+func (r *Repo) DeleteBarcode(ctx context.Context, materialID, barcodeID int64) (bool, error) {
+	var deleted bool
+	err := audit.Track(ctx, r.pool, audit.EntityMaterial, materialID, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+			DELETE FROM material_barcodes WHERE id = $1 AND material_id = $2
+		`, barcodeID, materialID)
+		deleted = tag.RowsAffected() > 0
+		return err
+	})
+	return deleted, err
+}
+
 func (r *Repo) UpdateName(ctx context.Context, id int64, name string) (*Material, error) {
-	row := r.pool.QueryRow(ctx, `
-		UPDATE materials SET name=$2 WHERE id=$1
-		RETURNING id, name, category_id, brand_id, unit, active, created_at
-	`, id, name)
 	var m Material
-	if err := row.Scan(&m.ID, &m.Name, &m.CategoryID, &m.BrandID, &m.Unit, &m.Active, &m.CreatedAt); err != nil {
+	err := audit.Track(ctx, r.pool, audit.EntityMaterial, id, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, `
+			UPDATE materials SET name=$2 WHERE id=$1
+			RETURNING id, name, category_id, brand_id, unit, active, created_at
+		`, id, name).Scan(&m.ID, &m.Name, &m.CategoryID, &m.BrandID, &m.Unit, &m.Active, &m.CreatedAt)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &m, nil
 }
 
 func (r *Repo) UpdateUnit(ctx context.Context, id int64, unit Unit) (*Material, error) {
-	row := r.pool.QueryRow(ctx, `
-		UPDATE materials SET unit=$2 WHERE id=$1
-		RETURNING id, name, category_id, brand_id, unit, active, created_at
-	`, id, string(unit))
 	var m Material
-	if err := row.Scan(&m.ID, &m.Name, &m.CategoryID, &m.BrandID, &m.Unit, &m.Active, &m.CreatedAt); err != nil {
+	err := audit.Track(ctx, r.pool, audit.EntityMaterial, id, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, `
+			UPDATE materials SET unit=$2 WHERE id=$1
+			RETURNING id, name, category_id, brand_id, unit, active, created_at
+		`, id, string(unit)).Scan(&m.ID, &m.Name, &m.CategoryID, &m.BrandID, &m.Unit, &m.Active, &m.CreatedAt)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &m, nil
 }
 
+// UpdatePurchaseUnit задаёт единицу закупки и коэффициент пересчёта в единицу расхода.
+// Пустая unit — закупаем в той же единице, что и расходуем.
+func (r *Repo) UpdatePurchaseUnit(ctx context.Context, id int64, unit Unit, factor float64) error {
+	if unit == "" {
+		factor = 1
+	}
+	return audit.Track(ctx, r.pool, audit.EntityMaterial, id, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			UPDATE materials SET purchase_unit=$2, purchase_factor=$3 WHERE id=$1
+		`, id, string(unit), factor)
+		return err
+	})
+}
+
 func (r *Repo) SetActive(ctx context.Context, id int64, active bool) (*Material, error) {
-	row := r.pool.QueryRow(ctx, `
-		UPDATE materials SET active=$2 WHERE id=$1
-		RETURNING id, name, category_id, brand_id, unit, active, created_at
-	`, id, active)
 	var m Material
-	if err := row.Scan(&m.ID, &m.Name, &m.CategoryID, &m.BrandID, &m.Unit, &m.Active, &m.CreatedAt); err != nil {
+	err := audit.Track(ctx, r.pool, audit.EntityMaterial, id, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, `
+			UPDATE materials SET active=$2 WHERE id=$1
+			RETURNING id, name, category_id, brand_id, unit, active, created_at
+		`, id, active).Scan(&m.ID, &m.Name, &m.CategoryID, &m.BrandID, &m.Unit, &m.Active, &m.CreatedAt)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &m, nil
@@ -102,7 +227,7 @@ func (r *Repo) SetActive(ctx context.Context, id int64, active bool) (*Material,
 
 func (r *Repo) List(ctx context.Context, onlyActive bool) ([]Material, error) {
 	q := `
-		SELECT m.id, m.name, m.category_id, m.brand_id, COALESCE(b.name,''), m.unit, m.active, m.created_at, m.price_per_unit
+		SELECT m.id, m.name, m.category_id, m.brand_id, COALESCE(b.name,''), m.unit, m.active, m.created_at, material_price_at(m.id, now())
 		FROM materials m
 		LEFT JOIN material_brands b ON b.id = m.brand_id
 	`
@@ -149,7 +274,7 @@ func (r *Repo) ListForAdminGrouped(ctx context.Context, onlyActive bool) ([]Admi
 			COALESCE(b.name, '') AS brand,
 			m.unit,
 			m.active,
-			m.price_per_unit
+			material_price_at(m.id, now())
 		FROM materials m
 		LEFT JOIN material_categories c ON c.id = m.category_id
 		LEFT JOIN material_brands b ON b.id = m.brand_id
@@ -192,8 +317,24 @@ type MatWithBal struct {
 	Name       string
 	Brand      string
 	Unit       Unit
-	Balance    int64
+	Balance    float64 // остаток может быть дробным (г/мл)
 	CategoryID int64
+
+	PurchaseUnit   Unit
+	PurchaseFactor float64
+}
+
+// AsMaterial — минимальная карточка материала для пересчёта единиц.
+func (it MatWithBal) AsMaterial() Material {
+	return Material{
+		ID:             it.ID,
+		Name:           it.Name,
+		Brand:          it.Brand,
+		Unit:           it.Unit,
+		CategoryID:     it.CategoryID,
+		PurchaseUnit:   it.PurchaseUnit,
+		PurchaseFactor: it.PurchaseFactor,
+	}
 }
 
 func (r *Repo) ListWithBalanceByWarehouse(ctx context.Context, warehouseID int64) ([]MatWithBal, error) {
@@ -204,7 +345,9 @@ func (r *Repo) ListWithBalanceByWarehouse(ctx context.Context, warehouseID int64
 			COALESCE(br.name, '') AS brand,
 			m.unit,
 			COALESCE(bal.qty, 0) AS qty,
-			m.category_id
+			m.category_id,
+			m.purchase_unit,
+			m.purchase_factor::float8
 		FROM materials m
 		INNER JOIN warehouse_material_categories wmc
 			ON wmc.category_id = m.category_id
@@ -232,6 +375,8 @@ func (r *Repo) ListWithBalanceByWarehouse(ctx context.Context, warehouseID int64
 			&it.Unit,
 			&it.Balance,
 			&it.CategoryID,
+			&it.PurchaseUnit,
+			&it.PurchaseFactor,
 		); err != nil {
 			return nil, err
 		}
@@ -254,21 +399,71 @@ func (r *Repo) GetBalance(ctx context.Context, warehouseID, materialID int64) (f
 	return q, nil
 }
 
+// UpdatePrice меняет цену материала с текущего момента и записывает её в историю цен.
 func (r *Repo) UpdatePrice(ctx context.Context, id int64, price float64) (*Material, error) {
-	row := r.pool.QueryRow(ctx, `
-		UPDATE materials SET price_per_unit=$2
-		WHERE id=$1
-		RETURNING id, name, category_id, brand_id, unit, active, created_at, price_per_unit
-	`, id, price)
 	var m Material
-	if err := row.Scan(&m.ID, &m.Name, &m.CategoryID, &m.BrandID, &m.Unit, &m.Active, &m.CreatedAt, &m.PricePerUnit); err != nil {
+	err := audit.Track(ctx, r.pool, audit.EntityMaterial, id, func(tx pgx.Tx) error {
+		if err := insertPrice(ctx, tx, id, price, nil); err != nil {
+			return err
+		}
+		return tx.QueryRow(ctx, `
+			UPDATE materials SET price_per_unit=$2
+			WHERE id=$1
+			RETURNING id, name, category_id, brand_id, unit, active, created_at, price_per_unit
+		`, id, price).Scan(&m.ID, &m.Name, &m.CategoryID, &m.BrandID, &m.Unit, &m.Active, &m.CreatedAt, &m.PricePerUnit)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &m, nil
 }
 
+// SchedulePrice планирует цену с момента validFrom; повторное планирование на то же время
+// заменяет цену. Момент в прошлом или сейчас — обычное изменение цены (UpdatePrice).
+func (r *Repo) SchedulePrice(ctx context.Context, id int64, price float64, validFrom time.Time) error {
+	if !validFrom.After(time.Now()) {
+		_, err := r.UpdatePrice(ctx, id, price)
+		return err
+	}
+	return audit.Track(ctx, r.pool, audit.EntityMaterial, id, func(tx pgx.Tx) error {
+		return insertPrice(ctx, tx, id, price, &validFrom)
+	})
+}
+
+// insertPrice добавляет запись истории цен; validFrom = nil — с текущего момента.
+func insertPrice(ctx context.Context, tx pgx.Tx, id int64, price float64, validFrom *time.Time) error {
+	actorID, _ := audit.ActorFrom(ctx)
+	var createdBy any
+	if actorID > 0 {
+		createdBy = actorID
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO material_prices (material_id, price, valid_from, created_by)
+		VALUES ($1, $2, COALESCE($3, now()), $4)
+		ON CONFLICT (material_id, valid_from) DO UPDATE
+		SET price = EXCLUDED.price, created_by = EXCLUDED.created_by, created_at = now()
+	`, id, price, validFrom, createdBy)
+	return err
+}
+
+// CancelScheduledPrice удаляет ещё не вступившую в силу цену.
+func (r *Repo) CancelScheduledPrice(ctx context.Context, materialID, priceID int64) error {
+	return audit.Track(ctx, r.pool, audit.EntityMaterial, materialID, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			DELETE FROM material_prices WHERE id = $1 AND material_id = $2 AND valid_from > now()
+		`, priceID, materialID)
+		return err
+	})
+}
+
+// GetPrice — текущая цена материала.
 func (r *Repo) GetPrice(ctx context.Context, id int64) (float64, error) {
-	row := r.pool.QueryRow(ctx, `SELECT price_per_unit FROM materials WHERE id=$1`, id)
+	return r.GetPriceAt(ctx, id, time.Now())
+}
+
+// GetPriceAt — цена материала, действовавшая на момент at.
+func (r *Repo) GetPriceAt(ctx context.Context, id int64, at time.Time) (float64, error) {
+	row := r.pool.QueryRow(ctx, `SELECT material_price_at(id, $2) FROM materials WHERE id=$1`, id, at)
 	var p float64
 	if err := row.Scan(&p); err != nil {
 		return 0, err
@@ -276,43 +471,27 @@ func (r *Repo) GetPrice(ctx context.Context, id int64) (float64, error) {
 	return p, nil
 }
 
-// SearchByName ищет материалы по части названия/бренда, без учёта регистра.
-func (r *Repo) SearchByName(ctx context.Context, q string, onlyActive bool) ([]Material, error) {
-	q = strings.TrimSpace(q)
-	if q == "" {
-		return nil, nil
-	}
-	like := "%" + strings.ToLower(q) + "%"
-
-	base := `
-		SELECT m.id, m.name, m.category_id, m.brand_id, COALESCE(b.name,''), m.unit, m.active, m.created_at, m.price_per_unit
-		FROM materials m
-		LEFT JOIN material_brands b ON b.id = m.brand_id
-		WHERE LOWER(m.name) LIKE $1 OR LOWER(b.name) LIKE $1
-	`
-
-	var rows pgx.Rows
-	var err error
-	if onlyActive {
-		rows, err = r.pool.Query(ctx, base+` AND m.active = TRUE ORDER BY b.name, m.name`, like)
-	} else {
-		rows, err = r.pool.Query(ctx, base+` ORDER BY b.name, m.name`, like)
-	}
+// ListPrices — история цен материала, включая запланированные, новые сверху.
+func (r *Repo) ListPrices(ctx context.Context, id int64, limit int) ([]Price, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, material_id, price, valid_from, COALESCE(created_by, 0), created_at
+		FROM material_prices
+		WHERE material_id = $1
+		ORDER BY valid_from DESC
+		LIMIT $2
+	`, id, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var out []Material
+	var out []Price
 	for rows.Next() {
-		var m Material
-		if err := rows.Scan(
-			&m.ID, &m.Name, &m.CategoryID, &m.BrandID, &m.Brand,
-			&m.Unit, &m.Active, &m.CreatedAt, &m.PricePerUnit,
-		); err != nil {
+		var p Price
+		if err := rows.Scan(&p.ID, &p.MaterialID, &p.Price, &p.ValidFrom, &p.CreatedBy, &p.CreatedAt); err != nil {
 			return nil, err
 		}
-		out = append(out, m)
+		out = append(out, p)
 	}
 	return out, rows.Err()
 }
@@ -355,7 +534,7 @@ func (r *Repo) ListByCategoryAndBrand(ctx context.Context, categoryID int64, bra
 		       m.unit,
 		       m.active,
 		       m.created_at,
-		       m.price_per_unit
+		       material_price_at(m.id, now())
 		FROM materials m
 		LEFT JOIN material_brands b ON b.id = m.brand_id
 		WHERE m.category_id = $1
@@ -390,7 +569,7 @@ func (r *Repo) ListByCategoryAndBrand(ctx context.Context, categoryID int64, bra
 
 func (r *Repo) ListByBrand(ctx context.Context, brandID int64) ([]Material, error) {
 	rows, err := r.pool.Query(ctx, `
-		SELECT m.id, m.name, m.category_id, m.brand_id, COALESCE(b.name,''), m.unit, m.active, m.created_at, m.price_per_unit
+		SELECT m.id, m.name, m.category_id, m.brand_id, COALESCE(b.name,''), m.unit, m.active, m.created_at, material_price_at(m.id, now())
 		FROM materials m
 		LEFT JOIN material_brands b ON b.id = m.brand_id
 		WHERE m.brand_id = $1
@@ -442,47 +621,115 @@ func (r *Repo) IsMaterialAllowedInWarehouse(ctx context.Context, warehouseID, ma
 	return ok, err
 }
 
-func (r *Repo) SearchByNameInWarehouse(ctx context.Context, warehouseID int64, q string, onlyActive bool) ([]Material, error) {
-	q = strings.TrimSpace(q)
-	if q == "" {
-		return nil, nil
-	}
-
-	like := "%" + strings.ToLower(q) + "%"
-
-	sql := `
-		SELECT
-			m.id,
-			m.name,
-			m.category_id,
-			m.brand_id,
-			COALESCE(b.name, ''),
-			m.unit,
-			m.active,
-			m.created_at,
-			m.price_per_unit
-		FROM materials m
-		INNER JOIN warehouse_material_categories wmc
-			ON wmc.category_id = m.category_id
-			AND wmc.warehouse_id = $1
-		LEFT JOIN material_brands b
-			ON b.id = m.brand_id
-		WHERE (LOWER(m.name) LIKE $2 OR LOWER(b.name) LIKE $2)
-	`
+const (
+	searchDefaultLimit = 50
+	// порог похожести pg_trgm, ниже — считаем, что не нашли; совпадает с pg_trgm.similarity_threshold
+	// по умолчанию, поэтому предварительный отбор операторами % идёт по тем же правилам
+	searchMinScore = 0.3
+)
 
-	if onlyActive {
-		sql += ` AND m.active = TRUE`
+// Search — нечёткий поиск материалов по названию, бренду и синонимам бренда.
+// Запрос сравнивается в нескольких вариантах (как ввели, латиницей, кириллицей) по триграммам;
+// точное вхождение подстроки всегда проходит. Материалы, которые мастер недавно расходовал,
+// поднимаются выше. Кандидаты отбираются по триграммным индексам (операторы %, <% и LIKE),
+// оценивается только этот отбор. Возвращает страницу (opt.Limit, opt.Offset) и общее число найденных;
+// смещение за концом выдачи сдвигается на последнюю страницу (см. SearchPage).
+func (r *Repo) Search(ctx context.Context, q string, opt SearchOptions) ([]Material, int, error) {
+	variants := SearchVariants(q)
+	if len(variants) == 0 {
+		return nil, 0, nil
+	}
+	limit := opt.Limit
+	if limit <= 0 {
+		limit = searchDefaultLimit
+	}
+	offset := opt.Offset
+	if offset < 0 {
+		offset = 0
 	}
 
-	sql += ` ORDER BY b.name, m.name`
-
-	rows, err := r.pool.Query(ctx, sql, warehouseID, like)
+	rows, err := r.pool.Query(ctx, `
+		WITH q AS (
+			SELECT v, '%' || replace(replace(v, '\', '\\'), '%', '\%') || '%' AS pattern
+			FROM unnest($1::text[]) AS v
+		),
+		candidates AS (
+			SELECT m.id
+			FROM materials m, q
+			WHERE search_norm(m.name) % q.v
+			   OR q.v <% search_norm(m.name)
+			   OR search_norm(m.name) LIKE q.pattern
+			UNION
+			SELECT m.id
+			FROM material_brands b
+			JOIN materials m ON m.brand_id = b.id, q
+			WHERE search_norm(b.name) % q.v
+			   OR q.v <% search_norm(b.name)
+			   OR search_norm(b.name) LIKE q.pattern
+			UNION
+			SELECT m.id
+			FROM material_brand_aliases a
+			JOIN materials m ON m.brand_id = a.brand_id, q
+			WHERE search_norm(a.alias) % q.v
+		),
+		recent AS (
+			SELECT ci.material_id, COUNT(*) AS cnt
+			FROM consumption_items ci
+			JOIN consumption_sessions cs ON cs.id = ci.session_id
+			WHERE $2::bigint > 0
+			  AND cs.user_id = $2
+			  AND cs.status <> 'canceled'
+			  AND cs.created_at > now() - interval '90 days'
+			GROUP BY ci.material_id
+		),
+		scored AS (
+			SELECT m.id,
+			       MAX(GREATEST(
+			           similarity(search_norm(m.name), q.v),
+			           similarity(search_norm(b.name), q.v),
+			           word_similarity(q.v, search_norm(COALESCE(b.name, '') || ' ' || m.name)),
+			           COALESCE((
+			               SELECT MAX(similarity(search_norm(a.alias), q.v))
+			               FROM material_brand_aliases a
+			               WHERE a.brand_id = m.brand_id
+			           ), 0),
+			           CASE WHEN strpos(search_norm(COALESCE(b.name, '') || ' ' || m.name), q.v) > 0 THEN 1 ELSE 0 END
+			       )) AS score
+			FROM candidates c
+			JOIN materials m ON m.id = c.id
+			LEFT JOIN material_brands b ON b.id = m.brand_id
+			CROSS JOIN q
+			WHERE ($3::bigint = 0 OR EXISTS (
+			          SELECT 1 FROM warehouse_material_categories wmc
+			          WHERE wmc.warehouse_id = $3 AND wmc.category_id = m.category_id
+			      ))
+			  AND (NOT $4::bool OR m.active)
+			GROUP BY m.id
+		),
+		matched AS (
+			SELECT s.id, s.score + LEAST(COALESCE(rc.cnt, 0) * 0.05, 0.3) AS rank
+			FROM scored s
+			LEFT JOIN recent rc ON rc.material_id = s.id
+			WHERE s.score >= $5
+		)
+		SELECT m.id, m.name, m.category_id, m.brand_id, COALESCE(b.name, ''), m.unit, m.active, m.created_at, material_price_at(m.id, now()),
+		       m.purchase_unit, m.purchase_factor::float8, (SELECT COUNT(*) FROM matched)
+		FROM matched s
+		JOIN materials m ON m.id = s.id
+		LEFT JOIN material_brands b ON b.id = m.brand_id
+		ORDER BY s.rank DESC, b.name, m.name, m.id
+		LIMIT $6::bigint
+		OFFSET LEAST($7::bigint, GREATEST((SELECT COUNT(*) FROM matched) - 1, 0) / $6::bigint * $6::bigint)
+	`, variants, opt.UserID, opt.WarehouseID, opt.OnlyActive, searchMinScore, limit, offset)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
-	var out []Material
+	var (
+		out   []Material
+		total int
+	)
 	for rows.Next() {
 		var m Material
 		if err := rows.Scan(
@@ -495,11 +742,13 @@ func (r *Repo) SearchByNameInWarehouse(ctx context.Context, warehouseID int64, q
 			&m.Active,
 			&m.CreatedAt,
 			&m.PricePerUnit,
+			&m.PurchaseUnit,
+			&m.PurchaseFactor,
+			&total,
 		); err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		out = append(out, m)
 	}
-
-	return out, rows.Err()
+	return out, total, rows.Err()
 }
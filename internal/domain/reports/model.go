@@ -0,0 +1,108 @@
+package reports
+
+import "time"
+
+// Kind — вид отчёта по расписанию; от него зависят периодичность и период данных.
+type Kind string
+
+const (
+	KindDailyRevenue  Kind = "daily_revenue"  // каждый день: выручка за вчера
+	KindWeeklyMasters Kind = "weekly_masters" // по понедельникам: отчёт по мастерам за прошлую неделю
+	KindMonthlyStock  Kind = "monthly_stock"  // 1-го числа: оценка остатков на складах
+	KindMonthlySubs   Kind = "monthly_subs"   // в последний день месяца: выполнение порогов абонементов
+)
+
+// Kinds — все виды отчётов в порядке отображения.
+var Kinds = []Kind{KindDailyRevenue, KindWeeklyMasters, KindMonthlyStock, KindMonthlySubs}
+
+// Schedule — подписка пользователя на отчёт.
+type Schedule struct {
+	ID         int64
+	UserID     int64
+	Kind       Kind
+	Hour       int // час отправки в часовом поясе салона
+	Active     bool
+	LastPeriod string // ключ последнего отправленного периода
+	LastSentAt *time.Time
+	CreatedAt  time.Time
+}
+
+// Period — период данных отчёта [From; To). Key однозначно определяет период.
+type Period struct {
+	Kind Kind
+	From time.Time
+	To   time.Time
+	Key  string
+}
+
+// LastRun — последний плановый момент отправки не позже now (в часовом поясе now).
+func (s Schedule) LastRun(now time.Time) time.Time {
+	day := time.Date(now.Year(), now.Month(), now.Day(), s.Hour, 0, 0, 0, now.Location())
+	switch s.Kind {
+	case KindWeeklyMasters:
+		day = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7)) // понедельник этой недели
+		if day.After(now) {
+			day = day.AddDate(0, 0, -7)
+		}
+	case KindMonthlyStock:
+		day = time.Date(now.Year(), now.Month(), 1, s.Hour, 0, 0, 0, now.Location())
+		if day.After(now) {
+			day = day.AddDate(0, -1, 0)
+		}
+	case KindMonthlySubs:
+		day = lastDayOfMonth(now.Year(), now.Month(), s.Hour, now.Location())
+		if day.After(now) {
+			prev := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, -1, 0)
+			day = lastDayOfMonth(prev.Year(), prev.Month(), s.Hour, now.Location())
+		}
+	default:
+		if day.After(now) {
+			day = day.AddDate(0, 0, -1)
+		}
+	}
+	return day
+}
+
+// PeriodAt — период данных для отправки в момент run.
+func PeriodAt(kind Kind, run time.Time) Period {
+	today := time.Date(run.Year(), run.Month(), run.Day(), 0, 0, 0, 0, run.Location())
+	p := Period{Kind: kind}
+	switch kind {
+	case KindWeeklyMasters:
+		p.To = today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		p.From = p.To.AddDate(0, 0, -7)
+	case KindMonthlyStock:
+		// остатки — на момент отправки, период — прошедший месяц
+		p.To = time.Date(run.Year(), run.Month(), 1, 0, 0, 0, 0, run.Location())
+		p.From = p.To.AddDate(0, -1, 0)
+	case KindMonthlySubs:
+		p.From = time.Date(run.Year(), run.Month(), 1, 0, 0, 0, 0, run.Location())
+		p.To = p.From.AddDate(0, 1, 0)
+	default:
+		p.To = today
+		p.From = today.AddDate(0, 0, -1)
+	}
+	p.Key = p.From.Format("2006-01-02")
+	return p
+}
+
+// Due — период, который пора отправить в момент now; false — отправлять нечего
+// (уже отправлен или плановый момент наступил раньше создания подписки).
+// Отправляется только период новее последнего отправленного: если час перенесли на более
+// поздний, LastRun возвращается к прошлому периоду, и его нельзя присылать повторно.
+// Ключи — даты начала периодов (YYYY-MM-DD), поэтому их можно сравнивать как строки.
+func (s Schedule) Due(now time.Time) (Period, bool) {
+	if !s.Active {
+		return Period{}, false
+	}
+	run := s.LastRun(now)
+	if run.Before(s.CreatedAt) {
+		return Period{}, false
+	}
+	p := PeriodAt(s.Kind, run)
+	return p, p.Key > s.LastPeriod
+}
+
+func lastDayOfMonth(year int, month time.Month, hour int, loc *time.Location) time.Time {
+	return time.Date(year, month+1, 0, hour, 0, 0, 0, loc)
+}
@@ -0,0 +1,86 @@
+package reports
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLastRun(t *testing.T) {
+	loc := time.FixedZone("MSK", 3*3600)
+	at := func(y int, m time.Month, d, h int) time.Time { return time.Date(y, m, d, h, 0, 0, 0, loc) }
+
+	tests := []struct {
+		name string
+		kind Kind
+		hour int
+		now  time.Time
+		want time.Time
+	}{
+		{name: "ежедневный, час уже прошёл", kind: KindDailyRevenue, hour: 9, now: at(2026, 3, 11, 10), want: at(2026, 3, 11, 9)},
+		{name: "ежедневный, час ещё не наступил", kind: KindDailyRevenue, hour: 9, now: at(2026, 3, 11, 8), want: at(2026, 3, 10, 9)},
+		{name: "еженедельный, среда", kind: KindWeeklyMasters, hour: 9, now: at(2026, 3, 11, 10), want: at(2026, 3, 9, 9)},
+		{name: "еженедельный, понедельник до часа", kind: KindWeeklyMasters, hour: 9, now: at(2026, 3, 9, 8), want: at(2026, 3, 2, 9)},
+		{name: "1-го числа, до часа", kind: KindMonthlyStock, hour: 9, now: at(2026, 3, 1, 8), want: at(2026, 2, 1, 9)},
+		{name: "1-го числа, середина месяца", kind: KindMonthlyStock, hour: 9, now: at(2026, 3, 15, 8), want: at(2026, 3, 1, 9)},
+		{name: "последний день, февраль", kind: KindMonthlySubs, hour: 20, now: at(2026, 3, 15, 10), want: at(2026, 2, 28, 20)},
+		{name: "последний день, в тот же день", kind: KindMonthlySubs, hour: 20, now: at(2026, 3, 31, 21), want: at(2026, 3, 31, 20)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Schedule{Kind: tt.kind, Hour: tt.hour}
+			if got := s.LastRun(tt.now); !got.Equal(tt.want) {
+				t.Errorf("LastRun() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDue(t *testing.T) {
+	loc := time.FixedZone("MSK", 3*3600)
+	now := time.Date(2026, 3, 11, 10, 0, 0, 0, loc)
+	created := now.AddDate(0, 0, -30)
+
+	tests := []struct {
+		name    string
+		s       Schedule
+		wantKey string
+		wantOK  bool
+	}{
+		{
+			name:    "ещё не отправлялся",
+			s:       Schedule{Kind: KindDailyRevenue, Hour: 9, Active: true, CreatedAt: created},
+			wantKey: "2026-03-10", wantOK: true,
+		},
+		{
+			name:    "уже отправлен",
+			s:       Schedule{Kind: KindDailyRevenue, Hour: 9, Active: true, CreatedAt: created, LastPeriod: "2026-03-10"},
+			wantKey: "2026-03-10",
+		},
+		{
+			name:    "час перенесли позже — прошлый период не повторяется",
+			s:       Schedule{Kind: KindDailyRevenue, Hour: 12, Active: true, CreatedAt: created, LastPeriod: "2026-03-10"},
+			wantKey: "2026-03-09",
+		},
+		{
+			name: "отключён",
+			s:    Schedule{Kind: KindDailyRevenue, Hour: 9, CreatedAt: created},
+		},
+		{
+			name: "создан после планового момента",
+			s:    Schedule{Kind: KindDailyRevenue, Hour: 9, Active: true, CreatedAt: now.Add(-30 * time.Minute)},
+		},
+		{
+			name:    "еженедельный — прошлая неделя",
+			s:       Schedule{Kind: KindWeeklyMasters, Hour: 9, Active: true, CreatedAt: created, LastPeriod: "2026-02-23"},
+			wantKey: "2026-03-02", wantOK: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, ok := tt.s.Due(now)
+			if ok != tt.wantOK || p.Key != tt.wantKey {
+				t.Errorf("Due() = (%q, %v), want (%q, %v)", p.Key, ok, tt.wantKey, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package reports
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Spok95/beauty-bot/internal/domain/audit"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repo struct{ pool *pgxpool.Pool }
+
+func NewRepo(pool *pgxpool.Pool) *Repo { return &Repo{pool: pool} }
+
+const scheduleColumns = `id, user_id, kind, hour, active, last_period, last_sent_at, created_at`
+
+func scanSchedules(rows pgx.Rows) ([]Schedule, error) {
+	defer rows.Close()
+	var out []Schedule
+	for rows.Next() {
+		var s Schedule
+		var hour int16
+		if err := rows.Scan(&s.ID, &s.UserID, &s.Kind, &hour, &s.Active, &s.LastPeriod, &s.LastSentAt, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		s.Hour = int(hour)
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// ListByUser — подписки пользователя.
+func (r *Repo) ListByUser(ctx context.Context, userID int64) ([]Schedule, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+scheduleColumns+` FROM report_schedules WHERE user_id = $1 ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanSchedules(rows)
+}
+
+// ListActive — включённые подписки всех пользователей (для планировщика).
+func (r *Repo) ListActive(ctx context.Context) ([]Schedule, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+scheduleColumns+` FROM report_schedules WHERE active ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	return scanSchedules(rows)
+}
+
+// SetActive включает или выключает отчёт пользователю; при первом включении создаёт подписку.
+// Отсчёт отправок идёт с момента включения, чтобы не присылать прошедшие периоды.
+func (r *Repo) SetActive(ctx context.Context, userID int64, kind Kind, active bool) error {
+	return r.upsert(ctx, userID, kind, `
+INSERT INTO report_schedules (user_id, kind, active)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, kind) DO UPDATE
+SET active     = EXCLUDED.active,
+    created_at = CASE WHEN EXCLUDED.active AND NOT report_schedules.active THEN now() ELSE report_schedules.created_at END
+RETURNING id
+`, userID, kind, active)
+}
+
+// SetHour меняет час отправки (подписка создаётся выключенной, если её ещё нет).
+func (r *Repo) SetHour(ctx context.Context, userID int64, kind Kind, hour int) error {
+	return r.upsert(ctx, userID, kind, `
+INSERT INTO report_schedules (user_id, kind, hour, active)
+VALUES ($1, $2, $3, FALSE)
+ON CONFLICT (user_id, kind) DO UPDATE SET hour = EXCLUDED.hour
+RETURNING id
+`, userID, kind, hour)
+}
+
+// upsert выполняет INSERT … ON CONFLICT … RETURNING id подписки userID/kind и пишет его в журнал:
+// изменение существующей подписки или создание новой.
+func (r *Repo) upsert(ctx context.Context, userID int64, kind Kind, q string, args ...any) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var id int64
+	err = tx.QueryRow(ctx, `SELECT id FROM report_schedules WHERE user_id = $1 AND kind = $2 FOR UPDATE`, userID, kind).Scan(&id)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	before, err := audit.Snapshot(ctx, tx, audit.EntityReportSchedule, id)
+	if err != nil {
+		return err
+	}
+	if err := tx.QueryRow(ctx, q, args...).Scan(&id); err != nil {
+		return err
+	}
+	after, err := audit.Snapshot(ctx, tx, audit.EntityReportSchedule, id)
+	if err != nil {
+		return err
+	}
+	if err := audit.Record(ctx, tx, audit.EntityReportSchedule, id, before, after); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// MarkSent запоминает отправленный период.
+func (r *Repo) MarkSent(ctx context.Context, id int64, periodKey string) error {
+	return audit.Track(ctx, r.pool, audit.EntityReportSchedule, id, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx,
+			`UPDATE report_schedules SET last_period = $2, last_sent_at = now() WHERE id = $1`, id, periodKey)
+		return err
+	})
+}
@@ -17,6 +17,38 @@ type Subscription struct {
 	MaterialsSumTotal       float64 // materials_sum_total
 	ThresholdMet            bool    // threshold_met
 
+	PricePerUnit float64 // цена аренды за час/день при покупке (0 — не сохранена)
+
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
+
+type RequestStatus string
+
+const (
+	RequestPending  RequestStatus = "pending"
+	RequestApproved RequestStatus = "approved"
+	RequestRejected RequestStatus = "rejected"
+)
+
+// Request — заявка мастера на покупку абонемента; решение по ней принимается один раз.
+type Request struct {
+	ID             int64
+	UserID         int64
+	Place          string
+	Unit           string
+	Qty            int
+	ThresholdTotal float64
+	PricePerUnit   float64
+	Status         RequestStatus
+	DecidedBy      *int64
+	SubscriptionID *int64
+	CreatedAt      time.Time
+}
+
+// Sales — продажи абонементов за период: каждая покупка — отдельная запись subscriptions.
+type Sales struct {
+	Count  int
+	Qty    int     // купленные часы/дни
+	Amount float64 // часы/дни × цена на момент покупки
+}
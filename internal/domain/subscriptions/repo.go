@@ -3,12 +3,18 @@ package subscriptions
 import (
 	"context"
 	"errors"
+	"time"
 
+	"github.com/Spok95/beauty-bot/internal/domain/audit"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-var ErrInsufficientLimit = errors.New("subscriptions: insufficient limit")
+var (
+	ErrInsufficientLimit = errors.New("subscriptions: insufficient limit")
+	// ErrRequestProcessed — по заявке уже принято решение (другим администратором или повторным нажатием).
+	ErrRequestProcessed = errors.New("subscriptions: request already processed")
+)
 
 type Repo struct{ db *pgxpool.Pool }
 
@@ -27,6 +33,7 @@ SELECT id,
        threshold_materials_total,
        materials_sum_total,
        threshold_met,
+       price_per_unit,
        created_at,
        updated_at
 FROM subscriptions
@@ -55,6 +62,7 @@ ORDER BY place, unit, plan_limit;
 			&s.ThresholdMaterialsTotal,
 			&s.MaterialsSumTotal,
 			&s.ThresholdMet,
+			&s.PricePerUnit,
 			&s.CreatedAt,
 			&s.UpdatedAt,
 		); err != nil {
@@ -65,6 +73,53 @@ ORDER BY place, unit, plan_limit;
 	return out, nil
 }
 
+// GetByUser возвращает абонемент мастера по id; nil — не найден или чужой.
+func (r *Repo) GetByUser(ctx context.Context, userID, id int64) (*Subscription, error) {
+	const q = `
+		SELECT
+		       id,
+		       user_id,
+		       place,
+		       unit,
+		       month,
+		       plan_limit,
+		       total_qty,
+		       used_qty,
+		       threshold_materials_total,
+		       materials_sum_total,
+		       threshold_met,
+		       price_per_unit,
+		       created_at,
+		       updated_at
+		FROM subscriptions
+		WHERE user_id = $1
+		  AND id      = $2;
+	`
+	var s Subscription
+	if err := r.db.QueryRow(ctx, q, userID, id).Scan(
+		&s.ID,
+		&s.UserID,
+		&s.Place,
+		&s.Unit,
+		&s.Month,
+		&s.PlanLimit,
+		&s.TotalQty,
+		&s.UsedQty,
+		&s.ThresholdMaterialsTotal,
+		&s.MaterialsSumTotal,
+		&s.ThresholdMet,
+		&s.PricePerUnit,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
 // LastByUserPlaceUnit возвращает последний абонемент мастера по помещению+единице
 // (по дате/месяцу, с учётом текущей таблицы subscriptions).
 func (r *Repo) LastByUserPlaceUnit(
@@ -85,6 +140,7 @@ func (r *Repo) LastByUserPlaceUnit(
 		       threshold_materials_total,
 		       materials_sum_total,
 		       threshold_met,
+		       price_per_unit,
 		       created_at,
 		       updated_at
 		FROM subscriptions
@@ -109,6 +165,7 @@ func (r *Repo) LastByUserPlaceUnit(
 		&s.ThresholdMaterialsTotal,
 		&s.MaterialsSumTotal,
 		&s.ThresholdMet,
+		&s.PricePerUnit,
 		&s.CreatedAt,
 		&s.UpdatedAt,
 	); err != nil {
@@ -140,6 +197,7 @@ SELECT id,
        threshold_materials_total,
        materials_sum_total,
        threshold_met,
+       price_per_unit,
        created_at,
        updated_at
 FROM subscriptions
@@ -171,6 +229,7 @@ ORDER BY created_at;
 			&s.ThresholdMaterialsTotal,
 			&s.MaterialsSumTotal,
 			&s.ThresholdMet,
+			&s.PricePerUnit,
 			&s.CreatedAt,
 			&s.UpdatedAt,
 		); err != nil {
@@ -181,6 +240,136 @@ ORDER BY created_at;
 	return res, nil
 }
 
+// ListActiveByPlan возвращает невыработанные абонементы всех мастеров по месту/единице
+// с месяца fromMonth, у которых лимит плана попадает в [minLimit; maxLimit] (maxLimit nil — без верха).
+// Нужен, чтобы предупредить, чьи абонементы затронет изменение ступени тарифа.
+func (r *Repo) ListActiveByPlan(
+	ctx context.Context,
+	place, unit, fromMonth string,
+	minLimit int, maxLimit *int,
+) ([]Subscription, error) {
+	const q = `
+SELECT id,
+       user_id,
+       place,
+       unit,
+       month,
+       plan_limit,
+       total_qty,
+       used_qty,
+       threshold_materials_total,
+       materials_sum_total,
+       threshold_met,
+       price_per_unit,
+       created_at,
+       updated_at
+FROM subscriptions
+WHERE place = $1
+  AND unit  = $2
+  AND month >= $3
+  AND total_qty > used_qty
+  AND plan_limit >= $4
+  AND ($5::int IS NULL OR plan_limit <= $5)
+ORDER BY month, user_id, created_at;
+`
+	rows, err := r.db.Query(ctx, q, place, unit, fromMonth, minLimit, maxLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []Subscription
+	for rows.Next() {
+		var s Subscription
+		if err := rows.Scan(
+			&s.ID,
+			&s.UserID,
+			&s.Place,
+			&s.Unit,
+			&s.Month,
+			&s.PlanLimit,
+			&s.TotalQty,
+			&s.UsedQty,
+			&s.ThresholdMaterialsTotal,
+			&s.MaterialsSumTotal,
+			&s.ThresholdMet,
+			&s.PricePerUnit,
+			&s.CreatedAt,
+			&s.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
+
+// ListByMonth возвращает абонементы всех мастеров за месяц (для отчёта по порогам).
+func (r *Repo) ListByMonth(ctx context.Context, month string) ([]Subscription, error) {
+	const q = `
+SELECT id,
+       user_id,
+       place,
+       unit,
+       month,
+       plan_limit,
+       total_qty,
+       used_qty,
+       threshold_materials_total,
+       materials_sum_total,
+       threshold_met,
+       price_per_unit,
+       created_at,
+       updated_at
+FROM subscriptions
+WHERE month = $1
+ORDER BY user_id, place, unit, plan_limit;
+`
+	rows, err := r.db.Query(ctx, q, month)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []Subscription
+	for rows.Next() {
+		var s Subscription
+		if err := rows.Scan(
+			&s.ID,
+			&s.UserID,
+			&s.Place,
+			&s.Unit,
+			&s.Month,
+			&s.PlanLimit,
+			&s.TotalQty,
+			&s.UsedQty,
+			&s.ThresholdMaterialsTotal,
+			&s.MaterialsSumTotal,
+			&s.ThresholdMet,
+			&s.PricePerUnit,
+			&s.CreatedAt,
+			&s.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
+
+// SalesBetween — абонементы, купленные в [from; to). Абонементы без сохранённой цены
+// (куплены до её появления) входят в количество, но не в сумму.
+func (r *Repo) SalesBetween(ctx context.Context, from, to time.Time) (Sales, error) {
+	var s Sales
+	err := r.db.QueryRow(ctx, `
+SELECT COUNT(*),
+       COALESCE(SUM(total_qty), 0),
+       COALESCE(SUM(total_qty * price_per_unit), 0)::float8
+FROM subscriptions
+WHERE created_at >= $1 AND created_at < $2`, from, to).Scan(&s.Count, &s.Qty, &s.Amount)
+	return s, err
+}
+
 func (r *Repo) AddUsage(ctx context.Context, id int64, qty int) error {
 	const q = `
 UPDATE subscriptions
@@ -248,12 +437,31 @@ WHERE id = $1;
 func (r *Repo) CreateOrSetTotal(ctx context.Context, userID int64, place, unit, month string, total int) (int64, error) {
 	// Админский режим: считаем, что он задаёт один актуальный абонемент на месяц.
 	// Удаляем все существующие записи по этому ключу и создаём одну новую.
-	if _, err := r.db.Exec(ctx,
-		`DELETE FROM subscriptions WHERE user_id=$1 AND place=$2 AND unit=$3 AND month=$4`,
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	rows, err := tx.Query(ctx,
+		`SELECT id FROM subscriptions WHERE user_id=$1 AND place=$2 AND unit=$3 AND month=$4 FOR UPDATE`,
 		userID, place, unit, month,
-	); err != nil {
+	)
+	if err != nil {
 		return 0, err
 	}
+	oldIDs, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return 0, err
+	}
+	for _, oldID := range oldIDs {
+		if err := audit.TrackTx(ctx, tx, audit.EntitySubscription, oldID, func() error {
+			_, err := tx.Exec(ctx, `DELETE FROM subscriptions WHERE id=$1`, oldID)
+			return err
+		}); err != nil {
+			return 0, err
+		}
+	}
 
 	const q = `
 		INSERT INTO subscriptions (user_id, place, unit, month, plan_limit, total_qty, used_qty)
@@ -262,10 +470,17 @@ func (r *Repo) CreateOrSetTotal(ctx context.Context, userID int64, place, unit,
 	`
 	planLimit := total
 	var id int64
-	if err := r.db.QueryRow(ctx, q, userID, place, unit, month, planLimit, total).Scan(&id); err != nil {
+	if err := tx.QueryRow(ctx, q, userID, place, unit, month, planLimit, total).Scan(&id); err != nil {
+		return 0, err
+	}
+	after, err := audit.Snapshot(ctx, tx, audit.EntitySubscription, id)
+	if err != nil {
 		return 0, err
 	}
-	return id, nil
+	if err := audit.Record(ctx, tx, audit.EntitySubscription, id, nil, after); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit(ctx)
 }
 
 // AddOrCreateTotal теперь всегда создаёт НОВУЮ запись
@@ -274,7 +489,7 @@ func (r *Repo) AddOrCreateTotal(
 	userID int64,
 	place, unit, month string,
 	qty int,
-	thresholdTotal float64,
+	thresholdTotal, pricePerUnit float64,
 ) (*Subscription, error) {
 	s := &Subscription{
 		UserID:                  userID,
@@ -287,24 +502,121 @@ func (r *Repo) AddOrCreateTotal(
 		ThresholdMaterialsTotal: thresholdTotal,
 		MaterialsSumTotal:       0,
 		ThresholdMet:            false,
+		PricePerUnit:            pricePerUnit,
 	}
 
-	row := r.db.QueryRow(ctx, `
-        INSERT INTO subscriptions (
-            user_id, place, unit, month,
-            plan_limit, total_qty, used_qty,
-            threshold_materials_total, materials_sum_total, threshold_met
-        )
-        VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
-        RETURNING id, created_at, updated_at
-    `,
-		s.UserID, s.Place, s.Unit, s.Month,
-		s.PlanLimit, s.TotalQty, s.UsedQty,
-		s.ThresholdMaterialsTotal, s.MaterialsSumTotal, s.ThresholdMet,
-	)
-
-	if err := row.Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt); err != nil {
+	err := audit.TrackCreate(ctx, r.db, audit.EntitySubscription, func(tx pgx.Tx) (int64, error) {
+		err := tx.QueryRow(ctx, `
+            INSERT INTO subscriptions (
+                user_id, place, unit, month,
+                plan_limit, total_qty, used_qty,
+                threshold_materials_total, materials_sum_total, threshold_met,
+                price_per_unit
+            )
+            VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+            RETURNING id, created_at, updated_at
+        `,
+			s.UserID, s.Place, s.Unit, s.Month,
+			s.PlanLimit, s.TotalQty, s.UsedQty,
+			s.ThresholdMaterialsTotal, s.MaterialsSumTotal, s.ThresholdMet,
+			s.PricePerUnit,
+		).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+		return s.ID, err
+	})
+	if err != nil {
 		return nil, err
 	}
 	return s, nil
 }
+
+// CreateRequest сохраняет заявку мастера на покупку абонемента.
+func (r *Repo) CreateRequest(ctx context.Context, req *Request) error {
+	return r.db.QueryRow(ctx, `
+		INSERT INTO subscription_requests (user_id, place, unit, qty, threshold_total, price_per_unit)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, status, created_at
+	`, req.UserID, req.Place, req.Unit, req.Qty, req.ThresholdTotal, req.PricePerUnit,
+	).Scan(&req.ID, &req.Status, &req.CreatedAt)
+}
+
+// ApproveRequest подтверждает заявку и создаёт по ней абонемент в одной транзакции.
+// Если заявка уже не в ожидании, возвращает ErrRequestProcessed и ничего не создаёт.
+func (r *Repo) ApproveRequest(ctx context.Context, id, decidedBy int64, month string) (*Request, *Subscription, error) {
+	req := &Request{ID: id}
+	s := &Subscription{Month: month}
+
+	err := audit.TrackCreate(ctx, r.db, audit.EntitySubscription, func(tx pgx.Tx) (int64, error) {
+		err := tx.QueryRow(ctx, `
+			UPDATE subscription_requests
+			SET status = 'approved', decided_by = $2, decided_at = now()
+			WHERE id = $1 AND status = 'pending'
+			RETURNING user_id, place, unit, qty, threshold_total, price_per_unit, status, created_at
+		`, id, decidedBy).Scan(
+			&req.UserID, &req.Place, &req.Unit, &req.Qty,
+			&req.ThresholdTotal, &req.PricePerUnit, &req.Status, &req.CreatedAt,
+		)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrRequestProcessed
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		s.UserID, s.Place, s.Unit = req.UserID, req.Place, req.Unit
+		s.PlanLimit, s.TotalQty = req.Qty, req.Qty
+		s.ThresholdMaterialsTotal = req.ThresholdTotal
+		s.PricePerUnit = req.PricePerUnit
+		err = tx.QueryRow(ctx, `
+            INSERT INTO subscriptions (
+                user_id, place, unit, month,
+                plan_limit, total_qty, used_qty,
+                threshold_materials_total, materials_sum_total, threshold_met,
+                price_per_unit
+            )
+            VALUES ($1,$2,$3,$4,$5,$6,0,$7,0,false,$8)
+            RETURNING id, created_at, updated_at
+        `,
+			s.UserID, s.Place, s.Unit, s.Month,
+			s.PlanLimit, s.TotalQty,
+			s.ThresholdMaterialsTotal,
+			s.PricePerUnit,
+		).Scan(&s.ID, &s.CreatedAt, &s.UpdatedAt)
+		if err != nil {
+			return 0, err
+		}
+
+		if _, err := tx.Exec(ctx,
+			`UPDATE subscription_requests SET subscription_id = $2 WHERE id = $1`, id, s.ID,
+		); err != nil {
+			return 0, err
+		}
+		req.DecidedBy = &decidedBy
+		req.SubscriptionID = &s.ID
+		return s.ID, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return req, s, nil
+}
+
+// RejectRequest отклоняет заявку; ErrRequestProcessed — решение уже принято.
+func (r *Repo) RejectRequest(ctx context.Context, id, decidedBy int64) (*Request, error) {
+	req := &Request{ID: id, DecidedBy: &decidedBy}
+	err := r.db.QueryRow(ctx, `
+		UPDATE subscription_requests
+		SET status = 'rejected', decided_by = $2, decided_at = now()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING user_id, place, unit, qty, threshold_total, price_per_unit, status, created_at
+	`, id, decidedBy).Scan(
+		&req.UserID, &req.Place, &req.Unit, &req.Qty,
+		&req.ThresholdTotal, &req.PricePerUnit, &req.Status, &req.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrRequestProcessed
+	}
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
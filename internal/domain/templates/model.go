@@ -0,0 +1,33 @@
+package templates
+
+import "time"
+
+// Template — шаблон расхода: набор материалов с количеством по умолчанию.
+type Template struct {
+	ID          int64
+	Name        string
+	OwnerUserID int64 // 0 — общий шаблон салона
+	Active      bool
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// IsShared — общий шаблон салона (не принадлежит конкретному мастеру).
+func (t Template) IsShared() bool {
+	return t.OwnerUserID == 0
+}
+
+type Item struct {
+	TemplateID int64
+	MaterialID int64
+	Brand      string // для отображения
+	Name       string // для отображения
+	Unit       string
+	Qty        float64
+}
+
+// ItemInput — позиция для сохранения шаблона.
+type ItemInput struct {
+	MaterialID int64
+	Qty        float64
+}
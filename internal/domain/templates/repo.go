@@ -0,0 +1,193 @@
+package templates
+
+import (
+	"context"
+
+	"github.com/Spok95/beauty-bot/internal/domain/audit"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repo struct{ pool *pgxpool.Pool }
+
+func NewRepo(pool *pgxpool.Pool) *Repo { return &Repo{pool: pool} }
+
+// Create создаёт шаблон с позициями. ownerUserID = 0 — общий шаблон салона.
+func (r *Repo) Create(ctx context.Context, name string, ownerUserID int64, active bool, items []ItemInput) (*Template, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var owner any
+	if ownerUserID > 0 {
+		owner = ownerUserID
+	}
+
+	var t Template
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO material_templates (name, owner_user_id, active)
+		VALUES ($1, $2, $3)
+		RETURNING id, name, COALESCE(owner_user_id, 0), active, created_at, updated_at
+	`, name, owner, active).Scan(&t.ID, &t.Name, &t.OwnerUserID, &t.Active, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	if err := replaceItemsTx(ctx, tx, t.ID, items); err != nil {
+		return nil, err
+	}
+
+	after, err := audit.Snapshot(ctx, tx, audit.EntityTemplate, t.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := audit.Record(ctx, tx, audit.EntityTemplate, t.ID, nil, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *Repo) GetByID(ctx context.Context, id int64) (*Template, error) {
+	var t Template
+	err := r.pool.QueryRow(ctx, `
+		SELECT id, name, COALESCE(owner_user_id, 0), active, created_at, updated_at
+		FROM material_templates
+		WHERE id = $1
+	`, id).Scan(&t.ID, &t.Name, &t.OwnerUserID, &t.Active, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListForUser — активные шаблоны, доступные мастеру: личные + общие салона.
+func (r *Repo) ListForUser(ctx context.Context, userID int64) ([]Template, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, name, COALESCE(owner_user_id, 0), active, created_at, updated_at
+		FROM material_templates
+		WHERE active AND (owner_user_id = $1 OR owner_user_id IS NULL)
+		ORDER BY (owner_user_id IS NULL), LOWER(name), id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Template
+	for rows.Next() {
+		var t Template
+		if err := rows.Scan(&t.ID, &t.Name, &t.OwnerUserID, &t.Active, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// ListShared — все общие шаблоны салона (для выгрузки админом).
+func (r *Repo) ListShared(ctx context.Context) ([]Template, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, name, COALESCE(owner_user_id, 0), active, created_at, updated_at
+		FROM material_templates
+		WHERE owner_user_id IS NULL
+		ORDER BY LOWER(name), id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Template
+	for rows.Next() {
+		var t Template
+		if err := rows.Scan(&t.ID, &t.Name, &t.OwnerUserID, &t.Active, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+func (r *Repo) ListItems(ctx context.Context, templateID int64) ([]Item, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT i.template_id, i.material_id, COALESCE(b.name, ''), m.name, m.unit, i.qty::float8
+		FROM material_template_items i
+		JOIN materials m ON m.id = i.material_id
+		LEFT JOIN material_brands b ON b.id = m.brand_id
+		WHERE i.template_id = $1
+		ORDER BY i.id
+	`, templateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Item
+	for rows.Next() {
+		var it Item
+		if err := rows.Scan(&it.TemplateID, &it.MaterialID, &it.Brand, &it.Name, &it.Unit, &it.Qty); err != nil {
+			return nil, err
+		}
+		out = append(out, it)
+	}
+	return out, rows.Err()
+}
+
+// Update меняет название/активность и полностью заменяет позиции шаблона.
+func (r *Repo) Update(ctx context.Context, id int64, name string, active bool, items []ItemInput) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	err = audit.TrackTx(ctx, tx, audit.EntityTemplate, id, func() error {
+		if _, err := tx.Exec(ctx, `
+			UPDATE material_templates
+			SET name = $2, active = $3, updated_at = now()
+			WHERE id = $1
+		`, id, name, active); err != nil {
+			return err
+		}
+		return replaceItemsTx(ctx, tx, id, items)
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Delete удаляет шаблон вместе с позициями.
+func (r *Repo) Delete(ctx context.Context, id int64) error {
+	return audit.Track(ctx, r.pool, audit.EntityTemplate, id, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `DELETE FROM material_templates WHERE id = $1`, id)
+		return err
+	})
+}
+
+func replaceItemsTx(ctx context.Context, tx pgx.Tx, templateID int64, items []ItemInput) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM material_template_items WHERE template_id = $1`, templateID); err != nil {
+		return err
+	}
+	for _, it := range items {
+		// одинаковые материалы в шаблоне суммируем
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO material_template_items (template_id, material_id, qty)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (template_id, material_id)
+			DO UPDATE SET qty = material_template_items.qty + EXCLUDED.qty
+		`, templateID, it.MaterialID, it.Qty); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,6 +1,11 @@
 package users
 
-import "time"
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+)
 
 type Role string
 
@@ -16,8 +21,21 @@ const (
 	StatusPending  Status = "pending"
 	StatusApproved Status = "approved"
 	StatusRejected Status = "rejected"
+	StatusBlocked  Status = "blocked" // отключён администратором
 )
 
+// AllRoles — все роли в порядке отображения.
+var AllRoles = []Role{RoleMaster, RoleAdministrator, RoleAdmin}
+
+// ErrLastRole — нельзя снять с пользователя единственную роль.
+var ErrLastRole = errors.New("нельзя снять последнюю роль пользователя")
+
+// ErrLastAdmin — нельзя снять роль admin с последнего активного админа.
+var ErrLastAdmin = errors.New("нельзя снять роль у последнего администратора")
+
+// ErrNotPending — заявка пользователя уже рассмотрена (одобрена, отклонена или он заблокирован).
+var ErrNotPending = errors.New("заявка пользователя уже рассмотрена")
+
 type User struct {
 	ID         int64
 	TelegramID int64
@@ -28,4 +46,117 @@ type User struct {
 	Status     Status
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
+	ApprovedAt *time.Time // когда заявку одобрили впервые; nil — ещё не одобрена
+}
+
+// HasRole — есть ли у пользователя роль (среди всех назначенных, не только активной).
+func (u *User) HasRole(role Role) bool {
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Specialization — направление работы мастера.
+type Specialization string
+
+const (
+	SpecHair  Specialization = "hair"
+	SpecNails Specialization = "nails"
+	SpecBrows Specialization = "brows"
+)
+
+// AllSpecializations — все специализации в порядке отображения.
+var AllSpecializations = []Specialization{SpecHair, SpecNails, SpecBrows}
+
+// ErrInvalidPhone — телефон не похож на номер.
+var ErrInvalidPhone = errors.New("некорректный номер телефона")
+
+// Profile — анкета пользователя (для мастера — полная карточка).
+type Profile struct {
+	UserID             int64
+	LastName           string
+	FirstName          string
+	MiddleName         string
+	Phone              string
+	Specializations    []Specialization
+	DefaultPlace       string // hall|cabinet|""
+	DefaultWarehouseID *int64
+	RentTerms          string // условия договора/аренды
+	PhotoFileID        string // Telegram file_id фото
+	AdminNotes         string // видно только админам
+	UpdatedAt          time.Time
+}
+
+// FullName — «Фамилия Имя Отчество».
+func (p *Profile) FullName() string {
+	if p == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(p.LastName+" "+p.FirstName+" "+p.MiddleName), " ")
+}
+
+// ShortName — «Фамилия И.О.» (для чеков и отчётов).
+func (p *Profile) ShortName() string {
+	if p == nil || p.LastName == "" {
+		return p.FullName()
+	}
+	initials := ""
+	for _, part := range []string{p.FirstName, p.MiddleName} {
+		if r := []rune(strings.TrimSpace(part)); len(r) > 0 {
+			initials += string(unicode.ToUpper(r[0])) + "."
+		}
+	}
+	if initials == "" {
+		return p.LastName
+	}
+	return p.LastName + " " + initials
+}
+
+// HasSpecialization — выбрана ли специализация.
+func (p *Profile) HasSpecialization(s Specialization) bool {
+	for _, x := range p.Specializations {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitFullName разбивает «Фамилия Имя Отчество…» на части; всё после имени — отчество.
+func SplitFullName(fio string) (last, first, middle string) {
+	parts := strings.Fields(fio)
+	switch len(parts) {
+	case 0:
+		return "", "", ""
+	case 1:
+		return parts[0], "", ""
+	case 2:
+		return parts[0], parts[1], ""
+	default:
+		return parts[0], parts[1], strings.Join(parts[2:], " ")
+	}
+}
+
+// NormalizePhone приводит номер к виду +7XXXXXXXXXX (для РФ) или +<цифры>.
+func NormalizePhone(s string) (string, error) {
+	var digits strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	d := digits.String()
+	switch {
+	case len(d) == 11 && (d[0] == '8' || d[0] == '7'):
+		return "+7" + d[1:], nil
+	case len(d) == 10 && d[0] == '9':
+		return "+7" + d, nil
+	case len(d) >= 10 && len(d) <= 15 && strings.HasPrefix(strings.TrimSpace(s), "+"):
+		return "+" + d, nil
+	default:
+		return "", ErrInvalidPhone
+	}
 }
@@ -2,7 +2,13 @@ package users
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 
+	"github.com/Spok95/beauty-bot/internal/domain/audit"
+	"github.com/Spok95/beauty-bot/internal/infra/db"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -13,12 +19,12 @@ func NewRepo(pool *pgxpool.Pool) *Repo { return &Repo{pool: pool} }
 
 func (r *Repo) GetByTelegramID(ctx context.Context, tgID int64) (*User, error) {
 	row := r.pool.QueryRow(ctx, `
-		SELECT id, telegram_id, COALESCE(username, ''), active_role, status, created_at, updated_at
+		SELECT id, telegram_id, COALESCE(username, ''), active_role, status, created_at, updated_at, approved_at
 		FROM users WHERE telegram_id = $1
 	`, tgID)
 
 	var u User
-	if err := row.Scan(&u.ID, &u.TelegramID, &u.Username, &u.Role, &u.Status, &u.CreatedAt, &u.UpdatedAt); err != nil {
+	if err := row.Scan(&u.ID, &u.TelegramID, &u.Username, &u.Role, &u.Status, &u.CreatedAt, &u.UpdatedAt, &u.ApprovedAt); err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil
 		}
@@ -38,11 +44,11 @@ func (r *Repo) UpsertByTelegram(ctx context.Context, tgID int64, defaultRole Rol
 		VALUES ($1, $2, $2)
 		ON CONFLICT (telegram_id) DO UPDATE SET
 			updated_at = now()
-		RETURNING id, telegram_id, COALESCE(username, ''), active_role, status, created_at, updated_at
+		RETURNING id, telegram_id, COALESCE(username, ''), active_role, status, created_at, updated_at, approved_at
 	`, tgID, defaultRole)
 
 	var u User
-	if err := row.Scan(&u.ID, &u.TelegramID, &u.Username, &u.Role, &u.Status, &u.CreatedAt, &u.UpdatedAt); err != nil {
+	if err := row.Scan(&u.ID, &u.TelegramID, &u.Username, &u.Role, &u.Status, &u.CreatedAt, &u.UpdatedAt, &u.ApprovedAt); err != nil {
 		return nil, err
 	}
 
@@ -55,15 +61,53 @@ func (r *Repo) UpsertByTelegram(ctx context.Context, tgID int64, defaultRole Rol
 	return &u, nil
 }
 
+// Resubmit возвращает отклонённого пользователя в ожидание, когда он подаёт заявку повторно.
+// Заблокированных и уже одобренных не трогает.
+func (r *Repo) Resubmit(ctx context.Context, tgID int64) error {
+	_, err := r.pool.Exec(ctx, `
+		UPDATE users SET status = 'pending', updated_at = now()
+		WHERE telegram_id = $1 AND status = 'rejected'
+	`, tgID)
+	return err
+}
+
+// idByTelegram — внутренний id пользователя (для журнала изменений).
+func (r *Repo) idByTelegram(ctx context.Context, tgID int64) (int64, error) {
+	var id int64
+	err := r.pool.QueryRow(ctx, `SELECT id FROM users WHERE telegram_id = $1`, tgID).Scan(&id)
+	return id, err
+}
+
+// SetFIO сохраняет ФИО: в users.username (отображаемое имя) и по полям в профиль.
 func (r *Repo) SetFIO(ctx context.Context, tgID int64, fio string) (*User, error) {
-	row := r.pool.QueryRow(ctx, `
-		UPDATE users
-		SET username = $2, updated_at = now()
-		WHERE telegram_id = $1
-		RETURNING id, telegram_id, COALESCE(username, ''), active_role, status, created_at, updated_at
-	`, tgID, fio)
+	fio = strings.Join(strings.Fields(fio), " ")
+	id, err := r.idByTelegram(ctx, tgID)
+	if err != nil {
+		return nil, err
+	}
 	var u User
-	if err := row.Scan(&u.ID, &u.TelegramID, &u.Username, &u.Role, &u.Status, &u.CreatedAt, &u.UpdatedAt); err != nil {
+	err = audit.Track(ctx, r.pool, audit.EntityUser, id, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx, `
+			UPDATE users
+			SET username = $2, updated_at = now()
+			WHERE id = $1
+			RETURNING id, telegram_id, COALESCE(username, ''), active_role, status, created_at, updated_at, approved_at
+		`, id, fio).Scan(&u.ID, &u.TelegramID, &u.Username, &u.Role, &u.Status, &u.CreatedAt, &u.UpdatedAt, &u.ApprovedAt); err != nil {
+			return err
+		}
+		last, first, middle := SplitFullName(fio)
+		_, err := tx.Exec(ctx, `
+			INSERT INTO user_profiles (user_id, last_name, first_name, middle_name)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (user_id) DO UPDATE SET
+				last_name = EXCLUDED.last_name,
+				first_name = EXCLUDED.first_name,
+				middle_name = EXCLUDED.middle_name,
+				updated_at = now()
+		`, u.ID, last, first, middle)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 	roles, _ := r.ListRoles(ctx, u.ID)
@@ -72,20 +116,33 @@ func (r *Repo) SetFIO(ctx context.Context, tgID int64, fio string) (*User, error
 	return &u, nil
 }
 
+// Approve одобряет заявку; рассмотреть можно только заявку в ожидании (иначе ErrNotPending).
 func (r *Repo) Approve(ctx context.Context, tgID int64, role Role) (*User, error) {
-	row := r.pool.QueryRow(ctx, `
-		UPDATE users
-		SET role = $2, active_role = $2, status = 'approved', updated_at = now()
-		WHERE telegram_id = $1
-		RETURNING id, telegram_id, COALESCE(username, ''), active_role, status, created_at, updated_at
-	`, tgID, role)
-
-	var u User
-	if err := row.Scan(&u.ID, &u.TelegramID, &u.Username, &u.Role, &u.Status, &u.CreatedAt, &u.UpdatedAt); err != nil {
+	id, err := r.idByTelegram(ctx, tgID)
+	if err != nil {
 		return nil, err
 	}
-
-	if err := r.AddRole(ctx, u.ID, role); err != nil {
+	var u User
+	err = audit.Track(ctx, r.pool, audit.EntityUser, id, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx, `
+			UPDATE users
+			SET role = $2, active_role = $2, status = 'approved', approved_at = COALESCE(approved_at, now()), updated_at = now()
+			WHERE id = $1 AND status = 'pending'
+			RETURNING id, telegram_id, COALESCE(username, ''), active_role, status, created_at, updated_at, approved_at
+		`, id, role).Scan(&u.ID, &u.TelegramID, &u.Username, &u.Role, &u.Status, &u.CreatedAt, &u.UpdatedAt, &u.ApprovedAt); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrNotPending
+			}
+			return err
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO user_roles (user_id, role)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id, role) DO NOTHING
+		`, id, role)
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -96,15 +153,26 @@ func (r *Repo) Approve(ctx context.Context, tgID int64, role Role) (*User, error
 	return &u, nil
 }
 
+// Reject отклоняет заявку в ожидании (иначе ErrNotPending).
 func (r *Repo) Reject(ctx context.Context, tgID int64) (*User, error) {
-	row := r.pool.QueryRow(ctx, `
-		UPDATE users
-		SET status = 'rejected', updated_at = now()
-		WHERE telegram_id = $1
-		RETURNING id, telegram_id, COALESCE(username, ''), active_role, status, created_at, updated_at
-	`, tgID)
+	id, err := r.idByTelegram(ctx, tgID)
+	if err != nil {
+		return nil, err
+	}
 	var u User
-	if err := row.Scan(&u.ID, &u.TelegramID, &u.Username, &u.Role, &u.Status, &u.CreatedAt, &u.UpdatedAt); err != nil {
+	err = audit.Track(ctx, r.pool, audit.EntityUser, id, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `
+			UPDATE users
+			SET status = 'rejected', updated_at = now()
+			WHERE id = $1 AND status = 'pending'
+			RETURNING id, telegram_id, COALESCE(username, ''), active_role, status, created_at, updated_at, approved_at
+		`, id).Scan(&u.ID, &u.TelegramID, &u.Username, &u.Role, &u.Status, &u.CreatedAt, &u.UpdatedAt, &u.ApprovedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotPending
+		}
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &u, nil
@@ -113,7 +181,7 @@ func (r *Repo) Reject(ctx context.Context, tgID int64) (*User, error) {
 // ListByRole возвращает всех пользователей с заданной ролью и статусом.
 func (r *Repo) ListByRole(ctx context.Context, role Role, status Status) ([]*User, error) {
 	rows, err := r.pool.Query(ctx, `
-		SELECT u.id, u.telegram_id, COALESCE(u.username, ''), u.active_role, u.status, u.created_at, u.updated_at
+		SELECT u.id, u.telegram_id, COALESCE(u.username, ''), u.active_role, u.status, u.created_at, u.updated_at, u.approved_at
 		FROM users u
 		INNER JOIN user_roles ur ON ur.user_id = u.id
 		WHERE ur.role = $1 AND u.status = $2
@@ -128,7 +196,7 @@ func (r *Repo) ListByRole(ctx context.Context, role Role, status Status) ([]*Use
 	for rows.Next() {
 		var u User
 		if err := rows.Scan(
-			&u.ID, &u.TelegramID, &u.Username, &u.Role, &u.Status, &u.CreatedAt, &u.UpdatedAt,
+			&u.ID, &u.TelegramID, &u.Username, &u.Role, &u.Status, &u.CreatedAt, &u.UpdatedAt, &u.ApprovedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -149,12 +217,12 @@ func (r *Repo) ListByRole(ctx context.Context, role Role, status Status) ([]*Use
 
 func (r *Repo) GetByID(ctx context.Context, id int64) (*User, error) {
 	row := r.pool.QueryRow(ctx, `
-		SELECT id, telegram_id, COALESCE(username, ''), active_role, status, created_at, updated_at
+		SELECT id, telegram_id, COALESCE(username, ''), active_role, status, created_at, updated_at, approved_at
 		FROM users
 		WHERE id = $1
 	`, id)
 	var u User
-	if err := row.Scan(&u.ID, &u.TelegramID, &u.Username, &u.Role, &u.Status, &u.CreatedAt, &u.UpdatedAt); err != nil {
+	if err := row.Scan(&u.ID, &u.TelegramID, &u.Username, &u.Role, &u.Status, &u.CreatedAt, &u.UpdatedAt, &u.ApprovedAt); err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil
 		}
@@ -214,26 +282,328 @@ func (r *Repo) ListRoles(ctx context.Context, userID int64) ([]Role, error) {
 }
 
 func (r *Repo) AddRole(ctx context.Context, userID int64, role Role) error {
-	_, err := r.pool.Exec(ctx, `
-		INSERT INTO user_roles (user_id, role)
-		VALUES ($1, $2)
-		ON CONFLICT (user_id, role) DO NOTHING
-	`, userID, role)
-
-	return err
+	return audit.Track(ctx, r.pool, audit.EntityUser, userID, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO user_roles (user_id, role)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id, role) DO NOTHING
+		`, userID, role)
+		return err
+	})
 }
 
 func (r *Repo) SetActiveRole(ctx context.Context, userID int64, role Role) error {
-	_, err := r.pool.Exec(ctx, `
-		UPDATE users
-		SET active_role = $2, updated_at = now()
-		WHERE id = $1
-		  AND EXISTS (
-			  SELECT 1
-			  FROM user_roles
-			  WHERE user_id = $1 AND role = $2
-		  )
-	`, userID, role)
+	return audit.Track(ctx, r.pool, audit.EntityUser, userID, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			UPDATE users
+			SET active_role = $2, updated_at = now()
+			WHERE id = $1
+			  AND EXISTS (
+				  SELECT 1
+				  FROM user_roles
+				  WHERE user_id = $1 AND role = $2
+			  )
+		`, userID, role)
+		return err
+	})
+}
 
-	return err
+// Search — постраничный список пользователей для админки.
+// q ищется в ФИО и telegram_id; пустой q — все пользователи. Возвращает страницу и общее количество.
+func (r *Repo) Search(ctx context.Context, q string, limit, offset int) ([]*User, int, error) {
+	q = strings.TrimSpace(q)
+	like := "%" + db.EscapeLike(strings.ToLower(q)) + "%"
+
+	var total int
+	if err := r.pool.QueryRow(ctx, `
+		SELECT COUNT(*)
+		FROM users
+		WHERE $1 = '' OR LOWER(username) LIKE $2 ESCAPE '\' OR telegram_id::text LIKE $2 ESCAPE '\'
+	`, q, like).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, telegram_id, COALESCE(username, ''), active_role, status, created_at, updated_at, approved_at
+		FROM users
+		WHERE $1 = '' OR LOWER(username) LIKE $2 ESCAPE '\' OR telegram_id::text LIKE $2 ESCAPE '\'
+		ORDER BY LOWER(username), telegram_id
+		LIMIT $3 OFFSET $4
+	`, q, like, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var out []*User
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.TelegramID, &u.Username, &u.Role, &u.Status, &u.CreatedAt, &u.UpdatedAt, &u.ApprovedAt); err != nil {
+			return nil, 0, err
+		}
+		u.ActiveRole = u.Role
+		out = append(out, &u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return out, total, nil
+}
+
+// Block отключает доступ пользователю и запоминает статус до блокировки.
+func (r *Repo) Block(ctx context.Context, userID int64) error {
+	return audit.Track(ctx, r.pool, audit.EntityUser, userID, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			UPDATE users
+			SET status_before_block = status, status = 'blocked', updated_at = now()
+			WHERE id = $1 AND status <> 'blocked'
+		`, userID)
+		return err
+	})
+}
+
+// Unblock возвращает пользователю статус, который был до блокировки
+// (не одобренный раньше так и остаётся неодобренным). Возвращает новый статус.
+func (r *Repo) Unblock(ctx context.Context, userID int64) (Status, error) {
+	var status Status
+	err := audit.Track(ctx, r.pool, audit.EntityUser, userID, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `
+			UPDATE users
+			SET status = COALESCE(status_before_block, 'pending'), status_before_block = NULL, updated_at = now()
+			WHERE id = $1 AND status = 'blocked'
+			RETURNING status
+		`, userID).Scan(&status)
+		if errors.Is(err, pgx.ErrNoRows) {
+			// уже разблокирован — отдаём текущий статус
+			return tx.QueryRow(ctx, `SELECT status FROM users WHERE id = $1`, userID).Scan(&status)
+		}
+		return err
+	})
+	return status, err
+}
+
+// RemoveRole снимает роль. Если она была активной — активной становится одна из оставшихся.
+// Последнюю роль снять нельзя (ErrLastRole).
+func (r *Repo) RemoveRole(ctx context.Context, userID int64, role Role) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	err = audit.TrackTx(ctx, tx, audit.EntityUser, userID, func() error {
+		var left int
+		if err := tx.QueryRow(ctx, `
+			SELECT COUNT(*) FROM user_roles WHERE user_id = $1 AND role <> $2
+		`, userID, role).Scan(&left); err != nil {
+			return err
+		}
+		if left == 0 {
+			return ErrLastRole
+		}
+
+		if role == RoleAdmin {
+			others, err := countAdmins(ctx, tx, userID)
+			if err != nil {
+				return err
+			}
+			if others == 0 {
+				return ErrLastAdmin
+			}
+		}
+
+		if _, err := tx.Exec(ctx, `
+			DELETE FROM user_roles WHERE user_id = $1 AND role = $2
+		`, userID, role); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, `
+			UPDATE users
+			SET active_role = (
+					SELECT ur.role FROM user_roles ur WHERE ur.user_id = $1 ORDER BY ur.role LIMIT 1
+				),
+				role = (
+					SELECT ur.role FROM user_roles ur WHERE ur.user_id = $1 ORDER BY ur.role LIMIT 1
+				),
+				updated_at = now()
+			WHERE id = $1 AND active_role = $2
+		`, userID, role); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// countAdmins — число подтверждённых пользователей с ролью admin, кроме exceptUserID.
+func countAdmins(ctx context.Context, q queryRower, exceptUserID int64) (int, error) {
+	var n int
+	err := q.QueryRow(ctx, `
+		SELECT COUNT(*)
+		FROM users u
+		JOIN user_roles ur ON ur.user_id = u.id AND ur.role = 'admin'
+		WHERE u.status = 'approved' AND u.id <> $1
+	`, exceptUserID).Scan(&n)
+	return n, err
+}
+
+// CountAdmins — число подтверждённых админов, кроме exceptUserID (0 — считать всех).
+func (r *Repo) CountAdmins(ctx context.Context, exceptUserID int64) (int, error) {
+	return countAdmins(ctx, r.pool, exceptUserID)
+}
+
+// BootstrapAdmins — первичная инициализация админов из конфигурации.
+// Срабатывает, только если в базе ещё нет ни одного подтверждённого админа;
+// дальше админы управляются из бота. Возвращает число назначенных админов.
+func (r *Repo) BootstrapAdmins(ctx context.Context, tgIDs []int64) (int, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// блокируем параллельный запуск второго экземпляра
+	if _, err := tx.Exec(ctx, `LOCK TABLE user_roles IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return 0, err
+	}
+
+	existing, err := countAdmins(ctx, tx, 0)
+	if err != nil {
+		return 0, err
+	}
+	if existing > 0 {
+		return 0, nil
+	}
+
+	n := 0
+	for _, tgID := range tgIDs {
+		if tgID == 0 {
+			continue
+		}
+		var before json.RawMessage
+		var prevID int64
+		if err := tx.QueryRow(ctx, `SELECT id FROM users WHERE telegram_id = $1`, tgID).Scan(&prevID); err == nil {
+			if before, err = audit.Snapshot(ctx, tx, audit.EntityUser, prevID); err != nil {
+				return 0, err
+			}
+		}
+		var id int64
+		if err := tx.QueryRow(ctx, `
+			INSERT INTO users (telegram_id, role, active_role, status, approved_at)
+			VALUES ($1, 'admin', 'admin', 'approved', now())
+			ON CONFLICT (telegram_id) DO UPDATE SET
+				role = 'admin', active_role = 'admin', status = 'approved',
+				approved_at = COALESCE(users.approved_at, now()), updated_at = now()
+			RETURNING id
+		`, tgID).Scan(&id); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO user_roles (user_id, role) VALUES ($1, 'admin')
+			ON CONFLICT (user_id, role) DO NOTHING
+		`, id); err != nil {
+			return 0, err
+		}
+		after, err := audit.Snapshot(ctx, tx, audit.EntityUser, id)
+		if err != nil {
+			return 0, err
+		}
+		if err := audit.Record(audit.WithSource(ctx, audit.SourceSystem), tx, audit.EntityUser, id, before, after); err != nil {
+			return 0, err
+		}
+		n++
+	}
+
+	return n, tx.Commit(ctx)
+}
+
+// GetProfile — профиль пользователя; если анкеты ещё нет, возвращается пустой профиль.
+func (r *Repo) GetProfile(ctx context.Context, userID int64) (*Profile, error) {
+	p := Profile{UserID: userID}
+	var specs []string
+	err := r.pool.QueryRow(ctx, `
+		SELECT last_name, first_name, middle_name, phone, specializations,
+		       default_place, default_warehouse_id, rent_terms, photo_file_id, admin_notes, updated_at
+		FROM user_profiles
+		WHERE user_id = $1
+	`, userID).Scan(
+		&p.LastName, &p.FirstName, &p.MiddleName, &p.Phone, &specs,
+		&p.DefaultPlace, &p.DefaultWarehouseID, &p.RentTerms, &p.PhotoFileID, &p.AdminNotes, &p.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range specs {
+		p.Specializations = append(p.Specializations, Specialization(s))
+	}
+	return &p, nil
+}
+
+// ProfileField — текстовое поле профиля, которое можно менять по одному.
+type ProfileField string
+
+const (
+	ProfilePhone        ProfileField = "phone"
+	ProfileDefaultPlace ProfileField = "default_place"
+	ProfileRentTerms    ProfileField = "rent_terms"
+	ProfilePhoto        ProfileField = "photo_file_id"
+	ProfileAdminNotes   ProfileField = "admin_notes"
+)
+
+// SetProfileField обновляет одно текстовое поле профиля (создаёт анкету при необходимости).
+func (r *Repo) SetProfileField(ctx context.Context, userID int64, field ProfileField, value string) error {
+	switch field {
+	case ProfilePhone, ProfileDefaultPlace, ProfileRentTerms, ProfilePhoto, ProfileAdminNotes:
+	default:
+		return fmt.Errorf("unknown profile field %q", field)
+	}
+	// имя колонки — только из белого списка выше
+	return audit.Track(ctx, r.pool, audit.EntityUser, userID, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, fmt.Sprintf(`
+			INSERT INTO user_profiles (user_id, %[1]s)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE SET %[1]s = EXCLUDED.%[1]s, updated_at = now()
+		`, field), userID, strings.TrimSpace(value))
+		return err
+	})
+}
+
+// SetSpecializations заменяет список специализаций.
+func (r *Repo) SetSpecializations(ctx context.Context, userID int64, specs []Specialization) error {
+	list := make([]string, 0, len(specs))
+	for _, s := range specs {
+		list = append(list, string(s))
+	}
+	return audit.Track(ctx, r.pool, audit.EntityUser, userID, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO user_profiles (user_id, specializations)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE SET specializations = EXCLUDED.specializations, updated_at = now()
+		`, userID, list)
+		return err
+	})
+}
+
+// SetDefaultWarehouse задаёт склад по умолчанию (nil — сбросить).
+func (r *Repo) SetDefaultWarehouse(ctx context.Context, userID int64, warehouseID *int64) error {
+	return audit.Track(ctx, r.pool, audit.EntityUser, userID, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO user_profiles (user_id, default_warehouse_id)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE SET default_warehouse_id = EXCLUDED.default_warehouse_id, updated_at = now()
+		`, userID, warehouseID)
+		return err
+	})
 }
@@ -0,0 +1,44 @@
+// Package barcode распознаёт штрихкоды на фото (EAN-13/8, UPC, Code128/39) и рисует QR-коды без внешних сервисов.
+package barcode
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/jpeg" // фото из Telegram приходят в JPEG
+	_ "image/png"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/oned"
+)
+
+// ErrNotFound — на изображении не найден читаемый штрихкод.
+var ErrNotFound = errors.New("штрихкод не найден")
+
+// Decode распознаёт штрихкод на изображении и возвращает его текст.
+func Decode(data []byte) (string, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return "", err
+	}
+
+	hints := map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_TRY_HARDER: true,
+	}
+	readers := []gozxing.Reader{
+		oned.NewMultiFormatUPCEANReader(hints),
+		oned.NewCode128Reader(),
+		oned.NewCode39Reader(),
+	}
+	for _, r := range readers {
+		res, err := r.Decode(bmp, hints)
+		if err == nil && res != nil && res.GetText() != "" {
+			return res.GetText(), nil
+		}
+	}
+	return "", ErrNotFound
+}
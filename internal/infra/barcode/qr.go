@@ -0,0 +1,41 @@
+package barcode
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+// EncodeQR рисует QR-код с текстом (обычно ссылкой) и возвращает PNG размером size×size.
+func EncodeQR(text string, size int) ([]byte, error) {
+	hints := map[gozxing.EncodeHintType]interface{}{
+		gozxing.EncodeHintType_MARGIN:           1,
+		gozxing.EncodeHintType_CHARACTER_SET:    "UTF-8",
+		gozxing.EncodeHintType_ERROR_CORRECTION: "M",
+	}
+	m, err := qrcode.NewQRCodeWriter().Encode(text, gozxing.BarcodeFormat_QR_CODE, size, size, hints)
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewGray(image.Rect(0, 0, m.GetWidth(), m.GetHeight()))
+	for y := 0; y < m.GetHeight(); y++ {
+		for x := 0; x < m.GetWidth(); x++ {
+			c := color.Gray{Y: 0xFF}
+			if m.Get(x, y) {
+				c = color.Gray{}
+			}
+			img.SetGray(x, y, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
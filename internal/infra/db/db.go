@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -18,3 +19,11 @@ func Connect(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
 	cfg.HealthCheckPeriod = 30 * time.Second
 	return pgxpool.NewWithConfig(ctx, cfg)
 }
+
+// likeEscaper экранирует спецсимволы LIKE: %, _ и \ во вводе ищутся как обычные символы.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// EscapeLike готовит пользовательский ввод для LIKE/ILIKE … ESCAPE '\'.
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/Spok95/beauty-bot/internal/domain/audit"
 	"github.com/Spok95/beauty-bot/internal/domain/consumption"
 )
 
@@ -24,7 +25,7 @@ func NewHandler(log *slog.Logger, cons *consumption.Repo) *Handler {
 // ServeHTTP эмулирует "успешную оплату":
 // /payments/pay?invoice=123 -> помечаем invoices.status='paid' и показываем простую HTML-страницу.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+	ctx := audit.WithSource(r.Context(), audit.SourceAPI)
 
 	invoiceStr := r.URL.Query().Get("invoice")
 	if invoiceStr == "" {
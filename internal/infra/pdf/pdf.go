@@ -0,0 +1,197 @@
+// Package pdf формирует простые документы (чеки, счета, подтверждения) в PDF.
+// Шрифты DejaVu встроены в бинарник, поэтому кириллица не зависит от окружения сервера.
+package pdf
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+//go:embed fonts/DejaVuSans.ttf
+var regularFont []byte
+
+//go:embed fonts/DejaVuSans-Bold.ttf
+var boldFont []byte
+
+const (
+	fontFamily = "DejaVu"
+	lineHeight = 5.5
+	labelWidth = 55
+)
+
+// Column — колонка таблицы: заголовок, ширина в мм и выравнивание по правому краю (для сумм).
+type Column struct {
+	Title string
+	Width float64
+	Right bool
+}
+
+// Doc — документ A4 с заголовком, полями, таблицами и итогами.
+type Doc struct {
+	f *fpdf.Fpdf
+}
+
+// New создаёт документ с названием title (оно же попадает в свойства файла).
+// generated — момент формирования в часовом поясе салона: печатается в колонтитуле.
+func New(title string, generated time.Time) *Doc {
+	f := fpdf.New("P", "mm", "A4", "")
+	f.AddUTF8FontFromBytes(fontFamily, "", regularFont)
+	f.AddUTF8FontFromBytes(fontFamily, "B", boldFont)
+	f.SetTitle(title, true)
+	f.SetCreator("beauty-bot", true)
+	f.SetMargins(15, 15, 15)
+	f.SetAutoPageBreak(true, 15)
+	f.AliasNbPages("")
+
+	f.SetCreationDate(generated)
+	stamp := generated.Format("02.01.2006 15:04")
+	f.SetFooterFunc(func() {
+		f.SetY(-12)
+		f.SetFont(fontFamily, "", 8)
+		f.SetTextColor(128, 128, 128)
+		f.CellFormat(0, 4, fmt.Sprintf("Сформировано %s · стр. %d из {nb}", stamp, f.PageNo()), "", 0, "C", false, 0, "")
+		f.SetTextColor(0, 0, 0)
+	})
+
+	f.AddPage()
+	d := &Doc{f: f}
+	d.Heading(title)
+	return d
+}
+
+// Heading — заголовок документа.
+func (d *Doc) Heading(text string) {
+	d.f.SetFont(fontFamily, "B", 16)
+	d.f.MultiCell(0, 8, text, "", "L", false)
+	d.f.Ln(2)
+}
+
+// Section — подзаголовок раздела.
+func (d *Doc) Section(text string) {
+	d.f.Ln(3)
+	d.f.SetFont(fontFamily, "B", 12)
+	d.f.MultiCell(0, 7, text, "", "L", false)
+}
+
+// Text — обычный абзац.
+func (d *Doc) Text(text string) {
+	d.f.SetFont(fontFamily, "", 10)
+	d.f.MultiCell(0, lineHeight, text, "", "L", false)
+}
+
+// Field — строка «название: значение».
+func (d *Doc) Field(label, value string) {
+	d.f.SetFont(fontFamily, "B", 10)
+	d.f.CellFormat(labelWidth, lineHeight, label, "", 0, "L", false, 0, "")
+	d.f.SetFont(fontFamily, "", 10)
+	d.f.MultiCell(0, lineHeight, value, "", "L", false)
+}
+
+// Total — строка итога, значение выравнивается по правому краю.
+func (d *Doc) Total(label, value string, bold bool) {
+	style := ""
+	if bold {
+		style = "B"
+	}
+	d.f.SetFont(fontFamily, style, 11)
+	left, _, right, _ := d.f.GetMargins()
+	w, _ := d.f.GetPageSize()
+	d.f.CellFormat(w-left-right-45, 6.5, label, "", 0, "R", false, 0, "")
+	d.f.CellFormat(45, 6.5, value, "", 1, "R", false, 0, "")
+}
+
+// Link — кликабельная ссылка.
+func (d *Doc) Link(text, url string) {
+	d.f.SetFont(fontFamily, "", 10)
+	d.f.SetTextColor(0, 70, 180)
+	d.f.WriteLinkString(lineHeight, text, url)
+	d.f.SetTextColor(0, 0, 0)
+	d.f.Ln(lineHeight)
+}
+
+// Image вставляет PNG шириной size мм с текущей позиции.
+func (d *Doc) Image(name string, png []byte, size float64) {
+	opts := fpdf.ImageOptions{ImageType: "PNG"}
+	d.f.RegisterImageOptionsReader(name, opts, bytes.NewReader(png))
+	d.f.ImageOptions(name, d.f.GetX(), d.f.GetY(), size, size, true, opts, 0, "")
+}
+
+// Gap — пустая строка.
+func (d *Doc) Gap() {
+	d.f.Ln(lineHeight)
+}
+
+// Table рисует таблицу; длинный текст переносится внутри ячейки, заголовок повторяется на новой странице.
+func (d *Doc) Table(cols []Column, rows [][]string) {
+	header := func() {
+		d.f.SetFont(fontFamily, "B", 9)
+		d.f.SetFillColor(235, 235, 235)
+		for _, c := range cols {
+			align := "L"
+			if c.Right {
+				align = "R"
+			}
+			d.f.CellFormat(c.Width, 6, c.Title, "1", 0, align, true, 0, "")
+		}
+		d.f.Ln(-1)
+	}
+
+	const h = 4.5
+	_, pageH := d.f.GetPageSize()
+	_, _, _, bottom := d.f.GetMargins()
+
+	header()
+	d.f.SetFont(fontFamily, "", 9)
+	for _, row := range rows {
+		lines := make([][]string, len(cols))
+		height := 1
+		for i, c := range cols {
+			text := ""
+			if i < len(row) {
+				text = row[i]
+			}
+			lines[i] = d.f.SplitText(text, c.Width-2*d.f.GetCellMargin())
+			if len(lines[i]) == 0 {
+				lines[i] = []string{""}
+			}
+			height = max(height, len(lines[i]))
+		}
+		rowH := float64(height)*h + 1
+
+		if d.f.GetY()+rowH > pageH-bottom {
+			d.f.AddPage()
+			header()
+			d.f.SetFont(fontFamily, "", 9)
+		}
+
+		x, y := d.f.GetXY()
+		for i, c := range cols {
+			align := "L"
+			if c.Right {
+				align = "R"
+			}
+			d.f.Rect(x, y, c.Width, rowH, "D")
+			for j, line := range lines[i] {
+				d.f.SetXY(x, y+0.5+float64(j)*h)
+				d.f.CellFormat(c.Width, h, line, "", 0, align, false, 0, "")
+			}
+			x += c.Width
+		}
+		left, _, _, _ := d.f.GetMargins()
+		d.f.SetXY(left, y+rowH)
+	}
+	d.f.Ln(2)
+}
+
+// Bytes возвращает готовый PDF.
+func (d *Doc) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := d.f.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,28 @@
+-- +goose Up
+
+-- Шаблоны расхода материалов (набор материалов с количеством по умолчанию).
+-- owner_user_id IS NULL — общий шаблон салона, иначе — личный шаблон мастера.
+CREATE TABLE IF NOT EXISTS material_templates (
+    id            BIGSERIAL PRIMARY KEY,
+    name          TEXT        NOT NULL,
+    owner_user_id BIGINT      REFERENCES users(id) ON DELETE CASCADE,
+    active        BOOLEAN     NOT NULL DEFAULT TRUE,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_material_templates_owner
+    ON material_templates(owner_user_id);
+
+CREATE TABLE IF NOT EXISTS material_template_items (
+    id          BIGSERIAL PRIMARY KEY,
+    template_id BIGINT        NOT NULL REFERENCES material_templates(id) ON DELETE CASCADE,
+    material_id BIGINT        NOT NULL REFERENCES materials(id) ON DELETE CASCADE,
+    qty         NUMERIC(18,3) NOT NULL CHECK (qty > 0),
+    CONSTRAINT uq_material_template_items UNIQUE (template_id, material_id)
+);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS material_template_items;
+DROP TABLE IF EXISTS material_templates;
@@ -0,0 +1,19 @@
+-- +goose Up
+
+-- Единица закупки материала и коэффициент пересчёта в единицу расхода (materials.unit).
+-- Например: unit = 'g', purchase_unit = 'tube', purchase_factor = 60 — 1 туба = 60 г.
+ALTER TABLE materials
+    ADD COLUMN IF NOT EXISTS purchase_unit TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS purchase_factor NUMERIC(18,3) NOT NULL DEFAULT 1;
+
+ALTER TABLE materials
+    ADD CONSTRAINT chk_materials_purchase_factor
+        CHECK (purchase_factor > 0);
+
+-- +goose Down
+
+ALTER TABLE materials DROP CONSTRAINT IF EXISTS chk_materials_purchase_factor;
+
+ALTER TABLE materials
+    DROP COLUMN IF EXISTS purchase_factor,
+    DROP COLUMN IF EXISTS purchase_unit;
@@ -0,0 +1,16 @@
+-- +goose Up
+
+-- Штрихкоды материалов (EAN/UPC и т.п.). У одного материала может быть несколько кодов,
+-- один код принадлежит только одному материалу.
+CREATE TABLE IF NOT EXISTS material_barcodes (
+    id          BIGSERIAL PRIMARY KEY,
+    material_id BIGINT NOT NULL REFERENCES materials(id) ON DELETE CASCADE,
+    code        TEXT   NOT NULL UNIQUE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_material_barcodes_material ON material_barcodes(material_id);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS material_barcodes;
@@ -0,0 +1,45 @@
+-- +goose Up
+
+-- Нечёткий поиск материалов: триграммы + нормализация строки.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- search_norm приводит строку к виду для поиска: нижний регистр, ё → е, без апострофов и знаков.
+-- Такие же правила применяются к запросу в Go (materials.NormalizeSearch).
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION search_norm(t TEXT) RETURNS TEXT
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE AS
+$$
+SELECT translate(lower(COALESCE(t, '')), 'ё''’`´".,-_/()', 'е')
+$$;
+-- +goose StatementEnd
+
+CREATE INDEX IF NOT EXISTS idx_materials_name_trgm
+    ON materials USING gin (search_norm(name) gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS idx_material_brands_name_trgm
+    ON material_brands USING gin (search_norm(name) gin_trgm_ops);
+
+-- Синонимы брендов: «лореаль», «loreal paris» → L'Oreal.
+CREATE TABLE IF NOT EXISTS material_brand_aliases (
+    id         BIGSERIAL PRIMARY KEY,
+    brand_id   BIGINT NOT NULL REFERENCES material_brands(id) ON DELETE CASCADE,
+    alias      TEXT   NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT uq_material_brand_aliases UNIQUE (brand_id, alias)
+);
+
+CREATE INDEX IF NOT EXISTS idx_material_brand_aliases_trgm
+    ON material_brand_aliases USING gin (search_norm(alias) gin_trgm_ops);
+
+-- Для ранжирования по недавнему расходу мастера.
+CREATE INDEX IF NOT EXISTS idx_consumption_items_material ON consumption_items(material_id);
+CREATE INDEX IF NOT EXISTS idx_consumption_sessions_user_created ON consumption_sessions(user_id, created_at);
+
+-- +goose Down
+
+DROP INDEX IF EXISTS idx_consumption_sessions_user_created;
+DROP INDEX IF EXISTS idx_consumption_items_material;
+DROP TABLE IF EXISTS material_brand_aliases;
+DROP INDEX IF EXISTS idx_material_brands_name_trgm;
+DROP INDEX IF EXISTS idx_materials_name_trgm;
+DROP FUNCTION IF EXISTS search_norm(TEXT);
@@ -0,0 +1,20 @@
+-- +goose Up
+
+-- Статус blocked: пользователь отключён администратором (ушёл из салона и т.п.).
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_status_check;
+ALTER TABLE users
+    ADD CONSTRAINT users_status_check
+        CHECK (status IN ('pending','approved','rejected','blocked'));
+
+CREATE INDEX IF NOT EXISTS idx_users_status ON users(status);
+
+-- +goose Down
+
+DROP INDEX IF EXISTS idx_users_status;
+
+UPDATE users SET status = 'rejected' WHERE status = 'blocked';
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_status_check;
+ALTER TABLE users
+    ADD CONSTRAINT users_status_check
+        CHECK (status IN ('pending','approved','rejected'));
@@ -0,0 +1,39 @@
+-- +goose Up
+
+-- Профиль пользователя: ФИО по полям, контакты, специализация, умолчания для расхода
+-- и заметки админа. users.username остаётся «отображаемым ФИО» и синхронизируется с профилем.
+CREATE TABLE IF NOT EXISTS user_profiles (
+    user_id              BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    last_name            TEXT        NOT NULL DEFAULT '',
+    first_name           TEXT        NOT NULL DEFAULT '',
+    middle_name          TEXT        NOT NULL DEFAULT '',
+    phone                TEXT        NOT NULL DEFAULT '',
+    specializations      TEXT[]      NOT NULL DEFAULT '{}',
+    default_place        TEXT        NOT NULL DEFAULT '',
+    default_warehouse_id BIGINT      REFERENCES warehouses(id) ON DELETE SET NULL,
+    rent_terms           TEXT        NOT NULL DEFAULT '',
+    photo_file_id        TEXT        NOT NULL DEFAULT '',
+    admin_notes          TEXT        NOT NULL DEFAULT '',
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT chk_user_profiles_specializations
+        CHECK (specializations <@ ARRAY['hair','nails','brows']::TEXT[]),
+    CONSTRAINT chk_user_profiles_default_place
+        CHECK (default_place IN ('', 'hall', 'cabinet'))
+);
+
+-- Переносим уже введённые ФИО: «Фамилия Имя Отчество…»
+INSERT INTO user_profiles (user_id, last_name, first_name, middle_name)
+SELECT s.id,
+       COALESCE(s.p[1], ''),
+       COALESCE(s.p[2], ''),
+       COALESCE(array_to_string(s.p[3:], ' '), '')
+FROM (
+    SELECT id, regexp_split_to_array(btrim(username), '\s+') AS p
+    FROM users
+    WHERE COALESCE(btrim(username), '') <> ''
+) s
+ON CONFLICT (user_id) DO NOTHING;
+
+-- +goose Down
+
+DROP TABLE IF EXISTS user_profiles;
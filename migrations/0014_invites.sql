@@ -0,0 +1,36 @@
+-- +goose Up
+
+-- Приглашения: deep link /start <code> с заранее назначенной ролью, помещением и складом.
+CREATE TABLE IF NOT EXISTS invites (
+    id            BIGSERIAL PRIMARY KEY,
+    code          TEXT        NOT NULL UNIQUE,
+    role          TEXT        NOT NULL,
+    place         TEXT        NOT NULL DEFAULT '',
+    warehouse_id  BIGINT      REFERENCES warehouses(id) ON DELETE SET NULL,
+    auto_approve  BOOLEAN     NOT NULL DEFAULT FALSE,
+    max_uses      INT         NOT NULL DEFAULT 1, -- 0 — без ограничения
+    used_count    INT         NOT NULL DEFAULT 0,
+    expires_at    TIMESTAMPTZ,
+    revoked_at    TIMESTAMPTZ,
+    created_by    BIGINT      REFERENCES users(id) ON DELETE SET NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT chk_invites_role CHECK (role IN ('admin', 'administrator', 'master')),
+    CONSTRAINT chk_invites_place CHECK (place IN ('', 'hall', 'cabinet')),
+    CONSTRAINT chk_invites_uses CHECK (max_uses >= 0 AND used_count >= 0)
+);
+
+-- Кто и когда зарегистрировался по приглашению.
+CREATE TABLE IF NOT EXISTS invite_uses (
+    invite_id BIGINT      NOT NULL REFERENCES invites(id) ON DELETE CASCADE,
+    user_id   BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    used_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (invite_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_invites_active ON invites(created_at DESC) WHERE revoked_at IS NULL;
+
+-- +goose Down
+
+DROP INDEX IF EXISTS idx_invites_active;
+DROP TABLE IF EXISTS invite_uses;
+DROP TABLE IF EXISTS invites;
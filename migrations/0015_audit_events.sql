@@ -0,0 +1,25 @@
+-- +goose Up
+
+-- Журнал административных изменений: кто, что и откуда поменял, состояние до и после.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id         BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    actor_id   BIGINT      REFERENCES users(id) ON DELETE SET NULL,
+    source     TEXT        NOT NULL DEFAULT 'system',
+    entity     TEXT        NOT NULL,
+    entity_id  BIGINT      NOT NULL,
+    action     TEXT        NOT NULL,
+    before     JSONB,
+    after      JSONB,
+    CONSTRAINT chk_audit_events_source CHECK (source IN ('bot', 'excel', 'api', 'system')),
+    CONSTRAINT chk_audit_events_action CHECK (action IN ('create', 'update', 'delete'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_created ON audit_events(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_entity ON audit_events(entity, entity_id, created_at DESC);
+
+-- +goose Down
+
+DROP INDEX IF EXISTS idx_audit_events_entity;
+DROP INDEX IF EXISTS idx_audit_events_created;
+DROP TABLE IF EXISTS audit_events;
@@ -0,0 +1,51 @@
+-- +goose Up
+
+-- История цен материалов: цена действует с valid_from до следующей записи.
+-- Записи с valid_from в будущем — запланированные изменения.
+CREATE TABLE IF NOT EXISTS material_prices (
+    id          BIGSERIAL PRIMARY KEY,
+    material_id BIGINT        NOT NULL REFERENCES materials(id) ON DELETE CASCADE,
+    price       NUMERIC(12,2) NOT NULL,
+    valid_from  TIMESTAMPTZ   NOT NULL,
+    created_by  BIGINT        REFERENCES users(id) ON DELETE SET NULL,
+    created_at  TIMESTAMPTZ   NOT NULL DEFAULT now(),
+    CONSTRAINT chk_material_prices_price CHECK (price >= 0),
+    CONSTRAINT uq_material_prices_from UNIQUE (material_id, valid_from)
+);
+
+-- Точка отсчёта истории — момент миграции: какие цены действовали раньше, неизвестно,
+-- поэтому текущая цена записывается действующей с now(), а не с materials.created_at
+-- (цену с тех пор могли менять, и старые сессии пересчитались бы по нынешней).
+-- Для моментов до точки отсчёта material_price_at отдаёт эту же первую цену истории.
+INSERT INTO material_prices (material_id, price, valid_from)
+SELECT id, price_per_unit, now()
+FROM materials
+ON CONFLICT (material_id, valid_from) DO NOTHING;
+
+-- material_price_at — цена материала на момент времени. До первой записи истории —
+-- первая уже вступившая в силу цена (для старых данных это цена на момент миграции),
+-- без истории — materials.price_per_unit.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION material_price_at(p_material_id BIGINT, p_at TIMESTAMPTZ) RETURNS NUMERIC
+    LANGUAGE sql STABLE AS
+$$
+SELECT COALESCE(
+    (SELECT mp.price
+     FROM material_prices mp
+     WHERE mp.material_id = p_material_id AND mp.valid_from <= p_at
+     ORDER BY mp.valid_from DESC
+     LIMIT 1),
+    (SELECT mp.price
+     FROM material_prices mp
+     WHERE mp.material_id = p_material_id AND mp.valid_from <= now()
+     ORDER BY mp.valid_from
+     LIMIT 1),
+    (SELECT m.price_per_unit FROM materials m WHERE m.id = p_material_id)
+)
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+
+DROP FUNCTION IF EXISTS material_price_at(BIGINT, TIMESTAMPTZ);
+DROP TABLE IF EXISTS material_prices;
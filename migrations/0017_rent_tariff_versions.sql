@@ -0,0 +1,56 @@
+-- +goose Up
+
+-- Версии тарифов аренды: набор ступеней rent_rates действует целиком в период [active_from; active_to].
+-- Черновик (draft) готовится из Excel и не участвует в расчётах до публикации.
+CREATE TABLE IF NOT EXISTS rent_tariff_versions (
+    id              BIGSERIAL PRIMARY KEY,
+    status          TEXT        NOT NULL DEFAULT 'draft',
+    base_version_id BIGINT      REFERENCES rent_tariff_versions(id) ON DELETE SET NULL,
+    active_from     DATE,
+    active_to       DATE,
+    comment         TEXT        NOT NULL DEFAULT '',
+    created_by      BIGINT      REFERENCES users(id) ON DELETE SET NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    published_at    TIMESTAMPTZ,
+    CONSTRAINT chk_rent_tariff_versions_status CHECK (status IN ('draft', 'published')),
+    CONSTRAINT chk_rent_tariff_versions_from CHECK (status = 'draft' OR active_from IS NOT NULL),
+    CONSTRAINT chk_rent_tariff_versions_range CHECK (active_to IS NULL OR active_to >= active_from)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rent_tariff_versions_active
+    ON rent_tariff_versions(active_from DESC) WHERE status = 'published';
+
+-- Существующие ступени становятся первой опубликованной версией.
+INSERT INTO rent_tariff_versions (status, active_from, comment, published_at)
+SELECT 'published', COALESCE(MIN(active_from), CURRENT_DATE), 'Тарифы до введения версий', now()
+FROM rent_rates
+WHERE NOT EXISTS (SELECT 1 FROM rent_tariff_versions);
+
+ALTER TABLE rent_rates
+    ADD COLUMN IF NOT EXISTS version_id BIGINT REFERENCES rent_tariff_versions(id) ON DELETE CASCADE;
+
+UPDATE rent_rates
+SET version_id = (SELECT MIN(id) FROM rent_tariff_versions)
+WHERE version_id IS NULL;
+
+ALTER TABLE rent_rates ALTER COLUMN version_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_rent_rates_version
+    ON rent_rates(version_id, place, unit, with_subscription, min_qty);
+
+-- Сессия помнит версию тарифов, по которой посчитана аренда.
+ALTER TABLE consumption_sessions
+    ADD COLUMN IF NOT EXISTS tariff_version_id BIGINT REFERENCES rent_tariff_versions(id);
+
+UPDATE consumption_sessions
+SET tariff_version_id = (SELECT MIN(id) FROM rent_tariff_versions)
+WHERE tariff_version_id IS NULL
+  AND place <> 'no_rent';
+
+-- +goose Down
+
+ALTER TABLE consumption_sessions DROP COLUMN IF EXISTS tariff_version_id;
+DROP INDEX IF EXISTS idx_rent_rates_version;
+ALTER TABLE rent_rates DROP COLUMN IF EXISTS version_id;
+DROP INDEX IF EXISTS idx_rent_tariff_versions_active;
+DROP TABLE IF EXISTS rent_tariff_versions;
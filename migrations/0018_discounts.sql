@@ -0,0 +1,52 @@
+-- +goose Up
+
+-- Правила скидок и акций. Пустые условия не ограничивают применение:
+-- user_id — персональная скидка мастера, place — помещение,
+-- hour_from/hour_to — время суток расчёта [from; to) (to < from — через полночь),
+-- promo_code — применяется только при вводе кода, first_month — первый месяц после одобрения заявки мастера.
+CREATE TABLE IF NOT EXISTS discounts (
+    id          BIGSERIAL PRIMARY KEY,
+    title       TEXT          NOT NULL,
+    kind        TEXT          NOT NULL,
+    target      TEXT          NOT NULL,
+    value       NUMERIC(12,2) NOT NULL,
+    user_id     BIGINT        REFERENCES users(id) ON DELETE CASCADE,
+    place       TEXT,
+    hour_from   SMALLINT,
+    hour_to     SMALLINT,
+    promo_code  TEXT,
+    first_month BOOLEAN       NOT NULL DEFAULT FALSE,
+    valid_from  DATE,
+    valid_to    DATE,
+    active      BOOLEAN       NOT NULL DEFAULT TRUE,
+    created_by  BIGINT        REFERENCES users(id) ON DELETE SET NULL,
+    created_at  TIMESTAMPTZ   NOT NULL DEFAULT now(),
+    CONSTRAINT chk_discounts_kind CHECK (kind IN ('percent', 'fixed')),
+    CONSTRAINT chk_discounts_target CHECK (target IN ('rent', 'materials')),
+    CONSTRAINT chk_discounts_value CHECK (value > 0 AND (kind <> 'percent' OR value <= 100)),
+    CONSTRAINT chk_discounts_place CHECK (place IS NULL OR place IN ('hall', 'cabinet')),
+    CONSTRAINT chk_discounts_hours CHECK (
+        (hour_from IS NULL AND hour_to IS NULL)
+            OR (hour_from BETWEEN 0 AND 23 AND hour_to BETWEEN 0 AND 24 AND hour_from <> hour_to)),
+    CONSTRAINT chk_discounts_valid CHECK (valid_to IS NULL OR valid_from IS NULL OR valid_to >= valid_from)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_discounts_promo_code
+    ON discounts(lower(promo_code)) WHERE promo_code IS NOT NULL;
+
+-- Скидки, применённые к сессии расхода/аренды (для чеков и отчётов).
+CREATE TABLE IF NOT EXISTS consumption_discounts (
+    id          BIGSERIAL PRIMARY KEY,
+    session_id  BIGINT        NOT NULL REFERENCES consumption_sessions(id) ON DELETE CASCADE,
+    discount_id BIGINT        REFERENCES discounts(id) ON DELETE SET NULL,
+    title       TEXT          NOT NULL,
+    target      TEXT          NOT NULL,
+    amount      NUMERIC(12,2) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_consumption_discounts_session ON consumption_discounts(session_id);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS consumption_discounts;
+DROP TABLE IF EXISTS discounts;
@@ -0,0 +1,21 @@
+-- +goose Up
+
+-- Подписки администраторов на отчёты по расписанию. Время отправки — час в часовом поясе салона,
+-- периодичность задаётся видом отчёта; last_period — ключ последнего отправленного периода.
+CREATE TABLE IF NOT EXISTS report_schedules (
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind         TEXT        NOT NULL,
+    hour         SMALLINT    NOT NULL DEFAULT 9,
+    active       BOOLEAN     NOT NULL DEFAULT TRUE,
+    last_period  TEXT        NOT NULL DEFAULT '',
+    last_sent_at TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT uq_report_schedules_user_kind UNIQUE (user_id, kind),
+    CONSTRAINT chk_report_schedules_kind CHECK (kind IN ('daily_revenue', 'weekly_masters', 'monthly_stock', 'monthly_subs')),
+    CONSTRAINT chk_report_schedules_hour CHECK (hour BETWEEN 0 AND 23)
+);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS report_schedules;
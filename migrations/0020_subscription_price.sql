@@ -0,0 +1,9 @@
+-- +goose Up
+
+-- Цена аренды за единицу на момент покупки абонемента (для подтверждения покупки в PDF).
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS price_per_unit NUMERIC(14,2) NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE subscriptions
+    DROP COLUMN IF EXISTS price_per_unit;
@@ -0,0 +1,39 @@
+-- +goose Up
+
+-- Рассылки: текст (и необязательное фото/документ), сегмент получателей и время отправки.
+-- Получатели фиксируются в broadcast_deliveries в момент запуска, статус доставки — по каждому.
+CREATE TABLE IF NOT EXISTS broadcasts (
+    id            BIGSERIAL PRIMARY KEY,
+    author_id     BIGINT      NOT NULL REFERENCES users(id),
+    text          TEXT        NOT NULL DEFAULT '',
+    media_type    TEXT        NOT NULL DEFAULT '',
+    media_file_id TEXT        NOT NULL DEFAULT '',
+    segment       JSONB       NOT NULL DEFAULT '{}',
+    status        TEXT        NOT NULL DEFAULT 'scheduled',
+    scheduled_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    started_at    TIMESTAMPTZ,
+    finished_at   TIMESTAMPTZ,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT chk_broadcasts_media CHECK (media_type IN ('', 'photo', 'document')),
+    CONSTRAINT chk_broadcasts_status CHECK (status IN ('scheduled', 'sending', 'done', 'canceled'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_broadcasts_status_scheduled ON broadcasts (status, scheduled_at);
+
+CREATE TABLE IF NOT EXISTS broadcast_deliveries (
+    broadcast_id BIGINT      NOT NULL REFERENCES broadcasts(id) ON DELETE CASCADE,
+    user_id      BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    telegram_id  BIGINT      NOT NULL,
+    status       TEXT        NOT NULL DEFAULT 'pending',
+    error        TEXT        NOT NULL DEFAULT '',
+    sent_at      TIMESTAMPTZ,
+    PRIMARY KEY (broadcast_id, user_id),
+    CONSTRAINT chk_broadcast_deliveries_status CHECK (status IN ('pending', 'sent', 'blocked', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_broadcast_deliveries_status ON broadcast_deliveries (broadcast_id, status);
+
+-- +goose Down
+
+DROP TABLE IF EXISTS broadcast_deliveries;
+DROP TABLE IF EXISTS broadcasts;
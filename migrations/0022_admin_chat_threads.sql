@@ -0,0 +1,73 @@
+-- +goose Up
+
+-- Обращения в админ-чат: одна ветка на автора (мастера), статус, ответственный и отметки для SLA.
+-- awaiting_reply — последнее слово за автором, администрация ещё не ответила;
+-- last_user_message_at — первое неотвеченное сообщение автора, от него считается SLA.
+CREATE TABLE IF NOT EXISTS admin_chat_threads (
+    id                   BIGSERIAL PRIMARY KEY,
+    user_id              BIGINT      NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    status               TEXT        NOT NULL DEFAULT 'open',
+    assignee_id          BIGINT      REFERENCES users(id) ON DELETE SET NULL,
+    awaiting_reply       BOOLEAN     NOT NULL DEFAULT FALSE,
+    last_message_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_user_message_at TIMESTAMPTZ,
+    last_reply_at        TIMESTAMPTZ,
+    sla_notified_at      TIMESTAMPTZ,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT chk_admin_chat_threads_status CHECK (status IN ('open', 'in_progress', 'resolved'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_chat_threads_status ON admin_chat_threads (status, last_message_at DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_chat_threads_assignee ON admin_chat_threads (assignee_id);
+
+ALTER TABLE admin_chat_messages
+    ADD COLUMN IF NOT EXISTS thread_id BIGINT REFERENCES admin_chat_threads(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_admin_chat_messages_thread ON admin_chat_messages (thread_id, id);
+
+-- Прочитанное: до какого сообщения ветки пользователь дочитал (для счётчиков непрочитанного).
+CREATE TABLE IF NOT EXISTS admin_chat_reads (
+    thread_id            BIGINT NOT NULL REFERENCES admin_chat_threads(id) ON DELETE CASCADE,
+    user_id              BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    last_read_message_id BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (thread_id, user_id)
+);
+
+-- Существующая история: ветка на каждого автора исходных (не ответных) сообщений,
+-- ответы попадают в ветку сообщения, на которое отвечают. Старые ветки считаются решёнными.
+INSERT INTO admin_chat_threads (user_id, status, last_message_at, created_at)
+SELECT sender_user_id, 'resolved', MAX(created_at), MIN(created_at)
+FROM admin_chat_messages
+WHERE sender_user_id IS NOT NULL AND reply_to_message_id IS NULL
+GROUP BY sender_user_id
+ON CONFLICT (user_id) DO NOTHING;
+
+WITH RECURSIVE chain AS (
+    SELECT id, id AS root_id FROM admin_chat_messages WHERE reply_to_message_id IS NULL
+    UNION ALL
+    SELECT m.id, c.root_id
+    FROM admin_chat_messages AS m
+    JOIN chain AS c ON m.reply_to_message_id = c.id
+)
+UPDATE admin_chat_messages AS m
+SET thread_id = t.id
+FROM chain AS c
+JOIN admin_chat_messages AS r ON r.id = c.root_id
+JOIN admin_chat_threads AS t ON t.user_id = r.sender_user_id
+WHERE m.id = c.id;
+
+-- старая история считается прочитанной администрацией
+INSERT INTO admin_chat_reads (thread_id, user_id, last_read_message_id)
+SELECT t.id, ur.user_id, COALESCE(MAX(m.id), 0)
+FROM admin_chat_threads AS t
+JOIN admin_chat_messages AS m ON m.thread_id = t.id
+CROSS JOIN (SELECT DISTINCT user_id FROM user_roles WHERE role IN ('admin', 'administrator')) AS ur
+GROUP BY t.id, ur.user_id
+ON CONFLICT DO NOTHING;
+
+-- +goose Down
+
+DROP TABLE IF EXISTS admin_chat_reads;
+DROP INDEX IF EXISTS idx_admin_chat_messages_thread;
+ALTER TABLE admin_chat_messages DROP COLUMN IF EXISTS thread_id;
+DROP TABLE IF EXISTS admin_chat_threads;
@@ -0,0 +1,15 @@
+-- +goose Up
+
+-- Полнотекстовый поиск по админ-чату: текст, подпись и имя файла (русская морфология).
+ALTER TABLE admin_chat_messages
+    ADD COLUMN IF NOT EXISTS search_tsv tsvector
+    GENERATED ALWAYS AS (
+        to_tsvector('russian', coalesce(text, '') || ' ' || coalesce(caption, '') || ' ' || coalesce(file_name, ''))
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_admin_chat_messages_search ON admin_chat_messages USING GIN (search_tsv);
+
+-- +goose Down
+
+DROP INDEX IF EXISTS idx_admin_chat_messages_search;
+ALTER TABLE admin_chat_messages DROP COLUMN IF EXISTS search_tsv;
@@ -0,0 +1,24 @@
+-- +goose Up
+
+-- Заявки мастеров на покупку абонемента. Заявку видят все, у кого есть право подтверждать,
+-- поэтому решение принимается один раз: статус меняется только из pending.
+CREATE TABLE IF NOT EXISTS subscription_requests (
+    id              BIGSERIAL PRIMARY KEY,
+    user_id         BIGINT        NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    place           TEXT          NOT NULL,
+    unit            TEXT          NOT NULL,
+    qty             INTEGER       NOT NULL,
+    threshold_total NUMERIC(14,2) NOT NULL DEFAULT 0,
+    price_per_unit  NUMERIC(14,2) NOT NULL DEFAULT 0,
+    status          TEXT          NOT NULL DEFAULT 'pending',
+    decided_by      BIGINT        REFERENCES users(id) ON DELETE SET NULL,
+    decided_at      TIMESTAMPTZ,
+    subscription_id BIGINT        REFERENCES subscriptions(id) ON DELETE SET NULL,
+    created_at      TIMESTAMPTZ   NOT NULL DEFAULT now(),
+    CONSTRAINT chk_subscription_requests_status CHECK (status IN ('pending', 'approved', 'rejected'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_requests_user ON subscription_requests (user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS subscription_requests;
@@ -0,0 +1,25 @@
+-- +goose Up
+
+-- Статус пользователя до блокировки: разблокировка возвращает его, а не открывает доступ
+-- тем, кого ещё не одобрили или отклонили.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS status_before_block TEXT;
+
+-- уже заблокированные: статус до блокировки берём из журнала изменений,
+-- если его там нет — пользователь снова ждёт подтверждения
+UPDATE users AS u
+SET status_before_block = COALESCE((
+    SELECT e.before ->> 'status'
+    FROM audit_events e
+    WHERE e.entity = 'user'
+      AND e.entity_id = u.id
+      AND e.after ->> 'status' = 'blocked'
+      AND e.before ->> 'status' IN ('pending', 'approved', 'rejected')
+    ORDER BY e.created_at DESC, e.id DESC
+    LIMIT 1
+), 'pending')
+WHERE u.status = 'blocked';
+
+-- +goose Down
+ALTER TABLE users
+    DROP COLUMN IF EXISTS status_before_block;
@@ -0,0 +1,30 @@
+-- +goose Up
+
+-- search_norm схлопывает пробелы и обрезает края так же, как materials.NormalizeSearch:
+-- «L' Oreal  Paris» и запрос «l oreal paris» дают одну и ту же строку.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION search_norm(t TEXT) RETURNS TEXT
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE AS
+$$
+SELECT btrim(regexp_replace(translate(lower(COALESCE(t, '')), 'ё''’`´".,-_/()', 'е'), '\s+', ' ', 'g'))
+$$;
+-- +goose StatementEnd
+
+-- индексы по search_norm построены по старым значениям функции
+REINDEX INDEX idx_materials_name_trgm;
+REINDEX INDEX idx_material_brands_name_trgm;
+REINDEX INDEX idx_material_brand_aliases_trgm;
+
+-- +goose Down
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION search_norm(t TEXT) RETURNS TEXT
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE AS
+$$
+SELECT translate(lower(COALESCE(t, '')), 'ё''’`´".,-_/()', 'е')
+$$;
+-- +goose StatementEnd
+
+REINDEX INDEX idx_materials_name_trgm;
+REINDEX INDEX idx_material_brands_name_trgm;
+REINDEX INDEX idx_material_brand_aliases_trgm;
@@ -0,0 +1,26 @@
+-- +goose Up
+
+-- Момент одобрения заявки: от него считается «первый месяц» мастера для скидок,
+-- а не от первого /start, который мог быть задолго до подтверждения.
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS approved_at TIMESTAMPTZ;
+
+-- уже одобренные: момент одобрения берём из журнала изменений,
+-- если его там нет — считаем от регистрации
+UPDATE users AS u
+SET approved_at = COALESCE((
+    SELECT e.created_at
+    FROM audit_events e
+    WHERE e.entity = 'user'
+      AND e.entity_id = u.id
+      AND e.before ->> 'status' = 'pending'
+      AND e.after ->> 'status' = 'approved'
+    ORDER BY e.created_at, e.id
+    LIMIT 1
+), u.created_at)
+WHERE u.status = 'approved'
+   OR (u.status = 'blocked' AND u.status_before_block = 'approved');
+
+-- +goose Down
+ALTER TABLE users
+    DROP COLUMN IF EXISTS approved_at;